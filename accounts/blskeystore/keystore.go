@@ -0,0 +1,347 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+/*
+Package blskeystore stores the BLS secret keys of validators encrypted as
+specified by EIP-2335, the format the deposit and validator tooling of other
+clients reads and writes, one key per file.
+
+The crypto is documented at https://eips.ethereum.org/EIPS/eip-2335
+*/
+package blskeystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/n42blockchain/N42/accounts/keystore"
+	"github.com/n42blockchain/N42/common/crypto/bls"
+	"github.com/n42blockchain/N42/common/types"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	version = 4
+
+	kdfScrypt      = "scrypt"
+	kdfPBKDF2      = "pbkdf2"
+	checksumSHA256 = "sha256"
+	cipherAES128   = "aes-128-ctr"
+	prfHMACSHA256  = "hmac-sha256"
+
+	scryptR  = 8
+	kdfDKLen = 32
+)
+
+var (
+	// ErrDecrypt is returned when a keystore cannot be decrypted with the
+	// password given.
+	ErrDecrypt = errors.New("could not decrypt key with given password")
+	// ErrExists is returned when storing a key which is in the keystore already.
+	ErrExists = errors.New("key is in the keystore already")
+	// ErrNotFound is returned when the key looked up is not in the keystore.
+	ErrNotFound = errors.New("key not found in the keystore")
+)
+
+// Keystore is a BLS secret key encrypted as specified by EIP-2335.
+type Keystore struct {
+	Crypto      Crypto `json:"crypto"`
+	Description string `json:"description"`
+	Pubkey      string `json:"pubkey"`
+	Path        string `json:"path"`
+	UUID        string `json:"uuid"`
+	Version     int    `json:"version"`
+}
+
+// Crypto holds the modules deriving the decryption key from the password,
+// checking it and decrypting the secret key.
+type Crypto struct {
+	KDF      Module `json:"kdf"`
+	Checksum Module `json:"checksum"`
+	Cipher   Module `json:"cipher"`
+}
+
+// Module is a function of a keystore with its parameters and message.
+type Module struct {
+	Function string `json:"function"`
+	Params   Params `json:"params"`
+	Message  string `json:"message"`
+}
+
+// Params are the parameters of the modules, each one using its own.
+type Params struct {
+	DKLen int    `json:"dklen,omitempty"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+	Salt  string `json:"salt,omitempty"`
+	IV    string `json:"iv,omitempty"`
+}
+
+// Encrypt encrypts key with password using scrypt, the weaker parameters of
+// the account keystore if light is set. path is the EIP-2334 derivation path of
+// the key, empty if it was not derived.
+func Encrypt(key bls.SecretKey, password, description, path string, light bool) (*Keystore, error) {
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if light {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	kdf := Module{
+		Function: kdfScrypt,
+		Params:   Params{DKLen: kdfDKLen, N: scryptN, R: scryptR, P: scryptP, Salt: hex.EncodeToString(salt)},
+	}
+	dk, err := deriveKey(kdf, password)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aesCTR(dk[:16], iv, key.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{
+		Crypto: Crypto{
+			KDF: kdf,
+			Checksum: Module{
+				Function: checksumSHA256,
+				Message:  hex.EncodeToString(checksum(dk, cipherText)),
+			},
+			Cipher: Module{
+				Function: cipherAES128,
+				Params:   Params{IV: hex.EncodeToString(iv)},
+				Message:  hex.EncodeToString(cipherText),
+			},
+		},
+		Description: description,
+		Pubkey:      hex.EncodeToString(key.PublicKey().Marshal()),
+		Path:        path,
+		UUID:        uuid.NewString(),
+		Version:     version,
+	}, nil
+}
+
+// Decrypt returns the secret key of ks, checking it belongs to its public key.
+func Decrypt(ks *Keystore, password string) (bls.SecretKey, error) {
+	if ks.Version != version {
+		return nil, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+	if ks.Crypto.Checksum.Function != checksumSHA256 {
+		return nil, fmt.Errorf("unsupported checksum function %q", ks.Crypto.Checksum.Function)
+	}
+	if ks.Crypto.Cipher.Function != cipherAES128 {
+		return nil, fmt.Errorf("unsupported cipher function %q", ks.Crypto.Cipher.Function)
+	}
+	cipherText, err := hex.DecodeString(ks.Crypto.Cipher.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cipher message: %w", err)
+	}
+	iv, err := hex.DecodeString(ks.Crypto.Cipher.Params.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid cipher iv")
+	}
+	sum, err := hex.DecodeString(ks.Crypto.Checksum.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum message: %w", err)
+	}
+	dk, err := deriveKey(ks.Crypto.KDF, password)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(checksum(dk, cipherText), sum) {
+		return nil, ErrDecrypt
+	}
+	secret, err := aesCTR(dk[:16], iv, cipherText)
+	if err != nil {
+		return nil, err
+	}
+	key, err := bls.SecretKeyFromBytes(secret)
+	if err != nil {
+		return nil, err
+	}
+	if ks.Pubkey != "" && !strings.EqualFold(hex.EncodeToString(key.PublicKey().Marshal()), strings.TrimPrefix(ks.Pubkey, "0x")) {
+		return nil, fmt.Errorf("key content mismatch: have public key %x, want %s", key.PublicKey().Marshal(), ks.Pubkey)
+	}
+	return key, nil
+}
+
+// deriveKey derives the decryption key from password with the kdf module.
+func deriveKey(kdf Module, password string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdf.Params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kdf salt: %w", err)
+	}
+	if kdf.Params.DKLen < kdfDKLen {
+		return nil, fmt.Errorf("kdf key length %d is shorter than %d", kdf.Params.DKLen, kdfDKLen)
+	}
+	pw := normalizePassword(password)
+	switch kdf.Function {
+	case kdfScrypt:
+		return scrypt.Key(pw, salt, kdf.Params.N, kdf.Params.R, kdf.Params.P, kdf.Params.DKLen)
+	case kdfPBKDF2:
+		if kdf.Params.PRF != prfHMACSHA256 {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %q", kdf.Params.PRF)
+		}
+		return pbkdf2.Key(pw, salt, kdf.Params.C, kdf.Params.DKLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf function %q", kdf.Function)
+	}
+}
+
+// normalizePassword returns the NFKD form of password without its control
+// codes, as the password is hashed.
+func normalizePassword(password string) []byte {
+	return []byte(strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, norm.NFKD.String(password)))
+}
+
+// checksum returns the checksum of cipherText for the decryption key dk.
+func checksum(dk, cipherText []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, dk[16:32]...), cipherText...))
+	return sum[:]
+}
+
+func aesCTR(key, iv, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+// KeyStore manages a directory of validator keys, one EIP-2335 keystore per
+// file named after its public key.
+type KeyStore struct {
+	dir string
+}
+
+// NewKeyStore creates a keystore for the directory dir.
+func NewKeyStore(dir string) *KeyStore {
+	return &KeyStore{dir: dir}
+}
+
+// Dir returns the directory of the keystore.
+func (ks *KeyStore) Dir() string {
+	return ks.dir
+}
+
+// List returns the keys of the keystore, sorted by public key. Files which are
+// not keystores are skipped.
+func (ks *KeyStore) List() ([]*Keystore, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*Keystore
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		key, err := Load(filepath.Join(ks.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Pubkey < keys[j].Pubkey })
+	return keys, nil
+}
+
+// Find returns the key with the hex encoded public key pubkey.
+func (ks *KeyStore) Find(pubkey string) (*Keystore, error) {
+	pubkey = strings.TrimPrefix(strings.ToLower(pubkey), "0x")
+	key, err := Load(ks.path(pubkey))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// Store writes key into the keystore, and returns the file written.
+func (ks *KeyStore) Store(key *Keystore) (string, error) {
+	pubkey := strings.TrimPrefix(strings.ToLower(key.Pubkey), "0x")
+	if _, err := hex.DecodeString(pubkey); err != nil || len(pubkey) != 2*types.PublicKeyLength {
+		return "", fmt.Errorf("invalid public key %q", key.Pubkey)
+	}
+	file := ks.path(pubkey)
+	if _, err := os.Stat(file); err == nil {
+		return "", ErrExists
+	}
+	content, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return "", err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmp, file)
+}
+
+func (ks *KeyStore) path(pubkey string) string {
+	return filepath.Join(ks.dir, "keystore-"+pubkey+".json")
+}
+
+// Load reads the keystore in file.
+func Load(file string) (*Keystore, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key := new(Keystore)
+	if err := json.Unmarshal(content, key); err != nil {
+		return nil, fmt.Errorf("invalid keystore %s: %w", file, err)
+	}
+	if key.Version != version || key.Pubkey == "" {
+		return nil, fmt.Errorf("invalid keystore %s: version %d, public key %q", file, key.Version, key.Pubkey)
+	}
+	key.Pubkey = strings.TrimPrefix(strings.ToLower(key.Pubkey), "0x")
+	return key, nil
+}
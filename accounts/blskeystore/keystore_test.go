@@ -0,0 +1,142 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package blskeystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n42blockchain/N42/common/crypto/bls"
+)
+
+// The test vectors of EIP-2335
+const (
+	vectorPassword = "𝔱𝔢𝔰𝔱𝔭𝔞𝔰𝔰𝔴𝔬𝔯𝔡🔑"
+	vectorSecret   = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	vectorPubkey   = "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07"
+)
+
+func TestDecryptTestVectors(t *testing.T) {
+	for _, file := range []string{"testdata/scrypt.json", "testdata/pbkdf2.json"} {
+		ks, err := Load(file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		key, err := Decrypt(ks, vectorPassword)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if secret := hex.EncodeToString(key.Marshal()); secret != vectorSecret {
+			t.Errorf("%s: secret %s, want %s", file, secret, vectorSecret)
+		}
+		if pubkey := hex.EncodeToString(key.PublicKey().Marshal()); pubkey != vectorPubkey {
+			t.Errorf("%s: public key %s, want %s", file, pubkey, vectorPubkey)
+		}
+		if _, err := Decrypt(ks, "testpassword"); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: wrong password: have %v, want %v", file, err, ErrDecrypt)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := bls.RandKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := Encrypt(key, "foo", "test key", "m/12381/3600/0/0/0", true)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if ks.Pubkey != hex.EncodeToString(key.PublicKey().Marshal()) {
+		t.Errorf("public key %s of another key", ks.Pubkey)
+	}
+	decrypted, err := Decrypt(ks, "foo")
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted.Marshal(), key.Marshal()) {
+		t.Error("decrypted another key")
+	}
+	if _, err := Decrypt(ks, "bar"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong password: have %v, want %v", err, ErrDecrypt)
+	}
+
+	// The public key must match the decrypted secret key
+	other, err := bls.RandKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Pubkey = hex.EncodeToString(other.PublicKey().Marshal())
+	if _, err := Decrypt(ks, "foo"); err == nil {
+		t.Error("decrypted a keystore with the public key of another key")
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	store := NewKeyStore(filepath.Join(t.TempDir(), "validators"))
+	if keys, err := store.List(); err != nil || len(keys) != 0 {
+		t.Fatalf("missing directory listed %v, %v", keys, err)
+	}
+
+	var pubkeys []string
+	for i := 0; i < 2; i++ {
+		key, err := bls.RandKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ks, err := Encrypt(key, "foo", "", "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Store(ks); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		if _, err := store.Store(ks); !errors.Is(err, ErrExists) {
+			t.Errorf("stored again: have %v, want %v", err, ErrExists)
+		}
+		pubkeys = append(pubkeys, ks.Pubkey)
+	}
+	if pubkeys[0] > pubkeys[1] {
+		pubkeys[0], pubkeys[1] = pubkeys[1], pubkeys[0]
+	}
+	// Files which are not keystores are skipped
+	if err := os.WriteFile(filepath.Join(store.Dir(), "notes.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Pubkey != pubkeys[0] || keys[1].Pubkey != pubkeys[1] {
+		t.Fatalf("listed %d keys, want %v", len(keys), pubkeys)
+	}
+	found, err := store.Find("0x" + pubkeys[1])
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if _, err := Decrypt(found, "foo"); err != nil {
+		t.Errorf("failed to decrypt the stored key: %v", err)
+	}
+	if _, err := store.Find(vectorPubkey); !errors.Is(err, ErrNotFound) {
+		t.Errorf("have %v, want %v", err, ErrNotFound)
+	}
+}
@@ -17,15 +17,13 @@
 package main
 
 import (
-	"fmt"
+	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/log"
 	"github.com/urfave/cli/v2"
-	"net/http"
-	_ "net/http/pprof"
+	"math/big"
 	"os"
 	"os/signal"
-	"runtime"
 	"strings"
 	"syscall"
 	"time"
@@ -36,7 +34,10 @@ import (
 	"github.com/n42blockchain/N42/cmd/utils"
 
 	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/debug"
 	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/internal/tracing"
+	n42utils "github.com/n42blockchain/N42/utils"
 )
 
 func appRun(ctx *cli.Context) error {
@@ -55,30 +56,39 @@ func appRun(ctx *cli.Context) error {
 		DefaultConfig.P2PCfg.BootstrapNodeAddr = p2pBootstrapNode.Value()
 		DefaultConfig.P2PCfg.DenyListCIDR = p2pDenyList.Value()
 
+		DefaultConfig.NodeCfg.RPCAllowIPs = rpcAllowIPs.Value()
+		DefaultConfig.GPO.MaxPrice = big.NewInt(gpoMaxPrice)
+		DefaultConfig.GPO.IgnorePrice = big.NewInt(gpoIgnorePrice)
+		DefaultConfig.Miner.GasPrice = big.NewInt(minerGasPrice)
+
 		//
 		DefaultConfig.P2PCfg.DataDir = DefaultConfig.NodeCfg.DataDir
 	}
 
+	var devDataDir string
+	if DefaultConfig.NodeCfg.Dev {
+		dir, err := setupDevMode(ctx, &DefaultConfig)
+		if err != nil {
+			return err
+		}
+		devDataDir = dir
+	}
+
+	// Pick up genesis, chain config and bootnodes of the selected network.
+	DefaultConfig.ApplyNetworkPreset()
+
 	log.Init(DefaultConfig.NodeCfg, DefaultConfig.LoggerCfg)
 
-	if DefaultConfig.PprofCfg.Pprof {
-		if DefaultConfig.PprofCfg.MaxCpu > 0 {
-			runtime.GOMAXPROCS(DefaultConfig.PprofCfg.MaxCpu)
-		}
-		if DefaultConfig.PprofCfg.TraceMutex {
-			runtime.SetMutexProfileFraction(1)
-		}
-		if DefaultConfig.PprofCfg.TraceBlock {
-			runtime.SetBlockProfileRate(1)
-		}
+	if err := debug.Setup(DefaultConfig.PprofCfg); err != nil {
+		return err
+	}
+	defer debug.Exit(DefaultConfig.PprofCfg)
 
-		go func() {
-			if err := http.ListenAndServe(fmt.Sprintf(":%d", DefaultConfig.PprofCfg.Port), nil); err != nil {
-				log.Error("failed to setup go pprof", "err", err)
-				os.Exit(0)
-			}
-		}()
+	stopTracing, err := tracing.Setup(DefaultConfig.TracingCfg)
+	if err != nil {
+		return err
 	}
+	defer stopTracing()
 
 	stack, err := node.NewNode(ctx, &DefaultConfig)
 	if err != nil {
@@ -86,6 +96,12 @@ func appRun(ctx *cli.Context) error {
 		return err
 	}
 
+	if DefaultConfig.NodeCfg.Dev {
+		if err := unlockDevAccount(stack); err != nil {
+			return err
+		}
+	}
+
 	StartNode(ctx, stack, false)
 
 	// Unlock any account specifically requested
@@ -130,6 +146,9 @@ func appRun(ctx *cli.Context) error {
 
 	stack.Wait()
 
+	if devDataDir != "" {
+		os.RemoveAll(devDataDir)
+	}
 	return nil
 }
 
@@ -181,18 +200,28 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 	if err := stack.Start(); err != nil {
 		log.Critf("Error starting protocol stack: %v", err)
 	}
+	handleDiagnosticSignal(stack)
 	go func() {
 		sigc := make(chan os.Signal, 1)
 		signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
 		defer signal.Stop(sigc)
 
-		if ctx.IsSet(MinFreeDiskSpaceFlag.Name) {
-			minFreeDiskSpace := ctx.Int(MinFreeDiskSpaceFlag.Name)
-			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024*1024)
+		if minFreeDiskSpace := DefaultConfig.NodeCfg.MinFreeDiskSpace; minFreeDiskSpace > 0 {
+			go monitorFreeDiskSpace(sigc, stack, uint64(minFreeDiskSpace)*1024*1024*1024)
+		}
+
+		// Tell systemd the node is up, and keep feeding its watchdog while
+		// the database answers so a hung node gets restarted.
+		sdNotify(daemon.SdNotifyReady)
+		var watchdog <-chan time.Time
+		if ticker := sdWatchdog(); ticker != nil {
+			defer ticker.Stop()
+			watchdog = ticker.C
 		}
 
 		shutdown := func() {
 			log.Info("Got interrupt, shutting down...")
+			sdNotify(daemon.SdNotifyStopping)
 			go stack.Close()
 			for i := 10; i > 0; i-- {
 				<-sigc
@@ -203,39 +232,58 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 			panic("Panic closing the ast node")
 		}
 
-		if isConsole {
-			// In JS console mode, SIGINT is ignored because it's handled by the console.
-			// However, SIGTERM still shuts down the node.
-			for {
-				sig := <-sigc
-				if sig == syscall.SIGTERM {
-					shutdown()
-					return
+		for {
+			select {
+			case <-watchdog:
+				if !stack.Responsive() {
+					log.Warn("Not feeding the systemd watchdog, the database is unresponsive")
+					continue
+				}
+				sdNotify(daemon.SdNotifyWatchdog)
+			case sig := <-sigc:
+				// In JS console mode, SIGINT is ignored because it's handled by the console.
+				// However, SIGTERM still shuts down the node.
+				if isConsole && sig != syscall.SIGTERM {
+					continue
 				}
+				shutdown()
+				return
 			}
-		} else {
-			<-sigc
-			shutdown()
 		}
 	}()
 }
 
-func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
-	if path == "" {
+// monitorFreeDiskSpace watches the free space of the volume holding the data
+// directory. Below twice the critical level it pauses sync, and below the
+// critical level it shuts the node down cleanly before the database runs out
+// of space.
+func monitorFreeDiskSpace(sigc chan os.Signal, stack *node.Node, freeDiskSpaceCritical uint64) {
+	path := stack.InstanceDir()
+	if path == "" || freeDiskSpaceCritical == 0 {
 		return
 	}
+	paused := false
 	for {
-		freeSpace, err := getFreeDiskSpace(path)
+		freeSpace, err := n42utils.FreeDiskSpace(path)
 		if err != nil {
 			log.Warn("Failed to get free disk space", "path", path, "err", err)
 			break
 		}
-		if freeSpace < freeDiskSpaceCritical {
-			log.Error("Low disk space. Gracefully shutting down Geth to prevent database corruption.", "available", types.StorageSize(freeSpace), "path", path)
+		switch {
+		case freeSpace < freeDiskSpaceCritical:
+			log.Error("Low disk space. Gracefully shutting down the node to prevent database corruption.", "available", types.StorageSize(freeSpace), "path", path)
 			sigc <- syscall.SIGTERM
-			break
-		} else if freeSpace < 2*freeDiskSpaceCritical {
-			log.Warn("Disk space is running low. Geth will shutdown if disk space runs below critical level.", "available", types.StorageSize(freeSpace), "critical_level", types.StorageSize(freeDiskSpaceCritical), "path", path)
+			return
+		case freeSpace < 2*freeDiskSpaceCritical:
+			if !paused {
+				stack.PauseSync()
+				paused = true
+			}
+			log.Warn("Disk space is running low, sync is paused. The node will shut down if disk space runs below critical level.", "available", types.StorageSize(freeSpace), "critical_level", types.StorageSize(freeDiskSpaceCritical), "path", path)
+		case paused:
+			stack.ResumeSync()
+			paused = false
+			log.Info("Disk space recovered, sync resumed", "available", types.StorageSize(freeSpace), "path", path)
 		}
 		time.Sleep(30 * time.Second)
 	}
@@ -0,0 +1,220 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/urfave/cli/v2"
+)
+
+// chainFileLogInterval is how often the progress of a chain export or import
+// is logged.
+const chainFileLogInterval = 8 * time.Second
+
+var (
+	ImportTrustedFlag = &cli.BoolFlag{
+		Name:  "trusted",
+		Usage: "Skip verifying the seals of the imported blocks, for files from a trusted source",
+	}
+
+	importCommand = &cli.Command{
+		Name:      "import",
+		Usage:     "Import blocks from chain files",
+		ArgsUsage: "<file>...",
+		Action:    importChain,
+		Flags: []cli.Flag{
+			DataDirFlag,
+			AncientDirFlag,
+			ImportTrustedFlag,
+		},
+		Description: `
+The import command inserts the blocks of the given chain files, written by the
+export command and gzipped if their name ends in .gz, into the chain. Blocks are
+validated and executed as if received from the network, blocks the chain
+already holds are skipped. With --trusted the seals of the blocks are not
+verified, which is only safe for files from a trusted source.`,
+	}
+)
+
+// exportChain writes the canonical blocks to a chain file.
+func exportChain(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return cli.ShowSubcommandHelp(ctx)
+	}
+	if ctx.NArg() != 1 && ctx.NArg() != 3 {
+		return fmt.Errorf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	from, to := uint64(1), stack.BlockChain().CurrentBlock().Number64().Uint64()
+	if ctx.NArg() == 3 {
+		if from, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			return fmt.Errorf("invalid first block: %w", err)
+		}
+		if to, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			return fmt.Errorf("invalid last block: %w", err)
+		}
+	}
+	if from == 0 || from > to {
+		return fmt.Errorf("nothing to export from block %d to %d", from, to)
+	}
+
+	path := ctx.Args().First()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var (
+		w  io.Writer = f
+		gz *gzip.Writer
+	)
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+
+	begin, logged := time.Now(), time.Now()
+	for start := from; start <= to; start += eraImportBatch {
+		end := min(start+eraImportBatch-1, to)
+		if err := stack.Database().View(ctx.Context, func(tx kv.Tx) error {
+			return rawdb.ExportChain(tx, w, start, end)
+		}); err != nil {
+			return err
+		}
+		if time.Since(logged) > chainFileLogInterval {
+			log.Info("Exporting blocks", "block", end, "last", to, "elapsed", time.Since(begin))
+			logged = time.Now()
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	log.Info("Exported blocks", "file", path, "from", from, "to", to, "elapsed", time.Since(begin))
+	return nil
+}
+
+func importChain(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("missing chain files")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	for _, path := range ctx.Args().Slice() {
+		begin := time.Now()
+		imported, err := importChainFile(stack, path, ctx.Bool(ImportTrustedFlag.Name))
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", path, err)
+		}
+		log.Info("Imported chain file", "file", path, "blocks", imported, "elapsed", time.Since(begin))
+	}
+	return nil
+}
+
+// importChainFile inserts the blocks of the chain file at path above the
+// current block into the chain and returns their number.
+func importChainFile(stack *node.Node, path string, trusted bool) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	chain := stack.BlockChain()
+	insertChain := chain.InsertChain
+	if trusted {
+		insertChain = chain.InsertChainWithoutSealVerification
+	}
+	var (
+		imported int
+		batch    []block.IBlock
+		logged   = time.Now()
+	)
+	insert := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := insertChain(batch)
+		if err != nil {
+			imported += n
+			return fmt.Errorf("failed to insert blocks from %d: %w", batch[0].Number64().Uint64(), err)
+		}
+		imported += len(batch)
+		batch = nil
+		if time.Since(logged) > chainFileLogInterval {
+			log.Info("Importing blocks", "file", path, "block", chain.CurrentBlock().Number64().Uint64(), "imported", imported)
+			logged = time.Now()
+		}
+		return nil
+	}
+
+	current := chain.CurrentBlock().Number64().Uint64()
+	reader := rawdb.NewChainFileReader(r)
+	for {
+		b, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+		if b.Number64().Uint64() <= current {
+			continue
+		}
+		if batch = append(batch, b); len(batch) == eraImportBatch {
+			if err := insert(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	return imported, insert()
+}
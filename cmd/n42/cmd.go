@@ -17,6 +17,7 @@
 package main
 
 import (
+	"github.com/n42blockchain/N42/conf"
 	"github.com/n42blockchain/N42/params/networkname"
 	"github.com/urfave/cli/v2"
 )
@@ -33,6 +34,13 @@ var (
 	p2pStaticPeers   = cli.NewStringSlice()
 	p2pBootstrapNode = cli.NewStringSlice()
 	p2pDenyList      = cli.NewStringSlice()
+
+	rpcAllowIPs = cli.NewStringSlice()
+
+	gpoMaxPrice    = conf.DefaultMaxPrice.Int64()
+	gpoIgnorePrice = conf.DefaultIgnorePrice.Int64()
+
+	minerGasPrice = DefaultConfig.Miner.GasPrice.Int64()
 )
 
 var rootCmd []*cli.Command
@@ -109,6 +117,79 @@ var rpcFlags = []cli.Flag{
 		Value:       "",
 		Destination: &DefaultConfig.NodeCfg.HTTPCors,
 	},
+	&cli.StringFlag{
+		Name:        "http.tlscert",
+		Usage:       "Path to the TLS certificate used to serve the HTTP-RPC server over HTTPS",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.HTTPTLSCert,
+	},
+	&cli.StringFlag{
+		Name:        "http.tlskey",
+		Usage:       "Path to the TLS private key matching --http.tlscert",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.HTTPTLSKey,
+	},
+	&cli.IntFlag{
+		Name:        "http.gzip.minsize",
+		Usage:       "Minimum HTTP-RPC response size in bytes to gzip compress (-1 = disable compression)",
+		Value:       DefaultConfig.NodeCfg.HTTPGzipMinSize,
+		Destination: &DefaultConfig.NodeCfg.HTTPGzipMinSize,
+	},
+	&cli.StringFlag{
+		Name:        "http.allow-methods",
+		Usage:       "Comma separated methods (or namespace_* wildcards) allowed over HTTP-RPC, empty allows all of --http.api",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.HTTPAllowMethods,
+	},
+	&cli.StringFlag{
+		Name:        "http.deny-methods",
+		Usage:       "Comma separated methods (or namespace_* wildcards) rejected over HTTP-RPC, e.g. debug_setHead",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.HTTPDenyMethods,
+	},
+	&cli.BoolFlag{
+		Name:        "graphql",
+		Usage:       "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
+		Value:       false,
+		Destination: &DefaultConfig.NodeCfg.GraphQL,
+	},
+	&cli.StringFlag{
+		Name:        "graphql.corsdomain",
+		Usage:       "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.GraphQLCors,
+	},
+	&cli.StringFlag{
+		Name:        "graphql.vhosts",
+		Usage:       "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value:       DefaultConfig.NodeCfg.GraphQLVHosts,
+		Destination: &DefaultConfig.NodeCfg.GraphQLVHosts,
+	},
+
+	&cli.StringFlag{
+		Name:        "private.api.addr",
+		Usage:       "Address of the gRPC server streaming reads of the chain database with the remote KV protocol, e.g. 127.0.0.1:9090 (disabled if empty)",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.PrivateAPIAddr,
+	},
+	&cli.IntFlag{
+		Name:        "private.api.ratelimit",
+		Usage:       "Maximum number of concurrent streams per connection to the remote database server (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.PrivateAPIMaxStreams,
+		Destination: &DefaultConfig.NodeCfg.PrivateAPIMaxStreams,
+	},
+	&cli.StringFlag{
+		Name:        "private.api.tls.cert",
+		Usage:       "Certificate file of the remote database server, served over TLS when set with private.api.tls.key",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.PrivateAPITLSCert,
+	},
+	&cli.StringFlag{
+		Name:        "private.api.tls.key",
+		Usage:       "Private key file of the remote database server",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.PrivateAPITLSKey,
+	},
 
 	&cli.BoolFlag{
 		Name:        "ws",
@@ -142,6 +223,209 @@ var rpcFlags = []cli.Flag{
 		Value:       "",
 		Destination: &DefaultConfig.NodeCfg.WSOrigins,
 	},
+	&cli.StringFlag{
+		Name:        "ws.tlscert",
+		Usage:       "Comma separated TLS certificates used to serve the WS-RPC server over wss",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.WSTLSCert,
+	},
+	&cli.StringFlag{
+		Name:        "ws.tlskey",
+		Usage:       "Comma separated TLS private keys matching --ws.tlscert",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.WSTLSKey,
+	},
+	&cli.StringFlag{
+		Name:        "ws.allow-methods",
+		Usage:       "Comma separated methods (or namespace_* wildcards) allowed over WS-RPC, empty allows all of --ws.api",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.WSAllowMethods,
+	},
+	&cli.StringFlag{
+		Name:        "ws.deny-methods",
+		Usage:       "Comma separated methods (or namespace_* wildcards) rejected over WS-RPC",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.WSDenyMethods,
+	},
+	&cli.IntFlag{
+		Name:        "ws.notification-buffer",
+		Usage:       "Maximum number of subscription notifications queued per WS-RPC connection before the client is dropped",
+		Value:       DefaultConfig.NodeCfg.WSNotificationBuffer,
+		Destination: &DefaultConfig.NodeCfg.WSNotificationBuffer,
+	},
+
+	&cli.Float64Flag{
+		Name:        "rpc.ratelimit",
+		Usage:       "Maximum HTTP-RPC requests / WS connections per second from a single IP, the messages of an open WS connection are not limited (0 = unlimited)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCRateLimit,
+	},
+	&cli.IntFlag{
+		Name:        "rpc.ratelimit.burst",
+		Usage:       "Number of requests a single IP may burst above --rpc.ratelimit",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCRateBurst,
+	},
+	&cli.StringFlag{
+		Name:        "rpc.trustedproxies",
+		Usage:       "Comma separated IPs/CIDRs of reverse proxies whose X-Forwarded-For header is trusted",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.RPCTrustedProxies,
+	},
+	&cli.StringSliceFlag{
+		Name:        "rpc.allow-ip",
+		Usage:       "CIDR subnets (or IPs) allowed to connect to the HTTP, WS and auth RPC servers, default allows all",
+		Destination: rpcAllowIPs,
+	},
+	&cli.IntFlag{
+		Name:        "rpc.batch-request-limit",
+		Usage:       "Maximum number of requests in a batch (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.BatchRequestLimit,
+		Destination: &DefaultConfig.NodeCfg.BatchRequestLimit,
+	},
+	&cli.IntFlag{
+		Name:        "rpc.batch-response-max-size",
+		Usage:       "Maximum number of bytes returned from a batched call (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.BatchResponseMaxSize,
+		Destination: &DefaultConfig.NodeCfg.BatchResponseMaxSize,
+	},
+	&cli.DurationFlag{
+		Name:        "rpc.timeout",
+		Usage:       "Maximum execution time of a single RPC call, the call is aborted when exceeded (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.RPCExecutionTimeout,
+		Destination: &DefaultConfig.NodeCfg.RPCExecutionTimeout,
+	},
+	&cli.Float64Flag{
+		Name:        "rpc.accesslog.rate",
+		Usage:       "Fraction of RPC calls written to the access log, from 0 (disabled) to 1 (all calls)",
+		Value:       DefaultConfig.NodeCfg.RPCAccessLogRate,
+		Destination: &DefaultConfig.NodeCfg.RPCAccessLogRate,
+	},
+	&cli.StringFlag{
+		Name:        "rpc.accesslog.redact",
+		Usage:       "Comma separated methods or namespace wildcards whose parameters are not written to the access log",
+		Value:       DefaultConfig.NodeCfg.RPCAccessLogRedact,
+		Destination: &DefaultConfig.NodeCfg.RPCAccessLogRedact,
+	},
+	&cli.DurationFlag{
+		Name:        "rpc.filter-timeout",
+		Usage:       "Time after which an installed filter that is not polled with eth_getFilterChanges is removed",
+		Value:       DefaultConfig.NodeCfg.FilterTimeout,
+		Destination: &DefaultConfig.NodeCfg.FilterTimeout,
+	},
+	&cli.Uint64Flag{
+		Name:        "rpc.logs.max-range",
+		Usage:       "Maximum number of blocks a single eth_getLogs query may span (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.LogsMaxBlockRange,
+		Destination: &DefaultConfig.NodeCfg.LogsMaxBlockRange,
+	},
+	&cli.IntFlag{
+		Name:        "rpc.logs.max-results",
+		Usage:       "Maximum number of logs a single eth_getLogs query may return (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.LogsMaxResults,
+		Destination: &DefaultConfig.NodeCfg.LogsMaxResults,
+	},
+	&cli.BoolFlag{
+		Name:        "rpc.logs.index",
+		Usage:       "Index the addresses and topics of logs in the background to speed up eth_getLogs over wide block ranges",
+		Value:       DefaultConfig.NodeCfg.LogsIndex,
+		Destination: &DefaultConfig.NodeCfg.LogsIndex,
+	},
+	&cli.BoolFlag{
+		Name:        "debug.badblocks.persist",
+		Usage:       "Store blocks which fail validation in the database for debug_getBadBlocks",
+		Value:       DefaultConfig.NodeCfg.PersistBadBlocks,
+		Destination: &DefaultConfig.NodeCfg.PersistBadBlocks,
+	},
+	&cli.Uint64Flag{
+		Name:        "health.ready.max-blocks-behind",
+		Usage:       "Number of blocks the node may be behind its best peer and still report ready on /readyz",
+		Value:       DefaultConfig.NodeCfg.ReadyMaxBlocksBehind,
+		Destination: &DefaultConfig.NodeCfg.ReadyMaxBlocksBehind,
+	},
+	&cli.IntFlag{
+		Name:        "health.ready.min-peers",
+		Usage:       "Number of connected peers needed to report ready on /readyz",
+		Value:       DefaultConfig.NodeCfg.ReadyMinPeers,
+		Destination: &DefaultConfig.NodeCfg.ReadyMinPeers,
+	},
+	&cli.DurationFlag{
+		Name:        "shutdown.timeout",
+		Usage:       "Time given on shutdown to the RPC requests in flight and the block being imported to finish",
+		Value:       DefaultConfig.NodeCfg.ShutdownTimeout,
+		Destination: &DefaultConfig.NodeCfg.ShutdownTimeout,
+	},
+}
+
+var gpoFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:        "gpo.blocks",
+		Usage:       "Number of recent blocks to check for gas prices",
+		Value:       DefaultConfig.GPO.Blocks,
+		Destination: &DefaultConfig.GPO.Blocks,
+	},
+	&cli.IntFlag{
+		Name:        "gpo.percentile",
+		Usage:       "Suggested gas price is the given percentile of a set of recent transaction gas prices",
+		Value:       DefaultConfig.GPO.Percentile,
+		Destination: &DefaultConfig.GPO.Percentile,
+	},
+	&cli.Int64Flag{
+		Name:        "gpo.maxprice",
+		Usage:       "Maximum transaction priority fee (or gasprice before London fork) to be recommended by gpo, in wei",
+		Value:       gpoMaxPrice,
+		Destination: &gpoMaxPrice,
+	},
+	&cli.Int64Flag{
+		Name:        "gpo.ignoreprice",
+		Usage:       "Gas price below which gpo will ignore transactions, in wei",
+		Value:       gpoIgnorePrice,
+		Destination: &gpoIgnorePrice,
+	},
+	&cli.IntFlag{
+		Name:        "gpo.maxheaderhistory",
+		Usage:       "Maximum number of blocks eth_feeHistory serves when no reward percentiles are requested",
+		Value:       DefaultConfig.GPO.MaxHeaderHistory,
+		Destination: &DefaultConfig.GPO.MaxHeaderHistory,
+	},
+	&cli.IntFlag{
+		Name:        "gpo.maxblockhistory",
+		Usage:       "Maximum number of blocks eth_feeHistory serves when reward percentiles are requested",
+		Value:       DefaultConfig.GPO.MaxBlockHistory,
+		Destination: &DefaultConfig.GPO.MaxBlockHistory,
+	},
+}
+
+var minerFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:        "miner.extradata",
+		Usage:       "Block extra data set by the miner, at most 32 bytes",
+		Destination: &DefaultConfig.Miner.ExtraData,
+	},
+	&cli.Uint64Flag{
+		Name:        "miner.gaslimit",
+		Usage:       "Target gas ceiling for mined blocks",
+		Value:       DefaultConfig.Miner.GasCeil,
+		Destination: &DefaultConfig.Miner.GasCeil,
+	},
+	&cli.Int64Flag{
+		Name:        "miner.gasprice",
+		Usage:       "Minimum priority fee (or gasprice before London fork) of the transactions included in mined blocks, in wei",
+		Value:       minerGasPrice,
+		Destination: &minerGasPrice,
+	},
+	&cli.DurationFlag{
+		Name:        "miner.recommit",
+		Usage:       "Time interval to recreate the block being mined with the newly arrived transactions",
+		Value:       DefaultConfig.Miner.Recommit,
+		Destination: &DefaultConfig.Miner.Recommit,
+	},
+	&cli.StringFlag{
+		Name:        "miner.txordering",
+		Usage:       "Ordering of the transactions in mined blocks: \"tip\" highest effective tip first, or \"fifo\" in arrival order",
+		Value:       DefaultConfig.Miner.TxOrdering,
+		Destination: &DefaultConfig.Miner.TxOrdering,
+	},
 }
 
 var consensusFlag = []cli.Flag{
@@ -163,6 +447,24 @@ var consensusFlag = []cli.Flag{
 		Value:       "",
 		Destination: &DefaultConfig.Miner.Etherbase,
 	},
+	&cli.DurationFlag{
+		Name:        "engine.proposal-deadline",
+		Usage:       "Least time a proposer keeps before the timestamp of its block to collect the signatures of the verifiers",
+		Value:       DefaultConfig.NodeCfg.ProposalDeadline,
+		Destination: &DefaultConfig.NodeCfg.ProposalDeadline,
+	},
+	&cli.DurationFlag{
+		Name:        "engine.max-future-drift",
+		Usage:       "How far ahead of the local clock the timestamp of a received block may be",
+		Value:       DefaultConfig.NodeCfg.MaxFutureDrift,
+		Destination: &DefaultConfig.NodeCfg.MaxFutureDrift,
+	},
+	&cli.StringFlag{
+		Name:        "engine.ntp-server",
+		Usage:       "NTP server the local clock is checked against at startup (empty = no check)",
+		Value:       DefaultConfig.NodeCfg.NTPServer,
+		Destination: &DefaultConfig.NodeCfg.NTPServer,
+	},
 }
 
 var configFlag = []cli.Flag{
@@ -199,18 +501,34 @@ var pprofCfg = []cli.Flag{
 		Value:       0,
 		Destination: &DefaultConfig.PprofCfg.MaxCpu,
 	},
+	&cli.StringFlag{
+		Name:        "pprof.addr",
+		Usage:       "pprof HTTP server listening interface",
+		Value:       "127.0.0.1",
+		Destination: &DefaultConfig.PprofCfg.Addr,
+	},
 	&cli.IntFlag{
 		Name:        "pprof.port",
 		Usage:       "pprof HTTP server listening port",
-		Value:       0,
+		Value:       6060,
 		Destination: &DefaultConfig.PprofCfg.Port,
 	},
+	&cli.StringFlag{
+		Name:        "pprof.cpuprofile",
+		Usage:       "Write a CPU profile to the given file, finished on shutdown",
+		Destination: &DefaultConfig.PprofCfg.CPUProfile,
+	},
+	&cli.StringFlag{
+		Name:        "pprof.memprofile",
+		Usage:       "Write a heap profile to the given file on shutdown",
+		Destination: &DefaultConfig.PprofCfg.MemProfile,
+	},
 }
 
 var loggerFlag = []cli.Flag{
 	&cli.StringFlag{
 		Name:        "log.name",
-		Usage:       "logger file name and path",
+		Usage:       "logger file name and path, relative to <datadir>/log unless absolute (empty disables the log file)",
 		Value:       "ast.log",
 		Destination: &DefaultConfig.LoggerCfg.LogFile,
 	},
@@ -246,6 +564,23 @@ var loggerFlag = []cli.Flag{
 		Value:       false,
 		Destination: &DefaultConfig.LoggerCfg.Compress,
 	},
+	&cli.StringFlag{
+		Name:        "log.console.format",
+		Usage:       "console log format (value:[text,json])",
+		Value:       "text",
+		Destination: &DefaultConfig.LoggerCfg.ConsoleFormat,
+	},
+	&cli.StringFlag{
+		Name:        "log.file.format",
+		Usage:       "logger file format (value:[text,json])",
+		Value:       "json",
+		Destination: &DefaultConfig.LoggerCfg.FileFormat,
+	},
+	&cli.StringFlag{
+		Name:        "log.vmodule",
+		Usage:       "per package output level, as pattern=level entries (e.g. p2p=trace,rawdb=debug)",
+		Destination: &DefaultConfig.LoggerCfg.VModule,
+	},
 }
 var (
 	// P2PNoDiscovery specifies whether we are running a local network and have no need for connecting
@@ -398,11 +733,167 @@ var (
 
 	MinFreeDiskSpaceFlag = &cli.IntFlag{
 		Name:        "data.dir.minfreedisk",
-		Usage:       "Minimum free disk space in GB, once reached triggers auto shut down (default = 10GB, 0 = disabled)",
+		Usage:       "Minimum free disk space in GB, once reached triggers auto shut down. Sync is paused below twice this amount (default = 10GB, 0 = disabled)",
 		Value:       10,
 		Destination: &DefaultConfig.NodeCfg.MinFreeDiskSpace,
 	}
 
+	MaxDataDirSizeFlag = &cli.IntFlag{
+		Name:        "data.dir.maxsize",
+		Usage:       "Size quota of the data directory in GB, warned about as it fills up (0 = no quota)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.MaxDataDirSize,
+	}
+
+	DataDirWarnPercentsFlag = &cli.StringFlag{
+		Name:        "data.dir.warnpercents",
+		Usage:       "Comma separated percentages of the data directory quota at which a warning is logged",
+		Value:       DefaultConfig.NodeCfg.DataDirWarnPercents,
+		Destination: &DefaultConfig.NodeCfg.DataDirWarnPercents,
+	}
+
+	AncientDirFlag = &cli.StringFlag{
+		Name:        "data.dir.ancient",
+		Usage:       "Directory of the freezer holding old blocks, can be on a separate volume (default = inside the data dir)",
+		Destination: &DefaultConfig.NodeCfg.AncientDir,
+	}
+
+	FreezeThresholdFlag = &cli.Uint64Flag{
+		Name:        "data.dir.ancient.threshold",
+		Usage:       "Number of recent blocks kept in the database, older blocks are moved to the freezer (0 = disabled)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.FreezeThreshold,
+	}
+
+	PruneFlag = &cli.StringFlag{
+		Name:        "prune",
+		Usage:       "Pruning mode: archive keeps all data, full prunes the state history, minimal also the receipts and transaction index of old blocks",
+		Value:       DefaultConfig.NodeCfg.Prune,
+		Destination: &DefaultConfig.NodeCfg.Prune,
+	}
+
+	PruneHistoryFlag = &cli.Uint64Flag{
+		Name:        "prune.history",
+		Usage:       "Number of recent blocks whose state history is kept, overriding the pruning mode (0 = mode default)",
+		Destination: &DefaultConfig.NodeCfg.PruneHistory,
+	}
+
+	PruneReceiptsFlag = &cli.Uint64Flag{
+		Name:        "prune.receipts",
+		Usage:       "Number of recent blocks whose receipts and logs are kept, overriding the pruning mode (0 = mode default)",
+		Destination: &DefaultConfig.NodeCfg.PruneReceipts,
+	}
+
+	PruneTxIndexFlag = &cli.Uint64Flag{
+		Name:        "prune.txindex",
+		Usage:       "Number of recent blocks whose transactions can be looked up by hash, overriding the pruning mode (0 = mode default)",
+		Destination: &DefaultConfig.NodeCfg.PruneTxIndex,
+	}
+
+	SenderWorkersFlag = &cli.IntFlag{
+		Name:        "senders.workers",
+		Usage:       "Number of workers recovering transaction senders (0 = number of CPUs)",
+		Destination: &DefaultConfig.NodeCfg.SenderWorkers,
+	}
+
+	ExecWorkersFlag = &cli.IntFlag{
+		Name:        "exec.workers",
+		Usage:       "Number of workers executing the transactions of imported blocks in parallel (0 or 1 = serial)",
+		Destination: &DefaultConfig.NodeCfg.ExecWorkers,
+	}
+
+	ReorgMaxDepthFlag = &cli.Uint64Flag{
+		Name:        "reorg.maxdepth",
+		Usage:       "Most blocks of the canonical chain a reorg may drop, deeper forks halt the import instead (0 = unlimited)",
+		Value:       DefaultConfig.NodeCfg.ReorgMaxDepth,
+		Destination: &DefaultConfig.NodeCfg.ReorgMaxDepth,
+	}
+
+	SyncModeFlag = &cli.StringFlag{
+		Name:        "sync.mode",
+		Usage:       "How an empty node syncs: full executes every block, snap downloads the state of a recent block from peers",
+		Value:       DefaultConfig.NodeCfg.SyncMode,
+		Destination: &DefaultConfig.NodeCfg.SyncMode,
+	}
+
+	SyncCheckpointFlag = &cli.StringFlag{
+		Name:        "sync.checkpoint",
+		Usage:       "Finalized block <number>:<hash> an empty node syncs from without validating the blocks below it (default: the checkpoint of the network)",
+		Destination: &DefaultConfig.NodeCfg.SyncCheckpoint,
+	}
+
+	DisabledStagesFlag = &cli.StringFlag{
+		Name:        "sync.stages.disable",
+		Usage:       "Comma separated optional sync stages to skip: txindex, logindex",
+		Destination: &DefaultConfig.NodeCfg.DisabledStages,
+	}
+
+	HeaderCacheFlag = &cli.IntFlag{
+		Name:        "db.cache.headers",
+		Usage:       "Number of recently read block headers kept decoded in memory (0 = disabled)",
+		Value:       DefaultConfig.DatabaseCfg.HeaderCache,
+		Destination: &DefaultConfig.DatabaseCfg.HeaderCache,
+	}
+
+	BodyCacheFlag = &cli.IntFlag{
+		Name:        "db.cache.bodies",
+		Usage:       "Number of recently read block bodies kept decoded in memory (0 = disabled)",
+		Value:       DefaultConfig.DatabaseCfg.BodyCache,
+		Destination: &DefaultConfig.DatabaseCfg.BodyCache,
+	}
+
+	ReceiptCacheFlag = &cli.IntFlag{
+		Name:        "db.cache.receipts",
+		Usage:       "Number of recently read block receipts kept decoded in memory (0 = disabled)",
+		Value:       DefaultConfig.DatabaseCfg.ReceiptCache,
+		Destination: &DefaultConfig.DatabaseCfg.ReceiptCache,
+	}
+
+	DBCompressionFlag = &cli.BoolFlag{
+		Name:        "db.compression",
+		Usage:       "Compress the transactions and receipts of a new database with zstd, trading CPU for disk space",
+		Destination: &DefaultConfig.DatabaseCfg.Compression,
+	}
+
+	DBMapSizeFlag = &cli.StringFlag{
+		Name:        "db.mapsize",
+		Usage:       "Maximum size of the database",
+		Value:       DefaultConfig.DatabaseCfg.MapSize,
+		Destination: &DefaultConfig.DatabaseCfg.MapSize,
+	}
+
+	DBGrowthStepFlag = &cli.StringFlag{
+		Name:        "db.growthstep",
+		Usage:       "Size the database file grows by when full",
+		Value:       DefaultConfig.DatabaseCfg.GrowthStep,
+		Destination: &DefaultConfig.DatabaseCfg.GrowthStep,
+	}
+
+	DBPageSizeFlag = &cli.StringFlag{
+		Name:        "db.pagesize",
+		Usage:       "Page size of a new database, a power of 2 between 256B and 64KB (default: OS page size)",
+		Destination: &DefaultConfig.DatabaseCfg.PageSize,
+	}
+
+	DBDirtySpaceFlag = &cli.StringFlag{
+		Name:        "db.dirtyspace",
+		Usage:       "Modified pages a write transaction keeps in memory before spilling them to disk (default: 1/21 of the RAM)",
+		Destination: &DefaultConfig.DatabaseCfg.DirtySpace,
+	}
+
+	DBSyncModeFlag = &cli.StringFlag{
+		Name:        "db.sync",
+		Usage:       `Flushing of commits to disk: "durable" on every commit, or "safe-nosync" periodically, losing the last commits on a crash`,
+		Value:       DefaultConfig.DatabaseCfg.SyncMode,
+		Destination: &DefaultConfig.DatabaseCfg.SyncMode,
+	}
+
+	DBReadOnlyFlag = &cli.BoolFlag{
+		Name:        "db.readonly",
+		Usage:       "Open the database read-only, alongside the node owning the data directory, without syncing",
+		Destination: &DefaultConfig.DatabaseCfg.ReadOnly,
+	}
+
 	FromDataDirFlag = &cli.StringFlag{
 		Name:  "chaindata.from",
 		Usage: "source data  dir",
@@ -414,10 +905,27 @@ var (
 
 	ChainFlag = &cli.StringFlag{
 		Name:        "chain",
-		Usage:       "Name of the testnet to join (value:[mainnet,testnet,private])",
+		Usage:       "Name of the network to join (value:[mainnet,testnet,devnet,private])",
 		Value:       networkname.MainnetChainName,
 		Destination: &DefaultConfig.NodeCfg.Chain,
 	}
+	OverrideChainConfigFlag = &cli.BoolFlag{
+		Name:        "override.chainconfig",
+		Usage:       "Start with the chain config of --chain even if it reschedules forks below the head of the database",
+		Destination: &DefaultConfig.NodeCfg.OverrideChainConfig,
+	}
+
+	DevFlag = &cli.BoolFlag{
+		Name:        "dev",
+		Usage:       "Ephemeral single-node developer network with a prefunded, unlocked account, mining enabled and every API served over HTTP and WS",
+		Destination: &DefaultConfig.NodeCfg.Dev,
+	}
+	DevPeriodFlag = &cli.Uint64Flag{
+		Name:        "dev.period",
+		Usage:       "Block period in seconds to use in developer mode (0 = mine only if transaction pending)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.DevPeriod,
+	}
 )
 
 var (
@@ -441,7 +949,7 @@ var (
 	}
 	JWTSecretFlag = &cli.StringFlag{
 		Name:        "authrpc.jwtsecret",
-		Usage:       "Path to a JWT secret to use for authenticated RPC endpoints",
+		Usage:       "Comma separated JWT secret files or directories for authenticated RPC endpoints, generated in <datadir>/jwt.hex if not set",
 		Value:       "",
 		Destination: &DefaultConfig.NodeCfg.JWTSecret,
 	}
@@ -469,6 +977,12 @@ var (
 		TakesFile:   true,
 		Destination: &DefaultConfig.NodeCfg.KeyStoreDir,
 	}
+	ValidatorKeyStoreDirFlag = &cli.PathFlag{
+		Name:        "validator.keystore",
+		Usage:       "Directory for the EIP-2335 keystore of validator keys (default = inside the datadir)",
+		TakesFile:   true,
+		Destination: &DefaultConfig.NodeCfg.ValidatorKeyStoreDir,
+	}
 	InsecureUnlockAllowedFlag = &cli.BoolFlag{
 		Name:        "account.allow.insecure.unlock",
 		Usage:       "Allow insecure account unlocking when account-related RPCs are exposed by http",
@@ -497,12 +1011,38 @@ var (
 	}
 	MetricsPortFlag = &cli.IntFlag{
 		Name: "metrics.port",
-		Usage: `Metrics HTTP server listening port.
+		Usage: `Metrics HTTP server listening port, serving Prometheus metrics at /metrics.
 Please note that --` + MetricsHTTPFlag.Name + ` must be set to start the server.`,
 		Value: 6060,
 		// Category: flags.MetricsCategory,
 		Destination: &DefaultConfig.MetricsCfg.Port,
 	}
+
+	// TracingEnabledFlag Tracing flags
+	TracingEnabledFlag = &cli.BoolFlag{
+		Name:        "tracing",
+		Usage:       "Enable exporting traces to an OpenTelemetry collector",
+		Value:       false,
+		Destination: &DefaultConfig.TracingCfg.Enable,
+	}
+	TracingEndpointFlag = &cli.StringFlag{
+		Name:        "tracing.endpoint",
+		Usage:       "OTLP/HTTP endpoint (host:port) of the OpenTelemetry collector",
+		Value:       "127.0.0.1:4318",
+		Destination: &DefaultConfig.TracingCfg.Endpoint,
+	}
+	TracingInsecureFlag = &cli.BoolFlag{
+		Name:        "tracing.insecure",
+		Usage:       "Send traces over plain HTTP instead of HTTPS",
+		Value:       false,
+		Destination: &DefaultConfig.TracingCfg.Insecure,
+	}
+	TracingSampleRatioFlag = &cli.Float64Flag{
+		Name:        "tracing.sampleratio",
+		Usage:       "Fraction of traces recorded, between 0 and 1",
+		Value:       1,
+		Destination: &DefaultConfig.TracingCfg.SampleRatio,
+	}
 )
 
 var (
@@ -515,7 +1055,34 @@ var (
 	settingFlag = []cli.Flag{
 		DataDirFlag,
 		ChainFlag,
+		OverrideChainConfigFlag,
 		MinFreeDiskSpaceFlag,
+		MaxDataDirSizeFlag,
+		DataDirWarnPercentsFlag,
+		AncientDirFlag,
+		FreezeThresholdFlag,
+		PruneFlag,
+		PruneHistoryFlag,
+		PruneReceiptsFlag,
+		PruneTxIndexFlag,
+		SenderWorkersFlag,
+		ExecWorkersFlag,
+		ReorgMaxDepthFlag,
+		SyncModeFlag,
+		SyncCheckpointFlag,
+		DisabledStagesFlag,
+		HeaderCacheFlag,
+		BodyCacheFlag,
+		ReceiptCacheFlag,
+		DBCompressionFlag,
+		DBMapSizeFlag,
+		DBGrowthStepFlag,
+		DBPageSizeFlag,
+		DBDirtySpaceFlag,
+		DBSyncModeFlag,
+		DBReadOnlyFlag,
+		DevFlag,
+		DevPeriodFlag,
 	}
 	accountFlag = []cli.Flag{
 		PasswordFileFlag,
@@ -531,6 +1098,13 @@ var (
 		MetricsPortFlag,
 	}
 
+	tracingFlags = []cli.Flag{
+		TracingEnabledFlag,
+		TracingEndpointFlag,
+		TracingInsecureFlag,
+		TracingSampleRatioFlag,
+	}
+
 	p2pFlags = []cli.Flag{
 		P2PNoDiscovery,
 		P2PAllowList,
@@ -17,7 +17,6 @@
 package main
 
 import (
-	"github.com/n42blockchain/N42/params"
 	"math/big"
 	"time"
 
@@ -32,6 +31,27 @@ var DefaultConfig = conf.Config{
 		HTTPPort:    "8545",
 		IPCPath:     "ast.ipc",
 		Miner:       false,
+
+		HTTPGzipMinSize:      1024,
+		BatchRequestLimit:    1000,
+		BatchResponseMaxSize: 25 * 1000 * 1000,
+		GraphQLVHosts:        "localhost",
+		WSNotificationBuffer: 10000,
+		RPCAccessLogRedact:   "personal_*,eth_sign*",
+		FilterTimeout:        5 * time.Minute,
+		LogsMaxBlockRange:    10000,
+		LogsMaxResults:       10000,
+		LogsIndex:            true,
+		Prune:                "archive",
+		SyncMode:             "full",
+		ReadyMaxBlocksBehind: 16,
+		ReadyMinPeers:        1,
+		ShutdownTimeout:      30 * time.Second,
+		ProposalDeadline:     4 * time.Second,
+		MaxFutureDrift:       time.Second,
+		NTPServer:            "pool.ntp.org",
+		PrivateAPIMaxStreams: 31872,
+		DataDirWarnPercents:  "80,90,95",
 	},
 	NetworkCfg: conf.NetWorkConfig{
 		Bootstrapped: true,
@@ -43,9 +63,13 @@ var DefaultConfig = conf.Config{
 		MaxBackups: 10,
 		MaxAge:     30,
 		Compress:   true,
+
+		ConsoleFormat: "text",
+		FileFormat:    "json",
 	},
 	PprofCfg: conf.PprofConfig{
 		MaxCpu:     0,
+		Addr:       "127.0.0.1",
 		Port:       6060,
 		TraceMutex: true,
 		TraceBlock: true,
@@ -60,19 +84,32 @@ var DefaultConfig = conf.Config{
 		IsMem:      false,
 		MaxDB:      100,
 		MaxReaders: 1000,
+
+		HeaderCache:  2048,
+		BodyCache:    256,
+		ReceiptCache: 256,
+
+		MapSize:    "8TB",
+		GrowthStep: "2GB",
+		SyncMode:   "durable",
 	},
 	MetricsCfg: conf.MetricsConfig{
 		Port: 6060,
 		HTTP: "127.0.0.1",
 	},
+	TracingCfg: conf.TracingConfig{
+		Endpoint:    "127.0.0.1:4318",
+		SampleRatio: 1,
+	},
 
 	P2PCfg: &conf.P2PConfig{P2PLimit: &conf.P2PLimit{}},
 
 	//GenesisCfg: ReadGenesis("allocs/mainnet.json"),
 	GPO: conf.FullNodeGPO,
 	Miner: conf.MinerConfig{
-		GasCeil:  30000000,
-		GasPrice: big.NewInt(params.GWei),
-		Recommit: 4 * time.Second,
+		GasCeil:    30000000,
+		GasPrice:   big.NewInt(1), // the price limit of the transaction pool
+		Recommit:   4 * time.Second,
+		TxOrdering: "tip",
 	},
 }
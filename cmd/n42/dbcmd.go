@@ -0,0 +1,704 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/urfave/cli/v2"
+)
+
+// txLookupBatch is the number of blocks indexed per write transaction by
+// rebuild-txlookup.
+const txLookupBatch = 10000
+
+// checkBatch is the number of blocks verified per transaction by check.
+const checkBatch = 10000
+
+// backupEraBlocks is the number of blocks per era file of an incremental
+// backup.
+const backupEraBlocks = 8192
+
+// salvageEraBlocks is the number of blocks per era file salvage carries the
+// blocks in from the old to the new data directory.
+const salvageEraBlocks = 8192
+
+var (
+	DBStatsJSONFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the statistics as JSON",
+	}
+	DBCheckFromFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to check",
+	}
+	DBCheckToFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to check (default: the head block)",
+	}
+	DBCheckRepairFlag = &cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Rewrite the broken hash to number and transaction lookup entries",
+	}
+	DBRegenerateFromFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to regenerate the receipts of (default: the first block whose parent state is kept)",
+	}
+	DBRegenerateToFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to regenerate the receipts of (default: the head block)",
+	}
+	DBBackupRateLimitFlag = &cli.IntFlag{
+		Name:  "ratelimit",
+		Usage: "Maximum read rate of a full backup in MB per second (0 = unlimited)",
+	}
+	DBBackupIncrementalFlag = &cli.StringFlag{
+		Name:  "incremental",
+		Usage: "Directory of the full or incremental backup to only back up the blocks added since",
+	}
+
+	dbCommand = &cli.Command{
+		Name:  "db",
+		Usage: "Low level database operations",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "rebuild-txlookup",
+				Usage:  "Rebuild the transaction lookup index of the canonical chain",
+				Action: rebuildTxLookup,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+				},
+				Description: `
+The rebuild-txlookup command writes the transaction hash to block number and
+index entries of every canonical block. Databases created before the index of
+the transaction was recorded need it for fast lookups by hash.`,
+			},
+			{
+				Name:   "regenerate-receipts",
+				Usage:  "Execute old blocks again to rebuild their missing receipts",
+				Action: regenerateReceipts,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+					DBRegenerateFromFlag,
+					DBRegenerateToFlag,
+				},
+				Description: `
+The regenerate-receipts command executes the canonical blocks from --from to
+--to whose receipts are missing, pruned or never stored, on the state of their
+parent read from the state history, and stores the receipts and logs once they
+match the receipt root of the block. Logs of blocks the log index already
+covers are indexed as well. Blocks whose parent state was pruned cannot be
+executed, so the receipts of a node that pruned its state history can only be
+regenerated as far back as the history goes. The node must be stopped, and
+restarted with a pruning mode keeping the receipts.`,
+			},
+			{
+				Name:   "stats",
+				Usage:  "Print the number of entries and size of every database table",
+				Action: dbStats,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+					DBStatsJSONFlag,
+				},
+				Description: `
+The stats command lists the tables of the chain database from the largest to
+the smallest, with their number of entries, size and share of the total size.
+Empty tables are left out unless --json is set. The database is opened
+read-only, so the command can run alongside the node.`,
+			},
+			{
+				Name:   "compact",
+				Usage:  "Rewrite the chain database without its free pages",
+				Action: dbCompact,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+The compact command copies the chain database table by table into a new file,
+leaving out the free pages a long running node accumulates, checks that every
+table holds as many entries, then replaces the database file with the copy.
+The node must be stopped, and the disk needs room for the compacted copy until
+it is swapped in. An interrupted compaction leaves the database untouched.`,
+			},
+			{
+				Name:      "backup",
+				Usage:     "Copy the chain database and the freezer of a running node into a new data directory",
+				ArgsUsage: "<datadir>",
+				Action:    dbBackup,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+					DBBackupRateLimitFlag,
+					DBBackupIncrementalFlag,
+				},
+				Description: `
+The backup command opens the database of --data.dir read-only and copies every
+table within a single read transaction, so the copy holds the database as it
+was when the backup started, while the node keeps running. The frozen blocks
+are copied afterwards. <datadir> must not hold a database yet; the node can be
+started on it like on the original data directory. --ratelimit limits the
+disk reads taken from the running node. The admin_backup method of the
+authenticated RPC endpoint starts the same backup from within the node.
+
+With --incremental, only the blocks added since the given backup are written
+to era files in <datadir>, to be replayed on top of it by the restore command.
+The head block of the given backup must still be canonical.`,
+			},
+			{
+				Name:      "restore",
+				Usage:     "Restore a full backup and the incremental backups taken after it",
+				ArgsUsage: "<full backup> [<incremental backup>...]",
+				Action:    dbRestore,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+				},
+				Description: `
+The restore command copies the database and the freezer of the full backup
+into --data.dir, which must not hold a database yet, then imports the blocks of
+the incremental backups in the given order, executing them to rebuild the
+state. Every incremental backup must have been taken against the one before
+it, and the checksums of its era files must match.`,
+			},
+			{
+				Name:      "salvage",
+				Usage:     "Recover the readable canonical blocks of a damaged database into a new data directory",
+				ArgsUsage: "<datadir>",
+				Action:    dbSalvage,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+					ChainFlag,
+				},
+				Description: `
+The salvage command opens the database of --data.dir read-only, finds the
+highest block up to which every canonical block is readable and linked to its
+parent, and imports those blocks into the data directory <datadir>, executing
+them again to rebuild the state, receipts and indexes. The new data directory
+must hold the same genesis: it is created for the networks known by --chain,
+private networks need it initialised with their genesis file first. An
+interrupted salvage resumes from the last imported block.`,
+			},
+			{
+				Name:   "check",
+				Usage:  "Verify the integrity of the canonical chain",
+				Action: dbCheck,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+					DBCheckFromFlag,
+					DBCheckToFlag,
+					DBCheckRepairFlag,
+				},
+				Description: `
+The check command walks the canonical blocks from --from to --to and reports
+missing canonical hashes, headers and bodies, headers not linked to their
+parent, receipts not matching the transactions, and hash to number or
+transaction lookup entries not pointing at their block. With --repair the
+index entries are rewritten from the stored blocks; the other issues can only
+be fixed by syncing the blocks again. The database is opened read-only unless
+--repair is set. The command fails if issues remain.`,
+			},
+		},
+	}
+)
+
+func rebuildTxLookup(ctx *cli.Context) error {
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	head := stack.BlockChain().CurrentBlock().Number64().Uint64()
+	start := time.Now()
+	for from := uint64(0); from <= head; from += txLookupBatch {
+		to := min(from+txLookupBatch-1, head)
+		if err := stack.Database().Update(ctx.Context, func(tx kv.RwTx) error {
+			return rawdb.RebuildTxLookup(tx, from, to)
+		}); err != nil {
+			return err
+		}
+		log.Info("Rebuilding transaction lookup index", "block", to, "head", head, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+func regenerateReceipts(ctx *cli.Context) error {
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	from, to := ctx.Uint64(DBRegenerateFromFlag.Name), stack.BlockChain().CurrentBlock().Number64().Uint64()
+	if ctx.IsSet(DBRegenerateToFlag.Name) {
+		to = ctx.Uint64(DBRegenerateToFlag.Name)
+	}
+	if !ctx.IsSet(DBRegenerateFromFlag.Name) {
+		if err := stack.Database().View(ctx.Context, func(tx kv.Tx) (err error) {
+			from, err = rawdb.ReadPruneProgress(tx, rawdb.PruneHistory)
+			return err
+		}); err != nil {
+			return err
+		}
+		from = max(from, 1)
+	}
+	start := time.Now()
+	regenerated, err := stack.RegenerateReceipts(ctx.Context, from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("regenerated the receipts of %d blocks in %d-%d in %s\n", regenerated, from, to, time.Since(start).Round(time.Second))
+	return nil
+}
+
+func dbStats(ctx *cli.Context) error {
+	// The statistics are only read, so they can be taken while the node runs.
+	DefaultConfig.DatabaseCfg.ReadOnly = true
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	var stats []rawdb.TableStats
+	if err := stack.Database().View(ctx.Context, func(tx kv.Tx) (err error) {
+		stats, err = rawdb.ReadAllTableStats(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	if ctx.Bool(DBStatsJSONFlag.Name) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Size > stats[j].Size })
+	var total uint64
+	for _, s := range stats {
+		total += s.Size
+	}
+	fmt.Printf("%-30s %14s %12s %7s\n", "TABLE", "ENTRIES", "SIZE", "SHARE")
+	for _, s := range stats {
+		if s.Entries == 0 {
+			continue
+		}
+		fmt.Printf("%-30s %14d %12s %6.2f%%\n", s.Table, s.Entries, types.StorageSize(s.Size), 100*float64(s.Size)/float64(max(total, 1)))
+	}
+	fmt.Printf("%-30s %14s %12s\n", "total", "", types.StorageSize(total))
+	return nil
+}
+
+func dbCheck(ctx *cli.Context) error {
+	repair := ctx.Bool(DBCheckRepairFlag.Name)
+	DefaultConfig.DatabaseCfg.ReadOnly = !repair
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	from, to := ctx.Uint64(DBCheckFromFlag.Name), stack.BlockChain().CurrentBlock().Number64().Uint64()
+	if ctx.IsSet(DBCheckToFlag.Name) {
+		to = ctx.Uint64(DBCheckToFlag.Name)
+	}
+	if from > to {
+		return fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+
+	var (
+		start    = time.Now()
+		counts   = make(map[string]int)
+		found    int
+		repaired int
+	)
+	for batch := from; batch <= to; batch += checkBatch {
+		last := min(batch+checkBatch-1, to)
+		var issues []rawdb.ChainIssue
+		if err := stack.Database().View(ctx.Context, func(tx kv.Tx) (err error) {
+			issues, err = rawdb.CheckChain(tx, batch, last)
+			return err
+		}); err != nil {
+			return err
+		}
+		if repair {
+			if err := stack.Database().Update(ctx.Context, func(tx kv.RwTx) error {
+				for i := range issues {
+					if !issues[i].Repairable() {
+						continue
+					}
+					if err := rawdb.RepairChainIssue(tx, issues[i]); err != nil {
+						return fmt.Errorf("failed to repair block %d: %w", issues[i].Number, err)
+					}
+					issues[i].Repaired = true
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		for _, issue := range issues {
+			log.Warn("Chain issue", "number", issue.Number, "hash", issue.Hash, "kind", issue.Kind, "detail", issue.Detail, "repaired", issue.Repaired)
+			counts[issue.Kind]++
+			if issue.Repaired {
+				repaired++
+			}
+		}
+		found += len(issues)
+		log.Info("Checking chain", "block", last, "to", to, "issues", found, "elapsed", time.Since(start))
+	}
+
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("%-20s %d\n", kind, counts[kind])
+	}
+	fmt.Printf("checked blocks %d-%d: %d issues, %d repaired\n", from, to, found, repaired)
+	if found > repaired {
+		return fmt.Errorf("%d chain issues remain", found-repaired)
+	}
+	return nil
+}
+
+func dbCompact(ctx *cli.Context) error {
+	before, after, err := node.CompactDatabase(ctx.Context, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("compacted database from %s to %s\n", types.StorageSize(before), types.StorageSize(after))
+	return nil
+}
+
+func dbBackup(ctx *cli.Context) error {
+	dst := ctx.Args().First()
+	if dst == "" {
+		return fmt.Errorf("missing data directory to back up into")
+	}
+	rateLimit := ctx.Int(DBBackupRateLimitFlag.Name)
+	if rateLimit < 0 {
+		return fmt.Errorf("invalid rate limit %d", rateLimit)
+	}
+	DefaultConfig.DatabaseCfg.ReadOnly = true
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	if base := ctx.String(DBBackupIncrementalFlag.Name); base != "" {
+		return dbBackupIncremental(ctx, stack, base, dst)
+	}
+	info, err := stack.Backup(ctx.Context, dst, rateLimit*1024*1024)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("backed up block %d into %s (%s) in %s\n", info.Block, info.Dest, types.StorageSize(info.Size), info.Finished.Sub(info.Started).Round(time.Second))
+	return nil
+}
+
+// dbBackupIncremental writes the canonical blocks added since the backup in
+// base to era files in dst, along with the manifest of the new backup.
+func dbBackupIncremental(ctx *cli.Context, stack *node.Node, base, dst string) error {
+	parent, err := node.ReadBackupManifest(base)
+	if err != nil {
+		return err
+	}
+	manifest := &node.BackupManifest{
+		Genesis:     stack.BlockChain().GenesisBlock().Hash(),
+		Incremental: true,
+		Parent:      parent.Block,
+		ParentHash:  parent.Hash,
+		Created:     time.Now(),
+	}
+	if manifest.Genesis != parent.Genesis {
+		return fmt.Errorf("backup %s is of genesis %s, not %s", base, parent.Genesis, manifest.Genesis)
+	}
+	if err := stack.Database().View(ctx.Context, func(tx kv.Tx) (err error) {
+		if canonical, err := rawdb.ReadCanonicalHash(tx, parent.Block); err != nil {
+			return err
+		} else if canonical != parent.Hash {
+			return fmt.Errorf("head block %d of backup %s is no longer canonical, take a full backup", parent.Block, base)
+		}
+		if number := rawdb.ReadCurrentBlockNumber(tx); number != nil {
+			manifest.Block = *number
+		}
+		manifest.Hash, err = rawdb.ReadCanonicalHash(tx, manifest.Block)
+		return err
+	}); err != nil {
+		return err
+	}
+	if manifest.Block <= parent.Block {
+		return fmt.Errorf("no blocks added since block %d of backup %s", parent.Block, base)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for start := parent.Block + 1; start <= manifest.Block; start += backupEraBlocks {
+		end := min(start+backupEraBlocks-1, manifest.Block)
+		name := fmt.Sprintf("n42-%010d-%010d.era", start, end)
+		sum, err := exportEraFile(ctx, stack.Database(), filepath.Join(dst, name), start, end)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, node.BackupFile{Name: name, SHA256: fmt.Sprintf("%x", sum)})
+		log.Info("Backed up blocks", "file", name, "blocks", end-start+1)
+	}
+	// The era files are read in transactions of their own, the head must
+	// still be canonical for them to hold the chain the manifest describes.
+	if err := stack.Database().View(ctx.Context, func(tx kv.Tx) error {
+		if canonical, err := rawdb.ReadCanonicalHash(tx, manifest.Block); err != nil {
+			return err
+		} else if canonical != manifest.Hash {
+			return fmt.Errorf("block %d was reorganised during the backup, try again", manifest.Block)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := node.WriteBackupManifest(dst, manifest); err != nil {
+		return err
+	}
+	fmt.Printf("backed up blocks %d-%d into %s\n", parent.Block+1, manifest.Block, dst)
+	return nil
+}
+
+func dbRestore(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("missing backup to restore")
+	}
+	dirs := ctx.Args().Slice()
+	manifests := make([]*node.BackupManifest, len(dirs))
+	for i, dir := range dirs {
+		manifest, err := node.ReadBackupManifest(dir)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			prev := manifests[i-1]
+			switch {
+			case !manifest.Incremental:
+				return fmt.Errorf("%s is not an incremental backup", dir)
+			case manifest.Genesis != prev.Genesis:
+				return fmt.Errorf("backup %s is of genesis %s, not %s", dir, manifest.Genesis, prev.Genesis)
+			case manifest.Parent != prev.Block || manifest.ParentHash != prev.Hash:
+				return fmt.Errorf("backup %s was not taken against %s", dir, dirs[i-1])
+			}
+		}
+		manifests[i] = manifest
+	}
+
+	if _, err := node.RestoreBackup(&DefaultConfig, dirs[0]); err != nil {
+		return err
+	}
+	log.Info("Restored full backup", "dir", dirs[0], "block", manifests[0].Block)
+	if len(dirs) == 1 {
+		return nil
+	}
+
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+	if hash := stack.BlockChain().GenesisBlock().Hash(); hash != manifests[0].Genesis {
+		return fmt.Errorf("genesis %s does not match the backup genesis %s", hash, manifests[0].Genesis)
+	}
+	for i, dir := range dirs[1:] {
+		for _, file := range manifests[i+1].Files {
+			path := filepath.Join(dir, file.Name)
+			if sum, err := fileChecksum(path); err != nil {
+				return err
+			} else if sum != file.SHA256 {
+				return fmt.Errorf("checksum mismatch of %s", path)
+			}
+			begin := time.Now()
+			imported, err := importEraFile(stack, path)
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", path, err)
+			}
+			log.Info("Imported backed up blocks", "file", path, "blocks", imported, "elapsed", time.Since(begin))
+		}
+	}
+	if head := stack.BlockChain().CurrentBlock(); head.Hash() != manifests[len(dirs)-1].Hash {
+		return fmt.Errorf("restored head %d %s, want %d %s", head.Number64().Uint64(), head.Hash(), manifests[len(dirs)-1].Block, manifests[len(dirs)-1].Hash)
+	}
+	fmt.Printf("restored blocks up to %d\n", manifests[len(dirs)-1].Block)
+	return nil
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of the file at path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func dbSalvage(ctx *cli.Context) error {
+	dst := ctx.Args().First()
+	if dst == "" {
+		return fmt.Errorf("missing data directory to salvage into")
+	}
+	if abs, err := filepath.Abs(dst); err != nil {
+		return err
+	} else if src, err := filepath.Abs(DefaultConfig.NodeCfg.DataDir); err != nil {
+		return err
+	} else if abs == src {
+		return fmt.Errorf("cannot salvage a data directory into itself")
+	}
+	eraDir := filepath.Join(dst, "salvage")
+	if err := os.MkdirAll(eraDir, 0700); err != nil {
+		return err
+	}
+
+	genesis, height, err := salvageExport(ctx, eraDir)
+	if err != nil {
+		return err
+	}
+
+	DefaultConfig.NodeCfg.DataDir, DefaultConfig.NodeCfg.AncientDir = dst, ""
+	DefaultConfig.DatabaseCfg.ReadOnly = false
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+	if hash := stack.BlockChain().GenesisBlock().Hash(); hash != genesis {
+		return fmt.Errorf("genesis %s of %s does not match the salvaged genesis %s", hash, dst, genesis)
+	}
+
+	files, err := filepath.Glob(filepath.Join(eraDir, "*.era"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		begin := time.Now()
+		imported, err := importEraFile(stack, file)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", file, err)
+		}
+		log.Info("Imported salvaged blocks", "file", filepath.Base(file), "blocks", imported, "elapsed", time.Since(begin))
+	}
+	if err := os.RemoveAll(eraDir); err != nil {
+		return err
+	}
+	fmt.Printf("salvaged blocks 1-%d into %s\n", height, dst)
+	return nil
+}
+
+// salvageExport opens the database of the configured data directory
+// read-only and writes its recoverable canonical blocks to era files in dir.
+// It returns the genesis hash and the number of the last recoverable block.
+func salvageExport(ctx *cli.Context, dir string) (types.Hash, uint64, error) {
+	DefaultConfig.DatabaseCfg.ReadOnly = true
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return types.Hash{}, 0, err
+	}
+	defer stack.Close()
+
+	genesis := stack.BlockChain().GenesisBlock().Hash()
+	head := stack.BlockChain().CurrentBlock().Number64().Uint64()
+	height := salvageableHeight(ctx, stack.Database(), head)
+	log.Info("Found recoverable blocks", "height", height, "head", head)
+
+	for start := uint64(1); start <= height; start += salvageEraBlocks {
+		end := min(start+salvageEraBlocks-1, height)
+		path := filepath.Join(dir, fmt.Sprintf("n42-%010d-%010d.era", start, end))
+		if _, err := exportEraFile(ctx, stack.Database(), path, start, end); err != nil {
+			log.Warn("Failed to export salvaged blocks", "from", start, "to", end, "err", err)
+			os.Remove(path)
+			height = start - 1
+			break
+		}
+	}
+	if height == 0 {
+		return genesis, 0, fmt.Errorf("no block above the genesis is recoverable")
+	}
+	return genesis, height, nil
+}
+
+// salvageableHeight returns the number of the last block up to which every
+// canonical block is readable and linked to its parent, walking the chain up
+// to head. A batch failing to be read is checked block by block to find the
+// block at fault.
+func salvageableHeight(ctx *cli.Context, db kv.RoDB, head uint64) uint64 {
+	// check returns the first broken block from through to, zero if there
+	// is none, and whether the blocks could be read at all.
+	check := func(from, to uint64) (uint64, bool) {
+		var issues []rawdb.ChainIssue
+		if err := db.View(ctx.Context, func(tx kv.Tx) (err error) {
+			issues, err = rawdb.CheckChain(tx, from, to)
+			return err
+		}); err != nil {
+			return 0, false
+		}
+		for _, issue := range issues {
+			if issue.BlockBroken() {
+				log.Warn("Unrecoverable block", "number", issue.Number, "kind", issue.Kind, "detail", issue.Detail)
+				return issue.Number, true
+			}
+		}
+		return 0, true
+	}
+
+	for start := uint64(1); start <= head; start += checkBatch {
+		end := min(start+checkBatch-1, head)
+		broken, ok := check(start, end)
+		if !ok {
+			broken = 0
+			for number := start; number <= end && broken == 0; number++ {
+				if broken, ok = check(number, number); !ok {
+					log.Warn("Unreadable block", "number", number)
+					return number - 1
+				}
+			}
+		}
+		if broken != 0 {
+			return broken - 1
+		}
+	}
+	return head
+}
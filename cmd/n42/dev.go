@@ -0,0 +1,107 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/n42blockchain/N42/accounts/keystore"
+	"github.com/n42blockchain/N42/common/crypto"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/params"
+	"github.com/n42blockchain/N42/params/networkname"
+)
+
+const (
+	// devAccountKey is the private key of the developer account prefunded in
+	// the devnet genesis (0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266). It is
+	// publicly known and must never hold real funds.
+	devAccountKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+)
+
+// setupDevMode turns cfg into a single-node developer network: the devnet
+// chain, sealing with the prefunded developer account and the RPC servers
+// enabled. Unless a data directory was given explicitly, the chain is kept in
+// a temporary directory whose path is returned so it can be removed on exit.
+// Unless restricted with --http.api and --ws.api, the node serves every
+// namespace it registers over HTTP and WS, see node.Node.startRPC.
+func setupDevMode(ctx *cli.Context, cfg *conf.Config) (string, error) {
+	var ephemeral string
+	if !ctx.IsSet(DataDirFlag.Name) && len(cfgFile) == 0 {
+		dir, err := os.MkdirTemp("", "n42-dev")
+		if err != nil {
+			return "", fmt.Errorf("failed to create developer datadir: %v", err)
+		}
+		cfg.NodeCfg.DataDir = dir
+		ephemeral = dir
+	}
+	cfg.P2PCfg.DataDir = cfg.NodeCfg.DataDir
+
+	cfg.NodeCfg.Chain = networkname.DevnetChainName
+	cfg.NodeCfg.Miner = true
+	cfg.NodeCfg.UseLightweightKDF = true
+
+	cfg.NodeCfg.HTTP = true
+	cfg.NodeCfg.WS = true
+	if cfg.NodeCfg.WSHost == "" {
+		cfg.NodeCfg.WSHost = "127.0.0.1"
+	}
+
+	// Nobody to sync with, start sealing straight away.
+	cfg.P2PCfg.NoDiscovery = true
+	cfg.P2PCfg.MinSyncPeers = 0
+	cfg.P2PCfg.BootstrapNodeAddr = nil
+	cfg.NetworkCfg.Bootstrapped = false
+
+	key, err := crypto.HexToECDSA(devAccountKey)
+	if err != nil {
+		return ephemeral, err
+	}
+	cfg.Miner.Etherbase = crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	// A period of 0 makes the engine seal only when transactions are pending.
+	params.DevnetChainConfig.Apos.Period = cfg.NodeCfg.DevPeriod
+	cfg.ChainCfg = params.DevnetChainConfig
+
+	log.Info("Running in developer mode", "datadir", cfg.NodeCfg.DataDir, "account", cfg.Miner.Etherbase, "period", cfg.NodeCfg.DevPeriod)
+	return ephemeral, nil
+}
+
+// unlockDevAccount imports the developer account into the keystore with an
+// empty password and unlocks it, so that it can seal blocks and sign
+// transactions without further setup.
+func unlockDevAccount(stack *node.Node) error {
+	key, err := crypto.HexToECDSA(devAccountKey)
+	if err != nil {
+		return err
+	}
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, err := ks.ImportECDSA(key, "")
+	if err != nil && !errors.Is(err, keystore.ErrAccountAlreadyExists) {
+		return fmt.Errorf("failed to import developer account: %v", err)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		return fmt.Errorf("failed to unlock developer account: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/log"
+)
+
+// handleDiagnosticSignal writes a diagnostic bundle of stack to its datadir
+// every time the process receives SIGUSR1.
+func handleDiagnosticSignal(stack *node.Node) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGUSR1)
+	go func() {
+		for range sigc {
+			dir, err := stack.WriteDiagnostics()
+			if err != nil {
+				log.Warn("Incomplete diagnostic dump", "dir", dir, "err", err)
+				continue
+			}
+			log.Info("Wrote diagnostic dump", "dir", dir)
+		}
+	}()
+}
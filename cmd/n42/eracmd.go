@@ -0,0 +1,251 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/urfave/cli/v2"
+)
+
+// eraImportBatch is the number of blocks inserted into the chain at once on
+// import.
+const eraImportBatch = 1000
+
+var (
+	EraFromFlag = &cli.Uint64Flag{
+		Name:  "era.from",
+		Usage: "First block to export",
+		Value: 1,
+	}
+	EraToFlag = &cli.Uint64Flag{
+		Name:  "era.to",
+		Usage: "Last block to export (default = current block)",
+	}
+	EraBlocksFlag = &cli.Uint64Flag{
+		Name:  "era.blocks",
+		Usage: "Number of blocks per era file",
+		Value: 8192,
+	}
+
+	eraCommand = &cli.Command{
+		Name:  "era",
+		Usage: "Export and import block history as era files",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export canonical blocks with their receipts to era files",
+				ArgsUsage: "<dir>",
+				Action:    exportEra,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+					DBReadOnlyFlag,
+					EraFromFlag,
+					EraToFlag,
+					EraBlocksFlag,
+				},
+				Description: `
+The export command writes the canonical blocks from --era.from to --era.to into
+era files of --era.blocks blocks each in <dir>, named after the first and last
+block they hold, and lists the SHA-256 checksum of every file in
+<dir>/checksums.txt.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import blocks from era files",
+				ArgsUsage: "<file or dir>...",
+				Action:    importEra,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					AncientDirFlag,
+				},
+				Description: `
+The import command inserts the blocks of the given era files, or of the era
+files in the given directories, into the chain. Blocks are validated and
+executed as if received from the network, blocks the chain already holds are
+skipped.`,
+			},
+		},
+	}
+)
+
+func exportEra(ctx *cli.Context) error {
+	dir := ctx.Args().First()
+	if dir == "" {
+		return fmt.Errorf("missing output directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	from, to, size := ctx.Uint64(EraFromFlag.Name), ctx.Uint64(EraToFlag.Name), ctx.Uint64(EraBlocksFlag.Name)
+	if current := stack.BlockChain().CurrentBlock().Number64().Uint64(); !ctx.IsSet(EraToFlag.Name) || to > current {
+		to = current
+	}
+	if size == 0 {
+		return fmt.Errorf("--%s must be positive", EraBlocksFlag.Name)
+	}
+	if from > to {
+		return fmt.Errorf("nothing to export, first block %d is above last block %d", from, to)
+	}
+
+	checksums, err := os.OpenFile(filepath.Join(dir, "checksums.txt"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer checksums.Close()
+
+	for start := from; start <= to; start += size {
+		end := min(start+size-1, to)
+		name := fmt.Sprintf("n42-%010d-%010d.era", start, end)
+		begin := time.Now()
+		sum, err := exportEraFile(ctx, stack.Database(), filepath.Join(dir, name), start, end)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		if _, err := fmt.Fprintf(checksums, "%x  %s\n", sum, name); err != nil {
+			return err
+		}
+		log.Info("Exported era file", "file", name, "blocks", end-start+1, "elapsed", time.Since(begin))
+	}
+	return nil
+}
+
+// exportEraFile writes the blocks from start through end to the era file at
+// path and returns its SHA-256 checksum.
+func exportEraFile(ctx *cli.Context, db kv.RoDB, path string, start, end uint64) ([]byte, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if err := db.View(ctx.Context, func(tx kv.Tx) error {
+		return rawdb.ExportEra(tx, io.MultiWriter(f, hash), start, end)
+	}); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), f.Sync()
+}
+
+func importEra(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("missing era files")
+	}
+	var files []string
+	for _, arg := range ctx.Args().Slice() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.era"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	for _, file := range files {
+		begin := time.Now()
+		imported, err := importEraFile(stack, file)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", file, err)
+		}
+		log.Info("Imported era file", "file", file, "blocks", imported, "elapsed", time.Since(begin))
+	}
+	return nil
+}
+
+// importEraFile inserts the blocks of the era file at path above the current
+// block into the chain and returns their number.
+func importEraFile(stack *node.Node, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	era, err := rawdb.NewEraReader(f, info.Size())
+	if err != nil {
+		return 0, err
+	}
+
+	chain := stack.BlockChain()
+	var (
+		imported int
+		batch    []block.IBlock
+	)
+	insert := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := chain.InsertChain(batch)
+		if err != nil {
+			imported += n
+			return fmt.Errorf("failed to insert blocks from %d: %w", batch[0].Number64().Uint64(), err)
+		}
+		imported += len(batch)
+		batch = nil
+		return nil
+	}
+	current := chain.CurrentBlock().Number64().Uint64()
+	for number := max(era.Start(), current+1); number < era.Start()+era.Count(); number++ {
+		b, _, err := era.ReadBlock(number)
+		if err != nil {
+			return imported, err
+		}
+		if batch = append(batch, b); len(batch) == eraImportBatch {
+			if err := insert(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	return imported, insert()
+}
@@ -37,10 +37,19 @@ import (
 
 var (
 	exportCommand = &cli.Command{
-		Name:        "export",
-		Usage:       "Export N42 data",
-		ArgsUsage:   "",
-		Description: ``,
+		Name:      "export",
+		Usage:     "Export N42 data",
+		ArgsUsage: "<file> [<first> <last>]",
+		Action:    exportChain,
+		Flags: []cli.Flag{
+			DataDirFlag,
+			AncientDirFlag,
+			DBReadOnlyFlag,
+		},
+		Description: `
+Given a file, the export command writes the canonical blocks from <first> to
+<last>, or all of them, to a chain file, gzipped if its name ends in .gz, which
+the import command loads into another node.`,
 		Subcommands: []*cli.Command{
 			{
 				Name:      "txs",
@@ -49,6 +58,7 @@ var (
 				Action:    exportTransactions,
 				Flags: []cli.Flag{
 					DataDirFlag,
+					DBReadOnlyFlag,
 				},
 				Description: ``,
 			},
@@ -59,6 +69,7 @@ var (
 				Action:    exportBalance,
 				Flags: []cli.Flag{
 					DataDirFlag,
+					DBReadOnlyFlag,
 				},
 				Description: ``,
 			},
@@ -69,6 +80,7 @@ var (
 				Action:    exportDBState,
 				Flags: []cli.Flag{
 					DataDirFlag,
+					DBReadOnlyFlag,
 				},
 				Description: ``,
 			},
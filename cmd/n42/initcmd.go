@@ -10,6 +10,7 @@ import (
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/conf"
 	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/internal/vm"
 	"github.com/n42blockchain/N42/log"
 	"github.com/n42blockchain/N42/modules/rawdb"
 	"github.com/urfave/cli/v2"
@@ -24,13 +25,18 @@ var (
 		Action:    initGenesis,
 		Flags: []cli.Flag{
 			DataDirFlag,
+			DBCompressionFlag,
+			DBMapSizeFlag,
+			DBPageSizeFlag,
 		},
 		Description: `
 The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument: the chain config with the parameters
+of the consensus engine, the initial signers and the allocations of the genesis
+block. Nodes of a network initialised this way run with --chain private.`,
 	}
 )
 
@@ -58,6 +64,12 @@ func initGenesis(cliCtx *cli.Context) error {
 	if err := json.NewDecoder(file).Decode(genesis); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
+	if err := genesis.Validate(); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	if err := vm.CheckPrecompiles(genesis.Config); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
 
 	chaindb, err := node.OpenDatabase(&DefaultConfig, nil, kv.ChainDB.String())
 	if err != nil {
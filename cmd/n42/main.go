@@ -36,15 +36,18 @@ func main() {
 	flags = append(flags, pprofCfg...)
 	flags = append(flags, nodeFlg...)
 	flags = append(flags, rpcFlags...)
+	flags = append(flags, gpoFlags...)
+	flags = append(flags, minerFlags...)
 	flags = append(flags, authRPCFlag...)
 	flags = append(flags, configFlag...)
 	flags = append(flags, settingFlag...)
 	flags = append(flags, accountFlag...)
 	flags = append(flags, metricsFlags...)
+	flags = append(flags, tracingFlags...)
 	flags = append(flags, p2pFlags...)
 	flags = append(flags, p2pLimitFlags...)
 
-	rootCmd = append(rootCmd, walletCommand, accountCommand, exportCommand, initCommand)
+	rootCmd = append(rootCmd, walletCommand, accountCommand, validatorCommand, exportCommand, importCommand, eraCommand, dbCommand, snapshotCommand, stageCommand, initCommand)
 	commands := rootCmd
 
 	app := &cli.App{
@@ -0,0 +1,85 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/log"
+	"github.com/urfave/cli/v2"
+)
+
+var snapshotCommand = &cli.Command{
+	Name:  "snapshot",
+	Usage: "Offline operations on the state",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "prune-state",
+			Usage:  "Delete the state history of old blocks and compact the database",
+			Action: pruneState,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				AncientDirFlag,
+				PruneFlag,
+				PruneHistoryFlag,
+			},
+			Description: `
+The prune-state command deletes the account and storage changesets and history
+index entries of the blocks further below the head than the pruning mode keeps,
+full unless --prune or --prune.history say otherwise, then compacts the
+database to hand the freed pages back to the file system, and reports the space
+reclaimed. It is meant for former archive nodes switched to a pruning mode,
+which otherwise prune their history in the background over a long time and
+never shrink the database file. The node must be stopped, and restarted with
+the same pruning mode, as the state of the pruned blocks cannot be read again.`,
+		},
+	},
+}
+
+func pruneState(ctx *cli.Context) error {
+	if !ctx.IsSet(PruneFlag.Name) {
+		DefaultConfig.NodeCfg.Prune = "full"
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	from, to, err := stack.PruneState()
+	stack.Close()
+	if err != nil {
+		return err
+	}
+	if to > from {
+		log.Info("Pruned state history", "from", from, "to", to, "elapsed", time.Since(start))
+	}
+
+	before, after, err := node.CompactDatabase(ctx.Context, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	if to > from {
+		fmt.Printf("pruned the state history of blocks %d-%d\n", from, to-1)
+	} else {
+		fmt.Printf("no state history to prune, kept from block %d\n", to)
+	}
+	fmt.Printf("compacted database from %s to %s, reclaimed %s\n", types.StorageSize(before), types.StorageSize(after), types.StorageSize(max(before-after, 0)))
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	StageNameFlag = &cli.StringFlag{
+		Name:     "stage",
+		Usage:    "Stage of the sync pipeline: execution, txindex or logindex",
+		Required: true,
+	}
+	StageBlockFlag = &cli.Uint64Flag{
+		Name:     "block",
+		Usage:    "Block to run or unwind the stage to",
+		Required: true,
+	}
+)
+
+var stageCommand = &cli.Command{
+	Name:  "stage",
+	Usage: "Inspect, run and unwind the stages of the sync pipeline",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "progress",
+			Usage:  "Print the last block processed by every stage",
+			Action: stageProgress,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				AncientDirFlag,
+				DisabledStagesFlag,
+			},
+		},
+		{
+			Name:   "run",
+			Usage:  "Run a stage up to a block",
+			Action: stageRun,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				AncientDirFlag,
+				DisabledStagesFlag,
+				StageNameFlag,
+				StageBlockFlag,
+			},
+			Description: `
+The run command makes the stage process the blocks after its progress up to
+--block, which the stages before it must have processed already. It completes
+an index left behind while its stage was disabled or unwound. The execution
+stage is run by the block import only.`,
+		},
+		{
+			Name:   "unwind",
+			Usage:  "Unwind a stage, and the stages after it, to a block",
+			Action: stageUnwind,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				AncientDirFlag,
+				DisabledStagesFlag,
+				StageNameFlag,
+				StageBlockFlag,
+			},
+			Description: `
+The unwind command reverts the stage, and the stages after it first, to --block.
+Unwinding the transaction index deletes the lookup entries of the later blocks,
+unwinding the log index makes them be indexed again, and unwinding the
+execution stage rewinds the head of the chain, the later blocks being fetched
+and executed again. The node must be stopped, the stage_run and stage_unwind
+RPC methods do the same on a running node.`,
+		},
+	},
+}
+
+func stageProgress(ctx *cli.Context) error {
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	stages, err := stack.Stages(ctx.Context)
+	if err != nil {
+		return err
+	}
+	for _, stage := range stages {
+		state := "enabled"
+		if !stage.Enabled {
+			state = "disabled"
+		}
+		fmt.Printf("%-10s %10d  %s\n", stage.Stage, stage.Block, state)
+	}
+	return nil
+}
+
+func stageRun(ctx *cli.Context) error {
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	start := time.Now()
+	stage, to := ctx.String(StageNameFlag.Name), ctx.Uint64(StageBlockFlag.Name)
+	if err := stack.RunStage(ctx.Context, stage, to); err != nil {
+		return err
+	}
+	fmt.Printf("ran the %s stage up to block %d in %s\n", stage, to, time.Since(start).Round(time.Second))
+	return nil
+}
+
+func stageUnwind(ctx *cli.Context) error {
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	start := time.Now()
+	stage, to := ctx.String(StageNameFlag.Name), ctx.Uint64(StageBlockFlag.Name)
+	if err := stack.UnwindStage(ctx.Context, stage, to); err != nil {
+		return err
+	}
+	fmt.Printf("unwound the %s stage to block %d in %s\n", stage, to, time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/n42blockchain/N42/log"
+)
+
+// sdNotify sends state to systemd. It does nothing unless the node runs as a
+// systemd service of Type=notify.
+func sdNotify(state string) {
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		log.Warn("Failed to notify systemd", "state", state, "err", err)
+	}
+}
+
+// sdWatchdog returns a ticker firing at half the watchdog interval of the
+// systemd service, or nil if the watchdog is not enabled (WatchdogSec=).
+func sdWatchdog() *time.Ticker {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.Warn("Failed to read systemd watchdog interval", "err", err)
+		return nil
+	}
+	if interval <= 0 {
+		return nil
+	}
+	log.Info("Enabled systemd watchdog", "interval", interval)
+	return time.NewTicker(interval / 2)
+}
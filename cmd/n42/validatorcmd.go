@@ -0,0 +1,315 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/accounts/blskeystore"
+	"github.com/n42blockchain/N42/cmd/utils"
+	"github.com/n42blockchain/N42/common/crypto/bls"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/node"
+	"github.com/n42blockchain/N42/internal/slashing"
+	"github.com/urfave/cli/v2"
+)
+
+var validatorCommand = &cli.Command{
+	Name:  "validator",
+	Usage: "Manage validator keys",
+	Description: `
+
+Manage the BLS keys of validators, list the keys of the validator keystore,
+create a new key, import keys from keystore files or export them.
+
+Keys are stored encrypted as specified by EIP-2335, one keystore file per key
+under <DATADIR>/validator_keystore, or the --validator.keystore directory. The
+files are the ones read and written by the deposit and validator tooling of
+other clients, they can be copied between nodes as they are.
+
+Make sure you backup your keys regularly.`,
+	Subcommands: []*cli.Command{
+		{
+			Name:   "list",
+			Usage:  "Print the public keys of the validator keystore",
+			Action: validatorList,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				ValidatorKeyStoreDirFlag,
+			},
+		},
+		{
+			Name:   "new",
+			Usage:  "Create a new validator key",
+			Action: validatorCreate,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				ValidatorKeyStoreDirFlag,
+				PasswordFileFlag,
+				LightKDFFlag,
+			},
+			Description: `
+    N42 validator new
+
+Creates a new BLS key, stores it encrypted with a password you are prompted for
+and prints its public key, the one to deposit with.
+
+For non-interactive use the password can be specified with the --account.password flag.`,
+		},
+		{
+			Name:      "import",
+			Usage:     "Import EIP-2335 keystore files into the validator keystore",
+			Action:    validatorImport,
+			ArgsUsage: "<keyFile> [keyFile...]",
+			Flags: []cli.Flag{
+				DataDirFlag,
+				ValidatorKeyStoreDirFlag,
+				PasswordFileFlag,
+			},
+			Description: `
+    N42 validator import <keyfile> [keyfile...]
+
+Imports keystore files, such as the ones of the deposit tooling. Each key is
+decrypted with its password before being imported as it is, still encrypted
+with the same password. The passwords are prompted for, or read from the lines
+of the --account.password file in the order of the files.`,
+		},
+		{
+			Name:      "export",
+			Usage:     "Export a key of the validator keystore",
+			Action:    validatorExport,
+			ArgsUsage: "<pubkey> <keyFile>",
+			Flags: []cli.Flag{
+				DataDirFlag,
+				ValidatorKeyStoreDirFlag,
+			},
+			Description: `
+    N42 validator export <pubkey> <keyfile>
+
+Writes the keystore of the key with the given public key to keyfile, encrypted
+with the password of the key.`,
+		},
+		{
+			Name:  "slashing-protection",
+			Usage: "Import or export the slashing protection history",
+			Description: `
+The node records the blocks signed by the validator keys and refuses to sign
+anything its keys could be slashed for. Before moving a key to another node or
+client, export the history and import it on the other side, with the key
+stopped on the first one, so that the new signer knows what was signed.
+
+The history is read and written in the EIP-3076 interchange format, where the
+slots are the block numbers and the genesis validators root is the hash of the
+genesis block.`,
+			Subcommands: []*cli.Command{
+				{
+					Name:      "import",
+					Usage:     "Import an EIP-3076 interchange file",
+					Action:    slashingProtectionImport,
+					ArgsUsage: "<file>",
+					Flags: []cli.Flag{
+						DataDirFlag,
+					},
+				},
+				{
+					Name:      "export",
+					Usage:     "Export the history to an EIP-3076 interchange file",
+					Action:    slashingProtectionExport,
+					ArgsUsage: "<file>",
+					Flags: []cli.Flag{
+						DataDirFlag,
+					},
+				},
+			},
+		},
+	},
+}
+
+// makeValidatorKeyStore opens the validator keystore defined by the config
+// file and the CLI flags.
+func makeValidatorKeyStore() *blskeystore.KeyStore {
+	cfg := DefaultConfig
+	// Load config file.
+	if len(cfgFile) > 0 {
+		if err := conf.LoadConfigFromFile(cfgFile, &cfg); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	dir, err := cfg.NodeCfg.ValidatorKeyDirConfig()
+	if err != nil {
+		utils.Fatalf("Failed to read configuration: %v", err)
+	}
+	return blskeystore.NewKeyStore(dir)
+}
+
+func validatorList(ctx *cli.Context) error {
+	ks := makeValidatorKeyStore()
+	keys, err := ks.List()
+	if err != nil {
+		utils.Fatalf("Could not list validator keys: %v", err)
+	}
+	for i, key := range keys {
+		fmt.Printf("Key #%d: 0x%s %s %s\n", i, key.Pubkey, key.Path, key.Description)
+	}
+	return nil
+}
+
+// validatorCreate creates a new key into the validator keystore.
+func validatorCreate(ctx *cli.Context) error {
+	ks := makeValidatorKeyStore()
+	password := utils.GetPassPhraseWithList("Your new validator key is locked with a password. Please give a password. Do not forget this password.", true, 0, MakePasswordList(ctx))
+
+	key, err := bls.RandKey()
+	if err != nil {
+		utils.Fatalf("Failed to generate validator key: %v", err)
+	}
+	keystore, err := blskeystore.Encrypt(key, password, "", "", ctx.Bool(LightKDFFlag.Name) || DefaultConfig.NodeCfg.UseLightweightKDF)
+	if err != nil {
+		utils.Fatalf("Failed to encrypt validator key: %v", err)
+	}
+	file, err := ks.Store(keystore)
+	if err != nil {
+		utils.Fatalf("Failed to store validator key: %v", err)
+	}
+	fmt.Printf("\nYour new validator key was generated\n\n")
+	fmt.Printf("Public key:                  0x%s\n", keystore.Pubkey)
+	fmt.Printf("Path of the secret key file: %s\n\n", file)
+	fmt.Printf("- You must BACKUP your key file! Without the key, the validator cannot sign.\n")
+	fmt.Printf("- You must REMEMBER your password! Without the password, it's impossible to decrypt the key!\n\n")
+	return nil
+}
+
+func validatorImport(ctx *cli.Context) error {
+	if ctx.Args().Len() == 0 {
+		utils.Fatalf("keyfile must be given as argument")
+	}
+	ks := makeValidatorKeyStore()
+	passwords := MakePasswordList(ctx)
+	for i, file := range ctx.Args().Slice() {
+		keystore, err := blskeystore.Load(file)
+		if err != nil {
+			utils.Fatalf("Could not read keystore: %v", err)
+		}
+		password := utils.GetPassPhraseWithList(fmt.Sprintf("Password of the validator key %s", file), false, i, passwords)
+		if _, err := blskeystore.Decrypt(keystore, password); err != nil {
+			utils.Fatalf("Could not decrypt %s: %v", file, err)
+		}
+		stored, err := ks.Store(keystore)
+		if err != nil {
+			utils.Fatalf("Could not import %s: %v", file, err)
+		}
+		fmt.Printf("Imported 0x%s into %s\n", keystore.Pubkey, stored)
+	}
+	return nil
+}
+
+func validatorExport(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		utils.Fatalf("public key and keyfile must be given as arguments")
+	}
+	ks := makeValidatorKeyStore()
+	keystore, err := ks.Find(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Could not find validator key: %v", err)
+	}
+	content, err := json.MarshalIndent(keystore, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := ctx.Args().Get(1)
+	if _, err := os.Stat(file); err == nil {
+		utils.Fatalf("%s already exists", file)
+	}
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		utils.Fatalf("Could not export validator key: %v", err)
+	}
+	fmt.Printf("Exported 0x%s to %s\n", keystore.Pubkey, file)
+	return nil
+}
+
+// openSlashingProtection opens the chain database and returns its slashing
+// protection and the hash of its genesis block.
+func openSlashingProtection(ctx *cli.Context) (*node.Node, *slashing.Protection, types.Hash, error) {
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return nil, nil, types.Hash{}, err
+	}
+	genesis := stack.BlockChain().GetHeaderByNumber(uint256.NewInt(0))
+	if genesis == nil {
+		stack.Close()
+		return nil, nil, types.Hash{}, fmt.Errorf("genesis block not found")
+	}
+	return stack, slashing.New(stack.Database()), genesis.Hash(), nil
+}
+
+func slashingProtectionImport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("interchange file must be given as argument")
+	}
+	content, err := os.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Could not read interchange file: %v", err)
+	}
+	var interchange slashing.Interchange
+	if err := json.Unmarshal(content, &interchange); err != nil {
+		utils.Fatalf("Could not decode interchange file: %v", err)
+	}
+	stack, protection, genesis, err := openSlashingProtection(ctx)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	if err := protection.Import(ctx.Context, genesis, &interchange); err != nil {
+		utils.Fatalf("Could not import slashing protection history: %v", err)
+	}
+	fmt.Printf("Imported the history of %d validator keys\n", len(interchange.Data))
+	return nil
+}
+
+func slashingProtectionExport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("interchange file must be given as argument")
+	}
+	file := ctx.Args().First()
+	if _, err := os.Stat(file); err == nil {
+		utils.Fatalf("%s already exists", file)
+	}
+	stack, protection, genesis, err := openSlashingProtection(ctx)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	interchange, err := protection.Export(ctx.Context, genesis)
+	if err != nil {
+		utils.Fatalf("Could not export slashing protection history: %v", err)
+	}
+	content, err := json.MarshalIndent(interchange, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		utils.Fatalf("Could not write interchange file: %v", err)
+	}
+	fmt.Printf("Exported the history of %d validator keys to %s\n", len(interchange.Data), file)
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package block
+
+import "github.com/n42blockchain/N42/common/types"
+
+// BadBlock is a block which failed validation, along with the context it was
+// rejected in.
+type BadBlock struct {
+	Hash   types.Hash `json:"hash"`
+	Number uint64     `json:"number"`
+	// Encoded is the block as returned by Block.Marshal.
+	Encoded []byte `json:"encoded"`
+	// Stage is the validation step the block failed in.
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+	// Head is the number of the canonical head when the block was rejected.
+	Head uint64 `json:"head"`
+	// Time is the unix time at which the block was rejected.
+	Time uint64 `json:"time"`
+}
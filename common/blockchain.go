@@ -39,11 +39,14 @@ type IBlockChain interface {
 	IHeaderChain
 	Config() *params.ChainConfig
 	CurrentBlock() block.IBlock
+	CurrentFinalBlock() block.IHeader
+	CurrentSafeBlock() block.IHeader
 	Blocks() []block.IBlock
 	Start() error
 	GenesisBlock() block.IBlock
 	NewBlockHandler(payload []byte, peer peer.ID) error
 	InsertChain(blocks []block.IBlock) (int, error)
+	InsertChainWithoutSealVerification(blocks []block.IBlock) (int, error)
 	InsertBlock(blocks []block.IBlock, isSync bool) (int, error)
 	SetEngine(engine consensus.Engine)
 	GetBlocksFromHash(hash types.Hash, n int) (blocks []block.IBlock)
@@ -52,6 +55,8 @@ type IBlockChain interface {
 	GetReceipts(blockHash types.Hash) (block.Receipts, error)
 	GetLogs(blockHash types.Hash) ([][]*block.Log, error)
 	SetHead(head uint64) error
+	CommitSnapHead(hash types.Hash) error
+	SetFinality(final, safe types.Hash) error
 	AddFutureBlock(block block.IBlock) error
 
 	GetHeader(types.Hash, *uint256.Int) block.IHeader
@@ -69,6 +74,8 @@ type IBlockChain interface {
 
 	WriteBlockWithState(block block.IBlock, receipts []*block.Receipt, ibs *state.IntraBlockState, nopay map[types.Address]*uint256.Int) error
 
+	BadBlocks() []*block.BadBlock
+
 	GetDepositInfo(address types.Address) (*uint256.Int, *uint256.Int)
 	GetAccountRewardUnpaid(account types.Address) (*uint256.Int, error)
 }
@@ -20,6 +20,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/n42blockchain/N42/common/block"
 	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/modules/state"
 )
 
@@ -50,6 +51,16 @@ type DownloaderStartEvent struct{}
 // DownloaderFinishEvent finish download
 type DownloaderFinishEvent struct{}
 
+// ChainReorgEvent is posted when the canonical chain switches to a fork,
+// Depth blocks of the old chain being dropped. Halted is set when the fork was
+// deeper than allowed and the chain stayed on the old head.
+type ChainReorgEvent struct {
+	OldHead types.Hash
+	NewHead types.Hash
+	Depth   uint64
+	Halted  bool
+}
+
 type ChainHighestBlock struct {
 	Block    block.Block
 	Inserted bool
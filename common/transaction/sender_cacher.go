@@ -0,0 +1,127 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package transaction
+
+import (
+	"runtime"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/n42blockchain/N42/common/types"
+)
+
+// senderCacheSize is the number of recovered senders kept by transaction
+// hash, enough for the transactions of a sync batch and the pool.
+const senderCacheSize = 1 << 16
+
+// SenderCacher is the sender recoverer shared by block import and the
+// transaction pool.
+var SenderCacher = NewTxSenderCacher(runtime.NumCPU())
+
+// SetSenderWorkers replaces SenderCacher by one running the given number of
+// workers, the number of CPUs if zero. It must be called before blocks or
+// transactions are processed.
+func SetSenderWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers == SenderCacher.threads {
+		return
+	}
+	old := SenderCacher
+	SenderCacher = NewTxSenderCacher(workers)
+	close(old.tasks)
+}
+
+// txSenderCacherRequest is a request for recovering the senders of every
+// inc-th transaction of txs with signer.
+type txSenderCacherRequest struct {
+	signer Signer
+	txs    []*Transaction
+	inc    int
+	done   *sync.WaitGroup
+}
+
+// TxSenderCacher recovers the senders of transactions on a pool of workers,
+// each taking a batch of the transactions, and caches them by transaction
+// hash, so that a transaction decoded again, from a block after the pool or
+// from another peer, needs no further ECRECOVER.
+type TxSenderCacher struct {
+	threads int
+	tasks   chan *txSenderCacherRequest
+	senders *lru.Cache[types.Hash, types.Address]
+}
+
+// NewTxSenderCacher creates a sender recoverer running threads workers.
+func NewTxSenderCacher(threads int) *TxSenderCacher {
+	senders, _ := lru.New[types.Hash, types.Address](senderCacheSize)
+	cacher := &TxSenderCacher{
+		threads: threads,
+		tasks:   make(chan *txSenderCacherRequest, threads),
+		senders: senders,
+	}
+	for i := 0; i < threads; i++ {
+		go cacher.cache()
+	}
+	return cacher
+}
+
+// cache recovers the senders of the batches of the requests it receives
+// until the cacher is replaced.
+func (cacher *TxSenderCacher) cache() {
+	for task := range cacher.tasks {
+		for i := 0; i < len(task.txs); i += task.inc {
+			cacher.Sender(task.signer, task.txs[i])
+		}
+		task.done.Done()
+	}
+}
+
+// Recover recovers the senders of txs with signer, split over the workers,
+// and returns once they are all recovered. Transactions with an invalid
+// signature are left for Sender to report.
+func (cacher *TxSenderCacher) Recover(signer Signer, txs []*Transaction) {
+	if len(txs) == 0 {
+		return
+	}
+	tasks := min(cacher.threads, (len(txs)+3)/4)
+	var done sync.WaitGroup
+	done.Add(tasks)
+	for i := 0; i < tasks; i++ {
+		cacher.tasks <- &txSenderCacherRequest{
+			signer: signer,
+			txs:    txs[i:],
+			inc:    tasks,
+			done:   &done,
+		}
+	}
+	done.Wait()
+}
+
+// Sender returns the sender of tx, from the cache if it was recovered before.
+func (cacher *TxSenderCacher) Sender(signer Signer, tx *Transaction) (types.Address, error) {
+	hash := tx.Hash()
+	if from, ok := cacher.senders.Get(hash); ok {
+		return from, nil
+	}
+	from, err := Sender(signer, tx)
+	if err != nil {
+		return types.Address{}, err
+	}
+	cacher.senders.Add(hash, from)
+	return from, nil
+}
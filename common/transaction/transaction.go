@@ -373,6 +373,11 @@ func (tx *Transaction) Type() uint8 {
 	return tx.inner.txType()
 }
 
+// Time returns the time the transaction was first seen locally.
+func (tx *Transaction) Time() time.Time {
+	return tx.time
+}
+
 func (tx *Transaction) ChainId() *uint256.Int {
 	return tx.inner.chainID()
 }
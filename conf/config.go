@@ -33,6 +33,7 @@ type Config struct {
 	ChainCfg    *params.ChainConfig `json:"chain" yaml:"chain"`
 	AccountCfg  AccountConfig       `json:"account" yaml:"account"`
 	MetricsCfg  MetricsConfig       `json:"metrics" yaml:"metrics"`
+	TracingCfg  TracingConfig       `json:"tracing" yaml:"tracing"`
 	P2PCfg      *P2PConfig          `json:"p2p" yaml:"p2p"`
 	// Gas Price Oracle options
 	GPO   GpoConfig   `json:"gpo" yaml:"gpo"`
@@ -25,4 +25,36 @@ type DatabaseConfig struct {
 	IsMem      bool     `json:"memory" yaml:"memory"`
 	MaxDB      uint64   `json:"max_db" yaml:"max_db"`
 	MaxReaders uint64   `json:"max_readers" yaml:"max_readers"`
+
+	// HeaderCache, BodyCache and ReceiptCache are the numbers of decoded
+	// headers, bodies and receipts of recently read blocks kept in memory,
+	// zero disables the cache.
+	HeaderCache  int `json:"header_cache" yaml:"header_cache"`
+	BodyCache    int `json:"body_cache" yaml:"body_cache"`
+	ReceiptCache int `json:"receipt_cache" yaml:"receipt_cache"`
+
+	// Compression zstd compresses the transactions and receipts written to
+	// a new database. It is recorded in the database on creation and cannot
+	// be changed later.
+	Compression bool `json:"compression" yaml:"compression"`
+
+	// MapSize is the maximum size the database may grow to and GrowthStep
+	// the size it grows by when full, for example "8TB" and "2GB".
+	MapSize    string `json:"map_size" yaml:"map_size"`
+	GrowthStep string `json:"growth_step" yaml:"growth_step"`
+	// PageSize is the page size of a new database, empty for the default of
+	// the OS. It cannot be changed once the database is created.
+	PageSize string `json:"page_size" yaml:"page_size"`
+	// DirtySpace is the amount of modified pages a write transaction keeps
+	// in memory before spilling them to disk, empty for a share of the RAM.
+	DirtySpace string `json:"dirty_space" yaml:"dirty_space"`
+	// SyncMode is "durable" to flush every commit to disk, or "safe-nosync"
+	// to flush them periodically, losing the last commits on a crash but
+	// never corrupting the database.
+	SyncMode string `json:"sync_mode" yaml:"sync_mode"`
+
+	// ReadOnly opens the database without writing to it, so that it can be
+	// read while the node owning the data directory runs. A node opened
+	// read-only serves RPC on the chain as of its start and does not sync.
+	ReadOnly bool `json:"readonly" yaml:"readonly"`
 }
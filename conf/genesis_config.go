@@ -17,6 +17,9 @@
 package conf
 
 import (
+	"fmt"
+	"math/big"
+
 	"github.com/holiman/uint256"
 	"github.com/n42blockchain/N42/common/types"
 
@@ -55,3 +58,54 @@ type GenesisAccount struct {
 	Storage map[types.Hash]types.Hash `json:"storage,omitempty"`
 	Nonce   uint64                    `json:"nonce,omitempty"`
 }
+
+// Validate checks that the genesis specification describes a chain a node can
+// run: a chain config with a chain ID and the parameters of its consensus
+// engine, the initial signers the engine needs, and decimal balances.
+func (g *Genesis) Validate() error {
+	if g.Config == nil {
+		return fmt.Errorf("missing chain config")
+	}
+	if g.Config.ChainID == nil || g.Config.ChainID.Sign() <= 0 {
+		return fmt.Errorf("missing chain ID")
+	}
+	switch g.Config.Consensus {
+	case params.AposConsensu:
+		if g.Config.Apos == nil {
+			return fmt.Errorf("missing apos consensus parameters")
+		}
+		if g.Config.Apos.Epoch == 0 {
+			return fmt.Errorf("apos epoch must be positive")
+		}
+	case params.CliqueConsensus:
+		if g.Config.Clique == nil {
+			return fmt.Errorf("missing clique consensus parameters")
+		}
+		if g.Config.Clique.Epoch == 0 {
+			return fmt.Errorf("clique epoch must be positive")
+		}
+	default:
+		return fmt.Errorf("unsupported consensus %q", g.Config.Consensus)
+	}
+	if len(g.Miners) == 0 {
+		return fmt.Errorf("no miners, the %s engine needs at least one signer", g.Config.Consensus)
+	}
+	for _, miner := range g.Miners {
+		if _, err := types.HexToString(miner); err != nil || len(miner) != 2+2*types.AddressLength {
+			return fmt.Errorf("invalid miner %q", miner)
+		}
+	}
+	if err := g.Config.CheckConfigForkOrder(); err != nil {
+		return err
+	}
+	if fee := g.Config.Eip1559MinBaseFee; fee != nil && (fee.Sign() < 0 || fee.BitLen() > 256) {
+		return fmt.Errorf("invalid EIP-1559 minimum base fee %v", fee)
+	}
+	for addr, account := range g.Alloc {
+		balance, ok := new(big.Int).SetString(account.Balance, 10)
+		if !ok || balance.Sign() < 0 || balance.BitLen() > 256 {
+			return fmt.Errorf("invalid balance %q of %s", account.Balance, addr)
+		}
+	}
+	return nil
+}
@@ -17,10 +17,21 @@
 package conf
 
 type LoggerConfig struct {
+	// LogFile is the log file, relative to the log directory of the data
+	// directory unless absolute. No log file is written if it is empty.
 	LogFile    string `json:"name" yaml:"name"`
 	Level      string `json:"level" yaml:"level"`
 	MaxSize    int    `json:"max_size" yaml:"max_size"`
 	MaxBackups int    `json:"max_count" yaml:"max_count"`
 	MaxAge     int    `json:"max_day" yaml:"max_day"`
 	Compress   bool   `json:"compress" yaml:"compress"`
+
+	// ConsoleFormat and FileFormat are the formats of the logs written to
+	// the console and to the log file, "text" or "json".
+	ConsoleFormat string `json:"console_format" yaml:"console_format"`
+	FileFormat    string `json:"file_format" yaml:"file_format"`
+
+	// VModule overrides the level of some packages, as a comma separated
+	// list of pattern=level entries, e.g. "p2p=trace,rawdb=debug".
+	VModule string `json:"vmodule" yaml:"vmodule"`
 }
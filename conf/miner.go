@@ -22,8 +22,10 @@ import (
 )
 
 type MinerConfig struct {
-	Etherbase string        // Public address for block mining rewards
-	GasCeil   uint64        // Target gas ceiling for mined blocks.
-	GasPrice  *big.Int      // Minimum gas price for mining a transaction
-	Recommit  time.Duration // The time interval for miner to re-create mining work
+	Etherbase  string        // Public address for block mining rewards
+	ExtraData  string        // Block extra data set by the miner
+	GasCeil    uint64        // Target gas ceiling for mined blocks.
+	GasPrice   *big.Int      // Minimum gas price for mining a transaction
+	Recommit   time.Duration // The time interval for miner to re-create mining work
+	TxOrdering string        // Ordering of the transactions in mined blocks, "tip", "fifo" or a registered one
 }
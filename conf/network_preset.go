@@ -0,0 +1,61 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package conf
+
+import (
+	"strconv"
+
+	"github.com/n42blockchain/N42/params"
+)
+
+// ApplyNetworkPreset fills in the settings implied by NodeCfg.Chain from the
+// built-in network presets: chain config, bootnodes and default ports. Values
+// that are already set are left untouched. Chains without a preset (e.g. a
+// private network initialised from a genesis file) are ignored.
+func (c *Config) ApplyNetworkPreset() *params.NetworkPreset {
+	preset := params.NetworkPresetByName(c.NodeCfg.Chain)
+	if preset == nil {
+		return nil
+	}
+
+	if c.ChainCfg == nil {
+		c.ChainCfg = preset.ChainConfig
+	}
+	if c.NodeCfg.HTTPPort == "" {
+		c.NodeCfg.HTTPPort = strconv.Itoa(preset.HTTPPort)
+	}
+	if c.NodeCfg.WSPort == "" {
+		c.NodeCfg.WSPort = strconv.Itoa(preset.WSPort)
+	}
+	if c.NodeCfg.AuthPort == 0 {
+		c.NodeCfg.AuthPort = preset.AuthPort
+	}
+
+	if c.P2PCfg == nil {
+		c.P2PCfg = &P2PConfig{P2PLimit: &P2PLimit{}}
+	}
+	if len(c.P2PCfg.BootstrapNodeAddr) == 0 {
+		c.P2PCfg.BootstrapNodeAddr = preset.Bootnodes
+	}
+	if c.P2PCfg.TCPPort == 0 {
+		c.P2PCfg.TCPPort = preset.TCPPort
+	}
+	if c.P2PCfg.UDPPort == 0 {
+		c.P2PCfg.UDPPort = preset.UDPPort
+	}
+	return preset
+}
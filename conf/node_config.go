@@ -17,12 +17,15 @@
 package conf
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const (
-	datadirDefaultKeyStore = "keystore" // Path within the datadir to the keystore
+	datadirDefaultKeyStore          = "keystore"           // Path within the datadir to the keystore
+	datadirDefaultValidatorKeyStore = "validator_keystore" // Path within the datadir to the validator keystore
 )
 
 type NodeConfig struct {
@@ -35,11 +38,34 @@ type NodeConfig struct {
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
 	HTTPCors string `json:"http_cors" yaml:"http_cors"`
+	// HTTPTLSCert and HTTPTLSKey are the PEM encoded certificate and private key
+	// files. When both are set the HTTP-RPC server serves HTTPS directly.
+	HTTPTLSCert string `json:"http_tls_cert" yaml:"http_tls_cert"`
+	HTTPTLSKey  string `json:"http_tls_key" yaml:"http_tls_key"`
+	// HTTPGzipMinSize is the size in bytes from which HTTP-RPC responses are
+	// gzip compressed for clients that accept it. A negative value disables it.
+	HTTPGzipMinSize int `json:"http_gzip_min_size" yaml:"http_gzip_min_size"`
+	// HTTPAllowMethods and HTTPDenyMethods refine HTTPApi per method. Both are
+	// comma separated method names or namespace wildcards like "debug_*".
+	HTTPAllowMethods string `json:"http_allow_methods" yaml:"http_allow_methods"`
+	HTTPDenyMethods  string `json:"http_deny_methods" yaml:"http_deny_methods"`
 
 	WS     bool   `json:"ws" yaml:"ws" `
 	WSHost string `json:"ws_host" yaml:"ws_host" `
 	WSPort string `json:"ws_port" yaml:"ws_port"`
 	WSApi  string `json:"ws_api" yaml:"ws_api"`
+	// WSTLSCert and WSTLSKey make the WS-RPC server accept wss connections.
+	// Both take comma separated lists of matching files to serve several
+	// certificates selected by SNI.
+	WSTLSCert string `json:"ws_tls_cert" yaml:"ws_tls_cert"`
+	WSTLSKey  string `json:"ws_tls_key" yaml:"ws_tls_key"`
+	// WSAllowMethods and WSDenyMethods are the WS-RPC counterparts of
+	// HTTPAllowMethods and HTTPDenyMethods.
+	WSAllowMethods string `json:"ws_allow_methods" yaml:"ws_allow_methods"`
+	WSDenyMethods  string `json:"ws_deny_methods" yaml:"ws_deny_methods"`
+	// WSNotificationBuffer is the number of subscription notifications queued
+	// per WebSocket connection. Clients falling further behind are dropped.
+	WSNotificationBuffer int `json:"ws_notification_buffer" yaml:"ws_notification_buffer"`
 	// WSOrigins is the list of domain to accept websocket requests from. Please be
 	// aware that the server can only act upon the HTTP request the client sends and
 	// cannot verify the validity of the request header.
@@ -50,6 +76,155 @@ type NodeConfig struct {
 	Chain            string `json:"chain" yaml:"chain"`
 	Miner            bool   `json:"miner" yaml:"miner"`
 
+	// OverrideChainConfig starts the node with the chain config of the chain
+	// even if it reschedules forks the database has already passed.
+	OverrideChainConfig bool `json:"override_chain_config" yaml:"override_chain_config"`
+
+	// MaxDataDirSize is the size in GB the data directory should stay under,
+	// zero for no quota. A warning is logged each time its usage crosses one
+	// of DataDirWarnPercents, a comma separated list of percentages.
+	MaxDataDirSize      int    `json:"max_data_dir_size" yaml:"max_data_dir_size"`
+	DataDirWarnPercents string `json:"data_dir_warn_percents" yaml:"data_dir_warn_percents"`
+
+	// AncientDir is the directory of the freezer holding the headers, bodies
+	// and receipts of old blocks, <datadir>/ancient if empty. Blocks more than
+	// FreezeThreshold blocks below the head are moved there from the database,
+	// zero disables freezing.
+	AncientDir      string `json:"ancient_dir" yaml:"ancient_dir"`
+	FreezeThreshold uint64 `json:"freeze_threshold" yaml:"freeze_threshold"`
+
+	// Prune is the pruning mode, archive, full or minimal, which sets how many
+	// recent blocks keep their state history, receipts and transaction index.
+	// PruneHistory, PruneReceipts and PruneTxIndex override the number of
+	// blocks of the mode if non-zero.
+	Prune         string `json:"prune" yaml:"prune"`
+	PruneHistory  uint64 `json:"prune_history" yaml:"prune_history"`
+	PruneReceipts uint64 `json:"prune_receipts" yaml:"prune_receipts"`
+	PruneTxIndex  uint64 `json:"prune_tx_index" yaml:"prune_tx_index"`
+
+	// SenderWorkers is the number of workers recovering the senders of the
+	// transactions of imported blocks and of the pool, the number of CPUs if
+	// zero.
+	SenderWorkers int `json:"sender_workers" yaml:"sender_workers"`
+
+	// ExecWorkers is the number of workers executing the transactions of an
+	// imported block optimistically in parallel, transactions whose accounts
+	// were changed by the ones before them being executed again in order. At
+	// most one executes them serially.
+	ExecWorkers int `json:"exec_workers" yaml:"exec_workers"`
+
+	// SyncMode is how an empty node catches up with the chain: full executes
+	// every block from genesis, snap downloads the state of a recent block
+	// from peers and executes the blocks after it only.
+	SyncMode string `json:"sync_mode" yaml:"sync_mode"`
+	// SyncCheckpoint is a finalized block, given as <number>:<hash>, an
+	// empty node syncs from like in snap mode while only accepting a chain
+	// containing it. It defaults to the checkpoint of the network preset.
+	SyncCheckpoint string `json:"sync_checkpoint" yaml:"sync_checkpoint"`
+
+	// DisabledStages is a comma separated list of the optional stages of the
+	// sync pipeline the node skips, txindex and logindex. The log index also
+	// needs LogsIndex.
+	DisabledStages string `json:"disabled_stages" yaml:"disabled_stages"`
+
+	// ReadyMaxBlocksBehind and ReadyMinPeers are the thresholds of the /readyz
+	// probe: the node reports ready while it is at most ReadyMaxBlocksBehind
+	// blocks behind its best peer, has ReadyMinPeers peers and its RPC servers
+	// are up.
+	ReadyMaxBlocksBehind uint64 `json:"ready_max_blocks_behind" yaml:"ready_max_blocks_behind"`
+	ReadyMinPeers        int    `json:"ready_min_peers" yaml:"ready_min_peers"`
+
+	// ShutdownTimeout is how long the node waits on shutdown for the RPC
+	// requests in flight and the block being imported to finish.
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+
+	// Dev runs a single-node developer network with a prefunded, unlocked
+	// account that seals blocks every DevPeriod seconds, or on demand when
+	// DevPeriod is 0.
+	Dev       bool   `json:"dev" yaml:"dev"`
+	DevPeriod uint64 `json:"dev_period" yaml:"dev_period"`
+
+	// RPCRateLimit is the number of HTTP requests (or WS connection attempts)
+	// per second accepted from a single client IP, with RPCRateBurst allowed in
+	// a burst. The messages of an open WS connection are not limited. Zero
+	// disables the limiter. RPCTrustedProxies is a comma
+	// separated list of proxy IPs/CIDRs whose forwarding headers are trusted.
+	RPCRateLimit      float64 `json:"rpc_rate_limit" yaml:"rpc_rate_limit"`
+	RPCRateBurst      int     `json:"rpc_rate_burst" yaml:"rpc_rate_burst"`
+	RPCTrustedProxies string  `json:"rpc_trusted_proxies" yaml:"rpc_trusted_proxies"`
+
+	// RPCAllowIPs is the list of CIDR ranges allowed to connect to the HTTP, WS and
+	// authenticated RPC servers. Requests from other addresses are rejected before
+	// they are parsed. Empty allows all clients.
+	RPCAllowIPs []string `json:"rpc_allow_ips" yaml:"rpc_allow_ips"`
+
+	// BatchRequestLimit is the maximum number of requests in a JSON-RPC batch
+	// and BatchResponseMaxSize the maximum number of bytes returned for it.
+	// Zero means no limit.
+	BatchRequestLimit    int `json:"batch_request_limit" yaml:"batch_request_limit"`
+	BatchResponseMaxSize int `json:"batch_response_max_size" yaml:"batch_response_max_size"`
+
+	// RPCExecutionTimeout is the maximum time a single RPC call may run before
+	// its context is cancelled and a timeout error is returned. Zero disables it.
+	RPCExecutionTimeout time.Duration `json:"rpc_execution_timeout" yaml:"rpc_execution_timeout"`
+
+	// RPCAccessLogRate is the fraction of the calls served by the HTTP, WS and
+	// authenticated RPC servers that is written to the log, from 0 (disabled)
+	// to 1. RPCAccessLogRedact lists the methods, or namespace wildcards like
+	// "personal_*", whose parameters are left out of the log.
+	RPCAccessLogRate   float64 `json:"rpc_access_log_rate" yaml:"rpc_access_log_rate"`
+	RPCAccessLogRedact string  `json:"rpc_access_log_redact" yaml:"rpc_access_log_redact"`
+
+	// FilterTimeout is how long a filter installed with eth_newFilter and
+	// friends is kept without eth_getFilterChanges being called for it.
+	FilterTimeout time.Duration `json:"filter_timeout" yaml:"filter_timeout"`
+
+	// LogsMaxBlockRange is the widest block range a single eth_getLogs query
+	// may span and LogsMaxResults the most logs it may return. Zero means no limit.
+	LogsMaxBlockRange uint64 `json:"logs_max_block_range" yaml:"logs_max_block_range"`
+	LogsMaxResults    int    `json:"logs_max_results" yaml:"logs_max_results"`
+	// LogsIndex keeps an index of the addresses and topics of logs by block,
+	// which range queries consult instead of the bloom of every header.
+	LogsIndex bool `json:"logs_index" yaml:"logs_index"`
+
+	// PersistBadBlocks also stores blocks which failed validation in the
+	// database, so debug_getBadBlocks still reports them after a restart.
+	PersistBadBlocks bool `json:"persist_bad_blocks" yaml:"persist_bad_blocks"`
+
+	// ProposalDeadline is the least time a proposer keeps before the
+	// timestamp of its block to collect the signatures of the verifiers, the
+	// timestamp is pushed back to leave it. MaxFutureDrift is how far ahead of
+	// the local clock the timestamp of a received block may be, for the clocks
+	// of the nodes never agree exactly.
+	ProposalDeadline time.Duration `json:"proposal_deadline" yaml:"proposal_deadline"`
+	MaxFutureDrift   time.Duration `json:"max_future_drift" yaml:"max_future_drift"`
+	// NTPServer is the NTP server the local clock is compared to at startup,
+	// a warning being logged if they drift apart by more than
+	// MaxFutureDrift. Empty disables the check.
+	NTPServer string `json:"ntp_server" yaml:"ntp_server"`
+
+	// ReorgMaxDepth is the most blocks of the canonical chain a reorg may
+	// drop. A deeper fork halts the import of the chain and is reported
+	// instead of being switched to. Zero means no limit.
+	ReorgMaxDepth uint64 `json:"reorg_max_depth" yaml:"reorg_max_depth"`
+
+	// GraphQL mounts a GraphQL query endpoint at /graphql on the HTTP-RPC
+	// server. GraphQLCors and GraphQLVHosts are comma separated and apply to
+	// that endpoint only.
+	GraphQL       bool   `json:"graphql" yaml:"graphql"`
+	GraphQLCors   string `json:"graphql_cors" yaml:"graphql_cors"`
+	GraphQLVHosts string `json:"graphql_vhosts" yaml:"graphql_vhosts"`
+
+	// PrivateAPIAddr is the address of the gRPC server streaming reads of the
+	// chain database with the remote KV protocol of erigon, empty to disable
+	// it. PrivateAPIMaxStreams caps the concurrent streams per client
+	// connection, zero for no limit. PrivateAPITLSCert and PrivateAPITLSKey
+	// make it serve TLS.
+	PrivateAPIAddr       string `json:"private_api_addr" yaml:"private_api_addr"`
+	PrivateAPIMaxStreams int    `json:"private_api_max_streams" yaml:"private_api_max_streams"`
+	PrivateAPITLSCert    string `json:"private_api_tls_cert" yaml:"private_api_tls_cert"`
+	PrivateAPITLSKey     string `json:"private_api_tls_key" yaml:"private_api_tls_key"`
+
 	AuthRPC bool `json:"auth_rpc" yaml:"auth_rpc"`
 	// AuthAddr is the listening address on which authenticated APIs are provided.
 	AuthAddr string `json:"auth_addr" yaml:"auth_addr"`
@@ -61,7 +236,8 @@ type NodeConfig struct {
 	// for the authenticated api. This is by default {'localhost'}.
 	AuthVirtualHosts []string `json:"auth_virtual_hosts" yaml:"auth_virtual_hosts"`
 
-	// JWTSecret is the path to the hex-encoded jwt secret.
+	// JWTSecret is a comma separated list of hex-encoded jwt secret files
+	// and directories of them, see admin_reloadJWTSecrets.
 	JWTSecret string `json:"jwt_secret" yaml:"jwt_secret"`
 
 	// KeyStoreDir is the file system folder that contains private keys. The directory can
@@ -73,6 +249,11 @@ type NodeConfig struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `json:"key_store_dir" yaml:"key_store_dir"`
 
+	// ValidatorKeyStoreDir is the folder that contains the EIP-2335 encrypted BLS
+	// keys of validators, resolved like KeyStoreDir. The default location is the
+	// "validator_keystore" subdirectory of DataDir.
+	ValidatorKeyStoreDir string `json:"validator_key_store_dir" yaml:"validator_key_store_dir"`
+
 	// ExternalSigner specifies an external URI for a clef-type signer
 	ExternalSigner string `json:"external_signer" yaml:"external_signer"`
 
@@ -107,6 +288,19 @@ func (c *NodeConfig) KeyDirConfig() (string, error) {
 	return keydir, err
 }
 
+// ValidatorKeyDirConfig determines the directory of the validator keystore.
+func (c *NodeConfig) ValidatorKeyDirConfig() (string, error) {
+	switch {
+	case filepath.IsAbs(c.ValidatorKeyStoreDir):
+		return c.ValidatorKeyStoreDir, nil
+	case c.ValidatorKeyStoreDir != "":
+		return filepath.Abs(c.ValidatorKeyStoreDir)
+	case c.DataDir != "":
+		return filepath.Join(c.DataDir, datadirDefaultValidatorKeyStore), nil
+	}
+	return "", errors.New("no validator keystore or data directory configured")
+}
+
 // getKeyStoreDir retrieves the key directory and will create
 // and ephemeral one if necessary.
 func getKeyStoreDir(conf *NodeConfig) (string, bool, error) {
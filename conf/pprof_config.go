@@ -17,9 +17,16 @@
 package conf
 
 type PprofConfig struct {
-	MaxCpu     int  `json:"cpu" yaml:"cpu"`
-	Port       int  `json:"port" yaml:"port"`
-	TraceMutex bool `json:"trace_mutex" yaml:"trace_mutex"`
-	TraceBlock bool `json:"trace_block" yaml:"trace_block"`
-	Pprof      bool `json:"pprof" yaml:"pprof"`
+	MaxCpu     int    `json:"cpu" yaml:"cpu"`
+	Addr       string `json:"addr" yaml:"addr"`
+	Port       int    `json:"port" yaml:"port"`
+	TraceMutex bool   `json:"trace_mutex" yaml:"trace_mutex"`
+	TraceBlock bool   `json:"trace_block" yaml:"trace_block"`
+	Pprof      bool   `json:"pprof" yaml:"pprof"`
+
+	// CPUProfile is the file a CPU profile is written to while the node
+	// runs. It is finished on shutdown.
+	CPUProfile string `json:"cpu_profile" yaml:"cpu_profile"`
+	// MemProfile is the file a heap profile is written to on shutdown.
+	MemProfile string `json:"mem_profile" yaml:"mem_profile"`
 }
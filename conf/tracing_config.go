@@ -0,0 +1,29 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package conf
+
+// TracingConfig configures the export of traces to an OpenTelemetry
+// collector.
+type TracingConfig struct {
+	Enable bool `json:"enable" yaml:"enable"`
+	// Endpoint is the host:port of the OTLP/HTTP receiver of the collector.
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Insecure sends the traces over plain HTTP instead of HTTPS.
+	Insecure bool `json:"insecure" yaml:"insecure"`
+	// SampleRatio is the fraction of traces recorded, between 0 and 1.
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+}
@@ -17,19 +17,191 @@
 package api
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
 	"github.com/n42blockchain/N42/common/hexutil"
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/internal/avm/common"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+	"github.com/n42blockchain/N42/modules/state"
+	"github.com/n42blockchain/N42/turbo/rpchelper"
 )
 
 type DumpAccount struct {
-	Balance   string                `json:"balance"`
-	Nonce     uint64                `json:"nonce"`
-	Root      hexutil.Bytes         `json:"root"`
-	CodeHash  hexutil.Bytes         `json:"codeHash"`
-	Code      hexutil.Bytes         `json:"code,omitempty"`
-	Storage   map[types.Hash]string `json:"storage,omitempty"`
-	Address   *common.Address       `json:"address,omitempty"` // Address only present in iterative (line-by-line) mode
-	SecureKey hexutil.Bytes         `json:"key,omitempty"`     // If we don't have address, we can output the key
+	Balance     string                `json:"balance"`
+	Nonce       uint64                `json:"nonce"`
+	Root        hexutil.Bytes         `json:"root"`
+	CodeHash    hexutil.Bytes         `json:"codeHash"`
+	Code        hexutil.Bytes         `json:"code,omitempty"`
+	Storage     map[types.Hash]string `json:"storage,omitempty"`
+	Address     *common.Address       `json:"address,omitempty"`     // Address only present in iterative (line-by-line) mode
+	SecureKey   hexutil.Bytes         `json:"key,omitempty"`         // If we don't have address, we can output the key
+	NextStorage hexutil.Bytes         `json:"nextStorage,omitempty"` // Location of the first storage slot left out, nil if none were
+
+}
+
+// AccountRangeMaxResults is the most accounts debug_accountRange returns per
+// call, and AccountRangeMaxStorage the most storage slots it returns per
+// account. StorageRangeMaxResults is the most slots debug_storageRange returns
+// per call.
+const (
+	AccountRangeMaxResults = 256
+	AccountRangeMaxStorage = 1024
+	StorageRangeMaxResults = 1024
+)
+
+// AccountRangeResult is a page of the accounts of the state as of a block.
+type AccountRangeResult struct {
+	Root     types.Hash                    `json:"root"`
+	Accounts map[types.Address]DumpAccount `json:"accounts"`
+	Next     hexutil.Bytes                 `json:"next,omitempty"` // Address to start the next page at, nil after the last account
+}
+
+// StorageRangeResult is a page of the storage of an account as of a block.
+type StorageRangeResult struct {
+	Storage map[types.Hash]string `json:"storage"`
+	Next    hexutil.Bytes         `json:"next,omitempty"` // Location to start the next page at, nil after the last slot
+}
+
+// AccountRange returns up to maxResults accounts of the state as of the given
+// block, in address order starting at start. The accounts are iterated from
+// the flat account and storage tables, which hold the current state and are
+// rewound through the state history for older blocks. At most
+// AccountRangeMaxStorage storage slots are returned per account, the rest are
+// paged through with StorageRange from the account's nextStorage.
+func (api *PrivateDebugAPI) AccountRange(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage bool) (*AccountRangeResult, error) {
+	if len(start) > types.AddressLength {
+		return nil, fmt.Errorf("start %x is longer than an address", start)
+	}
+	if maxResults <= 0 || maxResults > AccountRangeMaxResults {
+		maxResults = AccountRangeMaxResults
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	header, asOf, err := stateAsOf(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		reader = state.NewPlainState(tx, asOf)
+		result = &AccountRangeResult{Root: header.Root, Accounts: make(map[types.Address]DumpAccount)}
+	)
+	err = state.WalkAsOfAccounts(tx, types.BytesToAddress(start), asOf, func(k, _ []byte) (bool, error) {
+		addr := types.BytesToAddress(k)
+		if len(result.Accounts) == maxResults {
+			result.Next = addr.Bytes()
+			return false, nil
+		}
+		acc, err := reader.ReadAccountData(addr)
+		if err != nil {
+			return false, err
+		}
+		if acc == nil {
+			return true, nil
+		}
+		dump := DumpAccount{
+			Balance:  acc.Balance.ToBig().String(),
+			Nonce:    acc.Nonce,
+			Root:     acc.Root.Bytes(),
+			CodeHash: acc.CodeHash.Bytes(),
+		}
+		if !nocode {
+			if dump.Code, err = reader.ReadAccountCode(addr, acc.Incarnation, acc.CodeHash); err != nil {
+				return false, err
+			}
+		}
+		if !nostorage {
+			if dump.Storage, dump.NextStorage, err = storageRange(tx, addr, acc.Incarnation, types.Hash{}, asOf, AccountRangeMaxStorage); err != nil {
+				return false, err
+			}
+		}
+		result.Accounts[addr] = dump
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StorageRange returns up to maxResults storage slots of address as of the
+// given block, in location order starting at start.
+func (api *PrivateDebugAPI) StorageRange(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash, address types.Address, start hexutil.Bytes, maxResults int) (*StorageRangeResult, error) {
+	if len(start) > types.HashLength {
+		return nil, fmt.Errorf("start %x is longer than a hash", start)
+	}
+	if maxResults <= 0 || maxResults > StorageRangeMaxResults {
+		maxResults = StorageRangeMaxResults
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, asOf, err := stateAsOf(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := state.NewPlainState(tx, asOf).ReadAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	result := &StorageRangeResult{Storage: make(map[types.Hash]string)}
+	if acc == nil {
+		return result, nil
+	}
+	if result.Storage, result.Next, err = storageRange(tx, address, acc.Incarnation, types.BytesToHash(start), asOf, maxResults); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// stateAsOf returns the header of the given block and the timestamp of the
+// state history its state is read at.
+func stateAsOf(tx kv.Tx, blockNrOrHash jsonrpc.BlockNumberOrHash) (*block.Header, uint64, error) {
+	number, hash, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := rawdb.ReadHeader(tx, hash, number.Uint64())
+	if header == nil {
+		return nil, 0, fmt.Errorf("header %d not found", number.Uint64())
+	}
+	// The state as of a block is the state at the beginning of the next one.
+	asOf := number.Uint64() + 1
+	if err := rawdb.CheckPruned(tx, rawdb.PruneHistory, asOf); err != nil {
+		return nil, 0, err
+	}
+	return header, asOf, nil
+}
 
+// storageRange returns up to maxResults storage slots of the given incarnation
+// of address as of asOf starting at location start, and the location of the
+// next slot if there are more.
+func storageRange(tx kv.Tx, address types.Address, incarnation uint16, start types.Hash, asOf uint64, maxResults int) (map[types.Hash]string, hexutil.Bytes, error) {
+	var (
+		storage = make(map[types.Hash]string)
+		next    hexutil.Bytes
+	)
+	err := state.WalkAsOfStorage(tx, address, incarnation, start, asOf, func(_, loc, v []byte) (bool, error) {
+		if len(storage) == maxResults {
+			next = types.CopyBytes(loc)
+			return false, nil
+		}
+		storage[types.BytesToHash(loc)] = hexutil.Encode(v)
+		return true, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage, next, nil
 }
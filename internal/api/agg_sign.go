@@ -29,6 +29,7 @@ import (
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/contracts/deposit"
 	"github.com/n42blockchain/N42/internal/consensus"
+	"github.com/n42blockchain/N42/internal/slashing"
 	"github.com/n42blockchain/N42/log"
 	event "github.com/n42blockchain/N42/modules/event/v2"
 	"github.com/n42blockchain/N42/modules/rawdb"
@@ -254,7 +255,9 @@ LOOP:
 	return aggSign, verifiers, nil
 }
 
-func MachineVerify(ctx context.Context) error {
+// MachineVerify signs the state roots of the mined blocks with the local
+// validator keys, skipping the ones protection refuses as slashable.
+func MachineVerify(ctx context.Context, protection *slashing.Protection) error {
 	entire := make(chan common.MinedEntireEvent)
 	blocksSub := event.GlobalEvent.Subscribe(entire)
 	defer blocksSub.Unsubscribe()
@@ -302,12 +305,20 @@ func MachineVerify(ctx context.Context) error {
 						return
 					}
 
+					// slashing protection
+					var pubkey types.PublicKey
+					copy(pubkey[:], pri.PublicKey().Marshal())
+					if err := protection.CheckBlock(ctx, pubkey, b.Entire.Entire.Header.Number.Uint64(), b.Entire.Entire.Header.Root); err != nil {
+						log.Warn("refuse to sign block", "number", b.Entire.Entire.Header.Number.Uint64(), "pubkey", pubkey, "err", err)
+						return
+					}
+
 					// Signature
 					sign := pri.Sign(b.Entire.Entire.Header.Root[:])
 					tmp := AggSign{Number: b.Entire.Entire.Header.Number.Uint64()}
 					copy(tmp.StateRoot[:], b.Entire.Entire.Header.Root[:])
 					copy(tmp.Sign[:], sign.Marshal())
-					copy(tmp.PublicKey[:], pri.PublicKey().Marshal())
+					tmp.PublicKey = pubkey
 					tmp.Address = addr
 					// send res
 					sigChannel <- tmp
@@ -21,7 +21,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/holiman/uint256"
-	"github.com/n42blockchain/N42/conf"
 	"github.com/n42blockchain/N42/internal"
 	"github.com/n42blockchain/N42/internal/api/filters"
 	vm2 "github.com/n42blockchain/N42/internal/vm"
@@ -47,7 +46,11 @@ import (
 	mvm_common "github.com/n42blockchain/N42/internal/avm/common"
 	mvm_types "github.com/n42blockchain/N42/internal/avm/types"
 	"github.com/n42blockchain/N42/internal/consensus"
+	"github.com/n42blockchain/N42/internal/p2p"
+	"github.com/n42blockchain/N42/internal/tracers/logger"
+	"github.com/n42blockchain/N42/internal/txspool"
 	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules"
 	"github.com/n42blockchain/N42/modules/rawdb"
 	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
 	"github.com/n42blockchain/N42/params"
@@ -58,6 +61,10 @@ const (
 	baseFee       = 5000000
 	rpcEVMTimeout = time.Duration(5 * time.Second)
 	rpcGasCap     = 50000000
+
+	// defaultFilterTimeout is how long an installed filter may go unpolled
+	// before it is uninstalled.
+	defaultFilterTimeout = 5 * time.Minute
 )
 
 // API compatible EthereumAPI provides an API to access related information.
@@ -71,6 +78,13 @@ type API struct {
 	chainConfig    *params.ChainConfig
 
 	gpo *Oracle
+
+	filterTimeout time.Duration
+	logLimits     filters.LogLimits
+
+	bundles    *txspool.BundlePool
+	p2p        p2p.P2P
+	syncStatus func(ctx context.Context) (*SyncStatus, error)
 }
 
 // NewAPI creates a new protocol API.
@@ -89,9 +103,49 @@ func (api *API) SetGpo(gpo *Oracle) {
 	api.gpo = gpo
 }
 
+// SetBundlePool sets the pool eth_sendBundle submits to. Without one bundles
+// are rejected.
+func (api *API) SetBundlePool(pool *txspool.BundlePool) {
+	api.bundles = pool
+}
+
+// SetP2P sets the p2p service the net namespace reports on.
+func (api *API) SetP2P(service p2p.P2P) {
+	api.p2p = service
+}
+
+// SetSyncStatus sets the function eth_syncing reports the progress of the
+// sync with, it returns nil once the node caught up. Without one the node is
+// reported as synced.
+func (api *API) SetSyncStatus(status func(ctx context.Context) (*SyncStatus, error)) {
+	api.syncStatus = status
+}
+
+// SetFilterTimeout sets how long filters created with eth_newFilter,
+// eth_newBlockFilter or eth_newPendingTransactionFilter survive without being
+// polled. Zero selects the default of five minutes.
+func (api *API) SetFilterTimeout(timeout time.Duration) {
+	api.filterTimeout = timeout
+}
+
+// SetLogLimits bounds the block range and result count of eth_getLogs and the
+// other log queries.
+func (api *API) SetLogLimits(limits filters.LogLimits) {
+	api.logLimits = limits
+}
+
+// LogLimits returns the limits applied to log queries.
+func (api *API) LogLimits() filters.LogLimits {
+	return api.logLimits
+}
+
 func (api *API) Apis() []jsonrpc.API {
 	nonceLock := new(AddrLocker)
-	return []jsonrpc.API{
+	filterTimeout := api.filterTimeout
+	if filterTimeout <= 0 {
+		filterTimeout = defaultFilterTimeout
+	}
+	apis := []jsonrpc.API{
 		{
 			Namespace: "eth",
 			Service:   NewBlockChainAPI(api),
@@ -111,15 +165,33 @@ func (api *API) Apis() []jsonrpc.API {
 		{
 			Namespace: "debug",
 			Service:   NewDebugAPI(api),
+		}, {
+			Namespace:     "debug",
+			Service:       NewPrivateDebugAPI(api),
+			Authenticated: true,
 		},
 		{
 			Namespace: "txpool",
-			Service:   NewTxsPoolAPI(api),
+			Service:   NewTxPoolAPI(api),
 		}, {
 			Namespace: "eth",
-			Service:   filters.NewFilterAPI(api, 5*time.Minute),
+			Service:   filters.NewFilterAPI(api, filterTimeout),
 		},
+		{
+			Namespace:     "eth",
+			Service:       NewBundleAPI(api),
+			Authenticated: true,
+		},
+	}
+	// Only the apos engine has validators depositing their stake.
+	if _, ok := api.engine.(depositEngine); ok {
+		apis = append(apis, jsonrpc.API{
+			Namespace:     "validator",
+			Service:       NewValidatorAPI(api, nonceLock),
+			Authenticated: true,
+		})
 	}
+	return apis
 }
 
 func (n *API) TxsPool() common.ITxsPool       { return n.txspool }
@@ -154,10 +226,24 @@ func (n *API) State(tx kv.Tx, blockNrOrHash jsonrpc.BlockNumberOrHash) evmtypes.
 		return nil
 	}
 
+	if rawdb.CheckPruned(tx, rawdb.PruneHistory, *blockNr+1) != nil {
+		return nil
+	}
+
 	stateReader := state.NewPlainState(tx, *blockNr+1)
 	return state.New(stateReader)
 }
 
+// CheckStateAvailable fails with rawdb.ErrPruned if the state as of the block
+// of blockNrOrHash was pruned, which State reports as nil.
+func (n *API) CheckStateAvailable(tx kv.Tx, blockNrOrHash jsonrpc.BlockNumberOrHash) error {
+	blockNr, _, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx)
+	if err != nil {
+		return nil
+	}
+	return rawdb.CheckPruned(tx, rawdb.PruneHistory, blockNr.Uint64()+1)
+}
+
 func (n *API) GetChainConfig() *params.ChainConfig {
 	return n.chainConfig
 }
@@ -178,19 +264,14 @@ func NewastAPI(api *API) *astAPI {
 
 // GasPrice returns a suggestion for a gas price for legacy transactions.
 func (s *astAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
-	conf.LightClientGPO.Default = big.NewInt(params.GWei)
-	//oracle := NewOracle(s.api.BlockChain(), conf.LightClientGPO)
 	tipcap, err := s.api.gpo.SuggestTipCap(ctx, s.api.GetChainConfig())
 	if err != nil {
 		return nil, err
 	}
-	if head := s.api.BlockChain().CurrentBlock().Header(); head.BaseFee64() != uint256.NewInt(0) {
-		tipcap.Add(tipcap, head.BaseFee64().ToBig())
+	if baseFee := s.api.BlockChain().CurrentBlock().Header().BaseFee64(); baseFee != nil && !baseFee.IsZero() {
+		tipcap.Add(tipcap, baseFee.ToBig())
 	}
 	return (*hexutil.Big)(tipcap), nil
-	//todo hardcode 13Gwei
-	//tipcap := 13000000000
-	//return (*hexutil.Big)(new(big.Int).SetUint64(uint64(tipcap))), nil
 }
 
 // MaxPriorityFeePerGas returns a suggestion for a gas tip cap for dynamic fee transactions.
@@ -209,24 +290,11 @@ type feeHistoryResult struct {
 	GasUsedRatio []float64        `json:"gasUsedRatio"`
 }
 
-// FeeHistory returns the fee market history.
+// FeeHistory returns the fee market history. The number of blocks returned is
+// capped by the oracle's max header history, or max block history when reward
+// percentiles are requested.
 func (s *astAPI) FeeHistory(ctx context.Context, blockCount jsonrpc.DecimalOrHex, lastBlock jsonrpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
-
-	var (
-		resolvedLastBlock *uint256.Int
-		err               error
-	)
-
-	s.api.db.View(ctx, func(tx kv.Tx) error {
-		resolvedLastBlock, _, err = rpchelper.GetBlockNumber(jsonrpc.BlockNumberOrHashWithNumber(lastBlock), tx)
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	oldest, reward, baseFee, gasUsed, err := s.api.gpo.FeeHistory(ctx, int(blockCount), lastBlock, resolvedLastBlock, rewardPercentiles)
+	oldest, reward, baseFee, gasUsed, err := s.api.gpo.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
 	if err != nil {
 		return nil, err
 	}
@@ -268,12 +336,46 @@ func (s *TxPoolAPI) Content() map[string]map[string]map[string]*RPCTransaction {
 		"pending": make(map[string]map[string]*RPCTransaction),
 		"queued":  make(map[string]map[string]*RPCTransaction),
 	}
+	pending, queue := s.api.TxsPool().Content()
+	curHeader := s.api.BlockChain().CurrentBlock().Header()
+	// Flatten the pending transactions
+	for account, txs := range pending {
+		dump := make(map[string]*RPCTransaction)
+		for _, tx := range txs {
+			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader)
+		}
+		content["pending"][mvm_types.FromastAddress(&account).Hex()] = dump
+	}
+	// Flatten the queued transactions
+	for account, txs := range queue {
+		dump := make(map[string]*RPCTransaction)
+		for _, tx := range txs {
+			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader)
+		}
+		content["queued"][mvm_types.FromastAddress(&account).Hex()] = dump
+	}
 	return content
 }
 
 // ContentFrom returns the transactions contained within the transaction pool.
-func (s *TxPoolAPI) ContentFrom(addr types.Address) map[string]map[string]*RPCTransaction {
+func (s *TxPoolAPI) ContentFrom(addr mvm_common.Address) map[string]map[string]*RPCTransaction {
 	content := make(map[string]map[string]*RPCTransaction, 2)
+	pending, queue := s.api.TxsPool().ContentFrom(*mvm_types.ToastAddress(&addr))
+	curHeader := s.api.BlockChain().CurrentBlock().Header()
+
+	// Build the pending transactions
+	dump := make(map[string]*RPCTransaction, len(pending))
+	for _, tx := range pending {
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader)
+	}
+	content["pending"] = dump
+
+	// Build the queued transactions
+	dump = make(map[string]*RPCTransaction, len(queue))
+	for _, tx := range queue {
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader)
+	}
+	content["queued"] = dump
 
 	return content
 }
@@ -294,7 +396,31 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 		"pending": make(map[string]map[string]string),
 		"queued":  make(map[string]map[string]string),
 	}
+	pending, queue := s.api.TxsPool().Content()
 
+	// Define a formatter to flatten a transaction into a string
+	var format = func(tx *transaction.Transaction) string {
+		if to := tx.To(); to != nil {
+			return fmt.Sprintf("%s: %d wei + %d gas × %d wei", mvm_types.FromastAddress(to).Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+		}
+		return fmt.Sprintf("contract creation: %d wei + %d gas × %d wei", tx.Value(), tx.Gas(), tx.GasPrice())
+	}
+	// Flatten the pending transactions
+	for account, txs := range pending {
+		dump := make(map[string]string)
+		for _, tx := range txs {
+			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+		}
+		content["pending"][mvm_types.FromastAddress(&account).Hex()] = dump
+	}
+	// Flatten the queued transactions
+	for account, txs := range queue {
+		dump := make(map[string]string)
+		for _, tx := range txs {
+			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+		}
+		content["queued"][mvm_types.FromastAddress(&account).Hex()] = dump
+	}
 	return content
 }
 
@@ -329,6 +455,86 @@ func (api *BlockChainAPI) ChainId() *hexutil.Big {
 	return (*hexutil.Big)(api.api.GetChainConfig().ChainID)
 }
 
+// ForkInfo is a fork of the schedule returned by ForkSchedule.
+type ForkInfo struct {
+	Name   string       `json:"name"`
+	Block  *hexutil.Big `json:"block,omitempty"`
+	Time   *hexutil.Big `json:"time,omitempty"`
+	Active bool         `json:"active"`
+}
+
+// ForkSchedule is the fork schedule of the chain at the current block.
+type ForkSchedule struct {
+	ChainID *hexutil.Big   `json:"chainId"`
+	Genesis types.Hash     `json:"genesis"`
+	Head    hexutil.Uint64 `json:"head"`
+	ForkID  struct {
+		Hash hexutil.Bytes  `json:"hash"`
+		Next hexutil.Uint64 `json:"next"`
+	} `json:"forkId"`
+	Forks []ForkInfo `json:"forks"`
+}
+
+// ForkSchedule returns the forks of the chain config, whether they are active
+// at the current block, and the fork ID computed from them, so that the rules
+// a node runs can be compared before a fork.
+func (api *BlockChainAPI) ForkSchedule() *ForkSchedule {
+	config := api.api.GetChainConfig()
+	head := api.api.BlockChain().CurrentBlock()
+	genesis := api.api.BlockChain().GenesisBlock().Hash()
+	num, timestamp := head.Number64().Uint64(), head.Time()
+
+	schedule := &ForkSchedule{
+		ChainID: (*hexutil.Big)(config.ChainID),
+		Genesis: genesis,
+		Head:    hexutil.Uint64(num),
+		Forks:   []ForkInfo{},
+	}
+	id := config.ForkID(genesis, num, timestamp)
+	schedule.ForkID.Hash = id.Hash[:]
+	schedule.ForkID.Next = hexutil.Uint64(id.Next)
+	for _, f := range config.Forks() {
+		schedule.Forks = append(schedule.Forks, ForkInfo{
+			Name:   f.Name,
+			Block:  (*hexutil.Big)(f.Block),
+			Time:   (*hexutil.Big)(f.Time),
+			Active: f.Active(num, timestamp),
+		})
+	}
+	return schedule
+}
+
+// SyncStage is the last block processed by a stage of the sync pipeline.
+type SyncStage struct {
+	Stage string         `json:"stage"`
+	Block hexutil.Uint64 `json:"block"`
+}
+
+// SyncStatus is the progress of the sync returned by eth_syncing. Stage is the
+// stage blocks currently wait for, and EstimatedCompletion the time the node
+// is expected to reach HighestBlock at the rate it imported blocks so far.
+type SyncStatus struct {
+	StartingBlock       hexutil.Uint64  `json:"startingBlock"`
+	CurrentBlock        hexutil.Uint64  `json:"currentBlock"`
+	HighestBlock        hexutil.Uint64  `json:"highestBlock"`
+	Stage               string          `json:"stage"`
+	Stages              []SyncStage     `json:"stages"`
+	EstimatedCompletion *hexutil.Uint64 `json:"estimatedCompletion,omitempty"`
+}
+
+// Syncing returns false once the node caught up with its peers, and the
+// progress of the sync with the stages of the pipeline otherwise.
+func (s *BlockChainAPI) Syncing(ctx context.Context) (interface{}, error) {
+	if s.api.syncStatus == nil {
+		return false, nil
+	}
+	status, err := s.api.syncStatus(ctx)
+	if err != nil || status == nil {
+		return false, err
+	}
+	return status, nil
+}
+
 // GetBalance get balance
 func (s *BlockChainAPI) GetBalance(ctx context.Context, address mvm_common.Address, blockNrOrHash jsonrpc.BlockNumberOrHash) (*hexutil.Big, error) {
 	tx, err := s.api.db.BeginRo(ctx)
@@ -339,7 +545,7 @@ func (s *BlockChainAPI) GetBalance(ctx context.Context, address mvm_common.Addre
 
 	state := s.api.State(tx, blockNrOrHash)
 	if state == nil {
-		return nil, nil
+		return nil, s.api.CheckStateAvailable(tx, blockNrOrHash)
 	}
 	balance := state.GetBalance(*mvm_types.ToastAddress(&address))
 	return (*hexutil.Big)(balance.ToBig()), nil
@@ -361,7 +567,7 @@ func (s *BlockChainAPI) GetCode(ctx context.Context, address mvm_common.Address,
 
 	state := s.api.State(tx, blockNrOrHash)
 	if state == nil {
-		return nil, nil
+		return nil, s.api.CheckStateAvailable(tx, blockNrOrHash)
 	}
 	code := state.GetCode(*mvm_types.ToastAddress(&address))
 	return code, nil
@@ -379,7 +585,7 @@ func (s *BlockChainAPI) GetStorageAt(ctx context.Context, address types.Address,
 
 	state := s.api.State(tx, blockNrOrHash)
 	if state == nil {
-		return nil, nil
+		return nil, s.api.CheckStateAvailable(tx, blockNrOrHash)
 	}
 	var va uint256.Int
 	k := types.HexToHash(key)
@@ -412,22 +618,10 @@ func (s *BlockChainAPI) GetUncleByBlockHashAndIndex(ctx context.Context, blockHa
 	return nil, err
 }
 
-// Result structs for GetProof
-type AccountResult struct {
-	Address      types.Address   `json:"address"`
-	AccountProof []string        `json:"accountProof"`
-	Balance      *hexutil.Big    `json:"balance"`
-	CodeHash     types.Hash      `json:"codeHash"`
-	Nonce        hexutil.Uint64  `json:"nonce"`
-	StorageHash  types.Hash      `json:"storageHash"`
-	StorageProof []StorageResult `json:"storageProof"`
-}
-
-type StorageResult struct {
-	Key   string       `json:"key"`
-	Value *hexutil.Big `json:"value"`
-	Proof []string     `json:"proof"`
-}
+// eth_getProof is not served. The header state root is a hash over the
+// accounts modified by the block, not the root of a Merkle Patricia trie over
+// the whole state, so there is no commitment an account or storage proof could
+// be verified against.
 
 // // OverrideAccount indicates the overriding fields of account during the execution
 // // of a message call.
@@ -528,6 +722,11 @@ func (diff *BlockOverrides) Apply(blockCtx *evmtypes.BlockContext) {
 }
 
 func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, overrides *StateOverride, timeout time.Duration, globalGasCap uint64) (*internal.ExecutionResult, error) {
+	return doCall(ctx, api, args, blockNrOrHash, overrides, timeout, globalGasCap, &vm2.Config{NoBaseFee: true})
+}
+
+// doCall is DoCall with a caller supplied EVM config, used to attach tracers.
+func doCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, overrides *StateOverride, timeout time.Duration, globalGasCap uint64, vmConfig *vm2.Config) (*internal.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	// header := api.BlockChain().CurrentBlock().Header()
@@ -535,6 +734,9 @@ func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash j
 	var header block.IHeader
 	var err error
 	if blockNr, ok := blockNrOrHash.Number(); ok {
+		if blockNr, err = api.ResolveBlockNumber(blockNr); err != nil {
+			return nil, err
+		}
 		if blockNr < jsonrpc.EarliestBlockNumber {
 			header = api.BlockChain().CurrentBlock().Header()
 		} else {
@@ -558,6 +760,9 @@ func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash j
 	//ibs := state.New(reader)
 	ibs := api.State(tx, blockNrOrHash)
 	if ibs == nil {
+		if err := api.CheckStateAvailable(tx, blockNrOrHash); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("cannot load state")
 	}
 	if err := overrides.Apply(ibs.(*state.IntraBlockState)); err != nil {
@@ -581,8 +786,7 @@ func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash j
 		return nil, err
 	}
 
-	//todo debug: , Debug: true, Tracer: vm.NewMarkdownLogger(os.Stdout)
-	evm, vmError, err := api.GetEvm(ctx, msg, ibs, header, &vm2.Config{NoBaseFee: true})
+	evm, vmError, err := api.GetEvm(ctx, msg, ibs, header, vmConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -673,6 +877,10 @@ func BlockByNumber(ctx context.Context, number jsonrpc.BlockNumber, n *API) (blo
 		iblock := n.BlockChain().CurrentBlock()
 		return iblock, nil
 	}
+	number, err := n.ResolveBlockNumber(number)
+	if err != nil {
+		return nil, err
+	}
 	iblock, err := n.BlockChain().GetBlockByNumber(uint256.NewInt(uint64(number)))
 	if err != nil {
 		return nil, err
@@ -802,6 +1010,9 @@ func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrH
 		defer tx.Rollback()
 		statedb := n.State(tx, blockNrOrHash)
 		if statedb == nil {
+			if err := n.CheckStateAvailable(tx, blockNrOrHash); err != nil {
+				return 0, err
+			}
 			return 0, errors.New("cannot load stateDB")
 		}
 		balance := statedb.GetBalance(*mvm_types.ToastAddress(args.From)) // from
@@ -894,6 +1105,87 @@ func (s *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, b
 	return DoEstimateGas(ctx, s.api, args, bNrOrHash, rpcGasCap)
 }
 
+// accessListResult returns an optional accesslist
+// It's the result of the `eth_createAccessList` RPC call.
+// It contains an error if the transaction itself failed.
+type accessListResult struct {
+	Accesslist *mvm_types.AccessList `json:"accessList"`
+	Error      string                `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64        `json:"gasUsed"`
+}
+
+// CreateAccessList creates an EIP-2930 type AccessList for the given transaction.
+// BlockNrOrHash can be specified to create the accessList on top of a certain state,
+// it defaults to the pending block.
+func (s *BlockChainAPI) CreateAccessList(ctx context.Context, args TransactionArgs, blockNrOrHash *jsonrpc.BlockNumberOrHash) (*accessListResult, error) {
+	bNrOrHash := jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	acl, gasUsed, vmerr, err := AccessList(ctx, s.api, bNrOrHash, args)
+	if err != nil {
+		return nil, err
+	}
+	result := &accessListResult{Accesslist: &acl, GasUsed: hexutil.Uint64(gasUsed)}
+	if vmerr != nil {
+		result.Error = vmerr.Error()
+	}
+	return result, nil
+}
+
+// AccessList creates an access list for the given transaction.
+// If the accesslist creation fails an error is returned.
+// If the transaction itself fails, an vmErr is returned.
+func AccessList(ctx context.Context, n *API, blockNrOrHash jsonrpc.BlockNumberOrHash, args TransactionArgs) (acl mvm_types.AccessList, gasUsed uint64, vmErr error, err error) {
+	iblock, err := BlockByNumberOrHash(ctx, blockNrOrHash, n)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if iblock == nil {
+		return nil, 0, nil, errors.New("block not found")
+	}
+	// If the gas amount is not set, default to RPC gas cap.
+	if args.Gas == nil {
+		tmp := hexutil.Uint64(rpcGasCap)
+		args.Gas = &tmp
+	}
+	// Ensure any missing fields are filled, extract the recipient and input data
+	if err := args.setDefaults(ctx, n); err != nil {
+		return nil, 0, nil, err
+	}
+	from := *mvm_types.ToastAddress(args.From)
+	var to types.Address
+	if args.To != nil {
+		to = *mvm_types.ToastAddress(args.To)
+	} else {
+		to = crypto.CreateAddress(from, uint64(*args.Nonce))
+	}
+	// Retrieve the precompiles since they don't need to be added to the access list
+	precompiles := vm2.ActivePrecompiles(n.GetChainConfig().Rules(iblock.Number64().Uint64()))
+
+	// Create an initial tracer
+	prevTracer := logger.NewAccessListTracer(nil, from, to, precompiles)
+	if args.AccessList != nil {
+		prevTracer = logger.NewAccessListTracer(mvm_types.ToastAccessList(*args.AccessList), from, to, precompiles)
+	}
+	for {
+		// Retrieve the current access list to expand
+		accessList := mvm_types.FromastAccessList(prevTracer.AccessList())
+		args.AccessList = &accessList
+
+		// Apply the transaction with the access list tracer
+		tracer := logger.NewAccessListTracer(prevTracer.AccessList(), from, to, precompiles)
+		res, err := doCall(ctx, n, args, blockNrOrHash, nil, rpcEVMTimeout, rpcGasCap, &vm2.Config{Tracer: tracer, Debug: true, NoBaseFee: true})
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("failed to apply transaction: %v err: %v", args.toTransaction().Hash(), err)
+		}
+		if tracer.Equal(prevTracer) {
+			return accessList, res.UsedGas, res.Err, nil
+		}
+		prevTracer = tracer
+	}
+}
+
 // GetBlockByNumber returns the requested canonical block.
 //   - When blockNr is -1 the chain head is returned.
 //   - When blockNr is -2 the pending chain head is returned.
@@ -909,8 +1201,10 @@ func (s *BlockChainAPI) GetBlockByNumber(ctx context.Context, number jsonrpc.Blo
 	if number == jsonrpc.LatestBlockNumber {
 		block = s.api.BlockChain().CurrentBlock()
 		err = nil
+	} else if resolved, rerr := s.api.ResolveBlockNumber(number); rerr != nil {
+		return nil, rerr
 	} else {
-		block, err = s.api.BlockChain().GetBlockByNumber(uint256.NewInt(uint64(number.Int64())))
+		block, err = s.api.BlockChain().GetBlockByNumber(uint256.NewInt(uint64(resolved.Int64())))
 	}
 
 	if block != nil && err == nil {
@@ -1019,7 +1313,7 @@ func (s *TransactionAPI) GetTransactionCount(ctx context.Context, address mvm_co
 
 	state := s.api.State(tx, blockNrOrHash)
 	if state == nil {
-		return nil, nil
+		return nil, s.api.CheckStateAvailable(tx, blockNrOrHash)
 	}
 	nonce := state.GetNonce(*mvm_types.ToastAddress(&address))
 	return (*hexutil.Uint64)(&nonce), nil
@@ -1102,13 +1396,49 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash mvm_com
 	if len(receipts) <= int(index) {
 		return nil, nil
 	}
-	receipt := receipts[index]
+	header, err := s.api.BlockChain().GetHeaderByHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalReceipt(receipts[index], blockHash, blockNumber, header.BaseFee64(), tx, index), nil
+}
+
+// GetBlockReceipts returns the receipts of all transactions in the block
+// identified by number or hash, read in a single pass from the database.
+func (s *BlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	iblock, err := BlockByNumberOrHash(ctx, blockNrOrHash, s.api)
+	if iblock == nil || err != nil {
+		// When the block doesn't exist, the RPC method should return JSON null
+		// as per specification.
+		return nil, nil
+	}
+	receipts, err := s.api.BlockChain().GetReceipts(iblock.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := iblock.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	var (
+		blockHash   = iblock.Hash()
+		blockNumber = iblock.Number64().Uint64()
+		baseFee     = iblock.BaseFee64()
+		result      = make([]map[string]interface{}, len(receipts))
+	)
+	for i, receipt := range receipts {
+		result[i] = MarshalReceipt(receipt, blockHash, blockNumber, baseFee, txs[i], uint64(i))
+	}
+	return result, nil
+}
 
+// MarshalReceipt converts a receipt into the RPC representation.
+func MarshalReceipt(receipt *block.Receipt, blockHash types.Hash, blockNumber uint64, baseFee *uint256.Int, tx *transaction.Transaction, index uint64) map[string]interface{} {
 	from := tx.From()
 	fields := map[string]interface{}{
 		"blockHash":         mvm_types.FromastHash(blockHash),
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"transactionHash":   mvm_types.FromastHash(tx.Hash()),
 		"transactionIndex":  hexutil.Uint64(index),
 		"from":              mvm_types.FromastAddress(from),
 		"to":                mvm_types.FromastAddress(tx.To()),
@@ -1119,17 +1449,8 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash mvm_com
 		"type":              hexutil.Uint(tx.Type()),
 	}
 	// Assign the effective gas price paid
-	//todo !IsLondon
-	if false {
-		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
-	} else {
-		header, err := s.api.BlockChain().GetHeaderByHash(blockHash)
-		if err != nil {
-			return nil, err
-		}
-		gasPrice := new(big.Int).Add(header.BaseFee64().ToBig(), tx.EffectiveGasTipValue(header.BaseFee64()).ToBig())
-		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
-	}
+	gasPrice := new(big.Int).Add(baseFee.ToBig(), tx.EffectiveGasTipValue(baseFee).ToBig())
+	fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	// Assign receipt status or post state.
 	if len(receipt.PostState) > 0 {
 		fields["root"] = hexutil.Bytes(receipt.PostState)
@@ -1145,10 +1466,7 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash mvm_com
 	if !receipt.ContractAddress.IsNull() {
 		fields["contractAddress"] = mvm_types.FromastAddress(&receipt.ContractAddress)
 	}
-
-	//json, _ := json.Marshal(fields)
-	//log.Infof("GetTransactionReceipt, result %s", string(json))
-	return fields, nil
+	return fields
 }
 
 // GetBlockTransactionCountByHash returns the number of transactions in the block with the given hash.
@@ -1300,9 +1618,90 @@ func NewDebugAPI(api *API) *DebugAPI {
 	return &DebugAPI{api: api}
 }
 
+// BadBlockArgs represents the entries in the list returned when bad blocks are
+// queried.
+type BadBlockArgs struct {
+	Hash  types.Hash             `json:"hash"`
+	Block map[string]interface{} `json:"block"`
+	// RLP holds the block in the node's wire encoding, under the field name
+	// tooling expects.
+	RLP   hexutil.Bytes  `json:"rlp"`
+	Stage string         `json:"stage"`
+	Error string         `json:"error"`
+	Head  hexutil.Uint64 `json:"head"`
+	Time  hexutil.Uint64 `json:"time"`
+}
+
+// GetBadBlocks returns a list of the last 'bad blocks' that the client has seen
+// on the network and returns them as a JSON list of block hashes.
+func (api *DebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs, error) {
+	var (
+		bad     = api.api.BlockChain().BadBlocks()
+		results = make([]*BadBlockArgs, 0, len(bad))
+	)
+	for _, b := range bad {
+		result := &BadBlockArgs{
+			Hash:  b.Hash,
+			RLP:   b.Encoded,
+			Stage: b.Stage,
+			Error: b.Error,
+			Head:  hexutil.Uint64(b.Head),
+			Time:  hexutil.Uint64(b.Time),
+		}
+		blk := new(block.Block)
+		if err := blk.Unmarshal(b.Encoded); err == nil {
+			if fields, err := RPCMarshalBlock(blk, api.api.BlockChain(), true, true); err == nil {
+				result.Block = fields
+			} else {
+				result.Block = map[string]interface{}{"error": err.Error()}
+			}
+		} else {
+			result.Block = map[string]interface{}{"error": err.Error()}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// PrivateDebugAPI is the collection of debug methods which change the node's
+// data. It is only served over the authenticated and IPC endpoints.
+type PrivateDebugAPI struct {
+	api *API
+}
+
+// NewPrivateDebugAPI creates a new instance of PrivateDebugAPI.
+func NewPrivateDebugAPI(api *API) *PrivateDebugAPI {
+	return &PrivateDebugAPI{api: api}
+}
+
 // SetHead rewinds the head of the blockchain to a previous block.
-func (api *DebugAPI) SetHead(number hexutil.Uint64) {
-	api.api.BlockChain().SetHead(uint64(number))
+func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) error {
+	return api.api.BlockChain().SetHead(uint64(number))
+}
+
+// DbTables returns the names of the tables in the chain database.
+func (api *PrivateDebugAPI) DbTables() []string {
+	return modules.AstTables()
+}
+
+// DbGet returns the raw value of key in the given table of the chain
+// database, or nil if the key is not present.
+func (api *PrivateDebugAPI) DbGet(ctx context.Context, table string, key hexutil.Bytes) (hexutil.Bytes, error) {
+	if _, ok := modules.AstTableCfg[table]; !ok {
+		return nil, fmt.Errorf("unknown table %q", table)
+	}
+	var value hexutil.Bytes
+	err := api.api.Database().View(ctx, func(tx kv.Tx) error {
+		v, err := tx.GetOne(table, key)
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			value = types.CopyBytes(v)
+		}
+		return nil
+	})
+	return value, err
 }
 
 func (debug *DebugAPI) GetAccount(ctx context.Context, address types.Address) {
@@ -1322,58 +1721,25 @@ func NewNetAPI(api *API, networkVersion uint64) *NetAPI {
 
 // Listening returns an indication if the node is listening for network connections.
 func (s *NetAPI) Listening() bool {
-	return true // always listening
+	if s.api.p2p == nil || s.api.p2p.Host() == nil {
+		return false
+	}
+	return len(s.api.p2p.Host().Network().ListenAddresses()) > 0
 }
 
 // PeerCount returns the number of connected peers
 func (s *NetAPI) PeerCount() hexutil.Uint {
-	//return hexutil.Uint(s.api.P2pServer().PeerCount())
-	return 0
+	if s.api.p2p == nil {
+		return 0
+	}
+	return hexutil.Uint(len(s.api.p2p.Peers().Connected()))
 }
 
-// Version returns the current ethereum protocol version.
+// Version returns the network identifier, which is the chain ID.
 func (s *NetAPI) Version() string {
-	//todo networkID == chainID？ s.api.GetChainConfig().ChainID
 	return fmt.Sprintf("%d", s.networkVersion)
 }
 
-// TxsPoolAPI offers and API for the transaction pool. It only operates on data that is non confidential.
-type TxsPoolAPI struct {
-	api *API
-}
-
-// NewTxsPoolAPI creates a new tx pool service that gives information about the transaction pool.
-func NewTxsPoolAPI(api *API) *TxsPoolAPI {
-	return &TxsPoolAPI{api}
-}
-
-// Content returns the transactions contained within the transaction pool.
-func (s *TxsPoolAPI) Content() map[string]map[string]map[string]*RPCTransaction {
-	content := map[string]map[string]map[string]*RPCTransaction{
-		"pending": make(map[string]map[string]*RPCTransaction),
-		"queued":  make(map[string]map[string]*RPCTransaction),
-	}
-	pending, queue := s.api.TxsPool().Content()
-	curHeader := s.api.BlockChain().CurrentBlock().Header()
-	// Flatten the pending transactions
-	for account, txs := range pending {
-		dump := make(map[string]*RPCTransaction)
-		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader)
-		}
-		content["pending"][mvm_types.FromastAddress(&account).Hex()] = dump
-	}
-	// Flatten the queued transactions
-	for account, txs := range queue {
-		dump := make(map[string]*RPCTransaction)
-		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader)
-		}
-		content["queued"][mvm_types.FromastAddress(&account).Hex()] = dump
-	}
-	return content
-}
-
 func (api *TransactionAPI) TestBatchTxs(ctx context.Context) {
 	go batchTxs(api.api, 0, 1000000)
 }
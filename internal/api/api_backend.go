@@ -68,29 +68,36 @@ func (b *API) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*type
 	if number == rpc.LatestBlockNumber {
 		return b.bc.CurrentBlock().Header().(*types.Header), nil
 	}
-	//if number == rpc.FinalizedBlockNumber {
-	//	if !b.eth.Merger().TDDReached() {
-	//		return nil, errors.New("'finalized' tag not supported on pre-merge network")
-	//	}
-	//	block := b.eth.blockchain.CurrentFinalBlock()
-	//	if block != nil {
-	//		return block, nil
-	//	}
-	//	return nil, errors.New("finalized block not found")
-	//}
-	//if number == rpc.SafeBlockNumber {
-	//	if !b.eth.Merger().TDDReached() {
-	//		return nil, errors.New("'safe' tag not supported on pre-merge network")
-	//	}
-	//	block := b.eth.blockchain.CurrentSafeBlock()
-	//	if block != nil {
-	//		return block, nil
-	//	}
-	//	return nil, errors.New("safe block not found")
-	//}
+	if number == rpc.FinalizedBlockNumber {
+		header := b.bc.CurrentFinalBlock()
+		if header != nil {
+			return header.(*types.Header), nil
+		}
+		return nil, errors.New("finalized block not found")
+	}
+	if number == rpc.SafeBlockNumber {
+		header := b.bc.CurrentSafeBlock()
+		if header != nil {
+			return header.(*types.Header), nil
+		}
+		return nil, errors.New("safe block not found")
+	}
 	return b.bc.GetHeaderByNumber(uint256.NewInt(uint64(number.Int64()))).(*types.Header), nil
 }
 
+// ResolveBlockNumber resolves the "finalized" and "safe" block tags to the
+// number of the block they name, other numbers are returned as they are.
+func (b *API) ResolveBlockNumber(number rpc.BlockNumber) (rpc.BlockNumber, error) {
+	if number != rpc.FinalizedBlockNumber && number != rpc.SafeBlockNumber {
+		return number, nil
+	}
+	header, err := b.HeaderByNumber(context.Background(), number)
+	if err != nil {
+		return 0, err
+	}
+	return rpc.BlockNumber(header.Number64().Uint64()), nil
+}
+
 func (b *API) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
 	if blockNr, ok := blockNrOrHash.Number(); ok {
 		return b.HeaderByNumber(ctx, blockNr)
@@ -124,20 +131,13 @@ func (b *API) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types
 		header := b.bc.CurrentBlock()
 		return b.bc.GetBlock(header.Hash(), header.Number64().Uint64()).(*types.Block), nil
 	}
-	//if number == rpc.FinalizedBlockNumber {
-	//	if !b.eth.Merger().TDDReached() {
-	//		return nil, errors.New("'finalized' tag not supported on pre-merge network")
-	//	}
-	//	header := b.eth.blockchain.CurrentFinalBlock()
-	//	return b.eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
-	//}
-	//if number == rpc.SafeBlockNumber {
-	//	//if !b.eth.Merger().TDDReached() {
-	//	//	return nil, errors.New("'safe' tag not supported on pre-merge network")
-	//	//}
-	//	header := b.BlockChain().CurrentBlock()
-	//	return b.eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
-	//}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		header, err := b.HeaderByNumber(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		return b.bc.GetBlock(header.Hash(), header.Number64().Uint64()).(*types.Block), nil
+	}
 	iBlock, err := b.bc.GetBlockByNumber(uint256.NewInt(uint64(number)))
 	if nil != err {
 		return nil, err
@@ -411,8 +411,8 @@ func (eth *API) StateAtTransaction(ctx context.Context, dbTx kv.Tx, blk *types.B
 		return nil, evmtypes.BlockContext{}, nil, errors.New("no transaction in genesis")
 	}
 	// Create the parent state database
-	parent := eth.BlockChain().GetBlock(blk.ParentHash(), blk.Number64().Uint64()-1).(*types.Block)
-	if parent == nil {
+	parent, ok := eth.BlockChain().GetBlock(blk.ParentHash(), blk.Number64().Uint64()-1).(*types.Block)
+	if !ok || parent == nil {
 		return nil, evmtypes.BlockContext{}, nil, fmt.Errorf("parent %#x not found", blk.ParentHash())
 	}
 	// Lookup the statedb of parent block from the live database,
@@ -517,6 +517,9 @@ func (eth *API) StateAtBlock(ctx context.Context, tx kv.Tx, blk *types.Block) (s
 	// The state is available in live database, create a reference
 	// on top to prevent garbage collection and return a release
 	// function to deref it.
+	if err := rawdb.CheckPruned(tx, rawdb.PruneHistory, origin+1); err != nil {
+		return nil, err
+	}
 	statedb = eth.BlockChain().StateAt(tx, origin)
 	//statedb.Database().TrieDB().Reference(block.Root(), common.Hash{})
 	return statedb, nil
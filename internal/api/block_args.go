@@ -92,14 +92,14 @@ func newRPCTransactionFromBlockHash(b block.IBlock, findHash types.Hash) *RPCTra
 	for idx, tx := range b.Transactions() {
 		hash := tx.Hash()
 		if hash == findHash {
-			return newRPCTransactionFromBlockIndex(b, uint64(idx))
+			return NewRPCTransactionFromBlockIndex(b, uint64(idx))
 		}
 	}
 	return nil
 }
 
-// newRPCTransactionFromBlockIndex returns a transaction that will serialize to the RPC representation.
-func newRPCTransactionFromBlockIndex(b block.IBlock, index uint64) *RPCTransaction {
+// NewRPCTransactionFromBlockIndex returns a transaction that will serialize to the RPC representation.
+func NewRPCTransactionFromBlockIndex(b block.IBlock, index uint64) *RPCTransaction {
 	txs := b.Transactions()
 	if index >= uint64(len(txs)) {
 		return nil
@@ -0,0 +1,304 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/crypto"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal"
+	mvm_common "github.com/n42blockchain/N42/internal/avm/common"
+	mvm_types "github.com/n42blockchain/N42/internal/avm/types"
+	"github.com/n42blockchain/N42/internal/txspool"
+	vm2 "github.com/n42blockchain/N42/internal/vm"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+	"github.com/n42blockchain/N42/modules/state"
+	"github.com/n42blockchain/N42/params"
+)
+
+var (
+	errBundleNoTxs         = errors.New("bundle missing txs")
+	errBundleNoBlockNumber = errors.New("bundle missing blockNumber")
+	errBundlesDisabled     = errors.New("bundles are not accepted by this node")
+	errBundleStale         = errors.New("bundle targets a block that is already mined")
+)
+
+// BundleAPI lets searchers simulate ordered bundles of signed transactions
+// and submit them to the block builder. It is only served on the
+// authenticated endpoint.
+type BundleAPI struct {
+	api *API
+}
+
+// NewBundleAPI creates a new bundle service.
+func NewBundleAPI(api *API) *BundleAPI {
+	return &BundleAPI{api: api}
+}
+
+// CallBundleArgs are the arguments of eth_callBundle. The bundle is run in a
+// block numbered BlockNumber on top of the state of StateBlockNumberOrHash;
+// the optional fields override the header of that block.
+type CallBundleArgs struct {
+	Txs                    []hexutil.Bytes           `json:"txs"`
+	BlockNumber            jsonrpc.BlockNumber       `json:"blockNumber"`
+	StateBlockNumberOrHash jsonrpc.BlockNumberOrHash `json:"stateBlockNumber"`
+	Coinbase               *mvm_common.Address       `json:"coinbase"`
+	Timestamp              *uint64                   `json:"timestamp"`
+	Timeout                *int64                    `json:"timeout"`
+	GasLimit               *uint64                   `json:"gasLimit"`
+	BaseFee                *hexutil.Big              `json:"baseFee"`
+}
+
+// CallBundle executes the transactions of a bundle in order and reports the
+// result of each along with what the bundle paid to the coinbase. Failing
+// transactions are reported but do not stop the bundle.
+func (s *BundleAPI) CallBundle(ctx context.Context, args CallBundleArgs) (map[string]interface{}, error) {
+	if len(args.Txs) == 0 {
+		return nil, errBundleNoTxs
+	}
+	if args.BlockNumber <= 0 {
+		return nil, errBundleNoBlockNumber
+	}
+	chainConfig := s.api.GetChainConfig()
+	txs, err := decodeBundleTxs(args.Txs, chainConfig, uint64(args.BlockNumber))
+	if err != nil {
+		return nil, err
+	}
+	timeout := rpcEVMTimeout
+	if args.Timeout != nil && *args.Timeout > 0 {
+		timeout = time.Duration(*args.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	parentBlock, err := BlockByNumberOrHash(ctx, args.StateBlockNumberOrHash, s.api)
+	if err != nil {
+		return nil, err
+	}
+	if parentBlock == nil {
+		return nil, errors.New("header not found")
+	}
+	parent, ok := parentBlock.Header().(*block.Header)
+	if !ok {
+		return nil, fmt.Errorf("unexpected header type %T", parentBlock.Header())
+	}
+
+	tx, err := s.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ibs, ok := s.api.State(tx, args.StateBlockNumberOrHash).(*state.IntraBlockState)
+	if !ok {
+		return nil, errors.New("cannot load state")
+	}
+
+	header := nextHeader(chainConfig, parent)
+	header.Number = uint256.NewInt(uint64(args.BlockNumber))
+	if args.Timestamp != nil {
+		header.Time = *args.Timestamp
+	}
+	if args.GasLimit != nil {
+		header.GasLimit = *args.GasLimit
+	}
+	if args.Coinbase != nil {
+		header.Coinbase = *mvm_types.ToastAddress(args.Coinbase)
+	}
+	if args.BaseFee != nil {
+		header.BaseFee, _ = uint256.FromBig(args.BaseFee.ToInt())
+	}
+
+	var (
+		signer   = transaction.MakeSigner(chainConfig, header.Number.ToBig())
+		rules    = chainConfig.Rules(header.Number.Uint64())
+		blockCtx = internal.NewEVMBlockContext(header, internal.GetHashFn(header, s.getHeader), s.api.Engine(), &header.Coinbase)
+		gp       = new(common.GasPool).AddGas(header.GasLimit)
+
+		coinbaseBefore = new(uint256.Int).Set(ibs.GetBalance(header.Coinbase))
+		bundleHash     = make([]byte, 0, len(txs)*32)
+		totalGasUsed   uint64
+		totalGasFees   = new(big.Int)
+		results        = make([]map[string]interface{}, 0, len(txs))
+	)
+	for i, t := range txs {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("bundle execution aborted (timeout = %v)", timeout)
+		}
+		msg, err := t.AsMessage(signer, header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x: %w", t.Hash(), err)
+		}
+		ibs.Prepare(t.Hash(), header.Hash(), i)
+		before := new(uint256.Int).Set(ibs.GetBalance(header.Coinbase))
+
+		evm := vm2.NewEVM(blockCtx, internal.NewEVMTxContext(msg), ibs, chainConfig, vm2.Config{})
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+		result, err := internal.ApplyMessage(evm, msg, gp, true, false)
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("bundle execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x: %w", t.Hash(), err)
+		}
+		if err := ibs.FinalizeTx(rules, state.NewNoopWriter()); err != nil {
+			return nil, err
+		}
+
+		tip, err := t.EffectiveGasTip(header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x: %w", t.Hash(), err)
+		}
+		var (
+			gasPrice     = tip.ToBig()
+			gasFees      = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(result.UsedGas))
+			coinbaseDiff = new(big.Int).Sub(ibs.GetBalance(header.Coinbase).ToBig(), before.ToBig())
+			hash         = t.Hash()
+		)
+		totalGasUsed += result.UsedGas
+		totalGasFees.Add(totalGasFees, gasFees)
+		bundleHash = append(bundleHash, hash.Bytes()...)
+
+		txResult := map[string]interface{}{
+			"txHash":            mvm_types.FromastHash(hash),
+			"gasUsed":           result.UsedGas,
+			"fromAddress":       mvm_types.FromastAddress(t.From()),
+			"toAddress":         mvm_types.FromastAddress(t.To()),
+			"gasPrice":          gasPrice.String(),
+			"gasFees":           gasFees.String(),
+			"coinbaseDiff":      coinbaseDiff.String(),
+			"ethSentToCoinbase": new(big.Int).Sub(coinbaseDiff, gasFees).String(),
+		}
+		if result.Err != nil {
+			txResult["error"] = result.Err.Error()
+			if revert := result.Revert(); len(revert) > 0 {
+				txResult["revert"] = hexutil.Encode(revert)
+			}
+		} else {
+			txResult["value"] = hexutil.Bytes(result.Return())
+		}
+		results = append(results, txResult)
+	}
+
+	coinbaseDiff := new(big.Int).Sub(ibs.GetBalance(header.Coinbase).ToBig(), coinbaseBefore.ToBig())
+	bundleGasPrice := new(big.Int)
+	if totalGasUsed > 0 {
+		bundleGasPrice.Div(coinbaseDiff, new(big.Int).SetUint64(totalGasUsed))
+	}
+	return map[string]interface{}{
+		"results":           results,
+		"coinbaseDiff":      coinbaseDiff.String(),
+		"gasFees":           totalGasFees.String(),
+		"ethSentToCoinbase": new(big.Int).Sub(coinbaseDiff, totalGasFees).String(),
+		"bundleGasPrice":    bundleGasPrice.String(),
+		"bundleHash":        mvm_types.FromastHash(crypto.Keccak256Hash(bundleHash)),
+		"stateBlockNumber":  parent.Number.Uint64(),
+		"totalGasUsed":      totalGasUsed,
+	}, nil
+}
+
+// SendBundleArgs are the arguments of eth_sendBundle.
+type SendBundleArgs struct {
+	Txs               []hexutil.Bytes   `json:"txs"`
+	BlockNumber       hexutil.Uint64    `json:"blockNumber"`
+	MinTimestamp      *uint64           `json:"minTimestamp"`
+	MaxTimestamp      *uint64           `json:"maxTimestamp"`
+	RevertingTxHashes []mvm_common.Hash `json:"revertingTxHashes"`
+}
+
+// SendBundle queues a bundle for inclusion at the top of block BlockNumber.
+// The bundle is dropped if any of its transactions fails, unless the
+// transaction is listed in RevertingTxHashes.
+func (s *BundleAPI) SendBundle(ctx context.Context, args SendBundleArgs) (map[string]interface{}, error) {
+	if s.api.bundles == nil {
+		return nil, errBundlesDisabled
+	}
+	if len(args.Txs) == 0 {
+		return nil, errBundleNoTxs
+	}
+	if args.BlockNumber == 0 {
+		return nil, errBundleNoBlockNumber
+	}
+	head := s.api.BlockChain().CurrentBlock().Number64().Uint64()
+	if uint64(args.BlockNumber) <= head {
+		return nil, errBundleStale
+	}
+	txs, err := decodeBundleTxs(args.Txs, s.api.GetChainConfig(), uint64(args.BlockNumber))
+	if err != nil {
+		return nil, err
+	}
+	bundle := &txspool.Bundle{
+		Txs:         txs,
+		BlockNumber: uint64(args.BlockNumber),
+	}
+	if args.MinTimestamp != nil {
+		bundle.MinTimestamp = *args.MinTimestamp
+	}
+	if args.MaxTimestamp != nil {
+		bundle.MaxTimestamp = *args.MaxTimestamp
+	}
+	for _, hash := range args.RevertingTxHashes {
+		bundle.RevertingTxHashes = append(bundle.RevertingTxHashes, mvm_types.ToastHash(hash))
+	}
+	if err := s.api.bundles.Add(bundle, head); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"bundleHash": mvm_types.FromastHash(bundle.Hash()),
+	}, nil
+}
+
+// getHeader looks up canonical headers for BLOCKHASH.
+func (s *BundleAPI) getHeader(hash types.Hash, number uint64) *block.Header {
+	h, err := s.api.BlockChain().GetHeaderByHash(hash)
+	if err != nil || h == nil {
+		return nil
+	}
+	header, _ := h.(*block.Header)
+	return header
+}
+
+// decodeBundleTxs decodes the signed transactions of a bundle targeting the
+// given block.
+func decodeBundleTxs(raw []hexutil.Bytes, config *params.ChainConfig, number uint64) ([]*transaction.Transaction, error) {
+	txs := make([]*transaction.Transaction, 0, len(raw))
+	for i, input := range raw {
+		tx := new(mvm_types.Transaction)
+		if err := tx.UnmarshalBinary(input); err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		metaTx, err := tx.ToastTransaction(config, new(big.Int).SetUint64(number))
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		txs = append(txs, metaTx)
+	}
+	return txs, nil
+}
@@ -89,20 +89,22 @@ func (s sortGasAndReward) Less(i, j int) bool {
 // the block field filled in, retrieves the block from the backend if not present yet and
 // fills in the rest of the fields.
 func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
-	if bf.results.baseFee = bf.header.BaseFee64().ToBig(); bf.results.baseFee == nil {
+	header, ok := bf.header.(*block.Header)
+	if !ok {
+		bf.err = fmt.Errorf("unexpected header type %T for block %d", bf.header, bf.blockNumber)
+		return
+	}
+	if bf.results.baseFee = header.BaseFee64().ToBig(); bf.results.baseFee == nil {
 		bf.results.baseFee = new(big.Int)
 	}
-
-	// todo baseFee = 0
-	bf.results.nextBaseFee = new(big.Int)
-
 	if oracle.chainConfig.IsLondon(bf.blockNumber + 1) {
-		bf.results.nextBaseFee = misc.CalcBaseFee(oracle.chainConfig, bf.header.(*block.Header))
+		bf.results.nextBaseFee = misc.CalcBaseFee(oracle.chainConfig, header)
 	} else {
 		bf.results.nextBaseFee = new(big.Int)
 	}
-
-	bf.results.gasUsedRatio = float64(bf.block.GasUsed()) / float64(bf.block.GasLimit())
+	if header.GasLimit > 0 {
+		bf.results.gasUsedRatio = float64(header.GasUsed) / float64(header.GasLimit)
+	}
 	if len(percentiles) == 0 {
 		// rewards were not requested, return null
 		return
@@ -121,6 +123,10 @@ func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
 		return
 	}
 
+	if len(bf.receipts) != len(bf.block.Transactions()) {
+		bf.err = fmt.Errorf("block %d has %d transactions but %d receipts", bf.blockNumber, len(bf.block.Transactions()), len(bf.receipts))
+		return
+	}
 	sorter := make(sortGasAndReward, len(bf.block.Transactions()))
 	for i, tx := range bf.block.Transactions() {
 		reward, _ := tx.EffectiveGasTip(bf.header.BaseFee64())
@@ -132,7 +138,7 @@ func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
 	sumGasUsed := sorter[0].gasUsed
 
 	for i, p := range percentiles {
-		thresholdGasUsed := uint64(float64(bf.block.GasUsed()) * p / 100)
+		thresholdGasUsed := uint64(float64(header.GasUsed) * p / 100)
 		for sumGasUsed < thresholdGasUsed && txIndex < len(bf.block.Transactions())-1 {
 			txIndex++
 			sumGasUsed += sorter[txIndex].gasUsed
@@ -184,8 +190,14 @@ func (oracle *Oracle) resolveBlockRange(ctx context.Context, reqEnd jsonrpc.Bloc
 		case jsonrpc.LatestBlockNumber:
 			// Retrieved above.
 			resolved = headBlock
-		//case jsonrpc.SafeBlockNumber:
-		//case jsonrpc.FinalizedBlockNumber:
+		case jsonrpc.SafeBlockNumber:
+			if resolved = oracle.backend.CurrentSafeBlock(); resolved == nil {
+				err = errors.New("safe block not found")
+			}
+		case jsonrpc.FinalizedBlockNumber:
+			if resolved = oracle.backend.CurrentFinalBlock(); resolved == nil {
+				err = errors.New("finalized block not found")
+			}
 		case jsonrpc.EarliestBlockNumber:
 			resolved = oracle.backend.GetHeaderByNumber(uint256.NewInt(0))
 		}
@@ -221,7 +233,7 @@ func (oracle *Oracle) resolveBlockRange(ctx context.Context, reqEnd jsonrpc.Bloc
 //
 // Note: baseFee includes the next block after the newest of the returned range, because this
 // value can be derived from the newest block.
-func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLastBlock jsonrpc.BlockNumber, resolvedLastBlock *uint256.Int, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLastBlock jsonrpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
 	if blocks < 1 {
 		return common.Big0, nil, nil, nil, nil // returning with no data and no error means there are no retrievable blocks
 	}
@@ -282,15 +294,15 @@ func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLast
 						fees.results = p.(processedFees)
 						results <- fees
 					} else {
+						number := uint256.NewInt(blockNumber)
 						if len(rewardPercentiles) != 0 {
-							fees.block, fees.err = oracle.backend.GetBlockByNumber(resolvedLastBlock)
+							fees.block, fees.err = oracle.backend.GetBlockByNumber(number)
 							if fees.block != nil && fees.err == nil {
 								fees.receipts, fees.err = oracle.backend.GetReceipts(fees.block.Hash())
 								fees.header = fees.block.Header()
 							}
 						} else {
-							fees.err = nil
-							fees.header = oracle.backend.GetHeaderByNumber(resolvedLastBlock)
+							fees.header = oracle.backend.GetHeaderByNumber(number)
 						}
 						if fees.header != nil && fees.err == nil {
 							oracle.processBlock(fees, rewardPercentiles)
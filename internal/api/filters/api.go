@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hexutil"
 	"github.com/n42blockchain/N42/common/types"
 	mvm_types "github.com/n42blockchain/N42/internal/avm/types"
 	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
@@ -246,6 +247,60 @@ func (filterApi *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*jso
 	return rpcSub, nil
 }
 
+// SyncingResult is the payload of a syncing notification sent while the node
+// is catching up with the network.
+type SyncingResult struct {
+	Syncing bool          `json:"syncing"`
+	Status  SyncingStatus `json:"status"`
+}
+
+// SyncingStatus describes the progress of the running sync. As in eth_syncing,
+// StartingBlock is the head of the chain when the sync started, the last block
+// the node had rather than the first one it imports. The notification is sent
+// as the sync starts, so it equals CurrentBlock.
+type SyncingStatus struct {
+	StartingBlock hexutil.Uint64 `json:"startingBlock"`
+	CurrentBlock  hexutil.Uint64 `json:"currentBlock"`
+}
+
+// Syncing creates a subscription that notifies a SyncingResult when the node
+// starts syncing and false once the sync has finished.
+func (filterApi *FilterAPI) Syncing(ctx context.Context) (*jsonrpc.Subscription, error) {
+	notifier, supported := jsonrpc.NotifierFromContext(ctx)
+	if !supported {
+		return &jsonrpc.Subscription{}, jsonrpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		syncing := make(chan bool)
+		syncingSub := filterApi.events.SubscribeSyncing(syncing)
+		for {
+			select {
+			case active := <-syncing:
+				if !active {
+					notifier.Notify(rpcSub.ID, false)
+					continue
+				}
+				current := hexutil.Uint64(filterApi.api.BlockChain().CurrentBlock().Number64().Uint64())
+				notifier.Notify(rpcSub.ID, &SyncingResult{
+					Syncing: true,
+					Status:  SyncingStatus{StartingBlock: current, CurrentBlock: current},
+				})
+			case <-rpcSub.Err():
+				syncingSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				syncingSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewFilter creates a new filter and returns the filter id. It can be
 // used to retrieve logs when the state changes. This method cannot be
 // used to fetch logs that are already stored in the state.
@@ -3,6 +3,8 @@ package filters
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/RoaringBitmap/roaring"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/n42blockchain/N42/common"
@@ -12,6 +14,7 @@ import (
 	"github.com/n42blockchain/N42/internal/consensus"
 	vm2 "github.com/n42blockchain/N42/internal/vm"
 	"github.com/n42blockchain/N42/internal/vm/evmtypes"
+	"github.com/n42blockchain/N42/modules/rawdb"
 	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
 	"math/big"
 )
@@ -22,6 +25,14 @@ type Api interface {
 	Engine() consensus.Engine
 	BlockChain() common.IBlockChain
 	GetEvm(ctx context.Context, msg internal.Message, ibs evmtypes.IntraBlockState, header block.IHeader, vmConfig *vm2.Config) (*vm2.EVM, func() error, error)
+	LogLimits() LogLimits
+}
+
+// LogLimits bounds the work a single log query may cause. Zero values disable
+// the respective limit.
+type LogLimits struct {
+	MaxBlockRange uint64 // maximum number of blocks a range query may span
+	MaxResults    int    // maximum number of logs a query may return
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -35,36 +46,14 @@ type Filter struct {
 	block      types.Hash // Block hash if filtering a single block
 	begin, end int64      // Range interval if filtering multiple blocks
 
-	//matcher *bloombits.Matcher
+	limits LogLimits
 }
 
-// NewRangeFilter creates a new filter which uses a bloom filter on blocks to
-// figure out whether a particular block is interesting or not.
+// NewRangeFilter creates a new filter which uses the header bloom of each block
+// to figure out whether a particular block is interesting or not.
 func NewRangeFilter(api Api, begin, end int64, addresses []types.Address, topics [][]types.Hash) *Filter {
-	// Flatten the address and topic filter clauses into a single bloombits filter
-	// system. Since the bloombits are not positional, nil topics are permitted,
-	// which get flattened into a nil byte slice.
-	var filters [][][]byte
-	if len(addresses) > 0 {
-		filter := make([][]byte, len(addresses))
-		for i, address := range addresses {
-			filter[i] = address.Bytes()
-		}
-		filters = append(filters, filter)
-	}
-	for _, topicList := range topics {
-		filter := make([][]byte, len(topicList))
-		for i, topic := range topicList {
-			filter[i] = topic.Bytes()
-		}
-		filters = append(filters, filter)
-	}
-	//size, _ := api.BloomStatus()
-
 	// Create a generic filter and convert it into a range filter
 	filter := newFilter(api, addresses, topics)
-
-	//filter.matcher = bloombits.NewMatcher(size, filters)
 	filter.begin = begin
 	filter.end = end
 
@@ -88,6 +77,7 @@ func newFilter(api Api, addresses []types.Address, topics [][]types.Hash) *Filte
 		addresses: addresses,
 		topics:    topics,
 		db:        api.Database(),
+		limits:    api.LogLimits(),
 	}
 }
 
@@ -103,7 +93,11 @@ func (f *Filter) Logs(ctx context.Context) ([]*block.Log, error) {
 		if header == nil {
 			return nil, errors.New("unknown block")
 		}
-		return f.blockLogs(ctx, header)
+		logs, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		return logs, f.checkResults(len(logs))
 	}
 	// Short-cut if all we care about is pending logs
 	if f.begin == jsonrpc.PendingBlockNumber.Int64() {
@@ -112,6 +106,14 @@ func (f *Filter) Logs(ctx context.Context) ([]*block.Log, error) {
 		}
 		return f.pendingLogs()
 	}
+	// Resolve the finalized and safe block tags
+	var err error
+	if f.begin, err = f.resolveTag(f.begin); err != nil {
+		return nil, err
+	}
+	if f.end, err = f.resolveTag(f.end); err != nil {
+		return nil, err
+	}
 	// Figure out the limits of the filter range
 	header := f.api.BlockChain().CurrentBlock().Header()
 	if header == nil {
@@ -122,31 +124,24 @@ func (f *Filter) Logs(ctx context.Context) ([]*block.Log, error) {
 		end     = uint64(f.end)
 		pending = f.end == jsonrpc.PendingBlockNumber.Int64()
 	)
-	if f.begin == jsonrpc.LatestBlockNumber.Int64() {
+	if f.begin < 0 {
 		f.begin = int64(head)
 	}
-	if f.end == jsonrpc.LatestBlockNumber.Int64() || f.end == jsonrpc.PendingBlockNumber.Int64() {
+	if f.end < 0 {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs           []*block.Log
-		err            error
-		size, sections = uint64(4096), uint64(10)
-		//todo
-	)
-	if indexed := sections * size; indexed > uint64(f.begin) {
-		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
-		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
-		}
-		if err != nil {
-			return logs, err
-		}
+	if limit := f.limits.MaxBlockRange; limit > 0 && end >= uint64(f.begin) && end-uint64(f.begin) >= limit {
+		return nil, fmt.Errorf("block range %d-%d exceeds the limit of %d blocks", f.begin, end, limit)
+	}
+	if err := f.db.View(ctx, func(tx kv.Tx) error {
+		return rawdb.CheckPruned(tx, rawdb.PruneReceipts, uint64(f.begin))
+	}); err != nil {
+		return nil, err
+	}
+	logs, err := f.rangeLogs(ctx, end)
+	if err != nil {
+		return nil, err
 	}
-	rest, err := f.unindexedLogs(ctx, end)
-	logs = append(logs, rest...)
 	if pending {
 		pendingLogs, err := f.pendingLogs()
 		if err != nil {
@@ -154,62 +149,51 @@ func (f *Filter) Logs(ctx context.Context) ([]*block.Log, error) {
 		}
 		logs = append(logs, pendingLogs...)
 	}
-	return logs, err
+	return logs, f.checkResults(len(logs))
 }
 
-// indexedLogs returns the logs matching the filter criteria based on the bloom
-// bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*block.Log, error) {
-	// Create a matcher session and request servicing from the backend
-	matches := make(chan uint64, 64)
-
-	//session, err := f.matcher.Start(ctx, uint64(f.begin), end, matches)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//defer session.Close()
-
-	//f.api.ServiceFilter(ctx, session)
-
-	// Iterate over the matches until exhausted or context closed
-	var logs []*block.Log
-
-	for {
-		select {
-		case number, ok := <-matches:
-			// Abort if all matches have been fulfilled
-			if !ok {
-				//err := session.Error()
-				//if err == nil {
-				//	f.begin = int64(end) + 1
-				//}
-				return logs, nil
-			}
-			f.begin = int64(number) + 1
-
-			// Retrieve the suggested block and pull any truly matching logs
-			header := f.api.BlockChain().GetHeaderByNumber(uint256.NewInt(number))
-			if header == nil {
-				return logs, nil
-			}
-			found, err := f.checkMatches(ctx, header)
-			if err != nil {
-				return logs, err
-			}
-			logs = append(logs, found...)
-
-		case <-ctx.Done():
-			return logs, ctx.Err()
+// resolveTag resolves the "finalized" and "safe" block tags to the number of
+// the block they name, other numbers are returned as they are.
+func (f *Filter) resolveTag(number int64) (int64, error) {
+	var header block.IHeader
+	switch number {
+	case jsonrpc.FinalizedBlockNumber.Int64():
+		if header = f.api.BlockChain().CurrentFinalBlock(); header == nil {
+			return 0, errors.New("finalized block not found")
+		}
+	case jsonrpc.SafeBlockNumber.Int64():
+		if header = f.api.BlockChain().CurrentSafeBlock(); header == nil {
+			return 0, errors.New("safe block not found")
 		}
+	default:
+		return number, nil
 	}
+	return int64(header.Number64().Uint64()), nil
 }
 
-// unindexedLogs returns the logs matching the filter criteria based on raw block
-// iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*block.Log, error) {
-	var logs []*block.Log
+// checkResults fails queries that matched more logs than allowed.
+func (f *Filter) checkResults(count int) error {
+	if limit := f.limits.MaxResults; limit > 0 && count > limit {
+		return fmt.Errorf("query returned more than %d results", limit)
+	}
+	return nil
+}
+
+// rangeLogs returns the logs matching the filter criteria between f.begin and
+// end. The blocks covered by the log index are looked up there, for the others
+// the header bloom of each block is consulted first so receipts are only read
+// for blocks that may contain a match.
+func (f *Filter) rangeLogs(ctx context.Context, end uint64) ([]*block.Log, error) {
+	logs, err := f.indexedLogs(ctx, end)
+	if err != nil {
+		return nil, err
+	}
 
 	for ; f.begin <= int64(end); f.begin++ {
+		// Stop scanning once the request has been cancelled or timed out.
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
 		header := f.api.BlockChain().GetHeaderByNumber(uint256.NewInt(uint64(f.begin)))
 		if header == nil {
 			return logs, nil
@@ -219,15 +203,60 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*block.Log, e
 			return logs, err
 		}
 		logs = append(logs, found...)
+		if err := f.checkResults(len(logs)); err != nil {
+			return nil, err
+		}
 	}
 	return logs, nil
 }
 
+// indexedLogs returns the logs matching the filter criteria between f.begin
+// and end in the blocks covered by the log index, and moves f.begin past them.
+func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*block.Log, error) {
+	var (
+		blocks  *roaring.Bitmap
+		indexed uint64
+	)
+	if err := f.db.View(ctx, func(tx kv.Tx) error {
+		head, _, ok, err := rawdb.ReadLogIndexHead(tx)
+		if err != nil || !ok || head < uint64(f.begin) {
+			return err
+		}
+		indexed = min(head, end)
+		blocks, err = rawdb.ReadLogIndexBlocks(tx, f.addresses, f.topics, uint64(f.begin), indexed)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if blocks == nil {
+		return nil, nil // not indexed, or every block matches
+	}
+
+	var logs []*block.Log
+	for it := blocks.Iterator(); it.HasNext(); {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+		header := f.api.BlockChain().GetHeaderByNumber(uint256.NewInt(uint64(it.Next())))
+		if header == nil {
+			continue
+		}
+		found, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, found...)
+		if err := f.checkResults(len(logs)); err != nil {
+			return nil, err
+		}
+	}
+	f.begin = int64(indexed) + 1
+	return logs, nil
+}
+
 // blockLogs returns the logs matching the filter criteria within a single block.
 func (f *Filter) blockLogs(ctx context.Context, header block.IHeader) (logs []*block.Log, err error) {
-	//todo header.Bloom
-	bloom, _ := types.NewBloom(100)
-	if bloomFilter(bloom, f.addresses, f.topics) {
+	if bloomFilter(header, f.addresses, f.topics) {
 		found, err := f.checkMatches(ctx, header)
 		if err != nil {
 			return logs, err
@@ -328,11 +357,18 @@ Logs:
 	return ret
 }
 
-func bloomFilter(bloom *types.Bloom, addresses []types.Address, topics [][]types.Hash) bool {
+// bloomFilter reports whether the header's logs bloom may contain logs matching
+// the given criteria. Headers of unknown type always match.
+func bloomFilter(header block.IHeader, addresses []types.Address, topics [][]types.Hash) bool {
+	h, ok := header.(*block.Header)
+	if !ok {
+		return true
+	}
+	bloom := h.Bloom
 	if len(addresses) > 0 {
 		var included bool
 		for _, addr := range addresses {
-			if bloom.Contain(addr.Bytes()) {
+			if bloom.Test(addr.Bytes()) {
 				included = true
 				break
 			}
@@ -345,7 +381,7 @@ func bloomFilter(bloom *types.Bloom, addresses []types.Address, topics [][]types
 	for _, sub := range topics {
 		included := len(sub) == 0 // empty rule set == wildcard
 		for _, topic := range sub {
-			if bloom.Contain(topic.Bytes()) {
+			if bloom.Test(topic.Bytes()) {
 				included = true
 				break
 			}
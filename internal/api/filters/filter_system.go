@@ -33,6 +33,8 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// SyncingSubscription reports when the node starts and stops syncing
+	SyncingSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -57,6 +59,7 @@ type subscription struct {
 	logs      chan []*block.Log
 	hashes    chan []types.Hash
 	headers   chan block.IHeader
+	syncing   chan bool
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -74,6 +77,8 @@ type EventSystem struct {
 	rmLogsSub      event.Subscription // Subscription for removed log event
 	pendingLogsSub event.Subscription // Subscription for pending log event
 	chainSub       event.Subscription // Subscription for new chain event
+	syncStartSub   event.Subscription // Subscription for sync started event
+	syncDoneSub    event.Subscription // Subscription for sync finished event
 
 	// Channels
 	install       chan *subscription              // install filter for event notification
@@ -83,6 +88,9 @@ type EventSystem struct {
 	pendingLogsCh chan common.NewPendingLogsEvent // Channel to receive new log event
 	rmLogsCh      chan common.RemovedLogsEvent    // Channel to receive removed log event
 	chainCh       chan common.ChainHighestBlock   // Channel to receive new chain event
+
+	syncStartCh chan common.DownloaderStartEvent  // Channel to receive sync started event
+	syncDoneCh  chan common.DownloaderFinishEvent // Channel to receive sync finished event
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -103,6 +111,8 @@ func NewEventSystem(api Api) *EventSystem {
 		rmLogsCh:      make(chan common.RemovedLogsEvent),
 		pendingLogsCh: make(chan common.NewPendingLogsEvent),
 		chainCh:       make(chan common.ChainHighestBlock),
+		syncStartCh:   make(chan common.DownloaderStartEvent),
+		syncDoneCh:    make(chan common.DownloaderFinishEvent),
 	}
 
 	// Subscribe events
@@ -111,9 +121,12 @@ func NewEventSystem(api Api) *EventSystem {
 	m.rmLogsSub = event.GlobalEvent.Subscribe(m.rmLogsCh)
 	m.chainSub = event.GlobalEvent.Subscribe(m.chainCh)
 	m.pendingLogsSub = event.GlobalEvent.Subscribe(m.pendingLogsCh)
+	m.syncStartSub = event.GlobalEvent.Subscribe(m.syncStartCh)
+	m.syncDoneSub = event.GlobalEvent.Subscribe(m.syncDoneCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil {
+	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil ||
+		m.syncStartSub == nil || m.syncDoneSub == nil {
 		log.Error("Subscribe for event system failed")
 	}
 
@@ -149,6 +162,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.syncing:
 			}
 		}
 
@@ -288,6 +302,23 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []types.Hash) *Subscripti
 	return es.subscribe(sub)
 }
 
+// SubscribeSyncing creates a subscription that writes true when the node starts
+// syncing and false once it has caught up.
+func (es *EventSystem) SubscribeSyncing(syncing chan bool) *Subscription {
+	sub := &subscription{
+		id:        jsonrpc.NewID(),
+		typ:       SyncingSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*block.Log),
+		hashes:    make(chan []types.Hash),
+		headers:   make(chan block.IHeader),
+		syncing:   syncing,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[jsonrpc.ID]*subscription
 
 func (es *EventSystem) handleLogs(filters filterIndex, ev common.NewLogsEvent) {
@@ -349,6 +380,12 @@ func (es *EventSystem) handleChainEvent(filters filterIndex, ev common.ChainHigh
 	}
 }
 
+func (es *EventSystem) handleSyncEvent(filters filterIndex, syncing bool) {
+	for _, f := range filters[SyncingSubscription] {
+		f.syncing <- syncing
+	}
+}
+
 func (es *EventSystem) lightFilterNewHead(newHeader block.IHeader, callBack func(block.IHeader, bool)) {
 	oldh := es.lastHead
 	es.lastHead = newHeader
@@ -394,9 +431,7 @@ func (es *EventSystem) lightFilterNewHead(newHeader block.IHeader, callBack func
 
 // filter logs of a single header in light client mode
 func (es *EventSystem) lightFilterLogs(header block.IHeader, addresses []types.Address, topics [][]types.Hash, remove bool) []*block.Log {
-	//todo header.Bloom
-	bloom, _ := types.NewBloom(100)
-	if bloomFilter(bloom, addresses, topics) {
+	if bloomFilter(header, addresses, topics) {
 		// Get the logs of the block
 		_, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
@@ -443,6 +478,8 @@ func (es *EventSystem) eventLoop() {
 		es.rmLogsSub.Unsubscribe()
 		es.pendingLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		es.syncStartSub.Unsubscribe()
+		es.syncDoneSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -464,6 +501,10 @@ func (es *EventSystem) eventLoop() {
 			if ev.Inserted {
 				es.handleChainEvent(index, ev)
 			}
+		case <-es.syncStartCh:
+			es.handleSyncEvent(index, true)
+		case <-es.syncDoneCh:
+			es.handleSyncEvent(index, false)
 
 		case f := <-es.install:
 			if f.typ == MinedAndPendingLogsSubscription {
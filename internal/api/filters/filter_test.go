@@ -0,0 +1,222 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal"
+	"github.com/n42blockchain/N42/internal/consensus"
+	vm2 "github.com/n42blockchain/N42/internal/vm"
+	"github.com/n42blockchain/N42/internal/vm/evmtypes"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/ethdb/bitmapdb"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+var (
+	addrA  = types.HexToAddress("0x00000000000000000000000000000000000000aa")
+	addrB  = types.HexToAddress("0x00000000000000000000000000000000000000bb")
+	topic1 = types.Hash{31: 1}
+	topic2 = types.Hash{31: 2}
+)
+
+// testChain serves the headers and logs of a chain built in memory, the other
+// methods of the interface are not used by the filters.
+type testChain struct {
+	common.IBlockChain
+	headers []*block.Header
+	logs    map[types.Hash][][]*block.Log
+}
+
+func (c *testChain) CurrentBlock() block.IBlock {
+	return block.NewBlock(c.headers[len(c.headers)-1], nil)
+}
+
+func (c *testChain) GetHeaderByNumber(number *uint256.Int) block.IHeader {
+	if !number.IsUint64() || number.Uint64() >= uint64(len(c.headers)) {
+		return nil
+	}
+	return c.headers[number.Uint64()]
+}
+
+func (c *testChain) GetHeaderByHash(hash types.Hash) (block.IHeader, error) {
+	for _, header := range c.headers {
+		if header.Hash() == hash {
+			return header, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *testChain) GetLogs(hash types.Hash) ([][]*block.Log, error) {
+	return c.logs[hash], nil
+}
+
+func (c *testChain) GetReceipts(hash types.Hash) (block.Receipts, error) {
+	var receipts block.Receipts
+	for _, logs := range c.logs[hash] {
+		receipts = append(receipts, &block.Receipt{Logs: logs})
+	}
+	return receipts, nil
+}
+
+type testBackend struct {
+	db    kv.RwDB
+	chain *testChain
+}
+
+func (b *testBackend) TxsPool() common.ITxsPool       { return nil }
+func (b *testBackend) Database() kv.RwDB              { return b.db }
+func (b *testBackend) Engine() consensus.Engine       { return nil }
+func (b *testBackend) BlockChain() common.IBlockChain { return b.chain }
+func (b *testBackend) LogLimits() LogLimits           { return LogLimits{} }
+func (b *testBackend) GetEvm(context.Context, internal.Message, evmtypes.IntraBlockState, block.IHeader, *vm2.Config) (*vm2.EVM, func() error, error) {
+	return nil, nil, errors.New("not supported")
+}
+
+// newTestBackend builds a chain of 8 blocks whose logs are:
+//
+//	block 2: addrA topic1
+//	block 5: addrA topic2
+//	block 6: addrB topic1
+//
+// with the blocks up to 3 in the log index, which also lists block 1 for
+// addrA as left behind by a reorg.
+func newTestBackend(t *testing.T) *testBackend {
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	db := memdb.NewTestDB(t)
+
+	emitted := map[uint64][]*block.Log{
+		2: {{Address: addrA, Topics: []types.Hash{topic1}}},
+		5: {{Address: addrA, Topics: []types.Hash{topic2}}},
+		6: {{Address: addrB, Topics: []types.Hash{topic1}}},
+	}
+	chain := &testChain{logs: make(map[types.Hash][][]*block.Log)}
+	for i := uint64(0); i < 8; i++ {
+		header := &block.Header{
+			Number:     uint256.NewInt(i),
+			Difficulty: uint256.NewInt(0),
+			BaseFee:    uint256.NewInt(0),
+		}
+		if logs := emitted[i]; len(logs) > 0 {
+			header.Bloom = block.CreateBloom(block.Receipts{{Logs: logs}})
+		}
+		if i > 0 {
+			header.ParentHash = chain.headers[i-1].Hash()
+		}
+		chain.headers = append(chain.headers, header)
+		hash := header.Hash()
+		for _, l := range emitted[i] {
+			l.BlockNumber = uint256.NewInt(i)
+			l.BlockHash = hash
+			l.TxHash = types.Hash{31: byte(i)}
+		}
+		if logs := emitted[i]; len(logs) > 0 {
+			chain.logs[hash] = [][]*block.Log{logs}
+		}
+	}
+
+	if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		if err := bitmapdb.AppendMergeByOr(tx, modules.LogAddressIndex, addrA.Bytes(), roaring.BitmapOf(1, 2)); err != nil {
+			return err
+		}
+		if err := bitmapdb.AppendMergeByOr(tx, modules.LogTopicIndex, topic1.Bytes(), roaring.BitmapOf(2)); err != nil {
+			return err
+		}
+		return rawdb.WriteLogIndexHead(tx, 3, chain.headers[3].Hash())
+	}); err != nil {
+		t.Fatalf("failed to write the log index: %v", err)
+	}
+	return &testBackend{db: db, chain: chain}
+}
+
+func logBlocks(logs []*block.Log) []uint64 {
+	numbers := make([]uint64, len(logs))
+	for i, l := range logs {
+		numbers[i] = l.BlockNumber.Uint64()
+	}
+	return numbers
+}
+
+func TestRangeFilterLogs(t *testing.T) {
+	backend := newTestBackend(t)
+
+	tests := []struct {
+		name       string
+		begin, end int64
+		addresses  []types.Address
+		topics     [][]types.Hash
+		want       []uint64
+	}{
+		{"indexed only", 0, 3, []types.Address{addrA}, nil, []uint64{2}},
+		{"unindexed only", 4, 7, []types.Address{addrA}, nil, []uint64{5}},
+		{"across the index head", 0, 7, []types.Address{addrA}, nil, []uint64{2, 5}},
+		{"topic across the index head", 1, -1, nil, [][]types.Hash{{topic1}}, []uint64{2, 6}},
+		{"address and topic", 0, 7, []types.Address{addrA}, [][]types.Hash{{topic2}}, []uint64{5}},
+		{"any of addresses", 0, 7, []types.Address{addrA, addrB}, nil, []uint64{2, 5, 6}},
+		{"no criteria", 0, 7, nil, nil, []uint64{2, 5, 6}},
+		{"no match", 0, 7, []types.Address{types.HexToAddress("0xcc")}, nil, []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+			if err != nil {
+				t.Fatalf("Logs failed: %v", err)
+			}
+			have := logBlocks(logs)
+			if len(have) != len(tt.want) {
+				t.Fatalf("logs of blocks %v, want %v", have, tt.want)
+			}
+			for i := range have {
+				if have[i] != tt.want[i] {
+					t.Fatalf("logs of blocks %v, want %v", have, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockFilterLogs(t *testing.T) {
+	backend := newTestBackend(t)
+
+	logs, err := NewBlockFilter(backend, backend.chain.headers[2].Hash(), []types.Address{addrA}, nil).Logs(context.Background())
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Address != addrA || logs[0].BlockNumber.Uint64() != 2 {
+		t.Fatalf("unexpected logs %v", logs)
+	}
+
+	logs, err = NewBlockFilter(backend, backend.chain.headers[2].Hash(), []types.Address{addrB}, nil).Logs(context.Background())
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if len(logs) != 0 {
+		t.Fatalf("logs %v of another address", logs)
+	}
+}
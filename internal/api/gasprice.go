@@ -94,27 +94,37 @@ func NewOracle(backend common2.IBlockChain, miner common2.IMiner, chainConfig *p
 		log.Warn("Sanitizing invalid gasprice oracle max block history", "provided", params.MaxBlockHistory, "updated", maxBlockHistory)
 	}
 
+	lastPrice := params.Default
+	if lastPrice == nil {
+		lastPrice = new(big.Int)
+	}
+
 	cache, _ := lru.New(2048)
 
-	highestBlockCh := make(chan common2.ChainHighestBlock)
-	defer close(highestBlockCh)
+	// Drop the cached fee history whenever the head is not a child of the
+	// previous one; the cache is keyed by block number and would go stale.
+	highestBlockCh := make(chan common2.ChainHighestBlock, 10)
 	highestSub := event.GlobalEvent.Subscribe(highestBlockCh)
-	defer highestSub.Unsubscribe()
-
 	go func() {
+		defer highestSub.Unsubscribe()
 		var lastHead types2.Hash
-		for ev := range highestBlockCh {
-			if ev.Block.ParentHash() != lastHead {
-				cache.Purge()
+		for {
+			select {
+			case ev := <-highestBlockCh:
+				if ev.Block.ParentHash() != lastHead {
+					cache.Purge()
+				}
+				lastHead = ev.Block.Hash()
+			case <-highestSub.Err():
+				return
 			}
-			lastHead = ev.Block.Hash()
 		}
 	}()
 
 	return &Oracle{
 		backend:          backend,
 		miner:            miner,
-		lastPrice:        params.Default,
+		lastPrice:        lastPrice,
 		maxPrice:         maxPrice,
 		ignorePrice:      ignorePrice,
 		checkBlocks:      blocks,
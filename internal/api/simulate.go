@@ -0,0 +1,368 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal"
+	mvm_types "github.com/n42blockchain/N42/internal/avm/types"
+	vm2 "github.com/n42blockchain/N42/internal/vm"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+	"github.com/n42blockchain/N42/modules/state"
+	"github.com/n42blockchain/N42/params"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks a single
+	// eth_simulateV1 request may build.
+	maxSimulateBlocks = 256
+	// errCodeVMError is the JSON-RPC error code of a call aborted by the EVM.
+	errCodeVMError = -32015
+)
+
+var (
+	errSimulateNoBlocks      = errors.New("empty input")
+	errSimulateTooManyBlocks = fmt.Errorf("too many blocks, at most %d are allowed", maxSimulateBlocks)
+)
+
+// simBlock is a block of calls run by eth_simulateV1, together with the
+// overrides applied before its first call.
+type simBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// simOpts are the options of eth_simulateV1. With Validation set calls are
+// checked like real transactions: nonces must match and fees must cover the
+// base fee.
+type simOpts struct {
+	BlockStateCalls        []simBlock `json:"blockStateCalls"`
+	Validation             bool       `json:"validation"`
+	ReturnFullTransactions bool       `json:"returnFullTransactions"`
+}
+
+// simCallError describes why a simulated call failed.
+type simCallError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+// simCallResult is the outcome of a single simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes    `json:"returnData"`
+	Logs        []*mvm_types.Log `json:"logs"`
+	GasUsed     hexutil.Uint64   `json:"gasUsed"`
+	Status      hexutil.Uint64   `json:"status"`
+	Error       *simCallError    `json:"error,omitempty"`
+}
+
+// simulator runs the blocks of an eth_simulateV1 request on one state.
+type simulator struct {
+	api      *API
+	state    *state.IntraBlockState
+	base     uint64
+	validate bool
+	fullTx   bool
+	// hashes of the simulated blocks, served to BLOCKHASH.
+	hashes map[uint64]types.Hash
+}
+
+// SimulateV1 executes the given blocks of calls on top of the state of
+// blockNrOrHash, each block seeing the state left by the ones before it, and
+// returns the simulated blocks with the result and logs of every call.
+//
+// The simulated headers carry the gas used and logs bloom of their calls;
+// their state, transaction and receipt roots are not computed.
+func (s *BlockChainAPI) SimulateV1(ctx context.Context, opts simOpts, blockNrOrHash *jsonrpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errSimulateNoBlocks
+	}
+	if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, errSimulateTooManyBlocks
+	}
+	if blockNrOrHash == nil {
+		latest := jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	base, err := BlockByNumberOrHash(ctx, *blockNrOrHash, s.api)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return nil, errors.New("header not found")
+	}
+	parent, ok := base.Header().(*block.Header)
+	if !ok {
+		return nil, fmt.Errorf("unexpected header type %T", base.Header())
+	}
+
+	tx, err := s.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ibs, ok := s.api.State(tx, *blockNrOrHash).(*state.IntraBlockState)
+	if !ok {
+		return nil, errors.New("cannot load state")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rpcEVMTimeout)
+	defer cancel()
+
+	sim := &simulator{
+		api:      s.api,
+		state:    ibs,
+		base:     parent.Number.Uint64(),
+		validate: opts.Validation,
+		fullTx:   opts.ReturnFullTransactions,
+		hashes:   make(map[uint64]types.Hash),
+	}
+	results := make([]map[string]interface{}, 0, len(opts.BlockStateCalls))
+	for i := range opts.BlockStateCalls {
+		result, header, err := sim.processBlock(ctx, &opts.BlockStateCalls[i], parent)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// processBlock builds the header of a simulated block on top of parent and
+// runs its calls.
+func (sim *simulator) processBlock(ctx context.Context, b *simBlock, parent *block.Header) (map[string]interface{}, *block.Header, error) {
+	header := nextHeader(sim.api.GetChainConfig(), parent)
+	blockCtx := internal.NewEVMBlockContext(header, sim.getHash, sim.api.Engine(), &header.Coinbase)
+	if !sim.validate {
+		blockCtx.BaseFee = new(uint256.Int)
+	}
+	b.BlockOverrides.Apply(&blockCtx)
+	if blockCtx.BlockNumber <= parent.Number.Uint64() {
+		return nil, nil, fmt.Errorf("block number %d is not above its parent %d", blockCtx.BlockNumber, parent.Number.Uint64())
+	}
+	if blockCtx.Time <= parent.Time {
+		return nil, nil, fmt.Errorf("block timestamp %d is not above its parent %d", blockCtx.Time, parent.Time)
+	}
+	header.Number = uint256.NewInt(blockCtx.BlockNumber)
+	header.Time = blockCtx.Time
+	header.GasLimit = blockCtx.GasLimit
+	header.Coinbase = blockCtx.Coinbase
+	header.BaseFee = new(uint256.Int).Set(blockCtx.BaseFee)
+	if blockCtx.Difficulty != nil {
+		header.Difficulty, _ = uint256.FromBig(blockCtx.Difficulty)
+	}
+	if blockCtx.PrevRanDao != nil {
+		header.MixDigest = *blockCtx.PrevRanDao
+	}
+	if err := b.StateOverrides.Apply(sim.state); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		chainConfig = sim.api.GetChainConfig()
+		rules       = chainConfig.Rules(header.Number.Uint64())
+		gp          = new(common.GasPool).AddGas(header.GasLimit)
+		txs         = make([]*transaction.Transaction, 0, len(b.Calls))
+		results     = make([]*internal.ExecutionResult, 0, len(b.Calls))
+		callLogs    = make([][]*block.Log, 0, len(b.Calls))
+		logs        []*block.Log
+	)
+	for i := range b.Calls {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		args := &b.Calls[i]
+		if err := sim.setCallDefaults(args, header); err != nil {
+			return nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		tx := args.toTransaction()
+		tx.SetFrom(args.from())
+		txHash := tx.Hash()
+
+		msg, err := args.ToMessage(rpcGasCap, blockCtx.BaseFee.ToBig())
+		if err != nil {
+			return nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		if sim.validate {
+			msg = transaction.NewMessage(msg.From(), msg.To(), uint64(*args.Nonce), msg.Value(), msg.Gas(), msg.GasPrice(), msg.FeeCap(), msg.Tip(), msg.Data(), msg.AccessList(), true, false)
+		}
+		sim.state.Prepare(txHash, types.Hash{}, i)
+		evm := vm2.NewEVM(blockCtx, internal.NewEVMTxContext(msg), sim.state, chainConfig, vm2.Config{NoBaseFee: !sim.validate})
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+		result, err := internal.ApplyMessage(evm, msg, gp, true, false)
+		if evm.Cancelled() {
+			return nil, nil, fmt.Errorf("execution aborted (timeout = %v)", rpcEVMTimeout)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		if err := sim.state.FinalizeTx(rules, state.NewNoopWriter()); err != nil {
+			return nil, nil, err
+		}
+		header.GasUsed += result.UsedGas
+
+		txLogs := sim.state.GetLogs(txHash)
+		for _, l := range txLogs {
+			l.BlockNumber = new(uint256.Int).Set(header.Number)
+			l.TxHash = txHash
+			l.TxIndex = uint(i)
+			l.Index = uint(len(logs))
+			logs = append(logs, l)
+		}
+		results = append(results, result)
+		callLogs = append(callLogs, txLogs)
+		txs = append(txs, tx)
+	}
+	header.Bloom = block.BytesToBloom(block.LogsBloom(logs))
+
+	hash := header.Hash()
+	sim.hashes[header.Number.Uint64()] = hash
+	for _, l := range logs {
+		l.BlockHash = hash
+	}
+	calls := make([]simCallResult, len(results))
+	for i, result := range results {
+		calls[i] = newSimCallResult(result, callLogs[i])
+	}
+
+	fields := RPCMarshalHeader(header)
+	rpcTxs := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		if sim.fullTx {
+			rpcTxs[i] = newRPCTransaction(tx, hash, header.Number.Uint64(), uint64(i), header.BaseFee.ToBig())
+		} else {
+			rpcTxs[i] = mvm_types.FromastHash(tx.Hash())
+		}
+	}
+	fields["transactions"] = rpcTxs
+	fields["calls"] = calls
+	return fields, header, nil
+}
+
+// nextHeader returns the default header of a block built on top of parent,
+// one block period later.
+func nextHeader(config *params.ChainConfig, parent *block.Header) *block.Header {
+	header := &block.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase,
+		Difficulty: new(uint256.Int),
+		Number:     new(uint256.Int).AddUint64(parent.Number, 1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 1,
+		MixDigest:  parent.MixDigest,
+		BaseFee:    new(uint256.Int),
+	}
+	if parent.Difficulty != nil {
+		header.Difficulty.Set(parent.Difficulty)
+	}
+	if parent.BaseFee != nil {
+		header.BaseFee.Set(parent.BaseFee)
+	}
+	if apos := config.Apos; apos != nil && apos.Period > 0 {
+		header.Time = parent.Time + apos.Period
+	}
+	return header
+}
+
+// setCallDefaults fills in the fields of a simulated call left out by the
+// caller: the sender's current nonce, the gas left in the block and zero
+// prices and value.
+func (sim *simulator) setCallDefaults(args *TransactionArgs, header *block.Header) error {
+	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
+		return errors.New(`both "data" and "input" are set and not equal. Please use "input" to pass transaction call data`)
+	}
+	if args.Nonce == nil {
+		nonce := hexutil.Uint64(sim.state.GetNonce(args.from()))
+		args.Nonce = &nonce
+	}
+	if args.Gas == nil {
+		if header.GasUsed > header.GasLimit {
+			return errors.New("block gas limit reached")
+		}
+		gas := hexutil.Uint64(header.GasLimit - header.GasUsed)
+		args.Gas = &gas
+	}
+	if args.Value == nil {
+		args.Value = new(hexutil.Big)
+	}
+	if args.ChainID == nil {
+		args.ChainID = (*hexutil.Big)(sim.api.GetChainConfig().ChainID)
+	}
+	if args.MaxFeePerGas != nil {
+		if args.MaxPriorityFeePerGas == nil {
+			args.MaxPriorityFeePerGas = new(hexutil.Big)
+		}
+	} else if args.GasPrice == nil {
+		args.GasPrice = new(hexutil.Big)
+	}
+	return nil
+}
+
+// getHash returns the hash of block n, looking at the simulated blocks
+// before the canonical chain.
+func (sim *simulator) getHash(n uint64) types.Hash {
+	if hash, ok := sim.hashes[n]; ok {
+		return hash
+	}
+	if n > sim.base {
+		return types.Hash{}
+	}
+	header := sim.api.BlockChain().GetHeaderByNumber(uint256.NewInt(n))
+	if header == nil {
+		return types.Hash{}
+	}
+	return header.Hash()
+}
+
+func newSimCallResult(result *internal.ExecutionResult, logs []*block.Log) simCallResult {
+	callResult := simCallResult{
+		ReturnValue: result.Return(),
+		Logs:        mvm_types.FromastLogs(logs),
+		GasUsed:     hexutil.Uint64(result.UsedGas),
+		Status:      1,
+	}
+	if callResult.Logs == nil {
+		callResult.Logs = []*mvm_types.Log{}
+	}
+	if result.Failed() {
+		callResult.Status = 0
+		if len(result.Revert()) > 0 {
+			revert := newRevertError(result)
+			callResult.Error = &simCallError{Message: revert.Error(), Code: revert.ErrorCode(), Data: revert.reason}
+		} else {
+			callResult.Error = &simCallError{Message: result.Err.Error(), Code: errCodeVMError}
+		}
+	}
+	return callResult
+}
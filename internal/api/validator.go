@@ -0,0 +1,240 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/crypto"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/types"
+	mvm_types "github.com/n42blockchain/N42/internal/avm/types"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+)
+
+// The states of a validator reported by validator_status.
+const (
+	ValidatorUnknown      = "unknown"      // no deposit
+	ValidatorDepositing   = "depositing"   // deposited to a contract, not accepted by the node yet
+	ValidatorActive       = "active"       // deposit accepted and locked
+	ValidatorWithdrawable = "withdrawable" // deposit unlocked, the validator may exit
+	ValidatorExiting      = "exiting"      // exit sent, the deposit is not withdrawn yet
+	ValidatorExited       = "exited"       // deposit withdrawn by the exit
+)
+
+var (
+	withdrawSelector           = crypto.Keccak256([]byte("withdraw()"))[:4]
+	unlockingTimestampSelector = crypto.Keccak256([]byte("depositUnlockingTimestamp(address)"))[:4]
+)
+
+// depositEngine is implemented by the consensus engines whose validators
+// deposit their stake to contracts.
+type depositEngine interface {
+	DepositContracts() []types.Address
+}
+
+// ValidatorExit is an exit sent by validator_exit.
+type ValidatorExit struct {
+	Tx     types.Hash      `json:"tx"`
+	Block  *hexutil.Uint64 `json:"block"` // nil while the exit is pending
+	Failed bool            `json:"failed"`
+}
+
+// ValidatorStatus is the stake of a validator, the address which deposited it
+// and to which it is withdrawn.
+type ValidatorStatus struct {
+	Address         types.Address    `json:"address"`
+	State           string           `json:"state"`
+	PublicKey       *types.PublicKey `json:"publicKey,omitempty"`
+	Deposit         *hexutil.Big     `json:"deposit,omitempty"`
+	DepositContract *types.Address   `json:"depositContract,omitempty"`
+	UnlockTime      hexutil.Uint64   `json:"unlockTime,omitempty"`
+	UnpaidReward    *hexutil.Big     `json:"unpaidReward"`
+	Exit            *ValidatorExit   `json:"exit,omitempty"`
+}
+
+// ValidatorAPI lets the operator of a node running validators follow their
+// deposits, rewards and withdrawals, and exit them. The exits are sent from the
+// accounts of the node, it is only served over the authenticated endpoints.
+type ValidatorAPI struct {
+	api       *API
+	nonceLock *AddrLocker
+
+	exitsLock sync.Mutex
+	exits     map[types.Address]types.Hash // exits sent since the node started
+}
+
+// NewValidatorAPI creates a new instance of ValidatorAPI.
+func NewValidatorAPI(api *API, nonceLock *AddrLocker) *ValidatorAPI {
+	return &ValidatorAPI{
+		api:       api,
+		nonceLock: nonceLock,
+		exits:     make(map[types.Address]types.Hash),
+	}
+}
+
+// Status returns the stake of the validator which deposited from address.
+func (api *ValidatorAPI) Status(ctx context.Context, address types.Address) (*ValidatorStatus, error) {
+	engine, ok := api.api.Engine().(depositEngine)
+	if !ok {
+		return nil, errors.New("validators are not supported by the consensus engine")
+	}
+	api.exitsLock.Lock()
+	exitTx, exiting := api.exits[address]
+	api.exitsLock.Unlock()
+
+	status := &ValidatorStatus{
+		Address: address,
+		State:   ValidatorUnknown,
+	}
+	var deposited bool
+	err := api.api.Database().View(ctx, func(tx kv.Tx) error {
+		if pubkey, amount, err := rawdb.GetDeposit(tx, address); err == nil && amount != nil {
+			deposited = true
+			status.PublicKey = &pubkey
+			status.Deposit = (*hexutil.Big)(amount.ToBig())
+		}
+		reward, err := rawdb.GetAccountReward(tx, address)
+		if err != nil {
+			return err
+		}
+		status.UnpaidReward = (*hexutil.Big)(reward.ToBig())
+		if !exiting {
+			return nil
+		}
+		status.Exit = &ValidatorExit{Tx: exitTx}
+		receipt, _, number, _, err := rawdb.ReadReceipt(tx, exitTx)
+		if err != nil || receipt == nil {
+			return err
+		}
+		status.Exit.Block = (*hexutil.Uint64)(&number)
+		status.Exit.Failed = receipt.Status == block.ReceiptStatusFailed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contract := range engine.DepositContracts() {
+		unlock, err := api.unlockTime(ctx, contract, address)
+		if err != nil {
+			return nil, err
+		}
+		if unlock != 0 {
+			status.DepositContract = &contract
+			status.UnlockTime = hexutil.Uint64(unlock)
+			break
+		}
+	}
+
+	// A failed exit leaves the validator as it was.
+	switch {
+	case status.Exit != nil && !status.Exit.Failed && (status.Exit.Block == nil || deposited):
+		status.State = ValidatorExiting
+	case status.Exit != nil && !status.Exit.Failed:
+		status.State = ValidatorExited
+	case deposited && uint64(status.UnlockTime) > uint64(time.Now().Unix()):
+		status.State = ValidatorActive
+	case deposited:
+		status.State = ValidatorWithdrawable
+	case status.DepositContract != nil:
+		status.State = ValidatorDepositing
+	}
+	return status, nil
+}
+
+// Validators returns the stake of the accounts of the node which deposited.
+func (api *ValidatorAPI) Validators(ctx context.Context) ([]*ValidatorStatus, error) {
+	validators := make([]*ValidatorStatus, 0)
+	if api.api.AccountManager() == nil {
+		return validators, nil
+	}
+	for _, address := range api.api.AccountManager().Accounts() {
+		status, err := api.Status(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if status.State != ValidatorUnknown {
+			validators = append(validators, status)
+		}
+	}
+	return validators, nil
+}
+
+// Exit withdraws the deposit of address from its contract, once it is
+// unlocked, with a transaction signed by the account of the node. It returns
+// the hash of the transaction, whose progress validator_status reports.
+func (api *ValidatorAPI) Exit(ctx context.Context, address types.Address) (types.Hash, error) {
+	status, err := api.Status(ctx, address)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	switch status.State {
+	case ValidatorWithdrawable:
+	case ValidatorActive:
+		return types.Hash{}, fmt.Errorf("deposit of %s is locked until %s", address, time.Unix(int64(status.UnlockTime), 0).UTC())
+	case ValidatorExiting:
+		return types.Hash{}, fmt.Errorf("validator %s is already exiting in transaction %s", address, status.Exit.Tx)
+	default:
+		return types.Hash{}, fmt.Errorf("validator %s is %s, only a deposit accepted by the node can be withdrawn", address, status.State)
+	}
+
+	data := hexutil.Bytes(withdrawSelector)
+	args := TransactionArgs{
+		From: mvm_types.FromastAddress(&address),
+		To:   mvm_types.FromastAddress(status.DepositContract),
+		Data: &data,
+	}
+	hash, err := NewTransactionAPI(api.api, api.nonceLock).SendTransaction(ctx, args)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	exitTx := mvm_types.ToastHash(hash)
+	api.exitsLock.Lock()
+	api.exits[address] = exitTx
+	api.exitsLock.Unlock()
+	log.Info("Sent validator exit", "address", address, "contract", status.DepositContract, "tx", exitTx)
+	return exitTx, nil
+}
+
+// unlockTime returns the time the deposit of address to contract unlocks at,
+// zero if it did not deposit to the contract.
+func (api *ValidatorAPI) unlockTime(ctx context.Context, contract, address types.Address) (uint64, error) {
+	data := hexutil.Bytes(append(types.CopyBytes(unlockingTimestampSelector), types.BytesToHash(address.Bytes()).Bytes()...))
+	args := TransactionArgs{
+		To:   mvm_types.FromastAddress(&contract),
+		Data: &data,
+	}
+	result, err := DoCall(ctx, api.api, args, jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.LatestBlockNumber), nil, rpcEVMTimeout, rpcGasCap)
+	if err != nil {
+		return 0, err
+	}
+	// The contracts revert when address has no deposit.
+	if result.Failed() || len(result.Return()) != 32 {
+		return 0, nil
+	}
+	return new(uint256.Int).SetBytes(result.Return()).Uint64(), nil
+}
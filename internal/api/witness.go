@@ -0,0 +1,130 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/n42blockchain/N42/common/account"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+	"github.com/n42blockchain/N42/turbo/rpchelper"
+)
+
+// WitnessAccount is an account read by a block, as of its parent, with the
+// storage slots read.
+type WitnessAccount struct {
+	Nonce       hexutil.Uint64               `json:"nonce"`
+	Balance     *hexutil.Big                 `json:"balance"`
+	CodeHash    types.Hash                   `json:"codeHash"`
+	Incarnation hexutil.Uint64               `json:"incarnation"`
+	Storage     map[types.Hash]hexutil.Bytes `json:"storage,omitempty"`
+}
+
+// ExecutionWitness is the state a block reads, enough to execute it without
+// the database. There is no trie to prove the state with, Proof is the hash
+// stateless verifiers compute over the accounts, storage and codes read.
+type ExecutionWitness struct {
+	Block    types.Hash                        `json:"block"`
+	Number   hexutil.Uint64                    `json:"number"`
+	Accounts map[types.Address]*WitnessAccount `json:"accounts"`
+	Codes    map[types.Hash]hexutil.Bytes      `json:"codes"`
+	Headers  []*block.Header                   `json:"headers"`
+	Proof    types.Hash                        `json:"proof"`
+}
+
+// ExecutionWitness executes the given block again on the state of its parent
+// and returns the accounts, storage slots and codes it read, and the headers
+// of the ancestors whose hashes it looked up.
+func (api *DebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash) (*ExecutionWitness, error) {
+	chain, ok := api.api.BlockChain().(*internal.BlockChain)
+	if !ok {
+		return nil, errors.New("execution witnesses are not supported by the chain")
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	number, hash, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx)
+	if err != nil {
+		return nil, err
+	}
+	b := rawdb.ReadBlock(tx, hash, number.Uint64())
+	if b == nil {
+		return nil, fmt.Errorf("block %d not found", number.Uint64())
+	}
+	entire, err := chain.ExecutionWitness(tx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	witness := &ExecutionWitness{
+		Block:    hash,
+		Number:   hexutil.Uint64(number.Uint64()),
+		Accounts: make(map[types.Address]*WitnessAccount),
+		Codes:    make(map[types.Hash]hexutil.Bytes, len(entire.Codes)),
+		Headers:  entire.Headers,
+		Proof:    entire.Entire.Proof,
+	}
+	get := func(addr types.Address) *WitnessAccount {
+		if witness.Accounts[addr] == nil {
+			witness.Accounts[addr] = &WitnessAccount{Balance: new(hexutil.Big)}
+		}
+		return witness.Accounts[addr]
+	}
+	// The accounts are keyed by address, the storage slots by address,
+	// incarnation and location.
+	for _, item := range entire.Entire.Snap.Items {
+		switch len(item.Key) {
+		case types.AddressLength:
+			var acc account.StateAccount
+			if err := acc.DecodeForStorage(item.Value); err != nil {
+				return nil, err
+			}
+			w := get(types.BytesToAddress(item.Key))
+			w.Nonce = hexutil.Uint64(acc.Nonce)
+			w.Balance = (*hexutil.Big)(acc.Balance.ToBig())
+			w.CodeHash = acc.CodeHash
+			w.Incarnation = hexutil.Uint64(acc.Incarnation)
+		case types.AddressLength + types.IncarnationLength + types.HashLength:
+			w := get(types.BytesToAddress(item.Key[:types.AddressLength]))
+			if w.Storage == nil {
+				w.Storage = make(map[types.Hash]hexutil.Bytes)
+			}
+			if w.Incarnation == 0 {
+				w.Incarnation = hexutil.Uint64(binary.BigEndian.Uint16(item.Key[types.AddressLength:]))
+			}
+			w.Storage[types.BytesToHash(item.Key[types.AddressLength+types.IncarnationLength:])] = item.Value
+		}
+	}
+	for _, code := range entire.Codes {
+		witness.Codes[code.Hash] = code.Code
+	}
+	if witness.Headers == nil {
+		witness.Headers = []*block.Header{}
+	}
+	return witness, nil
+}
@@ -31,6 +31,7 @@ import (
 	"time"
 
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/modules"
 	"github.com/n42blockchain/N42/modules/state"
 	"github.com/n42blockchain/N42/params"
 
@@ -39,11 +40,13 @@ import (
 	"github.com/n42blockchain/N42/api/protocol/msg_proto"
 	"github.com/n42blockchain/N42/common"
 	block2 "github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/transaction"
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/internal/consensus"
 	"github.com/n42blockchain/N42/log"
 	event "github.com/n42blockchain/N42/modules/event/v2"
 	"github.com/n42blockchain/N42/modules/rawdb"
+	"go.opencensus.io/trace"
 )
 
 var (
@@ -56,13 +59,18 @@ var (
 	errChainStopped         = errors.New("blockchain is stopped")
 	errInsertionInterrupted = errors.New("insertion is interrupted")
 	errBlockDoesNotExist    = errors.New("block does not exist in blockchain")
+	ErrReorgTooDeep         = errors.New("reorg deeper than allowed")
 )
 var (
 	headBlockGauge       = prometheus.GetOrCreateCounter("chain_head_block", true)
+	finalBlockGauge      = prometheus.GetOrCreateCounter("chain_head_finalized", true)
 	blockInsertTimer     = prometheus.GetOrCreateHistogram("chain_inserts")
 	blockValidationTimer = prometheus.GetOrCreateHistogram("chain_validation")
 	blockExecutionTimer  = prometheus.GetOrCreateHistogram("chain_execution")
 	blockWriteTimer      = prometheus.GetOrCreateHistogram("chain_write")
+	reorgCounter         = prometheus.GetOrCreateCounter("chain_reorg_executes")
+	reorgHaltedCounter   = prometheus.GetOrCreateCounter("chain_reorg_halted")
+	reorgDepthGauge      = prometheus.GetOrCreateCounter("chain_reorg_depth", true)
 )
 
 type WriteStatus byte
@@ -83,6 +91,7 @@ const (
 	headerCacheLimit = 1024
 	tdCacheLimit     = 1024
 	numberCacheLimit = 2048
+	badBlockLimit    = 10
 )
 
 type BlockChain struct {
@@ -93,6 +102,8 @@ type BlockChain struct {
 	blocks       []block2.IBlock
 	headers      []block2.IHeader
 	currentBlock atomic.Pointer[block2.Block]
+	finalBlock   atomic.Pointer[block2.Header] // Block of the "finalized" tag, nil if none
+	safeBlock    atomic.Pointer[block2.Header] // Block of the "safe" tag, nil if none
 	//state        *statedb.StateDB
 	ChainDB kv.RwDB
 	engine  consensus.Engine
@@ -122,6 +133,10 @@ type BlockChain struct {
 	numberCache *lru.Cache[types.Hash, uint64]
 	tdCache     *lru.Cache[types.Hash, *uint256.Int]
 
+	badBlocks        *lru.Cache[types.Hash, *block2.BadBlock]
+	persistBadBlocks bool
+	maxReorgDepth    uint64
+
 	forker    *ForkChoice
 	validator Validator
 }
@@ -143,12 +158,17 @@ func (bc *BlockChain) Engine() consensus.Engine {
 
 func NewBlockChain(ctx context.Context, genesisBlock block2.IBlock, engine consensus.Engine, db kv.RwDB, p2p p2p.P2P, config *params.ChainConfig) (common.IBlockChain, error) {
 	c, cancel := context.WithCancel(ctx)
-	var current *block2.Block
+	var (
+		current     *block2.Block
+		final, safe *block2.Header
+	)
 	_ = db.View(c, func(tx kv.Tx) error {
 		current = rawdb.ReadCurrentBlock(tx)
 		if current == nil {
 			current = genesisBlock.(*block2.Block)
 		}
+		final = readHeaderByHash(tx, rawdb.ReadFinalizedBlockHash(tx))
+		safe = readHeaderByHash(tx, rawdb.ReadSafeBlockHash(tx))
 		return nil
 	})
 
@@ -158,6 +178,7 @@ func NewBlockChain(ctx context.Context, genesisBlock block2.IBlock, engine conse
 	tdCache, _ := lru.New[types.Hash, *uint256.Int](tdCacheLimit)
 	numberCache, _ := lru.New[types.Hash, uint64](numberCacheLimit)
 	headerCache, _ := lru.New[types.Hash, *block2.Header](headerCacheLimit)
+	badBlocks, _ := lru.New[types.Hash, *block2.BadBlock](badBlockLimit)
 	bc := &BlockChain{
 		chainConfig:  config, // Chain & network configuration
 		genesisBlock: genesisBlock,
@@ -180,9 +201,12 @@ func NewBlockChain(ctx context.Context, genesisBlock block2.IBlock, engine conse
 
 		numberCache: numberCache,
 		headerCache: headerCache,
+		badBlocks:   badBlocks,
 	}
 
 	bc.currentBlock.Store(current)
+	bc.finalBlock.Store(final)
+	bc.safeBlock.Store(safe)
 	headBlockGauge.Set(current.Number64().Uint64())
 	bc.forker = NewForkChoice(bc, nil)
 	//bc.process = avm.NewVMProcessor(ctx, bc, engine)
@@ -216,6 +240,105 @@ func (bc *BlockChain) CurrentBlock() block2.IBlock {
 	return bc.currentBlock.Load()
 }
 
+// CurrentFinalBlock returns the header of the finalized block, nil if none was
+// finalized yet.
+func (bc *BlockChain) CurrentFinalBlock() block2.IHeader {
+	if header := bc.finalBlock.Load(); header != nil {
+		return header
+	}
+	return nil
+}
+
+// CurrentSafeBlock returns the header of the safe block, nil if none is known
+// yet.
+func (bc *BlockChain) CurrentSafeBlock() block2.IHeader {
+	if header := bc.safeBlock.Load(); header != nil {
+		return header
+	}
+	return nil
+}
+
+// SetFinality moves the finalized and safe blocks forward to the canonical
+// blocks final and safe, as told by the consensus engine or the forkchoice
+// updates of a consensus client. A zero hash leaves the block as it is, and
+// neither of them is ever moved back.
+func (bc *BlockChain) SetFinality(final, safe types.Hash) error {
+	var finalHeader, safeHeader *block2.Header
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		var err error
+		if finalHeader, err = advanceFinality(tx, final, bc.finalBlock.Load(), rawdb.WriteFinalizedBlockHash); err != nil {
+			return err
+		}
+		safeHeader, err = advanceFinality(tx, safe, bc.safeBlock.Load(), rawdb.WriteSafeBlockHash)
+		return err
+	}); err != nil {
+		return err
+	}
+	if finalHeader != nil {
+		bc.finalBlock.Store(finalHeader)
+		finalBlockGauge.Set(finalHeader.Number64().Uint64())
+	}
+	if safeHeader != nil {
+		bc.safeBlock.Store(safeHeader)
+	}
+	return nil
+}
+
+// advanceFinality writes hash as the finalized or safe block with write if it
+// is the hash of a canonical block above current, and returns its header.
+func advanceFinality(tx kv.RwTx, hash types.Hash, current *block2.Header, write func(kv.Putter, types.Hash) error) (*block2.Header, error) {
+	if hash == (types.Hash{}) || current != nil && current.Hash() == hash {
+		return nil, nil
+	}
+	header := readHeaderByHash(tx, hash)
+	if header == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	number := header.Number64().Uint64()
+	if canonical, err := rawdb.ReadCanonicalHash(tx, number); err != nil {
+		return nil, err
+	} else if canonical != hash {
+		return nil, fmt.Errorf("block %d %x is not canonical", number, hash)
+	}
+	if current != nil && number < current.Number64().Uint64() {
+		return nil, nil
+	}
+	return header, write(tx, hash)
+}
+
+// readHeaderByHash reads the header of the block hash, nil if unknown.
+func readHeaderByHash(tx kv.Getter, hash types.Hash) *block2.Header {
+	if hash == (types.Hash{}) {
+		return nil
+	}
+	number := rawdb.ReadHeaderNumber(tx, hash)
+	if number == nil {
+		return nil
+	}
+	return rawdb.ReadHeader(tx, hash, *number)
+}
+
+// updateFinality moves the finalized and safe blocks forward to the ones the
+// consensus engine tells are final below head, if it does.
+func (bc *BlockChain) updateFinality(head block2.IBlock) {
+	engine, ok := bc.engine.(consensus.Finality)
+	if !ok {
+		return
+	}
+	final, safe, ok := engine.Finalized(bc, head.Header())
+	if !ok {
+		return
+	}
+	finalHeader := bc.GetHeaderByNumber(uint256.NewInt(final))
+	safeHeader := bc.GetHeaderByNumber(uint256.NewInt(safe))
+	if finalHeader == nil || safeHeader == nil {
+		return
+	}
+	if err := bc.SetFinality(finalHeader.Hash(), safeHeader.Hash()); err != nil {
+		log.Warn("Failed to update the finalized block", "number", final, "err", err)
+	}
+}
+
 func (bc *BlockChain) Blocks() []block2.IBlock {
 	return bc.blocks
 }
@@ -809,6 +932,16 @@ func (bc *BlockChain) skipBlock(err error) bool {
 
 // InsertChain
 func (bc *BlockChain) InsertChain(chain []block2.IBlock) (int, error) {
+	return bc.insertContiguousChain(chain, true)
+}
+
+// InsertChainWithoutSealVerification works like InsertChain, but does not
+// verify the seals of the blocks, which are trusted to come from the chain.
+func (bc *BlockChain) InsertChainWithoutSealVerification(chain []block2.IBlock) (int, error) {
+	return bc.insertContiguousChain(chain, false)
+}
+
+func (bc *BlockChain) insertContiguousChain(chain []block2.IBlock, verifySeals bool) (int, error) {
 	if len(chain) == 0 {
 		return 0, nil
 	}
@@ -829,10 +962,10 @@ func (bc *BlockChain) InsertChain(chain []block2.IBlock) (int, error) {
 	}
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
-	return bc.insertChain(chain)
+	return bc.insertChain(chain, verifySeals)
 }
 
-func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
+func (bc *BlockChain) insertChain(chain []block2.IBlock, verifySeals bool) (int, error) {
 	if bc.insertStopped() {
 		return 0, nil
 	}
@@ -855,11 +988,19 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 
 	for i, block := range chain {
 		headers[i] = block.Header()
-		seals[i] = true
+		seals[i] = verifySeals
 	}
 	abort, results := bc.engine.VerifyHeaders(bc, headers, seals)
 	defer close(abort)
 
+	// Recover the senders of the whole batch on the workers while the headers
+	// are verified, later lookups of the senders then find them cached.
+	var txs []*transaction.Transaction
+	for _, block := range chain {
+		txs = append(txs, block.Transactions()...)
+	}
+	transaction.SenderCacher.Recover(transaction.MakeSigner(bc.chainConfig, chain[len(chain)-1].Number64().ToBig()), txs)
+
 	// Peek the error for the first block to decide the directing import logic
 	it := newInsertIterator(chain, results, bc.validator)
 	block, err := it.next()
@@ -933,7 +1074,7 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 	case err != nil && !errors.Is(err, ErrKnownBlock):
 		bc.futureBlocks.Remove(block.Hash())
 		stats.ignored += len(it.chain)
-		bc.reportBlock(block, nil, err)
+		bc.reportBlock(block, nil, "body", err)
 		return it.index, err
 	}
 
@@ -1002,10 +1143,20 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 		//}
 		//ibs := state.New(stateReader)
 
+		ctx, span := trace.StartSpan(bc.ctx, "chain.insertBlock")
+		span.AddAttributes(
+			trace.Int64Attribute("number", int64(block.Number64().Uint64())),
+			trace.StringAttribute("hash", block.Hash().String()),
+			trace.Int64Attribute("txs", int64(len(block.Transactions()))),
+		)
+
 		var receipts block2.Receipts
 		var logs []*block2.Log
 		var usedGas uint64
-		ibs, nopay, err := evmRecord(bc.ctx, bc.ChainDB, block.Number64().Uint64(), func(tx kv.Tx, ibs *state.IntraBlockState, reader state.StateReader, writer state.WriterWithChangeSets) (map[types.Address]*uint256.Int, error) {
+		ibs, nopay, err := evmRecord(ctx, bc.ChainDB, block.Number64().Uint64(), func(tx kv.Tx, ibs *state.IntraBlockState, reader state.StateReader, writer state.WriterWithChangeSets) (map[types.Address]*uint256.Int, error) {
+			_, span := trace.StartSpan(ctx, "chain.execute")
+			defer span.End()
+
 			getHeader := func(hash types.Hash, number uint64) *block2.Header {
 				return rawdb.ReadHeader(tx, hash, number)
 			}
@@ -1017,7 +1168,7 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 			pstart := time.Now()
 			receipts, nopay, logs, usedGas, err = bc.process.Process(block.(*block2.Block), ibs, reader, writer, blockHashFunc)
 			if err != nil {
-				bc.reportBlock(block, receipts, err)
+				bc.reportBlock(block, receipts, "execution", err)
 				//atomic.StoreUint32(&followupInterrupt, 1)
 				return nil, err
 			}
@@ -1025,7 +1176,7 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 			vstart := time.Now()
 
 			if err := bc.validator.ValidateState(block, ibs, receipts, usedGas); err != nil {
-				bc.reportBlock(block, receipts, err)
+				bc.reportBlock(block, receipts, "state", err)
 				//atomic.StoreUint32(&followupInterrupt, 1)
 				return nil, err
 			}
@@ -1036,6 +1187,8 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 			return nopay, nil
 		})
 		if nil != err {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: err.Error()})
+			span.End()
 			return it.index, err
 		}
 		//var followupInterrupt uint32
@@ -1064,11 +1217,16 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 		//}
 		wstart := time.Now()
 		var status WriteStatus
+		_, writeSpan := trace.StartSpan(ctx, "chain.write")
 		status, err = bc.writeBlockWithState(block, receipts, ibs, nopay)
+		writeSpan.End()
 		//atomic.StoreUint32(&followupInterrupt, 1)
 		if err != nil {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: err.Error()})
+			span.End()
 			return it.index, err
 		}
+		span.End()
 		blockWriteTimer.Observe(float64(time.Since(wstart)))
 		blockInsertTimer.Observe(float64(time.Since(start)))
 		// Report the import stats before returning the various results
@@ -1222,7 +1380,7 @@ func (bc *BlockChain) insertSideChain(block block2.IBlock, it *insertIterator) (
 		// memory here.
 		if len(blocks) >= 2048 {
 			log.Info("Importing heavy sidechain segment", "blocks", len(blocks), "start", blocks[0].Number64(), "end", block.Number64())
-			if _, err := bc.insertChain(blocks); err != nil {
+			if _, err := bc.insertChain(blocks, true); err != nil {
 				return 0, err
 			}
 			blocks = blocks[:0]
@@ -1235,7 +1393,7 @@ func (bc *BlockChain) insertSideChain(block block2.IBlock, it *insertIterator) (
 	}
 	if len(blocks) > 0 {
 		log.Info("Importing sidechain segment", "start", blocks[0].Number64(), "end", blocks[len(blocks)-1].Number64())
-		return bc.insertChain(blocks)
+		return bc.insertChain(blocks, true)
 	}
 	return 0, nil
 }
@@ -1263,7 +1421,7 @@ func (bc *BlockChain) recoverAncestors(block block2.IBlock) (types.Hash, error)
 		} else {
 			b = bc.GetBlock(hashes[i], numbers[i].Uint64())
 		}
-		if _, err := bc.insertChain([]block2.IBlock{b}); err != nil {
+		if _, err := bc.insertChain([]block2.IBlock{b}, true); err != nil {
 			return b.ParentHash(), err
 		}
 	}
@@ -1293,7 +1451,8 @@ func (bc *BlockChain) WriteBlockWithState(block block2.IBlock, receipts []*block
 	return err
 }
 
-// writeBlockWithState
+// writeBlockWithState writes the block with its receipts and state, and makes
+// it the head block if it wins the fork choice, within a single transaction.
 func (bc *BlockChain) writeBlockWithState(block block2.IBlock, receipts []*block2.Receipt, ibs *state.IntraBlockState, nopay map[types.Address]*uint256.Int) (status WriteStatus, err error) {
 	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
 		//ptd := bc.GetTd(block.ParentHash(), block.Number64().Sub(uint256.NewInt(1)))
@@ -1305,22 +1464,11 @@ func (bc *BlockChain) writeBlockWithState(block block2.IBlock, receipts []*block
 			return consensus.ErrUnknownAncestor
 		}
 
-		//if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
 		externTd := uint256.NewInt(0).Add(ptd, block.Difficulty())
-		if err := rawdb.WriteTd(tx, block.Hash(), block.Number64().Uint64(), externTd); nil != err {
+		if err := rawdb.WriteBlockWithReceipts(tx, block.(*block2.Block), receipts, externTd, false); err != nil {
 			return err
 		}
 		log.Trace("writeTd:", "number", block.Number64().Uint64(), "hash", block.Hash(), "td", externTd.Uint64())
-		if len(receipts) > 0 {
-			//if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
-			if err := rawdb.AppendReceipts(tx, block.Number64().Uint64(), receipts); nil != err {
-				log.Errorf("rawdb.AppendReceipts failed err= %v", err)
-				return err
-			}
-		}
-		if err := rawdb.WriteBlock(tx, block.(*block2.Block)); err != nil {
-			return err
-		}
 
 		stateWriter := state.NewPlainStateWriter(tx, tx, block.Number64().Uint64())
 		if err := ibs.CommitBlock(bc.chainConfig.Rules(block.Number64().Uint64()), stateWriter); nil != err {
@@ -1335,38 +1483,38 @@ func (bc *BlockChain) writeBlockWithState(block block2.IBlock, receipts []*block
 			return fmt.Errorf("writing history for block %d failed: %w", block.Number64().Uint64(), err)
 		}
 
-		if nil != nopay {
-			for addr, v := range nopay {
-				rawdb.PutAccountReward(tx, addr, v)
-			}
+		if err := rawdb.WriteAccountRewards(tx, block.Number64().Uint64(), nopay); err != nil {
+			return err
 		}
 
-		return nil
-	}); nil != err {
-		return NonStatTy, err
-	}
-
-	reorg, err := bc.forker.ReorgNeeded(bc.CurrentBlock().Header(), block.Header())
-	if nil != err {
-		return NonStatTy, err
-	}
-	if reorg {
+		// The fork choice reads the total difficulty through the cache, the
+		// one just written is not visible outside tx until it is committed.
+		bc.tdCache.Add(block.Hash(), externTd)
+		reorg, err := bc.forker.ReorgNeeded(bc.CurrentBlock().Header(), block.Header())
+		if nil != err {
+			return err
+		}
+		if !reorg {
+			status = SideStatTy
+			return nil
+		}
 		// Reorganise the chain if the parent is not the head block
 		if block.ParentHash() != bc.CurrentBlock().Hash() {
-			if err := bc.reorg(nil, bc.CurrentBlock(), block); err != nil {
-				return NonStatTy, err
+			if err := bc.reorg(tx, bc.CurrentBlock(), block); err != nil {
+				return err
 			}
 		}
 		status = CanonStatTy
-	} else {
-		status = SideStatTy
+		return rawdb.WriteCanonicalBlock(tx, block.(*block2.Block))
+	}); nil != err {
+		return NonStatTy, err
 	}
+
 	// Set new head.
 	if status == CanonStatTy {
-		if err := bc.writeHeadBlock(nil, block); nil != err {
-			log.Errorf("failed to save lates blocks, err: %v", err)
-			return NonStatTy, err
-		}
+		bc.currentBlock.Store(block.(*block2.Block))
+		headBlockGauge.Set(block.Number64().Uint64())
+		bc.updateFinality(block)
 	}
 	//
 	if _, ok := bc.futureBlocks.Get(block.Hash()); ok {
@@ -1388,15 +1536,7 @@ func (bc *BlockChain) writeHeadBlock(tx kv.RwTx, block block2.IBlock) error {
 		notExternalTx = true
 	}
 
-	//if err := rawdb.WriteBlock(tx, block.(*block2.Block)); nil != err {
-	//	log.Errorf("failed to save last block, err: %v", err)
-	//	return err
-	//}
-	rawdb.WriteHeadBlockHash(tx, block.Hash())
-	rawdb.WriteHeadBlockHash(tx, block.Hash())
-	rawdb.WriteTxLookupEntries(tx, block.(*block2.Block))
-
-	if err = rawdb.WriteCanonicalHash(tx, block.Hash(), block.Number64().Uint64()); nil != err {
+	if err = rawdb.WriteCanonicalBlock(tx, block.(*block2.Block)); nil != err {
 		return err
 	}
 
@@ -1410,8 +1550,10 @@ func (bc *BlockChain) writeHeadBlock(tx kv.RwTx, block block2.IBlock) error {
 	return nil
 }
 
-// reportBlock logs a bad block error.
-func (bc *BlockChain) reportBlock(block block2.IBlock, receipts []*block2.Receipt, err error) {
+// reportBlock logs a bad block error and records the block for
+// debug_getBadBlocks.
+func (bc *BlockChain) reportBlock(block block2.IBlock, receipts []*block2.Receipt, stage string, err error) {
+	bc.addBadBlock(block, stage, err)
 
 	var receiptString string
 	for i, receipt := range receipts {
@@ -1431,6 +1573,86 @@ Error: %v
 `, block.Number64().String(), block.Hash(), receiptString, err))
 }
 
+// addBadBlock remembers a block which failed validation in stage, and also
+// stores it in the database when persistence is enabled.
+func (bc *BlockChain) addBadBlock(b block2.IBlock, stage string, err error) {
+	bad := &block2.BadBlock{
+		Hash:   b.Hash(),
+		Number: b.Number64().Uint64(),
+		Stage:  stage,
+		Error:  err.Error(),
+		Head:   bc.CurrentBlock().Number64().Uint64(),
+		Time:   uint64(time.Now().Unix()),
+	}
+	if blk, ok := b.(*block2.Block); ok {
+		encoded, mErr := blk.Marshal()
+		if mErr != nil {
+			log.Warn("Failed to encode bad block", "hash", bad.Hash, "err", mErr)
+		}
+		bad.Encoded = encoded
+	}
+	bc.badBlocks.Add(bad.Hash, bad)
+
+	if !bc.persistBadBlocks {
+		return
+	}
+	if wErr := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		return rawdb.WriteBadBlock(tx, bad)
+	}); wErr != nil {
+		log.Warn("Failed to store bad block", "hash", bad.Hash, "err", wErr)
+	}
+}
+
+// SetPersistBadBlocks sets whether bad blocks are also stored in the database
+// so they survive a restart.
+func (bc *BlockChain) SetPersistBadBlocks(persist bool) {
+	bc.persistBadBlocks = persist
+}
+
+// SetMaxReorgDepth sets the most blocks of the canonical chain a reorg may
+// drop, zero for no limit. Deeper forks are refused and left to the operator.
+func (bc *BlockChain) SetMaxReorgDepth(depth uint64) {
+	bc.maxReorgDepth = depth
+}
+
+// SetExecWorkers sets the number of workers executing the transactions of an
+// imported block optimistically in parallel, at most one executes them
+// serially.
+func (bc *BlockChain) SetExecWorkers(workers int) {
+	if p, ok := bc.process.(*StateProcessor); ok {
+		p.SetWorkers(workers)
+	}
+}
+
+// BadBlocks returns the blocks which recently failed validation, highest
+// number first. With persistence enabled the stored ones are included.
+func (bc *BlockChain) BadBlocks() []*block2.BadBlock {
+	seen := make(map[types.Hash]struct{})
+	var bad []*block2.BadBlock
+	for _, hash := range bc.badBlocks.Keys() {
+		if b, ok := bc.badBlocks.Peek(hash); ok {
+			bad = append(bad, b)
+			seen[hash] = struct{}{}
+		}
+	}
+	if bc.persistBadBlocks {
+		_ = bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+			stored, err := rawdb.ReadAllBadBlocks(tx)
+			if err != nil {
+				return err
+			}
+			for _, b := range stored {
+				if _, ok := seen[b.Hash]; !ok {
+					bad = append(bad, b)
+				}
+			}
+			return nil
+		})
+	}
+	sort.SliceStable(bad, func(i, j int) bool { return bad[i].Number > bad[j].Number })
+	return bad
+}
+
 // ReorgNeeded
 func (bc *BlockChain) ReorgNeeded(current block2.IBlock, header block2.IBlock) bool {
 	switch current.Number64().Cmp(header.Number64()) {
@@ -1442,15 +1664,143 @@ func (bc *BlockChain) ReorgNeeded(current block2.IBlock, header block2.IBlock) b
 	return true
 }
 
-// SetHead set new head
+// SetHead rewinds the canonical chain to block head. The state is unwound
+// to how it was after head, and all blocks above it are deleted so they are
+// fetched and executed again, and the unpaid account rewards are restored
+// with the state. It fails with rawdb.ErrPruned if the history of the blocks
+// above head was pruned.
 func (bc *BlockChain) SetHead(head uint64) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	current := bc.CurrentBlock().Number64().Uint64()
+	if head >= current {
+		return fmt.Errorf("cannot rewind to block %d, current head is %d", head, current)
+	}
 	newHeadBlock, err := bc.GetBlockByNumber(uint256.NewInt(head))
 	if err != nil {
-		return nil
+		return err
 	}
-	return bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+	if newHeadBlock == nil {
+		return fmt.Errorf("block %d not found", head)
+	}
+
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		// The state is unwound with the changesets of the blocks above head.
+		if err := rawdb.CheckPruned(tx, rawdb.PruneHistory, head+1); err != nil {
+			return err
+		}
+		for n := head + 1; n <= current; n++ {
+			b, err := rawdb.ReadBlockByNumber(tx, n)
+			if err != nil {
+				return err
+			}
+			if b == nil {
+				continue
+			}
+			for _, t := range b.Transactions() {
+				if err := rawdb.DeleteTxLookupEntry(tx, t.Hash()); err != nil {
+					return err
+				}
+			}
+		}
+		if err := state.UnwindPlainState(tx, head); err != nil {
+			return fmt.Errorf("unwind state: %w", err)
+		}
+		if err := rawdb.UnwindAccountRewards(tx, head+1); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateReceipts(tx, head+1); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateCanonicalHash(tx, head+1, false); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateTd(tx, head+1); err != nil {
+			return err
+		}
+		if err := tx.ForEach(modules.Headers, modules.EncodeBlockNumber(head+1), func(k, _ []byte) error {
+			rawdb.DeleteHeaderNumber(tx, types.BytesToHash(k[8:]))
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateBlocks(bc.ctx, tx, head+1); err != nil {
+			return err
+		}
+		// The finalized and safe blocks above head are gone, head takes
+		// their place.
+		if final := bc.finalBlock.Load(); final != nil && final.Number64().Uint64() > head {
+			if err := rawdb.WriteFinalizedBlockHash(tx, newHeadBlock.Hash()); err != nil {
+				return err
+			}
+		}
+		if safe := bc.safeBlock.Load(); safe != nil && safe.Number64().Uint64() > head {
+			if err := rawdb.WriteSafeBlockHash(tx, newHeadBlock.Hash()); err != nil {
+				return err
+			}
+		}
+		rawdb.WriteHeadBlockHash(tx, newHeadBlock.Hash())
 		return rawdb.WriteHeadHeaderHash(tx, newHeadBlock.Hash())
-	})
+	}); err != nil {
+		return err
+	}
+
+	bc.currentBlock.Store(newHeadBlock.(*block2.Block))
+	headBlockGauge.Set(head)
+	if final := bc.finalBlock.Load(); final != nil && final.Number64().Uint64() > head {
+		bc.finalBlock.Store(newHeadBlock.Header().(*block2.Header))
+		finalBlockGauge.Set(head)
+	}
+	if safe := bc.safeBlock.Load(); safe != nil && safe.Number64().Uint64() > head {
+		bc.safeBlock.Store(newHeadBlock.Header().(*block2.Header))
+	}
+	bc.blockCache.Purge()
+	bc.headerCache.Purge()
+	bc.numberCache.Purge()
+	bc.tdCache.Purge()
+	bc.receiptCache.Purge()
+	bc.futureBlocks.Purge()
+	rawdb.PurgeCaches()
+	log.Warn("Rewound chain", "from", current, "to", head, "hash", newHeadBlock.Hash())
+	return nil
+}
+
+// CommitSnapHead makes the canonical block hash the head of the chain once
+// snap sync stored it with its state, total difficulty and ancestors, without
+// executing it.
+func (bc *BlockChain) CommitSnapHead(hash types.Hash) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	var head *block2.Block
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		number := rawdb.ReadHeaderNumber(tx, hash)
+		if number == nil {
+			return fmt.Errorf("block %x not found", hash)
+		}
+		b := rawdb.ReadBlock(tx, hash, *number)
+		if b == nil {
+			return fmt.Errorf("block %d %x not found", *number, hash)
+		}
+		head = b
+		rawdb.WriteHeadBlockHash(tx, hash)
+		return rawdb.WriteHeadHeaderHash(tx, hash)
+	}); err != nil {
+		return err
+	}
+
+	bc.currentBlock.Store(head)
+	headBlockGauge.Set(head.Number64().Uint64())
+	bc.blockCache.Purge()
+	bc.headerCache.Purge()
+	bc.numberCache.Purge()
+	bc.tdCache.Purge()
+	bc.receiptCache.Purge()
+	bc.futureBlocks.Purge()
+	rawdb.PurgeCaches()
+	log.Info("Committed snap sync head", "number", head.Number64().Uint64(), "hash", hash)
+	return nil
 }
 
 // AddFutureBlock checks if the block is within the max allowed window to get
@@ -1501,6 +1851,7 @@ func (bc *BlockChain) writeKnownBlock(tx kv.RwTx, block block2.IBlock) error {
 		if err = tx.Commit(); nil != err {
 			return err
 		}
+		bc.updateFinality(block)
 	}
 	return nil
 }
@@ -1542,7 +1893,15 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		return fmt.Errorf("invalid new chain")
 	}
 
-	var useExternalTx bool
+	var (
+		oldHead = oldBlock.Hash()
+		newHead = newBlock.Hash()
+	)
+	if len(newChain) > 0 {
+		newHead = newChain[0].Hash()
+	}
+
+	useExternalTx := true
 	var err error
 	if tx == nil {
 		tx, err = bc.ChainDB.BeginRw(bc.ctx)
@@ -1582,6 +1941,17 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		}
 	}
 
+	// Refuse forks dropping more blocks than allowed, whatever their weight:
+	// they are more likely an attack or a bug than a legitimate chain.
+	depth := uint64(len(oldChain))
+	if bc.maxReorgDepth > 0 && depth > bc.maxReorgDepth {
+		log.Error("Deep chain reorg refused, manual intervention required", "number", commonBlock.Number64(), "hash", commonBlock.Hash(),
+			"depth", depth, "limit", bc.maxReorgDepth, "oldhead", oldHead, "newhead", newHead)
+		reorgHaltedCounter.Inc()
+		event.GlobalEvent.Send(common.ChainReorgEvent{OldHead: oldHead, NewHead: newHead, Depth: depth, Halted: true})
+		return fmt.Errorf("%w: %d blocks, limit %d", ErrReorgTooDeep, depth, bc.maxReorgDepth)
+	}
+
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Info
@@ -1620,6 +1990,9 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 	for _, t := range types.HashDifference(deletedTxs, addedTxs) {
 		rawdb.DeleteTxLookupEntry(tx, t)
 	}
+	for _, b := range oldChain {
+		rawdb.EvictCachedBlock(b.Hash())
+	}
 
 	// Delete all hash markers that are not part of the new canonical chain.
 	// Because the reorg function does not handle new chain head, all hash
@@ -1642,10 +2015,21 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		}
 	}
 
+	if depth > 0 {
+		reorgCounter.Inc()
+		reorgDepthGauge.Set(depth)
+		event.GlobalEvent.Send(common.ChainReorgEvent{OldHead: oldHead, NewHead: newHead, Depth: depth})
+	}
 	return nil
 }
+
+// Close stops the chain. Blocks not yet imported are abandoned, but the one
+// being written is allowed to finish, so Close blocks until it is done.
 func (bc *BlockChain) Close() error {
-	bc.Quit()
+	bc.StopInsert()
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	bc.cancel()
 	return nil
 }
 
@@ -1662,6 +2046,89 @@ func (bc *BlockChain) StateAt(tx kv.Tx, blockNr uint64) *state.IntraBlockState {
 	return state.New(reader)
 }
 
+// RegenerateReceipts executes the block again on the state of its parent,
+// read from the state history, and returns its receipts once their gas, bloom
+// and root match the header. The state is left untouched.
+func (bc *BlockChain) RegenerateReceipts(tx kv.Tx, b *block2.Block) (block2.Receipts, error) {
+	number := b.Number64().Uint64()
+	if number == 0 {
+		return nil, nil
+	}
+	if err := rawdb.CheckPruned(tx, rawdb.PruneHistory, number); err != nil {
+		return nil, fmt.Errorf("state of parent block: %w", err)
+	}
+	getHeader := func(hash types.Hash, number uint64) *block2.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	reader := state.NewPlainState(tx, number)
+	receipts, _, _, usedGas, err := bc.process.Process(b, state.New(reader), reader, state.NewNoopWriter(), GetHashFn(b.Header().(*block2.Header), getHeader))
+	if err != nil {
+		return nil, err
+	}
+	header := b.Header().(*block2.Header)
+	if usedGas != header.GasUsed {
+		return nil, fmt.Errorf("invalid gas used (remote: %d local: %d)", header.GasUsed, usedGas)
+	}
+	if bloom := block2.CreateBloom(receipts); bloom != header.Bloom {
+		return nil, fmt.Errorf("invalid bloom (remote: %x local: %x)", header.Bloom, bloom)
+	}
+	if root := DeriveSha(receipts); root != header.ReceiptHash {
+		return nil, fmt.Errorf("invalid receipt root hash (remote: %x local: %x)", header.ReceiptHash, root)
+	}
+	return receipts, nil
+}
+
+// ExecutionWitness executes the block again on the state of its parent, read
+// from the state history, while recording the accounts, storage and codes it
+// reads and the headers of the hashes it looks up. The witness is returned as
+// the mined blocks stateless verifiers consume, the proof being the hash of the
+// state read. The state is left untouched.
+func (bc *BlockChain) ExecutionWitness(tx kv.Tx, b *block2.Block) (*state.EntireCode, error) {
+	number := b.Number64().Uint64()
+	if number == 0 {
+		return nil, errors.New("genesis is not executed")
+	}
+	if err := rawdb.CheckPruned(tx, rawdb.PruneHistory, number); err != nil {
+		return nil, fmt.Errorf("state of parent block: %w", err)
+	}
+	var headers []*block2.Header
+	getHeader := func(hash types.Hash, number uint64) *block2.Header {
+		h := rawdb.ReadHeader(tx, hash, number)
+		if h != nil {
+			headers = append(headers, h)
+		}
+		return h
+	}
+	reader := state.NewPlainState(tx, number)
+	ibs := state.New(reader)
+	ibs.BeginWriteSnapshot()
+	ibs.BeginWriteCodes()
+	if _, _, _, _, err := bc.process.Process(b, ibs, reader, state.NewNoopWriter(), GetHashFn(b.Header().(*block2.Header), getHeader)); err != nil {
+		return nil, err
+	}
+
+	txs := make([][]byte, len(b.Transactions()))
+	for i, t := range b.Transactions() {
+		var err error
+		if txs[i], err = t.Marshal(); err != nil {
+			return nil, err
+		}
+	}
+	proof := ibs.BeforeStateRoot()
+	codes := make(state.HashCodes, 0, len(ibs.CodeHashes()))
+	for hash, code := range ibs.CodeHashes() {
+		codes = append(codes, &state.HashCode{Hash: hash, Code: code})
+	}
+	sort.Sort(codes)
+	header := b.Header().(*block2.Header)
+	return &state.EntireCode{
+		CoinBase: header.Coinbase,
+		Entire:   state.Entire{Header: header, Transactions: txs, Snap: ibs.Snap(), Proof: proof},
+		Codes:    codes,
+		Headers:  headers,
+	}, nil
+}
+
 func (bc *BlockChain) GetDepositInfo(address types.Address) (*uint256.Int, *uint256.Int) {
 	var info *deposit.Info
 	bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
@@ -0,0 +1,572 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package catalyst implements the engine namespace through which an external
+// consensus client drives the node. Payloads are imported through the
+// configured consensus engine, so they are only accepted on chains whose
+// engine does not require sealed headers.
+package catalyst
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/miner"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+)
+
+const (
+	// maxPayloads is the number of built payloads kept for getPayload.
+	maxPayloads = 10
+
+	// maxSideBlocks is the number of payloads off the canonical chain kept
+	// until a forkchoice update makes them canonical.
+	maxSideBlocks = 256
+
+	// beaconUpdateTimeout is how long a consensus client may stay silent
+	// before the node warns about it.
+	beaconUpdateTimeout = 30 * time.Second
+
+	// beaconUpdateWarnFrequency is the interval between repeated warnings
+	// about a silent consensus client.
+	beaconUpdateWarnFrequency = 5 * time.Minute
+)
+
+// caps are the engine API methods served by ConsensusAPI.
+var caps = []string{
+	"engine_forkchoiceUpdatedV1",
+	"engine_forkchoiceUpdatedV2",
+	"engine_forkchoiceUpdatedV3",
+	"engine_newPayloadV1",
+	"engine_newPayloadV2",
+	"engine_newPayloadV3",
+	"engine_getPayloadV1",
+	"engine_getPayloadV2",
+	"engine_getPayloadV3",
+	"engine_exchangeCapabilities",
+}
+
+// payload is a block built for a consensus client.
+type payload struct {
+	id       PayloadID
+	block    block.IBlock
+	receipts block.Receipts
+	version  int
+}
+
+// ConsensusAPI implements the engine namespace.
+type ConsensusAPI struct {
+	bc    common.IBlockChain
+	miner *miner.Miner
+
+	mu         sync.Mutex
+	payloads   []*payload                  // Recently built payloads, oldest first
+	sideBlocks map[types.Hash]block.IBlock // Payloads off the canonical chain by hash
+	sideOrder  []types.Hash                // Hashes of sideBlocks, oldest first
+
+	lastCall       atomic.Int64 // Time of the last engine API call in unix nanoseconds
+	lastForkchoice atomic.Int64 // Time of the last forkchoice update in unix nanoseconds
+}
+
+// NewConsensusAPI creates a new engine namespace service.
+func NewConsensusAPI(bc common.IBlockChain, miner *miner.Miner) *ConsensusAPI {
+	api := &ConsensusAPI{bc: bc, miner: miner, sideBlocks: make(map[types.Hash]block.IBlock)}
+	go api.heartbeat()
+	return api
+}
+
+// APIs returns the RPC services of the engine namespace, which are only
+// served over the authenticated endpoint.
+func APIs(bc common.IBlockChain, miner *miner.Miner) []jsonrpc.API {
+	return []jsonrpc.API{
+		{
+			Namespace:     "engine",
+			Service:       NewConsensusAPI(bc, miner),
+			Authenticated: true,
+		},
+	}
+}
+
+// ExchangeCapabilities returns the engine API methods supported by the node.
+func (api *ConsensusAPI) ExchangeCapabilities([]string) []string {
+	api.lastCall.Store(time.Now().UnixNano())
+	return caps
+}
+
+// ForkchoiceUpdatedV1 updates the head of the chain and starts building a
+// payload if attributes are given.
+func (api *ConsensusAPI) ForkchoiceUpdatedV1(update ForkchoiceStateV1, attr *PayloadAttributes) (ForkChoiceResponse, error) {
+	if attr != nil {
+		if attr.Withdrawals != nil || attr.BeaconRoot != nil {
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("withdrawals and beacon root not supported in V1"))
+		}
+		if api.bc.Config().IsShanghai(api.nextNumber()) {
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("forkchoiceUpdatedV1 called post-shanghai"))
+		}
+	}
+	return api.forkchoiceUpdated(update, attr, 1)
+}
+
+// ForkchoiceUpdatedV2 is ForkchoiceUpdatedV1 with withdrawals in the payload
+// attributes after Shanghai.
+func (api *ConsensusAPI) ForkchoiceUpdatedV2(update ForkchoiceStateV1, attr *PayloadAttributes) (ForkChoiceResponse, error) {
+	if attr != nil {
+		if attr.BeaconRoot != nil {
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("unexpected beacon root"))
+		}
+		shanghai := api.bc.Config().IsShanghai(api.nextNumber())
+		switch {
+		case shanghai && attr.Withdrawals == nil:
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("missing withdrawals"))
+		case !shanghai && attr.Withdrawals != nil:
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("withdrawals before shanghai"))
+		case api.bc.Config().IsCancun(api.nextNumber()):
+			return ForkChoiceResponse{}, UnsupportedFork.With(errors.New("forkchoiceUpdatedV2 called post-cancun"))
+		}
+	}
+	return api.forkchoiceUpdated(update, attr, 2)
+}
+
+// ForkchoiceUpdatedV3 is ForkchoiceUpdatedV2 with the parent beacon block
+// root in the payload attributes after Cancun.
+func (api *ConsensusAPI) ForkchoiceUpdatedV3(update ForkchoiceStateV1, attr *PayloadAttributes) (ForkChoiceResponse, error) {
+	if attr != nil {
+		switch {
+		case attr.Withdrawals == nil:
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("missing withdrawals"))
+		case attr.BeaconRoot == nil:
+			return ForkChoiceResponse{}, InvalidParams.With(errors.New("missing beacon root"))
+		case !api.bc.Config().IsCancun(api.nextNumber()):
+			return ForkChoiceResponse{}, UnsupportedFork.With(errors.New("forkchoiceUpdatedV3 called pre-cancun"))
+		}
+	}
+	return api.forkchoiceUpdated(update, attr, 3)
+}
+
+func (api *ConsensusAPI) forkchoiceUpdated(update ForkchoiceStateV1, attr *PayloadAttributes, version int) (ForkChoiceResponse, error) {
+	now := time.Now().UnixNano()
+	api.lastCall.Store(now)
+	api.lastForkchoice.Store(now)
+
+	if update.HeadBlockHash == (types.Hash{}) {
+		log.Warn("Forkchoice requested update to zero hash")
+		return ForkChoiceResponse{PayloadStatus: PayloadStatusV1{Status: INVALID}}, nil
+	}
+	head := api.getBlock(update.HeadBlockHash)
+	if head == nil {
+		log.Debug("Forkchoice head unknown, waiting for payload", "hash", update.HeadBlockHash)
+		return ForkChoiceResponse{PayloadStatus: PayloadStatusV1{Status: SYNCING}}, nil
+	}
+	if head.Hash() != api.bc.CurrentBlock().Hash() {
+		if status, err := api.setHead(head); err != nil || status != nil {
+			var resp ForkChoiceResponse
+			if status != nil {
+				resp.PayloadStatus = *status
+			}
+			return resp, err
+		}
+	}
+	for _, h := range []types.Hash{update.SafeBlockHash, update.FinalizedBlockHash} {
+		if h == (types.Hash{}) {
+			continue
+		}
+		b, err := api.bc.GetBlockByHash(h)
+		if err != nil || b == nil || !api.isCanonical(b) {
+			return ForkChoiceResponse{}, InvalidForkChoiceState.With(fmt.Errorf("block %v is not on the canonical chain", h))
+		}
+	}
+	if err := api.bc.SetFinality(update.FinalizedBlockHash, update.SafeBlockHash); err != nil {
+		log.Warn("Failed to update the finalized block", "finalized", update.FinalizedBlockHash, "safe", update.SafeBlockHash, "err", err)
+	}
+
+	headHash := head.Hash()
+	valid := ForkChoiceResponse{PayloadStatus: PayloadStatusV1{Status: VALID, LatestValidHash: &headHash}}
+	if attr == nil {
+		return valid, nil
+	}
+	if len(attr.Withdrawals) > 0 {
+		return valid, InvalidPayloadAttributes.With(errors.New("withdrawals are not supported"))
+	}
+	if uint64(attr.Timestamp) <= head.Time() {
+		return valid, InvalidPayloadAttributes.With(fmt.Errorf("timestamp %d not after parent %d", attr.Timestamp, head.Time()))
+	}
+	if api.miner == nil {
+		return valid, InvalidPayloadAttributes.With(errors.New("block building is not available"))
+	}
+	id := payloadID(headHash, attr, version)
+	if api.getPayload(id) != nil {
+		valid.PayloadID = &id
+		return valid, nil
+	}
+	b, receipts, err := api.miner.BuildPayload(&miner.BuildPayloadArgs{
+		Parent:       headHash,
+		Timestamp:    uint64(attr.Timestamp),
+		FeeRecipient: attr.SuggestedFeeRecipient,
+		Random:       attr.Random,
+	})
+	if err != nil {
+		log.Error("Failed to build payload", "err", err)
+		return valid, InvalidPayloadAttributes.With(err)
+	}
+	api.putPayload(&payload{id: id, block: b, receipts: receipts, version: version})
+	valid.PayloadID = &id
+	return valid, nil
+}
+
+// GetPayloadV1 returns a payload built after a forkchoice update.
+func (api *ConsensusAPI) GetPayloadV1(id PayloadID) (*ExecutableData, error) {
+	p, err := api.payloadByID(id, 1)
+	if err != nil {
+		return nil, err
+	}
+	return blockToExecutableData(p.block, 1), nil
+}
+
+// GetPayloadV2 returns a payload built after a forkchoice update together
+// with the fees it pays.
+func (api *ConsensusAPI) GetPayloadV2(id PayloadID) (*ExecutionPayloadEnvelope, error) {
+	p, err := api.payloadByID(id, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	return api.envelope(p), nil
+}
+
+// GetPayloadV3 returns a payload built after a forkchoice update together
+// with the fees it pays and the blobs of its transactions.
+func (api *ConsensusAPI) GetPayloadV3(id PayloadID) (*ExecutionPayloadEnvelope, error) {
+	p, err := api.payloadByID(id, 3)
+	if err != nil {
+		return nil, err
+	}
+	env := api.envelope(p)
+	env.BlobsBundle = &BlobsBundleV1{
+		Commitments: []hexutil.Bytes{},
+		Proofs:      []hexutil.Bytes{},
+		Blobs:       []hexutil.Bytes{},
+	}
+	return env, nil
+}
+
+func (api *ConsensusAPI) envelope(p *payload) *ExecutionPayloadEnvelope {
+	return &ExecutionPayloadEnvelope{
+		ExecutionPayload: blockToExecutableData(p.block, p.version),
+		BlockValue:       (*hexutil.Big)(blockValue(p.block, p.receipts)),
+	}
+}
+
+// NewPayloadV1 validates and imports a payload.
+func (api *ConsensusAPI) NewPayloadV1(params ExecutableData) (PayloadStatusV1, error) {
+	if params.Withdrawals != nil {
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("withdrawals not supported in V1"))
+	}
+	if params.BlobGasUsed != nil || params.ExcessBlobGas != nil {
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("blob fields not supported in V1"))
+	}
+	return api.newPayload(params, INVALID)
+}
+
+// NewPayloadV2 validates and imports a payload, which carries withdrawals
+// after Shanghai.
+func (api *ConsensusAPI) NewPayloadV2(params ExecutableData) (PayloadStatusV1, error) {
+	shanghai := api.bc.Config().IsShanghai(uint64(params.Number))
+	switch {
+	case api.bc.Config().IsCancun(uint64(params.Number)):
+		return PayloadStatusV1{}, UnsupportedFork.With(errors.New("newPayloadV2 called post-cancun"))
+	case shanghai && params.Withdrawals == nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("nil withdrawals post-shanghai"))
+	case !shanghai && params.Withdrawals != nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("non-nil withdrawals pre-shanghai"))
+	case params.BlobGasUsed != nil || params.ExcessBlobGas != nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("unexpected blob fields"))
+	}
+	return api.newPayload(params, INVALIDBLOCKHASH)
+}
+
+// NewPayloadV3 validates and imports a payload, which carries withdrawals
+// and blob fields after Cancun. The payload must not contain blob
+// transactions, so versionedHashes must be empty.
+func (api *ConsensusAPI) NewPayloadV3(params ExecutableData, versionedHashes []types.Hash, beaconRoot *types.Hash) (PayloadStatusV1, error) {
+	switch {
+	case params.Withdrawals == nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("nil withdrawals post-shanghai"))
+	case params.BlobGasUsed == nil || params.ExcessBlobGas == nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("nil blob fields post-cancun"))
+	case versionedHashes == nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("nil versionedHashes post-cancun"))
+	case beaconRoot == nil:
+		return PayloadStatusV1{}, InvalidParams.With(errors.New("nil beaconRoot post-cancun"))
+	case !api.bc.Config().IsCancun(uint64(params.Number)):
+		return PayloadStatusV1{}, UnsupportedFork.With(errors.New("newPayloadV3 called pre-cancun"))
+	}
+	if len(versionedHashes) > 0 {
+		return invalidStatus(nil, errors.New("blob transactions are not supported")), nil
+	}
+	return api.newPayload(params, INVALIDBLOCKHASH)
+}
+
+// newPayload imports the block of params if it extends the head of the
+// chain, and keeps it for a later forkchoice update otherwise. hashStatus is
+// the status reported when the payload does not hash to its block hash.
+func (api *ConsensusAPI) newPayload(params ExecutableData, hashStatus string) (PayloadStatusV1, error) {
+	api.lastCall.Store(time.Now().UnixNano())
+
+	b, err := executableDataToBlock(&params)
+	if err != nil {
+		log.Warn("Invalid payload", "number", uint64(params.Number), "hash", params.BlockHash, "err", err)
+		msg := err.Error()
+		return PayloadStatusV1{Status: hashStatus, ValidationError: &msg}, nil
+	}
+	if api.bc.HasBlock(b.Hash(), b.Number64().Uint64()) {
+		hash := b.Hash()
+		return PayloadStatusV1{Status: VALID, LatestValidHash: &hash}, nil
+	}
+	if api.getSideBlock(b.Hash()) != nil {
+		return PayloadStatusV1{Status: ACCEPTED}, nil
+	}
+	parent := api.getBlock(b.ParentHash())
+	if parent == nil {
+		log.Debug("Payload parent unknown", "number", b.Number64().Uint64(), "hash", b.Hash(), "parent", b.ParentHash())
+		return PayloadStatusV1{Status: SYNCING}, nil
+	}
+	if b.Time() <= parent.Time() {
+		return invalidStatus(parent, fmt.Errorf("timestamp %d not after parent %d", b.Time(), parent.Time())), nil
+	}
+	if parent.Hash() != api.bc.CurrentBlock().Hash() {
+		// The state is only kept for the canonical chain, a side chain is
+		// executed by the forkchoice update that makes it canonical.
+		api.putSideBlock(b)
+		log.Debug("Accepted side chain payload", "number", b.Number64().Uint64(), "hash", b.Hash(), "parent", b.ParentHash())
+		return PayloadStatusV1{Status: ACCEPTED}, nil
+	}
+	if _, err := api.bc.InsertChain([]block.IBlock{b}); err != nil {
+		log.Warn("Failed to import payload", "number", b.Number64().Uint64(), "hash", b.Hash(), "err", err)
+		return invalidStatus(parent, err), nil
+	}
+	hash := b.Hash()
+	return PayloadStatusV1{Status: VALID, LatestValidHash: &hash}, nil
+}
+
+// setHead makes head the head of the chain. The chain is rewound to the last
+// canonical ancestor of head and the side chain from there up to head is
+// executed on top. The blocks rewound are kept as side chain payloads so the
+// consensus client can switch back to them. A nil status and error mean head
+// is the new head of the chain.
+func (api *ConsensusAPI) setHead(head block.IBlock) (*PayloadStatusV1, error) {
+	var (
+		side     []block.IBlock // Side chain from head down to ancestor
+		ancestor = head
+	)
+	for !api.isCanonical(ancestor) {
+		side = append(side, ancestor)
+		if ancestor = api.getBlock(ancestor.ParentHash()); ancestor == nil {
+			log.Debug("Forkchoice head ancestor unknown, waiting for payload", "hash", head.Hash(), "missing", side[len(side)-1].ParentHash())
+			return &PayloadStatusV1{Status: SYNCING}, nil
+		}
+	}
+	number := ancestor.Number64().Uint64()
+	if final := api.bc.CurrentFinalBlock(); final != nil && number < final.Number64().Uint64() {
+		return nil, InvalidForkChoiceState.With(fmt.Errorf("head %v reorganises the finalized block %d", head.Hash(), final.Number64().Uint64()))
+	}
+	if current := api.bc.CurrentBlock().Number64().Uint64(); number < current {
+		for n := number + 1; n <= current && n <= number+maxSideBlocks; n++ {
+			if b, err := api.bc.GetBlockByNumber(uint256.NewInt(n)); err == nil && b != nil {
+				api.putSideBlock(b)
+			}
+		}
+		if err := api.bc.SetHead(number); err != nil {
+			return nil, fmt.Errorf("failed to rewind to block %d: %w", number, err)
+		}
+		log.Info("Rewound chain to forkchoice head ancestor", "number", number, "hash", ancestor.Hash(), "from", current)
+	}
+	if len(side) == 0 {
+		return nil, nil
+	}
+	chain := make([]block.IBlock, len(side))
+	for i, b := range side {
+		chain[len(side)-1-i] = b
+	}
+	n, err := api.bc.InsertChain(chain)
+	for _, b := range chain[:n] {
+		api.dropSideBlock(b.Hash())
+	}
+	if err != nil {
+		log.Warn("Failed to import forkchoice head", "number", chain[n].Number64().Uint64(), "hash", chain[n].Hash(), "err", err)
+		api.dropSideBlock(chain[n].Hash())
+		latestValid := ancestor
+		if n > 0 {
+			latestValid = chain[n-1]
+		}
+		status := invalidStatus(latestValid, err)
+		return &status, nil
+	}
+	log.Info("Switched chain to forkchoice head", "number", head.Number64().Uint64(), "hash", head.Hash(), "ancestor", number, "imported", len(chain))
+	return nil, nil
+}
+
+// invalidStatus reports a payload as invalid, with latestValid as the last
+// valid block on its chain if known.
+func invalidStatus(latestValid block.IBlock, err error) PayloadStatusV1 {
+	status := PayloadStatusV1{Status: INVALID}
+	if latestValid != nil {
+		hash := latestValid.Hash()
+		status.LatestValidHash = &hash
+	}
+	if err != nil {
+		msg := err.Error()
+		status.ValidationError = &msg
+	}
+	return status
+}
+
+// heartbeat periodically warns when a consensus client that has used the
+// engine API stops calling it, as the chain then silently stops advancing.
+// Nodes that were never driven by a consensus client stay quiet.
+func (api *ConsensusAPI) heartbeat() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var lastWarn time.Time
+	for {
+		select {
+		case <-api.bc.Quit():
+			return
+		case <-ticker.C:
+		}
+		lastCall := api.lastCall.Load()
+		if lastCall == 0 || time.Since(lastWarn) < beaconUpdateWarnFrequency {
+			continue
+		}
+		var (
+			sinceCall       = time.Since(time.Unix(0, lastCall))
+			sinceForkchoice = time.Since(time.Unix(0, api.lastForkchoice.Load()))
+		)
+		switch {
+		case sinceCall > beaconUpdateTimeout:
+			log.Warn("Consensus client has not called the engine API in a while, is it still running?", "last", common.PrettyDuration(sinceCall))
+		case sinceForkchoice > beaconUpdateTimeout:
+			log.Warn("Consensus client is connected but sends no forkchoice updates, is it syncing?", "last", common.PrettyDuration(sinceForkchoice))
+		default:
+			continue
+		}
+		lastWarn = time.Now()
+	}
+}
+
+// isCanonical reports whether b is on the canonical chain.
+func (api *ConsensusAPI) isCanonical(b block.IBlock) bool {
+	canonical, err := api.bc.GetBlockByNumber(uint256.NewInt(b.Number64().Uint64()))
+	return err == nil && canonical != nil && canonical.Hash() == b.Hash()
+}
+
+// nextNumber returns the number of the block following the chain head.
+// Forks are scheduled by block number, so payload attributes are checked
+// against the fork of the block they will be used for.
+func (api *ConsensusAPI) nextNumber() uint64 {
+	return api.bc.CurrentBlock().Number64().Uint64() + 1
+}
+
+// payloadByID returns the payload with the given id if it was built by one
+// of the given engine API versions.
+func (api *ConsensusAPI) payloadByID(id PayloadID, versions ...int) (*payload, error) {
+	api.lastCall.Store(time.Now().UnixNano())
+
+	p := api.getPayload(id)
+	if p == nil {
+		return nil, UnknownPayload
+	}
+	for _, v := range versions {
+		if p.version == v {
+			return p, nil
+		}
+	}
+	return nil, UnsupportedFork.With(fmt.Errorf("payload %v was built with forkchoiceUpdatedV%d", id, p.version))
+}
+
+func (api *ConsensusAPI) getPayload(id PayloadID) *payload {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for _, p := range api.payloads {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (api *ConsensusAPI) putPayload(p *payload) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.payloads = append(api.payloads, p)
+	if len(api.payloads) > maxPayloads {
+		api.payloads = api.payloads[len(api.payloads)-maxPayloads:]
+	}
+}
+
+// getBlock returns the block with the given hash from the chain or the side
+// chain payloads, nil if unknown.
+func (api *ConsensusAPI) getBlock(hash types.Hash) block.IBlock {
+	if b, err := api.bc.GetBlockByHash(hash); err == nil && b != nil {
+		return b
+	}
+	return api.getSideBlock(hash)
+}
+
+func (api *ConsensusAPI) getSideBlock(hash types.Hash) block.IBlock {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.sideBlocks[hash]
+}
+
+// putSideBlock keeps b until a forkchoice update makes it canonical, dropping
+// the oldest side chain payload beyond maxSideBlocks.
+func (api *ConsensusAPI) putSideBlock(b block.IBlock) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if _, ok := api.sideBlocks[b.Hash()]; ok {
+		return
+	}
+	api.sideBlocks[b.Hash()] = b
+	api.sideOrder = append(api.sideOrder, b.Hash())
+	for len(api.sideOrder) > maxSideBlocks {
+		delete(api.sideBlocks, api.sideOrder[0])
+		api.sideOrder = api.sideOrder[1:]
+	}
+}
+
+func (api *ConsensusAPI) dropSideBlock(hash types.Hash) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if _, ok := api.sideBlocks[hash]; !ok {
+		return
+	}
+	delete(api.sideBlocks, hash)
+	for i, h := range api.sideOrder {
+		if h == hash {
+			api.sideOrder = append(api.sideOrder[:i], api.sideOrder[i+1:]...)
+			break
+		}
+	}
+}
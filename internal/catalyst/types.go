@@ -0,0 +1,281 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hash"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+)
+
+// Payload status values, as defined by the engine API.
+const (
+	VALID            = "VALID"
+	INVALID          = "INVALID"
+	SYNCING          = "SYNCING"
+	ACCEPTED         = "ACCEPTED"
+	INVALIDBLOCKHASH = "INVALID_BLOCK_HASH"
+)
+
+// EngineAPIError is an error returned by the engine namespace, carrying one
+// of the error codes defined by the engine API.
+type EngineAPIError struct {
+	code int
+	msg  string
+	err  error
+}
+
+func (e *EngineAPIError) ErrorCode() int { return e.code }
+func (e *EngineAPIError) Error() string  { return e.msg }
+
+// With returns a copy of the error with err as its cause.
+func (e *EngineAPIError) With(err error) *EngineAPIError {
+	return &EngineAPIError{code: e.code, msg: fmt.Sprintf("%s: %v", e.msg, err), err: err}
+}
+
+func (e *EngineAPIError) Unwrap() error { return e.err }
+
+var (
+	UnknownPayload           = &EngineAPIError{code: -38001, msg: "Unknown payload"}
+	InvalidForkChoiceState   = &EngineAPIError{code: -38002, msg: "Invalid forkchoice state"}
+	InvalidPayloadAttributes = &EngineAPIError{code: -38003, msg: "Invalid payload attributes"}
+	UnsupportedFork          = &EngineAPIError{code: -38005, msg: "Unsupported fork"}
+	InvalidParams            = &EngineAPIError{code: -32602, msg: "Invalid parameters"}
+)
+
+// PayloadID identifies a payload being built by the node.
+type PayloadID [8]byte
+
+func (id PayloadID) String() string {
+	return hexutil.Encode(id[:])
+}
+
+func (id PayloadID) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(id[:]).MarshalText()
+}
+
+func (id *PayloadID) UnmarshalText(input []byte) error {
+	return hexutil.UnmarshalFixedText("PayloadID", input, id[:])
+}
+
+// Withdrawal is a validator withdrawal from the consensus layer.
+type Withdrawal struct {
+	Index     hexutil.Uint64 `json:"index"`
+	Validator hexutil.Uint64 `json:"validatorIndex"`
+	Address   types.Address  `json:"address"`
+	Amount    hexutil.Uint64 `json:"amount"`
+}
+
+// PayloadAttributes describes the block a consensus client wants built on
+// top of the new head of a forkchoice update.
+type PayloadAttributes struct {
+	Timestamp             hexutil.Uint64 `json:"timestamp"`
+	Random                types.Hash     `json:"prevRandao"`
+	SuggestedFeeRecipient types.Address  `json:"suggestedFeeRecipient"`
+	Withdrawals           []*Withdrawal  `json:"withdrawals"`
+	BeaconRoot            *types.Hash    `json:"parentBeaconBlockRoot"`
+}
+
+// ForkchoiceStateV1 is the head, safe and finalized block of the consensus
+// client's view of the chain.
+type ForkchoiceStateV1 struct {
+	HeadBlockHash      types.Hash `json:"headBlockHash"`
+	SafeBlockHash      types.Hash `json:"safeBlockHash"`
+	FinalizedBlockHash types.Hash `json:"finalizedBlockHash"`
+}
+
+// PayloadStatusV1 is the result of validating a payload or forkchoice head.
+type PayloadStatusV1 struct {
+	Status          string      `json:"status"`
+	LatestValidHash *types.Hash `json:"latestValidHash"`
+	ValidationError *string     `json:"validationError"`
+}
+
+// ForkChoiceResponse is the result of a forkchoice update.
+type ForkChoiceResponse struct {
+	PayloadStatus PayloadStatusV1 `json:"payloadStatus"`
+	PayloadID     *PayloadID      `json:"payloadId"`
+}
+
+// ExecutableData is an execution payload, the block as seen by the
+// consensus client.
+type ExecutableData struct {
+	ParentHash    types.Hash      `json:"parentHash"`
+	FeeRecipient  types.Address   `json:"feeRecipient"`
+	StateRoot     types.Hash      `json:"stateRoot"`
+	ReceiptsRoot  types.Hash      `json:"receiptsRoot"`
+	LogsBloom     hexutil.Bytes   `json:"logsBloom"`
+	Random        types.Hash      `json:"prevRandao"`
+	Number        hexutil.Uint64  `json:"blockNumber"`
+	GasLimit      hexutil.Uint64  `json:"gasLimit"`
+	GasUsed       hexutil.Uint64  `json:"gasUsed"`
+	Timestamp     hexutil.Uint64  `json:"timestamp"`
+	ExtraData     hexutil.Bytes   `json:"extraData"`
+	BaseFeePerGas *hexutil.Big    `json:"baseFeePerGas"`
+	BlockHash     types.Hash      `json:"blockHash"`
+	Transactions  []hexutil.Bytes `json:"transactions"`
+	Withdrawals   []*Withdrawal   `json:"withdrawals,omitempty"`
+	BlobGasUsed   *hexutil.Uint64 `json:"blobGasUsed,omitempty"`
+	ExcessBlobGas *hexutil.Uint64 `json:"excessBlobGas,omitempty"`
+}
+
+// ExecutionPayloadEnvelope is the result of getPayload from V2 on.
+type ExecutionPayloadEnvelope struct {
+	ExecutionPayload *ExecutableData `json:"executionPayload"`
+	BlockValue       *hexutil.Big    `json:"blockValue"`
+	BlobsBundle      *BlobsBundleV1  `json:"blobsBundle,omitempty"`
+	Override         bool            `json:"shouldOverrideBuilder"`
+}
+
+// BlobsBundleV1 holds the blobs of the transactions of a payload.
+type BlobsBundleV1 struct {
+	Commitments []hexutil.Bytes `json:"commitments"`
+	Proofs      []hexutil.Bytes `json:"proofs"`
+	Blobs       []hexutil.Bytes `json:"blobs"`
+}
+
+// payloadID computes the identifier of the payload built on parent with the
+// given attributes for engine API version.
+func payloadID(parent types.Hash, attr *PayloadAttributes, version int) PayloadID {
+	hasher := sha256.New()
+	hasher.Write(parent[:])
+	binary.Write(hasher, binary.BigEndian, uint64(attr.Timestamp))
+	hasher.Write(attr.Random[:])
+	hasher.Write(attr.SuggestedFeeRecipient[:])
+	if attr.BeaconRoot != nil {
+		hasher.Write(attr.BeaconRoot[:])
+	}
+	var out PayloadID
+	copy(out[:], hasher.Sum([]byte{byte(version)})[:8])
+	return out
+}
+
+// blockToExecutableData converts a block into an execution payload.
+func blockToExecutableData(b block.IBlock, version int) *ExecutableData {
+	header := b.Header().(*block.Header)
+	baseFee := new(big.Int)
+	if header.BaseFee != nil {
+		baseFee = header.BaseFee.ToBig()
+	}
+	txs := make([]hexutil.Bytes, 0, len(b.Transactions()))
+	for _, tx := range b.Transactions() {
+		enc, err := tx.Marshal()
+		if err != nil {
+			continue
+		}
+		txs = append(txs, enc)
+	}
+	data := &ExecutableData{
+		ParentHash:    header.ParentHash,
+		FeeRecipient:  header.Coinbase,
+		StateRoot:     header.Root,
+		ReceiptsRoot:  header.ReceiptHash,
+		LogsBloom:     header.Bloom.Bytes(),
+		Random:        header.MixDigest,
+		Number:        hexutil.Uint64(header.Number.Uint64()),
+		GasLimit:      hexutil.Uint64(header.GasLimit),
+		GasUsed:       hexutil.Uint64(header.GasUsed),
+		Timestamp:     hexutil.Uint64(header.Time),
+		ExtraData:     header.Extra,
+		BaseFeePerGas: (*hexutil.Big)(baseFee),
+		BlockHash:     b.Hash(),
+		Transactions:  txs,
+	}
+	if version >= 2 {
+		data.Withdrawals = []*Withdrawal{}
+	}
+	if version >= 3 {
+		zero := hexutil.Uint64(0)
+		data.BlobGasUsed, data.ExcessBlobGas = &zero, &zero
+	}
+	return data
+}
+
+// executableDataToBlock converts an execution payload into a block, checking
+// that the payload hashes to its block hash. The block format has no room
+// for withdrawals or blobs, so payloads carrying them are rejected.
+func executableDataToBlock(data *ExecutableData) (block.IBlock, error) {
+	if len(data.Withdrawals) > 0 {
+		return nil, fmt.Errorf("withdrawals are not supported")
+	}
+	if (data.BlobGasUsed != nil && *data.BlobGasUsed != 0) || (data.ExcessBlobGas != nil && *data.ExcessBlobGas != 0) {
+		return nil, fmt.Errorf("blob transactions are not supported")
+	}
+	if len(data.LogsBloom) != block.BloomByteLength {
+		return nil, fmt.Errorf("invalid logsBloom length: %d", len(data.LogsBloom))
+	}
+	if data.BaseFeePerGas == nil {
+		return nil, fmt.Errorf("missing baseFeePerGas")
+	}
+	baseFee, overflow := uint256.FromBig((*big.Int)(data.BaseFeePerGas))
+	if overflow {
+		return nil, fmt.Errorf("invalid baseFeePerGas: %v", data.BaseFeePerGas)
+	}
+	txs := make([]*transaction.Transaction, len(data.Transactions))
+	for i, enc := range data.Transactions {
+		tx := new(transaction.Transaction)
+		if err := tx.Unmarshal(enc); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		txs[i] = tx
+	}
+	header := &block.Header{
+		ParentHash:  data.ParentHash,
+		Coinbase:    data.FeeRecipient,
+		Root:        data.StateRoot,
+		TxHash:      hash.DeriveSha(transaction.Transactions(txs)),
+		ReceiptHash: data.ReceiptsRoot,
+		Bloom:       block.BytesToBloom(data.LogsBloom),
+		Difficulty:  uint256.NewInt(0),
+		Number:      uint256.NewInt(uint64(data.Number)),
+		GasLimit:    uint64(data.GasLimit),
+		GasUsed:     uint64(data.GasUsed),
+		Time:        uint64(data.Timestamp),
+		MixDigest:   data.Random,
+		Extra:       data.ExtraData,
+		BaseFee:     baseFee,
+	}
+	b := block.NewBlock(header, txs)
+	if b.Hash() != data.BlockHash {
+		return nil, fmt.Errorf("blockhash mismatch, want %v, got %v", data.BlockHash, b.Hash())
+	}
+	return b, nil
+}
+
+// blockValue returns the fees paid to the fee recipient of b.
+func blockValue(b block.IBlock, receipts block.Receipts) *big.Int {
+	value := new(uint256.Int)
+	for i, tx := range b.Transactions() {
+		if i >= len(receipts) {
+			break
+		}
+		tip, err := tx.EffectiveGasTip(b.BaseFee64())
+		if err != nil {
+			continue
+		}
+		value.Add(value, new(uint256.Int).Mul(tip, uint256.NewInt(receipts[i].GasUsed)))
+	}
+	return value.ToBig()
+}
@@ -187,10 +187,21 @@ type Apoa struct {
 	signFn SignerFn      // Signer function to authorize hashes with
 	lock   sync.RWMutex  // Protects the signer and proposals fields
 
+	maxFutureDrift time.Duration // How far ahead of the local clock a block timestamp may be
+
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
 }
 
+func init() {
+	consensus.Register(params.CliqueConsensus, func(config *params.ChainConfig, db kv.RwDB) (consensus.Engine, error) {
+		if config.Clique == nil {
+			return nil, errors.New("missing clique consensus parameters")
+		}
+		return New(config.Clique, db), nil
+	})
+}
+
 // New creates a Apoa proof-of-authority consensus engine with the initial
 // signers set to the ones provided by the user.
 func New(config *params.CliqueConfig, db kv.RwDB) consensus.Engine {
@@ -212,6 +223,12 @@ func New(config *params.CliqueConfig, db kv.RwDB) consensus.Engine {
 	}
 }
 
+// SetProposalTiming implements consensus.ProposalTiming. Blocks carry no
+// signatures of verifiers to collect, so the deadline does not apply.
+func (c *Apoa) SetProposalTiming(_, maxFutureDrift time.Duration) {
+	c.maxFutureDrift = maxFutureDrift
+}
+
 // Author implements consensus.Engine, returning the Ethereum address recovered
 // from the signature in the header's extra-data section.
 func (c *Apoa) Author(header block.IHeader) (types.Address, error) {
@@ -220,7 +237,7 @@ func (c *Apoa) Author(header block.IHeader) (types.Address, error) {
 
 // VerifyHeader checks whether a header conforms to the consensus rules.
 func (c *Apoa) VerifyHeader(chain consensus.ChainHeaderReader, header block.IHeader, seal bool) error {
-	return c.verifyHeader(chain, header, nil)
+	return c.verifyHeader(chain, header, nil, seal)
 }
 
 // VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
@@ -232,7 +249,7 @@ func (c *Apoa) VerifyHeaders(chain consensus.ChainHeaderReader, headers []block.
 
 	go func() {
 		for i, header := range headers {
-			err := c.verifyHeader(chain, header, headers[:i])
+			err := c.verifyHeader(chain, header, headers[:i], seals[i])
 
 			select {
 			case <-abort:
@@ -248,7 +265,7 @@ func (c *Apoa) VerifyHeaders(chain consensus.ChainHeaderReader, headers []block.
 // caller may optionally pass in a batch of parents (ascending order) to avoid
 // looking those up from the database. This is useful for concurrently verifying
 // a batch of new headers.
-func (c *Apoa) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader) error {
+func (c *Apoa) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader, seal bool) error {
 	header := iHeader.(*block.Header)
 	if header.Number.IsZero() {
 		return errUnknownBlock
@@ -256,7 +273,7 @@ func (c *Apoa) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHe
 	number := header.Number.Uint64()
 
 	// Don't waste time checking blocks from the future
-	if header.Time > uint64(time.Now().Unix()) {
+	if header.Time > uint64(time.Now().Add(c.maxFutureDrift).Unix()) {
 		return errors.New("block in the future")
 	}
 	// Checkpoint blocks need to enforce zero beneficiary
@@ -310,14 +327,14 @@ func (c *Apoa) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHe
 	//	return err
 	//}
 	// All basic checks passed, verify cascading fields
-	return c.verifyCascadingFields(chain, header, parents)
+	return c.verifyCascadingFields(chain, header, parents, seal)
 }
 
 // verifyCascadingFields verifies all the header fields that are not standalone,
 // rather depend on a batch of previous headers. The caller may optionally pass
 // in a batch of parents (ascending order) to avoid looking those up from the
 // database. This is useful for concurrently verifying a batch of new headers.
-func (c *Apoa) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader) error {
+func (c *Apoa) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader, seal bool) error {
 	header := iHeader.(*block.Header)
 	// The genesis block is the always valid dead-end
 	number := header.Number.Uint64()
@@ -372,6 +389,9 @@ func (c *Apoa) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader
 		}
 	}
 	// All basic checks passed, verify the seal and return
+	if !seal {
+		return nil
+	}
 	return c.verifySeal(snap, header, parents)
 }
 
@@ -753,6 +773,20 @@ func (c *Apoa) APIs(chain consensus.ChainReader) []jsonrpc.API {
 	}}
 }
 
+// Finalized implements consensus.Finality, a block is finalized once more
+// than half of the signers sealed blocks on top of it.
+func (c *Apoa) Finalized(chain consensus.ChainHeaderReader, head block.IHeader) (uint64, uint64, bool) {
+	number := head.Number64().Uint64()
+	if number == 0 {
+		return 0, 0, true
+	}
+	snap, err := c.snapshot(chain, number-1, head.(*block.Header).ParentHash, nil)
+	if err != nil {
+		return 0, 0, false
+	}
+	return consensus.SealerFinality(chain, head, len(snap.Signers), c.Author)
+}
+
 func (c *Apoa) Type() params.ConsensusType {
 	return params.CliqueConsensus
 }
@@ -63,7 +63,7 @@ const (
 	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
 
 	wiggleTime       = 500 * time.Millisecond // Random delay (per signer) to allow concurrent signers
-	mergeSignMinTime = 4                      // min time for merge sign
+	mergeSignMinTime = 4                      // Default min seconds for merge sign
 )
 
 // APos proof-of-authority protocol constants.
@@ -198,12 +198,24 @@ type APos struct {
 	signFn SignerFn      // Signer function to authorize hashes with
 	lock   sync.RWMutex  // Protects the signer and proposals fields
 
+	proposalDeadline time.Duration // Least time kept before the block timestamp to collect signatures
+	maxFutureDrift   time.Duration // How far ahead of the local clock a block timestamp may be
+
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
 
 	bc astCommon.IBlockChain
 }
 
+func init() {
+	consensus.Register(params.AposConsensu, func(config *params.ChainConfig, db kv.RwDB) (consensus.Engine, error) {
+		if config.Apos == nil {
+			return nil, errors.New("missing apos consensus parameters")
+		}
+		return New(config.Apos, db, config), nil
+	})
+}
+
 // New creates a APos proof-of-authority consensus engine with the initial
 // signers set to the ones provided by the user.
 func New(config *params.APosConfig, db kv.RwDB, chainConfig *params.ChainConfig) consensus.Engine {
@@ -223,9 +235,17 @@ func New(config *params.APosConfig, db kv.RwDB, chainConfig *params.ChainConfig)
 		recents:     recents,
 		signatures:  signatures,
 		proposals:   make(map[types.Address]bool),
+
+		proposalDeadline: mergeSignMinTime * time.Second,
 	}
 }
 
+// SetProposalTiming implements consensus.ProposalTiming.
+func (c *APos) SetProposalTiming(deadline, maxFutureDrift time.Duration) {
+	c.proposalDeadline = deadline
+	c.maxFutureDrift = maxFutureDrift
+}
+
 // Author implements consensus.Engine, returning the Ethereum address recovered
 // from the signature in the header's extra-data section.
 func (c *APos) Author(header block.IHeader) (types.Address, error) {
@@ -238,7 +258,7 @@ func (c *APos) SetBlockChain(bc astCommon.IBlockChain) {
 
 // VerifyHeader checks whether a header conforms to the consensus rules.
 func (c *APos) VerifyHeader(chain consensus.ChainHeaderReader, header block.IHeader, seal bool) error {
-	return c.verifyHeader(chain, header, nil)
+	return c.verifyHeader(chain, header, nil, seal)
 }
 
 // VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
@@ -250,7 +270,7 @@ func (c *APos) VerifyHeaders(chain consensus.ChainHeaderReader, headers []block.
 
 	go func() {
 		for i, header := range headers {
-			err := c.verifyHeader(chain, header, headers[:i])
+			err := c.verifyHeader(chain, header, headers[:i], seals[i])
 
 			select {
 			case <-abort:
@@ -266,7 +286,7 @@ func (c *APos) VerifyHeaders(chain consensus.ChainHeaderReader, headers []block.
 // caller may optionally pass in a batch of parents (ascending order) to avoid
 // looking those up from the database. This is useful for concurrently verifying
 // a batch of new headers.
-func (c *APos) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader) error {
+func (c *APos) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader, seal bool) error {
 	header := iHeader.(*block.Header)
 	if header.Number.IsZero() {
 		return errUnknownBlock
@@ -274,7 +294,7 @@ func (c *APos) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHe
 	number := header.Number.Uint64()
 
 	// Don't waste time checking blocks from the future
-	if header.Time > uint64(time.Now().Unix()) {
+	if header.Time > uint64(time.Now().Add(c.maxFutureDrift).Unix()) {
 		return errors.New("block in the future")
 	}
 	// Checkpoint blocks need to enforce zero beneficiary
@@ -328,14 +348,14 @@ func (c *APos) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHe
 	//	return err
 	//}
 	// All basic checks passed, verify cascading fields
-	return c.verifyCascadingFields(chain, header, parents)
+	return c.verifyCascadingFields(chain, header, parents, seal)
 }
 
 // verifyCascadingFields verifies all the header fields that are not standalone,
 // rather depend on a batch of previous headers. The caller may optionally pass
 // in a batch of parents (ascending order) to avoid looking those up from the
 // database. This is useful for concurrently verifying a batch of new headers.
-func (c *APos) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader) error {
+func (c *APos) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader block.IHeader, parents []block.IHeader, seal bool) error {
 	header := iHeader.(*block.Header)
 	// The genesis block is the always valid dead-end
 	number := header.Number.Uint64()
@@ -390,6 +410,9 @@ func (c *APos) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader
 		}
 	}
 	// All basic checks passed, verify the seal and return
+	if !seal {
+		return nil
+	}
 	return c.verifySeal(snap, header, parents)
 }
 
@@ -606,8 +629,8 @@ func (c *APos) Prepare(chain consensus.ChainHeaderReader, header block.IHeader)
 		return errors.New("unknown ancestor")
 	}
 	rawHeader.Time = parent.(*block.Header).Time + c.config.Period
-	if rawHeader.Time < uint64(time.Now().Unix())+mergeSignMinTime {
-		rawHeader.Time = uint64(time.Now().Unix()) + mergeSignMinTime
+	if deadline := uint64(time.Now().Add(c.proposalDeadline).Unix()); rawHeader.Time < deadline {
+		rawHeader.Time = deadline
 	}
 	return nil
 }
@@ -748,6 +771,7 @@ func (c *APos) Seal(chain consensus.ChainHeaderReader, b block.IBlock, results c
 		member := c.CountDepositor()
 		aggSign, verifiers, err := api.SignMerge(ctx, header, member)
 		if nil != err {
+			log.Warn("Missed block proposal, the signatures of the verifiers were not collected in time", "number", number, "deadline", time.Unix(int64(header.Time), 0), "err", err)
 			return err
 		}
 		ss := make([]bls.PublicKey, len(verifiers))
@@ -841,6 +865,33 @@ func (c *APos) APIs(chain consensus.ChainReader) []jsonrpc.API {
 	}}
 }
 
+// DepositContracts returns the addresses of the contracts validators deposit
+// their stake to, the ones of the config which are set and valid.
+func (c *APos) DepositContracts() []types.Address {
+	var contracts []types.Address
+	for _, s := range []string{c.config.DepositContract, c.config.DepositNFTContract, c.config.DepositFUJIContract} {
+		var addr types.Address
+		if s != "" && addr.DecodeString(s) {
+			contracts = append(contracts, addr)
+		}
+	}
+	return contracts
+}
+
+// Finalized implements consensus.Finality, a block is finalized once more
+// than half of the signers sealed blocks on top of it.
+func (c *APos) Finalized(chain consensus.ChainHeaderReader, head block.IHeader) (uint64, uint64, bool) {
+	number := head.Number64().Uint64()
+	if number == 0 {
+		return 0, 0, true
+	}
+	snap, err := c.snapshot(chain, number-1, head.(*block.Header).ParentHash, nil)
+	if err != nil {
+		return 0, 0, false
+	}
+	return consensus.SealerFinality(chain, head, len(snap.Signers), c.Author)
+}
+
 func (c *APos) Type() params.ConsensusType {
 	return params.CliqueConsensus
 }
@@ -17,6 +17,8 @@
 package consensus
 
 import (
+	"time"
+
 	"github.com/holiman/uint256"
 	"github.com/n42blockchain/N42/common/block"
 	"github.com/n42blockchain/N42/common/transaction"
@@ -149,6 +151,15 @@ type EngineReader interface {
 	Type() params.ConsensusType
 }
 
+// ProposalTiming is implemented by the engines whose timing of block proposals
+// and tolerance to blocks from the future can be configured.
+type ProposalTiming interface {
+	// SetProposalTiming sets the least time a proposer keeps before the
+	// timestamp of its block to collect signatures, and how far ahead of the
+	// local clock the timestamp of a received block may be.
+	SetProposalTiming(deadline, maxFutureDrift time.Duration)
+}
+
 var (
 	SystemAddress = types.HexToAddress("0xffffFFFfFFffffffffffffffFfFFFfffFFFfFFfE")
 )
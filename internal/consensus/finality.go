@@ -0,0 +1,67 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package consensus
+
+import (
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+)
+
+// Finality is implemented by the engines which tell when a block can no longer
+// be reorganised away, for the "finalized" and "safe" block tags.
+type Finality interface {
+	// Finalized returns the numbers of the finalized and safe blocks of the
+	// chain with the given head, ok is false if they cannot be told.
+	Finalized(chain ChainHeaderReader, head block.IHeader) (finalized, safe uint64, ok bool)
+}
+
+// SealerFinality returns the finalized and safe blocks of a chain sealed in
+// turn by a set of signers, of which author recovers the one of a header. A
+// block is finalized once more than half of the signers sealed blocks on top
+// of it, as a competing chain would need a majority of them to seal twice, and
+// safe once a signer other than its own built on it, or once it is finalized.
+func SealerFinality(chain ChainHeaderReader, head block.IHeader, signers int, author func(block.IHeader) (types.Address, error)) (finalized, safe uint64, ok bool) {
+	var (
+		sealers   = make(map[types.Address]struct{})
+		safeFound bool
+	)
+	for header := head; header != nil; {
+		number := header.Number64().Uint64()
+		if number == 0 {
+			// The genesis block is final, and carries no seal.
+			return 0, safe, true
+		}
+		signer, err := author(header)
+		if err != nil {
+			return 0, 0, false
+		}
+		_, sealed := sealers[signer]
+		if !safeFound && (len(sealers) > 1 || len(sealers) == 1 && !sealed) {
+			safe, safeFound = number, true
+		}
+		if len(sealers) > signers/2 {
+			if !safeFound {
+				safe = number
+			}
+			return number, safe, true
+		}
+		sealers[signer] = struct{}{}
+		header = chain.GetHeader(header.(*block.Header).ParentHash, uint256.NewInt(number-1))
+	}
+	return 0, 0, false
+}
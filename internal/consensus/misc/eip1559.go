@@ -32,7 +32,7 @@ func VerifyEip1559Header(config *params.ChainConfig, parent, header *block.Heade
 	// Verify that the gas limit remains within allowed bounds
 	parentGasLimit := parent.GasLimit
 	if !config.IsLondon(parent.Number.Uint64()) {
-		parentGasLimit = parent.GasLimit * params.ElasticityMultiplier
+		parentGasLimit = parent.GasLimit * config.ElasticityMultiplier()
 	}
 	if err := VerifyGaslimit(parentGasLimit, header.GasLimit); err != nil {
 		return err
@@ -54,13 +54,13 @@ func VerifyEip1559Header(config *params.ChainConfig, parent, header *block.Heade
 func CalcBaseFee(config *params.ChainConfig, parent *block.Header) *big.Int {
 	// If the current block is the first EIP-1559 block, return the InitialBaseFee.
 	if !config.IsLondon(parent.Number.Uint64()) {
-		return new(big.Int).SetUint64(params.InitialBaseFee)
+		return config.InitialBaseFee()
 	}
 
 	var (
-		parentGasTarget          = parent.GasLimit / params.ElasticityMultiplier
+		parentGasTarget          = parent.GasLimit / config.ElasticityMultiplier()
 		parentGasTargetBig       = new(big.Int).SetUint64(parentGasTarget)
-		baseFeeChangeDenominator = new(big.Int).SetUint64(params.BaseFeeChangeDenominator)
+		baseFeeChangeDenominator = new(big.Int).SetUint64(config.BaseFeeChangeDenominator())
 	)
 	// If the parent gasUsed is the same as the target, the baseFee remains unchanged.
 	if parent.GasUsed == parentGasTarget {
@@ -86,7 +86,7 @@ func CalcBaseFee(config *params.ChainConfig, parent *block.Header) *big.Int {
 
 		return math.BigMax(
 			x.Sub(parent.BaseFee.ToBig(), baseFeeDelta),
-			common.Big0,
+			config.MinBaseFee(),
 		)
 	}
 }
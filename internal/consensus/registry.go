@@ -0,0 +1,70 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package consensus
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/params"
+)
+
+// EngineFactory creates the consensus engine of a chain from its config, the
+// engine keeping its own data, such as snapshots, in db.
+type EngineFactory func(config *params.ChainConfig, db kv.RwDB) (Engine, error)
+
+var (
+	enginesLock sync.RWMutex
+	engines     = make(map[params.ConsensusType]EngineFactory)
+)
+
+// Register makes an engine available to the chains whose config selects it
+// with name. Engines register themselves when their package is imported, it
+// panics if name is already registered.
+func Register(name params.ConsensusType, factory EngineFactory) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("consensus engine %q registered twice", name))
+	}
+	engines[name] = factory
+}
+
+// Engines returns the names of the registered engines, sorted.
+func Engines() []params.ConsensusType {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+	names := make([]params.ConsensusType, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New creates the engine selected by the Consensus field of config.
+func New(config *params.ChainConfig, db kv.RwDB) (Engine, error) {
+	enginesLock.RLock()
+	factory, ok := engines[config.Consensus]
+	enginesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown consensus engine %q, registered engines are %v", config.Consensus, Engines())
+	}
+	return factory(config, db)
+}
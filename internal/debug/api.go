@@ -229,7 +229,30 @@ func (*HandlerT) FreeOSMemory() {
 // SetGCPercent sets the garbage collection target percentage. It returns the previous
 // setting. A negative value disables GC.
 func (*HandlerT) SetGCPercent(v int) int {
-	return debug.SetGCPercent(v)
+	prev := debug.SetGCPercent(v)
+	log.Info("Changed GC target percentage", "from", prev, "to", v)
+	return prev
+}
+
+// SetMemoryLimit sets the soft memory limit of the runtime in bytes. It returns
+// the previous limit. A negative value only reports the current limit.
+func (*HandlerT) SetMemoryLimit(limit int64) int64 {
+	prev := debug.SetMemoryLimit(limit)
+	if limit >= 0 {
+		log.Info("Changed runtime memory limit", "from", prev, "to", limit)
+	}
+	return prev
+}
+
+// Vmodule sets the log level of the packages matching the given patterns,
+// as comma separated pattern=level entries. An empty string removes all
+// package levels.
+func (*HandlerT) Vmodule(pattern string) error {
+	if err := log.SetVModule(pattern); err != nil {
+		return err
+	}
+	log.Info("Changed package log levels", "vmodule", pattern)
+	return nil
 }
 
 func writeProfile(name, file string) error {
@@ -0,0 +1,75 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+	"strconv"
+
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/log"
+)
+
+// Setup applies the profiling settings of cfg. It starts the CPU profile and
+// the pprof HTTP server if they are configured.
+func Setup(cfg conf.PprofConfig) error {
+	if cfg.CPUProfile != "" {
+		if err := Handler.StartCPUProfile(cfg.CPUProfile); err != nil {
+			return err
+		}
+	}
+	if !cfg.Pprof {
+		return nil
+	}
+	if cfg.MaxCpu > 0 {
+		runtime.GOMAXPROCS(cfg.MaxCpu)
+	}
+	if cfg.TraceMutex {
+		runtime.SetMutexProfileFraction(1)
+	}
+	if cfg.TraceBlock {
+		runtime.SetBlockProfileRate(1)
+	}
+	StartPProf(net.JoinHostPort(cfg.Addr, strconv.Itoa(cfg.Port)))
+	return nil
+}
+
+// StartPProf serves the pprof handlers of net/http/pprof at address.
+func StartPProf(address string) {
+	log.Info("Starting pprof server", "addr", "http://"+address+"/debug/pprof")
+	go func() {
+		if err := http.ListenAndServe(address, nil); err != nil {
+			log.Error("Failure in running pprof server", "err", err)
+		}
+	}()
+}
+
+// Exit finishes the CPU profile and writes the heap profile configured in
+// cfg. It is called when the node shuts down.
+func Exit(cfg conf.PprofConfig) {
+	if cfg.CPUProfile != "" {
+		Handler.StopCPUProfile()
+	}
+	if cfg.MemProfile != "" {
+		if err := Handler.WriteMemProfile(cfg.MemProfile); err != nil {
+			log.Error("Failed to write heap profile", "err", err)
+		}
+	}
+}
@@ -74,6 +74,11 @@ func NewForkChoice(chainReader ChainReader, preserve func(header block2.IHeader)
 // total difficulty is higher. In the extern mode, the trusted
 // header is always selected as the head.
 func (f *ForkChoice) ReorgNeeded(current block2.IHeader, header block2.IHeader) (bool, error) {
+	// Blocks without difficulty are payloads of a consensus client driving
+	// the node over the engine API, which chooses the head itself.
+	if h, ok := header.(*block2.Header); ok && h.Difficulty != nil && h.Difficulty.IsZero() {
+		return true, nil
+	}
 	var (
 		localTD  = f.chain.GetTd(current.Hash(), current.Number64())
 		externTd = f.chain.GetTd(header.Hash(), header.Number64())
@@ -206,7 +206,7 @@ func (g *GenesisBlock) ToBlock() (*block2.Block, *state.IntraBlockState, error)
 		if g.GenesisConfig.BaseFee != nil {
 			head.BaseFee = g.GenesisConfig.BaseFee
 		} else {
-			head.BaseFee = uint256.NewInt(params.InitialBaseFee)
+			head.BaseFee, _ = uint256.FromBig(g.GenesisConfig.Config.InitialBaseFee())
 		}
 	}
 
@@ -252,6 +252,8 @@ func GenesisByChainName(chain string) *conf.Genesis {
 		return mainnetGenesisBlock()
 	case networkname.TestnetChainName:
 		return testnetGenesisBlock()
+	case networkname.DevnetChainName:
+		return devnetGenesisBlock()
 	default:
 		return nil
 	}
@@ -282,3 +284,15 @@ func testnetGenesisBlock() *conf.Genesis {
 		Miners:    []string{"0xAA824Bf8afa35061d5b9FeBD0AD47642Cd3b1d17"},
 	}
 }
+
+// devnetGenesisBlock returns the genesis block of the local developer network.
+func devnetGenesisBlock() *conf.Genesis {
+	return &conf.Genesis{
+		Config:    params.DevnetChainConfig,
+		Nonce:     0,
+		Alloc:     readGenesisAlloc("allocs/devnet.json"),
+		Number:    0,
+		Timestamp: 1678174066,
+		Miners:    []string{"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"},
+	}
+}
@@ -0,0 +1,1074 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package graphql provides a GraphQL interface to chain data.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/api"
+	"github.com/n42blockchain/N42/internal/api/filters"
+	mvm_common "github.com/n42blockchain/N42/internal/avm/common"
+	mvm_types "github.com/n42blockchain/N42/internal/avm/types"
+	"github.com/n42blockchain/N42/internal/vm/evmtypes"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+)
+
+const (
+	// callTimeout bounds the execution of `call` queries, matching eth_call.
+	callTimeout = 5 * time.Second
+	// maxBlockRange is the maximum number of blocks returned by `blocks`.
+	maxBlockRange = 1024
+)
+
+var (
+	errBlockInvariant = errors.New("block objects must be instantiated with at least one of num or hash")
+)
+
+// Long is a 64 bit integer, sent as a JSON number and accepted as either a
+// number or a decimal / 0x-prefixed hexadecimal string.
+type Long int64
+
+// ImplementsGraphQLType returns true if Long implements the provided GraphQL type.
+func (b Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (b *Long) UnmarshalGraphQL(input interface{}) error {
+	var err error
+	switch input := input.(type) {
+	case string:
+		if len(input) >= 2 && input[0] == '0' && (input[1] == 'x' || input[1] == 'X') {
+			var value uint64
+			value, err = hexutil.DecodeUint64(input)
+			*b = Long(value)
+		} else {
+			var value int64
+			value, err = strconv.ParseInt(input, 10, 64)
+			*b = Long(value)
+		}
+	case int32:
+		*b = Long(input)
+	case int64:
+		*b = Long(input)
+	case float64:
+		*b = Long(input)
+	default:
+		err = fmt.Errorf("unexpected type %T for Long", input)
+	}
+	return err
+}
+
+// Account represents an account at a specific block.
+type Account struct {
+	r             *Resolver
+	address       types.Address
+	blockNrOrHash jsonrpc.BlockNumberOrHash
+}
+
+// withState runs fn against the account's state at the selected block.
+func (a *Account) withState(ctx context.Context, fn func(evmtypes.IntraBlockState) error) error {
+	tx, err := a.r.api.Database().BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state := a.r.api.State(tx, a.blockNrOrHash)
+	if state == nil {
+		return fmt.Errorf("state for block %s not found", a.blockNrOrHash.String())
+	}
+	return fn(state)
+}
+
+func (a *Account) Address(ctx context.Context) (mvm_common.Address, error) {
+	return *mvm_types.FromastAddress(&a.address), nil
+}
+
+func (a *Account) Balance(ctx context.Context) (hexutil.Big, error) {
+	var balance hexutil.Big
+	err := a.withState(ctx, func(state evmtypes.IntraBlockState) error {
+		balance = hexutil.Big(*state.GetBalance(a.address).ToBig())
+		return nil
+	})
+	return balance, err
+}
+
+func (a *Account) TransactionCount(ctx context.Context) (Long, error) {
+	var nonce Long
+	err := a.withState(ctx, func(state evmtypes.IntraBlockState) error {
+		nonce = Long(state.GetNonce(a.address))
+		return nil
+	})
+	return nonce, err
+}
+
+func (a *Account) Code(ctx context.Context) (hexutil.Bytes, error) {
+	var code hexutil.Bytes
+	err := a.withState(ctx, func(state evmtypes.IntraBlockState) error {
+		code = state.GetCode(a.address)
+		return nil
+	})
+	return code, err
+}
+
+func (a *Account) Storage(ctx context.Context, args struct{ Slot mvm_common.Hash }) (mvm_common.Hash, error) {
+	var value uint256.Int
+	err := a.withState(ctx, func(state evmtypes.IntraBlockState) error {
+		slot := mvm_types.ToastHash(args.Slot)
+		state.GetState(a.address, &slot, &value)
+		return nil
+	})
+	return mvm_common.Hash(value.Bytes32()), err
+}
+
+// Log represents an individual log message. All arguments are mandatory.
+type Log struct {
+	r           *Resolver
+	transaction *Transaction
+	log         *block.Log
+}
+
+func (l *Log) Transaction(ctx context.Context) *Transaction {
+	return l.transaction
+}
+
+func (l *Log) Account(ctx context.Context, args BlockNumberArgs) *Account {
+	return &Account{
+		r:             l.r,
+		address:       l.log.Address,
+		blockNrOrHash: args.NumberOrLatest(),
+	}
+}
+
+func (l *Log) Index(ctx context.Context) Long {
+	return Long(l.log.Index)
+}
+
+func (l *Log) Topics(ctx context.Context) []mvm_common.Hash {
+	topics := make([]mvm_common.Hash, len(l.log.Topics))
+	for i, topic := range l.log.Topics {
+		topics[i] = mvm_types.FromastHash(topic)
+	}
+	return topics
+}
+
+func (l *Log) Data(ctx context.Context) hexutil.Bytes {
+	return l.log.Data
+}
+
+// Transaction represents a transaction.
+// The transaction and block are lazily loaded from the database or pool.
+type Transaction struct {
+	r     *Resolver
+	hash  types.Hash
+	tx    *transaction.Transaction
+	block *Block
+	index uint64
+}
+
+// resolve returns the internal transaction object, fetching it if needed.
+// It also resolves the block the tx belongs to, unless it is still pending.
+func (t *Transaction) resolve(ctx context.Context) (*transaction.Transaction, *Block, error) {
+	if t.tx != nil {
+		return t.tx, t.block, nil
+	}
+	var (
+		tx        *transaction.Transaction
+		blockHash types.Hash
+		index     uint64
+	)
+	if err := t.r.api.Database().View(ctx, func(db kv.Tx) error {
+		var err error
+		tx, blockHash, _, index, err = rawdb.ReadTransactionByHash(db, t.hash)
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+	if tx != nil {
+		t.tx = tx
+		blockNrOrHash := jsonrpc.BlockNumberOrHashWithHash(blockHash, false)
+		t.block = &Block{
+			r:            t.r,
+			numberOrHash: &blockNrOrHash,
+			hash:         blockHash,
+		}
+		t.index = index
+		return t.tx, t.block, nil
+	}
+	// No finalized transaction, try to retrieve it from the pool
+	t.tx = t.r.api.TxsPool().GetTx(t.hash)
+	return t.tx, nil, nil
+}
+
+func (t *Transaction) Hash(ctx context.Context) mvm_common.Hash {
+	return mvm_types.FromastHash(t.hash)
+}
+
+func (t *Transaction) InputData(ctx context.Context) (hexutil.Bytes, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Bytes{}, err
+	}
+	return tx.Data(), nil
+}
+
+func (t *Transaction) Gas(ctx context.Context) (Long, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return 0, err
+	}
+	return Long(tx.Gas()), nil
+}
+
+func (t *Transaction) GasPrice(ctx context.Context) (hexutil.Big, error) {
+	tx, block, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Big{}, err
+	}
+	if tx.Type() == transaction.DynamicFeeTxType && block != nil {
+		header, err := block.resolveHeader(ctx)
+		if err != nil || header == nil {
+			return hexutil.Big{}, err
+		}
+		if header.BaseFee != nil {
+			price := new(big.Int).Add(header.BaseFee.ToBig(), tx.EffectiveGasTipValue(header.BaseFee).ToBig())
+			return hexutil.Big(*price), nil
+		}
+	}
+	return hexutil.Big(*tx.GasPrice().ToBig()), nil
+}
+
+func (t *Transaction) EffectiveGasPrice(ctx context.Context) (*hexutil.Big, error) {
+	_, block, err := t.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	price, err := t.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+func (t *Transaction) MaxFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil || tx.Type() != transaction.DynamicFeeTxType {
+		return nil, err
+	}
+	return (*hexutil.Big)(tx.GasFeeCap().ToBig()), nil
+}
+
+func (t *Transaction) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil || tx.Type() != transaction.DynamicFeeTxType {
+		return nil, err
+	}
+	return (*hexutil.Big)(tx.GasTipCap().ToBig()), nil
+}
+
+func (t *Transaction) EffectiveTip(ctx context.Context) (*hexutil.Big, error) {
+	tx, block, err := t.resolve(ctx)
+	if err != nil || tx == nil || block == nil {
+		return nil, err
+	}
+	header, err := block.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	baseFee := header.BaseFee
+	if baseFee == nil {
+		baseFee = uint256.NewInt(0)
+	}
+	return (*hexutil.Big)(tx.EffectiveGasTipValue(baseFee).ToBig()), nil
+}
+
+func (t *Transaction) Value(ctx context.Context) (hexutil.Big, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*tx.Value().ToBig()), nil
+}
+
+func (t *Transaction) Nonce(ctx context.Context) (Long, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return 0, err
+	}
+	return Long(tx.Nonce()), nil
+}
+
+func (t *Transaction) To(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	to := tx.To()
+	if to == nil {
+		return nil, nil
+	}
+	return &Account{
+		r:             t.r,
+		address:       *to,
+		blockNrOrHash: args.NumberOrLatest(),
+	}, nil
+}
+
+func (t *Transaction) From(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	var from types.Address
+	if sender := tx.From(); sender != nil {
+		from = *sender
+	}
+	return &Account{
+		r:             t.r,
+		address:       from,
+		blockNrOrHash: args.NumberOrLatest(),
+	}, nil
+}
+
+func (t *Transaction) Block(ctx context.Context) (*Block, error) {
+	_, block, err := t.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (t *Transaction) Index(ctx context.Context) (*Long, error) {
+	_, block, err := t.resolve(ctx)
+	// Pending tx
+	if err != nil || block == nil {
+		return nil, err
+	}
+	index := Long(t.index)
+	return &index, nil
+}
+
+// getReceipt returns the receipt associated with this transaction, if any.
+func (t *Transaction) getReceipt(ctx context.Context) (*block.Receipt, error) {
+	_, block, err := t.resolve(ctx)
+	// Pending tx
+	if err != nil || block == nil {
+		return nil, err
+	}
+	receipts, err := block.resolveReceipts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if t.index >= uint64(len(receipts)) {
+		return nil, nil
+	}
+	return receipts[t.index], nil
+}
+
+func (t *Transaction) Status(ctx context.Context) (*Long, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	if len(receipt.PostState) != 0 {
+		return nil, nil
+	}
+	ret := Long(receipt.Status)
+	return &ret, nil
+}
+
+func (t *Transaction) GasUsed(ctx context.Context) (*Long, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := Long(receipt.GasUsed)
+	return &ret, nil
+}
+
+func (t *Transaction) CumulativeGasUsed(ctx context.Context) (*Long, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := Long(receipt.CumulativeGasUsed)
+	return &ret, nil
+}
+
+func (t *Transaction) CreatedContract(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil || receipt.ContractAddress.IsNull() {
+		return nil, err
+	}
+	return &Account{
+		r:             t.r,
+		address:       receipt.ContractAddress,
+		blockNrOrHash: args.NumberOrLatest(),
+	}, nil
+}
+
+func (t *Transaction) Logs(ctx context.Context) (*[]*Log, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := make([]*Log, 0, len(receipt.Logs))
+	for _, log := range receipt.Logs {
+		ret = append(ret, &Log{
+			r:           t.r,
+			transaction: t,
+			log:         log,
+		})
+	}
+	return &ret, nil
+}
+
+func (t *Transaction) Type(ctx context.Context) (*Long, error) {
+	tx, _, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	txType := Long(tx.Type())
+	return &txType, nil
+}
+
+// Block represents a block. The block and header are lazily loaded, either
+// by number or by hash.
+type Block struct {
+	r            *Resolver
+	numberOrHash *jsonrpc.BlockNumberOrHash
+	hash         types.Hash
+	block        block.IBlock
+	receipts     block.Receipts
+}
+
+// resolve returns the internal block object representing this block, fetching
+// it if necessary.
+func (b *Block) resolve(ctx context.Context) (block.IBlock, error) {
+	if b.block != nil {
+		return b.block, nil
+	}
+	if b.numberOrHash == nil {
+		latest := jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.LatestBlockNumber)
+		b.numberOrHash = &latest
+	}
+	var err error
+	if hash, ok := b.numberOrHash.Hash(); ok {
+		b.block, _ = b.r.api.BlockChain().GetBlockByHash(hash)
+	} else if number, ok := b.numberOrHash.Number(); ok {
+		if number, err = b.r.api.ResolveBlockNumber(number); err != nil {
+			return nil, err
+		}
+		if number < 0 {
+			b.block = b.r.api.BlockChain().CurrentBlock()
+		} else {
+			b.block, err = b.r.api.BlockChain().GetBlockByNumber(uint256.NewInt(uint64(number)))
+		}
+	} else {
+		return nil, errBlockInvariant
+	}
+	if b.block != nil && b.hash == (types.Hash{}) {
+		b.hash = b.block.Hash()
+	}
+	return b.block, err
+}
+
+// resolveHeader returns the header of this block.
+func (b *Block) resolveHeader(ctx context.Context) (*block.Header, error) {
+	blk, err := b.resolve(ctx)
+	if err != nil || blk == nil {
+		return nil, err
+	}
+	header, ok := blk.Header().(*block.Header)
+	if !ok {
+		return nil, fmt.Errorf("unexpected header type %T", blk.Header())
+	}
+	return header, nil
+}
+
+// resolveReceipts returns the list of receipts for this block, fetching them
+// if necessary.
+func (b *Block) resolveReceipts(ctx context.Context) (block.Receipts, error) {
+	if b.receipts == nil {
+		hash, err := b.resolveHash(ctx)
+		if err != nil {
+			return nil, err
+		}
+		receipts, err := b.r.api.BlockChain().GetReceipts(hash)
+		if err != nil {
+			return nil, err
+		}
+		b.receipts = receipts
+	}
+	return b.receipts, nil
+}
+
+func (b *Block) resolveHash(ctx context.Context) (types.Hash, error) {
+	if b.hash != (types.Hash{}) {
+		return b.hash, nil
+	}
+	blk, err := b.resolve(ctx)
+	if err != nil || blk == nil {
+		return types.Hash{}, err
+	}
+	return b.hash, nil
+}
+
+// numberOrHashAt returns a selector pointing at this block's state.
+func (b *Block) numberOrHashAt(ctx context.Context) (jsonrpc.BlockNumberOrHash, error) {
+	hash, err := b.resolveHash(ctx)
+	if err != nil {
+		return jsonrpc.BlockNumberOrHash{}, err
+	}
+	return jsonrpc.BlockNumberOrHashWithHash(hash, false), nil
+}
+
+func (b *Block) Number(ctx context.Context) (Long, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return 0, err
+	}
+	return Long(header.Number.Uint64()), nil
+}
+
+func (b *Block) Hash(ctx context.Context) (mvm_common.Hash, error) {
+	hash, err := b.resolveHash(ctx)
+	return mvm_types.FromastHash(hash), err
+}
+
+func (b *Block) GasLimit(ctx context.Context) (Long, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return 0, err
+	}
+	return Long(header.GasLimit), nil
+}
+
+func (b *Block) GasUsed(ctx context.Context) (Long, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return 0, err
+	}
+	return Long(header.GasUsed), nil
+}
+
+func (b *Block) BaseFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil || header.BaseFee == nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(header.BaseFee.ToBig()), nil
+}
+
+func (b *Block) Parent(ctx context.Context) (*Block, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil || header.Number.IsZero() {
+		return nil, err
+	}
+	blockNrOrHash := jsonrpc.BlockNumberOrHashWithHash(header.ParentHash, false)
+	return &Block{
+		r:            b.r,
+		numberOrHash: &blockNrOrHash,
+		hash:         header.ParentHash,
+	}, nil
+}
+
+func (b *Block) Difficulty(ctx context.Context) (hexutil.Big, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*header.Difficulty.ToBig()), nil
+}
+
+func (b *Block) Timestamp(ctx context.Context) (Long, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return 0, err
+	}
+	return Long(header.Time), nil
+}
+
+func (b *Block) Nonce(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return hexutil.Bytes{}, err
+	}
+	return header.Nonce[:], nil
+}
+
+func (b *Block) MixHash(ctx context.Context) (mvm_common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return mvm_common.Hash{}, err
+	}
+	return mvm_types.FromastHash(header.MixDigest), nil
+}
+
+func (b *Block) TransactionsRoot(ctx context.Context) (mvm_common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return mvm_common.Hash{}, err
+	}
+	return mvm_types.FromastHash(header.TxHash), nil
+}
+
+func (b *Block) StateRoot(ctx context.Context) (mvm_common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return mvm_common.Hash{}, err
+	}
+	return mvm_types.FromastHash(header.Root), nil
+}
+
+func (b *Block) ReceiptsRoot(ctx context.Context) (mvm_common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return mvm_common.Hash{}, err
+	}
+	return mvm_types.FromastHash(header.ReceiptHash), nil
+}
+
+func (b *Block) ExtraData(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return hexutil.Bytes{}, err
+	}
+	return header.Extra, nil
+}
+
+func (b *Block) LogsBloom(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return hexutil.Bytes{}, err
+	}
+	return header.Bloom.Bytes(), nil
+}
+
+func (b *Block) Miner(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	return &Account{
+		r:             b.r,
+		address:       header.Coinbase,
+		blockNrOrHash: args.NumberOrLatest(),
+	}, nil
+}
+
+func (b *Block) TransactionCount(ctx context.Context) (*Long, error) {
+	blk, err := b.resolve(ctx)
+	if err != nil || blk == nil {
+		return nil, err
+	}
+	count := Long(len(blk.Transactions()))
+	return &count, nil
+}
+
+func (b *Block) Transactions(ctx context.Context) (*[]*Transaction, error) {
+	blk, err := b.resolve(ctx)
+	if err != nil || blk == nil {
+		return nil, err
+	}
+	ret := make([]*Transaction, 0, len(blk.Transactions()))
+	for i, tx := range blk.Transactions() {
+		ret = append(ret, &Transaction{
+			r:     b.r,
+			hash:  tx.Hash(),
+			tx:    tx,
+			block: b,
+			index: uint64(i),
+		})
+	}
+	return &ret, nil
+}
+
+func (b *Block) TransactionAt(ctx context.Context, args struct{ Index Long }) (*Transaction, error) {
+	blk, err := b.resolve(ctx)
+	if err != nil || blk == nil {
+		return nil, err
+	}
+	txs := blk.Transactions()
+	if args.Index < 0 || int(args.Index) >= len(txs) {
+		return nil, nil
+	}
+	tx := txs[args.Index]
+	return &Transaction{
+		r:     b.r,
+		hash:  tx.Hash(),
+		tx:    tx,
+		block: b,
+		index: uint64(args.Index),
+	}, nil
+}
+
+// BlockFilterCriteria encapsulates criteria passed to a `logs` accessor inside
+// a block.
+type BlockFilterCriteria struct {
+	Addresses *[]mvm_common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	//
+	// Examples:
+	// {} or nil          matches any topic list
+	// {{A}}              matches topic A in first position
+	// {{}, {B}}          matches any topic in first position, B in second position
+	// {{A}, {B}}         matches topic A in first position, B in second position
+	// {{A, B}}, {C, D}}  matches topic (A OR B) in first position, (C OR D) in second position
+	Topics *[][]mvm_common.Hash
+}
+
+// criteria converts the GraphQL filter arguments into the filters package
+// representation.
+func criteria(addresses *[]mvm_common.Address, topics *[][]mvm_common.Hash) ([]types.Address, [][]types.Hash) {
+	var addrs []types.Address
+	if addresses != nil {
+		for i := range *addresses {
+			addrs = append(addrs, *mvm_types.ToastAddress(&(*addresses)[i]))
+		}
+	}
+	var hashes [][]types.Hash
+	if topics != nil {
+		hashes = make([][]types.Hash, len(*topics))
+		for i, group := range *topics {
+			for _, topic := range group {
+				hashes[i] = append(hashes[i], mvm_types.ToastHash(topic))
+			}
+		}
+	}
+	return addrs, hashes
+}
+
+func runFilter(ctx context.Context, r *Resolver, filter *filters.Filter) ([]*Log, error) {
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Log, 0, len(logs))
+	for _, log := range logs {
+		ret = append(ret, &Log{
+			r:           r,
+			transaction: &Transaction{r: r, hash: log.TxHash},
+			log:         log,
+		})
+	}
+	return ret, nil
+}
+
+func (b *Block) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) ([]*Log, error) {
+	hash, err := b.resolveHash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addresses, topics := criteria(args.Filter.Addresses, args.Filter.Topics)
+	filter := filters.NewBlockFilter(b.r.api, hash, addresses, topics)
+	return runFilter(ctx, b.r, filter)
+}
+
+func (b *Block) Account(ctx context.Context, args struct{ Address mvm_common.Address }) (*Account, error) {
+	blockNrOrHash, err := b.numberOrHashAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		r:             b.r,
+		address:       *mvm_types.ToastAddress(&args.Address),
+		blockNrOrHash: blockNrOrHash,
+	}, nil
+}
+
+// CallData encapsulates arguments to `call` or `estimateGas`.
+// All arguments are optional.
+type CallData struct {
+	From                 *mvm_common.Address // The Ethereum address the call is from.
+	To                   *mvm_common.Address // The Ethereum address the call is to.
+	Gas                  *Long               // The amount of gas provided for the call.
+	GasPrice             *hexutil.Big        // The price of each unit of gas, in wei.
+	MaxFeePerGas         *hexutil.Big        // The max price of each unit of gas, in wei (1559).
+	MaxPriorityFeePerGas *hexutil.Big        // The max tip of each unit of gas, in wei (1559).
+	Value                *hexutil.Big        // The value sent along with the call.
+	Data                 *hexutil.Bytes      // Any data sent with the call.
+}
+
+func (c CallData) toTransactionArgs() api.TransactionArgs {
+	args := api.TransactionArgs{
+		From:                 c.From,
+		To:                   c.To,
+		GasPrice:             c.GasPrice,
+		MaxFeePerGas:         c.MaxFeePerGas,
+		MaxPriorityFeePerGas: c.MaxPriorityFeePerGas,
+		Value:                c.Value,
+		Data:                 c.Data,
+	}
+	if c.Gas != nil {
+		gas := hexutil.Uint64(*c.Gas)
+		args.Gas = &gas
+	}
+	return args
+}
+
+// CallResult encapsulates the result of an invocation of the `call` accessor.
+type CallResult struct {
+	data    hexutil.Bytes // The return data from the call
+	gasUsed Long          // The amount of gas used
+	status  Long          // The return status of the call - 0 for failure or 1 for success.
+}
+
+func (c *CallResult) Data() hexutil.Bytes {
+	return c.data
+}
+
+func (c *CallResult) GasUsed() Long {
+	return c.gasUsed
+}
+
+func (c *CallResult) Status() Long {
+	return c.status
+}
+
+func (b *Block) Call(ctx context.Context, args struct{ Data CallData }) (*CallResult, error) {
+	blockNrOrHash, err := b.numberOrHashAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.DoCall(ctx, b.r.api, args.Data.toTransactionArgs(), blockNrOrHash, nil, callTimeout, b.r.api.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
+	status := Long(1)
+	if result.Failed() {
+		status = 0
+	}
+	return &CallResult{
+		data:    result.Return(),
+		gasUsed: Long(result.UsedGas),
+		status:  status,
+	}, nil
+}
+
+func (b *Block) EstimateGas(ctx context.Context, args struct{ Data CallData }) (Long, error) {
+	blockNrOrHash, err := b.numberOrHashAt(ctx)
+	if err != nil {
+		return 0, err
+	}
+	gas, err := api.DoEstimateGas(ctx, b.r.api, args.Data.toTransactionArgs(), blockNrOrHash, b.r.api.RPCGasCap())
+	return Long(gas), err
+}
+
+// Pending represents the current contents of the transaction pool.
+type Pending struct {
+	r *Resolver
+}
+
+func (p *Pending) pending() []*transaction.Transaction {
+	var txs []*transaction.Transaction
+	for _, list := range p.r.api.TxsPool().Pending(false) {
+		txs = append(txs, list...)
+	}
+	return txs
+}
+
+func (p *Pending) TransactionCount(ctx context.Context) Long {
+	return Long(len(p.pending()))
+}
+
+func (p *Pending) Transactions(ctx context.Context) *[]*Transaction {
+	txs := p.pending()
+	ret := make([]*Transaction, 0, len(txs))
+	for _, tx := range txs {
+		ret = append(ret, &Transaction{
+			r:    p.r,
+			hash: tx.Hash(),
+			tx:   tx,
+		})
+	}
+	return &ret
+}
+
+// Resolver is the top-level object in the GraphQL hierarchy.
+type Resolver struct {
+	api *api.API
+}
+
+func (r *Resolver) Block(ctx context.Context, args struct {
+	Number *Long
+	Hash   *mvm_common.Hash
+}) (*Block, error) {
+	if args.Number != nil && args.Hash != nil {
+		return nil, errors.New("only one of number or hash must be specified")
+	}
+	var numberOrHash jsonrpc.BlockNumberOrHash
+	if args.Number != nil {
+		if *args.Number < 0 {
+			return nil, errors.New("invalid block number")
+		}
+		numberOrHash = jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.BlockNumber(*args.Number))
+	} else if args.Hash != nil {
+		numberOrHash = jsonrpc.BlockNumberOrHashWithHash(mvm_types.ToastHash(*args.Hash), false)
+	} else {
+		numberOrHash = jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.LatestBlockNumber)
+	}
+	blk := &Block{
+		r:            r,
+		numberOrHash: &numberOrHash,
+	}
+	// Resolve the block, return nil if it doesn't exist.
+	b, err := blk.resolve(ctx)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+func (r *Resolver) Blocks(ctx context.Context, args struct {
+	From *Long
+	To   *Long
+}) ([]*Block, error) {
+	current := Long(r.api.BlockChain().CurrentBlock().Number64().Uint64())
+	from := current
+	if args.From != nil {
+		from = *args.From
+	}
+	to := current
+	if args.To != nil && *args.To < current {
+		to = *args.To
+	}
+	if from < 0 || to < from {
+		return []*Block{}, nil
+	}
+	if to-from >= maxBlockRange {
+		return nil, fmt.Errorf("block range too large, max %d", maxBlockRange)
+	}
+	ret := make([]*Block, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		numberOrHash := jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.BlockNumber(i))
+		blk := &Block{
+			r:            r,
+			numberOrHash: &numberOrHash,
+		}
+		// Resolve the block, stop at the first one that doesn't exist.
+		b, err := blk.resolve(ctx)
+		if err != nil {
+			return nil, err
+		} else if b == nil {
+			break
+		}
+		ret = append(ret, blk)
+	}
+	return ret, nil
+}
+
+func (r *Resolver) Pending(ctx context.Context) *Pending {
+	return &Pending{r}
+}
+
+func (r *Resolver) Transaction(ctx context.Context, args struct{ Hash mvm_common.Hash }) (*Transaction, error) {
+	tx := &Transaction{
+		r:    r,
+		hash: mvm_types.ToastHash(args.Hash),
+	}
+	// Resolve the transaction; if it doesn't exist, return nil.
+	t, _, err := tx.resolve(ctx)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (r *Resolver) SendRawTransaction(ctx context.Context, args struct{ Data hexutil.Bytes }) (mvm_common.Hash, error) {
+	return api.NewTransactionAPI(r.api, nil).SendRawTransaction(ctx, args.Data)
+}
+
+// FilterCriteria encapsulates the arguments to `logs` on the root resolver object.
+type FilterCriteria struct {
+	FromBlock *Long                 // beginning of the queried range, nil means latest block
+	ToBlock   *Long                 // end of the range, nil means latest block
+	Addresses *[]mvm_common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	Topics *[][]mvm_common.Hash
+}
+
+func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	begin := jsonrpc.LatestBlockNumber.Int64()
+	if args.Filter.FromBlock != nil {
+		begin = int64(*args.Filter.FromBlock)
+	}
+	end := jsonrpc.LatestBlockNumber.Int64()
+	if args.Filter.ToBlock != nil {
+		end = int64(*args.Filter.ToBlock)
+	}
+	addresses, topics := criteria(args.Filter.Addresses, args.Filter.Topics)
+	filter := filters.NewRangeFilter(r.api, begin, end, addresses, topics)
+	return runFilter(ctx, r, filter)
+}
+
+func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
+	price, err := api.NewastAPI(r.api).GasPrice(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return *price, nil
+}
+
+func (r *Resolver) MaxPriorityFeePerGas(ctx context.Context) (hexutil.Big, error) {
+	tip, err := api.NewastAPI(r.api).MaxPriorityFeePerGas(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return *tip, nil
+}
+
+func (r *Resolver) ChainID(ctx context.Context) (hexutil.Big, error) {
+	return hexutil.Big(*r.api.GetChainConfig().ChainID), nil
+}
+
+// BlockNumberArgs encapsulates arguments to accessors that specify a block number.
+type BlockNumberArgs struct {
+	// TODO: Ideally we could use input unions to allow the query to specify the
+	// block parameter by hash, block number, or tag but input unions aren't part of the
+	// standard GraphQL schema SDL yet, see: https://github.com/graphql/graphql-spec/issues/488
+	Block *Long
+}
+
+// NumberOrLatest returns the value of the block number argument, or the latest
+// block if none was specified.
+func (a BlockNumberArgs) NumberOrLatest() jsonrpc.BlockNumberOrHash {
+	if a.Block != nil {
+		return jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.BlockNumber(*a.Block))
+	}
+	return jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.LatestBlockNumber)
+}
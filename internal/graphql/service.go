@@ -0,0 +1,81 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/n42blockchain/N42/internal/api"
+)
+
+// maxRequestSize is the maximum accepted size of a POSTed query document.
+const maxRequestSize = 5 * 1024 * 1024
+
+type handler struct {
+	schema *graphql.Schema
+}
+
+// ServeHTTP executes a query sent either as a JSON document in a POST body
+// or as the query, operationName and variables URL parameters of a GET.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		params.Query = query.Get("query")
+		params.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &params.Variables); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := h.schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
+// New parses the schema and returns an http.Handler serving GraphQL queries
+// against the chain data exposed by backend.
+func New(backend *api.API) (http.Handler, error) {
+	s, err := graphql.ParseSchema(schema, &Resolver{api: backend})
+	if err != nil {
+		return nil, err
+	}
+	return &handler{schema: s}, nil
+}
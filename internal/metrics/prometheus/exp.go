@@ -12,8 +12,7 @@ var EnabledExpensive = false
 // This function enables metrics reporting separate from pprof.
 func Setup(address string, log log.Logger) *http.ServeMux {
 	prometheusMux := http.NewServeMux()
-
-	prometheusMux.Handle("/debug/metrics/prometheus", Handler(DefaultRegistry))
+	Register(prometheusMux)
 
 	promServer := &http.Server{
 		Addr:    address,
@@ -26,7 +25,16 @@ func Setup(address string, log log.Logger) *http.ServeMux {
 		}
 	}()
 
-	log.Info("Enabling metrics export to prometheus", "path", fmt.Sprintf("http://%s/debug/metrics/prometheus", address))
+	log.Info("Enabling metrics export to prometheus", "path", fmt.Sprintf("http://%s/metrics", address))
 
 	return prometheusMux
 }
+
+// Register serves the metrics at /metrics and /debug/metrics/prometheus of
+// mux, for a server started elsewhere. Only one of Setup and Register may be
+// called.
+func Register(mux *http.ServeMux) {
+	handler := Handler(DefaultRegistry)
+	mux.Handle("/metrics", handler)
+	mux.Handle("/debug/metrics/prometheus", handler)
+}
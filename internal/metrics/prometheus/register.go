@@ -52,6 +52,10 @@ func GetOrCreateCounter(s string, isGauge ...bool) Counter {
 	}
 }
 
+func GetOrCreateGauge(s string) prometheus.Gauge {
+	return defaultSet.GetOrCreateGauge(s)
+}
+
 func GetOrCreateGaugeFunc(s string, f func() float64) prometheus.GaugeFunc {
 	return defaultSet.GetOrCreateGaugeFunc(s, f)
 }
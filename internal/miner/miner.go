@@ -23,6 +23,7 @@ import (
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/conf"
 	"github.com/n42blockchain/N42/internal/consensus"
+	"github.com/n42blockchain/N42/internal/txspool"
 	"github.com/n42blockchain/N42/log"
 	event "github.com/n42blockchain/N42/modules/event/v2"
 	"golang.org/x/sync/errgroup"
@@ -145,6 +146,34 @@ func (m *Miner) SetCoinbase(addr types.Address) {
 	m.worker.setCoinbase(addr)
 }
 
+// SetBundlePool makes the miner include the bundles queued in pool at the
+// top of the blocks it builds.
+func (m *Miner) SetBundlePool(pool *txspool.BundlePool) {
+	m.worker.setBundlePool(pool)
+}
+
 func (m *Miner) PendingBlockAndReceipts() (block.IBlock, block.Receipts) {
 	return m.worker.pendingBlockAndReceipts()
 }
+
+// BuildPayloadArgs contains the fields of a block requested by an external
+// consensus client.
+type BuildPayloadArgs struct {
+	Parent       types.Hash    // The parent block to build on, which must be the chain head
+	Timestamp    uint64        // The timestamp of the block
+	FeeRecipient types.Address // The address receiving the transaction fees
+	Random       types.Hash    // The randomness provided by the consensus client
+	NoTxs        bool          // Flag whether an empty block is expected
+}
+
+// BuildPayload assembles an unsealed block as described by args, together
+// with the receipts of its transactions. The block is not written to the chain.
+func (m *Miner) BuildPayload(args *BuildPayloadArgs) (block.IBlock, block.Receipts, error) {
+	return m.worker.generateWork(&generateParams{
+		timestamp:  args.Timestamp,
+		parentHash: args.Parent,
+		coinbase:   args.FeeRecipient,
+		random:     args.Random,
+		noTxs:      args.NoTxs,
+	})
+}
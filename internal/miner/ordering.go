@@ -0,0 +1,163 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+)
+
+const (
+	// TipOrdering includes the transactions paying the highest effective tip
+	// first, the default.
+	TipOrdering = "tip"
+	// FIFOOrdering includes the transactions in the order they arrived, for
+	// networks with fixed gas prices.
+	FIFOOrdering = "fifo"
+)
+
+// TxOrdering orders the transactions a block is built with.
+type TxOrdering interface {
+	// Order returns the pending transactions of the pool, sorted by nonce
+	// for each account, in the order they are to be included in a block with
+	// the given base fee. Transactions of an account must keep their order.
+	Order(pending map[types.Address][]*transaction.Transaction, baseFee *uint256.Int) []*transaction.Transaction
+}
+
+// TxOrderingFunc adapts a function to the TxOrdering interface.
+type TxOrderingFunc func(pending map[types.Address][]*transaction.Transaction, baseFee *uint256.Int) []*transaction.Transaction
+
+// Order implements TxOrdering.
+func (f TxOrderingFunc) Order(pending map[types.Address][]*transaction.Transaction, baseFee *uint256.Int) []*transaction.Transaction {
+	return f(pending, baseFee)
+}
+
+var (
+	orderingsLock sync.RWMutex
+	orderings     = map[string]TxOrdering{
+		TipOrdering: TxOrderingFunc(func(pending map[types.Address][]*transaction.Transaction, baseFee *uint256.Int) []*transaction.Transaction {
+			return orderHeads(pending, func(a, b *transaction.Transaction) bool {
+				if cmp := a.EffectiveGasTipCmp(b, baseFee); cmp != 0 {
+					return cmp > 0
+				}
+				return a.Time().Before(b.Time())
+			})
+		}),
+		FIFOOrdering: TxOrderingFunc(func(pending map[types.Address][]*transaction.Transaction, _ *uint256.Int) []*transaction.Transaction {
+			return orderHeads(pending, func(a, b *transaction.Transaction) bool {
+				return a.Time().Before(b.Time())
+			})
+		}),
+	}
+)
+
+// RegisterTxOrdering makes ordering selectable with name by the miner config.
+// It panics if name is already registered.
+func RegisterTxOrdering(name string, ordering TxOrdering) {
+	orderingsLock.Lock()
+	defer orderingsLock.Unlock()
+	if _, ok := orderings[name]; ok {
+		panic(fmt.Sprintf("transaction ordering %q registered twice", name))
+	}
+	orderings[name] = ordering
+}
+
+// TxOrderings returns the names of the registered orderings, sorted.
+func TxOrderings() []string {
+	orderingsLock.RLock()
+	defer orderingsLock.RUnlock()
+	names := make([]string, 0, len(orderings))
+	for name := range orderings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LookupTxOrdering returns the ordering registered with name, the tip
+// ordering if name is empty.
+func LookupTxOrdering(name string) (TxOrdering, error) {
+	if name == "" {
+		name = TipOrdering
+	}
+	orderingsLock.RLock()
+	ordering, ok := orderings[name]
+	orderingsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction ordering %q, registered orderings are %v", name, TxOrderings())
+	}
+	return ordering, nil
+}
+
+// orderHeads merges the nonce sorted transactions of the accounts, taking
+// the first of less among the next transaction of every account each time.
+func orderHeads(pending map[types.Address][]*transaction.Transaction, less func(a, b *transaction.Transaction) bool) []*transaction.Transaction {
+	var (
+		heads = &txHeads{less: less}
+		count int
+	)
+	for _, txs := range pending {
+		if len(txs) > 0 {
+			heads.lists = append(heads.lists, txs)
+			count += len(txs)
+		}
+	}
+	heap.Init(heads)
+
+	ordered := make([]*transaction.Transaction, 0, count)
+	for heads.Len() > 0 {
+		txs := heads.lists[0]
+		ordered = append(ordered, txs[0])
+		if len(txs) > 1 {
+			heads.lists[0] = txs[1:]
+			heap.Fix(heads, 0)
+		} else {
+			heap.Pop(heads)
+		}
+	}
+	return ordered
+}
+
+// txHeads is a heap of the remaining transactions of the accounts, ordered
+// by their first transaction.
+type txHeads struct {
+	lists [][]*transaction.Transaction
+	less  func(a, b *transaction.Transaction) bool
+}
+
+func (h *txHeads) Len() int           { return len(h.lists) }
+func (h *txHeads) Less(i, j int) bool { return h.less(h.lists[i][0], h.lists[j][0]) }
+func (h *txHeads) Swap(i, j int)      { h.lists[i], h.lists[j] = h.lists[j], h.lists[i] }
+
+func (h *txHeads) Push(x any) {
+	h.lists = append(h.lists, x.([]*transaction.Transaction))
+}
+
+func (h *txHeads) Pop() any {
+	old := h.lists
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	h.lists = old[:n-1]
+	return x
+}
@@ -25,6 +25,8 @@ import (
 	"github.com/n42blockchain/N42/internal/api"
 	"github.com/n42blockchain/N42/internal/consensus/misc"
 	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	"github.com/n42blockchain/N42/internal/slashing"
+	"github.com/n42blockchain/N42/internal/txspool"
 	"sort"
 	"sync"
 
@@ -124,6 +126,9 @@ const (
 	staleThreshold         = 7
 	resubmitAdjustChanSize = 10
 
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
+
 	// maxRecommitInterval is the maximum time interval to recreate the sealing block with
 	// any newly arrived transactions.
 	maxRecommitInterval = 12 * time.Second
@@ -147,12 +152,16 @@ type intervalAdjust struct {
 
 type worker struct {
 	minerConf conf.MinerConfig
+	extra     []byte       // Extra data of the blocks built
+	tip       *uint256.Int // Minimum tip of the transactions included
+	ordering  TxOrdering   // Order the transactions are included in
 	engine    consensus.Engine
 	chain     common.IBlockChain
 	txsPool   common.ITxsPool
 
 	coinbase    types.Address
 	chainConfig *params.ChainConfig
+	bundles     *txspool.BundlePool
 
 	isLocalBlock func(header *block.Header) bool
 	pendingTasks map[types.Hash]*task
@@ -200,7 +209,22 @@ func newWorker(ctx context.Context, group *errgroup.Group, chainConfig *params.C
 		pendingTasks:     make(map[types.Hash]*task),
 		minerConf:        minerConf,
 		resubmitAdjustCh: make(chan *intervalAdjust, resubmitAdjustChanSize),
+		tip:              uint256.NewInt(0),
+	}
+	if extra := []byte(minerConf.ExtraData); uint64(len(extra)) > params.MaximumExtraDataSize {
+		log.Warn("Miner extra data exceed limit, ignored", "extra", minerConf.ExtraData, "limit", params.MaximumExtraDataSize)
+	} else {
+		worker.extra = extra
+	}
+	if minerConf.GasPrice != nil {
+		worker.tip, _ = uint256.FromBig(minerConf.GasPrice)
+	}
+	ordering, err := LookupTxOrdering(minerConf.TxOrdering)
+	if err != nil {
+		log.Warn("Falling back to the tip ordering of transactions", "err", err)
+		ordering, _ = LookupTxOrdering(TipOrdering)
 	}
+	worker.ordering = ordering
 	recommit := worker.minerConf.Recommit
 	if recommit < minPeriodInterval {
 		recommit = minPeriodInterval
@@ -208,7 +232,7 @@ func newWorker(ctx context.Context, group *errgroup.Group, chainConfig *params.C
 
 	// machine verify
 	group.Go(func() error {
-		return api.MachineVerify(ctx)
+		return api.MachineVerify(ctx, slashing.New(bc.DB()))
 	})
 
 	group.Go(func() error {
@@ -250,12 +274,40 @@ func (w *worker) close() {
 func (w *worker) isRunning() bool {
 	return atomic.LoadInt32(&w.running) == 1
 }
+
+// isOnDemand reports whether the consensus engine only seals blocks that
+// carry transactions, i.e. it is configured with a 0 block period.
+func (w *worker) isOnDemand() bool {
+	switch {
+	case w.chainConfig.Apos != nil:
+		return w.chainConfig.Apos.Period == 0
+	case w.chainConfig.Clique != nil:
+		return w.chainConfig.Clique.Period == 0
+	}
+	return false
+}
+
+// resubmits reports whether the block being sealed is rebuilt every recommit
+// interval with the transactions arrived since. A 0-period engine builds a
+// block as transactions arrive instead, and the APos engine would push the
+// timestamp of a rebuilt block back and void the signatures of the verifiers
+// already collected on it.
+func (w *worker) resubmits() bool {
+	return !w.isOnDemand() && w.chainConfig.Apos == nil
+}
+
 func (w *worker) setCoinbase(addr types.Address) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	w.coinbase = addr
 }
 
+func (w *worker) setBundlePool(pool *txspool.BundlePool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.bundles = pool
+}
+
 func (w *worker) runLoop() error {
 	defer w.cancel()
 	defer w.stop()
@@ -454,7 +506,7 @@ func (w *worker) commitWork(interrupt *atomic.Int32, noempty bool, timestamp int
 		return h
 	}
 
-	err = w.fillTransactions(interrupt, current, ibs, getHeader)
+	err = w.fillTransactions(interrupt, current, ibs, stateReader, getHeader)
 	switch {
 	case err == nil:
 		w.resubmitAdjustCh <- &intervalAdjust{inc: false}
@@ -486,6 +538,46 @@ func (w *worker) commitWork(interrupt *atomic.Int32, noempty bool, timestamp int
 	return nil
 }
 
+// generateWork builds a block on top of the chain head from params without
+// sealing it or writing it to the chain.
+func (w *worker) generateWork(params *generateParams) (block.IBlock, block.Receipts, error) {
+	if head := w.chain.CurrentBlock().Hash(); params.parentHash != head {
+		return nil, nil, fmt.Errorf("parent %v is not the chain head %v", params.parentHash, head)
+	}
+	env, err := w.prepareWork(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The consensus engine may have claimed these for its own use, but the
+	// requested block must carry exactly the given fields.
+	env.header.Coinbase = params.coinbase
+	env.header.MixDigest = params.random
+	env.header.Difficulty = uint256.NewInt(0)
+	env.header.Nonce = block.BlockNonce{}
+
+	tx, err := w.chain.DB().BeginRo(w.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	stateReader := state.NewPlainStateReader(tx)
+	ibs := state.New(stateReader)
+	if !params.noTxs {
+		getHeader := func(hash types.Hash, number uint64) *block.Header {
+			return rawdb.ReadHeader(tx, hash, number)
+		}
+		if err := w.fillTransactions(nil, env, ibs, stateReader, getHeader); err != nil {
+			return nil, nil, err
+		}
+	}
+	iblock, _, _, err := w.engine.FinalizeAndAssemble(w.chain, env.header, ibs, env.txs, nil, env.receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return iblock, env.receipts, nil
+}
+
 // recalcRecommit recalculates the resubmitting interval upon feedback.
 func recalcRecommit(minRecommit, prev time.Duration, target float64, inc bool) time.Duration {
 	var (
@@ -523,6 +615,16 @@ func (w *worker) workLoop(recommit time.Duration) error {
 	newBlockSub := event.GlobalEvent.Subscribe(newBlockCh)
 	defer newBlockSub.Unsubscribe()
 
+	// A 0-period engine (dev mode) refuses to seal empty blocks, so sealing
+	// is driven by incoming transactions instead. Otherwise they are counted
+	// to rebuild the block being sealed on recommit.
+	var txsCh chan common.NewTxsEvent
+	if w.isOnDemand() || w.resubmits() {
+		txsCh = make(chan common.NewTxsEvent, txChanSize)
+		txsSub := event.GlobalEvent.Subscribe(txsCh)
+		defer txsSub.Unsubscribe()
+	}
+
 	timer := time.NewTimer(0)
 	defer timer.Stop()
 	<-timer.C // discard the initial tick
@@ -538,7 +640,7 @@ func (w *worker) workLoop(recommit time.Duration) error {
 			return
 		}
 		timer.Reset(recommit)
-		//atomic.StoreInt32(&w.newTxs, 0)
+		atomic.StoreInt32(&w.newTxs, 0)
 	}
 
 	clearPending := func(number *uint256.Int) {
@@ -562,18 +664,34 @@ func (w *worker) workLoop(recommit time.Duration) error {
 
 		case blockEvent := <-newBlockCh:
 			clearPending(blockEvent.Block.Number64())
+			w.mu.RLock()
+			if w.bundles != nil {
+				w.bundles.SetHead(blockEvent.Block.Number64().Uint64())
+			}
+			w.mu.RUnlock()
 			timestamp = time.Now().Unix()
 			commit(false, commitInterruptNewHead)
 		case err := <-newBlockSub.Err():
 			return err
 
+		case ev := <-txsCh:
+			if !w.isOnDemand() {
+				atomic.AddInt32(&w.newTxs, int32(len(ev.Txs)))
+			} else if w.isRunning() {
+				timestamp = time.Now().Unix()
+				commit(true, commitInterruptNewHead)
+			}
+
 		case <-timer.C:
 			// If sealing is running resubmit a new work cycle periodically to pull in
-			// higher priced transactions. Disable this overhead for pending blocks.
-			//if w.isRunning() && (w.chainConfig.Apos == nil && w.chainConfig.Clique == nil) {
-			//	continue
-			//	commit(false, commitInterruptResubmit)
-			//}
+			// the transactions arrived since. Disable this overhead for pending blocks.
+			if w.isRunning() && w.resubmits() {
+				if atomic.LoadInt32(&w.newTxs) == 0 {
+					timer.Reset(recommit)
+					continue
+				}
+				commit(true, commitInterruptResubmit)
+			}
 		case adjust := <-w.resubmitAdjustCh:
 			// Adjust resubmit interval by feedback.
 			if adjust.inc {
@@ -590,16 +708,12 @@ func (w *worker) workLoop(recommit time.Duration) error {
 	}
 }
 
-func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs *state.IntraBlockState, getHeader func(hash types.Hash, number uint64) *block.Header) error {
+func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs *state.IntraBlockState, stateReader state.StateReader, getHeader func(hash types.Hash, number uint64) *block.Header) error {
 	// todo fillTx
 	env.txs = []*transaction.Transaction{}
-	txs, err := w.txsPool.GetTransaction()
-	if err != nil {
-		log.Warn("get transaction error", "err", err)
-		return err
-	}
-
 	header := env.header
+	txs := w.ordering.Order(w.txsPool.Pending(false), header.BaseFee)
+
 	noop := state.NewNoopWriter()
 	var miningCommitTx = func(txn *transaction.Transaction, coinbase types.Address, vmConfig *vm2.Config, chainConfig *params.ChainConfig, ibs *state.IntraBlockState, current *environment) ([]*block.Log, error) {
 		ibs.Prepare(txn.Hash(), types.Hash{}, env.tcount)
@@ -618,6 +732,38 @@ func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs
 		return receipt.Logs, nil
 	}
 
+	w.mu.RLock()
+	bundles := w.bundles
+	w.mu.RUnlock()
+	if bundles != nil {
+		for _, bundle := range w.acceptBundles(env, bundles, stateReader) {
+			var (
+				snap     = ibs.Snapshot()
+				gasSnap  = env.gasPool.Gas()
+				usedSnap = header.GasUsed
+				txsSnap  = len(env.txs)
+				tcount   = env.tcount
+			)
+			for _, tx := range bundle.Txs {
+				_, err := miningCommitTx(tx, env.coinbase, &vm2.Config{}, w.chainConfig, ibs, env)
+				if err == nil && env.receipts[len(env.receipts)-1].Status == block.ReceiptStatusFailed && !bundle.MayRevert(tx.Hash()) {
+					err = errors.New("reverted")
+				}
+				if err != nil {
+					// Drop the whole bundle, not just the failing transaction
+					log.Warn("Bundle transaction failed after simulation", "bundle", bundle.Hash(), "hash", tx.Hash(), "err", err)
+					ibs.RevertToSnapshot(snap)
+					env.gasPool = new(common.GasPool).AddGas(gasSnap)
+					header.GasUsed = usedSnap
+					env.txs, env.receipts = env.txs[:txsSnap], env.receipts[:txsSnap]
+					env.tcount = tcount
+					break
+				}
+				env.tcount++
+			}
+		}
+	}
+
 	log.Tracef("fillTransactions txs len:%d", len(txs))
 	for _, tx := range txs {
 		// Check interruption signal and abort building if it's fired.
@@ -630,6 +776,10 @@ func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs
 			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
 			break
 		}
+		if tx.EffectiveGasTipIntCmp(w.tip, header.BaseFee) < 0 {
+			log.Trace("Skipping transaction below the miner tip", "hash", tx.Hash(), "tip", w.tip)
+			continue
+		}
 		// Start executing the transaction
 		_, err := miningCommitTx(tx, env.coinbase, &vm2.Config{}, w.chainConfig, ibs, env)
 
@@ -651,6 +801,53 @@ func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs
 	return nil
 }
 
+// acceptBundles returns the bundles queued for the block being built which
+// execute cleanly, in the order they are to be included. Each bundle is first
+// run on a scratch state on top of the bundles already accepted.
+func (w *worker) acceptBundles(env *environment, pool *txspool.BundlePool, stateReader state.StateReader) []*txspool.Bundle {
+	var (
+		accepted []*txspool.Bundle
+		txs      []*transaction.Transaction
+	)
+	for _, bundle := range pool.Bundles(env.header.Number.Uint64(), env.header.Time) {
+		if err := w.simulateBundle(env, stateReader, txs, bundle); err != nil {
+			log.Debug("Skipping bundle", "hash", bundle.Hash(), "err", err)
+			continue
+		}
+		accepted = append(accepted, bundle)
+		txs = append(txs, bundle.Txs...)
+	}
+	return accepted
+}
+
+// simulateBundle runs the accepted transactions followed by bundle on a
+// scratch state, failing if a bundle transaction cannot be applied or reverts
+// without being allowed to.
+func (w *worker) simulateBundle(env *environment, stateReader state.StateReader, accepted []*transaction.Transaction, bundle *txspool.Bundle) error {
+	var (
+		scratch   = state.New(stateReader)
+		header    = block.CopyHeader(env.header)
+		gp        = new(common.GasPool).AddGas(env.gasPool.Gas())
+		noop      = state.NewNoopWriter()
+		txs       = append(append(make([]*transaction.Transaction, 0, len(accepted)+len(bundle.Txs)), accepted...), bundle.Txs...)
+		getHeader = func(hash types.Hash, number uint64) *block.Header {
+			h, _ := w.chain.GetHeader(hash, uint256.NewInt(number)).(*block.Header)
+			return h
+		}
+	)
+	for i, tx := range txs {
+		scratch.Prepare(tx.Hash(), types.Hash{}, env.tcount+i)
+		receipt, _, err := internal.ApplyTransaction(w.chainConfig, internal.GetHashFn(header, getHeader), w.engine, &env.coinbase, gp, scratch, noop, header, tx, &header.GasUsed, vm2.Config{})
+		if err != nil {
+			return fmt.Errorf("transaction %v: %w", tx.Hash(), err)
+		}
+		if i >= len(accepted) && receipt.Status == block.ReceiptStatusFailed && !bundle.MayRevert(tx.Hash()) {
+			return fmt.Errorf("transaction %v reverted", tx.Hash())
+		}
+	}
+	return nil
+}
+
 func (w *worker) prepareWork(param *generateParams) (*environment, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -676,6 +873,7 @@ func (w *worker) prepareWork(param *generateParams) (*environment, error) {
 		Coinbase:   param.coinbase,
 		Number:     uint256.NewInt(0).Add(parent.Number64(), uint256.NewInt(1)),
 		GasLimit:   CalcGasLimit(parent.GasLimit, w.minerConf.GasCeil),
+		Extra:      append([]byte{}, w.extra...),
 		Time:       uint64(timestamp),
 		Difficulty: uint256.NewInt(0),
 		// just for now
@@ -686,7 +884,7 @@ func (w *worker) prepareWork(param *generateParams) (*environment, error) {
 	if w.chainConfig.IsLondon(header.Number.Uint64()) {
 		header.BaseFee, _ = uint256.FromBig(misc.CalcBaseFee(w.chainConfig, parent))
 		if !w.chainConfig.IsLondon(parent.Number64().Uint64()) {
-			parentGasLimit := parent.GasLimit * params.ElasticityMultiplier
+			parentGasLimit := parent.GasLimit * w.chainConfig.ElasticityMultiplier()
 			header.GasLimit = CalcGasLimit(parentGasLimit, w.minerConf.GasCeil)
 		}
 	}
@@ -0,0 +1,218 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/internal/p2p"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+	"github.com/n42blockchain/N42/utils"
+)
+
+// apis returns the collection of built-in RPC APIs of the node. The admin and
+// stage namespaces are only served over the authenticated and IPC endpoints.
+func (n *Node) apis() []jsonrpc.API {
+	return []jsonrpc.API{
+		{
+			Namespace:     "admin",
+			Service:       &adminAPI{n},
+			Authenticated: true,
+		},
+		{
+			Namespace:     "stage",
+			Service:       &stageAPI{n},
+			Authenticated: true,
+		},
+	}
+}
+
+// adminAPI is the collection of administrative API methods exposed over the
+// authenticated RPC endpoints.
+type adminAPI struct {
+	node *Node
+}
+
+// PeerInfo describes a connected peer.
+type PeerInfo struct {
+	ID          string `json:"id"`
+	ENR         string `json:"enr,omitempty"`
+	Address     string `json:"address"`
+	Direction   string `json:"direction"`
+	BlockNumber uint64 `json:"blockNumber"`
+}
+
+// NodeInfo describes the local node.
+type NodeInfo struct {
+	ID             string   `json:"id"`
+	ENR            string   `json:"enr,omitempty"`
+	ListenAddrs    []string `json:"listenAddrs"`
+	DiscoveryAddrs []string `json:"discoveryAddrs"`
+}
+
+// AddPeer connects to the peer at url, given as a multiaddr with a /p2p/
+// component or as an ENR.
+func (api *adminAPI) AddPeer(url string) (bool, error) {
+	info, err := parsePeerAddr(url)
+	if err != nil {
+		return false, err
+	}
+	if err := api.node.p2p.Connect(*info); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePeer disconnects from the peer at url, given as a multiaddr, an ENR
+// or a bare peer ID. Discovery may connect to the peer again later.
+func (api *adminAPI) RemovePeer(url string) (bool, error) {
+	pid, err := peer.Decode(url)
+	if err != nil {
+		info, err := parsePeerAddr(url)
+		if err != nil {
+			return false, err
+		}
+		pid = info.ID
+	}
+	if err := api.node.p2p.Disconnect(pid); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Peers returns the peers the node is currently connected to.
+func (api *adminAPI) Peers() ([]*PeerInfo, error) {
+	status := api.node.p2p.Peers()
+	infos := make([]*PeerInfo, 0)
+	for _, pid := range status.Connected() {
+		info := &PeerInfo{ID: pid.String()}
+		if addr, err := status.Address(pid); err == nil && addr != nil {
+			info.Address = addr.String()
+		}
+		if dir, err := status.Direction(pid); err == nil {
+			info.Direction = strings.ToLower(dir.String())
+		}
+		if record, err := status.ENR(pid); err == nil && record != nil {
+			info.ENR, _ = p2p.SerializeENR(record)
+		}
+		if chainState, err := status.ChainState(pid); err == nil && chainState != nil && chainState.CurrentHeight != nil {
+			info.BlockNumber = utils.ConvertH256ToUint256Int(chainState.CurrentHeight).Uint64()
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// DataDirUsage returns the size of the data directory, its growth rate and
+// when it is projected to reach the quota set with --data.dir.maxsize.
+func (api *adminAPI) DataDirUsage() *DataDirUsage {
+	return api.node.dataDir.lastUsage()
+}
+
+// NodeInfo returns the identity and addresses of the local node.
+func (api *adminAPI) NodeInfo() (*NodeInfo, error) {
+	info := &NodeInfo{
+		ID:             api.node.p2p.PeerID().String(),
+		ListenAddrs:    make([]string, 0),
+		DiscoveryAddrs: make([]string, 0),
+	}
+	if record := api.node.p2p.ENR(); record != nil {
+		info.ENR, _ = p2p.SerializeENR(record)
+	}
+	for _, addr := range api.node.p2p.Host().Addrs() {
+		info.ListenAddrs = append(info.ListenAddrs, addr.String())
+	}
+	addrs, err := api.node.p2p.DiscoveryAddresses()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		info.DiscoveryAddrs = append(info.DiscoveryAddrs, addr.String())
+	}
+	return info, nil
+}
+
+// ReloadJWTSecrets reads the secrets of the authenticated endpoint again,
+// so that secrets can be added or removed without a restart. It returns the
+// number of secrets now accepted.
+func (api *adminAPI) ReloadJWTSecrets() (int, error) {
+	if api.node.jwtKeys == nil {
+		return 0, errors.New("authenticated RPC is not enabled")
+	}
+	return api.node.jwtKeys.reload()
+}
+
+// Backup starts writing a consistent copy of the chain database and the
+// freezer to the data directory dest in the background, reading at most
+// rateLimit MB per second, zero for no limit. The progress is reported by
+// BackupStatus.
+func (api *adminAPI) Backup(dest string, rateLimit int) (*BackupInfo, error) {
+	if rateLimit < 0 {
+		return nil, fmt.Errorf("invalid rate limit %d", rateLimit)
+	}
+	info, err := api.node.startBackup(dest)
+	if err != nil {
+		return nil, err
+	}
+	started := *info
+	go api.node.runBackup(api.node.ctx, info, rateLimit*1024*1024)
+	return &started, nil
+}
+
+// BackupStatus returns the progress of the running backup, or the outcome of
+// the last one.
+func (api *adminAPI) BackupStatus() *BackupInfo {
+	return api.node.BackupStatus()
+}
+
+// stageAPI runs and unwinds the stages of the sync pipeline, for recovery
+// and experimentation.
+type stageAPI struct {
+	node *Node
+}
+
+// Progress returns the last block processed by every stage.
+func (api *stageAPI) Progress(ctx context.Context) ([]StageProgress, error) {
+	return api.node.Stages(ctx)
+}
+
+// Run makes stage process the blocks up to block, and returns once it did.
+func (api *stageAPI) Run(ctx context.Context, stage string, block hexutil.Uint64) error {
+	return api.node.RunStage(ctx, stage, uint64(block))
+}
+
+// Unwind reverts stage, and the stages after it, to block.
+func (api *stageAPI) Unwind(ctx context.Context, stage string, block hexutil.Uint64) error {
+	return api.node.UnwindStage(ctx, stage, uint64(block))
+}
+
+// parsePeerAddr resolves a multiaddr or ENR to the peer's address info.
+func parsePeerAddr(url string) (*peer.AddrInfo, error) {
+	addrs, err := p2p.PeersFromStringAddrs([]string{url})
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("invalid peer address %q", url)
+	}
+	return peer.AddrInfoFromP2pAddr(addrs[0])
+}
@@ -0,0 +1,445 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	log2 "github.com/ledgerwatch/log/v3"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+// backupLogInterval is how often the progress of a backup is logged.
+const backupLogInterval = 20 * time.Second
+
+// backupManifestFile is the file describing a backup within its directory.
+const backupManifestFile = "backup.json"
+
+// restoreSuffix is appended to the database and freezer directories to name
+// the directories a backup is restored to before they are moved in place.
+const restoreSuffix = ".restore"
+
+// BackupManifest describes the chain a backup holds. A full backup is a data
+// directory holding the chain up to Block, an incremental backup holds the
+// blocks from Parent+1 through Block in era files, to be imported on top of
+// the backup it was taken against.
+type BackupManifest struct {
+	Genesis     types.Hash   `json:"genesis"`
+	Block       uint64       `json:"block"`
+	Hash        types.Hash   `json:"hash"`
+	Incremental bool         `json:"incremental"`
+	Parent      uint64       `json:"parent,omitempty"`     // head block of the base backup
+	ParentHash  types.Hash   `json:"parentHash,omitempty"` // head hash of the base backup
+	Files       []BackupFile `json:"files,omitempty"`      // era files of an incremental backup
+	Created     time.Time    `json:"created"`
+}
+
+// BackupFile is an era file of an incremental backup.
+type BackupFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// ReadBackupManifest reads the manifest of the backup in dir.
+func ReadBackupManifest(dir string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(BackupManifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest in %s: %w", dir, err)
+	}
+	return manifest, nil
+}
+
+// WriteBackupManifest writes the manifest of the backup in dir, which fails
+// if dir holds one already.
+func WriteBackupManifest(dir string, manifest *BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, backupManifestFile), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RestoreBackup copies the database and the freezer of the full backup in dir
+// into the data directory of cfg, which must not hold a database yet, and
+// returns the manifest of the backup.
+func RestoreBackup(cfg *conf.Config, dir string) (*BackupManifest, error) {
+	manifest, err := ReadBackupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Incremental {
+		return nil, fmt.Errorf("%s holds an incremental backup", dir)
+	}
+	if cfg.NodeCfg.DataDir == "" {
+		return nil, fmt.Errorf("no data directory")
+	}
+	if err := os.MkdirAll(cfg.NodeCfg.DataDir, 0700); err != nil {
+		return nil, err
+	}
+	lock := flock.New(filepath.Join(cfg.NodeCfg.DataDir, "LOCK"))
+	if locked, err := lock.TryLock(); err != nil {
+		return nil, err
+	} else if !locked {
+		return nil, ErrDatadirUsed
+	}
+	defer lock.Unlock()
+
+	dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+	if _, err := os.Stat(dbPath); !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s already holds a database", cfg.NodeCfg.DataDir)
+	}
+	ancient := cfg.NodeCfg.AncientDir
+	if ancient == "" {
+		ancient = filepath.Join(cfg.NodeCfg.DataDir, ancientDir)
+	}
+	// The copies are moved in place once complete, the database last, so
+	// that an interrupted restore is not mistaken for a complete one.
+	restore := func(src, dst string) error {
+		if err := os.RemoveAll(dst + restoreSuffix); err != nil {
+			return err
+		}
+		if err := copyBackupDir(src, dst+restoreSuffix); err != nil {
+			return err
+		}
+		return os.Rename(dst+restoreSuffix, dst)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ancientDir)); err == nil {
+		if _, err := os.Stat(ancient); !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("freezer %s already exists", ancient)
+		}
+		if err := restore(filepath.Join(dir, ancientDir), ancient); err != nil {
+			return nil, err
+		}
+	}
+	if err := restore(filepath.Join(dir, kv.ChainDB.String()), dbPath); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// copyBackupDir copies the files of the directory src, but the mdbx lock
+// file, to the new directory dst.
+func copyBackupDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0744); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == mdbxLockFile {
+			continue
+		}
+		log.Info("Restoring backup", "file", filepath.Join(src, entry.Name()))
+		if err := copyBackupFile(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyBackupFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// BackupInfo describes the last backup of the node.
+type BackupInfo struct {
+	Dest     string    `json:"dest"`
+	Block    uint64    `json:"block"` // head block of the backup
+	Size     uint64    `json:"size"`  // bytes copied so far
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Running  bool      `json:"running"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// backupState tracks the backup in progress, a node runs one at a time.
+type backupState struct {
+	mu   sync.Mutex
+	info *BackupInfo
+}
+
+// Backup writes a copy of the chain database and the freezer as they are at
+// one point in time to the data directory dest while the node keeps running,
+// reading at most bytesPerSecond bytes per second, zero for no limit. The
+// database is copied within a single read transaction, which sees a
+// consistent snapshot of it. Dest must not hold a database yet.
+func (n *Node) Backup(ctx context.Context, dest string, bytesPerSecond int) (*BackupInfo, error) {
+	info, err := n.startBackup(dest)
+	if err != nil {
+		return nil, err
+	}
+	return n.runBackup(ctx, info, bytesPerSecond)
+}
+
+// BackupStatus returns the running or last backup, nil if there was none.
+func (n *Node) BackupStatus() *BackupInfo {
+	n.backupState.mu.Lock()
+	defer n.backupState.mu.Unlock()
+	if n.backupState.info == nil {
+		return nil
+	}
+	info := *n.backupState.info
+	return &info
+}
+
+func (n *Node) startBackup(dest string) (*BackupInfo, error) {
+	n.backupState.mu.Lock()
+	defer n.backupState.mu.Unlock()
+	if n.backupState.info != nil && n.backupState.info.Running {
+		return nil, fmt.Errorf("backup to %s already running", n.backupState.info.Dest)
+	}
+	if _, err := os.Stat(filepath.Join(dest, kv.ChainDB.String())); !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s already holds a database", dest)
+	}
+	n.backupState.info = &BackupInfo{Dest: dest, Started: time.Now(), Running: true}
+	return n.backupState.info, nil
+}
+
+// runBackup performs the backup started with startBackup and records its
+// outcome.
+func (n *Node) runBackup(ctx context.Context, info *BackupInfo, bytesPerSecond int) (*BackupInfo, error) {
+	err := n.backup(ctx, info, bytesPerSecond)
+	if err != nil {
+		log.Error("Failed to back up database", "dest", info.Dest, "err", err)
+	}
+	return n.finishBackup(err), err
+}
+
+func (n *Node) finishBackup(err error) *BackupInfo {
+	n.backupState.mu.Lock()
+	defer n.backupState.mu.Unlock()
+	info := n.backupState.info
+	info.Running, info.Finished = false, time.Now()
+	if err != nil {
+		info.Error = err.Error()
+	}
+	copied := *info
+	return &copied
+}
+
+func (n *Node) backup(ctx context.Context, info *BackupInfo, bytesPerSecond int) error {
+	throttle := &backupThrottle{ctx: ctx, rate: float64(bytesPerSecond), start: time.Now()}
+	count := func(size int) error {
+		n.backupState.mu.Lock()
+		info.Size += uint64(size)
+		n.backupState.mu.Unlock()
+		return throttle.wait(size)
+	}
+
+	srcTx, err := n.db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcTx.Rollback()
+	var head uint64
+	if number := rawdb.ReadCurrentBlockNumber(srcTx); number != nil {
+		head = *number
+	}
+	manifest := &BackupManifest{Block: head, Created: info.Started}
+	if manifest.Genesis, err = rawdb.ReadCanonicalHash(srcTx, 0); err != nil {
+		return err
+	}
+	if manifest.Hash, err = rawdb.ReadCanonicalHash(srcTx, head); err != nil {
+		return err
+	}
+	n.backupState.mu.Lock()
+	info.Block = head
+	n.backupState.mu.Unlock()
+	log.Info("Backing up database", "dest", info.Dest, "block", head)
+
+	opts := mdbx.NewMDBX(log2.Root()).Path(filepath.Join(info.Dest, kv.ChainDB.String())).Label(kv.ChainDB)
+	if opts, err = tuneDatabase(opts, &n.config.DatabaseCfg); err != nil {
+		return err
+	}
+	dst, err := opts.Open()
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	tables := make([]string, 0, len(n.db.AllTables()))
+	for table, cfg := range n.db.AllTables() {
+		if !cfg.IsDeprecated {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if err := backupTable(ctx, srcTx, dst, table, count); err != nil {
+			return fmt.Errorf("failed to back up table %s: %w", table, err)
+		}
+	}
+	srcTx.Rollback()
+
+	// The freezer is copied after the snapshot of the database was taken, so
+	// it holds all the blocks the snapshot no longer has. The blocks frozen
+	// meanwhile are in both, as after a crash while freezing.
+	if f := n.freezer.freezer; f != nil {
+		log.Info("Backing up freezer", "dest", info.Dest, "blocks", f.Frozen()-1)
+		wrap := func(r io.Reader) io.Reader { return &throttledReader{r: r, count: count} }
+		if err := f.CopyTo(filepath.Join(info.Dest, ancientDir), wrap); err != nil {
+			return err
+		}
+	}
+	if err := WriteBackupManifest(info.Dest, manifest); err != nil {
+		return err
+	}
+	log.Info("Backed up database", "dest", info.Dest, "block", head, "elapsed", time.Since(info.Started))
+	return nil
+}
+
+// backupTable copies table from srcTx to dst in a single write transaction.
+func backupTable(ctx context.Context, srcTx kv.Tx, dst kv.RwDB, table string, count func(int) error) error {
+	src, err := srcTx.Cursor(table)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	total, _ := src.Count()
+
+	return dst.Update(ctx, func(tx kv.RwTx) error {
+		c, err := tx.RwCursor(table)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		dup, isDupSort := c.(kv.RwCursorDupSort)
+
+		logEvery := time.NewTicker(backupLogInterval)
+		defer logEvery.Stop()
+		var copied uint64
+		for k, v, err := src.First(); k != nil; k, v, err = src.Next() {
+			if err != nil {
+				return err
+			}
+			if isDupSort {
+				err = dup.AppendDup(k, v)
+			} else {
+				err = c.Append(k, v)
+			}
+			if err != nil {
+				return err
+			}
+			if err := count(len(k) + len(v)); err != nil {
+				return err
+			}
+			copied++
+			select {
+			case <-logEvery.C:
+				log.Info("Backing up database", "table", table, "entries", copied, "total", total)
+			default:
+			}
+		}
+		return nil
+	})
+}
+
+// backupThrottle delays a backup to read rate bytes per second on average,
+// no limit if rate is not positive.
+type backupThrottle struct {
+	ctx   context.Context
+	rate  float64
+	start time.Time
+	bytes float64
+}
+
+// wait accounts for size bytes read and sleeps while the backup is ahead of
+// the rate.
+func (t *backupThrottle) wait(size int) error {
+	if t.rate <= 0 {
+		return nil
+	}
+	t.bytes += float64(size)
+	ahead := time.Duration(t.bytes/t.rate*float64(time.Second)) - time.Since(t.start)
+	if ahead < 10*time.Millisecond {
+		return nil
+	}
+	timer := time.NewTimer(ahead)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
+
+// throttledReader counts the bytes read from r.
+type throttledReader struct {
+	r     io.Reader
+	count func(int) error
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.count(n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
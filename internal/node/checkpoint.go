@@ -0,0 +1,52 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/params"
+)
+
+// syncCheckpoint returns the checkpoint of the configuration, given as
+// <number>:<hash>, or else the one of the network preset, nil if neither.
+func syncCheckpoint(config *conf.NodeConfig, preset *params.NetworkPreset) (*params.SyncCheckpoint, error) {
+	if config.SyncCheckpoint == "" {
+		if preset != nil {
+			return preset.Checkpoint, nil
+		}
+		return nil, nil
+	}
+	number, hash, ok := strings.Cut(config.SyncCheckpoint, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid sync checkpoint %q, want <number>:<hash>", config.SyncCheckpoint)
+	}
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || n == 0 {
+		return nil, fmt.Errorf("invalid sync checkpoint number %q", number)
+	}
+	h, err := hexutil.Decode(hash)
+	if err != nil || len(h) != types.HashLength {
+		return nil, fmt.Errorf("invalid sync checkpoint hash %q", hash)
+	}
+	return &params.SyncCheckpoint{Number: n, Hash: types.BytesToHash(h)}, nil
+}
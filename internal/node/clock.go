@@ -0,0 +1,119 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/n42blockchain/N42/log"
+)
+
+const (
+	// ntpMeasurements is the number of clock drifts measured against the NTP
+	// server, the highest and lowest of which are dropped.
+	ntpMeasurements = 5
+	// ntpTimeout is how long a reply of the NTP server is waited for.
+	ntpTimeout = 5 * time.Second
+)
+
+// ntpEpoch is the origin of the timestamps of the NTP protocol.
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// checkClock measures the drift of the local clock against the NTP server and
+// warns if it exceeds tolerance, as blocks are then proposed too late to
+// collect their signatures or rejected by the other nodes as from the future.
+func checkClock(server string, tolerance time.Duration) {
+	drift, err := sntpDrift(server, ntpMeasurements)
+	if err != nil {
+		log.Debug("Failed to check the clock against the NTP server", "server", server, "err", err)
+		return
+	}
+	if drift < -tolerance || drift > tolerance {
+		log.Warn("System clock seems off, block proposals and imports may fail. Please enable network time synchronisation in the system settings",
+			"server", server, "drift", drift, "tolerance", tolerance)
+		return
+	}
+	log.Debug("System clock checked against the NTP server", "server", server, "drift", drift)
+}
+
+// sntpDrift returns the average drift of the local clock against the NTP
+// server over the given number of measurements, positive if the local clock
+// is ahead. It is a simple SNTP client, RFC 4330, only reading the transmit
+// time of the replies and halving their round trip.
+func sntpDrift(server string, measurements int) (time.Duration, error) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(server, "123"))
+	if err != nil {
+		return 0, err
+	}
+	// Version 4, client mode
+	request := make([]byte, 48)
+	request[0] = 4<<3 | 3
+
+	drifts := make([]time.Duration, 0, measurements)
+	for i := 0; i < measurements; i++ {
+		drift, err := sntpMeasure(addr, request)
+		if err != nil {
+			return 0, err
+		}
+		drifts = append(drifts, drift)
+	}
+	slices.Sort(drifts)
+	if len(drifts) > 2 {
+		drifts = drifts[1 : len(drifts)-1]
+	}
+	var sum time.Duration
+	for _, drift := range drifts {
+		sum += drift
+	}
+	return sum / time.Duration(len(drifts)), nil
+}
+
+// sntpMeasure sends request to the NTP server at addr and returns the drift of
+// the local clock against its reply.
+func sntpMeasure(addr *net.UDPAddr, request []byte) (time.Duration, error) {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	sent := time.Now()
+	if err := conn.SetDeadline(sent.Add(ntpTimeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+	reply := make([]byte, 48)
+	if n, err := conn.Read(reply); err != nil {
+		return 0, err
+	} else if n < len(reply) {
+		return 0, fmt.Errorf("short NTP reply of %d bytes", n)
+	}
+	elapsed := time.Since(sent)
+
+	// The transmit timestamp is in seconds since the NTP epoch, with 32 bits
+	// of fraction.
+	seconds := uint64(binary.BigEndian.Uint32(reply[40:]))
+	fraction := uint64(binary.BigEndian.Uint32(reply[44:]))
+	remote := ntpEpoch.Add(time.Duration(seconds*1e9 + (fraction*1e9)>>32))
+	return sent.Sub(remote) + elapsed/2, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/turbo/backup"
+)
+
+// The files of an mdbx environment.
+const (
+	mdbxDataFile = "mdbx.dat"
+	mdbxLockFile = "mdbx.lck"
+)
+
+// compactSuffix is appended to the chain database directory to name the
+// directory the compacted copy is written to.
+const compactSuffix = ".compact"
+
+// CompactDatabase copies the chain database of the data directory of cfg into
+// a new file, leaving out the free pages a long running node accumulates, and
+// replaces the database file with the copy once the number of entries of
+// every table is verified. It returns the sizes of the database file before
+// and after. The node must be stopped; an interrupted compaction leaves the
+// database untouched.
+func CompactDatabase(ctx context.Context, cfg *conf.Config) (before, after int64, err error) {
+	if cfg.NodeCfg.DataDir == "" {
+		return 0, 0, fmt.Errorf("no data directory")
+	}
+	lock := flock.New(filepath.Join(cfg.NodeCfg.DataDir, "LOCK"))
+	if locked, err := lock.TryLock(); err != nil {
+		return 0, 0, err
+	} else if !locked {
+		return 0, 0, ErrDatadirUsed
+	}
+	defer lock.Unlock()
+
+	dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+	stat, err := os.Stat(filepath.Join(dbPath, mdbxDataFile))
+	if err != nil {
+		return 0, 0, err
+	}
+	before = stat.Size()
+
+	tmpPath := dbPath + compactSuffix
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return before, 0, err
+	}
+	if err := os.MkdirAll(tmpPath, 0744); err != nil {
+		return before, 0, err
+	}
+	defer os.RemoveAll(tmpPath)
+
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	if err := copyDatabase(ctx, dbPath, tmpPath); err != nil {
+		return before, 0, err
+	}
+
+	// Renaming the file over the database swaps it in atomically, the lock
+	// file of the old database is recreated on the next open.
+	if stat, err = os.Stat(filepath.Join(tmpPath, mdbxDataFile)); err != nil {
+		return before, 0, err
+	}
+	if err := os.Rename(filepath.Join(tmpPath, mdbxDataFile), filepath.Join(dbPath, mdbxDataFile)); err != nil {
+		return before, 0, err
+	}
+	if err := os.Remove(filepath.Join(dbPath, mdbxLockFile)); err != nil && !os.IsNotExist(err) {
+		return before, stat.Size(), err
+	}
+	return before, stat.Size(), nil
+}
+
+// copyDatabase copies the tables of the database at src to a new database at
+// dst one by one, and verifies the copy holds as many entries.
+func copyDatabase(ctx context.Context, src, dst string) error {
+	from, to := backup.OpenPair(src, dst, kv.ChainDB, 0)
+	defer from.Close()
+	defer to.Close()
+
+	tables := make([]string, 0, len(from.AllTables()))
+	for table, cfg := range from.AllTables() {
+		if !cfg.IsDeprecated {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+
+	start := time.Now()
+	for i, table := range tables {
+		log.Info("Compacting database", "table", table, "progress", fmt.Sprintf("%d/%d", i+1, len(tables)), "elapsed", time.Since(start))
+		if err := backup.Kv2kv(ctx, from, to, []string{table}, backup.ReadAheadThreads); err != nil {
+			return err
+		}
+	}
+
+	var want, got []rawdb.TableStats
+	if err := from.View(ctx, func(tx kv.Tx) (err error) {
+		want, err = rawdb.ReadAllTableStats(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := to.View(ctx, func(tx kv.Tx) (err error) {
+		got, err = rawdb.ReadAllTableStats(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	for i := range want {
+		if want[i].Entries != got[i].Entries {
+			return fmt.Errorf("compacted table %s holds %d entries, want %d", want[i].Table, got[i].Entries, want[i].Entries)
+		}
+	}
+	log.Info("Compacted database", "tables", len(tables), "elapsed", time.Since(start))
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/erigontech/mdbx-go/mdbx"
+	mdbx2 "github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/n42blockchain/N42/conf"
+)
+
+// The sync modes of the chain database.
+const (
+	syncModeDurable    = "durable"
+	syncModeSafeNoSync = "safe-nosync"
+)
+
+// safeNoSyncPeriod is how often the commits are flushed to disk in the
+// safe-nosync mode.
+const safeNoSyncPeriod = 5 * time.Second
+
+// tuneDatabase applies the geometry and sync settings of cfg to the options
+// of the chain database. Empty settings keep the defaults of opts.
+func tuneDatabase(opts mdbx2.MdbxOpts, cfg *conf.DatabaseConfig) (mdbx2.MdbxOpts, error) {
+	if cfg.MapSize != "" {
+		size, err := parseDatabaseSize("map size", cfg.MapSize)
+		if err != nil {
+			return opts, err
+		}
+		opts = opts.MapSize(size)
+	}
+	if cfg.GrowthStep != "" {
+		size, err := parseDatabaseSize("growth step", cfg.GrowthStep)
+		if err != nil {
+			return opts, err
+		}
+		opts = opts.GrowthStep(size)
+	}
+	if cfg.PageSize != "" {
+		size, err := parseDatabaseSize("page size", cfg.PageSize)
+		if err != nil {
+			return opts, err
+		}
+		if size < 256 || size > 64*datasize.KB || size&(size-1) != 0 {
+			return opts, fmt.Errorf("invalid database page size %s: must be a power of 2 between 256B and 64KB", cfg.PageSize)
+		}
+		opts = opts.PageSize(size.Bytes())
+	}
+	if cfg.DirtySpace != "" {
+		size, err := parseDatabaseSize("dirty space", cfg.DirtySpace)
+		if err != nil {
+			return opts, err
+		}
+		opts = opts.DirtySpace(size.Bytes())
+	}
+
+	switch cfg.SyncMode {
+	case "", syncModeDurable:
+	case syncModeSafeNoSync:
+		opts = opts.Flags(func(flags uint) uint { return flags&^mdbx.Durable | mdbx.SafeNoSync }).
+			SyncPeriod(safeNoSyncPeriod)
+	default:
+		return opts, fmt.Errorf("unknown database sync mode %q", cfg.SyncMode)
+	}
+	return opts, nil
+}
+
+// readonlyDatabase makes opts open the chain database read-only, taking the
+// geometry set by the process writing to it.
+func readonlyDatabase(opts mdbx2.MdbxOpts) mdbx2.MdbxOpts {
+	return opts.Readonly().Flags(func(flags uint) uint { return flags | mdbx.Accede })
+}
+
+func parseDatabaseSize(name, value string) (datasize.ByteSize, error) {
+	var size datasize.ByteSize
+	if err := size.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid database %s %q: %w", name, value, err)
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("invalid database %s %q", name, value)
+	}
+	return size, nil
+}
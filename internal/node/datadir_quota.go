@@ -0,0 +1,207 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/utils"
+)
+
+const (
+	// dataDirCheckInterval is how often the size of the data directory is
+	// measured.
+	dataDirCheckInterval = time.Minute
+	// dataDirGrowthWindow is the period the growth rate is averaged over.
+	dataDirGrowthWindow = 6 * time.Hour
+)
+
+var (
+	dataDirSizeGauge       = prometheus.GetOrCreateCounter("datadir_size", true)
+	dataDirQuotaGauge      = prometheus.GetOrCreateCounter("datadir_quota", true)
+	dataDirGrowthGauge     = prometheus.GetOrCreateCounter("datadir_growth_per_hour", true)
+	dataDirTimeToFullGauge = prometheus.GetOrCreateCounter("datadir_quota_full_seconds", true)
+)
+
+// DataDirUsage describes the size of the data directory against its quota.
+type DataDirUsage struct {
+	Path        string  `json:"path"`
+	Size        uint64  `json:"size"`
+	Quota       uint64  `json:"quota"`
+	UsedPercent float64 `json:"usedPercent"`
+	// GrowthPerHour is the average growth in bytes per hour over the last six
+	// hours, and ProjectedFull the time the quota is reached at that rate.
+	GrowthPerHour uint64     `json:"growthPerHour"`
+	ProjectedFull *time.Time `json:"projectedFull,omitempty"`
+}
+
+type dataDirSample struct {
+	time time.Time
+	size uint64
+}
+
+// dataDirMonitor tracks the size of the data directory and warns each time
+// its usage crosses one of the warning percentages of the quota.
+type dataDirMonitor struct {
+	path     string
+	quota    uint64    // bytes, zero if there is no quota
+	warnings []float64 // ascending percentages
+
+	mu      sync.Mutex
+	samples []dataDirSample // within dataDirGrowthWindow, oldest first
+	warned  int             // number of warning levels crossed
+}
+
+func newDataDirMonitor(config *conf.NodeConfig) *dataDirMonitor {
+	m := &dataDirMonitor{
+		path:  config.DataDir,
+		quota: uint64(config.MaxDataDirSize) * 1024 * 1024 * 1024,
+	}
+	for _, s := range utils.SplitAndTrim(config.DataDirWarnPercents) {
+		percent, err := strconv.ParseFloat(s, 64)
+		if err != nil || percent <= 0 {
+			log.Warn("Ignoring invalid data directory warning percentage", "percent", s)
+			continue
+		}
+		m.warnings = append(m.warnings, percent)
+	}
+	sort.Float64s(m.warnings)
+	dataDirQuotaGauge.Set(m.quota)
+	return m
+}
+
+// loop measures the data directory until quit is closed.
+func (m *dataDirMonitor) loop(quit <-chan struct{}) {
+	ticker := time.NewTicker(dataDirCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.check(); err != nil {
+			log.Warn("Failed to measure data directory", "path", m.path, "err", err)
+		}
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check measures the data directory and logs a warning if its usage crossed
+// a warning percentage since the last check.
+func (m *dataDirMonitor) check() error {
+	size, err := dirSize(m.path)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	m.samples = append(m.samples, dataDirSample{time: now, size: size})
+	for len(m.samples) > 1 && now.Sub(m.samples[0].time) > dataDirGrowthWindow {
+		m.samples = m.samples[1:]
+	}
+	usage := m.usage()
+	crossed := 0
+	for _, percent := range m.warnings {
+		if usage.UsedPercent >= percent {
+			crossed++
+		}
+	}
+	warn := crossed > m.warned
+	m.warned = crossed
+	m.mu.Unlock()
+
+	dataDirSizeGauge.Set(usage.Size)
+	dataDirGrowthGauge.Set(usage.GrowthPerHour)
+	if usage.ProjectedFull != nil {
+		dataDirTimeToFullGauge.Set(uint64(time.Until(*usage.ProjectedFull).Seconds()))
+	} else {
+		dataDirTimeToFullGauge.Set(0)
+	}
+
+	if warn && m.quota > 0 {
+		ctx := []interface{}{"path", m.path, "size", types.StorageSize(usage.Size), "quota", types.StorageSize(m.quota), "used", strconv.FormatFloat(usage.UsedPercent, 'f', 1, 64) + "%"}
+		if usage.ProjectedFull != nil {
+			ctx = append(ctx, "full", usage.ProjectedFull.Format(time.RFC3339))
+		}
+		log.Warn("Data directory is approaching its size quota", ctx...)
+	}
+	return nil
+}
+
+// lastUsage returns the last measured usage of the data directory.
+func (m *dataDirMonitor) lastUsage() *DataDirUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage()
+}
+
+// usage computes the usage from the samples, the caller must hold m.mu.
+func (m *dataDirMonitor) usage() *DataDirUsage {
+	usage := &DataDirUsage{Path: m.path, Quota: m.quota}
+	if len(m.samples) == 0 {
+		return usage
+	}
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	usage.Size = last.size
+	if m.quota > 0 {
+		usage.UsedPercent = float64(last.size) * 100 / float64(m.quota)
+	}
+	if elapsed := last.time.Sub(first.time); elapsed > 0 && last.size > first.size {
+		usage.GrowthPerHour = uint64(float64(last.size-first.size) / elapsed.Hours())
+		if m.quota > last.size && usage.GrowthPerHour > 0 {
+			full := last.time.Add(time.Duration(float64(m.quota-last.size) / float64(usage.GrowthPerHour) * float64(time.Hour)))
+			usage.ProjectedFull = &full
+		}
+	}
+	return usage
+}
+
+// dirSize returns the total size of the regular files below path.
+func dirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // removed while walking, e.g. a rotated log
+		}
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
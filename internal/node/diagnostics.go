@@ -0,0 +1,111 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// diagnosticsDir is the directory within the datadir holding the
+// diagnostic bundles.
+const diagnosticsDir = "diagnostics"
+
+// WriteDiagnostics captures the state of the node into a new timestamped
+// directory under <datadir>/diagnostics and returns its path. The bundle
+// holds the goroutine stacks, the memory statistics, the sizes of the chain
+// database tables and the connected peers. Parts which cannot be captured are
+// skipped and reported in the returned error.
+func (n *Node) WriteDiagnostics() (string, error) {
+	dir := filepath.Join(n.InstanceDir(), diagnosticsDir, time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	var errs []error
+	if err := writeDiagnosticFile(filepath.Join(dir, "goroutines.txt"), func(f *os.File) error {
+		return pprof.Lookup("goroutine").WriteTo(f, 2)
+	}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := writeDiagnosticJSON(filepath.Join(dir, "memstats.json"), func() (interface{}, error) {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return &stats, nil
+	}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := writeDiagnosticJSON(filepath.Join(dir, "tables.json"), n.tableSizes); err != nil {
+		errs = append(errs, err)
+	}
+	if err := writeDiagnosticJSON(filepath.Join(dir, "peers.json"), func() (interface{}, error) {
+		return (&adminAPI{n}).Peers()
+	}); err != nil {
+		errs = append(errs, err)
+	}
+	return dir, errors.Join(errs...)
+}
+
+// tableSizes returns the size in bytes of every chain database table.
+func (n *Node) tableSizes() (interface{}, error) {
+	sizes := make(map[string]uint64)
+	err := n.db.View(context.Background(), func(tx kv.Tx) error {
+		for _, table := range modules.AstTables() {
+			size, err := tx.BucketSize(table)
+			if err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+			sizes[table] = size
+		}
+		return nil
+	})
+	return sizes, err
+}
+
+func writeDiagnosticFile(path string, write func(f *os.File) error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func writeDiagnosticJSON(path string, collect func() (interface{}, error)) error {
+	v, err := collect()
+	if err != nil {
+		return fmt.Errorf("failed to collect %s: %w", filepath.Base(path), err)
+	}
+	return writeDiagnosticFile(path, func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	})
+}
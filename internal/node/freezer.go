@@ -0,0 +1,166 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+const (
+	// ancientDir is the default directory of the freezer within the datadir.
+	ancientDir = "ancient"
+	// freezeInterval is how often old blocks are moved to the freezer.
+	freezeInterval = time.Minute
+	// freezeBatchSize is the number of blocks moved per write transaction,
+	// which keeps block import waiting for a short time only.
+	freezeBatchSize = 1000
+)
+
+var frozenBlocksGauge = prometheus.GetOrCreateCounter("freezer_blocks", true)
+
+// chainFreezer moves the blocks more than threshold blocks below the head of
+// the chain from the database to the freezer.
+type chainFreezer struct {
+	freezer   *rawdb.Freezer
+	db        kv.RwDB
+	chain     common.IBlockChain
+	threshold uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openFreezer opens the freezer configured in config and makes the chain
+// accessors read from it. It returns nil if freezing is disabled and nothing
+// was frozen before. A read-only freezer is never created.
+func openFreezer(config *conf.NodeConfig, readonly bool) (*rawdb.Freezer, error) {
+	if config.DataDir == "" {
+		return nil, nil
+	}
+	dir := config.AncientDir
+	if dir == "" {
+		dir = filepath.Join(config.DataDir, ancientDir)
+	}
+	if config.FreezeThreshold == 0 || readonly {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	log.Info("Opening freezer", "path", dir, "readonly", readonly)
+	open := rawdb.OpenFreezer
+	if readonly {
+		open = rawdb.OpenFreezerReadonly
+	}
+	f, err := open(dir)
+	if err != nil {
+		return nil, err
+	}
+	rawdb.SetFreezer(f)
+	frozenBlocksGauge.Set(f.Frozen() - 1)
+	return f, nil
+}
+
+func newChainFreezer(f *rawdb.Freezer, db kv.RwDB, chain common.IBlockChain, threshold uint64) *chainFreezer {
+	return &chainFreezer{
+		freezer:   f,
+		db:        db,
+		chain:     chain,
+		threshold: threshold,
+		quit:      make(chan struct{}),
+	}
+}
+
+// start runs the freezer in the background if freezing is enabled.
+func (c *chainFreezer) start() {
+	if c.freezer == nil || c.threshold == 0 {
+		return
+	}
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// stop waits for the batch being frozen and stops the freezer.
+func (c *chainFreezer) stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// close closes the freezer files, the chain accessors stop reading them.
+func (c *chainFreezer) close() error {
+	if c.freezer == nil {
+		return nil
+	}
+	rawdb.SetFreezer(nil)
+	return c.freezer.Close()
+}
+
+func (c *chainFreezer) loop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(freezeInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.freeze(); err != nil {
+			log.Error("Failed to freeze blocks", "err", err)
+		}
+		select {
+		case <-c.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// freeze moves the blocks below the threshold to the freezer, one batch per
+// transaction, until they are all moved or the freezer is stopped.
+func (c *chainFreezer) freeze() error {
+	head := c.chain.CurrentBlock().Number64().Uint64()
+	if head <= c.threshold {
+		return nil
+	}
+	limit := head - c.threshold
+	for c.freezer.Frozen() < limit {
+		start, from := time.Now(), c.freezer.Frozen()
+		var frozen int
+		if err := c.db.Update(context.Background(), func(tx kv.RwTx) (err error) {
+			frozen, err = rawdb.FreezeBlocks(tx, c.freezer, limit, freezeBatchSize)
+			return err
+		}); err != nil {
+			return err
+		}
+		frozenBlocksGauge.Set(c.freezer.Frozen() - 1)
+		log.Info("Moved blocks to the freezer", "from", from, "to", from+uint64(frozen)-1, "elapsed", time.Since(start))
+
+		select {
+		case <-c.quit:
+			return nil
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/utils"
+)
+
+const (
+	// dbHealthTimeout bounds the wait for a write transaction in the database
+	// check, which blocks while a block is being written.
+	dbHealthTimeout = 5 * time.Second
+	// dbHealthInterval is how often the database check runs, the health
+	// endpoint serving its last result.
+	dbHealthInterval = 30 * time.Second
+)
+
+// healthReport is the status served by the health endpoint.
+type healthReport struct {
+	Healthy bool       `json:"healthy"`
+	Sync    syncHealth `json:"sync"`
+	Peers   peerHealth `json:"peers"`
+	Disk    diskHealth `json:"disk"`
+	DB      dbHealth   `json:"db"`
+}
+
+// liveReport is the status served by the liveness probe.
+type liveReport struct {
+	Alive bool `json:"alive"`
+}
+
+// readyReport is the status served by the readiness probe. The node is ready
+// to serve when it is close enough to the head of the chain, has enough peers
+// and its RPC servers are up.
+type readyReport struct {
+	Ready bool       `json:"ready"`
+	Sync  syncHealth `json:"sync"`
+	Peers peerHealth `json:"peers"`
+	RPC   rpcHealth  `json:"rpc"`
+}
+
+// syncHealth reports how far the local chain is behind the best known peer.
+type syncHealth struct {
+	Healthy  bool   `json:"healthy"`
+	Syncing  bool   `json:"syncing"`
+	Current  uint64 `json:"current"`
+	Highest  uint64 `json:"highest"`
+	Distance uint64 `json:"distance"`
+}
+
+// peerHealth compares the connected peers with a minimum.
+type peerHealth struct {
+	Healthy   bool `json:"healthy"`
+	Connected int  `json:"connected"`
+	Minimum   int  `json:"minimum"`
+}
+
+// diskHealth compares the free space of the data directory with the
+// configured minimum, both in bytes.
+type diskHealth struct {
+	Healthy bool   `json:"healthy"`
+	Free    uint64 `json:"free"`
+	Minimum uint64 `json:"minimum"`
+	Error   string `json:"error,omitempty"`
+}
+
+// dbHealth reports whether the chain database accepts write transactions, or
+// read transactions if it is opened read-only.
+type dbHealth struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// rpcHealth reports the listening addresses of the enabled HTTP and WS
+// servers. It is healthy when all of them are up.
+type rpcHealth struct {
+	Healthy bool   `json:"healthy"`
+	HTTP    string `json:"http,omitempty"`
+	WS      string `json:"ws,omitempty"`
+}
+
+// healthHandler serves the report it returns as JSON, with status 200 if ok
+// is set and 503 otherwise.
+type healthHandler func(ctx context.Context) (report interface{}, ok bool)
+
+// ServeHTTP implements http.Handler
+func (h healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report, ok := h(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// healthHandlers returns the health endpoint and the liveness and readiness
+// probes by path.
+func (n *Node) healthHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/health": healthHandler(func(context.Context) (interface{}, bool) {
+			report := n.health()
+			return report, report.Healthy
+		}),
+		"/livez": healthHandler(func(context.Context) (interface{}, bool) {
+			return &liveReport{Alive: true}, true
+		}),
+		"/readyz": healthHandler(func(context.Context) (interface{}, bool) {
+			report := n.ready()
+			return report, report.Ready
+		}),
+	}
+}
+
+// health runs all health checks of the node.
+func (n *Node) health() *healthReport {
+	report := &healthReport{
+		Sync:  n.syncHealth(),
+		Peers: n.peerHealth(n.p2p.GetConfig().MinSyncPeers),
+		Disk:  n.diskHealth(),
+		DB:    n.dbProbe.last(),
+	}
+	report.Sync.Healthy = !report.Sync.Syncing
+	report.Healthy = report.Sync.Healthy && report.Peers.Healthy && report.Disk.Healthy && report.DB.Healthy
+	return report
+}
+
+// Responsive reports whether the chain database accepted a transaction within
+// the last two database checks. A single check timing out behind a long block
+// write does not fail it, a database hanging for longer does.
+func (n *Node) Responsive() bool {
+	return n.dbProbe.passedWithin(2*dbHealthInterval + dbHealthTimeout)
+}
+
+// ready checks the node against the readiness thresholds of the config.
+func (n *Node) ready() *readyReport {
+	report := &readyReport{
+		Sync:  n.syncHealth(),
+		Peers: n.peerHealth(n.config.NodeCfg.ReadyMinPeers),
+		RPC:   n.rpcHealth(),
+	}
+	report.Sync.Healthy = report.Sync.Distance <= n.config.NodeCfg.ReadyMaxBlocksBehind
+	report.Ready = report.Sync.Healthy && report.Peers.Healthy && report.RPC.Healthy
+	return report
+}
+
+func (n *Node) syncHealth() syncHealth {
+	current := n.blockChain.CurrentBlock().Number64()
+	highest, _ := n.p2p.Peers().BestPeers(1, current)
+	status := syncHealth{
+		Syncing: n.is.Syncing(),
+		Current: current.Uint64(),
+		Highest: current.Uint64(),
+	}
+	if highest != nil && highest.Gt(current) {
+		status.Highest = highest.Uint64()
+		status.Distance = status.Highest - status.Current
+	}
+	return status
+}
+
+func (n *Node) peerHealth(minimum int) peerHealth {
+	status := peerHealth{
+		Connected: len(n.p2p.Peers().Connected()),
+		Minimum:   minimum,
+	}
+	status.Healthy = status.Connected >= status.Minimum
+	return status
+}
+
+func (n *Node) diskHealth() diskHealth {
+	status := diskHealth{Minimum: uint64(n.config.NodeCfg.MinFreeDiskSpace) * 1024 * 1024 * 1024}
+	free, err := utils.FreeDiskSpace(n.InstanceDir())
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Free = free
+	status.Healthy = free >= status.Minimum
+	return status
+}
+
+// dbProbe checks periodically that the chain database accepts write
+// transactions, so that the health endpoint does not contend with the block
+// writer on every request.
+type dbProbe struct {
+	db       kv.RwDB
+	readonly bool // only read transactions are opened
+
+	mu      sync.Mutex
+	result  dbHealth
+	healthy time.Time // time of the last passed check
+}
+
+func newDBProbe(db kv.RwDB, readonly bool) *dbProbe {
+	return &dbProbe{db: db, readonly: readonly, result: dbHealth{Error: "not checked yet"}, healthy: time.Now()}
+}
+
+// loop checks the database until quit is closed.
+func (p *dbProbe) loop(quit <-chan struct{}) {
+	ticker := time.NewTicker(dbHealthInterval)
+	defer ticker.Stop()
+	for {
+		result := p.check()
+		p.mu.Lock()
+		p.result = result
+		if result.Healthy {
+			p.healthy = time.Now()
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// last returns the result of the last check.
+func (p *dbProbe) last() dbHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.result
+}
+
+// passedWithin reports whether a check passed within the last d, counting
+// from the creation of the probe.
+func (p *dbProbe) passedWithin(d time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Since(p.healthy) <= d
+}
+
+// check opens and discards a write transaction on the database, a read
+// transaction if it is read-only.
+func (p *dbProbe) check() dbHealth {
+	ctx, cancel := context.WithTimeout(context.Background(), dbHealthTimeout)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		var (
+			tx  kv.Tx
+			err error
+		)
+		if p.readonly {
+			tx, err = p.db.BeginRo(ctx)
+		} else {
+			tx, err = p.db.BeginRw(ctx)
+		}
+		if err == nil {
+			tx.Rollback()
+		}
+		errc <- err
+	}()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = errors.New("timed out waiting for a transaction")
+	}
+	if err != nil {
+		return dbHealth{Error: err.Error()}
+	}
+	return dbHealth{Healthy: true}
+}
+
+func (n *Node) rpcHealth() rpcHealth {
+	status := rpcHealth{Healthy: true}
+	if n.config.NodeCfg.HTTP {
+		if n.http.rpcAllowed() && n.http.running() {
+			status.HTTP = n.http.listenAddr()
+		} else {
+			status.Healthy = false
+		}
+	}
+	if n.config.NodeCfg.WS {
+		if n.ws.wsAllowed() && n.ws.running() {
+			status.WS = n.ws.listenAddr()
+		} else {
+			status.Healthy = false
+		}
+	}
+	return status
+}
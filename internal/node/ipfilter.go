@@ -0,0 +1,92 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseIPNet parses a CIDR range, a bare IP is treated as a single host range.
+func parseIPNet(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
+
+// containsIP reports whether addr lies within any of the networks.
+func containsIP(networks []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAllowlistHandler rejects requests whose remote address is outside the
+// allowed networks. Forwarding headers are deliberately ignored.
+type ipAllowlistHandler struct {
+	allowed []*net.IPNet
+	next    http.Handler
+}
+
+// newIPAllowlistHandler wraps next so that only clients from the given CIDRs
+// reach it. An empty list allows everybody.
+func newIPAllowlistHandler(cidrs []string, next http.Handler) (http.Handler, error) {
+	if len(cidrs) == 0 {
+		return next, nil
+	}
+	h := &ipAllowlistHandler{next: next}
+	for _, cidr := range cidrs {
+		network, err := parseIPNet(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RPC allowed IP range: %v", err)
+		}
+		h.allowed = append(h.allowed, network)
+	}
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *ipAllowlistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !containsIP(h.allowed, host) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
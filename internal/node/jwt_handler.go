@@ -17,6 +17,8 @@
 package node
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
@@ -25,17 +27,17 @@ import (
 
 const jwtExpiryTimeout = 60 * time.Second
 
+var errNoKeyID = errors.New("token has no key id")
+
 type jwtHandler struct {
-	keyFunc func(token *jwt.Token) (interface{}, error)
-	next    http.Handler
+	keys *jwtKeySet
+	next http.Handler
 }
 
 // newJWTHandler creates a http.Handler with jwt authentication support.
-func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+func newJWTHandler(keys *jwtKeySet, next http.Handler) http.Handler {
 	return &jwtHandler{
-		keyFunc: func(token *jwt.Token) (interface{}, error) {
-			return secret, nil
-		},
+		keys: keys,
 		next: next,
 	}
 }
@@ -56,9 +58,7 @@ func (handler *jwtHandler) ServeHTTP(out http.ResponseWriter, r *http.Request) {
 	// We explicitly set only HS256 allowed, and also disables the
 	// claim-check: the RegisteredClaims internally requires 'iat' to
 	// be no later than 'now', but we allow for a bit of drift.
-	token, err := jwt.ParseWithClaims(strToken, &claims, handler.keyFunc,
-		jwt.WithValidMethods([]string{"HS256"}),
-		jwt.WithoutClaimsValidation())
+	token, err := handler.parse(strToken, &claims)
 
 	switch {
 	case err != nil:
@@ -77,3 +77,34 @@ func (handler *jwtHandler) ServeHTTP(out http.ResponseWriter, r *http.Request) {
 		handler.next.ServeHTTP(out, r)
 	}
 }
+
+// parse verifies strToken against the secret named by its "kid" header, or
+// against every secret if it has none.
+func (handler *jwtHandler) parse(strToken string, claims *jwt.RegisteredClaims) (*jwt.Token, error) {
+	parse := func(keyFunc jwt.Keyfunc) (*jwt.Token, error) {
+		*claims = jwt.RegisteredClaims{}
+		return jwt.ParseWithClaims(strToken, claims, keyFunc,
+			jwt.WithValidMethods([]string{"HS256"}),
+			jwt.WithoutClaimsValidation())
+	}
+	token, err := parse(func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errNoKeyID
+		}
+		if secret, ok := handler.keys.secret(kid); ok {
+			return secret, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	})
+	if !errors.Is(err, errNoKeyID) {
+		return token, err
+	}
+	for _, secret := range handler.keys.secrets() {
+		secret := secret
+		if token, err = parse(func(*jwt.Token) (interface{}, error) { return secret, nil }); err == nil {
+			break
+		}
+	}
+	return token, err
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/utils"
+)
+
+// jwtKeySet holds the secrets accepted by the authenticated RPC endpoint.
+// Several secrets can be valid at once, so a consensus client can be moved
+// to a new secret before the old one is removed.
+//
+// The secrets are read from source, a comma separated list of files and
+// directories. The files of a directory named after a key ID, with a .hex
+// extension or none, are secrets; other files are skipped. The key ID of a
+// secret is its file name without extension.
+type jwtKeySet struct {
+	source string
+
+	mu   sync.RWMutex
+	keys map[string][]byte // key id -> secret
+}
+
+// newJWTKeySet creates a key set reading its secrets from source.
+func newJWTKeySet(source string) (*jwtKeySet, error) {
+	set := &jwtKeySet{source: source}
+	if _, err := set.reload(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// reload reads the secrets from the source again, replacing the current
+// ones. It returns the number of secrets loaded, and leaves the current
+// secrets in place if any of them cannot be read.
+func (s *jwtKeySet) reload() (int, error) {
+	var files []string
+	for _, p := range utils.SplitAndTrim(s.source) {
+		info, err := os.Stat(p)
+		if err != nil {
+			return 0, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			file := filepath.Join(p, e.Name())
+			if !isJWTSecretFile(e.Name()) {
+				log.Warn("Skipping file in JWT secret directory", "path", file)
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+
+	keys := make(map[string][]byte, len(files))
+	for _, file := range files {
+		secret, err := readJWTSecret(file)
+		if err != nil {
+			return 0, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if _, ok := keys[kid]; ok {
+			return 0, fmt.Errorf("duplicate JWT key id %q", kid)
+		}
+		keys[kid] = secret
+		log.Info("Loaded JWT secret file", "path", file, "kid", kid, "crc32", fmt.Sprintf("%#x", crc32.ChecksumIEEE(secret)))
+	}
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("no JWT secrets found in %s", s.source)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return len(keys), nil
+}
+
+// secret returns the secret with the given key id.
+func (s *jwtKeySet) secret(kid string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secret, ok := s.keys[kid]
+	return secret, ok
+}
+
+// secrets returns all secrets, ordered by key id.
+func (s *jwtKeySet) secrets() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	kids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	secrets := make([][]byte, len(kids))
+	for i, kid := range kids {
+		secrets[i] = s.keys[kid]
+	}
+	return secrets
+}
+
+// jwtKeyID matches the key IDs of the secret files of a directory.
+var jwtKeyID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// isJWTSecretFile reports whether the file name of a directory entry is that
+// of a secret.
+func isJWTSecretFile(name string) bool {
+	ext := filepath.Ext(name)
+	return (ext == "" || ext == ".hex") && jwtKeyID.MatchString(strings.TrimSuffix(name, ext))
+}
+
+// readJWTSecret reads a hex encoded 32 byte secret from file.
+func readJWTSecret(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hexutil.Decode(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT secret %s: %w", file, err)
+	}
+	if len(secret) != 32 {
+		log.Error("Invalid JWT secret", "path", file, "length", len(secret))
+		return nil, fmt.Errorf("invalid JWT secret %s", file)
+	}
+	return secret, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	"github.com/n42blockchain/N42/log"
+	event "github.com/n42blockchain/N42/modules/event/v2"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+const (
+	// logIndexBatchSize is the number of blocks indexed per write
+	// transaction.
+	logIndexBatchSize = 1000
+	// logIndexInterval is how often the index catches up with the chain when
+	// no new block was announced.
+	logIndexInterval = time.Minute
+	// logIndexReorgDepth is how far back indexing restarts after a reorg
+	// whose dropped blocks are no longer known.
+	logIndexReorgDepth = 1024
+)
+
+var logIndexHeadGauge = prometheus.GetOrCreateCounter("logindex_head", true)
+
+// logIndexer keeps the log index, which eth_getLogs consults to find the
+// blocks holding matching logs, up to date with the chain.
+type logIndexer struct {
+	db      kv.RwDB
+	chain   common.IBlockChain
+	enabled bool
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newLogIndexer(db kv.RwDB, chain common.IBlockChain, enabled bool) *logIndexer {
+	return &logIndexer{
+		db:      db,
+		chain:   chain,
+		enabled: enabled,
+		quit:    make(chan struct{}),
+	}
+}
+
+// start runs the indexer in the background if it is enabled.
+func (l *logIndexer) start() {
+	if !l.enabled {
+		return
+	}
+	l.wg.Add(1)
+	go l.loop()
+}
+
+// stop waits for the batch being indexed and stops the indexer.
+func (l *logIndexer) stop() {
+	close(l.quit)
+	l.wg.Wait()
+}
+
+func (l *logIndexer) loop() {
+	defer l.wg.Done()
+
+	headCh := make(chan common.ChainHighestBlock, 10)
+	headSub := event.GlobalEvent.Subscribe(headCh)
+	defer headSub.Unsubscribe()
+	ticker := time.NewTicker(logIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.index(); err != nil {
+			log.Error("Failed to index logs", "err", err)
+		}
+		select {
+		case <-l.quit:
+			return
+		case <-headSub.Err():
+			return
+		case <-headCh:
+		case <-ticker.C:
+		}
+	}
+}
+
+// index adds the blocks up to the head of the chain to the index, one batch
+// per transaction, until they are all indexed or the indexer is stopped.
+func (l *logIndexer) index() error {
+	from, err := l.resume()
+	if err != nil {
+		return err
+	}
+	head := l.chain.CurrentBlock().Number64().Uint64()
+	start := time.Now()
+	for from <= head {
+		to := min(from+logIndexBatchSize-1, head)
+		if err := l.db.Update(context.Background(), func(tx kv.RwTx) error {
+			return rawdb.IndexLogs(tx, from, to)
+		}); err != nil {
+			return err
+		}
+		logIndexHeadGauge.Set(to)
+		if to-from+1 == logIndexBatchSize {
+			log.Info("Indexing logs", "block", to, "head", head, "elapsed", time.Since(start))
+		}
+		from = to + 1
+
+		select {
+		case <-l.quit:
+			return nil
+		default:
+		}
+	}
+	return nil
+}
+
+// resume returns the first block to index: the one after the last indexed
+// block, or after its last ancestor still in the chain if it was reorged out.
+func (l *logIndexer) resume() (uint64, error) {
+	var from uint64
+	err := l.db.View(context.Background(), func(tx kv.Tx) error {
+		number, hash, ok, err := rawdb.ReadLogIndexHead(tx)
+		if err != nil || !ok {
+			return err
+		}
+		for {
+			canonical, err := rawdb.ReadCanonicalHash(tx, number)
+			if err != nil {
+				return err
+			}
+			if canonical == hash {
+				from = number + 1
+				return nil
+			}
+			header := rawdb.ReadHeader(tx, hash, number)
+			if header == nil || number == 0 {
+				from = number - min(number, logIndexReorgDepth)
+				return nil
+			}
+			number, hash = number-1, header.ParentHash
+		}
+	})
+	return from, err
+}
@@ -27,24 +27,27 @@ import (
 	amtdeposit "github.com/n42blockchain/N42/contracts/deposit/AMT"
 	fujideposit "github.com/n42blockchain/N42/contracts/deposit/FUJI"
 	nftdeposit "github.com/n42blockchain/N42/contracts/deposit/NFT"
+	"github.com/n42blockchain/N42/internal/catalyst"
 	"github.com/n42blockchain/N42/internal/debug"
+	"github.com/n42blockchain/N42/internal/graphql"
 	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	"github.com/n42blockchain/N42/internal/otterscan"
 	"github.com/n42blockchain/N42/internal/p2p"
 	astsync "github.com/n42blockchain/N42/internal/sync"
 	initialsync "github.com/n42blockchain/N42/internal/sync/initial-sync"
 	"github.com/n42blockchain/N42/internal/tracers"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
-	"hash/crc32"
 	"net"
+	"net/http"
 	"path"
 	"runtime"
 	"strings"
 
 	"github.com/n42blockchain/N42/internal"
 	"github.com/n42blockchain/N42/internal/api"
+	"github.com/n42blockchain/N42/internal/api/filters"
 
-	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/erigon-lib/kv/memdb"
@@ -71,6 +74,7 @@ import (
 	"github.com/n42blockchain/N42/internal/consensus/apos"
 	"github.com/n42blockchain/N42/internal/miner"
 	"github.com/n42blockchain/N42/internal/txspool"
+	"github.com/n42blockchain/N42/internal/vm"
 	"github.com/n42blockchain/N42/modules/rawdb"
 	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
 	"github.com/n42blockchain/N42/params"
@@ -78,7 +82,10 @@ import (
 	"go.uber.org/zap"
 )
 
-const datadirJWTKey = "jwtsecret" // Path within the datadir to the node's jwt secret
+const (
+	datadirJWTKey       = "jwt.hex"   // Path within the datadir to the node's jwt secret
+	datadirLegacyJWTKey = "jwtsecret" // Path of the jwt secret written by earlier versions
+)
 
 type Node struct {
 	cliCtx       *cli.Context
@@ -114,8 +121,20 @@ type Node struct {
 	ws            *httpServer
 	httpAuth      *httpServer //
 	wsAuth        *httpServer //
+	jwtKeys       *jwtKeySet  // secrets of the authenticated endpoint, nil if it is disabled
 	inprocHandler *jsonrpc.Server
 
+	dataDir  *dataDirMonitor // size of the data directory against its quota
+	freezer  *chainFreezer   // moves old blocks out of the database
+	pruner   *chainPruner    // deletes the history, receipts and tx index of old blocks
+	logIndex *logIndexer     // indexes the addresses and topics of logs
+	dbProbe  *dbProbe        // checks that the database accepts writes
+
+	disabledStages map[string]bool // optional sync stages skipped
+	remoteDB       *remoteDBServer // serves the chain database over gRPC
+
+	backupState backupState // the running or last backup
+
 	keyDir     string // key store directory
 	keyDirTemp bool   // If true, key directory will be removed by Stop
 
@@ -139,15 +158,50 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		genesisHash     types.Hash
 		genesisConfig   *conf.Genesis
 		chainConfig     *params.ChainConfig
+		head            uint64
 		chainKv         kv.RwDB
+		ancients        *rawdb.Freezer
 		err             error
 	)
 
+	prune, err := pruneDistances(&cfg.NodeCfg)
+	if err != nil {
+		return nil, err
+	}
+	disabled, err := disabledStages(&cfg.NodeCfg)
+	if err != nil {
+		return nil, err
+	}
+	if mode := cfg.NodeCfg.SyncMode; mode != "" && mode != "full" && mode != "snap" {
+		return nil, fmt.Errorf("unknown sync mode %q, want full or snap", mode)
+	}
+	transaction.SetSenderWorkers(cfg.NodeCfg.SenderWorkers)
+
 	//
 	chainKv, err = OpenDatabase(cfg, nil, kv.ChainDB.String())
 	if nil != err {
 		return nil, err
 	}
+	readonly := cfg.DatabaseCfg.ReadOnly
+	if readonly {
+		err = rawdb.CheckSchemaVersion(ctx, chainKv)
+	} else {
+		err = rawdb.MigrateSchema(ctx, chainKv)
+	}
+	if err != nil {
+		chainKv.Close()
+		return nil, err
+	}
+	if ancients, err = openFreezer(&cfg.NodeCfg, readonly); err != nil {
+		return nil, err
+	}
+	rawdb.SetCacheSizes(cfg.DatabaseCfg.HeaderCache, cfg.DatabaseCfg.BodyCache, cfg.DatabaseCfg.ReceiptCache)
+	rawdb.SetTxIndex(!disabled[rawdb.StageTxIndex])
+	if !readonly {
+		if err := setupTxIndex(ctx, chainKv, !disabled[rawdb.StageTxIndex]); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := chainKv.View(ctx, func(tx kv.Tx) error {
 		//
@@ -170,16 +224,25 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		if genesisBlock, err = rawdb.ReadBlockByHash(tx, genesisHash); genesisBlock == nil {
 			return fmt.Errorf("genesisBlock is missing err:%w", err)
 		}
+		if number := rawdb.ReadCurrentBlockNumber(tx); number != nil {
+			head = *number
+		}
 
 		return nil
 	}); err != nil {
 		return nil, err
 	}
 
+	preset := params.NetworkPresetByName(cfg.NodeCfg.Chain)
 	if genesisHash == (types.Hash{}) {
-		genesisHash = *params.GenesisHashByChainName(cfg.NodeCfg.Chain)
+		if readonly {
+			return nil, fmt.Errorf("database opened read-only has no genesis block")
+		}
+		if preset == nil {
+			return nil, fmt.Errorf("unknown chain %q, initialise the datadir with a genesis file first", cfg.NodeCfg.Chain)
+		}
 		genesisConfig = internal.GenesisByChainName(cfg.NodeCfg.Chain)
-		chainConfig = params.ChainConfigByChainName(cfg.NodeCfg.Chain)
+		chainConfig = preset.ChainConfig
 		if err := chainKv.Update(ctx, func(tx kv.RwTx) error {
 			var genesisErr error
 			genesisBlock, genesisErr = WriteGenesisBlock(tx, genesisConfig)
@@ -190,27 +253,51 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		}); err != nil {
 			return nil, err
 		}
+		genesisHash = genesisBlock.Hash()
 	}
 
 	// update ChainConfig everytime
-	if cfg.NodeCfg.Chain != "private" {
-		if err := chainKv.Update(ctx, func(tx kv.RwTx) error {
-			genesisHash = *params.GenesisHashByChainName(cfg.NodeCfg.Chain)
-			genesisConfig = internal.GenesisByChainName(cfg.NodeCfg.Chain)
-			if err := WriteChainConfig(tx, genesisHash, genesisConfig); err != nil {
-				return err
+	if preset != nil {
+		// The canonical genesis hash stored is what the chain was built on,
+		// compare it rather than the hash of the decoded genesis block.
+		if preset.GenesisHash != (types.Hash{}) && preset.GenesisHash != genesisHash {
+			return nil, fmt.Errorf("database genesis %s does not match the %s genesis %s, run a network initialised with a genesis file with --chain private", genesisHash, preset.Name, preset.GenesisHash)
+		}
+		// The stored config must not reschedule the forks the chain has
+		// already passed, or the blocks would be executed differently.
+		if chainConfig != nil && chainConfig != preset.ChainConfig {
+			if compatErr := chainConfig.CheckCompatible(preset.ChainConfig, head); compatErr != nil {
+				if !cfg.NodeCfg.OverrideChainConfig {
+					return nil, fmt.Errorf("the %s chain config is incompatible with the database at block %d: %w, set the head to block %d first or start with --override.chainconfig", preset.Name, head, compatErr, compatErr.RewindTo)
+				}
+				log.Warn("Overriding incompatible chain config", "chain", preset.Name, "head", head, "err", compatErr)
 			}
-			return nil
-		}); err != nil {
-			return nil, err
 		}
+		if !readonly {
+			if err := chainKv.Update(ctx, func(tx kv.RwTx) error {
+				genesisConfig = internal.GenesisByChainName(cfg.NodeCfg.Chain)
+				if err := WriteChainConfig(tx, genesisHash, genesisConfig); err != nil {
+					return err
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+		chainConfig = preset.ChainConfig
 	}
 
-	// Acquire the instance directory lock.
-	if err := node.openDataDir(cfg); err != nil {
-		return nil, err
+	// Acquire the instance directory lock, which the node owning the data
+	// directory holds when it is opened read-only.
+	if !readonly {
+		if err := node.openDataDir(cfg); err != nil {
+			return nil, err
+		}
 	}
 
+	if err := vm.CheckPrecompiles(chainConfig); err != nil {
+		return nil, fmt.Errorf("invalid chain config: %w", err)
+	}
 	cfg.ChainCfg = chainConfig
 
 	p2p, err := p2p.NewService(ctx, genesisBlock.Hash(), cfg.P2PCfg, cfg.NodeCfg)
@@ -218,16 +305,20 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		return nil, err
 	}
 
-	switch cfg.ChainCfg.Consensus {
-	case params.CliqueConsensus:
-		engine = apoa.New(cfg.ChainCfg.Clique, chainKv)
-	case params.AposConsensu:
-		engine = apos.New(cfg.ChainCfg.Apos, chainKv, cfg.ChainCfg)
-	default:
-		return nil, fmt.Errorf("invalid engine name %s", cfg.ChainCfg.Consensus)
+	// The engines register themselves, see consensus.Register.
+	if engine, err = consensus.New(cfg.ChainCfg, chainKv); err != nil {
+		return nil, err
+	}
+	if timing, ok := engine.(consensus.ProposalTiming); ok {
+		timing.SetProposalTiming(cfg.NodeCfg.ProposalDeadline, cfg.NodeCfg.MaxFutureDrift)
 	}
 
 	bc, _ := internal.NewBlockChain(ctx, genesisBlock, engine, chainKv, p2p, cfg.ChainCfg)
+	if chain, ok := bc.(*internal.BlockChain); ok {
+		chain.SetPersistBadBlocks(cfg.NodeCfg.PersistBadBlocks)
+		chain.SetExecWorkers(cfg.NodeCfg.ExecWorkers)
+		chain.SetMaxReorgDepth(cfg.NodeCfg.ReorgMaxDepth)
+	}
 
 	if cfg.ChainCfg.Apos != nil {
 		depositContracts := make(map[types.Address]deposit.DepositContract, 0)
@@ -258,9 +349,15 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 
 	pool, _ := txspool.NewTxsPool(ctx, bc, depositContract)
 
+	checkpoint, err := syncCheckpoint(&cfg.NodeCfg, preset)
+	if err != nil {
+		return nil, err
+	}
 	is := initialsync.NewService(ctx, &initialsync.Config{
-		Chain: bc,
-		P2P:   p2p,
+		Chain:      bc,
+		P2P:        p2p,
+		SnapSync:   cfg.NodeCfg.SyncMode == "snap",
+		Checkpoint: checkpoint,
 	})
 
 	syncServer := astsync.NewService(
@@ -285,6 +382,9 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		}
 	}
 
+	if _, err := miner.LookupTxOrdering(cfg.Miner.TxOrdering); err != nil {
+		return nil, err
+	}
 	miner := miner.NewMiner(ctx, cfg, bc, engine, pool, nil)
 
 	keyDir, isEphem, err := getKeyStoreDir(&cfg.NodeCfg)
@@ -317,7 +417,15 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		wsAuth:        newHTTPServer(),
 		httpAuth:      newHTTPServer(),
 		ipc:           newIPCServer(&cfg.NodeCfg),
-		etherbase:     types.HexToAddress(cfg.Miner.Etherbase),
+		dataDir:       newDataDirMonitor(&cfg.NodeCfg),
+		freezer:       newChainFreezer(ancients, chainKv, bc, cfg.NodeCfg.FreezeThreshold),
+		pruner:        newChainPruner(chainKv, bc, prune),
+		logIndex:      newLogIndexer(chainKv, bc, !disabled[rawdb.StageLogIndex]),
+		dbProbe:       newDBProbe(chainKv, cfg.DatabaseCfg.ReadOnly),
+		remoteDB:      newRemoteDBServer(&cfg.NodeCfg, chainKv),
+
+		disabledStages: disabled,
+		etherbase:      types.HexToAddress(cfg.Miner.Etherbase),
 
 		accman:     accman,
 		keyDir:     keyDir,
@@ -351,6 +459,16 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 
 	node.api = api.NewAPI(bc, chainKv, engine, pool, node.AccountManager(), cfg.ChainCfg)
 	node.api.SetGpo(api.NewOracle(bc, miner, cfg.ChainCfg, gpoParams))
+	bundles := txspool.NewBundlePool()
+	miner.SetBundlePool(bundles)
+	node.api.SetBundlePool(bundles)
+	node.api.SetP2P(p2p)
+	node.api.SetSyncStatus(node.syncStatus)
+	node.api.SetFilterTimeout(cfg.NodeCfg.FilterTimeout)
+	node.api.SetLogLimits(filters.LogLimits{
+		MaxBlockRange: cfg.NodeCfg.LogsMaxBlockRange,
+		MaxResults:    cfg.NodeCfg.LogsMaxResults,
+	})
 	return &node, nil
 }
 
@@ -370,12 +488,15 @@ func (n *Node) Start() error {
 	n.state = runningState
 	n.lock.Unlock()
 
-	if err := n.blockChain.Start(); err != nil {
-		log.Errorf("failed setup blockChain service, err: %v", err)
-		return err
+	readonly := n.config.DatabaseCfg.ReadOnly
+	if !readonly {
+		if err := n.blockChain.Start(); err != nil {
+			log.Errorf("failed setup blockChain service, err: %v", err)
+			return err
+		}
 	}
 
-	if n.config.NodeCfg.Miner {
+	if n.config.NodeCfg.Miner && !readonly {
 
 		// Configure the local mining address
 		eb, err := n.Etherbase()
@@ -408,27 +529,48 @@ func (n *Node) Start() error {
 		pos.SetBlockChain(n.blockChain)
 	}
 
+	n.rpcAPIs = append(n.rpcAPIs, n.apis()...)
 	n.rpcAPIs = append(n.rpcAPIs, n.engine.APIs(n.blockChain)...)
 	n.rpcAPIs = append(n.rpcAPIs, n.api.Apis()...)
 	n.rpcAPIs = append(n.rpcAPIs, tracers.APIs(n.api)...)
+	n.rpcAPIs = append(n.rpcAPIs, otterscan.APIs(n.api)...)
 	n.rpcAPIs = append(n.rpcAPIs, debug.APIs()...)
+	n.rpcAPIs = append(n.rpcAPIs, catalyst.APIs(n.blockChain, n.miner)...)
 
 	if err := n.startRPC(); err != nil {
 		log.Error("failed start jsonrpc service", zap.Error(err))
 		return err
 	}
+	if err := n.remoteDB.start(n.ctx); err != nil {
+		log.Error("Failed to start the remote database server", "err", err)
+		return err
+	}
+
+	n.SetupMetrics(n.config.MetricsCfg)
+	go n.dataDir.loop(n.shutDown)
+	go n.dbProbe.loop(n.shutDown)
+	if server := n.config.NodeCfg.NTPServer; server != "" {
+		go checkClock(server, n.config.NodeCfg.MaxFutureDrift)
+	}
+
+	// A read-only node only serves the chain as found in the database.
+	if readonly {
+		log.Info("Database opened read-only, not syncing", "head", n.blockChain.CurrentBlock().Number64().Uint64())
+		return nil
+	}
 
 	//n.p2p.AddConnectionHandler()
 	n.p2p.Start()
 	n.sync.Start()
 
-	n.SetupMetrics(n.config.MetricsCfg)
-
 	if n.depositContract != nil {
 		n.depositContract.Start()
 	}
 
 	go n.is.Start()
+	n.freezer.start()
+	n.pruner.start()
+	n.logIndex.start()
 
 	log.Debug("node setup success!")
 
@@ -487,67 +629,120 @@ func (n *Node) closeDataDir() {
 	}
 }
 
-// obtainJWTSecret loads the jwt-secret, either from the provided config,
+// obtainJWTSecret loads the jwt-secrets, either from the provided config,
 // or from the default location. If neither of those are present, it generates
 // a new secret and stores to the default location.
-func (n *Node) obtainJWTSecret(cliParam string) ([]byte, error) {
+func (n *Node) obtainJWTSecret(cliParam string) (*jwtKeySet, error) {
 	fileName := cliParam
 	if len(fileName) == 0 {
-		// no path provided, use default
+		// no path provided, use default, keeping a secret generated by an
+		// earlier version so that configured consensus clients still work
 		fileName = path.Join(n.config.NodeCfg.DataDir, datadirJWTKey)
-	}
-	// try reading from file
-	if data, err := os.ReadFile(fileName); err == nil {
-		jwtSecret, err := hexutil.Decode(strings.TrimSpace(string(data)))
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("failed to decode hex (%s) string", strings.TrimSpace(string(data))))
-		}
-		if len(jwtSecret) == 32 {
-			log.Info("Loaded JWT secret file", "path", fileName, "crc32", fmt.Sprintf("%#x", crc32.ChecksumIEEE(jwtSecret)))
-			return jwtSecret, nil
+		if legacy := path.Join(n.config.NodeCfg.DataDir, datadirLegacyJWTKey); !utils.Exists(fileName) && utils.Exists(legacy) {
+			fileName = legacy
 		}
-		log.Error("Invalid JWT secret", "path", fileName, "length", len(jwtSecret))
-		return nil, errors.New("invalid JWT secret")
+	}
+	// a single missing file is generated, lists and directories must exist
+	if strings.Contains(fileName, ",") || utils.Exists(fileName) {
+		return newJWTKeySet(fileName)
 	}
 	// Need to generate one
 	jwtSecret := make([]byte, 32)
-	rand.Read(jwtSecret)
+	if _, err := rand.Read(jwtSecret); err != nil {
+		return nil, errors.Wrap(err, "failed to generate JWT secret")
+	}
 
+	if err := os.MkdirAll(path.Dir(fileName), 0700); err != nil {
+		return nil, err
+	}
 	if err := os.WriteFile(fileName, []byte(hexutil.Encode(jwtSecret)), 0600); err != nil {
 		return nil, err
 	}
 	log.Info("Generated JWT secret", "path", fileName)
-	return jwtSecret, nil
+	return newJWTKeySet(fileName)
 }
 
 func (n *Node) startRPC() error {
 
 	openAPIs, allAPIs := n.getAPIs()
+	httpModules := utils.SplitAndTrim(n.config.NodeCfg.HTTPApi)
+	wsModules := utils.SplitAndTrim(n.config.NodeCfg.WSApi)
+	// A developer network serves every namespace, the authenticated ones
+	// included, over HTTP and WS unless told otherwise.
+	if n.config.NodeCfg.Dev {
+		openAPIs = allAPIs
+		_, available := checkModuleAvailability(nil, allAPIs)
+		if len(httpModules) == 0 {
+			httpModules = available
+		}
+		if len(wsModules) == 0 {
+			wsModules = available
+		}
+	}
+
+	var rateLimit *rateLimitConfig
+	if n.config.NodeCfg.RPCRateLimit > 0 {
+		rateLimit = &rateLimitConfig{
+			RequestsPerSecond: n.config.NodeCfg.RPCRateLimit,
+			Burst:             n.config.NodeCfg.RPCRateBurst,
+			TrustedProxies:    utils.SplitAndTrim(n.config.NodeCfg.RPCTrustedProxies),
+		}
+	}
 
 	if err := n.startInProc(); err != nil {
 		return err
 	}
 
 	if n.ipc.endpoint != "" {
-		//if err := n.ipc.start(n.rpcAPIs); err != nil {
-		//	return err
-		//}
+		if err := n.ipc.start(n.rpcAPIs); err != nil {
+			return err
+		}
+	}
+	if n.config.NodeCfg.GraphQL && !n.config.NodeCfg.HTTP {
+		log.Warn("GraphQL requires the HTTP-RPC server, enable it with --http")
 	}
 	if n.config.NodeCfg.HTTP {
 		//todo []string{"eth", "web3", "debug", "net", "apoa", "txpool", "apos"}
 		config := httpConfig{
 			CorsAllowedOrigins: utils.SplitAndTrim(n.config.NodeCfg.HTTPCors),
 			Vhosts:             []string{"*"},
-			Modules:            utils.SplitAndTrim(n.config.NodeCfg.HTTPApi),
+			Modules:            httpModules,
 			prefix:             "",
+			rateLimit:          rateLimit,
+			gzipMinSize:        n.config.NodeCfg.HTTPGzipMinSize,
+			allowMethods:       utils.SplitAndTrim(n.config.NodeCfg.HTTPAllowMethods),
+			denyMethods:        utils.SplitAndTrim(n.config.NodeCfg.HTTPDenyMethods),
+			accessLogRate:      n.config.NodeCfg.RPCAccessLogRate,
+			accessLogRedact:    utils.SplitAndTrim(n.config.NodeCfg.RPCAccessLogRedact),
+
+			batchItemLimit:         n.config.NodeCfg.BatchRequestLimit,
+			batchResponseSizeLimit: n.config.NodeCfg.BatchResponseMaxSize,
+			executionTimeout:       n.config.NodeCfg.RPCExecutionTimeout,
 		}
 		port, _ := strconv.Atoi(n.config.NodeCfg.HTTPPort)
 		if err := n.http.setListenAddr(n.config.NodeCfg.HTTPHost, port); err != nil {
 			return err
 		}
-		if err := n.http.enableRPC(n.rpcAPIs, config); err != nil {
+		if err := n.http.setTLS(n.config.NodeCfg.HTTPTLSCert, n.config.NodeCfg.HTTPTLSKey); err != nil {
+			return err
+		}
+		if err := n.http.setAllowIPs(n.config.NodeCfg.RPCAllowIPs); err != nil {
+			return err
+		}
+		if err := n.http.enableRPC(openAPIs, config); err != nil {
 			return err
 		}
+		if n.config.NodeCfg.GraphQL {
+			handler, err := graphql.New(n.api)
+			if err != nil {
+				return err
+			}
+			cors, vhosts := utils.SplitAndTrim(n.config.NodeCfg.GraphQLCors), utils.SplitAndTrim(n.config.NodeCfg.GraphQLVHosts)
+			n.http.registerHandler("GraphQL", "/graphql", newRateLimitHandler(rateLimit, NewHTTPHandlerStack(handler, cors, vhosts, nil, config.gzipMinSize)))
+		}
+		for path, handler := range n.healthHandlers() {
+			n.http.registerHandler("Health", path, handler)
+		}
 		if err := n.http.start(); err != nil {
 			return err
 		}
@@ -559,14 +754,31 @@ func (n *Node) startRPC() error {
 		if err := n.ws.setListenAddr(n.config.NodeCfg.WSHost, port); err != nil {
 			return err
 		}
+		if err := n.ws.setTLS(n.config.NodeCfg.WSTLSCert, n.config.NodeCfg.WSTLSKey); err != nil {
+			return err
+		}
+		if err := n.ws.setAllowIPs(n.config.NodeCfg.RPCAllowIPs); err != nil {
+			return err
+		}
 		//todo
 		config := wsConfig{
-			Modules:   utils.SplitAndTrim(n.config.NodeCfg.WSApi),
+			Modules:   wsModules,
 			Origins:   utils.SplitAndTrim(n.config.NodeCfg.WSOrigins),
 			prefix:    "",
-			jwtSecret: []byte{},
+			rateLimit: rateLimit,
+
+			allowMethods: utils.SplitAndTrim(n.config.NodeCfg.WSAllowMethods),
+			denyMethods:  utils.SplitAndTrim(n.config.NodeCfg.WSDenyMethods),
+
+			accessLogRate:   n.config.NodeCfg.RPCAccessLogRate,
+			accessLogRedact: utils.SplitAndTrim(n.config.NodeCfg.RPCAccessLogRedact),
+
+			batchItemLimit:         n.config.NodeCfg.BatchRequestLimit,
+			batchResponseSizeLimit: n.config.NodeCfg.BatchResponseMaxSize,
+			executionTimeout:       n.config.NodeCfg.RPCExecutionTimeout,
+			notificationBuffer:     n.config.NodeCfg.WSNotificationBuffer,
 		}
-		if err := n.ws.enableWS(n.rpcAPIs, config); err != nil {
+		if err := n.ws.enableWS(openAPIs, config); err != nil {
 			return err
 		}
 		if err := n.ws.start(); err != nil {
@@ -576,21 +788,31 @@ func (n *Node) startRPC() error {
 
 	// Configure authenticated API
 	if len(openAPIs) != len(allAPIs) && n.config.NodeCfg.AuthRPC {
-		jwtSecret, err := n.obtainJWTSecret(n.config.NodeCfg.JWTSecret)
+		jwtKeys, err := n.obtainJWTSecret(n.config.NodeCfg.JWTSecret)
 		if err != nil {
 			return err
 		}
+		n.jwtKeys = jwtKeys
 		config := httpConfig{
 			CorsAllowedOrigins: utils.SplitAndTrim(n.config.NodeCfg.HTTPCors),
 			Vhosts:             []string{"*"},
-			Modules:            []string{"admin", "apos"},
+			Modules:            []string{"admin", "apos", "debug", "engine", "eth", "stage", "validator"},
 			prefix:             "",
-			jwtSecret:          jwtSecret,
+			jwtKeys:            jwtKeys,
+			accessLogRate:      n.config.NodeCfg.RPCAccessLogRate,
+			accessLogRedact:    utils.SplitAndTrim(n.config.NodeCfg.RPCAccessLogRedact),
+
+			batchItemLimit:         n.config.NodeCfg.BatchRequestLimit,
+			batchResponseSizeLimit: n.config.NodeCfg.BatchResponseMaxSize,
+			executionTimeout:       n.config.NodeCfg.RPCExecutionTimeout,
 		}
 
 		if err := n.httpAuth.setListenAddr(n.config.NodeCfg.AuthAddr, n.config.NodeCfg.AuthPort); err != nil {
 			return err
 		}
+		if err := n.httpAuth.setAllowIPs(n.config.NodeCfg.RPCAllowIPs); err != nil {
+			return err
+		}
 		if err := n.httpAuth.enableRPC(n.rpcAPIs, config); err != nil {
 			return err
 		}
@@ -602,13 +824,36 @@ func (n *Node) startRPC() error {
 	return nil
 }
 
-func (n *Node) stopRPC() {
-	n.http.stop()
-	n.ws.stop()
+// stopRPC closes the RPC endpoints, giving the HTTP requests in flight until
+// ctx is done to finish.
+func (n *Node) stopRPC(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, h := range []*httpServer{n.http, n.ws, n.httpAuth, n.wsAuth} {
+		wg.Add(1)
+		go func(h *httpServer) {
+			defer wg.Done()
+			h.stop(ctx)
+		}(h)
+	}
+	wg.Wait()
 	n.ipc.stop()
 	n.stopInProc()
 }
 
+// PauseSync stops importing blocks from the network until ResumeSync is
+// called. Blocks produced locally and received over the engine API are still
+// imported.
+func (n *Node) PauseSync() {
+	n.is.Pause()
+	n.sync.Pause()
+}
+
+// ResumeSync imports blocks from the network again after PauseSync.
+func (n *Node) ResumeSync() {
+	n.is.Resume()
+	n.sync.Resume()
+}
+
 // InstanceDir retrieves the instance directory used by the protocol stack.
 func (n *Node) InstanceDir() string {
 	return n.config.NodeCfg.DataDir
@@ -640,17 +885,39 @@ func (n *Node) Close() error {
 }
 
 // stopServices terminates running services, RPC and p2p networking.
-// It is the inverse of Start.
+// It is the inverse of Start. The RPC requests in flight and the block being
+// imported get up to ShutdownTimeout to finish before the node moves on.
 func (n *Node) stopServices() []error {
 	var errs []error
-	n.stopRPC()
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.NodeCfg.ShutdownTimeout)
+	defer cancel()
 
-	n.miner.Close()
+	n.stopRPC(ctx)
+	n.remoteDB.stop(ctx)
+
+	// A read-only node started none of the services syncing the chain.
+	readonly := n.config.DatabaseCfg.ReadOnly
+	if !readonly {
+		n.miner.Close()
+
+		// Stop the sources of new blocks before the chain.
+		if err := n.is.Stop(); err != nil {
+			errs = append(errs, err)
+		}
 
-	if err := n.blockChain.Close(); err != nil {
+		if err := n.sync.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := n.closeBlockChain(ctx); err != nil {
 		errs = append(errs, err)
 	}
 
+	n.freezer.stop()
+	n.pruner.stop()
+	n.logIndex.stop()
+
 	if err := n.engine.Close(); err != nil {
 		errs = append(errs, err)
 	}
@@ -659,11 +926,11 @@ func (n *Node) stopServices() []error {
 		errs = append(errs, err)
 	}
 
-	if err := n.depositContract.Stop(); err != nil {
-		errs = append(errs, err)
+	if readonly {
+		return errs
 	}
 
-	if err := n.is.Stop(); err != nil {
+	if err := n.depositContract.Stop(); err != nil {
 		errs = append(errs, err)
 	}
 
@@ -671,13 +938,26 @@ func (n *Node) stopServices() []error {
 		errs = append(errs, err)
 	}
 
-	if err := n.sync.Stop(); err != nil {
-		errs = append(errs, err)
-	}
-
 	return errs
 }
 
+// closeBlockChain closes the chain, waiting until ctx is done for the block
+// import in progress to finish. Closing the database afterwards still waits
+// for its write transaction, so giving up here never cuts a write short.
+func (n *Node) closeBlockChain(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- n.blockChain.Close()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		log.Warn("Block import did not finish in time", "timeout", n.config.NodeCfg.ShutdownTimeout)
+		return nil
+	}
+}
+
 // doClose releases resources acquired by New(), collecting errors.
 func (n *Node) doClose(errs []error) error {
 	// Close databases. This needs the lock because it needs to
@@ -687,6 +967,10 @@ func (n *Node) doClose(errs []error) error {
 	n.db.Close()
 	n.lock.Unlock()
 
+	if err := n.freezer.close(); err != nil {
+		errs = append(errs, err)
+	}
+
 	if err := n.accman.Close(); err != nil {
 		errs = append(errs, err)
 	}
@@ -783,8 +1067,21 @@ func (n *Node) SetupMetrics(config conf.MetricsConfig) {
 	if config.Enable {
 		if config.HTTP != "" {
 			address := net.JoinHostPort(config.HTTP, fmt.Sprintf("%d", config.Port))
-			log.Info("Enabling stand-alone metrics HTTP endpoint", "address", address)
-			prometheus.Setup(address, log.Root())
+			n.registerDBMetrics()
+			var mux *http.ServeMux
+			// Both servers listen on port 6060 by default, the pprof server then
+			// serves the metrics as well.
+			if pprof := n.config.PprofCfg; pprof.Pprof && address == net.JoinHostPort(pprof.Addr, strconv.Itoa(pprof.Port)) {
+				log.Info("Serving metrics on the pprof HTTP endpoint", "address", address)
+				mux = http.DefaultServeMux
+				prometheus.Register(mux)
+			} else {
+				log.Info("Enabling stand-alone metrics HTTP endpoint", "address", address)
+				mux = prometheus.Setup(address, log.Root())
+			}
+			for path, handler := range n.healthHandlers() {
+				mux.Handle(path, handler)
+			}
 		} else if config.Port != 0 {
 			log.Warn(fmt.Sprintf("--%s specified without --%s, metrics server will not start.", "metrics.port", "metrics.addr"))
 		}
@@ -792,6 +1089,24 @@ func (n *Node) SetupMetrics(config conf.MetricsConfig) {
 
 }
 
+// registerDBMetrics exports the size of every chain database table. The
+// sizes are read when the metrics are scraped.
+func (n *Node) registerDBMetrics() {
+	for _, table := range modules.AstTables() {
+		table := table
+		prometheus.GetOrCreateGaugeFunc(fmt.Sprintf(`db_table_size{table="%s"}`, table), func() float64 {
+			var size uint64
+			if err := n.db.View(context.Background(), func(tx kv.Tx) (err error) {
+				size, err = tx.BucketSize(table)
+				return err
+			}); err != nil {
+				return 0
+			}
+			return float64(size)
+		})
+	}
+}
+
 func (s *Node) Etherbase() (eb types.Address, err error) {
 	s.lock.RLock()
 	etherbase := s.etherbase
@@ -842,7 +1157,12 @@ func OpenDatabase(cfg *conf.Config, logger log2.Logger, name string) (kv.RwDB, e
 		modules.AstInit()
 		kv.ChaindataTablesCfg = modules.AstTableCfg
 
-		opts = opts.MapSize(8 * datasize.TB)
+		if cfg.DatabaseCfg.ReadOnly {
+			return readonlyDatabase(opts).Open()
+		}
+		if opts, err = tuneDatabase(opts, &cfg.DatabaseCfg); err != nil {
+			return nil, err
+		}
 		return opts.Open()
 	}
 	chainKv, err = openFunc(false)
@@ -850,9 +1170,15 @@ func OpenDatabase(cfg *conf.Config, logger log2.Logger, name string) (kv.RwDB, e
 		return nil, err
 	}
 
-	if err = chainKv.Update(context.Background(), func(tx kv.RwTx) (err error) {
-		return params.SetastVersion(tx, params.VersionKeyCreated)
-	}); err != nil {
+	if !cfg.DatabaseCfg.ReadOnly {
+		if err = chainKv.Update(context.Background(), func(tx kv.RwTx) (err error) {
+			return params.SetastVersion(tx, params.VersionKeyCreated)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err = rawdb.SetupCompression(context.Background(), chainKv, cfg.DatabaseCfg.Compression && !cfg.DatabaseCfg.ReadOnly); err != nil {
+		chainKv.Close()
 		return nil, err
 	}
 	return chainKv, nil
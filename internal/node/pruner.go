@@ -0,0 +1,206 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+const (
+	// pruneInterval is how often the data of old blocks is pruned.
+	pruneInterval = time.Minute
+	// pruneBatchSize is the number of blocks pruned per write transaction,
+	// which keeps block import waiting for a short time only.
+	pruneBatchSize = 1000
+	// pruneMinHistory is the fewest blocks whose state history is kept, so
+	// that the chain can still be rewound for a reorg.
+	pruneMinHistory = 1024
+)
+
+// The pruning modes and the number of recent blocks whose data they keep,
+// per kind of data. Kinds not listed are kept for every block.
+var pruneModes = map[string]map[string]uint64{
+	"archive": {},
+	"full": {
+		rawdb.PruneHistory: 90000,
+	},
+	"minimal": {
+		rawdb.PruneHistory:  pruneMinHistory,
+		rawdb.PruneReceipts: pruneMinHistory,
+		rawdb.PruneTxIndex:  pruneMinHistory,
+	},
+}
+
+// pruneDistances returns the number of recent blocks whose data is kept per
+// kind of data for the pruning mode and overrides of config.
+func pruneDistances(config *conf.NodeConfig) (map[string]uint64, error) {
+	mode := config.Prune
+	if mode == "" {
+		mode = "archive"
+	}
+	preset, ok := pruneModes[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown pruning mode %q, want archive, full or minimal", mode)
+	}
+	distances := make(map[string]uint64)
+	for kind, distance := range preset {
+		distances[kind] = distance
+	}
+	for kind, distance := range map[string]uint64{
+		rawdb.PruneHistory:  config.PruneHistory,
+		rawdb.PruneReceipts: config.PruneReceipts,
+		rawdb.PruneTxIndex:  config.PruneTxIndex,
+	} {
+		if distance != 0 {
+			distances[kind] = distance
+		}
+	}
+	if distance, ok := distances[rawdb.PruneHistory]; ok && distance < pruneMinHistory {
+		return nil, fmt.Errorf("state history must be kept for at least %d blocks", pruneMinHistory)
+	}
+	return distances, nil
+}
+
+// chainPruner deletes the state history, receipts and transaction index of
+// the blocks further below the head of the chain than configured.
+type chainPruner struct {
+	db        kv.RwDB
+	chain     common.IBlockChain
+	distances map[string]uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newChainPruner(db kv.RwDB, chain common.IBlockChain, distances map[string]uint64) *chainPruner {
+	return &chainPruner{
+		db:        db,
+		chain:     chain,
+		distances: distances,
+		quit:      make(chan struct{}),
+	}
+}
+
+// start runs the pruner in the background if any data is pruned.
+func (p *chainPruner) start() {
+	if len(p.distances) == 0 {
+		return
+	}
+	log.Info("Pruning old blocks", "history", p.distances[rawdb.PruneHistory], "receipts", p.distances[rawdb.PruneReceipts], "txindex", p.distances[rawdb.PruneTxIndex])
+	p.wg.Add(1)
+	go p.loop()
+}
+
+// stop waits for the batch being pruned and stops the pruner.
+func (p *chainPruner) stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+func (p *chainPruner) loop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		for _, kind := range rawdb.PruneKinds {
+			if distance, ok := p.distances[kind]; ok {
+				if err := p.prune(kind, distance); err != nil {
+					log.Error("Failed to prune blocks", "kind", kind, "err", err)
+				}
+			}
+		}
+		select {
+		case <-p.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune deletes the data of kind of the blocks more than distance blocks
+// below the head, one batch per transaction, until they are all pruned or the
+// pruner is stopped.
+func (p *chainPruner) prune(kind string, distance uint64) error {
+	head := p.chain.CurrentBlock().Number64().Uint64()
+	if head <= distance {
+		return nil
+	}
+	limit := head - distance
+	gauge := prometheus.GetOrCreateCounter(fmt.Sprintf("prune_%s_from", kind), true)
+	for {
+		start := time.Now()
+		var (
+			pruned int
+			from   uint64
+		)
+		if err := p.db.Update(context.Background(), func(tx kv.RwTx) (err error) {
+			if pruned, err = rawdb.PruneData(tx, kind, limit, pruneBatchSize); err != nil {
+				return err
+			}
+			from, err = rawdb.ReadPruneProgress(tx, kind)
+			return err
+		}); err != nil {
+			return err
+		}
+		if pruned == 0 {
+			return nil
+		}
+		gauge.Set(from)
+		log.Debug("Pruned blocks", "kind", kind, "blocks", pruned, "from", from, "elapsed", time.Since(start))
+
+		select {
+		case <-p.quit:
+			return nil
+		default:
+		}
+	}
+}
+
+// PruneState deletes the state history of the blocks further below the head
+// than the pruning mode keeps, without waiting for the background pruner of a
+// running node, and returns the range of blocks pruned.
+func (n *Node) PruneState() (from, to uint64, err error) {
+	distance, ok := n.pruner.distances[rawdb.PruneHistory]
+	if !ok {
+		return 0, 0, fmt.Errorf("pruning mode %q keeps the state history of every block", n.config.NodeCfg.Prune)
+	}
+	read := func() (progress uint64, err error) {
+		err = n.db.View(context.Background(), func(tx kv.Tx) (err error) {
+			progress, err = rawdb.ReadPruneProgress(tx, rawdb.PruneHistory)
+			return err
+		})
+		return progress, err
+	}
+	if from, err = read(); err != nil {
+		return 0, 0, err
+	}
+	if err := n.pruner.prune(rawdb.PruneHistory, distance); err != nil {
+		return 0, 0, err
+	}
+	to, err = read()
+	return from, to, err
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	leakybucket "github.com/n42blockchain/N42/internal/p2p/leaky-bucket"
+	"github.com/n42blockchain/N42/log"
+)
+
+// rateLimitErrorCode is the JSON-RPC error code returned to throttled clients.
+const rateLimitErrorCode = -32005
+
+// rateLimitResponse is sent instead of the result when a client is throttled.
+// The request is never decoded, so the id is always null.
+var rateLimitResponse = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":null,"error":{"code":%d,"message":"rate limit exceeded"}}`+"\n", rateLimitErrorCode))
+
+// rateLimitConfig configures the per client IP request limiter.
+type rateLimitConfig struct {
+	RequestsPerSecond float64
+	Burst             int
+	// TrustedProxies lists the IPs or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For / X-Real-IP headers are used to find the client IP.
+	TrustedProxies []string
+}
+
+type rateLimitHandler struct {
+	limiter *leakybucket.Collector
+	trusted []*net.IPNet
+	next    http.Handler
+}
+
+// newRateLimitHandler wraps next with a token bucket limiter keyed by client
+// IP. A nil config or a non-positive rate disables limiting.
+func newRateLimitHandler(config *rateLimitConfig, next http.Handler) http.Handler {
+	if config == nil || config.RequestsPerSecond <= 0 {
+		return next
+	}
+	burst := config.Burst
+	if burst < 1 {
+		burst = int(config.RequestsPerSecond)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	h := &rateLimitHandler{
+		limiter: leakybucket.NewCollector(config.RequestsPerSecond, int64(burst), time.Second, true /* deleteEmptyBuckets */),
+		next:    next,
+	}
+	for _, proxy := range config.TrustedProxies {
+		network, err := parseIPNet(proxy)
+		if err != nil {
+			log.Warn("Ignoring invalid trusted RPC proxy", "proxy", proxy, "err", err)
+			continue
+		}
+		h.trusted = append(h.trusted, network)
+	}
+	return h
+}
+
+// freeRateLimit stops the pruning of idle client buckets if h is a rate
+// limiter returned by newRateLimitHandler.
+func freeRateLimit(h http.Handler) {
+	if rl, ok := h.(*rateLimitHandler); ok {
+		rl.limiter.Free()
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *rateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip := h.clientIP(r)
+	if h.limiter.Add(ip, 1) < 1 {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(rateLimitResponse)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// clientIP returns the address of the client. Forwarding headers are only
+// honoured when the request comes from a trusted proxy, walking the
+// X-Forwarded-For chain from the right to skip further trusted hops.
+func (h *rateLimitHandler) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !h.isTrusted(host) {
+		return host
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		hops := strings.Split(fwd, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			host = hop
+			if !h.isTrusted(hop) {
+				break
+			}
+		}
+		return host
+	}
+	if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(real) != nil {
+		return real
+	}
+	return host
+}
+
+func (h *rateLimitHandler) isTrusted(addr string) bool {
+	return containsIP(h.trusted, addr)
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+// regenerateBatchSize is the number of blocks whose receipts are regenerated
+// per write transaction.
+const regenerateBatchSize = 1000
+
+// RegenerateReceipts executes the canonical blocks from through to missing
+// their receipts again to store their receipts and logs, adds their logs to
+// the log index if it already covers them, and lowers the receipts prune
+// progress to from if the blocks reach up to it. It returns the number of
+// blocks whose receipts were regenerated. The state of the parent of from
+// must still be kept.
+func (n *Node) RegenerateReceipts(ctx context.Context, from, to uint64) (int, error) {
+	chain, ok := n.blockChain.(*internal.BlockChain)
+	if !ok {
+		return 0, fmt.Errorf("cannot execute blocks on %T", n.blockChain)
+	}
+	if from == 0 || from > to {
+		return 0, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+
+	var (
+		start       = time.Now()
+		regenerated int
+	)
+	for batch := from; batch <= to; batch += regenerateBatchSize {
+		last := min(batch+regenerateBatchSize-1, to)
+		if err := n.db.Update(ctx, func(tx kv.RwTx) error {
+			for number := batch; number <= last; number++ {
+				if rawdb.HasReceipts(tx, number) {
+					continue
+				}
+				hash, err := rawdb.ReadCanonicalHash(tx, number)
+				if err != nil {
+					return err
+				}
+				b := rawdb.ReadBlock(tx, hash, number)
+				if hash == (types.Hash{}) || b == nil {
+					return fmt.Errorf("canonical block %d not found", number)
+				}
+				receipts, err := chain.RegenerateReceipts(tx, b)
+				if err != nil {
+					return fmt.Errorf("failed to execute block %d: %w", number, err)
+				}
+				if err := rawdb.WriteReceipts(tx, number, receipts); err != nil {
+					return err
+				}
+				regenerated++
+			}
+			// Blocks the log index passed while their receipts were missing
+			// are indexed now, without moving the index head back.
+			head, headHash, ok, err := rawdb.ReadLogIndexHead(tx)
+			if err != nil || !ok || head < batch {
+				return err
+			}
+			if err := rawdb.IndexLogs(tx, batch, min(last, head)); err != nil {
+				return err
+			}
+			return rawdb.WriteLogIndexHead(tx, head, headHash)
+		}); err != nil {
+			return regenerated, err
+		}
+		log.Info("Regenerating receipts", "block", last, "to", to, "regenerated", regenerated, "elapsed", time.Since(start))
+	}
+
+	return regenerated, n.db.Update(ctx, func(tx kv.RwTx) error {
+		progress, err := rawdb.ReadPruneProgress(tx, rawdb.PruneReceipts)
+		if err != nil || progress <= from || to+1 < progress {
+			return err
+		}
+		return rawdb.WritePruneProgress(tx, rawdb.PruneReceipts, from)
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/remotedbserver"
+	log2 "github.com/ledgerwatch/log/v3"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// remoteDBServer serves the remote KV gRPC protocol of erigon on the chain
+// database, so that indexers and RPC daemons in other processes or on other
+// hosts can read its tables in transactions of their own.
+type remoteDBServer struct {
+	config *conf.NodeConfig
+	db     kv.RoDB
+
+	server *grpc.Server
+	wg     sync.WaitGroup
+}
+
+func newRemoteDBServer(config *conf.NodeConfig, db kv.RoDB) *remoteDBServer {
+	return &remoteDBServer{config: config, db: db}
+}
+
+// start listens on the configured address, if any.
+func (s *remoteDBServer) start(ctx context.Context) error {
+	if s.config.PrivateAPIAddr == "" {
+		return nil
+	}
+	opts := []grpc.ServerOption{
+		// Clients keep their transactions open between requests, do not
+		// drop their idle connections.
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	}
+	if s.config.PrivateAPIMaxStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(uint32(s.config.PrivateAPIMaxStreams)))
+	}
+	if s.config.PrivateAPITLSCert != "" || s.config.PrivateAPITLSKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.config.PrivateAPITLSCert, s.config.PrivateAPITLSKey)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	listener, err := net.Listen("tcp", s.config.PrivateAPIAddr)
+	if err != nil {
+		return err
+	}
+	s.server = grpc.NewServer(opts...)
+	remote.RegisterKVServer(s.server, remotedbserver.NewKvServer(ctx, s.db, nil, nil, log2.Root()))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Error("Remote database server failed", "err", err)
+		}
+	}()
+	log.Info("Remote database server started", "addr", listener.Addr(), "tls", s.config.PrivateAPITLSCert != "")
+	return nil
+}
+
+// stop waits until ctx is done for the streams in flight to finish, then
+// closes them.
+func (s *remoteDBServer) stop(ctx context.Context) {
+	if s.server == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+	s.wg.Wait()
+	log.Info("Remote database server stopped", "addr", s.config.PrivateAPIAddr)
+}
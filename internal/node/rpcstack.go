@@ -19,13 +19,14 @@ package node
 import (
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/n42blockchain/N42/log"
 	"github.com/rs/cors"
-	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/n42blockchain/N42/conf"
 	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+	"github.com/n42blockchain/N42/utils"
 )
 
 type httpConfig struct {
@@ -41,15 +43,36 @@ type httpConfig struct {
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string
-	jwtSecret          []byte // optional JWT secret
+	jwtKeys            *jwtKeySet       // optional JWT secrets
+	rateLimit          *rateLimitConfig // optional per client IP rate limit
+	gzipMinSize        int              // smallest response compressed, negative disables gzip
+	allowMethods       []string         // optional method allowlist, see jsonrpc.Server.SetMethodFilter
+	denyMethods        []string         // optional method denylist
+	accessLogRate      float64          // fraction of calls logged, see jsonrpc.Server.SetAccessLog
+	accessLogRedact    []string         // methods whose parameters are not logged
+
+	batchItemLimit         int
+	batchResponseSizeLimit int
+	executionTimeout       time.Duration
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
 	Origins   []string
 	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	prefix    string           // path prefix on which to mount ws handler
+	jwtKeys   *jwtKeySet       // optional JWT secrets
+	rateLimit *rateLimitConfig // optional per client IP rate limit
+
+	allowMethods    []string // optional method allowlist, see jsonrpc.Server.SetMethodFilter
+	denyMethods     []string // optional method denylist
+	accessLogRate   float64  // fraction of calls logged, see jsonrpc.Server.SetAccessLog
+	accessLogRedact []string // methods whose parameters are not logged
+
+	batchItemLimit         int
+	batchResponseSizeLimit int
+	executionTimeout       time.Duration
+	notificationBuffer     int // subscription notifications queued per connection
 }
 
 type rpcHandler struct {
@@ -57,6 +80,12 @@ type rpcHandler struct {
 	server *jsonrpc.Server
 }
 
+// stop stops the RPC server and the rate limiter in front of it.
+func (h *rpcHandler) stop() {
+	h.server.Stop()
+	freeRateLimit(h.Handler)
+}
+
 type httpServer struct {
 	mux      http.ServeMux
 	mu       sync.Mutex
@@ -74,7 +103,14 @@ type httpServer struct {
 	host     string
 	port     int
 
+	// tlsConfig, when set, makes the server terminate TLS itself.
+	tlsConfig *tls.Config
+
+	// allowIPs restricts the clients that may connect, empty allows all.
+	allowIPs []string
+
 	handlerNames map[string]string
+	handlers     []http.Handler // registered with registerHandler, freed on stop
 }
 
 func newHTTPServer() *httpServer {
@@ -98,6 +134,73 @@ func (h *httpServer) setListenAddr(host string, port int) error {
 	return nil
 }
 
+// setTLS configures the server to serve HTTPS (and wss for WebSocket) using
+// the given certificate and key files. It must be called before the server
+// is started.
+func (h *httpServer) setTLS(certFile, keyFile string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.listener != nil {
+		return fmt.Errorf("HTTP server already running on %s", h.endpoint)
+	}
+	if certFile == "" && keyFile == "" {
+		h.tlsConfig = nil
+		return nil
+	}
+	config, err := newTLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	h.tlsConfig = config
+	return nil
+}
+
+// setAllowIPs restricts the server to clients within the given CIDR ranges.
+// It must be called before the server is started.
+func (h *httpServer) setAllowIPs(cidrs []string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.listener != nil {
+		return fmt.Errorf("HTTP server already running on %s", h.endpoint)
+	}
+	h.allowIPs = cidrs
+	return nil
+}
+
+// newTLSConfig loads the certificate/key pairs into a server TLS configuration.
+// certFiles and keyFiles are comma separated lists of equal length; with more
+// than one pair the certificate is picked by the SNI name the client asks for,
+// falling back to the first one.
+func newTLSConfig(certFiles, keyFiles string) (*tls.Config, error) {
+	certs, keys := utils.SplitAndTrim(certFiles), utils.SplitAndTrim(keyFiles)
+	if len(certs) == 0 || len(certs) != len(keys) {
+		return nil, fmt.Errorf("TLS certificates and keys must be specified in pairs")
+	}
+	config := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		// Only forward secret AEAD suites for TLS 1.2, TLS 1.3 suites are not configurable.
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+	for i := range certs {
+		cert, err := tls.LoadX509KeyPair(certs[i], keys[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate %s: %v", certs[i], err)
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	return config, nil
+}
+
 func (h *httpServer) listenAddr() string {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -108,6 +211,13 @@ func (h *httpServer) listenAddr() string {
 	return h.endpoint
 }
 
+// running reports whether the server is listening.
+func (h *httpServer) running() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.listener != nil
+}
+
 func (h *httpServer) start() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -116,7 +226,11 @@ func (h *httpServer) start() error {
 		return nil // already running or not configured
 	}
 
-	h.server = &http.Server{Handler: h}
+	handler, err := newIPAllowlistHandler(h.allowIPs, h)
+	if err != nil {
+		return err
+	}
+	h.server = &http.Server{Handler: handler}
 
 	//todo
 	h.server.ReadTimeout = time.Duration(60 * time.Second)
@@ -129,11 +243,18 @@ func (h *httpServer) start() error {
 		h.disableWS()
 		return err
 	}
+	if h.tlsConfig != nil {
+		listener = tls.NewListener(listener, h.tlsConfig)
+	}
 	h.listener = listener
 	go h.server.Serve(listener)
 
+	httpScheme, wsScheme := "http", "ws"
+	if h.tlsConfig != nil {
+		httpScheme, wsScheme = "https", "wss"
+	}
 	if h.wsAllowed() {
-		url := fmt.Sprintf("ws://%v", listener.Addr())
+		url := fmt.Sprintf("%s://%v", wsScheme, listener.Addr())
 		if h.wsConfig.prefix != "" {
 			url += h.wsConfig.prefix
 		}
@@ -148,6 +269,7 @@ func (h *httpServer) start() error {
 		"prefix", h.httpConfig.prefix,
 		"cors", strings.Join(h.httpConfig.CorsAllowedOrigins, ","),
 		"vhosts", strings.Join(h.httpConfig.Vhosts, ","),
+		"tls", h.tlsConfig != nil,
 	)
 
 	var paths []string
@@ -159,7 +281,7 @@ func (h *httpServer) start() error {
 	for _, path := range paths {
 		name := h.handlerNames[path]
 		if !logged[name] {
-			log.Info(name+" enabled", "url", "http://"+listener.Addr().String()+path)
+			log.Info(name+" enabled", "url", httpScheme+"://"+listener.Addr().String()+path)
 			logged[name] = true
 		}
 	}
@@ -203,12 +325,17 @@ func (h *httpServer) enableWS(apis []jsonrpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := jsonrpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	srv.SetExecutionTimeout(config.executionTimeout)
+	srv.SetMethodFilter(config.allowMethods, config.denyMethods)
+	srv.SetAccessLog(config.accessLogRate, config.accessLogRedact)
+	srv.SetNotificationBuffer(config.notificationBuffer)
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtSecret),
+		Handler: newRateLimitHandler(config.rateLimit, NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtKeys)),
 		server:  srv,
 	})
 	return nil
@@ -221,7 +348,7 @@ func (h *httpServer) stopWS() {
 
 	if h.disableWS() {
 		if !h.rpcAllowed() {
-			h.doStop()
+			h.doStop(context.Background())
 		}
 	}
 }
@@ -231,7 +358,7 @@ func (h *httpServer) disableWS() bool {
 	ws := h.wsHandler.Load().(*rpcHandler)
 	if ws != nil {
 		h.wsHandler.Store((*rpcHandler)(nil))
-		ws.server.Stop()
+		ws.stop()
 	}
 	return ws != nil
 }
@@ -265,23 +392,33 @@ func validatePrefix(what, path string) error {
 	return nil
 }
 
-func (h *httpServer) stop() {
+// stop shuts the server down. It stops accepting connections right away and
+// lets the requests in flight finish until ctx is done.
+func (h *httpServer) stop(ctx context.Context) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.doStop()
+	h.doStop(ctx)
 }
 
-func (h *httpServer) doStop() {
+func (h *httpServer) doStop(ctx context.Context) {
 	if h.listener == nil {
 		return // not running
 	}
 
+	if err := h.server.Shutdown(ctx); err != nil {
+		log.Warn("HTTP server requests did not finish in time", "endpoint", h.listener.Addr(), "err", err)
+		h.server.Close()
+	}
 	httpHandler := h.httpHandler.Load().(*rpcHandler)
 	if httpHandler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
-		httpHandler.server.Stop()
+		httpHandler.stop()
 	}
-	h.server.Shutdown(context.Background())
+	h.disableWS()
+	for _, handler := range h.handlers {
+		freeRateLimit(handler)
+	}
+	h.handlers = nil
 	h.listener.Close()
 	log.Info("HTTP server stopped", "endpoint", h.listener.Addr())
 
@@ -298,22 +435,37 @@ func (h *httpServer) enableRPC(apis []jsonrpc.API, config httpConfig) error {
 	}
 
 	srv := jsonrpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	srv.SetExecutionTimeout(config.executionTimeout)
+	srv.SetMethodFilter(config.allowMethods, config.denyMethods)
+	srv.SetAccessLog(config.accessLogRate, config.accessLogRedact)
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
+		Handler: newRateLimitHandler(config.rateLimit, NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtKeys, config.gzipMinSize)),
 		server:  srv,
 	})
 	return nil
 }
 
+// registerHandler mounts handler at path next to the JSON-RPC endpoint. It
+// must be called before the server is started.
+func (h *httpServer) registerHandler(name, path string, handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.mux.Handle(path, handler)
+	h.handlerNames[path] = name
+	h.handlers = append(h.handlers, handler)
+}
+
 func (h *httpServer) disableRPC() bool {
 	handler := h.httpHandler.Load().(*rpcHandler)
 	if handler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
-		handler.server.Stop()
+		handler.stop()
 	}
 	return handler != nil
 }
@@ -327,20 +479,20 @@ func (h *httpServer) wsAllowed() bool {
 	return h.wsHandler.Load().(*rpcHandler) != nil
 }
 
-func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSecret []byte) http.Handler {
+func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtKeys *jwtKeySet, gzipMinSize int) http.Handler {
 	// Wrap the CORS-handler within a host-handler
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
-	if len(jwtSecret) != 0 {
-		handler = newJWTHandler(jwtSecret, handler)
+	if jwtKeys != nil {
+		handler = newJWTHandler(jwtKeys, handler)
 	}
-	return newGzipHandler(handler)
+	return newGzipHandler(handler, gzipMinSize)
 }
 
 // NewWSHandlerStack returns a wrapped ws-related handler.
-func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
-	if len(jwtSecret) != 0 {
-		return newJWTHandler(jwtSecret, srv)
+func NewWSHandlerStack(srv http.Handler, jwtKeys *jwtKeySet) http.Handler {
+	if jwtKeys != nil {
+		return newJWTHandler(jwtKeys, srv)
 	}
 	return srv
 }
@@ -407,34 +559,109 @@ var gzPool = sync.Pool{
 	},
 }
 
+// gzipResponseWriter buffers the response until it reaches minSize bytes and
+// only then switches to gzip, so that small responses are sent as is.
 type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
+	resp    http.ResponseWriter
+	minSize int
+
+	buf         []byte       // response held back until minSize is reached
+	gz          *gzip.Writer // set once compression has started
+	passThrough bool         // the handler encoded the response itself
+	status      int
+	wroteHeader bool
 }
 
+func (w *gzipResponseWriter) Header() http.Header {
+	return w.resp.Header()
+}
+
+// WriteHeader defers the status until it is known whether the response is
+// compressed, since the headers can't be changed after that.
 func (w *gzipResponseWriter) WriteHeader(status int) {
-	w.Header().Del("Content-Length")
-	w.ResponseWriter.WriteHeader(status)
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) writeHeader() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.status != 0 {
+		w.resp.WriteHeader(w.status)
+	}
 }
 
 func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(b)
+	case w.passThrough:
+		return w.resp.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// startCompression sets the gzip headers and flushes the buffered output
+// through the compressor.
+func (w *gzipResponseWriter) startCompression() error {
+	buf := w.buf
+	w.buf = nil
+	if w.Header().Get("Content-Encoding") != "" {
+		w.passThrough = true
+		w.writeHeader()
+		_, err := w.resp.Write(buf)
+		return err
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+	w.writeHeader()
+
+	w.gz = gzPool.Get().(*gzip.Writer)
+	w.gz.Reset(w.resp)
+	_, err := w.gz.Write(buf)
+	return err
 }
 
-func newGzipHandler(next http.Handler) http.Handler {
+// close flushes whatever is left, uncompressed if minSize was never reached.
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+		gzPool.Put(w.gz)
+		w.gz = nil
+		return
+	}
+	w.writeHeader()
+	if len(w.buf) > 0 {
+		w.resp.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// newGzipHandler compresses responses of at least minSize bytes for clients
+// accepting gzip. A negative minSize disables compression.
+func newGzipHandler(next http.Handler, minSize int) http.Handler {
+	if minSize < 0 {
+		return next
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 			next.ServeHTTP(w, r)
 			return
 		}
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzPool.Get().(*gzip.Writer)
-		defer gzPool.Put(gz)
-
-		gz.Reset(w)
-		defer gz.Close()
+		gw := &gzipResponseWriter{resp: w, minSize: minSize}
+		defer gw.close()
 
-		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, Writer: gz}, r)
+		next.ServeHTTP(gw, r)
 	})
 }
 
@@ -447,6 +674,12 @@ type ipcServer struct {
 }
 
 func newIPCServer(config *conf.NodeConfig) *ipcServer {
+	if config.IPCPath == "" {
+		return &ipcServer{}
+	}
+	if filepath.IsAbs(config.IPCPath) {
+		return &ipcServer{endpoint: config.IPCPath}
+	}
 	return &ipcServer{endpoint: fmt.Sprintf("%s/%s", config.DataDir, config.IPCPath)}
 }
 
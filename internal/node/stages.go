@@ -0,0 +1,309 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/internal/api"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/utils"
+)
+
+// stageBatchSize is the number of blocks a stage runs or unwinds per write
+// transaction.
+const stageBatchSize = 1000
+
+// StageProgress is the last block processed by a stage of the sync pipeline.
+type StageProgress struct {
+	Stage   string `json:"stage"`
+	Block   uint64 `json:"block"`
+	Enabled bool   `json:"enabled"`
+}
+
+// disabledStages returns the optional stages disabled by the configuration.
+func disabledStages(config *conf.NodeConfig) (map[string]bool, error) {
+	disabled := make(map[string]bool)
+	for _, stage := range utils.SplitAndTrim(config.DisabledStages) {
+		if !slices.Contains(rawdb.OptionalStages, stage) {
+			return nil, fmt.Errorf("invalid stage %q, optional stages are %v", stage, rawdb.OptionalStages)
+		}
+		disabled[stage] = true
+	}
+	if !config.LogsIndex {
+		disabled[rawdb.StageLogIndex] = true
+	}
+	return disabled, nil
+}
+
+// setupTxIndex records from which block on the transaction index is missing
+// while it is disabled, and warns of an index left incomplete when enabled.
+func setupTxIndex(ctx context.Context, db kv.RwDB, enabled bool) error {
+	return db.Update(ctx, func(tx kv.RwTx) error {
+		progress, ok, err := rawdb.ReadStageProgress(tx, rawdb.StageTxIndex)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			if ok {
+				log.Warn("Transaction index is incomplete, run the txindex stage to complete it", "indexed", progress)
+			}
+			return nil
+		}
+		if ok {
+			return nil
+		}
+		head := rawdb.ReadCurrentBlock(tx)
+		if head == nil {
+			return nil
+		}
+		return rawdb.WriteStageProgress(tx, rawdb.StageTxIndex, head.Number64().Uint64())
+	})
+}
+
+// Stages returns the progress of every stage of the sync pipeline.
+func (n *Node) Stages(ctx context.Context) ([]StageProgress, error) {
+	stages := make([]StageProgress, 0, len(rawdb.Stages))
+	err := n.db.View(ctx, func(tx kv.Tx) error {
+		for _, stage := range rawdb.Stages {
+			progress, err := n.stageProgress(tx, stage)
+			if err != nil {
+				return err
+			}
+			stages = append(stages, StageProgress{Stage: stage, Block: progress, Enabled: !n.disabledStages[stage]})
+		}
+		return nil
+	})
+	return stages, err
+}
+
+// syncStatus returns the progress of the sync for eth_syncing, nil once the
+// node caught up with its best peer. The completion is estimated from the
+// rate blocks were imported at since the sync started.
+func (n *Node) syncStatus(ctx context.Context) (*api.SyncStatus, error) {
+	health := n.syncHealth()
+	if health.Distance == 0 || (n.is.Synced() && !n.is.Syncing()) {
+		return nil, nil
+	}
+	status := &api.SyncStatus{
+		CurrentBlock: hexutil.Uint64(health.Current),
+		HighestBlock: hexutil.Uint64(health.Highest),
+		Stage:        rawdb.StageExecution,
+		Stages:       []api.SyncStage{},
+	}
+	err := n.db.View(ctx, func(tx kv.Tx) error {
+		// While snap sync downloads the state, no block is imported.
+		if _, _, ok, err := rawdb.ReadSnapPivot(tx); err != nil {
+			return err
+		} else if ok {
+			status.Stage = "snap"
+		}
+		for _, stage := range rawdb.Stages {
+			if n.disabledStages[stage] {
+				continue
+			}
+			progress, err := n.stageProgress(tx, stage)
+			if err != nil {
+				return err
+			}
+			status.Stages = append(status.Stages, api.SyncStage{Stage: stage, Block: hexutil.Uint64(progress)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	starting, started := n.is.Progress()
+	if started.IsZero() {
+		return status, nil
+	}
+	status.StartingBlock = hexutil.Uint64(starting)
+	if elapsed := time.Since(started); health.Current > starting && elapsed > 0 {
+		rate := float64(health.Current-starting) / elapsed.Seconds()
+		eta := hexutil.Uint64(time.Now().Add(time.Duration(float64(health.Distance) / rate * float64(time.Second))).Unix())
+		status.EstimatedCompletion = &eta
+	}
+	return status, nil
+}
+
+// stageProgress returns the last block processed by stage.
+func (n *Node) stageProgress(tx kv.Tx, stage string) (uint64, error) {
+	head := n.blockChain.CurrentBlock().Number64().Uint64()
+	switch stage {
+	case rawdb.StageExecution:
+		return head, nil
+	case rawdb.StageTxIndex:
+		progress, ok, err := rawdb.ReadStageProgress(tx, stage)
+		if err != nil || !ok {
+			return head, err
+		}
+		return min(progress, head), nil
+	case rawdb.StageLogIndex:
+		progress, _, _, err := rawdb.ReadLogIndexHead(tx)
+		return progress, err
+	}
+	return 0, fmt.Errorf("unknown stage %q, stages are %v", stage, rawdb.Stages)
+}
+
+// RunStage makes stage process the blocks after its progress up to block to,
+// which the stages before it must have processed. Blocks are executed by the
+// import only.
+func (n *Node) RunStage(ctx context.Context, stage string, to uint64) error {
+	index := slices.Index(rawdb.Stages, stage)
+	if index < 0 {
+		return fmt.Errorf("unknown stage %q, stages are %v", stage, rawdb.Stages)
+	}
+	if stage == rawdb.StageExecution {
+		return fmt.Errorf("the %s stage is run by the block import", stage)
+	}
+	var from uint64
+	if err := n.db.View(ctx, func(tx kv.Tx) error {
+		progress, err := n.stageProgress(tx, stage)
+		if err != nil {
+			return err
+		}
+		from = progress + 1
+		for _, prev := range rawdb.Stages[:index] {
+			if progress, err := n.stageProgress(tx, prev); err != nil {
+				return err
+			} else if to > progress {
+				return fmt.Errorf("the %s stage only reached block %d", prev, progress)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	for batch := from; batch <= to; batch += stageBatchSize {
+		last := min(batch+stageBatchSize-1, to)
+		if err := n.db.Update(ctx, func(tx kv.RwTx) error {
+			switch stage {
+			case rawdb.StageTxIndex:
+				// Blocks below the pruned transaction index stay unindexed.
+				pruned, err := rawdb.ReadPruneProgress(tx, rawdb.PruneTxIndex)
+				if err != nil {
+					return err
+				}
+				for number := max(batch, pruned); number <= last; number++ {
+					b, err := rawdb.ReadBlockByNumber(tx, number)
+					if err != nil {
+						return err
+					}
+					if b == nil {
+						return fmt.Errorf("canonical block %d not found", number)
+					}
+					rawdb.WriteTxLookupEntries(tx, b)
+				}
+				// Once at the head, the import keeps the index complete.
+				if !n.disabledStages[stage] && last >= n.blockChain.CurrentBlock().Number64().Uint64() {
+					return rawdb.DeleteStageProgress(tx, stage)
+				}
+				return rawdb.WriteStageProgress(tx, stage, last)
+			default:
+				return rawdb.IndexLogs(tx, batch, last)
+			}
+		}); err != nil {
+			return err
+		}
+		log.Info("Running stage", "stage", stage, "block", last, "to", to, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// UnwindStage reverts stage, and the stages after it first, to block to.
+// Unwinding the execution stage rewinds the head of the chain.
+func (n *Node) UnwindStage(ctx context.Context, stage string, to uint64) error {
+	index := slices.Index(rawdb.Stages, stage)
+	if index < 0 {
+		return fmt.Errorf("unknown stage %q, stages are %v", stage, rawdb.Stages)
+	}
+	for i := len(rawdb.Stages) - 1; i >= index; i-- {
+		if err := n.unwindStage(ctx, rawdb.Stages[i], to); err != nil {
+			return fmt.Errorf("unwind %s stage: %w", rawdb.Stages[i], err)
+		}
+	}
+	if stage != rawdb.StageExecution || n.disabledStages[rawdb.StageTxIndex] {
+		return nil
+	}
+	// The transaction index reaches the new head, the import keeps it
+	// complete from there.
+	return n.db.Update(ctx, func(tx kv.RwTx) error {
+		return rawdb.DeleteStageProgress(tx, rawdb.StageTxIndex)
+	})
+}
+
+func (n *Node) unwindStage(ctx context.Context, stage string, to uint64) error {
+	var progress uint64
+	if err := n.db.View(ctx, func(tx kv.Tx) (err error) {
+		progress, err = n.stageProgress(tx, stage)
+		return err
+	}); err != nil || progress <= to {
+		return err
+	}
+
+	switch stage {
+	case rawdb.StageExecution:
+		return n.blockChain.SetHead(to)
+	case rawdb.StageLogIndex:
+		// Blocks stay in the index, which the log filter tolerates, until
+		// they are indexed again.
+		return n.db.Update(ctx, func(tx kv.RwTx) error {
+			hash, err := rawdb.ReadCanonicalHash(tx, to)
+			if err != nil {
+				return err
+			}
+			return rawdb.WriteLogIndexHead(tx, to, hash)
+		})
+	}
+	for last := progress; last > to; {
+		first := to + 1
+		if last-to > stageBatchSize {
+			first = last - stageBatchSize + 1
+		}
+		if err := n.db.Update(ctx, func(tx kv.RwTx) error {
+			for number := first; number <= last; number++ {
+				b, err := rawdb.ReadBlockByNumber(tx, number)
+				if err != nil {
+					return err
+				}
+				if b == nil {
+					continue
+				}
+				for _, t := range b.Transactions() {
+					if err := rawdb.DeleteTxLookupEntry(tx, t.Hash()); err != nil {
+						return err
+					}
+				}
+			}
+			return rawdb.WriteStageProgress(tx, stage, first-1)
+		}); err != nil {
+			return err
+		}
+		last = first - 1
+	}
+	return nil
+}
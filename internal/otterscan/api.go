@@ -0,0 +1,206 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package otterscan implements the ots namespace used by the Otterscan block
+// explorer.
+package otterscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/transaction"
+	common "github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/api"
+	"github.com/n42blockchain/N42/internal/tracers"
+	"github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+)
+
+// apiLevel is the version of the Otterscan API implemented by this node.
+const apiLevel = 8
+
+var errStateNotFound = errors.New("state not found")
+
+// OtterscanAPI implements the ots namespace.
+type OtterscanAPI struct {
+	api    *api.API
+	tracer *tracers.API
+}
+
+// NewOtterscanAPI creates a new ots namespace service.
+func NewOtterscanAPI(backend *api.API) *OtterscanAPI {
+	return &OtterscanAPI{api: backend, tracer: tracers.NewAPI(backend)}
+}
+
+// APIs returns the RPC services of the ots namespace.
+func APIs(backend *api.API) []jsonrpc.API {
+	return []jsonrpc.API{
+		{
+			Namespace: "ots",
+			Service:   NewOtterscanAPI(backend),
+		},
+	}
+}
+
+// GetApiLevel returns the Otterscan API level, which the explorer checks
+// before using the namespace.
+func (o *OtterscanAPI) GetApiLevel() uint8 {
+	return apiLevel
+}
+
+// HasCode reports whether address holds contract code at the given block.
+func (o *OtterscanAPI) HasCode(ctx context.Context, address common.Address, blockNrOrHash jsonrpc.BlockNumberOrHash) (bool, error) {
+	tx, err := o.api.Database().BeginRo(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	state := o.api.State(tx, blockNrOrHash)
+	if state == nil {
+		return false, errStateNotFound
+	}
+	return len(state.GetCode(address)) > 0, nil
+}
+
+// GetBlockDetails returns the header fields of a block together with its
+// transaction count, issuance and the fees paid by its transactions.
+func (o *OtterscanAPI) GetBlockDetails(ctx context.Context, number jsonrpc.BlockNumber) (map[string]interface{}, error) {
+	b, err := api.BlockByNumber(ctx, number, o.api)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	return o.blockDetails(b)
+}
+
+// GetBlockDetailsByHash is GetBlockDetails for a block identified by hash.
+func (o *OtterscanAPI) GetBlockDetailsByHash(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	b, err := o.api.BlockChain().GetBlockByHash(hash)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	return o.blockDetails(b)
+}
+
+func (o *OtterscanAPI) blockDetails(b block.IBlock) (map[string]interface{}, error) {
+	fields, err := api.RPCMarshalBlock(b, o.api.BlockChain(), true, false)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "transactions")
+	fields["transactionCount"] = len(b.Transactions())
+	fields["logsBloom"] = nil
+
+	receipts, err := o.blockReceipts(b)
+	if err != nil {
+		return nil, err
+	}
+	fees := new(big.Int)
+	for i, tx := range b.Transactions() {
+		price := effectiveGasPrice(tx, b.BaseFee64())
+		fees.Add(fees, price.Mul(price, new(big.Int).SetUint64(receipts[i].GasUsed)))
+	}
+	issuance := new(big.Int)
+	for _, reward := range b.Body().Reward() {
+		if reward.Amount != nil {
+			issuance.Add(issuance, reward.Amount.ToBig())
+		}
+	}
+	return map[string]interface{}{
+		"block": fields,
+		"issuance": map[string]interface{}{
+			"blockReward": (*hexutil.Big)(issuance),
+			"uncleReward": (*hexutil.Big)(new(big.Int)),
+			"issuance":    (*hexutil.Big)(issuance),
+		},
+		"totalFees": (*hexutil.Big)(fees),
+	}, nil
+}
+
+// GetBlockTransactions returns a page of the transactions of a block along
+// with their receipts. Pages are counted from the end of the block, matching
+// the newest-first order used by the explorer; inputs are cut down to the
+// method selector and logs are left out to keep the response small.
+func (o *OtterscanAPI) GetBlockTransactions(ctx context.Context, number jsonrpc.BlockNumber, pageNumber uint8, pageSize uint8) (map[string]interface{}, error) {
+	b, err := api.BlockByNumber(ctx, number, o.api)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	fields, err := api.RPCMarshalBlock(b, o.api.BlockChain(), true, true)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := o.blockReceipts(b)
+	if err != nil {
+		return nil, err
+	}
+	txs := b.Transactions()
+	pageEnd := len(txs) - int(pageNumber)*int(pageSize)
+	if pageEnd < 0 {
+		pageEnd = 0
+	}
+	pageStart := pageEnd - int(pageSize)
+	if pageStart < 0 {
+		pageStart = 0
+	}
+
+	var (
+		rpcTxs      = fields["transactions"].([]interface{})[pageStart:pageEnd]
+		rpcReceipts = make([]map[string]interface{}, 0, len(rpcTxs))
+	)
+	for i := pageStart; i < pageEnd; i++ {
+		if rpcTx, ok := rpcTxs[i-pageStart].(*api.RPCTransaction); ok && len(rpcTx.Input) > 4 {
+			rpcTx.Input = rpcTx.Input[:4]
+		}
+		receipt := api.MarshalReceipt(receipts[i], b.Hash(), b.Number64().Uint64(), b.BaseFee64(), txs[i], uint64(i))
+		receipt["logs"] = nil
+		receipt["logsBloom"] = nil
+		rpcReceipts = append(rpcReceipts, receipt)
+	}
+	fields["transactions"] = rpcTxs
+	fields["transactionCount"] = len(txs)
+	return map[string]interface{}{
+		"fullblock": fields,
+		"receipts":  rpcReceipts,
+	}, nil
+}
+
+// blockReceipts reads the receipts of b, checking they line up with its
+// transactions.
+func (o *OtterscanAPI) blockReceipts(b block.IBlock) (block.Receipts, error) {
+	receipts, err := o.api.BlockChain().GetReceipts(b.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(b.Transactions()) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(b.Transactions()), len(receipts))
+	}
+	return receipts, nil
+}
+
+// effectiveGasPrice returns the price per gas paid by tx in a block with the
+// given base fee.
+func effectiveGasPrice(tx *transaction.Transaction, baseFee *uint256.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice().ToBig()
+	}
+	return new(big.Int).Add(baseFee.ToBig(), tx.EffectiveGasTipValue(baseFee).ToBig())
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package otterscan
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	common "github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/api"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/ethdb/bitmapdb"
+	"github.com/n42blockchain/N42/modules/state"
+)
+
+// TransactionsWithReceipts is a page of ots_searchTransactions results,
+// ordered from the newest transaction to the oldest.
+type TransactionsWithReceipts struct {
+	Txs       []*api.RPCTransaction    `json:"txs"`
+	Receipts  []map[string]interface{} `json:"receipts"`
+	FirstPage bool                     `json:"firstPage"`
+	LastPage  bool                     `json:"lastPage"`
+}
+
+// ContractCreator identifies the transaction and account that deployed a
+// contract.
+type ContractCreator struct {
+	Tx      common.Hash    `json:"hash"`
+	Creator common.Address `json:"creator"`
+}
+
+// SearchTransactionsBefore returns at least pageSize transactions touching
+// address in blocks before blockNum, or from the head of the chain when
+// blockNum is zero. Blocks are never split across pages.
+//
+// Candidate blocks come from the account history index, so transactions
+// which reach address without changing its account, e.g. a value-less call
+// into a contract that only touches its storage, are found only when address
+// is the sender, the recipient, the created contract or a log emitter of a
+// transaction in one of those blocks.
+func (o *OtterscanAPI) SearchTransactionsBefore(ctx context.Context, address common.Address, blockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error) {
+	history, err := o.accountHistory(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	end := len(history)
+	if blockNum > 0 {
+		end = sort.Search(len(history), func(i int) bool { return history[i] >= blockNum })
+	}
+
+	result := &TransactionsWithReceipts{FirstPage: blockNum == 0}
+	i := end - 1
+	for ; i >= 0 && len(result.Txs) < int(pageSize); i-- {
+		if err := o.collectBlock(ctx, address, history[i], result); err != nil {
+			return nil, err
+		}
+	}
+	result.LastPage = i < 0
+	return result, nil
+}
+
+// SearchTransactionsAfter returns at least pageSize transactions touching
+// address in blocks after blockNum, or from genesis when blockNum is zero.
+// The page is still ordered newest first; see SearchTransactionsBefore for
+// the transactions this index can find.
+func (o *OtterscanAPI) SearchTransactionsAfter(ctx context.Context, address common.Address, blockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error) {
+	history, err := o.accountHistory(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	start := sort.Search(len(history), func(i int) bool { return history[i] > blockNum })
+
+	var (
+		ascending = &TransactionsWithReceipts{}
+		i         = start
+	)
+	for ; i < len(history) && len(ascending.Txs) < int(pageSize); i++ {
+		// collectBlock yields newest first; flip each block so the page
+		// stays ascending until it is reversed below.
+		page := &TransactionsWithReceipts{}
+		if err := o.collectBlock(ctx, address, history[i], page); err != nil {
+			return nil, err
+		}
+		for j := len(page.Txs) - 1; j >= 0; j-- {
+			ascending.Txs = append(ascending.Txs, page.Txs[j])
+			ascending.Receipts = append(ascending.Receipts, page.Receipts[j])
+		}
+	}
+
+	result := &TransactionsWithReceipts{
+		Txs:       make([]*api.RPCTransaction, 0, len(ascending.Txs)),
+		Receipts:  make([]map[string]interface{}, 0, len(ascending.Receipts)),
+		FirstPage: i == len(history),
+		LastPage:  blockNum == 0,
+	}
+	for j := len(ascending.Txs) - 1; j >= 0; j-- {
+		result.Txs = append(result.Txs, ascending.Txs[j])
+		result.Receipts = append(result.Receipts, ascending.Receipts[j])
+	}
+	return result, nil
+}
+
+// collectBlock appends the transactions of block number touching address to
+// result, newest first.
+func (o *OtterscanAPI) collectBlock(ctx context.Context, address common.Address, number uint64, result *TransactionsWithReceipts) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b, err := o.api.BlockChain().GetBlockByNumber(uint256.NewInt(number))
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return nil
+	}
+	receipts, err := o.blockReceipts(b)
+	if err != nil {
+		return err
+	}
+	txs := b.Transactions()
+	for i := len(txs) - 1; i >= 0; i-- {
+		tx, receipt := txs[i], receipts[i]
+		if !touches(address, tx.From(), tx.To(), receipt) {
+			continue
+		}
+		result.Txs = append(result.Txs, api.NewRPCTransactionFromBlockIndex(b, uint64(i)))
+		result.Receipts = append(result.Receipts, api.MarshalReceipt(receipt, b.Hash(), number, b.BaseFee64(), tx, uint64(i)))
+	}
+	return nil
+}
+
+// touches reports whether a transaction from sender to recipient with the
+// given receipt involves address.
+func touches(address common.Address, sender, recipient *common.Address, receipt *block.Receipt) bool {
+	if (sender != nil && *sender == address) || (recipient != nil && *recipient == address) {
+		return true
+	}
+	if receipt.ContractAddress == address {
+		return true
+	}
+	for _, l := range receipt.Logs {
+		if l.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTransactionBySenderAndNonce returns the hash of the transaction sent by
+// address with the given nonce, or nil if it has not been included yet.
+func (o *OtterscanAPI) GetTransactionBySenderAndNonce(ctx context.Context, address common.Address, nonce uint64) (*common.Hash, error) {
+	history, err := o.accountHistory(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	// The sender's nonce only grows, so the transaction is in the first
+	// block after which it exceeds the requested one.
+	var idx int
+	if err := o.api.Database().View(ctx, func(tx kv.Tx) error {
+		var searchErr error
+		reader := state.NewPlainState(tx, 0)
+		idx = sort.Search(len(history), func(i int) bool {
+			if searchErr != nil {
+				return true
+			}
+			reader.SetBlockNr(history[i] + 1)
+			acc, err := reader.ReadAccountData(address)
+			if err != nil {
+				searchErr = err
+				return true
+			}
+			return acc != nil && acc.Nonce > nonce
+		})
+		return searchErr
+	}); err != nil {
+		return nil, err
+	}
+	if idx == len(history) {
+		return nil, nil
+	}
+
+	b, err := o.api.BlockChain().GetBlockByNumber(uint256.NewInt(history[idx]))
+	if b == nil || err != nil {
+		return nil, err
+	}
+	for _, tx := range b.Transactions() {
+		if from := tx.From(); from != nil && *from == address && tx.Nonce() == nonce {
+			hash := tx.Hash()
+			return &hash, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetContractCreator returns the transaction and account which deployed the
+// contract at address, or nil if address holds no code. For contracts which
+// were destroyed and deployed again, the first deployment is reported.
+func (o *OtterscanAPI) GetContractCreator(ctx context.Context, address common.Address) (*ContractCreator, error) {
+	history, err := o.accountHistory(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	var idx int
+	if err := o.api.Database().View(ctx, func(tx kv.Tx) error {
+		var searchErr error
+		reader := state.NewPlainState(tx, 0)
+		idx = sort.Search(len(history), func(i int) bool {
+			if searchErr != nil {
+				return true
+			}
+			reader.SetBlockNr(history[i] + 1)
+			acc, err := reader.ReadAccountData(address)
+			if err != nil {
+				searchErr = err
+				return true
+			}
+			return acc != nil && !acc.IsEmptyCodeHash()
+		})
+		return searchErr
+	}); err != nil {
+		return nil, err
+	}
+	if idx == len(history) {
+		return nil, nil
+	}
+
+	b, err := o.api.BlockChain().GetBlockByNumber(uint256.NewInt(history[idx]))
+	if b == nil || err != nil {
+		return nil, err
+	}
+	receipts, err := o.blockReceipts(b)
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range b.Transactions() {
+		if receipts[i].ContractAddress == address && tx.From() != nil {
+			return &ContractCreator{Tx: tx.Hash(), Creator: *tx.From()}, nil
+		}
+	}
+	// Not a top level deployment, look for the CREATE frame.
+	for _, tx := range b.Transactions() {
+		frame, err := o.callTrace(ctx, tx.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if creator := frame.creatorOf(address); creator != nil {
+			return &ContractCreator{Tx: tx.Hash(), Creator: *creator}, nil
+		}
+	}
+	return nil, nil
+}
+
+// accountHistory returns the numbers of the blocks in which the account at
+// address changed, in ascending order.
+func (o *OtterscanAPI) accountHistory(ctx context.Context, address common.Address) ([]uint64, error) {
+	var history []uint64
+	if err := o.api.Database().View(ctx, func(tx kv.Tx) error {
+		bm, err := bitmapdb.Get64(tx, modules.AccountsHistory, address.Bytes(), 0, math.MaxUint64)
+		if err != nil {
+			return err
+		}
+		history = bm.ToArray()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package otterscan
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/n42blockchain/N42/common/hexutil"
+	common "github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/tracers"
+)
+
+// Operation types reported by ots_getInternalOperations.
+const (
+	opTransfer     = 0
+	opSelfDestruct = 1
+	opCreate       = 2
+	opCreate2      = 3
+)
+
+// callFrame is the subset of the callTracer output used by this namespace.
+type callFrame struct {
+	Type   string          `json:"type"`
+	From   common.Address  `json:"from"`
+	To     *common.Address `json:"to"`
+	Value  *hexutil.Big    `json:"value"`
+	Input  hexutil.Bytes   `json:"input"`
+	Output hexutil.Bytes   `json:"output"`
+	Error  string          `json:"error"`
+	Calls  []*callFrame    `json:"calls"`
+}
+
+// TraceEntry is a single call of an ots_traceTransaction result.
+type TraceEntry struct {
+	Type   string          `json:"type"`
+	Depth  int             `json:"depth"`
+	From   common.Address  `json:"from"`
+	To     *common.Address `json:"to"`
+	Value  *hexutil.Big    `json:"value"`
+	Input  hexutil.Bytes   `json:"input"`
+	Output hexutil.Bytes   `json:"output"`
+}
+
+// InternalOperation is a value transfer, self-destruct or contract creation
+// performed by a transaction below its top level call.
+type InternalOperation struct {
+	Type  int             `json:"type"`
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+}
+
+// TraceTransaction returns the calls made by a transaction, flattened in
+// execution order.
+func (o *OtterscanAPI) TraceTransaction(ctx context.Context, hash common.Hash) ([]*TraceEntry, error) {
+	frame, err := o.callTrace(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*TraceEntry, 0)
+	frame.walk(0, func(f *callFrame, depth int) {
+		entries = append(entries, &TraceEntry{
+			Type:   f.Type,
+			Depth:  depth,
+			From:   f.From,
+			To:     f.To,
+			Value:  f.Value,
+			Input:  f.Input,
+			Output: f.Output,
+		})
+	})
+	return entries, nil
+}
+
+// GetInternalOperations returns the value transfers, self-destructs and
+// contract creations made by the nested calls of a transaction.
+func (o *OtterscanAPI) GetInternalOperations(ctx context.Context, hash common.Hash) ([]*InternalOperation, error) {
+	frame, err := o.callTrace(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]*InternalOperation, 0)
+	frame.walk(0, func(f *callFrame, depth int) {
+		if depth == 0 || f.Error != "" {
+			return
+		}
+		op := &InternalOperation{From: f.From, To: f.To, Value: f.Value}
+		switch f.Type {
+		case "CALL", "CALLCODE":
+			if f.Value == nil || f.Value.ToInt().Sign() == 0 {
+				return
+			}
+			op.Type = opTransfer
+		case "SELFDESTRUCT":
+			op.Type = opSelfDestruct
+		case "CREATE":
+			op.Type = opCreate
+		case "CREATE2":
+			op.Type = opCreate2
+		default:
+			return
+		}
+		ops = append(ops, op)
+	})
+	return ops, nil
+}
+
+// GetTransactionError returns the revert data of a failed transaction, or
+// empty bytes when it succeeded.
+func (o *OtterscanAPI) GetTransactionError(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
+	frame, err := o.callTrace(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Error == "" {
+		return hexutil.Bytes{}, nil
+	}
+	return frame.Output, nil
+}
+
+// callTrace re-executes a transaction with the callTracer.
+func (o *OtterscanAPI) callTrace(ctx context.Context, hash common.Hash) (*callFrame, error) {
+	tracer := "callTracer"
+	result, err := o.tracer.TraceTransaction(ctx, hash, &tracers.TraceConfig{Tracer: &tracer})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	frame := new(callFrame)
+	if err := json.Unmarshal(raw, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// walk calls fn for f and all of its nested calls, depth first.
+func (f *callFrame) walk(depth int, fn func(*callFrame, int)) {
+	fn(f, depth)
+	for _, call := range f.Calls {
+		call.walk(depth+1, fn)
+	}
+}
+
+// creatorOf returns the account which created address within f, if any.
+func (f *callFrame) creatorOf(address common.Address) *common.Address {
+	var creator *common.Address
+	f.walk(0, func(c *callFrame, _ int) {
+		if creator != nil || c.Error != "" || c.To == nil || *c.To != address {
+			return
+		}
+		if c.Type == "CREATE" || c.Type == "CREATE2" {
+			from := c.From
+			creator = &from
+		}
+	})
+	return creator
+}
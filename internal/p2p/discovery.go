@@ -8,7 +8,6 @@ import (
 	"github.com/n42blockchain/N42/internal/p2p/enode"
 	"github.com/n42blockchain/N42/internal/p2p/enr"
 	"github.com/n42blockchain/N42/params"
-	"github.com/n42blockchain/N42/params/networkname"
 	"github.com/n42blockchain/N42/utils"
 	"net"
 	"path/filepath"
@@ -319,11 +318,8 @@ func PeersFromStringAddrs(addrs []string) ([]ma.Multiaddr, error) {
 
 func parseBootStrapAddrs(addrs []string, nodeCfg conf.NodeConfig) (discv5Nodes []string) {
 	if len(addrs) == 0 {
-		switch nodeCfg.Chain {
-		case networkname.MainnetChainName:
-			addrs = params.MainnetBootnodes
-		case networkname.TestnetChainName:
-			addrs = params.TestnetBootnodes
+		if preset := params.NetworkPresetByName(nodeCfg.Chain); preset != nil {
+			addrs = preset.Bootnodes
 		}
 	}
 	discv5Nodes, _ = parseGenericAddrs(addrs)
@@ -77,6 +77,7 @@ type PubSubProvider interface {
 
 // PeerManager abstracts some peer management methods from libp2p.
 type PeerManager interface {
+	Connect(peer.AddrInfo) error
 	Disconnect(peer.ID) error
 	PeerID() peer.ID
 	Host() host.Host
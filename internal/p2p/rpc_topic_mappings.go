@@ -3,6 +3,7 @@ package p2p
 import (
 	"github.com/n42blockchain/N42/api/protocol/sync_pb"
 	ssztype "github.com/n42blockchain/N42/common/types/ssz"
+	p2ptypes "github.com/n42blockchain/N42/internal/p2p/types"
 	"reflect"
 
 	"github.com/pkg/errors"
@@ -29,6 +30,9 @@ const BodiesByRangeMessageName = "/bodies_by_range"
 // HeadersByRangeMessageName specifies the name for the Headers by range message topic.
 const HeadersByRangeMessageName = "/headers_by_range"
 
+// StateByRangeMessageName specifies the name for the State by range message topic.
+const StateByRangeMessageName = "/state_by_range"
+
 const (
 	// V1 RPC Topics
 	// RPCStatusTopicV1 defines the v1 topic for the status rpc method.
@@ -43,6 +47,9 @@ const (
 
 	// RPCHeadersDataTopicV1 defines the v1 topic for the Headers rpc method.
 	RPCHeadersDataTopicV1 = protocolPrefix + HeadersByRangeMessageName + SchemaVersionV1
+
+	// RPCStateDataTopicV1 defines the v1 topic for the State rpc method.
+	RPCStateDataTopicV1 = protocolPrefix + StateByRangeMessageName + SchemaVersionV1
 )
 
 // RPC errors for topic parsing.
@@ -54,7 +61,9 @@ const (
 var RPCTopicMappings = map[string]interface{}{
 	// RPC Status Message
 	RPCStatusTopicV1:     new(sync_pb.Status),
-	RPCBodiesDataTopicV1: new(sync_pb.BodiesByRangeRequest),
+	RPCBodiesDataTopicV1:  new(sync_pb.BodiesByRangeRequest),
+	RPCHeadersDataTopicV1: new(sync_pb.HeadersByRangeRequest),
+	RPCStateDataTopicV1:   new(p2ptypes.StateRangeRequest),
 
 	RPCPingTopicV1:    new(ssztype.SSZUint64),
 	RPCGoodByeTopicV1: new(ssztype.SSZUint64),
@@ -73,6 +82,7 @@ var messageMapping = map[string]bool{
 	PingMessageName:           true,
 	BodiesByRangeMessageName:  true,
 	HeadersByRangeMessageName: true,
+	StateByRangeMessageName:   true,
 }
 
 var versionMapping = map[string]bool{
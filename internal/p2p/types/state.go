@@ -0,0 +1,135 @@
+package p2ptypes
+
+import (
+	"github.com/pkg/errors"
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// maxStateKeyLength bounds the keys of state entries, the longest being the
+// address, incarnation and location of a storage slot.
+const maxStateKeyLength = 64
+
+// maxStateValueLength bounds the values of state entries, the largest being
+// contract code.
+const maxStateValueLength = 1 << 20
+
+// The tables of the state walked by a StateRangeRequest.
+const (
+	StateTableAccounts     uint8 = iota // accounts with their code and storage, as of the pivot block
+	StateTableIncarnations              // incarnations of deleted accounts
+	StateTableRewards                   // unpaid rewards
+	StateTableDeposits                  // deposits
+)
+
+// stateRangeRequestFixedSize is the size of the fields of a state range
+// request, with the offset of its origin.
+const stateRangeRequestFixedSize = 8 + rootLength + 1 + 8 + 4
+
+// StateRangeRequest asks for up to Count entries of a table of the state as of
+// block Number with hash Hash, in key order starting at Origin.
+type StateRangeRequest struct {
+	Number uint64
+	Hash   [rootLength]byte
+	Table  uint8
+	Count  uint64
+	Origin []byte
+}
+
+// MarshalSSZTo marshals the state range request with the provided byte slice.
+func (r *StateRangeRequest) MarshalSSZTo(dst []byte) ([]byte, error) {
+	if len(r.Origin) > maxStateKeyLength {
+		return nil, errors.Errorf("state range origin exceeds max size: %d > %d", len(r.Origin), maxStateKeyLength)
+	}
+	dst = ssz.MarshalUint64(dst, r.Number)
+	dst = append(dst, r.Hash[:]...)
+	dst = append(dst, r.Table)
+	dst = ssz.MarshalUint64(dst, r.Count)
+	dst = ssz.WriteOffset(dst, stateRangeRequestFixedSize)
+	return append(dst, r.Origin...), nil
+}
+
+// MarshalSSZ marshals the state range request into the serialized object.
+func (r *StateRangeRequest) MarshalSSZ() ([]byte, error) {
+	return r.MarshalSSZTo(make([]byte, 0, r.SizeSSZ()))
+}
+
+// SizeSSZ returns the size of the serialized representation.
+func (r *StateRangeRequest) SizeSSZ() int {
+	return stateRangeRequestFixedSize + len(r.Origin)
+}
+
+// UnmarshalSSZ unmarshals the provided bytes buffer into the state range
+// request object.
+func (r *StateRangeRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) < stateRangeRequestFixedSize {
+		return ssz.ErrSize
+	}
+	if len(buf)-stateRangeRequestFixedSize > maxStateKeyLength {
+		return errors.Errorf("expected origin with length of upto %d but received length %d", maxStateKeyLength, len(buf)-stateRangeRequestFixedSize)
+	}
+	if ssz.ReadOffset(buf[49:53]) != stateRangeRequestFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	r.Number = ssz.UnmarshallUint64(buf[0:8])
+	copy(r.Hash[:], buf[8:40])
+	r.Table = buf[40]
+	r.Count = ssz.UnmarshallUint64(buf[41:49])
+	r.Origin = append([]byte(nil), buf[stateRangeRequestFixedSize:]...)
+	return nil
+}
+
+// StateEntry is a key and its value in a table of the state. The entries of
+// the accounts table are keyed by the address of an account, by its address
+// and incarnation for its code, and by its address, incarnation and location
+// for its storage, so that the code and storage of an account follow it in key
+// order.
+type StateEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// MarshalSSZTo marshals the state entry with the provided byte slice.
+func (e *StateEntry) MarshalSSZTo(dst []byte) ([]byte, error) {
+	if len(e.Key) > maxStateKeyLength {
+		return nil, errors.Errorf("state entry key exceeds max size: %d > %d", len(e.Key), maxStateKeyLength)
+	}
+	if len(e.Value) > maxStateValueLength {
+		return nil, errors.Errorf("state entry value exceeds max size: %d > %d", len(e.Value), maxStateValueLength)
+	}
+	dst = ssz.WriteOffset(dst, 8)
+	dst = ssz.WriteOffset(dst, 8+len(e.Key))
+	dst = append(dst, e.Key...)
+	return append(dst, e.Value...), nil
+}
+
+// MarshalSSZ marshals the state entry into the serialized object.
+func (e *StateEntry) MarshalSSZ() ([]byte, error) {
+	return e.MarshalSSZTo(make([]byte, 0, e.SizeSSZ()))
+}
+
+// SizeSSZ returns the size of the serialized representation.
+func (e *StateEntry) SizeSSZ() int {
+	return 8 + len(e.Key) + len(e.Value)
+}
+
+// UnmarshalSSZ unmarshals the provided bytes buffer into the state entry
+// object.
+func (e *StateEntry) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < 8 {
+		return ssz.ErrSize
+	}
+	o0, o1 := ssz.ReadOffset(buf[0:4]), ssz.ReadOffset(buf[4:8])
+	if o0 != 8 || o1 < o0 || o1 > size {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if o1-o0 > maxStateKeyLength {
+		return errors.Errorf("expected key with length of upto %d but received length %d", maxStateKeyLength, o1-o0)
+	}
+	if size-o1 > maxStateValueLength {
+		return errors.Errorf("expected value with length of upto %d but received length %d", maxStateValueLength, size-o1)
+	}
+	e.Key = append([]byte(nil), buf[o0:o1]...)
+	e.Value = append([]byte(nil), buf[o1:]...)
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/n42blockchain/N42/common"
+	"github.com/n42blockchain/N42/common/account"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	vm2 "github.com/n42blockchain/N42/internal/vm"
+	"github.com/n42blockchain/N42/internal/vm/evmtypes"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules/state"
+)
+
+// minParallelTxs is the least number of transactions of a block executed in
+// parallel, fewer are not worth the speculative executions.
+const minParallelTxs = 4
+
+var (
+	parallelTxsMerged     = prometheus.GetOrCreateCounter("chain_execution_parallel_merged")
+	parallelTxsReexecuted = prometheus.GetOrCreateCounter("chain_execution_parallel_reexecuted")
+)
+
+// ownerCalls carries the reads of the workers to the goroutine owning the
+// database transaction, as database transactions must not be shared.
+type ownerCalls chan func()
+
+// do runs f on the owning goroutine and waits for it.
+func (calls ownerCalls) do(f func()) {
+	done := make(chan struct{})
+	calls <- func() {
+		f()
+		close(done)
+	}
+	<-done
+}
+
+// speculativeReader reads the state of the parent block for a transaction
+// executed ahead of its turn, and records the accounts it read.
+type speculativeReader struct {
+	calls  ownerCalls
+	reader state.StateReader
+	read   map[types.Address]struct{}
+}
+
+func (r *speculativeReader) ReadAccountData(address types.Address) (acc *account.StateAccount, err error) {
+	r.read[address] = struct{}{}
+	r.calls.do(func() { acc, err = r.reader.ReadAccountData(address) })
+	return acc, err
+}
+
+func (r *speculativeReader) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) (enc []byte, err error) {
+	r.read[address] = struct{}{}
+	r.calls.do(func() { enc, err = r.reader.ReadAccountStorage(address, incarnation, key) })
+	return enc, err
+}
+
+func (r *speculativeReader) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) (code []byte, err error) {
+	r.read[address] = struct{}{}
+	r.calls.do(func() { code, err = r.reader.ReadAccountCode(address, incarnation, codeHash) })
+	return code, err
+}
+
+func (r *speculativeReader) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (size int, err error) {
+	r.read[address] = struct{}{}
+	r.calls.do(func() { size, err = r.reader.ReadAccountCodeSize(address, incarnation, codeHash) })
+	return size, err
+}
+
+func (r *speculativeReader) ReadAccountIncarnation(address types.Address) (inc uint16, err error) {
+	r.read[address] = struct{}{}
+	r.calls.do(func() { inc, err = r.reader.ReadAccountIncarnation(address) })
+	return inc, err
+}
+
+// speculativeTx is the outcome of executing a transaction on the state of the
+// parent block.
+type speculativeTx struct {
+	ibs    *state.IntraBlockState
+	read   map[types.Address]struct{}
+	msg    transaction.Message
+	result *ExecutionResult
+	err    error
+}
+
+// valid reports whether the speculative execution holds on ibs, the state
+// after the transactions before it, that is none of the accounts it read was
+// changed since.
+func (s *speculativeTx) valid(ibs *state.IntraBlockState) bool {
+	if s.err != nil || s.ibs.Error() != nil {
+		return false
+	}
+	for addr := range s.read {
+		if ibs.Changed(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyTransactionsParallel applies the transactions of b to ibs like the
+// serial loop of Process, but first executes them all on workers against the
+// state of the parent block. Going through the transactions in order, the
+// changes of a speculative execution are taken over if none of the accounts it
+// read was changed by the transactions before, and the transaction is
+// executed again on ibs otherwise. On failure it returns the index of the
+// transaction which could not be applied.
+func (p *StateProcessor) applyTransactionsParallel(b *block.Block, ibs *state.IntraBlockState, reader state.StateReader, gp *common.GasPool, usedGas *uint64, blockHashFunc func(n uint64) types.Hash, cfg vm2.Config) (block.Receipts, int, error) {
+	header := b.Header().(*block.Header)
+	txs := b.Transactions()
+
+	calls := make(ownerCalls)
+	hashFunc := func(n uint64) (hash types.Hash) {
+		calls.do(func() { hash = blockHashFunc(n) })
+		return hash
+	}
+	blockContext := NewEVMBlockContext(header, hashFunc, p.engine, nil)
+
+	var (
+		specs = make([]*speculativeTx, len(txs))
+		next  atomic.Int64
+		wg    sync.WaitGroup
+	)
+	for w := 0; w < min(p.workers, len(txs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(txs); i = int(next.Add(1) - 1) {
+				spec := &speculativeTx{read: make(map[types.Address]struct{})}
+				spec.ibs = state.New(&speculativeReader{calls: calls, reader: reader, read: spec.read})
+				spec.ibs.Prepare(txs[i].Hash(), b.Hash(), i)
+				evm := vm2.NewEVM(blockContext, evmtypes.TxContext{}, spec.ibs, p.config, cfg)
+				spec.msg, spec.result, spec.err = applyMessage(p.config, p.engine, new(common.GasPool).AddGas(header.GasLimit), spec.ibs, header, txs[i], evm, cfg)
+				specs[i] = spec
+			}
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	for running := true; running; {
+		select {
+		case call := <-calls:
+			call()
+		case <-finished:
+			running = false
+		}
+	}
+
+	var (
+		rules      = p.config.Rules(header.Number.Uint64())
+		noop       = state.NewNoopWriter()
+		receipts   = make(block.Receipts, 0, len(txs))
+		reexecuted int
+	)
+	for i, tx := range txs {
+		ibs.Prepare(tx.Hash(), b.Hash(), i)
+		spec := specs[i]
+		// The block gas limit is checked before the transaction runs, the
+		// speculative execution had it all.
+		if !spec.valid(ibs) || gp.Gas() < tx.Gas() {
+			receipt, _, err := ApplyTransaction(p.config, blockHashFunc, p.engine, nil, gp, ibs, noop, header, tx, usedGas, cfg)
+			if err != nil {
+				return nil, i, err
+			}
+			if !cfg.NoReceipts {
+				receipts = append(receipts, receipt)
+			}
+			reexecuted++
+			continue
+		}
+		if err := gp.SubGas(spec.result.UsedGas); err != nil {
+			return nil, i, err
+		}
+		ibs.MergeTx(spec.ibs)
+		if err := ibs.FinalizeTx(rules, noop); err != nil {
+			return nil, i, err
+		}
+		*usedGas += spec.result.UsedGas
+		if !cfg.NoReceipts {
+			receipts = append(receipts, newReceipt(ibs, header, tx, spec.msg, spec.result, *usedGas))
+		}
+	}
+
+	parallelTxsMerged.Add(len(txs) - reexecuted)
+	parallelTxsReexecuted.Add(reexecuted)
+	log.Debug("Executed transactions in parallel", "block", header.Number.Uint64(), "txs", len(txs), "reexecuted", reexecuted)
+	return receipts, 0, nil
+}
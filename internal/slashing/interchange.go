@@ -0,0 +1,178 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package slashing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/hexutil"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+// InterchangeVersion is the version of the EIP-3076 interchange format read
+// and written.
+const InterchangeVersion = "5"
+
+// Interchange is the signing history of validator keys in the EIP-3076
+// interchange format, which moves it between nodes and clients. The slots of
+// the blocks are their numbers, and the genesis validators root is the hash of
+// the genesis block.
+type Interchange struct {
+	Metadata InterchangeMetadata `json:"metadata"`
+	Data     []InterchangeData   `json:"data"`
+}
+
+// InterchangeMetadata identifies the format and the chain of an interchange.
+type InterchangeMetadata struct {
+	Version               string     `json:"interchange_format_version"`
+	GenesisValidatorsRoot types.Hash `json:"genesis_validators_root"`
+}
+
+// InterchangeData is the signing history of a validator key.
+type InterchangeData struct {
+	Pubkey             hexutil.Bytes       `json:"pubkey"`
+	SignedBlocks       []SignedBlock       `json:"signed_blocks"`
+	SignedAttestations []SignedAttestation `json:"signed_attestations"`
+}
+
+// SignedBlock is a block signed by a validator key.
+type SignedBlock struct {
+	Slot        uint64      `json:"slot,string"`
+	SigningRoot *types.Hash `json:"signing_root,omitempty"`
+}
+
+// SignedAttestation is an attestation signed by a validator key.
+type SignedAttestation struct {
+	SourceEpoch uint64      `json:"source_epoch,string"`
+	TargetEpoch uint64      `json:"target_epoch,string"`
+	SigningRoot *types.Hash `json:"signing_root,omitempty"`
+}
+
+// Export returns the signing history of every key of the chain with the
+// genesis block genesis.
+func (p *Protection) Export(ctx context.Context, genesis types.Hash) (*Interchange, error) {
+	interchange := &Interchange{
+		Metadata: InterchangeMetadata{Version: InterchangeVersion, GenesisValidatorsRoot: genesis},
+		Data:     []InterchangeData{},
+	}
+	err := p.db.View(ctx, func(tx kv.Tx) error {
+		keys, err := rawdb.ReadSigningKeys(tx)
+		if err != nil {
+			return err
+		}
+		for _, pubkey := range keys {
+			data := InterchangeData{
+				Pubkey:             pubkey.Bytes(),
+				SignedBlocks:       []SignedBlock{},
+				SignedAttestations: []SignedAttestation{},
+			}
+			if err := rawdb.ReadSignedBlocks(tx, pubkey, func(number uint64, root types.Hash) error {
+				data.SignedBlocks = append(data.SignedBlocks, SignedBlock{Slot: number, SigningRoot: signingRoot(root)})
+				return nil
+			}); err != nil {
+				return err
+			}
+			if err := rawdb.ReadSignedAttestations(tx, pubkey, func(source, target uint64, root types.Hash) error {
+				data.SignedAttestations = append(data.SignedAttestations, SignedAttestation{SourceEpoch: source, TargetEpoch: target, SigningRoot: signingRoot(root)})
+				return nil
+			}); err != nil {
+				return err
+			}
+			interchange.Data = append(interchange.Data, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return interchange, nil
+}
+
+// Import merges the signing history of interchange, which must be of the chain
+// with the genesis block genesis, into the history of the node. A block or an
+// attestation conflicting with the one recorded is kept without signing root,
+// so that neither of them is signed again.
+func (p *Protection) Import(ctx context.Context, genesis types.Hash, interchange *Interchange) error {
+	if interchange.Metadata.Version != InterchangeVersion {
+		return fmt.Errorf("unsupported interchange format version %q, want %q", interchange.Metadata.Version, InterchangeVersion)
+	}
+	if interchange.Metadata.GenesisValidatorsRoot != genesis {
+		return fmt.Errorf("interchange of the chain %s, not %s", interchange.Metadata.GenesisValidatorsRoot, genesis)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.db.Update(ctx, func(tx kv.RwTx) error {
+		for _, data := range interchange.Data {
+			var pubkey types.PublicKey
+			if err := pubkey.SetBytes(data.Pubkey); err != nil {
+				return fmt.Errorf("invalid public key %s: %w", data.Pubkey, err)
+			}
+			for _, b := range data.SignedBlocks {
+				root := types.Hash{}
+				if b.SigningRoot != nil {
+					root = *b.SigningRoot
+				}
+				signed, ok, err := rawdb.ReadSignedBlock(tx, pubkey, b.Slot)
+				if err != nil {
+					return err
+				}
+				if ok && signed != root {
+					root = types.Hash{}
+				}
+				if err := rawdb.WriteSignedBlock(tx, pubkey, b.Slot, root); err != nil {
+					return err
+				}
+			}
+			signed := make(map[uint64]types.Hash)
+			if err := rawdb.ReadSignedAttestations(tx, pubkey, func(_, target uint64, root types.Hash) error {
+				signed[target] = root
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, a := range data.SignedAttestations {
+				if a.SourceEpoch > a.TargetEpoch {
+					return fmt.Errorf("%w: source %d, target %d of %s", ErrInvalidAttestation, a.SourceEpoch, a.TargetEpoch, data.Pubkey)
+				}
+				root := types.Hash{}
+				if a.SigningRoot != nil {
+					root = *a.SigningRoot
+				}
+				if r, ok := signed[a.TargetEpoch]; ok && r != root {
+					root = types.Hash{}
+				}
+				signed[a.TargetEpoch] = root
+				if err := rawdb.WriteSignedAttestation(tx, pubkey, a.SourceEpoch, a.TargetEpoch, root); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// signingRoot returns root, nil for the zero root of an imported record
+// without one.
+func signingRoot(root types.Hash) *types.Hash {
+	if root == (types.Hash{}) {
+		return nil
+	}
+	return &root
+}
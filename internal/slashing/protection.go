@@ -0,0 +1,137 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package slashing keeps the signing history of the local validator keys and
+// refuses to sign anything a validator could be slashed for, signing two
+// different blocks of the same number or conflicting attestations.
+package slashing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules/rawdb"
+)
+
+var (
+	// ErrDoubleBlock is returned when signing a block of a number the key
+	// signed another block of.
+	ErrDoubleBlock = errors.New("slashable block, another block of the same number was signed")
+	// ErrBlockTooLow is returned when signing a block below the history, which
+	// may have been signed before the history was recorded.
+	ErrBlockTooLow = errors.New("block is below the lowest signed block")
+	// ErrDoubleVote is returned when signing an attestation of a target epoch
+	// the key signed another attestation of.
+	ErrDoubleVote = errors.New("slashable attestation, another attestation of the same target was signed")
+	// ErrSurroundVote is returned when signing an attestation surrounding or
+	// surrounded by a signed one.
+	ErrSurroundVote = errors.New("slashable attestation, it surrounds or is surrounded by a signed attestation")
+	// ErrAttestationTooLow is returned when signing an attestation below the
+	// history, which may have been signed before the history was recorded.
+	ErrAttestationTooLow = errors.New("attestation is below the lowest signed attestation")
+	// ErrInvalidAttestation is returned when signing an attestation whose
+	// source is after its target.
+	ErrInvalidAttestation = errors.New("attestation source is after its target")
+)
+
+// Protection records what the local validator keys sign in the chain database,
+// and refuses to sign what would be slashable given that history.
+type Protection struct {
+	db kv.RwDB
+	mu sync.Mutex // Serialises the checks so that two signatures cannot pass the same one
+}
+
+// New creates the slashing protection keeping its history in db.
+func New(db kv.RwDB) *Protection {
+	return &Protection{db: db}
+}
+
+// CheckBlock records that pubkey signs the block number with the signing root
+// root, or returns an error if it must not be signed. Signing the same block
+// again is allowed.
+func (p *Protection) CheckBlock(ctx context.Context, pubkey types.PublicKey, number uint64, root types.Hash) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.db.Update(ctx, func(tx kv.RwTx) error {
+		signed, ok, err := rawdb.ReadSignedBlock(tx, pubkey, number)
+		if err != nil {
+			return err
+		}
+		if ok {
+			// An imported block without signing root conflicts with any.
+			if signed == root && root != (types.Hash{}) {
+				return nil
+			}
+			return fmt.Errorf("%w: block %d signed with root %s", ErrDoubleBlock, number, signed)
+		}
+		lowest, ok, err := rawdb.ReadLowestSignedBlock(tx, pubkey)
+		if err != nil {
+			return err
+		}
+		if ok && number < lowest {
+			return fmt.Errorf("%w: block %d, lowest %d", ErrBlockTooLow, number, lowest)
+		}
+		return rawdb.WriteSignedBlock(tx, pubkey, number, root)
+	})
+}
+
+// CheckAttestation records that pubkey signs the attestation from the source
+// to the target epoch with the signing root root, or returns an error if it
+// must not be signed. Signing the same attestation again is allowed.
+func (p *Protection) CheckAttestation(ctx context.Context, pubkey types.PublicKey, source, target uint64, root types.Hash) error {
+	if source > target {
+		return fmt.Errorf("%w: source %d, target %d", ErrInvalidAttestation, source, target)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.db.Update(ctx, func(tx kv.RwTx) error {
+		var (
+			signedAgain bool
+			history     bool
+			minSource   uint64
+			minTarget   uint64
+		)
+		err := rawdb.ReadSignedAttestations(tx, pubkey, func(s, t uint64, r types.Hash) error {
+			switch {
+			case t == target && r == root && root != (types.Hash{}):
+				signedAgain = true
+			case t == target:
+				return fmt.Errorf("%w: target %d signed with root %s", ErrDoubleVote, target, r)
+			case s < source && target < t, source < s && t < target:
+				return fmt.Errorf("%w: source %d, target %d, signed source %d, target %d", ErrSurroundVote, source, target, s, t)
+			}
+			if !history || s < minSource {
+				minSource = s
+			}
+			if !history || t < minTarget {
+				minTarget = t
+			}
+			history = true
+			return nil
+		})
+		if err != nil || signedAgain {
+			return err
+		}
+		if history && (source < minSource || target <= minTarget) {
+			return fmt.Errorf("%w: source %d, target %d, lowest source %d, target %d", ErrAttestationTooLow, source, target, minSource, minTarget)
+		}
+		return rawdb.WriteSignedAttestation(tx, pubkey, source, target, root)
+	})
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package slashing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+)
+
+var (
+	testKey     = types.PublicKey{1}
+	otherKey    = types.PublicKey{2}
+	testGenesis = types.Hash{31: 0x42}
+
+	rootA = types.Hash{31: 0xa0}
+	rootB = types.Hash{31: 0xb0}
+)
+
+func newTestProtection(t *testing.T) *Protection {
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	return New(memdb.NewTestDB(t))
+}
+
+func TestCheckBlock(t *testing.T) {
+	p := newTestProtection(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		pubkey types.PublicKey
+		number uint64
+		root   types.Hash
+		want   error
+	}{
+		{testKey, 10, rootA, nil},
+		{testKey, 10, rootA, nil},
+		{testKey, 10, rootB, ErrDoubleBlock},
+		{testKey, 5, rootB, ErrBlockTooLow},
+		{testKey, 11, rootB, nil},
+		{otherKey, 5, rootB, nil},
+	}
+	for i, tt := range tests {
+		if err := p.CheckBlock(ctx, tt.pubkey, tt.number, tt.root); !errors.Is(err, tt.want) {
+			t.Errorf("test %d: block %d: have %v, want %v", i, tt.number, err, tt.want)
+		}
+	}
+}
+
+func TestCheckAttestation(t *testing.T) {
+	p := newTestProtection(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		source, target uint64
+		root           types.Hash
+		want           error
+	}{
+		{3, 4, rootA, nil},
+		{3, 4, rootA, nil},
+		{3, 4, rootB, ErrDoubleVote},
+		{5, 4, rootB, ErrInvalidAttestation},
+		{2, 5, rootB, ErrSurroundVote},
+		{4, 8, rootB, nil},
+		{5, 7, rootB, ErrSurroundVote},
+		{2, 3, rootB, ErrAttestationTooLow},
+		{8, 9, rootB, nil},
+	}
+	for i, tt := range tests {
+		if err := p.CheckAttestation(ctx, testKey, tt.source, tt.target, tt.root); !errors.Is(err, tt.want) {
+			t.Errorf("test %d: attestation %d-%d: have %v, want %v", i, tt.source, tt.target, err, tt.want)
+		}
+	}
+}
+
+func TestInterchangeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProtection(t)
+	if err := p.CheckBlock(ctx, testKey, 10, rootA); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CheckAttestation(ctx, testKey, 3, 4, rootA); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CheckBlock(ctx, otherKey, 7, rootB); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := p.Export(ctx, testGenesis)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	enc, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var interchange Interchange
+	if err := json.Unmarshal(enc, &interchange); err != nil {
+		t.Fatalf("failed to decode the interchange: %v", err)
+	}
+
+	imported := newTestProtection(t)
+	if err := imported.Import(ctx, types.Hash{31: 0x43}, &interchange); err == nil {
+		t.Error("imported the interchange of another chain")
+	}
+	if err := imported.Import(ctx, testGenesis, &interchange); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	reexported, err := imported.Export(ctx, testGenesis)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	reenc, err := json.Marshal(reexported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reenc, enc) {
+		t.Errorf("interchange changed on import\nhave %s\nwant %s", reenc, enc)
+	}
+
+	// The imported history protects as the original one
+	if err := imported.CheckBlock(ctx, testKey, 10, rootA); err != nil {
+		t.Errorf("refused the signed block again: %v", err)
+	}
+	if err := imported.CheckBlock(ctx, testKey, 10, rootB); !errors.Is(err, ErrDoubleBlock) {
+		t.Errorf("have %v, want %v", err, ErrDoubleBlock)
+	}
+	if err := imported.CheckAttestation(ctx, testKey, 2, 5, rootB); !errors.Is(err, ErrSurroundVote) {
+		t.Errorf("have %v, want %v", err, ErrSurroundVote)
+	}
+}
+
+func TestImportConflicting(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProtection(t)
+	if err := p.CheckBlock(ctx, testKey, 10, rootA); err != nil {
+		t.Fatal(err)
+	}
+	interchange := &Interchange{
+		Metadata: InterchangeMetadata{Version: InterchangeVersion, GenesisValidatorsRoot: testGenesis},
+		Data: []InterchangeData{{
+			Pubkey:       testKey.Bytes(),
+			SignedBlocks: []SignedBlock{{Slot: 10, SigningRoot: &rootB}},
+		}},
+	}
+	if err := p.Import(ctx, testGenesis, interchange); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	// Neither of the conflicting blocks may be signed again
+	for _, root := range []types.Hash{rootA, rootB} {
+		if err := p.CheckBlock(ctx, testKey, 10, root); !errors.Is(err, ErrDoubleBlock) {
+			t.Errorf("root %s: have %v, want %v", root, err, ErrDoubleBlock)
+		}
+	}
+
+	interchange.Metadata.Version = "4"
+	if err := p.Import(ctx, testGenesis, interchange); err == nil {
+		t.Error("imported an unsupported interchange version")
+	}
+}
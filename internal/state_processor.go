@@ -39,9 +39,10 @@ import (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
-	engine consensus.Engine    // Consensus engine used for block rewards
+	config  *params.ChainConfig // Chain configuration options
+	bc      *BlockChain         // Canonical block chain
+	engine  consensus.Engine    // Consensus engine used for block rewards
+	workers int                 // Number of workers executing transactions in parallel
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -53,6 +54,12 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 	}
 }
 
+// SetWorkers sets the number of workers executing the transactions of a block
+// optimistically in parallel, at most one executes them serially.
+func (p *StateProcessor) SetWorkers(workers int) {
+	p.workers = workers
+}
+
 // Process processes the state changes according to the Ethereum rules by running
 // the transaction messages using the statedb and applying any rewards to both
 // the processor (coinbase) and any included uncles.
@@ -76,24 +83,39 @@ func (p *StateProcessor) Process(b *block.Block, ibs *state.IntraBlockState, sta
 	cfg := vm2.Config{}
 
 	chainConfig := p.config
-	if chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(b.Number64().ToBig()) == 0 {
+	daoFork := chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(b.Number64().ToBig()) == 0
+	if daoFork {
 		misc.ApplyDAOHardFork(ibs)
 	}
 	noop := state.NewNoopWriter()
 
-	//posa, isPoSA := p.engine.(*apoa.Apoa)
-	for i, tx := range b.Transactions() {
-		ibs.Prepare(tx.Hash(), b.Hash(), i)
-		receipt, _, err := ApplyTransaction(chainConfig, blockHashFunc, p.engine, nil, gp, ibs, noop, header.(*block.Header), tx, usedGas, cfg)
+	// The speculative executions read the state below the DAO changes and
+	// any snapshot being recorded would miss their reads.
+	if p.workers > 1 && len(b.Transactions()) >= minParallelTxs && !daoFork && ibs.Snap() == nil {
+		var (
+			i   int
+			err error
+		)
+		receipts, i, err = p.applyTransactionsParallel(b, ibs, stateReader, gp, usedGas, blockHashFunc, cfg)
 		if err != nil {
-			if !cfg.StatelessExec {
-				return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
-			}
-			rejectedTxs = append(rejectedTxs, &RejectedTx{i, err.Error()})
-		} else {
-			includedTxs = append(includedTxs, tx)
-			if !cfg.NoReceipts {
-				receipts = append(receipts, receipt)
+			tx := b.Transactions()[i]
+			return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
+		}
+	} else {
+		//posa, isPoSA := p.engine.(*apoa.Apoa)
+		for i, tx := range b.Transactions() {
+			ibs.Prepare(tx.Hash(), b.Hash(), i)
+			receipt, _, err := ApplyTransaction(chainConfig, blockHashFunc, p.engine, nil, gp, ibs, noop, header.(*block.Header), tx, usedGas, cfg)
+			if err != nil {
+				if !cfg.StatelessExec {
+					return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
+				}
+				rejectedTxs = append(rejectedTxs, &RejectedTx{i, err.Error()})
+			} else {
+				includedTxs = append(includedTxs, tx)
+				if !cfg.NoReceipts {
+					receipts = append(receipts, receipt)
+				}
 			}
 		}
 	}
@@ -136,6 +158,27 @@ func (p *StateProcessor) Process(b *block.Block, ibs *state.IntraBlockState, sta
 // indicating the block was invalid.
 func applyTransaction(config *params.ChainConfig, engine consensus.Engine, gp *common.GasPool, ibs *state.IntraBlockState, stateWriter state.StateWriter, header *block.Header, tx *transaction.Transaction, usedGas *uint64, evm vm2.VMInterface, cfg vm2.Config) (*block.Receipt, []byte, error) {
 	rules := evm.ChainRules()
+	msg, result, err := applyMessage(config, engine, gp, ibs, header, tx, evm, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Update the state with pending changes
+	if err = ibs.FinalizeTx(rules, stateWriter); err != nil {
+		return nil, nil, err
+	}
+	*usedGas += result.UsedGas
+
+	var receipt *block.Receipt
+	if !cfg.NoReceipts {
+		receipt = newReceipt(ibs, header, tx, msg, result, *usedGas)
+	}
+
+	return receipt, result.ReturnData, err
+}
+
+// applyMessage executes a transaction on the given state database without
+// finalizing its changes.
+func applyMessage(config *params.ChainConfig, engine consensus.Engine, gp *common.GasPool, ibs *state.IntraBlockState, header *block.Header, tx *transaction.Transaction, evm vm2.VMInterface, cfg vm2.Config) (transaction.Message, *ExecutionResult, error) {
 	//msg, err := tx.AsMessage(*transaction.MakeSigner(config, header.Number.Uint64()))
 	//if err != nil {
 	//	return nil, nil, err
@@ -143,7 +186,7 @@ func applyTransaction(config *params.ChainConfig, engine consensus.Engine, gp *c
 
 	msg, err := tx.AsMessage(transaction.MakeSigner(config, header.Number.ToBig()), header.BaseFee)
 	if err != nil {
-		return nil, nil, err
+		return msg, nil, err
 	}
 
 	msg.SetCheckNonce(!cfg.StatelessExec)
@@ -165,41 +208,34 @@ func applyTransaction(config *params.ChainConfig, engine consensus.Engine, gp *c
 	evm.Reset(txContext, ibs)
 
 	result, err := ApplyMessage(evm, msg, gp, true /* refunds */, false /* gasBailout */)
-	if err != nil {
-		return nil, nil, err
-	}
-	// Update the state with pending changes
-	if err = ibs.FinalizeTx(rules, stateWriter); err != nil {
-		return nil, nil, err
-	}
-	*usedGas += result.UsedGas
+	return msg, result, err
+}
 
+// newReceipt creates the receipt of a transaction whose changes were applied
+// to the given state database, with the gas used by the block so far.
+func newReceipt(ibs *state.IntraBlockState, header *block.Header, tx *transaction.Transaction, msg transaction.Message, result *ExecutionResult, cumulativeGasUsed uint64) *block.Receipt {
 	// Set the receipt logs and create the bloom filter.
 	// based on the eip phase, we're passing whether the root touch-delete accounts.
-	var receipt *block.Receipt
-	if !cfg.NoReceipts {
-		// by the tx.
-		receipt = &block.Receipt{Type: tx.Type(), CumulativeGasUsed: *usedGas}
-		if result.Failed() {
-			receipt.Status = block.ReceiptStatusFailed
-		} else {
-			receipt.Status = block.ReceiptStatusSuccessful
-		}
-
-		receipt.TxHash = tx.Hash()
-		receipt.GasUsed = result.UsedGas
-		// if the transaction created a contract, store the creation address in the receipt.
-		if msg.To() == nil {
-			receipt.ContractAddress = crypto.CreateAddress(evm.TxContext().Origin, tx.Nonce())
-		}
-		// Set the receipt logs and create a bloom for filtering
-		receipt.Logs = ibs.GetLogs(tx.Hash())
-		receipt.Bloom = block.CreateBloom(block.Receipts{receipt})
-		receipt.BlockNumber = header.Number
-		receipt.TransactionIndex = uint(ibs.TxIndex())
+	// by the tx.
+	receipt := &block.Receipt{Type: tx.Type(), CumulativeGasUsed: cumulativeGasUsed}
+	if result.Failed() {
+		receipt.Status = block.ReceiptStatusFailed
+	} else {
+		receipt.Status = block.ReceiptStatusSuccessful
 	}
 
-	return receipt, result.ReturnData, err
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = result.UsedGas
+	// if the transaction created a contract, store the creation address in the receipt.
+	if msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From(), tx.Nonce())
+	}
+	// Set the receipt logs and create a bloom for filtering
+	receipt.Logs = ibs.GetLogs(tx.Hash())
+	receipt.Bloom = block.CreateBloom(block.Receipts{receipt})
+	receipt.BlockNumber = header.Number
+	receipt.TransactionIndex = uint(ibs.TxIndex())
+	return receipt
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
@@ -63,6 +63,7 @@ type blocksQueueConfig struct {
 	highestExpectedBlockNr *uint256.Int
 	p2p                    p2p.P2P
 	mode                   syncMode
+	skeleton               *headerSkeleton
 }
 
 // blocksQueue is a priority queue that serves as a intermediary between block fetchers (producers)
@@ -75,6 +76,8 @@ type blocksQueue struct {
 	chain                  common.IBlockChain
 	highestExpectedBlockNr *uint256.Int
 	mode                   syncMode
+	skeleton               *headerSkeleton // checks fetched blocks, if set
+	lookahead              int             // number of state machines
 	exitConditions         struct {
 		noRequiredPeersErrRetries int
 	}
@@ -111,10 +114,17 @@ func newBlocksQueue(ctx context.Context, cfg *blocksQueueConfig) *blocksQueue {
 		blocksFetcher:          blocksFetcher,
 		chain:                  cfg.chain,
 		mode:                   cfg.mode,
+		skeleton:               cfg.skeleton,
+		lookahead:              lookaheadSteps,
 		fetchedData:            make(chan *blocksQueueFetchedData, 1),
 		quit:                   make(chan struct{}),
 	}
 
+	// Blocks checked against a skeleton may be fetched from more peers at once.
+	if queue.skeleton != nil {
+		queue.lookahead = skeletonLookaheadSteps
+	}
+
 	// Configure state machines.
 	queue.smm = newStateMachineManager()
 	queue.smm.addEventHandler(eventTick, stateNew, queue.onScheduleEvent(ctx))
@@ -165,7 +175,7 @@ func (q *blocksQueue) loop() {
 	// currentblock update?
 	startBlockNr := new(uint256.Int).AddUint64(q.chain.CurrentBlock().Number64(), 1)
 	blocksPerRequest := q.blocksFetcher.blocksPerPeriod
-	for i := startBlockNr.Clone(); i.Cmp(new(uint256.Int).AddUint64(startBlockNr, blocksPerRequest*lookaheadSteps)) == -1; i = i.AddUint64(i, blocksPerRequest) {
+	for i := startBlockNr.Clone(); i.Cmp(new(uint256.Int).AddUint64(startBlockNr, blocksPerRequest*uint64(q.lookahead))) == -1; i = i.AddUint64(i, blocksPerRequest) {
 		q.smm.addStateMachine(i)
 	}
 
@@ -216,7 +226,7 @@ func (q *blocksQueue) loop() {
 					if err := q.smm.removeStateMachine(fsm.start); err != nil {
 						log.Debug("Can not remove state machine", "err", err)
 					}
-					if len(q.smm.machines) < lookaheadSteps {
+					if len(q.smm.machines) < q.lookahead {
 						q.smm.addStateMachine(new(uint256.Int).AddUint64(highestStartSlot, blocksPerRequest))
 					}
 				}
@@ -275,6 +285,10 @@ func (q *blocksQueue) onScheduleEvent(ctx context.Context) eventHandlerFn {
 		if q.highestExpectedBlockNr.Cmp(new(uint256.Int).AddUint64(m.start, blocksPerRequest)) < 0 {
 			blocksPerRequest = new(uint256.Int).Sub(q.highestExpectedBlockNr, m.start).Uint64() + 1
 		}
+		// Wait for the skeleton to reach the blocks, rather than fetching them unchecked.
+		if q.skeleton != nil && q.skeleton.pending(m.start.Uint64()+blocksPerRequest-1) {
+			return m.state, nil
+		}
 		if err := q.blocksFetcher.scheduleRequest(ctx, m.start, blocksPerRequest); err != nil {
 			return m.state, err
 		}
@@ -312,6 +326,13 @@ func (q *blocksQueue) onDataReceivedEvent(ctx context.Context) eventHandlerFn {
 			}
 			return m.state, response.err
 		}
+		if q.skeleton != nil {
+			if err := q.skeleton.verify(response.blocks); err != nil {
+				q.blocksFetcher.p2p.Peers().Scorers().BadResponsesScorer().Increment(response.pid)
+				log.Debug("Peer is penalized for blocks off the skeleton", "pid", response.pid, "err", err)
+				return m.state, err
+			}
+		}
 		m.pid = response.pid
 		m.blocks = response.blocks
 		return stateDataParsed, nil
@@ -34,7 +34,7 @@ func (q *blocksQueue) resetFromFork(fork *forkData) error {
 
 	// The rest of machines are in skipped state.
 	startBlockNr := new(uint256.Int).AddUint64(firstBlockNr, uint64(len(fork.blocks)))
-	for i := startBlockNr.Clone(); i.Cmp(new(uint256.Int).AddUint64(startBlockNr, blocksPerRequest*(lookaheadSteps-1))) == -1; i.AddUint64(i, blocksPerRequest) {
+	for i := startBlockNr.Clone(); i.Cmp(new(uint256.Int).AddUint64(startBlockNr, blocksPerRequest*uint64(q.lookahead-1))) == -1; i.AddUint64(i, blocksPerRequest) {
 
 		fsm := q.smm.addStateMachine(i)
 		fsm.state = stateSkipped
@@ -49,7 +49,7 @@ func (q *blocksQueue) resetFromBlockNr(ctx context.Context, startBlockNr *uint25
 	if err := q.smm.removeAllStateMachines(); err != nil {
 		return err
 	}
-	for i := startBlockNr.Clone(); i.Cmp(new(uint256.Int).AddUint64(startBlockNr, blocksPerRequest*(lookaheadSteps-1))) == -1; i.AddUint64(i, blocksPerRequest) {
+	for i := startBlockNr.Clone(); i.Cmp(new(uint256.Int).AddUint64(startBlockNr, blocksPerRequest*uint64(q.lookahead-1))) == -1; i.AddUint64(i, blocksPerRequest) {
 		q.smm.addStateMachine(i)
 	}
 
@@ -0,0 +1,26 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package initialsync
+
+import "github.com/n42blockchain/N42/internal/metrics/prometheus"
+
+var (
+	// syncHighestBlockGauge is the block number initial sync is syncing to.
+	syncHighestBlockGauge = prometheus.GetOrCreateGauge("sync_highest_block")
+	// syncingGauge is 1 while initial sync is running and 0 otherwise.
+	syncingGauge = prometheus.GetOrCreateGauge("sync_syncing")
+)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/paulbellamy/ratecounter"
+	"go.opencensus.io/trace"
 )
 
 const (
@@ -29,9 +30,14 @@ type batchBlockReceiverFn func(chain []block2.IBlock) (int, error)
 func (s *Service) roundRobinSync(highestExpectedBlockNr *uint256.Int) error {
 	ctx, cancel := context.WithCancel(s.ctx)
 	defer cancel()
+	ctx, span := trace.StartSpan(ctx, "initialsync.roundRobinSync")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("highestExpectedBlockNr", int64(highestExpectedBlockNr.Uint64()))) // lint:ignore uintcast -- This conversion is OK for tracing.
 
 	s.counter = ratecounter.NewRateCounter(counterSeconds * time.Second)
 	s.highestExpectedBlockNr = highestExpectedBlockNr.Clone()
+	syncHighestBlockGauge.Set(float64(highestExpectedBlockNr.Uint64()))
+	syncingGauge.Set(1)
 	// Step 1 - Sync to end of finalized BlockNr.
 	if err := s.syncToFinalizedBlockNr(ctx, highestExpectedBlockNr); err != nil {
 		return err
@@ -47,11 +53,19 @@ func (s *Service) syncToFinalizedBlockNr(ctx context.Context, highestExpectedBlo
 		log.Debug("Already synced to finalized block number")
 		return nil
 	}
+	// Fetched blocks are checked against a skeleton several peers agree on,
+	// so a single peer cannot feed blocks off the canonical chain.
+	skeletonCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	skeleton := newHeaderSkeleton(s.cfg.Chain, s.cfg.P2P)
+	go skeleton.run(skeletonCtx)
+
 	queue := newBlocksQueue(ctx, &blocksQueueConfig{
 		p2p:                    s.cfg.P2P,
 		chain:                  s.cfg.Chain,
 		highestExpectedBlockNr: highestExpectedBlockNr,
 		mode:                   modeStopOnFinalizedEpoch,
+		skeleton:               skeleton,
 	})
 	if err := queue.start(); err != nil {
 		return err
@@ -73,6 +87,9 @@ func (s *Service) syncToFinalizedBlockNr(ctx context.Context, highestExpectedBlo
 func (s *Service) processFetchedData(ctx context.Context, startBlockNr *uint256.Int, data *blocksQueueFetchedData) {
 	defer s.updatePeerScorerStats(data.pid, startBlockNr)
 
+	if !s.waitResumed(ctx) {
+		return
+	}
 	// Use Batch Block Verify to process and verify batches directly.
 	if _, err := s.processBatchedBlocks(ctx, data.blocks, s.cfg.Chain.InsertChain); err != nil {
 		log.Warn("Skip processing batched blocks", "err", err)
@@ -80,6 +97,10 @@ func (s *Service) processFetchedData(ctx context.Context, startBlockNr *uint256.
 }
 
 func (s *Service) processBatchedBlocks(ctx context.Context, blks []*types_pb.Block, bFunc batchBlockReceiverFn) (int, error) {
+	_, span := trace.StartSpan(ctx, "initialsync.processBatchedBlocks")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("blocks", int64(len(blks))))
+
 	if len(blks) == 0 {
 		return 0, errors.New("0 blocks provided into method")
 	}
@@ -108,7 +129,11 @@ func (s *Service) processBatchedBlocks(ctx context.Context, blks []*types_pb.Blo
 
 	s.logBatchSyncStatus(blks)
 
-	return bFunc(blocks)
+	n, err := bFunc(blocks)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: err.Error()})
+	}
+	return n, err
 }
 
 // updatePeerScorerStats adjusts monitored metrics for a peer.
@@ -11,6 +11,7 @@ import (
 	"github.com/n42blockchain/N42/common"
 	"github.com/n42blockchain/N42/internal/p2p"
 	event "github.com/n42blockchain/N42/modules/event/v2"
+	"github.com/n42blockchain/N42/params"
 	"github.com/paulbellamy/ratecounter"
 	"sync/atomic"
 	"time"
@@ -22,6 +23,12 @@ import (
 type Config struct {
 	P2P   p2p.P2P
 	Chain common.IBlockChain
+	// SnapSync downloads the state of a recent block from peers on an empty
+	// chain instead of executing every block.
+	SnapSync bool
+	// Checkpoint makes an empty chain snap sync from a chain containing the
+	// trusted block, at or above it.
+	Checkpoint *params.SyncCheckpoint
 }
 
 // Service service.
@@ -31,8 +38,11 @@ type Service struct {
 	cancel                 context.CancelFunc
 	synced                 atomic.Bool
 	syncing                atomic.Bool
+	paused                 atomic.Bool
 	counter                *ratecounter.RateCounter
 	highestExpectedBlockNr *uint256.Int
+	startingBlock          atomic.Uint64
+	startTime              atomic.Int64
 }
 
 // NewService configures the initial sync service responsible for bringing the node up to the
@@ -57,6 +67,15 @@ func (s *Service) Start() {
 
 	log.Info("Starting initial chain sync...")
 	highestExpectedBlockNr := s.waitForMinimumPeers()
+	s.markStarted()
+	if s.cfg.SnapSync || s.cfg.Checkpoint != nil {
+		if err := s.snapSync(highestExpectedBlockNr); err != nil {
+			if errors.Is(s.ctx.Err(), context.Canceled) {
+				return
+			}
+			panic(err)
+		}
+	}
 	if err := s.roundRobinSync(highestExpectedBlockNr); err != nil {
 		if errors.Is(s.ctx.Err(), context.Canceled) {
 			return
@@ -74,6 +93,37 @@ func (s *Service) Stop() error {
 	return nil
 }
 
+// Pause holds initial sync before the next batch of blocks is imported until
+// Resume is called.
+func (s *Service) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume lets initial sync continue after Pause.
+func (s *Service) Resume() {
+	s.paused.Store(false)
+}
+
+// waitResumed blocks while initial sync is paused. It returns false if ctx
+// is done first.
+func (s *Service) waitResumed(ctx context.Context) bool {
+	if !s.paused.Load() {
+		return true
+	}
+	log.Info("Initial sync paused")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for s.paused.Load() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	log.Info("Initial sync resumed")
+	return true
+}
+
 // Status of initial sync.
 func (s *Service) Status() error {
 	if s.syncing.Load() == true {
@@ -92,6 +142,16 @@ func (s *Service) Synced() bool {
 	return s.synced.Load()
 }
 
+// Progress returns the block the running or last sync started from, and when
+// it started, the zero time if it did not yet.
+func (s *Service) Progress() (startingBlock uint64, started time.Time) {
+	start := s.startTime.Load()
+	if start == 0 {
+		return 0, time.Time{}
+	}
+	return s.startingBlock.Load(), time.Unix(0, start)
+}
+
 // Resync allows a node to start syncing again if it has fallen
 // behind the current network head.
 func (s *Service) Resync() error {
@@ -105,6 +165,7 @@ func (s *Service) Resync() error {
 	//
 	beforeBlockNr := s.cfg.Chain.CurrentBlock().Number64()
 	highestExpectedBlockNr := s.waitForMinimumPeers()
+	s.markStarted()
 	if err := s.roundRobinSync(highestExpectedBlockNr); err != nil {
 		log.Error("Resync fail", "err", err, "highestExpectedBlockNr", highestExpectedBlockNr, "currentNr", s.cfg.Chain.CurrentBlock().Number64(), "beforeResyncBlockNr", beforeBlockNr)
 		return err
@@ -129,6 +190,12 @@ func (s *Service) waitForMinimumPeers() (highestExpectedBlockNr *uint256.Int) {
 	return
 }
 
+// markStarted records the block and time the sync starts from.
+func (s *Service) markStarted() {
+	s.startingBlock.Store(s.cfg.Chain.CurrentBlock().Number64().Uint64())
+	s.startTime.Store(time.Now().UnixNano())
+}
+
 // markSynced marks node as synced and notifies feed listeners.
 func (s *Service) markSyncing() {
 	s.syncing.Swap(true)
@@ -138,4 +205,5 @@ func (s *Service) markSyncing() {
 func (s *Service) markSynced() {
 	s.syncing.Swap(false)
 	s.synced.Swap(true)
+	syncingGauge.Set(0)
 }
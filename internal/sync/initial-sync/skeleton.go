@@ -0,0 +1,303 @@
+package initialsync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/n42blockchain/N42/api/protocol/sync_pb"
+	"github.com/n42blockchain/N42/api/protocol/types_pb"
+	"github.com/n42blockchain/N42/common"
+	block2 "github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/p2p"
+	astsync "github.com/n42blockchain/N42/internal/sync"
+	"github.com/n42blockchain/N42/utils"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// skeletonSegment is the number of headers from one anchor of the skeleton
+	// to the next.
+	skeletonSegment = 192
+	// skeletonAnchors is the most anchors requested at once.
+	skeletonAnchors = 32
+	// skeletonPeers is the number of peers that must agree on the anchors.
+	skeletonPeers = 2
+	// skeletonFillers is the most segments filled in concurrently.
+	skeletonFillers = 8
+	// skeletonTipDistance is how far below the head of the peers the skeleton
+	// ends. Blocks above it may still be reorganised, they are fetched without
+	// being checked against the skeleton.
+	skeletonTipDistance = 64
+	// skeletonAhead is how far ahead of the chain the skeleton grows.
+	skeletonAhead = 4 * skeletonAnchors * skeletonSegment
+	// skeletonMaxMismatches is how many fetched block ranges may not match
+	// the skeleton before it is dropped and fetched again.
+	skeletonMaxMismatches = 3
+	// skeletonLookaheadSteps is the number of block ranges fetched in parallel
+	// while they are checked against the skeleton.
+	skeletonLookaheadSteps = 8
+)
+
+var errSkeletonMismatch = errors.New("blocks do not match the header skeleton")
+
+// headerSkeleton holds the hashes of the canonical headers ahead of the chain,
+// so that blocks fetched from any peer can be checked before being processed.
+// Anchors every skeletonSegment headers are fetched from several peers which
+// must agree on them, then the segments between them are filled in from
+// different peers concurrently, each one checked to link its anchors.
+type headerSkeleton struct {
+	sync.RWMutex
+	chain      common.IBlockChain
+	p2p        p2p.P2P
+	hashes     map[uint64]types.Hash
+	last       uint64 // number of the last header of the skeleton
+	target     uint64 // head of the peers the skeleton last grew towards, 0 if it could not
+	mismatches int
+}
+
+// newHeaderSkeleton creates an empty skeleton.
+func newHeaderSkeleton(chain common.IBlockChain, p2p p2p.P2P) *headerSkeleton {
+	return &headerSkeleton{
+		chain:  chain,
+		p2p:    p2p,
+		hashes: make(map[uint64]types.Hash),
+	}
+}
+
+// run grows the skeleton ahead of the chain until ctx is done.
+func (sk *headerSkeleton) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		grown, err := sk.grow(ctx)
+		if err != nil {
+			log.Debug("Could not grow header skeleton", "err", err)
+			// Blocks are not held back while peers cannot serve the skeleton.
+			sk.Lock()
+			sk.target = 0
+			sk.Unlock()
+		}
+		if grown {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// grow extends the skeleton by up to skeletonAnchors segments, it reports
+// false if the skeleton is far enough ahead or close to the head of the peers.
+func (sk *headerSkeleton) grow(ctx context.Context) (bool, error) {
+	from, fromHash := sk.tail()
+	if from > sk.chain.CurrentBlock().Number64().Uint64()+skeletonAhead {
+		return false, nil
+	}
+	target, peers := sk.p2p.Peers().BestPeers(sk.p2p.GetConfig().MinSyncPeers, uint256.NewInt(from))
+	if len(peers) == 0 || target.Uint64() < from+skeletonTipDistance+skeletonSegment {
+		return false, nil
+	}
+	count := min((target.Uint64()-skeletonTipDistance-from)/skeletonSegment, skeletonAnchors)
+
+	anchors, err := sk.fetchAnchors(ctx, from, count, peers)
+	if err != nil {
+		return false, err
+	}
+	segments, err := sk.fill(ctx, from, fromHash, anchors, peers)
+	if err != nil {
+		return false, err
+	}
+
+	sk.Lock()
+	defer sk.Unlock()
+	// The skeleton was dropped or outpaced by the chain meanwhile.
+	if last := max(sk.last, sk.chain.CurrentBlock().Number64().Uint64()); last != from {
+		return true, nil
+	}
+	for i, segment := range segments {
+		for j, hash := range segment {
+			sk.hashes[from+uint64(i)*skeletonSegment+uint64(j)+1] = hash
+		}
+	}
+	sk.last = from + count*skeletonSegment
+	sk.target = target.Uint64()
+	log.Debug("Grew header skeleton", "from", from+1, "to", sk.last, "target", sk.target)
+	return true, nil
+}
+
+// tail returns the number and hash of the header the skeleton grows from, its
+// last header or the head of the chain once it caught up with it.
+func (sk *headerSkeleton) tail() (uint64, types.Hash) {
+	sk.Lock()
+	defer sk.Unlock()
+	head := sk.chain.CurrentBlock()
+	number := head.Number64().Uint64()
+	for n := range sk.hashes {
+		if n <= number {
+			delete(sk.hashes, n)
+		}
+	}
+	if sk.last > number {
+		return sk.last, sk.hashes[sk.last]
+	}
+	sk.last = 0
+	return number, head.Hash()
+}
+
+// fetchAnchors requests count anchors every skeletonSegment headers above
+// block from, until skeletonPeers peers return the same ones.
+func (sk *headerSkeleton) fetchAnchors(ctx context.Context, from, count uint64, peers []peer.ID) ([]types.Hash, error) {
+	req := &sync_pb.HeadersByRangeRequest{
+		StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(from + skeletonSegment)),
+		Count:            count,
+		Step:             skeletonSegment,
+	}
+	var (
+		anchors []types.Hash
+		agreed  int
+	)
+	for _, pid := range peers {
+		headers, err := astsync.SendHeadersByRangeRequest(ctx, sk.p2p, pid, req)
+		if err != nil || uint64(len(headers)) != count {
+			log.Debug("Could not request skeleton anchors", "peer", pid, "from", from, "count", count, "err", err)
+			continue
+		}
+		hashes := make([]types.Hash, len(headers))
+		for i, pb := range headers {
+			if hashes[i], err = headerHash(pb); err != nil {
+				sk.p2p.Peers().Scorers().BadResponsesScorer().Increment(pid)
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		if anchors == nil {
+			anchors = hashes
+		} else {
+			for i := range anchors {
+				if anchors[i] != hashes[i] {
+					return nil, fmt.Errorf("peers disagree on the skeleton anchor %d", from+uint64(i+1)*skeletonSegment)
+				}
+			}
+		}
+		if agreed++; agreed == min(len(peers), skeletonPeers) {
+			return anchors, nil
+		}
+	}
+	return nil, fmt.Errorf("%d of %d peers returned the skeleton anchors", agreed, min(len(peers), skeletonPeers))
+}
+
+// fill requests the headers of the segments between the anchors from the
+// peers concurrently, and returns their hashes.
+func (sk *headerSkeleton) fill(ctx context.Context, from uint64, fromHash types.Hash, anchors []types.Hash, peers []peer.ID) ([][]types.Hash, error) {
+	segments := make([][]types.Hash, len(anchors))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(skeletonFillers)
+	for i := range anchors {
+		parent := fromHash
+		if i > 0 {
+			parent = anchors[i-1]
+		}
+		start := from + uint64(i)*skeletonSegment + 1
+		g.Go(func() error {
+			req := &sync_pb.HeadersByRangeRequest{
+				StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(start)),
+				Count:            skeletonSegment,
+				Step:             1,
+			}
+			// Every segment starts with a different peer.
+			for j := range peers {
+				pid := peers[(i+j)%len(peers)]
+				headers, err := astsync.SendHeadersByRangeRequest(ctx, sk.p2p, pid, req)
+				if err == nil {
+					segments[i], err = linkHeaders(headers, parent, anchors[i])
+				}
+				if err == nil {
+					return nil
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				sk.p2p.Peers().Scorers().BadResponsesScorer().Increment(pid)
+				log.Debug("Could not fill skeleton segment", "peer", pid, "start", start, "err", err)
+			}
+			return fmt.Errorf("no peer filled the skeleton segment from block %d", start)
+		})
+	}
+	return segments, g.Wait()
+}
+
+// verify checks fetched blocks against the skeleton. Blocks it does not reach
+// pass unchecked. Once skeletonMaxMismatches ranges mismatched, the skeleton is
+// dropped in case it is the one that is wrong.
+func (sk *headerSkeleton) verify(blks []*types_pb.Block) error {
+	sk.Lock()
+	defer sk.Unlock()
+	for _, blk := range blks {
+		if blk.Header == nil || blk.Header.Number == nil {
+			return astsync.ErrInvalidFetchedData
+		}
+		number := utils.ConvertH256ToUint256Int(blk.Header.Number).Uint64()
+		expected, ok := sk.hashes[number]
+		if !ok {
+			continue
+		}
+		if hash, err := headerHash(blk.Header); err != nil || hash != expected {
+			if sk.mismatches++; sk.mismatches >= skeletonMaxMismatches {
+				log.Warn("Dropping header skeleton not matching fetched blocks", "number", number, "hash", hash, "expected", expected)
+				sk.hashes = make(map[uint64]types.Hash)
+				sk.last, sk.target, sk.mismatches = 0, 0, 0
+			}
+			return fmt.Errorf("%w at block %d", errSkeletonMismatch, number)
+		}
+	}
+	return nil
+}
+
+// pending reports whether the blocks up to end are to be checked against the
+// skeleton, which does not reach them yet.
+func (sk *headerSkeleton) pending(end uint64) bool {
+	sk.RLock()
+	defer sk.RUnlock()
+	return end > sk.last && end+skeletonTipDistance+skeletonSegment <= sk.target
+}
+
+// linkHeaders checks that headers form a chain from the child of parent to
+// anchor, and returns their hashes.
+func linkHeaders(headers []*types_pb.Header, parent, anchor types.Hash) ([]types.Hash, error) {
+	if len(headers) != skeletonSegment {
+		return nil, fmt.Errorf("expected %d headers, got %d", skeletonSegment, len(headers))
+	}
+	hashes := make([]types.Hash, len(headers))
+	for i, pb := range headers {
+		header := new(block2.Header)
+		if err := header.FromProtoMessage(pb); err != nil {
+			return nil, err
+		}
+		if header.ParentHash != parent {
+			return nil, fmt.Errorf("header %d is not a child of %x", header.Number.Uint64(), parent)
+		}
+		parent = header.Hash()
+		hashes[i] = parent
+	}
+	if parent != anchor {
+		return nil, fmt.Errorf("segment ends at %x instead of the anchor %x", parent, anchor)
+	}
+	return hashes, nil
+}
+
+// headerHash returns the hash of a header received from a peer.
+func headerHash(pb *types_pb.Header) (types.Hash, error) {
+	header := new(block2.Header)
+	if err := header.FromProtoMessage(pb); err != nil {
+		return types.Hash{}, err
+	}
+	return header.Hash(), nil
+}
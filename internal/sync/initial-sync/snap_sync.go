@@ -0,0 +1,607 @@
+package initialsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/api/protocol/sync_pb"
+	"github.com/n42blockchain/N42/api/protocol/types_pb"
+	"github.com/n42blockchain/N42/common/account"
+	block2 "github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/crypto"
+	"github.com/n42blockchain/N42/common/hash"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+	p2ptypes "github.com/n42blockchain/N42/internal/p2p/types"
+	astsync "github.com/n42blockchain/N42/internal/sync"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/modules/state"
+	"github.com/n42blockchain/N42/utils"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// snapPivotDistance is how far below the head of the peers the pivot block
+	// is, so that their state history still covers it.
+	snapPivotDistance = 128
+	// snapPivotPeers is the number of peers that must agree on the pivot block
+	// and on every range of its state.
+	snapPivotPeers = 3
+	// snapStateBatch is the number of state entries requested at once.
+	snapStateBatch = 4096
+	// snapBackfillBatch is the number of blocks below the pivot requested at
+	// once.
+	snapBackfillBatch = 256
+	// snapMaxAttempts is how many rounds of requests to all peers fail before
+	// a new pivot block is picked.
+	snapMaxAttempts = 3
+	// snapLogInterval is the interval progress is logged at.
+	snapLogInterval = 30 * time.Second
+)
+
+var errSnapPivotStale = errors.New("peers do not serve the state of the pivot block")
+
+// snapTable is a table of the state downloaded by snap sync.
+type snapTable struct {
+	id   uint8
+	name string
+}
+
+// snapTables lists the tables of the state in the order they are downloaded.
+// The unpaid rewards only match the pivot block until the next rewards are
+// paid out, they come first while the pivot is recent.
+var snapTables = []snapTable{
+	{p2ptypes.StateTableRewards, modules.Reward},
+	{p2ptypes.StateTableIncarnations, modules.IncarnationMap},
+	{p2ptypes.StateTableDeposits, modules.Deposit},
+	{p2ptypes.StateTableAccounts, modules.Account},
+}
+
+// snapSync downloads the state as of a recent pivot block from peers instead
+// of executing every block from genesis, and the blocks below the pivot
+// without executing them. The blocks after the pivot are imported by
+// roundRobinSync as usual. It only runs on an empty chain, or to complete a
+// snap sync that was interrupted. With a checkpoint, the pivot is at or above
+// it and the blocks down to it are downloaded first, the pivot being rejected
+// unless they lead to the checkpoint.
+func (s *Service) snapSync(highestExpectedBlockNr *uint256.Int) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	db := s.cfg.Chain.DB()
+	var (
+		number uint64
+		hash   types.Hash
+		ok     bool
+	)
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		number, hash, ok, err = rawdb.ReadSnapPivot(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	if !ok && s.cfg.Chain.CurrentBlock().Number64().Uint64() != 0 {
+		return nil
+	}
+	if !ok && s.cfg.Checkpoint == nil && highestExpectedBlockNr.Uint64() <= 2*snapPivotDistance {
+		return nil
+	}
+
+	fetcher := newBlocksFetcher(ctx, &blocksFetcherConfig{
+		chain: s.cfg.Chain,
+		p2p:   s.cfg.P2P,
+	})
+	if err := fetcher.start(); err != nil {
+		return err
+	}
+	defer fetcher.stop()
+
+	for {
+		if !ok {
+			var err error
+			if number, hash, err = s.pickSnapPivot(ctx, fetcher); err != nil {
+				return err
+			}
+			ok = true
+		}
+		if cp := s.cfg.Checkpoint; cp != nil {
+			linked, err := s.linkSnapCheckpoint(ctx, fetcher, number)
+			if err != nil {
+				return err
+			}
+			if !linked {
+				log.Error("Chain of the peers does not contain the checkpoint, picking a new pivot", "pivot", number, "hash", hash, "checkpoint", cp.Number, "checkpointHash", cp.Hash)
+				if err := s.resetSnapSync(ctx); err != nil {
+					return err
+				}
+				ok = false
+				time.Sleep(handshakePollingInterval)
+				continue
+			}
+		}
+		log.Info("Snap syncing state", "pivot", number, "hash", hash)
+		err := s.downloadSnapState(ctx, number, hash)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errSnapPivotStale) {
+			return err
+		}
+		log.Warn("Pivot block went stale, picking a new one", "pivot", number, "hash", hash)
+		if err := s.resetSnapSync(ctx); err != nil {
+			return err
+		}
+		ok = false
+	}
+	if err := s.backfillSnapBlocks(ctx, fetcher, number, 1); err != nil {
+		return err
+	}
+	return s.commitSnapSync(ctx, number, hash)
+}
+
+// pickSnapPivot stores the block snapPivotDistance below the head of the
+// peers, or the last block paying out rewards or the checkpoint if later, as
+// the pivot block once enough peers agree on it.
+func (s *Service) pickSnapPivot(ctx context.Context, fetcher *blocksFetcher) (uint64, types.Hash, error) {
+	for {
+		if ctx.Err() != nil {
+			return 0, types.Hash{}, ctx.Err()
+		}
+		target, peers := s.cfg.P2P.Peers().BestPeers(s.cfg.P2P.GetConfig().MinSyncPeers, s.cfg.Chain.CurrentBlock().Number64())
+		if len(peers) == 0 || target.Uint64() <= snapPivotDistance {
+			time.Sleep(handshakePollingInterval)
+			continue
+		}
+		number := target.Uint64() - snapPivotDistance
+		if last, ok := astsync.LastRewardBlock(s.cfg.Chain.Config(), target.Uint64()); ok && last > number {
+			number = last
+		}
+		if cp := s.cfg.Checkpoint; cp != nil && cp.Number > number {
+			if cp.Number > target.Uint64() {
+				log.Info("Waiting for peers to reach the checkpoint", "checkpoint", cp.Number, "target", target.Uint64())
+				time.Sleep(handshakePollingInterval)
+				continue
+			}
+			number = cp.Number
+		}
+
+		var pivot *block2.Block
+		req := &sync_pb.BodiesByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(number)),
+			Count:            1,
+			Step:             1,
+		}
+		agreed := 0
+		for _, pid := range peers[:min(len(peers), snapPivotPeers)] {
+			blks, err := fetcher.requestBlocks(ctx, req, pid)
+			if err != nil || len(blks) != 1 {
+				log.Debug("Could not request pivot block", "peer", pid, "number", number, "err", err)
+				continue
+			}
+			b := new(block2.Block)
+			if err := b.FromProtoMessage(blks[0]); err != nil || b.Number64().Uint64() != number {
+				s.cfg.P2P.Peers().Scorers().BadResponsesScorer().Increment(pid)
+				continue
+			}
+			if pivot != nil && pivot.Hash() != b.Hash() {
+				log.Warn("Peers disagree on the pivot block", "number", number, "hash", pivot.Hash(), "peer", pid, "peerHash", b.Hash())
+				agreed = 0
+				break
+			}
+			pivot = b
+			agreed++
+		}
+		if agreed == 0 || agreed < min(len(peers), snapPivotPeers) {
+			time.Sleep(handshakePollingInterval)
+			continue
+		}
+
+		if err := s.cfg.Chain.DB().Update(ctx, func(tx kv.RwTx) error {
+			for _, table := range rawdb.SnapStateTables {
+				if err := tx.ClearBucket(table); err != nil {
+					return err
+				}
+			}
+			if err := rawdb.WriteSnapBlock(tx, pivot); err != nil {
+				return err
+			}
+			return rawdb.WriteSnapPivot(tx, number, pivot.Hash())
+		}); err != nil {
+			return 0, types.Hash{}, err
+		}
+		return number, pivot.Hash(), nil
+	}
+}
+
+// resetSnapSync deletes the state and blocks downloaded for a stale pivot.
+func (s *Service) resetSnapSync(ctx context.Context) error {
+	return s.cfg.Chain.DB().Update(ctx, func(tx kv.RwTx) error {
+		for _, table := range rawdb.SnapStateTables {
+			if err := tx.ClearBucket(table); err != nil {
+				return err
+			}
+		}
+		if err := rawdb.TruncateCanonicalHash(tx, 1, false); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateBlocks(ctx, tx, 1); err != nil {
+			return err
+		}
+		return rawdb.DeleteSnapSync(tx)
+	})
+}
+
+// downloadSnapState downloads every table of the state as of the pivot block,
+// resuming where an interrupted download stopped.
+func (s *Service) downloadSnapState(ctx context.Context, number uint64, hash types.Hash) error {
+	db := s.cfg.Chain.DB()
+	for _, table := range snapTables {
+		var (
+			origin []byte
+			done   bool
+		)
+		if err := db.View(ctx, func(tx kv.Tx) (err error) {
+			origin, done, err = rawdb.ReadSnapProgress(tx, table.name)
+			return err
+		}); err != nil {
+			return err
+		}
+
+		var (
+			entries int
+			start   = time.Now()
+			logged  = time.Now()
+		)
+		for !done {
+			batch, err := s.requestSnapState(ctx, &p2ptypes.StateRangeRequest{
+				Number: number,
+				Hash:   hash,
+				Table:  table.id,
+				Count:  snapStateBatch,
+				Origin: origin,
+			})
+			if err != nil {
+				return err
+			}
+			// Peers only return fewer entries than asked for at the end of
+			// the table, which the agreeing peers all returned.
+			done = len(batch) < snapStateBatch
+			if len(batch) > 0 {
+				origin = append(bytes.Clone(batch[len(batch)-1].Key), 0)
+			}
+			if err := db.Update(ctx, func(tx kv.RwTx) error {
+				if err := writeSnapEntries(tx, table.id, batch); err != nil {
+					return err
+				}
+				return rawdb.WriteSnapProgress(tx, table.name, origin, done)
+			}); err != nil {
+				return err
+			}
+			entries += len(batch)
+			if time.Since(logged) > snapLogInterval {
+				log.Info("Snap syncing state", "table", table.name, "entries", entries, "origin", fmt.Sprintf("%x", origin), "elapsed", time.Since(start))
+				logged = time.Now()
+			}
+		}
+		log.Info("Snap synced state table", "table", table.name, "entries", entries, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// requestSnapState requests a range of the state from the peers, and returns
+// it once snapPivotPeers of them, or all of them if fewer, served the same
+// entries. The state root of a block only covers the accounts it changes, so
+// the state of the pivot cannot be checked against it and a single peer is
+// not trusted with it, nor with the end of a table. It fails with
+// errSnapPivotStale once the peers do not serve the pivot block anymore.
+func (s *Service) requestSnapState(ctx context.Context, req *p2ptypes.StateRangeRequest) ([]*p2ptypes.StateEntry, error) {
+	for attempt := 0; attempt < snapMaxAttempts; attempt++ {
+		_, peers := s.cfg.P2P.Peers().BestPeers(s.cfg.P2P.GetConfig().MinSyncPeers, uint256.NewInt(req.Number))
+		var (
+			need    = min(len(peers), snapPivotPeers)
+			agreed  []*p2ptypes.StateEntry
+			digest  types.Hash
+			matches int
+		)
+		for _, pid := range peers {
+			entries, err := astsync.SendStateByRangeRequest(ctx, s.cfg.P2P, pid, req)
+			if err != nil {
+				if errors.Is(err, astsync.ErrInvalidFetchedData) {
+					s.cfg.P2P.Peers().Scorers().BadResponsesScorer().Increment(pid)
+				}
+				log.Debug("Could not request state range", "peer", pid, "table", req.Table, "err", err)
+				continue
+			}
+			d := snapDigest(entries)
+			if matches > 0 && d != digest {
+				log.Warn("Peers disagree on a range of the state", "table", req.Table, "origin", fmt.Sprintf("%x", req.Origin), "peer", pid)
+				matches = 0
+				break
+			}
+			agreed, digest = entries, d
+			if matches++; matches >= need {
+				return agreed, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(handshakePollingInterval):
+		}
+	}
+	return nil, errSnapPivotStale
+}
+
+// snapDigest returns a hash of the keys and values of entries, which peers
+// serving the same range of the state agree on.
+func snapDigest(entries []*p2ptypes.StateEntry) types.Hash {
+	var (
+		hasher = crypto.NewKeccakState()
+		size   [4]byte
+		digest types.Hash
+	)
+	for _, entry := range entries {
+		binary.BigEndian.PutUint32(size[:], uint32(len(entry.Key)))
+		hasher.Write(size[:])
+		hasher.Write(entry.Key)
+		binary.BigEndian.PutUint32(size[:], uint32(len(entry.Value)))
+		hasher.Write(size[:])
+		hasher.Write(entry.Value)
+	}
+	hasher.Read(digest[:])
+	return digest
+}
+
+// writeSnapEntries stores downloaded entries of a table of the state.
+func writeSnapEntries(tx kv.RwTx, table uint8, entries []*p2ptypes.StateEntry) error {
+	var name string
+	switch table {
+	case p2ptypes.StateTableAccounts:
+		return writeSnapAccounts(tx, entries)
+	case p2ptypes.StateTableIncarnations:
+		name = modules.IncarnationMap
+	case p2ptypes.StateTableRewards:
+		name = modules.Reward
+	case p2ptypes.StateTableDeposits:
+		name = modules.Deposit
+	default:
+		return fmt.Errorf("unknown state table %d", table)
+	}
+	for _, entry := range entries {
+		if err := tx.Put(name, entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSnapAccounts stores downloaded accounts with their code and storage.
+func writeSnapAccounts(tx kv.RwTx, entries []*p2ptypes.StateEntry) error {
+	var (
+		w      = state.NewPlainStateWriterNoHistory(tx)
+		reader = state.NewPlainStateReader(tx)
+		prefix = types.AddressLength + 2
+	)
+	for _, entry := range entries {
+		addr := types.BytesToAddress(entry.Key[:min(len(entry.Key), types.AddressLength)])
+		switch len(entry.Key) {
+		case types.AddressLength:
+			var acc account.StateAccount
+			if err := acc.Unmarshal(entry.Value); err != nil {
+				return fmt.Errorf("invalid account %x: %w", addr, err)
+			}
+			if err := w.UpdateAccountData(addr, nil, &acc); err != nil {
+				return err
+			}
+		case prefix:
+			incarnation := binary.BigEndian.Uint16(entry.Key[types.AddressLength:])
+			codeHash := crypto.Keccak256Hash(entry.Value)
+			acc, err := reader.ReadAccountData(addr)
+			if err != nil {
+				return err
+			}
+			if acc == nil || acc.Incarnation != incarnation || acc.CodeHash != codeHash {
+				return fmt.Errorf("code of account %x does not match its code hash", addr)
+			}
+			if err := w.UpdateAccountCode(addr, incarnation, codeHash, entry.Value); err != nil {
+				return err
+			}
+		case prefix + types.HashLength:
+			incarnation := binary.BigEndian.Uint16(entry.Key[types.AddressLength:])
+			location := types.BytesToHash(entry.Key[prefix:])
+			if err := w.WriteAccountStorage(addr, incarnation, &location, new(uint256.Int), new(uint256.Int).SetBytes(entry.Value)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid state key %x", entry.Key)
+		}
+	}
+	return nil
+}
+
+// linkSnapCheckpoint downloads the blocks below the pivot block down to the
+// checkpoint, and reports whether the block at its height is the checkpoint.
+func (s *Service) linkSnapCheckpoint(ctx context.Context, fetcher *blocksFetcher, pivot uint64) (bool, error) {
+	cp := s.cfg.Checkpoint
+	if err := s.backfillSnapBlocks(ctx, fetcher, pivot, cp.Number); err != nil {
+		return false, err
+	}
+	var hash types.Hash
+	if err := s.cfg.Chain.DB().View(ctx, func(tx kv.Tx) (err error) {
+		hash, err = rawdb.ReadCanonicalHash(tx, cp.Number)
+		return err
+	}); err != nil {
+		return false, err
+	}
+	return hash == cp.Hash, nil
+}
+
+// backfillSnapBlocks downloads the blocks below the pivot block down to block
+// to, checking that each is the parent of the one above it.
+func (s *Service) backfillSnapBlocks(ctx context.Context, fetcher *blocksFetcher, pivot, to uint64) error {
+	db := s.cfg.Chain.DB()
+	low := pivot
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		number, ok, err := rawdb.ReadSnapBackfill(tx)
+		if ok {
+			low = number
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	start, logged := time.Now(), time.Now()
+	for low > to {
+		count := min(uint64(snapBackfillBatch), low-to)
+		first := low - count
+		var parent types.Hash
+		if err := db.View(ctx, func(tx kv.Tx) error {
+			h, err := rawdb.ReadCanonicalHash(tx, low)
+			if err != nil {
+				return err
+			}
+			header := rawdb.ReadHeader(tx, h, low)
+			if header == nil {
+				return fmt.Errorf("snap synced block %d not found", low)
+			}
+			parent = header.ParentHash
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		_, peers := s.cfg.P2P.Peers().BestPeers(s.cfg.P2P.GetConfig().MinSyncPeers, uint256.NewInt(first))
+		blks, pid, err := fetcher.fetchBlocksFromPeer(ctx, uint256.NewInt(first), count, peers)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Debug("Could not backfill blocks", "first", first, "count", count, "err", err)
+			time.Sleep(handshakePollingInterval)
+			continue
+		}
+		blocks, err := s.verifySnapBlocks(blks, first, count, parent)
+		if err != nil {
+			log.Debug("Invalid backfilled blocks", "peer", pid, "first", first, "err", err)
+			s.cfg.P2P.Peers().Scorers().BadResponsesScorer().Increment(pid)
+			continue
+		}
+		if err := db.Update(ctx, func(tx kv.RwTx) error {
+			for _, b := range blocks {
+				if err := rawdb.WriteSnapBlock(tx, b); err != nil {
+					return err
+				}
+			}
+			return rawdb.WriteSnapBackfill(tx, first)
+		}); err != nil {
+			return err
+		}
+		low = first
+		if time.Since(logged) > snapLogInterval {
+			log.Info("Snap syncing blocks below the pivot", "block", low, "pivot", pivot, "elapsed", time.Since(start))
+			logged = time.Now()
+		}
+	}
+	return nil
+}
+
+// verifySnapBlocks decodes count blocks from first on, checking that they
+// form a chain whose last block has hash parent, and that the first block is
+// a child of genesis if it is block 1.
+func (s *Service) verifySnapBlocks(blks []*types_pb.Block, first, count uint64, parent types.Hash) ([]*block2.Block, error) {
+	if uint64(len(blks)) != count {
+		return nil, fmt.Errorf("expected %d blocks, got %d", count, len(blks))
+	}
+	blocks := make([]*block2.Block, len(blks))
+	for i := len(blks) - 1; i >= 0; i-- {
+		b := new(block2.Block)
+		if err := b.FromProtoMessage(blks[i]); err != nil {
+			return nil, err
+		}
+		if b.Number64().Uint64() != first+uint64(i) {
+			return nil, fmt.Errorf("expected block %d, got %d", first+uint64(i), b.Number64().Uint64())
+		}
+		if b.Hash() != parent {
+			return nil, fmt.Errorf("block %d %x is not the parent %x", first+uint64(i), b.Hash(), parent)
+		}
+		if root := hash.DeriveSha(transaction.Transactions(b.Transactions())); root != b.TxHash() {
+			return nil, fmt.Errorf("transaction root hash mismatch of block %d: have %x, want %x", first+uint64(i), root, b.TxHash())
+		}
+		blocks[i] = b
+		parent = b.ParentHash()
+	}
+	if first == 1 && parent != s.cfg.Chain.GenesisBlock().Hash() {
+		return nil, fmt.Errorf("block 1 is not a child of genesis %x", s.cfg.Chain.GenesisBlock().Hash())
+	}
+	return blocks, nil
+}
+
+// commitSnapSync computes the total difficulty of the downloaded blocks, marks
+// the history and receipts below the pivot as missing, and makes the pivot the
+// head of the chain.
+func (s *Service) commitSnapSync(ctx context.Context, pivot uint64, pivotHash types.Hash) error {
+	db := s.cfg.Chain.DB()
+	genesis := s.cfg.Chain.GenesisBlock()
+	var td *uint256.Int
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		td, err = rawdb.ReadTd(tx, genesis.Hash(), 0)
+		return err
+	}); err != nil {
+		return err
+	}
+	if td == nil {
+		return fmt.Errorf("total difficulty of genesis not found")
+	}
+	for from := uint64(1); from <= pivot; from += snapBackfillBatch {
+		to := min(from+snapBackfillBatch-1, pivot)
+		if err := db.Update(ctx, func(tx kv.RwTx) error {
+			for number := from; number <= to; number++ {
+				header := rawdb.ReadHeaderByNumber(tx, number)
+				if header == nil {
+					return fmt.Errorf("snap synced block %d not found", number)
+				}
+				td = new(uint256.Int).Add(td, header.Difficulty)
+				if err := rawdb.WriteTd(tx, header.Hash(), number, td); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		// The history and receipts only exist from the blocks executed after
+		// the pivot on.
+		if err := rawdb.WritePruneProgress(tx, rawdb.PruneHistory, pivot+1); err != nil {
+			return err
+		}
+		if err := rawdb.WritePruneProgress(tx, rawdb.PruneReceipts, pivot+1); err != nil {
+			return err
+		}
+		// The head is stored along with removing the progress, so that an
+		// interrupted commit does not leave the node at genesis with the
+		// state of the pivot.
+		rawdb.WriteHeadBlockHash(tx, pivotHash)
+		if err := rawdb.WriteHeadHeaderHash(tx, pivotHash); err != nil {
+			return err
+		}
+		return rawdb.DeleteSnapSync(tx)
+	}); err != nil {
+		return err
+	}
+	if err := s.cfg.Chain.CommitSnapHead(pivotHash); err != nil {
+		return err
+	}
+	log.Info("Snap sync complete", "pivot", pivot, "hash", pivotHash)
+	return nil
+}
@@ -19,6 +19,9 @@ const defaultBurstLimit = 5
 
 const leakyBucketPeriod = 1 * time.Second
 
+// headersPerBlock is how many headers a peer may request for a block body.
+const headersPerBlock = 16
+
 // Dummy topic to validate all incoming rpc requests.
 const rpcLimiterTopic = "rpc-limiter-topic"
 
@@ -54,8 +57,11 @@ func newRateLimiter(p2pProvider p2p.P2P) *limiter {
 	// Bodies Message
 	topicMap[addEncoding(p2p.RPCBodiesDataTopicV1)] = leakybucket.NewCollector(allowedBlocksPerSecond, allowedBlocksBurst, blockLimiterPeriod, false /* deleteEmptyBuckets */)
 
-	// Headers Message
-	topicMap[addEncoding(p2p.RPCHeadersDataTopicV1)] = leakybucket.NewCollector(allowedBlocksPerSecond, allowedBlocksBurst, blockLimiterPeriod, false /* deleteEmptyBuckets */)
+	// Headers Message, much smaller than bodies.
+	topicMap[addEncoding(p2p.RPCHeadersDataTopicV1)] = leakybucket.NewCollector(allowedBlocksPerSecond*headersPerBlock, allowedBlocksBurst*headersPerBlock, blockLimiterPeriod, false /* deleteEmptyBuckets */)
+
+	// State Message
+	topicMap[addEncoding(p2p.RPCStateDataTopicV1)] = leakybucket.NewCollector(5, defaultBurstLimit*2, leakyBucketPeriod, false /* deleteEmptyBuckets */)
 
 	// General topic for all rpc requests.
 	topicMap[rpcLimiterTopic] = leakybucket.NewCollector(5, defaultBurstLimit*2, leakyBucketPeriod, false /* deleteEmptyBuckets */)
@@ -47,6 +47,14 @@ func (s *Service) registerRPCHandlers() {
 		p2p.RPCBodiesDataTopicV1,
 		s.bodiesByRangeRPCHandler,
 	)
+	s.registerRPC(
+		p2p.RPCHeadersDataTopicV1,
+		s.headersByRangeRPCHandler,
+	)
+	s.registerRPC(
+		p2p.RPCStateDataTopicV1,
+		s.stateByRangeRPCHandler,
+	)
 }
 
 // Remove all Stream handlers
@@ -55,11 +63,15 @@ func (s *Service) unregisterHandlers() {
 	fullStatusTopic := p2p.RPCStatusTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
 	fullGoodByeTopic := p2p.RPCGoodByeTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
 	fullPingTopic := p2p.RPCPingTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
+	fullHeadersRangeTopic := p2p.RPCHeadersDataTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
+	fullStateRangeTopic := p2p.RPCStateDataTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
 
 	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullBodiesRangeTopic))
 	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStatusTopic))
 	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullGoodByeTopic))
 	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullPingTopic))
+	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullHeadersRangeTopic))
+	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStateRangeTopic))
 }
 
 // registerRPC for a given topic with an expected protobuf message type.
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/api/protocol/sync_pb"
+	"github.com/n42blockchain/N42/api/protocol/types_pb"
+	p2ptypes "github.com/n42blockchain/N42/internal/p2p/types"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/utils"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// headersByRangeRPCHandler looks up the requested headers from the database, every
+// step-th one from a given start block. Unlike bodies, headers may be requested with
+// a step, which syncing peers use to fetch a skeleton of the chain.
+func (s *Service) headersByRangeRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.HeadersByRangeHandler")
+	defer span.End()
+	_, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.HeadersByRangeRequest)
+	if !ok {
+		return errors.New("message is not type *pb.HeadersByRangeRequest")
+	}
+	start := utils.ConvertH256ToUint256Int(m.StartBlockNumber)
+	if m.Count == 0 || m.Count > maxRequestBlocks || m.Step == 0 || m.Step > rangeLimit || !start.IsUint64() {
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrInvalidRequest.Error(), stream)
+		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+		return p2ptypes.ErrInvalidRequest
+	}
+	if err := s.rateLimiter.validateRequest(stream, m.Count); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, int64(m.Count))
+	span.AddAttributes(
+		trace.Int64Attribute("start", int64(start.Uint64())), // lint:ignore uintcast -- This conversion is OK for tracing.
+		trace.Int64Attribute("step", int64(m.Step)),
+		trace.Int64Attribute("count", int64(m.Count)),
+		trace.StringAttribute("peer", stream.Conn().RemotePeer().String()),
+	)
+
+	SetStreamWriteDeadline(stream, defaultWriteDuration)
+	head := s.cfg.chain.CurrentBlock().Number64().Uint64()
+	for i, number := uint64(0), start.Uint64(); i < m.Count && number <= head; i, number = i+1, number+m.Step {
+		header := s.cfg.chain.GetHeaderByNumber(uint256.NewInt(number))
+		if header == nil {
+			break
+		}
+		if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+			return err
+		}
+		if _, err := s.cfg.p2p.Encoding().EncodeWithMaxLength(stream, header.ToProtoMessage().(*types_pb.Header)); err != nil {
+			log.Debug("Could not send a chunked response", "err", err)
+			return err
+		}
+	}
+	closeStream(stream)
+	return nil
+}
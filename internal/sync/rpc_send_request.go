@@ -1,12 +1,14 @@
 package sync
 
 import (
+	"bytes"
 	"context"
 	"github.com/holiman/uint256"
 	"github.com/n42blockchain/N42/api/protocol/sync_pb"
 	"github.com/n42blockchain/N42/api/protocol/types_pb"
 	"github.com/n42blockchain/N42/common"
 	"github.com/n42blockchain/N42/internal/p2p"
+	p2ptypes "github.com/n42blockchain/N42/internal/p2p/types"
 	"github.com/n42blockchain/N42/utils"
 	"io"
 
@@ -88,3 +90,105 @@ func SendBodiesByRangeRequest(ctx context.Context, chain common.IBlockChain, p2p
 
 	return blocks, nil
 }
+
+// SendStateByRangeRequest sends a StateByRange request and returns the fetched
+// state entries, if any, in key order.
+func SendStateByRangeRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *p2ptypes.StateRangeRequest) ([]*p2ptypes.StateEntry, error) {
+	topic, err := p2p.TopicFromMessage(p2p.StateByRangeMessageName)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := p2pProvider.Send(ctx, req, topic, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream(stream)
+
+	entries := make([]*p2ptypes.StateEntry, 0, min(req.Count, stateRangeLimit))
+	for i := uint64(0); ; i++ {
+		var (
+			code   uint8
+			errMsg string
+		)
+		if i == 0 {
+			code, errMsg, err = ReadStatusCode(stream, p2pProvider.Encoding())
+		} else {
+			SetStreamReadDeadline(stream, respTimeout)
+			code, errMsg, err = readStatusCodeNoDeadline(stream, p2pProvider.Encoding())
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 {
+			return nil, errors.New(errMsg)
+		}
+		// The response MUST contain no more than `count` entries, in key order.
+		if i >= req.Count || i >= stateRangeLimit {
+			return nil, ErrInvalidFetchedData
+		}
+		entry := new(p2ptypes.StateEntry)
+		if err := p2pProvider.Encoding().DecodeWithMaxLength(stream, entry); err != nil {
+			return nil, err
+		}
+		if bytes.Compare(entry.Key, req.Origin) < 0 || (len(entries) > 0 && bytes.Compare(entry.Key, entries[len(entries)-1].Key) <= 0) {
+			return nil, ErrInvalidFetchedData
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// SendHeadersByRangeRequest sends a HeadersByRange request and returns the fetched
+// headers, if any, every step-th one from the start block.
+func SendHeadersByRangeRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.HeadersByRangeRequest) ([]*types_pb.Header, error) {
+	topic, err := p2p.TopicFromMessage(p2p.HeadersByRangeMessageName)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := p2pProvider.Send(ctx, req, topic, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream(stream)
+
+	start := utils.ConvertH256ToUint256Int(req.StartBlockNumber)
+	headers := make([]*types_pb.Header, 0, min(req.Count, maxRequestBlocks))
+	for i := uint64(0); ; i++ {
+		var (
+			code   uint8
+			errMsg string
+		)
+		if i == 0 {
+			code, errMsg, err = ReadStatusCode(stream, p2pProvider.Encoding())
+		} else {
+			SetStreamReadDeadline(stream, respTimeout)
+			code, errMsg, err = readStatusCodeNoDeadline(stream, p2pProvider.Encoding())
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 {
+			return nil, errors.New(errMsg)
+		}
+		// The response MUST contain no more than `count` headers.
+		if i >= req.Count || i >= maxRequestBlocks {
+			return nil, ErrInvalidFetchedData
+		}
+		header := new(types_pb.Header)
+		if err := p2pProvider.Encoding().DecodeWithMaxLength(stream, header); err != nil {
+			return nil, err
+		}
+		// Returned headers MUST be the ones at start + i * step.
+		if header.Number == nil || utils.ConvertH256ToUint256Int(header.Number).Cmp(new(uint256.Int).AddUint64(start, i*req.Step)) != 0 {
+			return nil, ErrInvalidFetchedData
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
@@ -0,0 +1,195 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	p2ptypes "github.com/n42blockchain/N42/internal/p2p/types"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/rawdb"
+	"github.com/n42blockchain/N42/modules/state"
+	"github.com/n42blockchain/N42/params"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// stateRangeLimit is the most state entries returned for a request.
+const stateRangeLimit = 4096
+
+// LastRewardBlock returns the last block up to number paying out rewards, ok
+// is false if rewards are not paid yet. The unpaid rewards of the Reward table
+// only change at these blocks.
+func LastRewardBlock(config *params.ChainConfig, number uint64) (last uint64, ok bool) {
+	if config.Apos == nil || config.Apos.RewardEpoch == 0 || !config.IsBeijing(number) {
+		return 0, false
+	}
+	return number - (number-config.BeijingBlock.Uint64())%config.Apos.RewardEpoch, true
+}
+
+// stateByRangeRPCHandler serves the entries of a table of the state as of a
+// recent block, in key order from the requested origin. Accounts are read
+// through the state history, the other tables keep no history and are served
+// as they are.
+func (s *Service) stateByRangeRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.StateByRangeHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*p2ptypes.StateRangeRequest)
+	if !ok {
+		return errors.New("message is not type *p2ptypes.StateRangeRequest")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+	if m.Count == 0 {
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrInvalidRequest.Error(), stream)
+		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+		return p2ptypes.ErrInvalidRequest
+	}
+	span.AddAttributes(
+		trace.Int64Attribute("number", int64(m.Number)), // lint:ignore uintcast -- This conversion is OK for tracing.
+		trace.Int64Attribute("table", int64(m.Table)),
+		trace.Int64Attribute("count", int64(m.Count)),
+		trace.StringAttribute("peer", stream.Conn().RemotePeer().String()),
+	)
+
+	var entries []*p2ptypes.StateEntry
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) (err error) {
+		entries, err = s.readStateRange(tx, m, min(m.Count, stateRangeLimit))
+		return err
+	}); err != nil {
+		log.Debug("Could not read state range", "peer", stream.Conn().RemotePeer(), "number", m.Number, "table", m.Table, "err", err)
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, err.Error(), stream)
+		return err
+	}
+	SetStreamWriteDeadline(stream, defaultWriteDuration)
+	for _, entry := range entries {
+		if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+			return err
+		}
+		if _, err := s.cfg.p2p.Encoding().EncodeWithMaxLength(stream, entry); err != nil {
+			log.Debug("Could not send a chunked response", "err", err)
+			return err
+		}
+	}
+	closeStream(stream)
+	return nil
+}
+
+// readStateRange reads up to count entries of the table of m as of its block,
+// starting at its origin.
+func (s *Service) readStateRange(tx kv.Tx, m *p2ptypes.StateRangeRequest, count uint64) ([]*p2ptypes.StateEntry, error) {
+	hash, err := rawdb.ReadCanonicalHash(tx, m.Number)
+	if err != nil {
+		return nil, err
+	}
+	if hash != types.Hash(m.Hash) {
+		return nil, fmt.Errorf("block %d %x is not canonical", m.Number, m.Hash)
+	}
+	// The state as of a block is the state at the beginning of the next one.
+	asOf := m.Number + 1
+	if err := rawdb.CheckPruned(tx, rawdb.PruneHistory, asOf); err != nil {
+		return nil, err
+	}
+
+	switch m.Table {
+	case p2ptypes.StateTableAccounts:
+		return readAccountsRange(tx, asOf, m.Origin, count)
+	case p2ptypes.StateTableIncarnations:
+		return readTableRange(tx, modules.IncarnationMap, m.Origin, count)
+	case p2ptypes.StateTableRewards:
+		// The rewards are the ones as of the block as long as none were
+		// paid out since.
+		head := s.cfg.chain.CurrentBlock().Number64().Uint64()
+		if last, ok := LastRewardBlock(s.cfg.chain.Config(), head); ok && last > m.Number {
+			return nil, fmt.Errorf("rewards were paid out at block %d after block %d", last, m.Number)
+		}
+		return readTableRange(tx, modules.Reward, m.Origin, count)
+	case p2ptypes.StateTableDeposits:
+		return readTableRange(tx, modules.Deposit, m.Origin, count)
+	}
+	return nil, fmt.Errorf("unknown state table %d", m.Table)
+}
+
+// readTableRange reads up to count entries of table starting at origin.
+func readTableRange(tx kv.Tx, table string, origin []byte, count uint64) ([]*p2ptypes.StateEntry, error) {
+	var entries []*p2ptypes.StateEntry
+	c, err := tx.Cursor(table)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	for k, v, err := c.Seek(origin); k != nil && uint64(len(entries)) < count; k, v, err = c.Next() {
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &p2ptypes.StateEntry{Key: bytes.Clone(k), Value: bytes.Clone(v)})
+	}
+	return entries, err
+}
+
+// readAccountsRange reads up to count entries of the accounts table as of
+// block asOf starting at origin: every account followed by its code and its
+// storage.
+func readAccountsRange(tx kv.Tx, asOf uint64, origin []byte, count uint64) ([]*p2ptypes.StateEntry, error) {
+	var (
+		entries []*p2ptypes.StateEntry
+		reader  = state.NewPlainState(tx, asOf)
+	)
+	add := func(key, value []byte) bool {
+		if bytes.Compare(key, origin) >= 0 {
+			entries = append(entries, &p2ptypes.StateEntry{Key: key, Value: value})
+		}
+		return uint64(len(entries)) < count
+	}
+	err := state.WalkAsOfAccounts(tx, types.BytesToAddress(origin[:min(len(origin), types.AddressLength)]), asOf, func(k, _ []byte) (bool, error) {
+		addr := types.BytesToAddress(k)
+		acc, err := reader.ReadAccountData(addr)
+		if err != nil || acc == nil {
+			return err == nil, err
+		}
+		data, err := acc.Marshal()
+		if err != nil {
+			return false, err
+		}
+		if !add(addr.Bytes(), data) {
+			return false, nil
+		}
+		if acc.Incarnation == 0 {
+			return true, nil
+		}
+		prefix := modules.PlainGenerateStoragePrefix(addr.Bytes(), acc.Incarnation)
+		if !acc.IsEmptyCodeHash() {
+			code, err := reader.ReadAccountCode(addr, acc.Incarnation, acc.CodeHash)
+			if err != nil {
+				return false, err
+			}
+			if !add(prefix, code) {
+				return false, nil
+			}
+		}
+		var start types.Hash
+		if bytes.HasPrefix(origin, prefix) {
+			start = types.BytesToHash(origin[len(prefix):min(len(origin), len(prefix)+types.HashLength)])
+		}
+		goOn := true
+		if err := state.WalkAsOfStorage(tx, addr, acc.Incarnation, start, asOf, func(_, loc, v []byte) (bool, error) {
+			goOn = add(modules.PlainGenerateCompositeStorageKey(addr.Bytes(), acc.Incarnation, loc), bytes.Clone(v))
+			return goOn, nil
+		}); err != nil {
+			return false, err
+		}
+		return goOn, nil
+	})
+	return entries, err
+}
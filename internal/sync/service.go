@@ -13,6 +13,7 @@ import (
 	"github.com/n42blockchain/N42/internal/p2p"
 	"github.com/n42blockchain/N42/utils"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
@@ -66,6 +67,7 @@ type Service struct {
 	cfg    *config
 	ctx    context.Context
 	cancel context.CancelFunc
+	paused atomic.Bool // drop gossiped blocks instead of importing them
 
 	subHandler  *subTopicHandler
 	rateLimiter *limiter
@@ -133,6 +135,17 @@ func (s *Service) Start() {
 	utils.RunEvery(s.ctx, syncMetricsInterval, s.updateMetrics)
 }
 
+// Pause stops importing gossiped blocks until Resume is called. The blocks
+// received meanwhile are dropped and fetched again by initial sync.
+func (s *Service) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume imports gossiped blocks again after Pause.
+func (s *Service) Resume() {
+	s.paused.Store(false)
+}
+
 // Stop the regular sync service.
 func (s *Service) Stop() error {
 	defer func() {
@@ -14,6 +14,11 @@ func (s *Service) blockSubscriber(ctx context.Context, msg proto.Message) error
 		return err
 	}
 
+	if s.paused.Load() {
+		log.Debug("Dropping gossiped block while sync is paused", "hash", iBlock.Header().Hash(), "blockNr", iBlock.Header().Number64().Uint64())
+		return nil
+	}
+
 	blocks := make([]block2.IBlock, 0)
 	blocks = append(blocks, iBlock)
 
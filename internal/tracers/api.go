@@ -59,6 +59,10 @@ const (
 	// will only be found every ~15K blocks or so.
 	defaultTracechainMemLimit = common.StorageSize(500 * 1024 * 1024)
 
+	// maxBlockTraceSize caps the combined size of the per-transaction results
+	// of a block trace, so a single request cannot exhaust the node's memory.
+	maxBlockTraceSize = common.StorageSize(256 * 1024 * 1024)
+
 	// maximumPendingTraceStates is the maximum number of states allowed waiting
 	// for tracing. The creation of trace state will be paused if the unused
 	// trace states exceed this limit.
@@ -630,10 +634,18 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 		blockCtx = core.NewEVMBlockContext(block.Header().(*types.Header), core.GetHashFn(block.Header().(*types.Header), api.chainContext(ctx).GetHeader), api.chainContext(ctx).Engine(), nil)
 		signer   = transaction.MakeSigner(api.backend.ChainConfig(), block.Number64().ToBig())
 		results  = make([]*txTraceResult, len(txs))
+		size     common.StorageSize
 	)
+	// Predecessors are executed once on the shared state; each transaction is
+	// traced on top of the state left behind by the previous one.
 	for i, tx := range txs {
-		// Generate the next state snapshot fast without tracing
-		msg, _ := tx.AsMessage(signer, block.BaseFee64())
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, err := tx.AsMessage(signer, block.BaseFee64())
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x: %w", tx.Hash(), err)
+		}
 		txctx := &Context{
 			BlockHash:   blockHash,
 			BlockNumber: block.Number64().ToBig(),
@@ -644,6 +656,11 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 		if err != nil {
 			return nil, err
 		}
+		if raw, ok := res.(json.RawMessage); ok {
+			if size += common.StorageSize(len(raw)); size > maxBlockTraceSize {
+				return nil, fmt.Errorf("block trace exceeds %v at transaction %d", maxBlockTraceSize, i)
+			}
+		}
 		results[i] = &txTraceResult{Result: res}
 		// Finalize the state so any modifications are written to the trie
 		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
@@ -893,6 +910,9 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if err != nil {
 		return nil, err
 	}
+	if msg == nil {
+		return nil, fmt.Errorf("transaction index %d out of range for block %#x", index, blockHash)
+	}
 	//defer release()
 
 	txctx := &Context{
@@ -956,8 +976,9 @@ func (api *API) TraceCall(ctx context.Context, args api.TransactionArgs, blockNr
 		}
 		config.BlockOverrides.Apply(&vmctx)
 	}
-	// Execute the trace
-	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee64().ToBig())
+	// Execute the trace. The message is priced against the possibly overridden
+	// base fee so fee checks match the simulated block.
+	msg, err := args.ToMessage(api.backend.RPCGasCap(), vmctx.BaseFee.ToBig())
 	if err != nil {
 		return nil, err
 	}
@@ -1026,6 +1047,10 @@ func APIs(backend Backend) []rpc.API {
 			Namespace: "debug",
 			Service:   NewAPI(backend),
 		},
+		{
+			Namespace: "trace",
+			Service:   NewTraceAPI(backend),
+		},
 	}
 }
 
@@ -23,6 +23,7 @@ import (
 	"github.com/holiman/uint256"
 	"github.com/n42blockchain/N42/internal/vm/evmtypes"
 	"math/big"
+	"time"
 
 	"github.com/dop251/goja"
 
@@ -37,6 +38,10 @@ import (
 
 const (
 	memoryPadLimit = 1024 * 1024
+
+	// setupTimeout bounds how long a user supplied tracer may spend being
+	// evaluated and running setup(), before the transaction timeout kicks in.
+	setupTimeout = 5 * time.Second
 )
 
 var assetTracers = make(map[string]string)
@@ -160,8 +165,20 @@ func newJsTracer(code string, ctx *tracers.Context, cfg json.RawMessage) (tracer
 		}
 	}
 
-	t.setTypeConverters()
+	if err := t.setTypeConverters(); err != nil {
+		return nil, err
+	}
 	t.setBuiltinFunctions()
+
+	// Tracer code is arbitrary user input; interrupt it if evaluating the
+	// object or its setup never returns.
+	watchdog := time.AfterFunc(setupTimeout, func() {
+		vm.Interrupt(fmt.Errorf("tracer setup timed out after %v", setupTimeout))
+	})
+	defer func() {
+		watchdog.Stop()
+		vm.ClearInterrupt()
+	}()
 	ret, err := vm.RunString("(" + code + ")")
 	if err != nil {
 		return nil, err
@@ -17,8 +17,8 @@
 package logger
 
 import (
+	"github.com/holiman/uint256"
 	"github.com/n42blockchain/N42/common/transaction"
-	"math/big"
 
 	common "github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/internal/vm"
@@ -132,7 +132,7 @@ func NewAccessListTracer(acl transaction.AccessList, from, to common.Address, pr
 	}
 }
 
-func (a *AccessListTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+func (a *AccessListTracer) CaptureStart(env vm.VMInterface, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *uint256.Int) {
 }
 
 // CaptureState captures all opcodes that touch storage or addresses and adds them to the accesslist.
@@ -163,7 +163,7 @@ func (*AccessListTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64,
 
 func (*AccessListTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {}
 
-func (*AccessListTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+func (*AccessListTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *uint256.Int) {
 }
 
 func (*AccessListTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
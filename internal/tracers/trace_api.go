@@ -0,0 +1,236 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	common "github.com/n42blockchain/N42/common/types"
+	rpc "github.com/n42blockchain/N42/modules/rpc/jsonrpc"
+)
+
+// maxTraceFilterRange is the maximum number of blocks a single trace_filter
+// request may re-execute.
+const maxTraceFilterRange = 1000
+
+var (
+	errTraceFilterRange  = fmt.Errorf("trace_filter range exceeds %d blocks", maxTraceFilterRange)
+	errInvalidTraceRange = errors.New("invalid trace_filter range: fromBlock is after toBlock")
+)
+
+// parityTraceConfig selects the flat call tracer with OpenEthereum error strings.
+var parityTraceConfig = json.RawMessage(`{"convertParityErrors":true}`)
+
+// TraceAPI implements the OpenEthereum style trace namespace. Traces are
+// produced by re-executing blocks with the flatCallTracer; block reward
+// traces are not reported.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new trace namespace service.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// TraceFilterArgs are the arguments of trace_filter. A trace matches when its
+// sender is in FromAddress and its recipient is in ToAddress, an empty list
+// matching any address.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"`
+	Count       *uint64          `json:"count"`
+}
+
+// parityTraceAddresses holds the fields of a flat trace used for filtering.
+type parityTraceAddresses struct {
+	Action struct {
+		From          *common.Address `json:"from"`
+		To            *common.Address `json:"to"`
+		Address       *common.Address `json:"address"`
+		RefundAddress *common.Address `json:"refundAddress"`
+	} `json:"action"`
+	Result *struct {
+		Address *common.Address `json:"address"`
+	} `json:"result"`
+}
+
+func (t *TraceAPI) traceConfig() *TraceConfig {
+	tracer := "flatCallTracer"
+	return &TraceConfig{Tracer: &tracer, TracerConfig: parityTraceConfig}
+}
+
+// Block returns the traces of all transactions in the given block.
+func (t *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]json.RawMessage, error) {
+	block, err := t.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if block.Number64().Uint64() == 0 {
+		return []json.RawMessage{}, nil
+	}
+	results, err := t.api.traceBlock(ctx, block, t.traceConfig())
+	if err != nil {
+		return nil, err
+	}
+	traces := make([]json.RawMessage, 0, len(results))
+	for _, result := range results {
+		flat, err := flatTraces(result.Result)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, flat...)
+	}
+	return traces, nil
+}
+
+// Transaction returns the traces of the given transaction.
+func (t *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]json.RawMessage, error) {
+	result, err := t.api.TraceTransaction(ctx, hash, t.traceConfig())
+	if err != nil {
+		return nil, err
+	}
+	return flatTraces(result)
+}
+
+// Filter returns the traces of the blocks in the requested range which match
+// the address filters, honouring the after and count pagination arguments.
+func (t *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]json.RawMessage, error) {
+	from, err := t.resolveNumber(ctx, args.FromBlock, rpc.EarliestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	to, err := t.resolveNumber(ctx, args.ToBlock, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, errInvalidTraceRange
+	}
+	if to-from >= maxTraceFilterRange {
+		return nil, errTraceFilterRange
+	}
+	var (
+		fromAddrs = addressSet(args.FromAddress)
+		toAddrs   = addressSet(args.ToAddress)
+		skip      uint64
+		traces    = []json.RawMessage{}
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for number := from; number <= to; number++ {
+		block, err := t.Block(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range block {
+			var addrs parityTraceAddresses
+			if err := json.Unmarshal(trace, &addrs); err != nil {
+				return nil, err
+			}
+			if !addrs.matches(fromAddrs, toAddrs) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			traces = append(traces, trace)
+			if args.Count != nil && uint64(len(traces)) >= *args.Count {
+				return traces, nil
+			}
+		}
+	}
+	return traces, nil
+}
+
+// resolveNumber converts a trace_filter block argument into an absolute number.
+func (t *TraceAPI) resolveNumber(ctx context.Context, number *rpc.BlockNumber, def rpc.BlockNumber) (uint64, error) {
+	if number == nil {
+		number = &def
+	}
+	switch *number {
+	case rpc.EarliestBlockNumber:
+		return 0, nil
+	case rpc.PendingBlockNumber:
+		return 0, errors.New("tracing the pending block is not supported")
+	}
+	header, err := t.api.backend.HeaderByNumber(ctx, *number)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, fmt.Errorf("block #%d not found", *number)
+	}
+	return header.Number64().Uint64(), nil
+}
+
+func (a *parityTraceAddresses) matches(from, to map[common.Address]struct{}) bool {
+	if len(from) > 0 && !containsAddress(from, a.Action.From, a.Action.Address) {
+		return false
+	}
+	if len(to) > 0 {
+		var created *common.Address
+		if a.Result != nil {
+			created = a.Result.Address
+		}
+		if !containsAddress(to, a.Action.To, a.Action.RefundAddress, created) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAddress(set map[common.Address]struct{}, addrs ...*common.Address) bool {
+	for _, addr := range addrs {
+		if addr == nil {
+			continue
+		}
+		if _, ok := set[*addr]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func addressSet(addrs []common.Address) map[common.Address]struct{} {
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// flatTraces splits the JSON array produced by the flatCallTracer.
+func flatTraces(result interface{}) ([]json.RawMessage, error) {
+	raw, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected trace result type %T", result)
+	}
+	var traces []json.RawMessage
+	if err := json.Unmarshal(raw, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing exports the spans of the node to an OpenTelemetry
+// collector over OTLP.
+//
+// The node is instrumented with OpenCensus spans. Setup bridges them to an
+// OpenTelemetry tracer provider, which batches and exports them.
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/n42blockchain/N42/conf"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/params"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/bridge/opencensus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// shutdownTimeout bounds the time spent flushing the pending spans when the
+// node stops.
+const shutdownTimeout = 5 * time.Second
+
+// Setup starts exporting traces as configured by cfg. The returned function
+// flushes the pending spans and stops the export, it must be called when the
+// node shuts down.
+func Setup(cfg conf.TracingConfig) (func(), error) {
+	if !cfg.Enable {
+		return func() {}, nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("n42"),
+			semconv.ServiceVersion(params.VersionWithMeta),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	octrace.DefaultTracer = opencensus.NewTracer(provider.Tracer("github.com/n42blockchain/N42"))
+	log.Info("Exporting traces", "endpoint", cfg.Endpoint, "ratio", cfg.SampleRatio)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Warn("Failed to flush traces", "err", err)
+		}
+	}, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package txspool
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/n42blockchain/N42/common/crypto"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+)
+
+const (
+	// maxBundles is the number of bundles the pool holds before rejecting new
+	// ones.
+	maxBundles = 1024
+	// maxBundleTxs is the number of transactions a bundle may hold.
+	maxBundleTxs = 64
+	// maxBundleDistance is how many blocks past the head a bundle may target.
+	maxBundleDistance = 64
+)
+
+var (
+	ErrBundleKnown    = fmt.Errorf("bundle already known")
+	ErrBundlePoolFull = fmt.Errorf("bundle pool is full")
+	ErrBundleEmpty    = fmt.Errorf("bundle has no transactions")
+	ErrBundleTimespan = fmt.Errorf("bundle minTimestamp is after maxTimestamp")
+	ErrBundleTooLarge = fmt.Errorf("bundle has too many transactions")
+	ErrBundleStale    = fmt.Errorf("bundle targets a past block")
+	ErrBundleTooFar   = fmt.Errorf("bundle targets a block too far ahead")
+)
+
+// Bundle is an ordered group of transactions to be included together at the
+// top of the block with number BlockNumber. A zero MinTimestamp or
+// MaxTimestamp leaves that bound open.
+type Bundle struct {
+	Txs          []*transaction.Transaction
+	BlockNumber  uint64
+	MinTimestamp uint64
+	MaxTimestamp uint64
+	// RevertingTxHashes lists the transactions allowed to fail without
+	// dropping the bundle.
+	RevertingTxHashes []types.Hash
+
+	hash types.Hash
+}
+
+// Hash returns the keccak256 hash of the concatenated transaction hashes.
+func (b *Bundle) Hash() types.Hash {
+	if b.hash == (types.Hash{}) {
+		hashes := make([]byte, 0, len(b.Txs)*types.HashLength)
+		for _, tx := range b.Txs {
+			hash := tx.Hash()
+			hashes = append(hashes, hash.Bytes()...)
+		}
+		b.hash = crypto.Keccak256Hash(hashes)
+	}
+	return b.hash
+}
+
+// MayRevert reports whether the bundle tolerates tx failing.
+func (b *Bundle) MayRevert(hash types.Hash) bool {
+	for _, h := range b.RevertingTxHashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// BundlePool keeps the bundles submitted for upcoming blocks until their
+// target block has passed.
+type BundlePool struct {
+	mu      sync.Mutex
+	bundles []*Bundle
+	known   map[types.Hash]struct{}
+}
+
+// NewBundlePool creates an empty bundle pool.
+func NewBundlePool() *BundlePool {
+	return &BundlePool{known: make(map[types.Hash]struct{})}
+}
+
+// Add queues a bundle for its target block, which must be at most
+// maxBundleDistance blocks past head. The bundles of blocks up to head are
+// dropped first.
+func (p *BundlePool) Add(b *Bundle, head uint64) error {
+	if len(b.Txs) == 0 {
+		return ErrBundleEmpty
+	}
+	if len(b.Txs) > maxBundleTxs {
+		return ErrBundleTooLarge
+	}
+	if b.MinTimestamp != 0 && b.MaxTimestamp != 0 && b.MinTimestamp > b.MaxTimestamp {
+		return ErrBundleTimespan
+	}
+	if b.BlockNumber <= head {
+		return ErrBundleStale
+	}
+	if b.BlockNumber > head+maxBundleDistance {
+		return ErrBundleTooFar
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prune(head + 1)
+
+	hash := b.Hash()
+	if _, ok := p.known[hash]; ok {
+		return ErrBundleKnown
+	}
+	if len(p.bundles) >= maxBundles {
+		return ErrBundlePoolFull
+	}
+	p.bundles = append(p.bundles, b)
+	p.known[hash] = struct{}{}
+	return nil
+}
+
+// Bundles returns, in arrival order, the bundles which may be included in the
+// block with the given number and timestamp. Bundles targeting earlier blocks
+// are dropped.
+func (p *BundlePool) Bundles(number, timestamp uint64) []*Bundle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prune(number)
+	var eligible []*Bundle
+	for _, b := range p.bundles {
+		if b.BlockNumber != number {
+			continue
+		}
+		if b.MinTimestamp != 0 && timestamp < b.MinTimestamp {
+			continue
+		}
+		if b.MaxTimestamp != 0 && timestamp > b.MaxTimestamp {
+			continue
+		}
+		eligible = append(eligible, b)
+	}
+	return eligible
+}
+
+// SetHead drops the bundles of the blocks up to the new chain head.
+func (p *BundlePool) SetHead(head uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prune(head + 1)
+}
+
+// prune drops the bundles targeting blocks before number.
+func (p *BundlePool) prune(number uint64) {
+	kept := p.bundles[:0]
+	for _, b := range p.bundles {
+		if b.BlockNumber < number {
+			delete(p.known, b.Hash())
+			continue
+		}
+		kept = append(kept, b)
+	}
+	for i := len(kept); i < len(p.bundles); i++ {
+		p.bundles[i] = nil
+	}
+	p.bundles = kept
+}
+
+// Len returns the number of queued bundles.
+func (p *BundlePool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.bundles)
+}
@@ -274,6 +274,7 @@ func (pool *TxsPool) addTxs(txs []*transaction.Transaction, local, sync bool) []
 		errs = make([]error, len(txs))
 		news = make([]*transaction.Transaction, 0, len(txs))
 	)
+	transaction.SenderCacher.Recover(pool.signer(), txs)
 	for i, tx := range txs {
 		// If the transaction is known, pre-set the error slot
 		hash := tx.Hash()
@@ -625,6 +626,12 @@ func (pool *TxsPool) validateSender(tx *transaction.Transaction) bool {
 	return true
 }
 
+// signer returns the signer recovering the senders of the transactions of
+// any type the chain accepts.
+func (pool *TxsPool) signer() transaction.Signer {
+	return transaction.LatestSignerForChainID(pool.chainconfig.ChainID)
+}
+
 // requestReset requests a pool reset to the new head block.
 // The returned channel is closed when the reset has occurred.
 func (pool *TxsPool) requestReset(oldBlock block.IBlock, newBlock block.IBlock) <-chan struct{} {
@@ -1282,6 +1289,22 @@ func (pool *TxsPool) Content() (map[types.Address][]*transaction.Transaction, ma
 	return pending, queued
 }
 
+// ContentFrom retrieves the pending and queued transactions of a single
+// account, sorted by nonce.
+func (pool *TxsPool) ContentFrom(addr types.Address) ([]*transaction.Transaction, []*transaction.Transaction) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued []*transaction.Transaction
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
 func (pool *TxsPool) Nonce(addr types.Address) uint64 {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
@@ -1294,13 +1317,23 @@ func (pool *TxsPool) StatsPrint() {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
-	pendingAddresses, pendingTxs, queuedAddresses, queuedTxs := pool.Stats()
+	pendingAddresses, pendingTxs, queuedAddresses, queuedTxs := pool.stats()
 
 	log.Debugf("txs pool： pendingAddresses count: %d pendingTxs count: %d ", pendingAddresses, pendingTxs)
 	log.Debugf("txs pool： queuedAddresses count: %d queuedTxs count: %d", queuedAddresses, queuedTxs)
 }
 
+// Stats returns the number of accounts and transactions in the pending and
+// queued sets.
 func (pool *TxsPool) Stats() (int, int, int, int) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.stats()
+}
+
+// stats is Stats for callers already holding the pool lock.
+func (pool *TxsPool) stats() (int, int, int, int) {
 	pendingTxs := 0
 	pendingAddresses := len(pool.pending)
 	for _, list := range pool.pending {
@@ -189,6 +189,20 @@ func init() {
 
 // ActivePrecompiles returns the precompiles enabled with the current configuration.
 func ActivePrecompiles(rules *params.Rules) []types.Address {
+	addresses := forkPrecompiles(rules)
+	if len(rules.Precompiles) == 0 {
+		return addresses
+	}
+	addresses = append(make([]types.Address, 0, len(addresses)+len(rules.Precompiles)), addresses...)
+	for addr := range rules.Precompiles {
+		addresses = append(addresses, addr)
+	}
+	return addresses
+}
+
+// forkPrecompiles returns the precompiles of the forks enabled with the
+// current configuration.
+func forkPrecompiles(rules *params.Rules) []types.Address {
 	switch {
 	case rules.IsMoran:
 		return PrecompiledAddressesMoran
@@ -49,8 +49,13 @@ func (evm *EVM) precompile(addr types.Address) (PrecompiledContract, bool) {
 	default:
 		precompiles = PrecompiledContractsHomestead
 	}
-	p, ok := precompiles[addr]
-	return p, ok
+	if p, ok := precompiles[addr]; ok {
+		return p, true
+	}
+	if name, ok := evm.chainRules.Precompiles[addr]; ok {
+		return registeredPrecompile(name)
+	}
+	return nil, false
 }
 
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
@@ -0,0 +1,89 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/params"
+)
+
+// The registry of the precompiled contracts a chain config can activate by
+// name at addresses and blocks of its choosing, see params.PrecompileConfig.
+// The EIP-2537 BLS12-381 operations are registered by default.
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]PrecompiledContract{
+		"bls12381G1Add":      &bls12381G1Add{},
+		"bls12381G1Mul":      &bls12381G1Mul{},
+		"bls12381G1MultiExp": &bls12381G1MultiExp{},
+		"bls12381G2Add":      &bls12381G2Add{},
+		"bls12381G2Mul":      &bls12381G2Mul{},
+		"bls12381G2MultiExp": &bls12381G2MultiExp{},
+		"bls12381Pairing":    &bls12381Pairing{},
+		"bls12381MapG1":      &bls12381MapG1{},
+		"bls12381MapG2":      &bls12381MapG2{},
+	}
+)
+
+// RegisterPrecompile makes the precompiled contract p available to chain
+// configs under name. It is meant to be called from the init function of the
+// package implementing the contract, and panics if name is taken.
+func RegisterPrecompile(name string, p PrecompiledContract) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("precompile %s registered twice", name))
+	}
+	registry[name] = p
+}
+
+func registeredPrecompile(name string) (PrecompiledContract, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// CheckPrecompiles verifies that the precompiles of the chain config are
+// registered, have an activation block and use distinct addresses that none
+// of the precompiles of the forks occupies.
+func CheckPrecompiles(config *params.ChainConfig) error {
+	seen := make(map[types.Address]string)
+	for _, p := range config.Precompiles {
+		if _, ok := registeredPrecompile(p.Name); !ok {
+			return fmt.Errorf("unknown precompile %q", p.Name)
+		}
+		if p.Block == nil {
+			return fmt.Errorf("precompile %s has no activation block", p.Name)
+		}
+		if name, ok := seen[p.Address]; ok {
+			return fmt.Errorf("precompiles %s and %s share address %s", name, p.Name, p.Address)
+		}
+		for _, builtin := range []map[types.Address]PrecompiledContract{
+			PrecompiledContractsIsMoran, PrecompiledContractsNano, PrecompiledContractsBerlin, PrecompiledContractsIstanbulForBSC,
+		} {
+			if _, ok := builtin[p.Address]; ok {
+				return fmt.Errorf("precompile %s at %s overrides a builtin precompile", p.Name, p.Address)
+			}
+		}
+		seen[p.Address] = p.Name
+	}
+	return nil
+}
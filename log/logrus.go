@@ -67,12 +67,18 @@ func (l *logger) write(msg string, lvl Lvl, ctx []interface{}, skip int) {
 		}
 	}
 
-	if terminal.IsLevelEnabled(logrus.Level(lvl)) {
+	toFile := terminal.IsLevelEnabled(logrus.Level(lvl))
+	toConsole := std.IsLevelEnabled(logrus.Level(lvl))
+	if vm := vmodules.Load(); vm != nil {
+		toFile, toConsole = vm.enabled(lvl, skip)
+	}
+
+	if toFile {
 		prepareFields()
 		terminal.WithFields(field).Log(logrus.Level(lvl), msg)
 	}
 
-	if std.IsLevelEnabled(logrus.Level(lvl)) {
+	if toConsole {
 		prepareFields()
 		std.WithFields(field).Log(logrus.Level(lvl), msg)
 	}
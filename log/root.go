@@ -18,8 +18,11 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/n42blockchain/N42/conf"
 	prefixed "github.com/n42blockchain/N42/log/logrus-prefixed-formatter"
@@ -51,26 +54,62 @@ const (
 	LvlTrace
 )
 
+// Log formats selectable for the console and the log file.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 func Init(nodeConfig conf.NodeConfig, config conf.LoggerConfig) {
+	logrus.SetFormatter(newFormatter(config.ConsoleFormat, true))
+	lvl, _ := logrus.ParseLevel(config.Level)
+
+	if config.LogFile == "" {
+		terminal.SetOutput(io.Discard)
+	} else {
+		file := config.LogFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(nodeConfig.DataDir, "log", file)
+		}
+		fileFormat := config.FileFormat
+		if fileFormat == "" {
+			fileFormat = FormatJSON
+		}
+		terminal.SetFormatter(newFormatter(fileFormat, false))
+		terminal.SetOutput(&lumberjack.Logger{
+			Filename:   file,
+			MaxSize:    config.MaxSize,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAge,
+			Compress:   config.Compress,
+		})
+	}
+
+	setBaseLevels(lvl, lvl, config.LogFile != "")
+
+	for _, format := range []string{config.ConsoleFormat, config.FileFormat} {
+		if format != "" && format != FormatText && format != FormatJSON {
+			Warn("Unknown log format, using text", "format", format)
+		}
+	}
+	if err := SetVModule(config.VModule); err != nil {
+		Warn("Ignoring log module levels", "err", err)
+	}
+}
+
+// newFormatter returns the formatter of the given format. JSON records carry
+// RFC 3339 timestamps so log collectors can parse them.
+func newFormatter(format string, colors bool) logrus.Formatter {
+	if format == FormatJSON {
+		formatter := new(logrus.JSONFormatter)
+		formatter.TimestampFormat = time.RFC3339Nano
+		return formatter
+	}
 	formatter := new(prefixed.TextFormatter)
 	formatter.TimestampFormat = "2006-01-02 15:04:05"
 	formatter.FullTimestamp = true
-	formatter.DisableColors = false
-	logrus.SetFormatter(formatter)
-	lvl, _ := logrus.ParseLevel(config.Level)
-	logrus.SetLevel(lvl)
-
-	jsonFormatter := new(logrus.JSONFormatter)
-	jsonFormatter.TimestampFormat = "2006-01-02 15:04:05"
-	terminal.SetFormatter(jsonFormatter)
-	terminal.SetLevel(lvl)
-	terminal.SetOutput(&lumberjack.Logger{
-		Filename:   fmt.Sprintf("%s/log/%s", nodeConfig.DataDir, config.LogFile),
-		MaxSize:    config.MaxSize,
-		MaxBackups: config.MaxBackups,
-		MaxAge:     config.MaxAge,
-		Compress:   config.Compress,
-	})
+	formatter.DisableColors = !colors
+	return formatter
 }
 
 func InitMobileLogger(filepath string, isDebug bool) {
@@ -0,0 +1,201 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	vmodules atomic.Pointer[vmodule]
+
+	// The levels and outputs configured by Init, applied to the packages no
+	// vmodule rule matches. baseMu serialises the writers, log calls read
+	// base without locking.
+	baseMu sync.Mutex
+	base   atomic.Pointer[baseLevels]
+)
+
+func init() {
+	base.Store(&baseLevels{console: logrus.InfoLevel, file: logrus.InfoLevel, toFile: true})
+}
+
+// baseLevels are the levels of the console and the log file, and whether
+// records are written to the file at all.
+type baseLevels struct {
+	console, file logrus.Level
+	toFile        bool
+}
+
+// vmoduleRule sets the level of the packages matching pattern.
+type vmoduleRule struct {
+	pattern string
+	level   logrus.Level
+}
+
+// vmodule holds the per package level overrides. The level of a call site is
+// resolved once and cached by program counter.
+type vmodule struct {
+	rules    []vmoduleRule
+	maxLevel logrus.Level // most verbose level of the rules
+	cache    sync.Map     // pc -> *vmoduleRule, nil if no rule matches
+}
+
+// SetVModule sets the per package log levels from a comma separated list of
+// pattern=level entries, e.g. "p2p=trace,rawdb=debug". A pattern without a
+// slash matches any element of a package path, so "p2p" covers internal/p2p
+// and internal/p2p/peers; a pattern with slashes matches the end of the path.
+// Patterns may use the wildcards of path.Match, and the first matching entry
+// wins. An empty spec removes all overrides.
+func SetVModule(spec string) error {
+	var rules []vmoduleRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, lvl, ok := strings.Cut(entry, "=")
+		if !ok || pattern == "" {
+			return fmt.Errorf("invalid vmodule entry %q, want pattern=level", entry)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid vmodule pattern %q: %v", pattern, err)
+		}
+		level, err := logrus.ParseLevel(lvl)
+		if err != nil {
+			return fmt.Errorf("invalid vmodule level %q: %v", lvl, err)
+		}
+		rules = append(rules, vmoduleRule{pattern: pattern, level: level})
+	}
+
+	baseMu.Lock()
+	defer baseMu.Unlock()
+	if len(rules) == 0 {
+		vmodules.Store(nil)
+		applyBaseLevels()
+		return nil
+	}
+	vm := &vmodule{rules: rules}
+	for _, rule := range rules {
+		if rule.level > vm.maxLevel {
+			vm.maxLevel = rule.level
+		}
+	}
+	// Let every record through logrus, write filters them instead.
+	std.SetLevel(logrus.TraceLevel)
+	if base.Load().toFile {
+		terminal.SetLevel(logrus.TraceLevel)
+	}
+	vmodules.Store(vm)
+	return nil
+}
+
+// setBaseLevels records the levels configured by Init.
+func setBaseLevels(console, file logrus.Level, toFile bool) {
+	baseMu.Lock()
+	defer baseMu.Unlock()
+	base.Store(&baseLevels{console: console, file: file, toFile: toFile})
+	if vmodules.Load() == nil {
+		applyBaseLevels()
+	}
+}
+
+func applyBaseLevels() {
+	levels := base.Load()
+	std.SetLevel(levels.console)
+	if levels.toFile {
+		terminal.SetLevel(levels.file)
+	} else {
+		terminal.SetLevel(logrus.PanicLevel)
+	}
+}
+
+// enabled reports whether a record of lvl logged skip frames above it goes to
+// the log file and to the console. The call site is not looked up for records
+// neither the base levels nor any rule let through.
+func (vm *vmodule) enabled(lvl Lvl, skip int) (toFile, toConsole bool) {
+	var (
+		levels = base.Load()
+		level  = logrus.Level(lvl)
+	)
+	toFile, toConsole = levels.toFile && levels.file >= level, levels.console >= level
+	if level > vm.maxLevel && !toFile && !toConsole {
+		return false, false
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) == 1 {
+		if rule := vm.rule(pcs[0]); rule != nil {
+			toFile, toConsole = levels.toFile && rule.level >= level, rule.level >= level
+		}
+	}
+	return toFile, toConsole
+}
+
+// rule returns the first rule matching the package of the function at pc,
+// or nil if there is none.
+func (vm *vmodule) rule(pc uintptr) *vmoduleRule {
+	if rule, ok := vm.cache.Load(pc); ok {
+		return rule.(*vmoduleRule)
+	}
+	var match *vmoduleRule
+	if frame, _ := runtime.CallersFrames([]uintptr{pc}).Next(); frame.Function != "" {
+		pkg := packagePath(frame.Function)
+		for i := range vm.rules {
+			if matchPackage(vm.rules[i].pattern, pkg) {
+				match = &vm.rules[i]
+				break
+			}
+		}
+	}
+	vm.cache.Store(pc, match)
+	return match
+}
+
+// packagePath returns the import path of the package of the function with
+// the fully qualified name fn.
+func packagePath(fn string) string {
+	slash := strings.LastIndexByte(fn, '/') + 1
+	if dot := strings.IndexByte(fn[slash:], '.'); dot >= 0 {
+		return fn[:slash+dot]
+	}
+	return fn
+}
+
+func matchPackage(pattern, pkg string) bool {
+	if strings.Contains(pattern, "/") {
+		elems := strings.Count(pattern, "/") + 1
+		parts := strings.Split(pkg, "/")
+		if len(parts) < elems {
+			return false
+		}
+		ok, _ := path.Match(pattern, strings.Join(parts[len(parts)-elems:], "/"))
+		return ok
+	}
+	for _, elem := range strings.Split(pkg, "/") {
+		if ok, _ := path.Match(pattern, elem); ok {
+			return true
+		}
+	}
+	return false
+}
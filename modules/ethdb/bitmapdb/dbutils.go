@@ -164,6 +164,55 @@ func TruncateRange(db kv.RwTx, bucket string, key []byte, to uint32) error {
 	})
 }
 
+// AppendMergeByOr - merges `delta` by Or operator into the shards of `key` holding
+// values from the minimum of `delta` on, re-chunking them by ChunkLimit. `delta` is emptied.
+// Appending values above the existing ones only touches the hot shard.
+func AppendMergeByOr(db kv.RwTx, bucket string, key []byte, delta *roaring.Bitmap) error {
+	if delta.IsEmpty() {
+		return nil
+	}
+	fromKey := make([]byte, len(key)+4)
+	copy(fromKey, key)
+	binary.BigEndian.PutUint32(fromKey[len(fromKey)-4:], delta.Minimum())
+
+	var merged [][]byte
+	c, err := db.Cursor(bucket)
+	if err != nil {
+		return err
+	}
+	for k, v, err := c.Seek(fromKey); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			c.Close()
+			return err
+		}
+		if !bytes.HasPrefix(k, key) || len(k) != len(key)+4 {
+			break
+		}
+		bm := roaring.New()
+		if _, err := bm.ReadFrom(bytes.NewReader(v)); err != nil {
+			c.Close()
+			return err
+		}
+		delta.Or(bm)
+		merged = append(merged, libcommon.Copy(k))
+	}
+	c.Close()
+	for _, k := range merged {
+		if err := db.Delete(bucket, k); err != nil {
+			return err
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	return WalkChunkWithKeys(key, delta, ChunkLimit, func(chunkKey []byte, chunk *roaring.Bitmap) error {
+		buf.Reset()
+		if _, err := chunk.WriteTo(buf); err != nil {
+			return err
+		}
+		return db.Put(bucket, chunkKey, libcommon.Copy(buf.Bytes()))
+	})
+}
+
 // Get - reading as much chunks as needed to satisfy [from, to] condition
 // join all chunks to 1 bitmap by Or operator
 func Get(db kv.Tx, bucket string, key []byte, from, to uint32) (*roaring.Bitmap, error) {
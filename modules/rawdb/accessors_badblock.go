@@ -0,0 +1,83 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// MaxBadBlocks is the number of bad blocks kept in the database. The lowest
+// numbered ones are dropped first.
+const MaxBadBlocks = 10
+
+// WriteBadBlock stores a bad block, dropping the lowest numbered ones beyond
+// MaxBadBlocks.
+func WriteBadBlock(db kv.RwTx, bad *block.BadBlock) error {
+	data, err := json.Marshal(bad)
+	if err != nil {
+		return fmt.Errorf("failed to encode bad block: %w", err)
+	}
+	if err := db.Put(modules.BadBlocks, modules.BlockBodyKey(bad.Number, bad.Hash), data); err != nil {
+		return fmt.Errorf("failed to store bad block: %w", err)
+	}
+	c, err := db.RwCursor(modules.BadBlocks)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	count, err := c.Count()
+	if err != nil {
+		return err
+	}
+	for ; count > MaxBadBlocks; count-- {
+		k, _, err := c.First()
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			break
+		}
+		if err := c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAllBadBlocks retrieves the stored bad blocks, highest number first.
+func ReadAllBadBlocks(db kv.Tx) ([]*block.BadBlock, error) {
+	var bad []*block.BadBlock
+	if err := db.ForEach(modules.BadBlocks, nil, func(k, v []byte) error {
+		b := new(block.BadBlock)
+		if err := json.Unmarshal(v, b); err != nil {
+			return fmt.Errorf("invalid bad block JSON err: %v", err)
+		}
+		bad = append(bad, b)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(bad)-1; i < j; i, j = i+1, j-1 {
+		bad[i], bad[j] = bad[j], bad[i]
+	}
+	return bad, nil
+}
@@ -121,12 +121,18 @@ func ReadHeaderRAW(db kv.Getter, hash types.Hash, number uint64) []byte {
 	if err != nil {
 		log.Error("ReadHeaderRAW failed", "err", err)
 	}
+	if len(data) == 0 {
+		return readAncient(db, freezerHeaderTable, hash, number)
+	}
 	return data
 }
 
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db kv.Has, hash types.Hash, number uint64) bool {
 	if has, err := db.Has(modules.Headers, modules.HeaderKey(number, hash)); !has || err != nil {
+		if getter, ok := db.(kv.Getter); ok {
+			return hasAncient(getter, hash, number)
+		}
 		return false
 	}
 	return true
@@ -134,24 +140,33 @@ func HasHeader(db kv.Has, hash types.Hash, number uint64) bool {
 
 // ReadHeader retrieves the block header corresponding to the hash.
 func ReadHeader(db kv.Getter, hash types.Hash, number uint64) *block.Header {
+	if header, ok := cachedHeader(hash); ok && header.Number64().Uint64() == number {
+		return header
+	}
 	data := ReadHeaderRAW(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
-
-	header := new(block.Header)
-	pbHeader := new(types_pb.Header)
-
-	if err := proto.Unmarshal(data, pbHeader); err != nil {
+	header, err := decodeHeader(data)
+	if err != nil {
 		log.Error("Invalid block header RAW", "hash", hash, "err", err)
 		return nil
 	}
+	cacheHeader(hash, header)
+	return header
+}
 
+// decodeHeader decodes a header in its database encoding.
+func decodeHeader(data []byte) (*block.Header, error) {
+	pbHeader := new(types_pb.Header)
+	if err := proto.Unmarshal(data, pbHeader); err != nil {
+		return nil, err
+	}
+	header := new(block.Header)
 	if err := header.FromProtoMessage(pbHeader); err != nil {
-		log.Error("header FromProtoMessage failed", "err", err)
-		return nil
+		return nil, err
 	}
-	return header
+	return header, nil
 }
 
 //func ReadCurrentBlockNumber(db kv.Getter) *uint64 {
@@ -291,6 +306,9 @@ func CanonicalTxnByID(db kv.Getter, id uint64) (*transaction.Transaction, error)
 	if err != nil {
 		return nil, err
 	}
+	if v, err = decodeValue(modules.BlockTx, v); err != nil {
+		return nil, err
+	}
 
 	tx := new(transaction.Transaction)
 	if err := tx.Unmarshal(v); nil != err {
@@ -310,7 +328,10 @@ func CanonicalTransactions(db kv.Getter, baseTxId uint64, amount uint32) ([]*tra
 	i := uint32(0)
 
 	if err := db.ForAmount(modules.BlockTx, txIdKey, amount, func(k, v []byte) error {
-		var decodeErr error
+		v, decodeErr := decodeValue(modules.BlockTx, v)
+		if decodeErr != nil {
+			return decodeErr
+		}
 		tx := new(transaction.Transaction)
 		if decodeErr = tx.Unmarshal(v); nil != decodeErr {
 			return decodeErr
@@ -343,7 +364,7 @@ func WriteTransactions(db kv.RwTx, txs []*transaction.Transaction, baseTxId uint
 		//}
 
 		// If next Append returns KeyExists error - it means you need to open transaction in App code before calling this func. Batch is also fine.
-		if err := db.Append(modules.BlockTx, txIdKey, types.CopyBytes(data)); err != nil {
+		if err := db.Append(modules.BlockTx, txIdKey, types.CopyBytes(encodeValue(modules.BlockTx, data))); err != nil {
 			return err
 		}
 	}
@@ -356,7 +377,7 @@ func WriteRawTransactions(tx kv.RwTx, txs [][]byte, baseTxId uint64) error {
 		txIdKey := make([]byte, 8)
 		binary.BigEndian.PutUint64(txIdKey, txId)
 		// If next Append returns KeyExists error - it means you need to open transaction in App code before calling this func. Batch is also fine.
-		if err := tx.Append(modules.BlockTx, txIdKey, txn); err != nil {
+		if err := tx.Append(modules.BlockTx, txIdKey, encodeValue(modules.BlockTx, txn)); err != nil {
 			return fmt.Errorf("txId=%d, baseTxId=%d, %w", txId, baseTxId, err)
 		}
 		txId++
@@ -395,9 +416,15 @@ func ReadBodyWithTransactions(db kv.Getter, hash types.Hash, number uint64) (*bl
 }
 
 func ReadCanonicalBodyWithTransactions(db kv.Getter, hash types.Hash, number uint64) *block.Body {
+	if body, ok := cachedBody(hash); ok {
+		return body
+	}
 	body, baseTxId, txAmount := ReadBody(db, hash, number)
 	if body == nil {
-		return nil
+		if body = readAncientBody(db, hash, number); body != nil {
+			cacheBody(hash, body)
+		}
+		return body
 	}
 	var err error
 	body.Txs, err = CanonicalTransactions(db, baseTxId, txAmount)
@@ -419,6 +446,7 @@ func ReadCanonicalBodyWithTransactions(db kv.Getter, hash types.Hash, number uin
 		return nil
 	}
 	body.Rewards = rewards
+	cacheBody(hash, body)
 	return body
 }
 
@@ -450,6 +478,10 @@ func RawTransactionsRange(db kv.Getter, from, to uint64) (res [][]byte, err erro
 
 		binary.BigEndian.PutUint64(encNum, baseTxId)
 		if err = db.ForAmount(modules.BlockTx, encNum, txAmount, func(k, v []byte) error {
+			v, err := decodeValue(modules.BlockTx, v)
+			if err != nil {
+				return err
+			}
 			res = append(res, v)
 			return nil
 		}); err != nil {
@@ -675,7 +707,7 @@ func TruncateTd(tx kv.RwTx, blockFrom uint64) error {
 // to a block.
 func HasReceipts(db kv.Has, number uint64) bool {
 	if has, err := db.Has(modules.Receipts, modules.EncodeBlockNumber(number)); !has || err != nil {
-		return false
+		return len(readAncientReceipts(number)) > 0
 	}
 	return true
 }
@@ -684,11 +716,27 @@ func HasReceipts(db kv.Has, number uint64) bool {
 // The receipt metadata fields are not guaranteed to be populated, so they
 // should not be used. Use ReadReceipts instead if the metadata is needed.
 func ReadRawReceipts(db kv.Tx, blockNum uint64) block.Receipts {
+	hash, err := ReadCanonicalHash(db, blockNum)
+	if err != nil {
+		log.Error("ReadRawReceipts failed", "err", err)
+	}
+	if hash != (types.Hash{}) {
+		if receipts, ok := cachedReceipts(hash); ok {
+			return receipts
+		}
+	}
 	// Retrieve the flattened receipt slice
 	data, err := db.GetOne(modules.Receipts, modules.EncodeBlockNumber(blockNum))
 	if err != nil {
 		log.Error("ReadRawReceipts failed", "err", err)
 	}
+	if data, err = decodeValue(modules.Receipts, data); err != nil {
+		log.Error("ReadRawReceipts failed", "err", err)
+		return nil
+	}
+	if len(data) == 0 {
+		data = readAncientReceipts(blockNum)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -714,6 +762,9 @@ func ReadRawReceipts(db kv.Tx, blockNum uint64) block.Receipts {
 	//	return nil
 	//}
 
+	if hash != (types.Hash{}) {
+		cacheReceipts(hash, receipts)
+	}
 	return receipts
 }
 
@@ -779,7 +830,7 @@ func WriteReceipts(tx kv.Putter, number uint64, receipts block.Receipts) error {
 			return fmt.Errorf("encode block logs for block %d: %w", number, err)
 		}
 
-		if err = tx.Put(modules.Log, modules.LogKey(number, uint32(txId)), v); err != nil {
+		if err = tx.Put(modules.Log, modules.LogKey(number, uint32(txId)), encodeValue(modules.Log, v)); err != nil {
 			return fmt.Errorf("writing logs for block %d: %w", number, err)
 		}
 	}
@@ -789,7 +840,7 @@ func WriteReceipts(tx kv.Putter, number uint64, receipts block.Receipts) error {
 		return fmt.Errorf("encode block receipts for block %d: %w", number, err)
 	}
 
-	if err = tx.Put(modules.Receipts, modules.EncodeBlockNumber(number), v); err != nil {
+	if err = tx.Put(modules.Receipts, modules.EncodeBlockNumber(number), encodeValue(modules.Receipts, v)); err != nil {
 		return fmt.Errorf("writing receipts for block %d: %w", number, err)
 	}
 	return nil
@@ -809,7 +860,7 @@ func AppendReceipts(tx kv.StatelessWriteTx, blockNumber uint64, receipts block.R
 			return err
 		}
 
-		if err = tx.Append(modules.Log, modules.LogKey(blockNumber, uint32(txId)), v); err != nil {
+		if err = tx.Append(modules.Log, modules.LogKey(blockNumber, uint32(txId)), encodeValue(modules.Log, v)); err != nil {
 			return fmt.Errorf("writing receipts for block %d: %w", blockNumber, err)
 		}
 	}
@@ -819,7 +870,7 @@ func AppendReceipts(tx kv.StatelessWriteTx, blockNumber uint64, receipts block.R
 		return fmt.Errorf("encode block receipts for block %d: %w", blockNumber, err)
 	}
 
-	if err = tx.Append(modules.Receipts, modules.EncodeBlockNumber(blockNumber), rv); err != nil {
+	if err = tx.Append(modules.Receipts, modules.EncodeBlockNumber(blockNumber), encodeValue(modules.Receipts, rv)); err != nil {
 		return fmt.Errorf("writing receipts for block %d: %w", blockNumber, err)
 	}
 	return nil
@@ -843,7 +894,14 @@ func TruncateReceipts(db kv.RwTx, number uint64) error {
 	return nil
 }
 
+// ReceiptsAvailableFrom returns the first block whose receipts are stored,
+// math.MaxUint64 if there are none. Receipts below the prune progress count
+// as missing even if the freezer still holds them.
 func ReceiptsAvailableFrom(tx kv.Tx) (uint64, error) {
+	pruned, err := ReadPruneProgress(tx, PruneReceipts)
+	if err != nil {
+		return math.MaxUint64, err
+	}
 	c, err := tx.Cursor(modules.Receipts)
 	if err != nil {
 		return math.MaxUint64, err
@@ -853,10 +911,13 @@ func ReceiptsAvailableFrom(tx kv.Tx) (uint64, error) {
 	if err != nil {
 		return math.MaxUint64, err
 	}
+	if f := freezer.Load(); f != nil && f.Frozen() > 1 && (len(k) == 0 || binary.BigEndian.Uint64(k) > 1) {
+		return max(1, pruned), nil
+	}
 	if len(k) == 0 {
 		return math.MaxUint64, nil
 	}
-	return binary.BigEndian.Uint64(k), nil
+	return max(binary.BigEndian.Uint64(k), pruned), nil
 }
 
 // ReadBlock retrieves an entire block corresponding to the hash, assembling it
@@ -881,7 +942,7 @@ func ReadBlock(tx kv.Getter, hash types.Hash, number uint64) *block.Block {
 // It's is not equivalent of HasHeader because headers and bodies written by different stages
 func HasBlock(db kv.Getter, hash types.Hash, number uint64) bool {
 	body := ReadStorageBodyRAW(db, hash, number)
-	return len(body) > 0
+	return len(body) > 0 || hasAncient(db, hash, number)
 }
 
 func ReadBlockWithSenders(db kv.Getter, hash types.Hash, number uint64) (*block.Block, []types.Address, error) {
@@ -915,6 +976,43 @@ func WriteBlock(db kv.RwTx, b *block.Block) error {
 	return nil
 }
 
+// WriteBlockWithReceipts stores a block with its receipts and total difficulty.
+// If canonical is set the block also becomes the canonical block at its height
+// and the head block, see WriteCanonicalBlock. Everything is written within tx,
+// so that a crash leaves either the full block or none of it.
+func WriteBlockWithReceipts(tx kv.RwTx, b *block.Block, receipts block.Receipts, td *uint256.Int, canonical bool) error {
+	number := b.Number64().Uint64()
+	if err := WriteBlock(tx, b); err != nil {
+		return err
+	}
+	if err := WriteTd(tx, b.Hash(), number, td); err != nil {
+		return err
+	}
+	if len(receipts) > 0 {
+		if err := AppendReceipts(tx, number, receipts); err != nil {
+			return err
+		}
+	}
+	if canonical {
+		return WriteCanonicalBlock(tx, b)
+	}
+	return nil
+}
+
+// WriteCanonicalBlock makes a stored block the canonical block at its height
+// and the head block, and adds its transactions to the lookup index unless
+// disabled by SetTxIndex.
+func WriteCanonicalBlock(tx kv.RwTx, b *block.Block) error {
+	if err := WriteCanonicalHash(tx, b.Hash(), b.Number64().Uint64()); err != nil {
+		return err
+	}
+	if !txIndexDisabled.Load() {
+		WriteTxLookupEntries(tx, b)
+	}
+	WriteHeadBlockHash(tx, b.Hash())
+	return nil
+}
+
 // DeleteAncientBlocks - delete [1, to) old blocks after moving it to snapshots.
 // keeps genesis in db: [1, to)
 // doesn't change sequences of kv.EthTx and kv.NonCanonicalTxs
@@ -1056,6 +1154,9 @@ func TruncateBlocks(ctx context.Context, tx kv.RwTx, blockFrom uint64) error {
 	if blockFrom < 1 { //protect genesis
 		blockFrom = 1
 	}
+	if f := freezer.Load(); f != nil && blockFrom < f.Frozen() {
+		return fmt.Errorf("cannot truncate frozen blocks, first unfrozen block is %d", f.Frozen())
+	}
 	sequenceTo := map[string]uint64{}
 	for k, _, err := c.Last(); k != nil; k, _, err = c.Prev() {
 		if err != nil {
@@ -1339,6 +1440,48 @@ func WriteHeadHeaderHash(db kv.Putter, hash types.Hash) error {
 	return nil
 }
 
+// ReadFinalizedBlockHash retrieves the hash of the finalized block, the zero
+// hash if none was finalized.
+func ReadFinalizedBlockHash(db kv.Getter) types.Hash {
+	data, err := db.GetOne(modules.FinalizedBlockKey, []byte(modules.FinalizedBlockKey))
+	if err != nil {
+		log.Error("ReadFinalizedBlockHash failed", "err", err)
+	}
+	if len(data) == 0 {
+		return types.Hash{}
+	}
+	return types.BytesToHash(data)
+}
+
+// WriteFinalizedBlockHash stores the hash of the finalized block.
+func WriteFinalizedBlockHash(db kv.Putter, hash types.Hash) error {
+	if err := db.Put(modules.FinalizedBlockKey, []byte(modules.FinalizedBlockKey), hash.Bytes()); err != nil {
+		return fmt.Errorf("failed to store finalized block's hash: %w", err)
+	}
+	return nil
+}
+
+// ReadSafeBlockHash retrieves the hash of the safe block, the zero hash if
+// none is known.
+func ReadSafeBlockHash(db kv.Getter) types.Hash {
+	data, err := db.GetOne(modules.SafeBlockKey, []byte(modules.SafeBlockKey))
+	if err != nil {
+		log.Error("ReadSafeBlockHash failed", "err", err)
+	}
+	if len(data) == 0 {
+		return types.Hash{}
+	}
+	return types.BytesToHash(data)
+}
+
+// WriteSafeBlockHash stores the hash of the safe block.
+func WriteSafeBlockHash(db kv.Putter, hash types.Hash) error {
+	if err := db.Put(modules.SafeBlockKey, []byte(modules.SafeBlockKey), hash.Bytes()); err != nil {
+		return fmt.Errorf("failed to store safe block's hash: %w", err)
+	}
+	return nil
+}
+
 func GetPoaSnapshot(db kv.Getter, hash types.Hash) ([]byte, error) {
 
 	return db.GetOne(modules.PoaSnapshot, hash.Bytes())
@@ -17,6 +17,9 @@
 package rawdb
 
 import (
+	"encoding/binary"
+	"fmt"
+
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/n42blockchain/N42/common/block"
@@ -26,17 +29,22 @@ import (
 	"github.com/n42blockchain/N42/modules"
 )
 
+// txLookupEntrySize is the size of a lookup entry: the block number and the
+// index of the transaction in the block, 8 bytes each. Entries written before
+// the index was recorded hold the block number only, in at most 8 bytes.
+const txLookupEntrySize = 16
+
 // TxLookupEntry is a positional metadata to help looking up the data content of
 // a transaction or receipt given only its hash.
 type TxLookupEntry struct {
-	BlockHash  types.Hash
-	BlockIndex uint64
-	Index      uint64
+	BlockNumber uint64
+	Index       uint64
+	HasIndex    bool // false for entries written without the index
 }
 
-// ReadTxLookupEntry retrieves the positional metadata associated with a transaction
+// ReadTxLookup retrieves the positional metadata associated with a transaction
 // hash to allow retrieving the transaction or receipt by hash.
-func ReadTxLookupEntry(db kv.Getter, txnHash types.Hash) (*uint64, error) {
+func ReadTxLookup(db kv.Getter, txnHash types.Hash) (*TxLookupEntry, error) {
 	data, err := db.GetOne(modules.TxLookup, txnHash.Bytes())
 	if err != nil {
 		return nil, err
@@ -44,16 +52,34 @@ func ReadTxLookupEntry(db kv.Getter, txnHash types.Hash) (*uint64, error) {
 	if len(data) == 0 {
 		return nil, nil
 	}
-	// number := new(big.Int).SetBytes(data).Uint64()
-	number := uint256.NewInt(0).SetBytes(data).Uint64()
-	return &number, nil
+	if len(data) == txLookupEntrySize {
+		return &TxLookupEntry{
+			BlockNumber: binary.BigEndian.Uint64(data[:8]),
+			Index:       binary.BigEndian.Uint64(data[8:]),
+			HasIndex:    true,
+		}, nil
+	}
+	return &TxLookupEntry{BlockNumber: uint256.NewInt(0).SetBytes(data).Uint64()}, nil
+}
+
+// ReadTxLookupEntry retrieves the number of the block holding the transaction
+// with the given hash.
+func ReadTxLookupEntry(db kv.Getter, txnHash types.Hash) (*uint64, error) {
+	entry, err := ReadTxLookup(db, txnHash)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return &entry.BlockNumber, nil
 }
 
 // WriteTxLookupEntries stores a positional metadata for every transaction from
 // a block, enabling hash based transaction and receipt lookups.
 func WriteTxLookupEntries(db kv.Putter, block *block.Block) {
-	for _, tx := range block.Transactions() {
-		data := block.Number64().Bytes()
+	number := block.Number64().Uint64()
+	for i, tx := range block.Transactions() {
+		data := make([]byte, txLookupEntrySize)
+		binary.BigEndian.PutUint64(data[:8], number)
+		binary.BigEndian.PutUint64(data[8:], uint64(i))
 		h := tx.Hash()
 		if err := db.Put(modules.TxLookup, h.Bytes(), data); err != nil {
 			log.Crit("Failed to store transaction lookup entry", "err", err)
@@ -61,6 +87,32 @@ func WriteTxLookupEntries(db kv.Putter, block *block.Block) {
 	}
 }
 
+// RebuildTxLookup writes the lookup entries of the transactions of the
+// canonical blocks from through to, replacing the entries written without the
+// index of the transaction.
+func RebuildTxLookup(tx kv.RwTx, from, to uint64) error {
+	count := int(to - from + 1)
+	hashes, err := readCanonicalHashes(tx, from, count)
+	if err != nil {
+		return err
+	}
+	headers, err := ReadHeadersRange(tx, from, count)
+	if err != nil {
+		return err
+	}
+	bodies, err := ReadBodiesRange(tx, from, count)
+	if err != nil {
+		return err
+	}
+	if len(hashes) < count || len(headers) < count || len(bodies) < count {
+		return fmt.Errorf("block %d is missing", from+uint64(min(len(hashes), len(headers), len(bodies))))
+	}
+	for i, hash := range hashes {
+		WriteTxLookupEntries(tx, block.NewBlockFromStorage(hash, headers[i], bodies[i]))
+	}
+	return nil
+}
+
 // DeleteTxLookupEntry removes all transaction data associated with a hash.
 func DeleteTxLookupEntry(db kv.Deleter, hash types.Hash) error {
 	return db.Delete(modules.TxLookup, hash.Bytes())
@@ -69,38 +121,48 @@ func DeleteTxLookupEntry(db kv.Deleter, hash types.Hash) error {
 // ReadTransactionByHash retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func ReadTransactionByHash(db kv.Tx, hash types.Hash) (*transaction.Transaction, types.Hash, uint64, uint64, error) {
-	blockNumber, err := ReadTxLookupEntry(db, hash)
+	entry, err := ReadTxLookup(db, hash)
 	if err != nil {
 		return nil, types.Hash{}, 0, 0, err
 	}
-	if blockNumber == nil {
+	if entry == nil {
 		return nil, types.Hash{}, 0, 0, nil
 	}
-	blockHash, err := ReadCanonicalHash(db, *blockNumber)
-	if err != nil {
-		return nil, types.Hash{}, 0, 0, err
-	}
-	if blockHash == (types.Hash{}) {
-		return nil, types.Hash{}, 0, 0, nil
-	}
-	body := ReadCanonicalBodyWithTransactions(db, blockHash, *blockNumber)
-	if body == nil {
-		log.Error("Transaction referenced missing", "number", blockNumber, "hash", blockHash)
-		return nil, types.Hash{}, 0, 0, nil
-	}
-	senders, err1 := ReadSenders(db, blockHash, *blockNumber)
-	if err1 != nil {
-		return nil, types.Hash{}, 0, 0, err1
+	if entry.HasIndex {
+		blockHash, err := ReadCanonicalHash(db, entry.BlockNumber)
+		if err != nil {
+			return nil, types.Hash{}, 0, 0, err
+		}
+		if blockHash == (types.Hash{}) {
+			return nil, types.Hash{}, 0, 0, nil
+		}
+		tx, err := readCanonicalTxn(db, blockHash, entry.BlockNumber, entry.Index)
+		if err != nil {
+			return nil, types.Hash{}, 0, 0, err
+		}
+		if tx != nil && tx.Hash() == hash {
+			return tx, blockHash, entry.BlockNumber, entry.Index, nil
+		}
 	}
-	body.SendersToTxs(senders)
-	for txIndex, tx := range body.Txs {
-		h := tx.Hash()
-		if h == hash {
-			return tx, blockHash, *blockNumber, uint64(txIndex), nil
+	return ReadTransaction(db, hash, entry.BlockNumber)
+}
+
+// readCanonicalTxn returns the transaction at index in canonical block number,
+// or nil if the block holds fewer transactions. Only that transaction is read,
+// unless the block is frozen.
+func readCanonicalTxn(db kv.Getter, hash types.Hash, number, index uint64) (*transaction.Transaction, error) {
+	if b, err := ReadStorageBody(db, hash, number); err == nil {
+		// 1 system txn in the beginning of block, and 1 at the end
+		if index+2 >= uint64(b.TxAmount) {
+			return nil, nil
 		}
+		return CanonicalTxnByID(db, b.BaseTxId+1+index)
 	}
-	log.Error("Transaction not found", "number", blockNumber, "hash", blockHash, "txhash", hash)
-	return nil, types.Hash{}, 0, 0, nil
+	body := ReadCanonicalBodyWithTransactions(db, hash, number)
+	if body == nil || index >= uint64(len(body.Txs)) {
+		return nil, nil
+	}
+	return body.Txs[index], nil
 }
 
 // ReadTransaction retrieves a specific transaction from the database, along with
@@ -135,13 +197,14 @@ func ReadTransaction(db kv.Tx, hash types.Hash, blockNumber uint64) (*transactio
 
 func ReadReceipt(db kv.Tx, txHash types.Hash) (*block.Receipt, types.Hash, uint64, uint64, error) {
 	// Retrieve the context of the receipt based on the transaction hash
-	blockNumber, err := ReadTxLookupEntry(db, txHash)
+	entry, err := ReadTxLookup(db, txHash)
 	if err != nil {
 		return nil, types.Hash{}, 0, 0, err
 	}
-	if blockNumber == nil {
+	if entry == nil {
 		return nil, types.Hash{}, 0, 0, nil
 	}
+	blockNumber := &entry.BlockNumber
 	blockHash, err := ReadCanonicalHash(db, *blockNumber)
 	if err != nil {
 		return nil, types.Hash{}, 0, 0, err
@@ -155,6 +218,9 @@ func ReadReceipt(db kv.Tx, txHash types.Hash) (*block.Receipt, types.Hash, uint6
 	}
 	// Read all the receipts from the block and return the one with the matching hash
 	receipts := ReadReceipts(db, b, senders)
+	if entry.HasIndex && entry.Index < uint64(len(receipts)) && receipts[entry.Index].TxHash == txHash {
+		return receipts[entry.Index], blockHash, *blockNumber, entry.Index, nil
+	}
 	for receiptIndex, receipt := range receipts {
 		if receipt.TxHash == txHash {
 			return receipt, blockHash, *blockNumber, uint64(receiptIndex), nil
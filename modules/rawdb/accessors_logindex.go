@@ -0,0 +1,155 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/ethdb/bitmapdb"
+)
+
+// logIndexHeadKey tracks the number and hash of the last block whose logs are
+// in the log index.
+var logIndexHeadKey = []byte("LogIndexHead")
+
+// ReadLogIndexHead retrieves the number and hash of the last indexed block,
+// ok is false if no block was indexed yet.
+func ReadLogIndexHead(db kv.Getter) (number uint64, hash types.Hash, ok bool, err error) {
+	data, err := db.GetOne(modules.DatabaseInfo, logIndexHeadKey)
+	if err != nil || len(data) == 0 {
+		return 0, types.Hash{}, false, err
+	}
+	if len(data) != 8+types.HashLength {
+		return 0, types.Hash{}, false, fmt.Errorf("invalid log index head")
+	}
+	return binary.BigEndian.Uint64(data[:8]), types.BytesToHash(data[8:]), true, nil
+}
+
+// WriteLogIndexHead stores the number and hash of the last indexed block.
+func WriteLogIndexHead(db kv.Putter, number uint64, hash types.Hash) error {
+	data := make([]byte, 8+types.HashLength)
+	binary.BigEndian.PutUint64(data[:8], number)
+	copy(data[8:], hash[:])
+	return db.Put(modules.DatabaseInfo, logIndexHeadKey, data)
+}
+
+// IndexLogs adds the addresses and topics of the logs of the canonical blocks
+// from through to to the log index, and makes to the last indexed block.
+//
+// Blocks are never removed from the index: after a reorg the blocks of the new
+// chain are indexed again, and the numbers of blocks dropped from the chain
+// only cause false positives, which the log filter drops.
+func IndexLogs(tx kv.RwTx, from, to uint64) error {
+	blocks, err := ReadReceiptsRange(tx, from, int(to-from+1))
+	if err != nil {
+		return err
+	}
+	addresses := make(map[types.Address]*roaring.Bitmap)
+	topics := make(map[types.Hash]*roaring.Bitmap)
+	for i, receipts := range blocks {
+		number := from + uint64(i)
+		for _, receipt := range receipts {
+			for _, l := range receipt.Logs {
+				bm, ok := addresses[l.Address]
+				if !ok {
+					bm = roaring.New()
+					addresses[l.Address] = bm
+				}
+				bm.Add(uint32(number))
+				for _, topic := range l.Topics {
+					bm, ok := topics[topic]
+					if !ok {
+						bm = roaring.New()
+						topics[topic] = bm
+					}
+					bm.Add(uint32(number))
+				}
+			}
+		}
+	}
+	for addr, bm := range addresses {
+		if err := bitmapdb.AppendMergeByOr(tx, modules.LogAddressIndex, addr.Bytes(), bm); err != nil {
+			return err
+		}
+	}
+	for topic, bm := range topics {
+		if err := bitmapdb.AppendMergeByOr(tx, modules.LogTopicIndex, topic.Bytes(), bm); err != nil {
+			return err
+		}
+	}
+	hash, err := ReadCanonicalHash(tx, to)
+	if err != nil {
+		return err
+	}
+	return WriteLogIndexHead(tx, to, hash)
+}
+
+// ReadLogIndexBlocks returns the numbers of the blocks from through to which
+// may hold logs emitted by one of addresses, with a topic of every non-empty
+// group of topics. The criteria are the ones of eth_getLogs, except that the
+// position of the topics is not checked. It returns nil if there are no
+// criteria and all blocks match.
+func ReadLogIndexBlocks(tx kv.Tx, addresses []types.Address, topics [][]types.Hash, from, to uint64) (*roaring.Bitmap, error) {
+	var result *roaring.Bitmap
+	and := func(bm *roaring.Bitmap) {
+		if result == nil {
+			result = bm
+		} else {
+			result.And(bm)
+		}
+	}
+	if len(addresses) > 0 {
+		bm, err := readLogIndexUnion(tx, modules.LogAddressIndex, len(addresses), func(i int) []byte { return addresses[i].Bytes() }, from, to)
+		if err != nil {
+			return nil, err
+		}
+		and(bm)
+	}
+	for _, sub := range topics {
+		if len(sub) == 0 {
+			continue // empty rule set == wildcard
+		}
+		bm, err := readLogIndexUnion(tx, modules.LogTopicIndex, len(sub), func(i int) []byte { return sub[i].Bytes() }, from, to)
+		if err != nil {
+			return nil, err
+		}
+		and(bm)
+	}
+	if result != nil {
+		result.RemoveRange(0, from)
+		result.RemoveRange(to+1, uint64(^uint32(0))+1)
+	}
+	return result, nil
+}
+
+// readLogIndexUnion returns the union of the bitmaps of n keys of bucket.
+func readLogIndexUnion(tx kv.Tx, bucket string, n int, key func(i int) []byte, from, to uint64) (*roaring.Bitmap, error) {
+	union := roaring.New()
+	for i := 0; i < n; i++ {
+		bm, err := bitmapdb.Get(tx, bucket, key(i), uint32(from), uint32(to))
+		if err != nil {
+			return nil, err
+		}
+		union.Or(bm)
+	}
+	return union, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/transaction"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// The range accessors read count consecutive canonical blocks from number
+// from, walking the tables with cursors instead of looking every block up on
+// its own. They stop at the first block missing from the canonical chain, so
+// they may return fewer than count items. They bypass the block caches, so
+// that scanning the history does not evict the recently read blocks.
+
+// readCanonicalHashes returns the hashes of up to count consecutive canonical
+// blocks from number from.
+func readCanonicalHashes(tx kv.Tx, from uint64, count int) ([]types.Hash, error) {
+	c, err := tx.Cursor(modules.HeaderCanonical)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	hashes := make([]types.Hash, 0, count)
+	k, v, err := c.Seek(modules.EncodeBlockNumber(from))
+	for ; err == nil && k != nil && len(hashes) < count; k, v, err = c.Next() {
+		if binary.BigEndian.Uint64(k) != from+uint64(len(hashes)) {
+			break
+		}
+		hashes = append(hashes, types.BytesToHash(v))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// ReadHeadersRange returns the headers of up to count consecutive canonical
+// blocks from number from.
+func ReadHeadersRange(tx kv.Tx, from uint64, count int) ([]*block.Header, error) {
+	hashes, err := readCanonicalHashes(tx, from, count)
+	if err != nil {
+		return nil, err
+	}
+	c, err := tx.Cursor(modules.Headers)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	headers := make([]*block.Header, len(hashes))
+	for i, hash := range hashes {
+		number := from + uint64(i)
+		_, data, err := c.SeekExact(modules.HeaderKey(number, hash))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			data = readAncient(tx, freezerHeaderTable, hash, number)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("header of block %d is missing", number)
+		}
+		if headers[i], err = decodeHeader(data); err != nil {
+			return nil, fmt.Errorf("invalid header of block %d: %w", number, err)
+		}
+	}
+	return headers, nil
+}
+
+// ReadBodiesRange returns the bodies, with their transactions, of up to count
+// consecutive canonical blocks from number from.
+func ReadBodiesRange(tx kv.Tx, from uint64, count int) ([]*block.Body, error) {
+	hashes, err := readCanonicalHashes(tx, from, count)
+	if err != nil {
+		return nil, err
+	}
+	bodyCursor, err := tx.Cursor(modules.BlockBody)
+	if err != nil {
+		return nil, err
+	}
+	defer bodyCursor.Close()
+	txCursor, err := tx.Cursor(modules.BlockTx)
+	if err != nil {
+		return nil, err
+	}
+	defer txCursor.Close()
+
+	bodies := make([]*block.Body, len(hashes))
+	for i, hash := range hashes {
+		number := from + uint64(i)
+		_, data, err := bodyCursor.SeekExact(modules.BlockBodyKey(number, hash))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			if bodies[i] = readAncientBody(tx, hash, number); bodies[i] == nil {
+				return nil, fmt.Errorf("body of block %d is missing", number)
+			}
+			continue
+		}
+
+		if len(data) < 12 || binary.BigEndian.Uint32(data[8:]) < 2 {
+			return nil, fmt.Errorf("invalid body of block %d", number)
+		}
+		// 1 system txn in the beginning of block, and 1 at the end
+		baseTxId, txAmount := binary.BigEndian.Uint64(data[:8])+1, binary.BigEndian.Uint32(data[8:])-2
+		body := &block.Body{Txs: make([]*transaction.Transaction, 0, txAmount)}
+		k, v, err := txCursor.Seek(modules.EncodeBlockNumber(baseTxId))
+		for ; err == nil && k != nil && uint32(len(body.Txs)) < txAmount; k, v, err = txCursor.Next() {
+			if v, err = decodeValue(modules.BlockTx, v); err != nil {
+				break
+			}
+			txn := new(transaction.Transaction)
+			if err = txn.Unmarshal(v); err != nil {
+				break
+			}
+			body.Txs = append(body.Txs, txn)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid transactions of block %d: %w", number, err)
+		}
+		if body.Verifiers, err = ReadVerifies(tx, hash, number); err != nil {
+			return nil, err
+		}
+		if body.Rewards, err = ReadRewards(tx, hash, number); err != nil {
+			return nil, err
+		}
+		bodies[i] = body
+	}
+	return bodies, nil
+}
+
+// ReadReceiptsRange returns the receipts of up to count consecutive canonical
+// blocks from number from, nil for the blocks without stored receipts.
+func ReadReceiptsRange(tx kv.Tx, from uint64, count int) ([]block.Receipts, error) {
+	hashes, err := readCanonicalHashes(tx, from, count)
+	if err != nil {
+		return nil, err
+	}
+	c, err := tx.Cursor(modules.Receipts)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	raw := make([][]byte, len(hashes))
+	k, v, err := c.Seek(modules.EncodeBlockNumber(from))
+	for ; err == nil && k != nil; k, v, err = c.Next() {
+		i := binary.BigEndian.Uint64(k) - from
+		if i >= uint64(len(raw)) {
+			break
+		}
+		raw[i], err = decodeValue(modules.Receipts, v)
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := make([]block.Receipts, len(hashes))
+	for i, data := range raw {
+		number := from + uint64(i)
+		if len(data) == 0 {
+			data = readAncientReceipts(number)
+		}
+		if len(data) == 0 {
+			continue
+		}
+		if err := receipts[i].Unmarshal(data); err != nil {
+			return nil, fmt.Errorf("invalid receipts of block %d: %w", number, err)
+		}
+	}
+	return receipts, nil
+}
@@ -40,3 +40,45 @@ func GetAccountReward(db kv.Getter, account types.Address) (*uint256.Int, error)
 	}
 	return uint256.NewInt(0).SetBytes(val), nil
 }
+
+// WriteAccountRewards sets the unpaid rewards of the accounts after block
+// number, recording the previous ones so that UnwindAccountRewards can
+// restore them.
+func WriteAccountRewards(tx kv.RwTx, number uint64, rewards map[types.Address]*uint256.Int) error {
+	for account, val := range rewards {
+		prev, err := GetAccountReward(tx, account)
+		if err != nil {
+			return err
+		}
+		key := append(modules.EncodeBlockNumber(number), account.Bytes()...)
+		if err := tx.Put(modules.RewardChangeSet, key, prev.Bytes()); err != nil {
+			return err
+		}
+		if err := PutAccountReward(tx, account, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnwindAccountRewards restores the unpaid rewards of the accounts to how they
+// were before block from, and deletes the changes of the blocks from on.
+func UnwindAccountRewards(tx kv.RwTx, from uint64) error {
+	restore := make(map[types.Address]*uint256.Int)
+	if err := tx.ForEach(modules.RewardChangeSet, modules.EncodeBlockNumber(from), func(k, v []byte) error {
+		account := types.BytesToAddress(k[8:])
+		// The first change of the account holds its reward before from.
+		if _, ok := restore[account]; !ok {
+			restore[account] = new(uint256.Int).SetBytes(v)
+		}
+		return tx.Delete(modules.RewardChangeSet, k)
+	}); err != nil {
+		return fmt.Errorf("UnwindAccountRewards: %w", err)
+	}
+	for account, val := range restore {
+		if err := PutAccountReward(tx, account, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -19,13 +19,17 @@ package rawdb
 import (
 	"context"
 	"github.com/c2h5oh/datasize"
+	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/common/cmp"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/modules"
 	"github.com/n42blockchain/N42/params"
 	"golang.org/x/sync/semaphore"
 	"runtime"
+	"testing"
 
 	log2 "github.com/ledgerwatch/log/v3"
 )
@@ -99,6 +103,85 @@ import (
 //	t.Log(m)
 //}
 
+// Tests that unwinding the unpaid rewards of the blocks after a rewind and
+// importing them again leaves the same rewards as the first import, instead
+// of crediting them twice.
+func TestUnwindAccountRewards(t *testing.T) {
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	_, tx := memdb.NewTestTx(t)
+
+	var (
+		a     = types.HexToAddress("0x0a")
+		b     = types.HexToAddress("0x0b")
+		limit = uint256.NewInt(200)
+	)
+	// The reward blocks of the chain with the rewards they earn
+	blocks := []struct {
+		number uint64
+		earned map[types.Address]uint64
+	}{
+		{10, map[types.Address]uint64{a: 100}},
+		{20, map[types.Address]uint64{a: 120, b: 50}},
+		{30, map[types.Address]uint64{a: 80, b: 70}},
+	}
+	// importFrom imports the reward blocks from number from on, adding the
+	// rewards earned to the unpaid ones and paying them once over the limit.
+	importFrom := func(from uint64) {
+		t.Helper()
+		for _, blk := range blocks {
+			if blk.number < from {
+				continue
+			}
+			unpaid := make(map[types.Address]*uint256.Int)
+			for account, earned := range blk.earned {
+				amount, err := GetAccountReward(tx, account)
+				if err != nil {
+					t.Fatalf("GetAccountReward failed: %v", err)
+				}
+				amount.AddUint64(amount, earned)
+				if amount.Cmp(limit) >= 0 {
+					amount.Clear()
+				}
+				unpaid[account] = amount
+			}
+			if err := WriteAccountRewards(tx, blk.number, unpaid); err != nil {
+				t.Fatalf("WriteAccountRewards failed: %v", err)
+			}
+		}
+	}
+	check := func(want map[types.Address]uint64) {
+		t.Helper()
+		for account, amount := range want {
+			have, err := GetAccountReward(tx, account)
+			if err != nil {
+				t.Fatalf("GetAccountReward failed: %v", err)
+			}
+			if have.Uint64() != amount {
+				t.Errorf("unpaid reward of %x: have %d, want %d", account, have.Uint64(), amount)
+			}
+		}
+	}
+	importFrom(0)
+	check(map[types.Address]uint64{a: 80, b: 120})
+
+	// Rewind to block 15, before the second reward block, and import again
+	if err := UnwindAccountRewards(tx, 16); err != nil {
+		t.Fatalf("UnwindAccountRewards failed: %v", err)
+	}
+	check(map[types.Address]uint64{a: 100, b: 0})
+	importFrom(16)
+	check(map[types.Address]uint64{a: 80, b: 120})
+
+	// Rewind to block 25, between the last two reward blocks, and import again
+	if err := UnwindAccountRewards(tx, 26); err != nil {
+		t.Fatalf("UnwindAccountRewards failed: %v", err)
+	}
+	check(map[types.Address]uint64{a: 0, b: 50})
+	importFrom(26)
+	check(map[types.Address]uint64{a: 80, b: 120})
+}
+
 func OpenDatabase() (kv.RwDB, error) {
 	var chainKv kv.RwDB
 	var err error
@@ -119,8 +202,8 @@ func OpenDatabase() (kv.RwDB, error) {
 			opts = opts.Exclusive()
 		}
 
-		modules.astInit()
-		kv.ChaindataTablesCfg = modules.astTableCfg
+		modules.AstInit()
+		kv.ChaindataTablesCfg = modules.AstTableCfg
 
 		opts = opts.MapSize(8 * datasize.TB)
 		return opts.Open()
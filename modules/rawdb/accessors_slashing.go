@@ -0,0 +1,124 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+)
+
+func signedKey(pubkey types.PublicKey, n uint64) []byte {
+	key := make([]byte, types.PublicKeyLength+8)
+	copy(key, pubkey[:])
+	binary.BigEndian.PutUint64(key[types.PublicKeyLength:], n)
+	return key
+}
+
+// WriteSignedBlock records that the validator key pubkey signed the block
+// number with the signing root root.
+func WriteSignedBlock(db kv.Putter, pubkey types.PublicKey, number uint64, root types.Hash) error {
+	if err := db.Put(modules.SignedBlocks, signedKey(pubkey, number), root[:]); err != nil {
+		return fmt.Errorf("failed to store signed block: %w", err)
+	}
+	return nil
+}
+
+// ReadSignedBlocks calls fn with the blocks signed by pubkey, lowest number
+// first, until it returns an error.
+func ReadSignedBlocks(db kv.Tx, pubkey types.PublicKey, fn func(number uint64, root types.Hash) error) error {
+	return db.ForPrefix(modules.SignedBlocks, pubkey[:], func(k, v []byte) error {
+		if len(k) != types.PublicKeyLength+8 {
+			return fmt.Errorf("invalid signed block key %x", k)
+		}
+		return fn(binary.BigEndian.Uint64(k[types.PublicKeyLength:]), types.BytesToHash(v))
+	})
+}
+
+// WriteSignedAttestation records that the validator key pubkey signed an
+// attestation from the source to the target epoch with the signing root root.
+func WriteSignedAttestation(db kv.Putter, pubkey types.PublicKey, source, target uint64, root types.Hash) error {
+	value := make([]byte, 8+types.HashLength)
+	binary.BigEndian.PutUint64(value, source)
+	copy(value[8:], root[:])
+	if err := db.Put(modules.SignedAttestations, signedKey(pubkey, target), value); err != nil {
+		return fmt.Errorf("failed to store signed attestation: %w", err)
+	}
+	return nil
+}
+
+// ReadSignedAttestations calls fn with the attestations signed by pubkey,
+// lowest target epoch first, until it returns an error.
+func ReadSignedAttestations(db kv.Tx, pubkey types.PublicKey, fn func(source, target uint64, root types.Hash) error) error {
+	return db.ForPrefix(modules.SignedAttestations, pubkey[:], func(k, v []byte) error {
+		if len(k) != types.PublicKeyLength+8 || len(v) != 8+types.HashLength {
+			return fmt.Errorf("invalid signed attestation %x", k)
+		}
+		return fn(binary.BigEndian.Uint64(v), binary.BigEndian.Uint64(k[types.PublicKeyLength:]), types.BytesToHash(v[8:]))
+	})
+}
+
+// ReadSigningKeys returns the validator keys with a signing history, sorted.
+func ReadSigningKeys(db kv.Tx) ([]types.PublicKey, error) {
+	var keys []types.PublicKey
+	seen := make(map[types.PublicKey]struct{})
+	for _, table := range []string{modules.SignedBlocks, modules.SignedAttestations} {
+		if err := db.ForEach(table, nil, func(k, _ []byte) error {
+			var pubkey types.PublicKey
+			copy(pubkey[:], k)
+			if _, ok := seen[pubkey]; !ok {
+				seen[pubkey] = struct{}{}
+				keys = append(keys, pubkey)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(keys, func(a, b types.PublicKey) int { return bytes.Compare(a[:], b[:]) })
+	return keys, nil
+}
+
+// ReadSignedBlock returns the signing root of the block number signed by
+// pubkey, ok is false if the key did not sign it.
+func ReadSignedBlock(db kv.Getter, pubkey types.PublicKey, number uint64) (root types.Hash, ok bool, err error) {
+	v, err := db.GetOne(modules.SignedBlocks, signedKey(pubkey, number))
+	if err != nil || v == nil {
+		return types.Hash{}, false, err
+	}
+	return types.BytesToHash(v), true, nil
+}
+
+// ReadLowestSignedBlock returns the lowest number of the blocks signed by
+// pubkey, ok is false if the key did not sign any.
+func ReadLowestSignedBlock(db kv.Tx, pubkey types.PublicKey) (number uint64, ok bool, err error) {
+	c, err := db.Cursor(modules.SignedBlocks)
+	if err != nil {
+		return 0, false, err
+	}
+	defer c.Close()
+	k, _, err := c.Seek(pubkey[:])
+	if err != nil || k == nil || !bytes.HasPrefix(k, pubkey[:]) || len(k) != types.PublicKeyLength+8 {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint64(k[types.PublicKeyLength:]), true, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// TableStats is the number of entries of a database table and the size in
+// bytes of its pages.
+type TableStats struct {
+	Table   string `json:"table"`
+	Entries uint64 `json:"entries"`
+	Size    uint64 `json:"size"`
+}
+
+// ReadTableStats returns the statistics of table.
+func ReadTableStats(tx kv.Tx, table string) (TableStats, error) {
+	stats := TableStats{Table: table}
+	size, err := tx.BucketSize(table)
+	if err != nil {
+		return stats, fmt.Errorf("table %s: %w", table, err)
+	}
+	stats.Size = size
+
+	c, err := tx.Cursor(table)
+	if err != nil {
+		return stats, fmt.Errorf("table %s: %w", table, err)
+	}
+	defer c.Close()
+	if stats.Entries, err = c.Count(); err != nil {
+		return stats, fmt.Errorf("table %s: %w", table, err)
+	}
+	return stats, nil
+}
+
+// ReadAllTableStats returns the statistics of every chain database table,
+// ordered by table name.
+func ReadAllTableStats(tx kv.Tx) ([]TableStats, error) {
+	tables := modules.AstTables()
+	all := make([]TableStats, 0, len(tables))
+	for _, table := range tables {
+		stats, err := ReadTableStats(tx, table)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, stats)
+	}
+	return all, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+)
+
+var (
+	headerCacheHit   = prometheus.GetOrCreateCounter("rawdb_cache_header_hit")
+	headerCacheMiss  = prometheus.GetOrCreateCounter("rawdb_cache_header_miss")
+	bodyCacheHit     = prometheus.GetOrCreateCounter("rawdb_cache_body_hit")
+	bodyCacheMiss    = prometheus.GetOrCreateCounter("rawdb_cache_body_miss")
+	receiptCacheHit  = prometheus.GetOrCreateCounter("rawdb_cache_receipt_hit")
+	receiptCacheMiss = prometheus.GetOrCreateCounter("rawdb_cache_receipt_miss")
+)
+
+// caches holds the decoded headers, canonical bodies and receipts most
+// recently read by the chain accessors, nil until SetCacheSizes is called.
+var caches atomic.Pointer[chainCaches]
+
+// chainCaches are keyed by block hash, so a block keeps its entries across
+// reorgs. Receipts are stored by number in the database and cached under the
+// hash of the canonical block they were read for. The cached values are
+// shared between readers and must not be modified.
+type chainCaches struct {
+	headers  *lru.Cache[types.Hash, *block.Header]
+	bodies   *lru.Cache[types.Hash, *block.Body]
+	receipts *lru.Cache[types.Hash, block.Receipts]
+}
+
+// SetCacheSizes makes the chain accessors cache up to the given numbers of
+// headers, bodies and receipts of blocks, a size of zero disables that cache.
+func SetCacheSizes(headers, bodies, receipts int) {
+	c := new(chainCaches)
+	if headers > 0 {
+		c.headers, _ = lru.New[types.Hash, *block.Header](headers)
+	}
+	if bodies > 0 {
+		c.bodies, _ = lru.New[types.Hash, *block.Body](bodies)
+	}
+	if receipts > 0 {
+		c.receipts, _ = lru.New[types.Hash, block.Receipts](receipts)
+	}
+	caches.Store(c)
+}
+
+// EvictCachedBlock drops the cached header, body and receipts of a block,
+// such as one reorged out of the chain.
+func EvictCachedBlock(hash types.Hash) {
+	c := caches.Load()
+	if c == nil {
+		return
+	}
+	if c.headers != nil {
+		c.headers.Remove(hash)
+	}
+	if c.bodies != nil {
+		c.bodies.Remove(hash)
+	}
+	if c.receipts != nil {
+		c.receipts.Remove(hash)
+	}
+}
+
+// PurgeCaches drops every cached entry. It must be called once blocks have
+// been deleted from the database.
+func PurgeCaches() {
+	c := caches.Load()
+	if c == nil {
+		return
+	}
+	if c.headers != nil {
+		c.headers.Purge()
+	}
+	if c.bodies != nil {
+		c.bodies.Purge()
+	}
+	if c.receipts != nil {
+		c.receipts.Purge()
+	}
+}
+
+// cacheGet looks hash up in cache, counting the hit or miss. It always misses
+// if cache is disabled.
+func cacheGet[V any](cache *lru.Cache[types.Hash, V], hash types.Hash, hit, miss prometheus.Counter) (V, bool) {
+	if cache == nil {
+		var zero V
+		return zero, false
+	}
+	v, ok := cache.Get(hash)
+	if ok {
+		hit.Inc()
+	} else {
+		miss.Inc()
+	}
+	return v, ok
+}
+
+func cachedHeader(hash types.Hash) (*block.Header, bool) {
+	if c := caches.Load(); c != nil {
+		return cacheGet(c.headers, hash, headerCacheHit, headerCacheMiss)
+	}
+	return nil, false
+}
+
+func cacheHeader(hash types.Hash, header *block.Header) {
+	if c := caches.Load(); c != nil && c.headers != nil {
+		c.headers.Add(hash, header)
+	}
+}
+
+func cachedBody(hash types.Hash) (*block.Body, bool) {
+	if c := caches.Load(); c != nil {
+		return cacheGet(c.bodies, hash, bodyCacheHit, bodyCacheMiss)
+	}
+	return nil, false
+}
+
+func cacheBody(hash types.Hash, body *block.Body) {
+	if c := caches.Load(); c != nil && c.bodies != nil {
+		c.bodies.Add(hash, body)
+	}
+}
+
+func cachedReceipts(hash types.Hash) (block.Receipts, bool) {
+	if c := caches.Load(); c != nil {
+		return cacheGet(c.receipts, hash, receiptCacheHit, receiptCacheMiss)
+	}
+	return nil, false
+}
+
+func cacheReceipts(hash types.Hash, receipts block.Receipts) {
+	if c := caches.Load(); c != nil && c.receipts != nil {
+		c.receipts.Add(hash, receipts)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+)
+
+// The kinds of chain issues reported by CheckChain.
+const (
+	IssueMissingCanonical = "missing-canonical" // no canonical hash for the number
+	IssueMissingHeader    = "missing-header"    // the canonical header is not stored
+	IssueInvalidHeader    = "invalid-header"    // the header does not decode or match its number and hash
+	IssueBrokenLink       = "broken-link"       // the parent hash is not the previous canonical hash
+	IssueMissingBody      = "missing-body"      // the canonical body is not stored
+	IssueMissingReceipts  = "missing-receipts"  // the receipts are missing or do not match the transactions
+	IssueHeaderNumber     = "header-number"     // the hash to number mapping is missing or wrong
+	IssueTxLookup         = "tx-lookup"         // transaction lookup entries are missing or wrong
+)
+
+// ChainIssue is an inconsistency of the canonical chain in the database.
+type ChainIssue struct {
+	Number   uint64     `json:"number"`
+	Hash     types.Hash `json:"hash"`
+	Kind     string     `json:"kind"`
+	Detail   string     `json:"detail"`
+	Repaired bool       `json:"repaired"`
+}
+
+// Repairable reports whether RepairChainIssue can fix the issue, which holds
+// for the indexes derived from the stored blocks only.
+func (i ChainIssue) Repairable() bool {
+	return i.Kind == IssueHeaderNumber || i.Kind == IssueTxLookup
+}
+
+// BlockBroken reports whether the block itself is missing or broken, rather
+// than its receipts or an index of it.
+func (i ChainIssue) BlockBroken() bool {
+	switch i.Kind {
+	case IssueMissingCanonical, IssueMissingHeader, IssueInvalidHeader, IssueBrokenLink, IssueMissingBody:
+		return true
+	}
+	return false
+}
+
+// CheckChain verifies the canonical blocks from through to: every number has a
+// canonical hash whose header is stored, decodes to that number and hash and
+// links to the previous canonical block, the body is stored, the receipts
+// match the transactions where receipts are kept, and the hash to number and
+// transaction lookup indexes point at the block.
+func CheckChain(tx kv.Tx, from, to uint64) ([]ChainIssue, error) {
+	receiptsFrom, err := ReceiptsAvailableFrom(tx)
+	if err != nil {
+		return nil, err
+	}
+	txIndexFrom, err := ReadPruneProgress(tx, PruneTxIndex)
+	if err != nil {
+		return nil, err
+	}
+	var parent types.Hash
+	if from > 0 {
+		if parent, err = ReadCanonicalHash(tx, from-1); err != nil {
+			return nil, err
+		}
+	}
+
+	var issues []ChainIssue
+	for number := from; number <= to; number++ {
+		hash, err := ReadCanonicalHash(tx, number)
+		if err != nil {
+			return issues, err
+		}
+		if hash == (types.Hash{}) {
+			issues = append(issues, ChainIssue{Number: number, Kind: IssueMissingCanonical})
+			parent = types.Hash{}
+			continue
+		}
+		issues = append(issues, checkCanonicalBlock(tx, hash, number, parent, number >= receiptsFrom, number >= txIndexFrom)...)
+		parent = hash
+	}
+	return issues, nil
+}
+
+// checkCanonicalBlock verifies the canonical block number with hash, whose
+// parent is the canonical block with hash parent, or unknown if it is empty.
+// Receipts and transaction lookup entries are only checked if they are kept.
+func checkCanonicalBlock(tx kv.Tx, hash types.Hash, number uint64, parent types.Hash, hasReceipts, hasTxLookup bool) []ChainIssue {
+	var issues []ChainIssue
+	report := func(kind, format string, args ...interface{}) {
+		issues = append(issues, ChainIssue{Number: number, Hash: hash, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if data := ReadHeaderRAW(tx, hash, number); len(data) == 0 {
+		report(IssueMissingHeader, "")
+	} else if header, err := decodeHeader(data); err != nil {
+		report(IssueInvalidHeader, "%v", err)
+	} else if header.Number64().Uint64() != number || header.Hash() != hash {
+		report(IssueInvalidHeader, "header of block %d with hash %s", header.Number64().Uint64(), header.Hash())
+	} else if number > 0 && parent != (types.Hash{}) && header.ParentHash != parent {
+		report(IssueBrokenLink, "parent %s, canonical block %d is %s", header.ParentHash, number-1, parent)
+	}
+
+	if stored := ReadHeaderNumber(tx, hash); stored == nil {
+		report(IssueHeaderNumber, "missing")
+	} else if *stored != number {
+		report(IssueHeaderNumber, "points at block %d", *stored)
+	}
+
+	body := ReadCanonicalBodyWithTransactions(tx, hash, number)
+	if body == nil {
+		report(IssueMissingBody, "")
+		return issues
+	}
+
+	if hasTxLookup {
+		var wrong int
+		for i, txn := range body.Txs {
+			entry, err := ReadTxLookup(tx, txn.Hash())
+			if err != nil || entry == nil || entry.BlockNumber != number || entry.HasIndex && entry.Index != uint64(i) {
+				wrong++
+			}
+		}
+		if wrong > 0 {
+			report(IssueTxLookup, "%d of %d transactions", wrong, len(body.Txs))
+		}
+	}
+
+	if hasReceipts && len(body.Txs) > 0 {
+		if receipts := ReadRawReceipts(tx, number); receipts == nil {
+			report(IssueMissingReceipts, "")
+		} else if len(receipts) != len(body.Txs) {
+			report(IssueMissingReceipts, "%d receipts for %d transactions", len(receipts), len(body.Txs))
+		}
+	}
+	return issues
+}
+
+// RepairChainIssue rewrites the index entries of the block of issue from the
+// stored block. The issue must be repairable.
+func RepairChainIssue(tx kv.RwTx, issue ChainIssue) error {
+	switch issue.Kind {
+	case IssueHeaderNumber:
+		return WriteHeaderNumber(tx, issue.Hash, issue.Number)
+	case IssueTxLookup:
+		header := ReadHeader(tx, issue.Hash, issue.Number)
+		body := ReadCanonicalBodyWithTransactions(tx, issue.Hash, issue.Number)
+		if header == nil || body == nil {
+			return fmt.Errorf("block %d is missing", issue.Number)
+		}
+		WriteTxLookupEntries(tx, block.NewBlockFromStorage(issue.Hash, header, body))
+		return nil
+	}
+	return fmt.Errorf("%s issues cannot be repaired", issue.Kind)
+}
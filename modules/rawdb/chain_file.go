@@ -0,0 +1,97 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/internal/avm/rlp"
+	"google.golang.org/protobuf/proto"
+)
+
+// A chain file is a stream of consecutive canonical blocks, each an RLP list
+// of the header and the body in the encodings of the freezer. Unlike an era
+// file it has no index and no receipts, so it can be written to and read from
+// a pipe, for instance through gzip.
+type chainFileBlock struct {
+	Header []byte
+	Body   []byte
+}
+
+// ExportChain writes the canonical blocks from through to to w as a chain
+// file.
+func ExportChain(tx kv.Tx, w io.Writer, from, to uint64) error {
+	bw := bufio.NewWriter(w)
+	for start := from; start <= to; start += eraReadBatch {
+		count := int(min(to-start+1, eraReadBatch))
+		headers, err := ReadHeadersRange(tx, start, count)
+		if err != nil {
+			return err
+		}
+		bodies, err := ReadBodiesRange(tx, start, count)
+		if err != nil {
+			return err
+		}
+		if n := min(len(headers), len(bodies)); n < count {
+			return fmt.Errorf("block %d is missing", start+uint64(n))
+		}
+		for i := 0; i < count; i++ {
+			var entry chainFileBlock
+			if entry.Header, err = headers[i].Marshal(); err != nil {
+				return err
+			}
+			if entry.Body, err = proto.Marshal(bodies[i].ToProtoMessage()); err != nil {
+				return err
+			}
+			if err := rlp.Encode(bw, &entry); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ChainFileReader reads the blocks of a chain file.
+type ChainFileReader struct {
+	stream *rlp.Stream
+}
+
+// NewChainFileReader returns a reader of the chain file r.
+func NewChainFileReader(r io.Reader) *ChainFileReader {
+	return &ChainFileReader{stream: rlp.NewStream(bufio.NewReader(r), 0)}
+}
+
+// Next returns the next block of the file, or io.EOF at its end.
+func (c *ChainFileReader) Next() (*block.Block, error) {
+	var entry chainFileBlock
+	if err := c.stream.Decode(&entry); err != nil {
+		return nil, err
+	}
+	header := new(block.Header)
+	if err := header.Unmarshal(entry.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	body, err := decodeBody(entry.Body)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: invalid body: %w", header.Number.Uint64(), err)
+	}
+	return block.NewBlockFromStorage(header.Hash(), header, body), nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// The compressions of the values of a table, recorded in the database
+// metadata. Tables without a recorded compression are not compressed.
+const (
+	compressionNone byte = 0
+	compressionZstd byte = 1
+)
+
+// compressibleTables are the tables holding the transactions and receipts of
+// blocks, which make up most of the historical data.
+var compressibleTables = []string{modules.BlockTx, modules.Receipts, modules.Log}
+
+// zstdTables is the set of tables whose values are zstd compressed in the
+// opened database, nil until SetupCompression is called.
+var zstdTables atomic.Pointer[map[string]bool]
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+}
+
+func compressionKey(table string) []byte {
+	return []byte("Compression-" + table)
+}
+
+// SetupCompression makes the chain accessors compress or decompress the
+// values of the tables holding transactions and receipts as recorded in db.
+// If enable is set, the compression is recorded for the tables that are still
+// empty: the values of a table are either all compressed or none are, so
+// enabling it on an existing database has no effect.
+func SetupCompression(ctx context.Context, db kv.RwDB, enable bool) error {
+	compressed := make(map[string]bool)
+	var unrecorded []string
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		for _, table := range compressibleTables {
+			data, err := tx.GetOne(modules.DatabaseInfo, compressionKey(table))
+			if err != nil {
+				return err
+			}
+			if len(data) != 1 {
+				unrecorded = append(unrecorded, table)
+				continue
+			}
+			switch data[0] {
+			case compressionNone:
+			case compressionZstd:
+				compressed[table] = true
+			default:
+				return fmt.Errorf("unknown compression %d of table %s", data[0], table)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if enable && len(unrecorded) > 0 {
+		if err := db.Update(ctx, func(tx kv.RwTx) error {
+			for _, table := range unrecorded {
+				empty, err := isTableEmpty(tx, table)
+				if err != nil {
+					return err
+				}
+				if !empty {
+					log.Warn("Not compressing table holding uncompressed values", "table", table)
+					continue
+				}
+				if err := tx.Put(modules.DatabaseInfo, compressionKey(table), []byte{compressionZstd}); err != nil {
+					return err
+				}
+				compressed[table] = true
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(compressed) > 0 {
+		initZstd()
+	}
+	zstdTables.Store(&compressed)
+	return nil
+}
+
+func isTableEmpty(tx kv.Tx, table string) (bool, error) {
+	c, err := tx.Cursor(table)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	k, _, err := c.First()
+	return k == nil, err
+}
+
+func isCompressed(table string) bool {
+	tables := zstdTables.Load()
+	return tables != nil && (*tables)[table]
+}
+
+// encodeValue returns data as stored in table.
+func encodeValue(table string, data []byte) []byte {
+	if !isCompressed(table) {
+		return data
+	}
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)))
+}
+
+// decodeValue returns the value data read from table as it was written.
+func decodeValue(table string, data []byte) ([]byte, error) {
+	if !isCompressed(table) || len(data) == 0 {
+		return data, nil
+	}
+	decoded, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s value: %w", table, err)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/n42blockchain/N42/modules"
+)
+
+func newCompressionTestDB(t *testing.T) kv.RwDB {
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	t.Cleanup(func() { zstdTables.Store(nil) })
+	return memdb.NewTestDB(t)
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	db := newCompressionTestDB(t)
+	if err := SetupCompression(context.Background(), db, true); err != nil {
+		t.Fatalf("SetupCompression failed: %v", err)
+	}
+	data := bytes.Repeat([]byte("receipt"), 100)
+	for _, table := range compressibleTables {
+		enc := encodeValue(table, data)
+		if len(enc) >= len(data) {
+			t.Errorf("%s: value of %d bytes stored in %d", table, len(data), len(enc))
+		}
+		if dec, err := decodeValue(table, enc); err != nil || !bytes.Equal(dec, data) {
+			t.Errorf("%s: decoded %x, %v", table, dec, err)
+		}
+		if _, err := decodeValue(table, data); err == nil {
+			t.Errorf("%s: decoded an uncompressed value", table)
+		}
+		if dec, err := decodeValue(table, nil); err != nil || len(dec) != 0 {
+			t.Errorf("%s: decoded empty value to %x, %v", table, dec, err)
+		}
+	}
+	// Other tables are never compressed
+	if enc := encodeValue(modules.Headers, data); !bytes.Equal(enc, data) {
+		t.Error("compressed a header")
+	}
+}
+
+func TestCompressionRecorded(t *testing.T) {
+	db := newCompressionTestDB(t)
+	ctx := context.Background()
+
+	// An uncompressed table holding values stays uncompressed
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		return tx.Put(modules.Receipts, modules.EncodeBlockNumber(1), []byte{1})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupCompression(ctx, db, true); err != nil {
+		t.Fatalf("SetupCompression failed: %v", err)
+	}
+	if isCompressed(modules.Receipts) {
+		t.Error("compressed a table holding uncompressed values")
+	}
+	if !isCompressed(modules.BlockTx) || !isCompressed(modules.Log) {
+		t.Error("empty tables not compressed")
+	}
+
+	// The recorded compression applies whatever the setting
+	if err := SetupCompression(ctx, db, false); err != nil {
+		t.Fatalf("SetupCompression failed: %v", err)
+	}
+	if isCompressed(modules.Receipts) || !isCompressed(modules.BlockTx) || !isCompressed(modules.Log) {
+		t.Error("compression changed after disabling it")
+	}
+
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		return tx.Put(modules.DatabaseInfo, compressionKey(modules.Receipts), []byte{7})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupCompression(ctx, db, false); err == nil {
+		t.Error("accepted an unknown compression")
+	}
+}
+
+func TestCompressedChain(t *testing.T) {
+	db := newCompressionTestDB(t)
+	if err := SetupCompression(context.Background(), db, true); err != nil {
+		t.Fatalf("SetupCompression failed: %v", err)
+	}
+	tx, err := db.BeginRw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	blocks := writeTestChain(t, tx, 3)
+	for _, b := range blocks {
+		number := b.Number64().Uint64()
+		raw, err := tx.GetOne(modules.Receipts, modules.EncodeBlockNumber(number))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := zstdDecoder.DecodeAll(raw, nil); err != nil {
+			t.Errorf("block %d: receipts not compressed: %v", number, err)
+		}
+		receipts := ReadRawReceipts(tx, number)
+		if len(receipts) != 1 || receipts[0].CumulativeGasUsed != number {
+			t.Errorf("block %d: receipts %v", number, receipts)
+		}
+		if read := ReadBlock(tx, b.Hash(), number); read == nil || read.Hash() != b.Hash() {
+			t.Errorf("block %d: read %v", number, read)
+		}
+	}
+}
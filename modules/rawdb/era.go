@@ -0,0 +1,266 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/api/protocol/types_pb"
+	"github.com/n42blockchain/N42/common/block"
+	"google.golang.org/protobuf/proto"
+)
+
+// An era file holds a range of consecutive canonical blocks. It is a list of
+// entries, each made of an 8 byte header - the type (2 bytes), the length of
+// the data (4 bytes) and 2 reserved bytes, little endian - followed by the
+// data. The file starts with a version entry, then holds the header, body and
+// receipts entries of every block, in the encodings of the freezer, and ends
+// with the block index: the number of the first block, the offset of the
+// header entry of every block and the number of blocks, 8 bytes each.
+const (
+	eraVersion    uint16 = 0x3265
+	eraHeader     uint16 = 0x03
+	eraBody       uint16 = 0x04
+	eraReceipts   uint16 = 0x05
+	eraBlockIndex uint16 = 0x3266
+
+	eraEntryHeaderSize = 8
+)
+
+// EraWriter writes an era file.
+type EraWriter struct {
+	w       *bufio.Writer
+	offset  int64
+	start   uint64
+	offsets []int64
+}
+
+// NewEraWriter starts an era file holding the blocks from number start on.
+func NewEraWriter(w io.Writer, start uint64) (*EraWriter, error) {
+	e := &EraWriter{w: bufio.NewWriter(w), start: start}
+	if err := e.writeEntry(eraVersion, nil); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Add appends the next block, with its header, body and receipts in their
+// freezer encodings.
+func (e *EraWriter) Add(header, body, receipts []byte) error {
+	e.offsets = append(e.offsets, e.offset)
+	for _, entry := range []struct {
+		typ  uint16
+		data []byte
+	}{{eraHeader, header}, {eraBody, body}, {eraReceipts, receipts}} {
+		if err := e.writeEntry(entry.typ, entry.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish writes the block index and flushes the file.
+func (e *EraWriter) Finish() error {
+	index := make([]byte, 8*(len(e.offsets)+2))
+	binary.LittleEndian.PutUint64(index, e.start)
+	for i, offset := range e.offsets {
+		binary.LittleEndian.PutUint64(index[8*(i+1):], uint64(offset))
+	}
+	binary.LittleEndian.PutUint64(index[8*(len(e.offsets)+1):], uint64(len(e.offsets)))
+	if err := e.writeEntry(eraBlockIndex, index); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *EraWriter) writeEntry(typ uint16, data []byte) error {
+	var header [eraEntryHeaderSize]byte
+	binary.LittleEndian.PutUint16(header[0:], typ)
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(data)))
+	if _, err := e.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	e.offset += int64(eraEntryHeaderSize + len(data))
+	return nil
+}
+
+// EraReader reads the blocks of an era file.
+type EraReader struct {
+	r       io.ReaderAt
+	start   uint64
+	offsets []int64
+}
+
+// NewEraReader opens the era file r of size bytes and reads its block index.
+func NewEraReader(r io.ReaderAt, size int64) (*EraReader, error) {
+	var buf [8]byte
+	if size < eraEntryHeaderSize+16 {
+		return nil, fmt.Errorf("era file too short")
+	}
+	if _, err := r.ReadAt(buf[:], size-8); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(buf[:])
+	indexSize := int64(8 * (count + 2))
+	if count > uint64(size)/8 || indexSize+eraEntryHeaderSize > size {
+		return nil, fmt.Errorf("invalid era block index")
+	}
+	typ, index, err := readEraEntry(r, size-indexSize-eraEntryHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	if typ != eraBlockIndex || int64(len(index)) != indexSize {
+		return nil, fmt.Errorf("invalid era block index")
+	}
+	e := &EraReader{r: r, start: binary.LittleEndian.Uint64(index), offsets: make([]int64, count)}
+	for i := range e.offsets {
+		e.offsets[i] = int64(binary.LittleEndian.Uint64(index[8*(i+1):]))
+	}
+	return e, nil
+}
+
+// Start returns the number of the first block of the file.
+func (e *EraReader) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the file.
+func (e *EraReader) Count() uint64 {
+	return uint64(len(e.offsets))
+}
+
+// ReadBlock returns block number and its receipts.
+func (e *EraReader) ReadBlock(number uint64) (*block.Block, block.Receipts, error) {
+	if number < e.start || number-e.start >= e.Count() {
+		return nil, nil, fmt.Errorf("block %d not in era file", number)
+	}
+	offset := e.offsets[number-e.start]
+	var entries [3][]byte
+	for i, want := range []uint16{eraHeader, eraBody, eraReceipts} {
+		typ, data, err := readEraEntry(e.r, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		if typ != want {
+			return nil, nil, fmt.Errorf("block %d: unexpected era entry type %#x, want %#x", number, typ, want)
+		}
+		entries[i] = data
+		offset += int64(eraEntryHeaderSize + len(data))
+	}
+
+	header := new(block.Header)
+	if err := header.Unmarshal(entries[0]); err != nil {
+		return nil, nil, fmt.Errorf("block %d: invalid header: %w", number, err)
+	}
+	if header.Number.Uint64() != number {
+		return nil, nil, fmt.Errorf("block %d: header has number %d", number, header.Number.Uint64())
+	}
+	body, err := decodeBody(entries[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("block %d: invalid body: %w", number, err)
+	}
+	var receipts block.Receipts
+	if len(entries[2]) > 0 {
+		if err := receipts.Unmarshal(entries[2]); err != nil {
+			return nil, nil, fmt.Errorf("block %d: invalid receipts: %w", number, err)
+		}
+	}
+	return block.NewBlockFromStorage(header.Hash(), header, body), receipts, nil
+}
+
+func readEraEntry(r io.ReaderAt, offset int64) (uint16, []byte, error) {
+	var header [eraEntryHeaderSize]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[2:]))
+	if _, err := r.ReadAt(data, offset+eraEntryHeaderSize); err != nil {
+		return 0, nil, err
+	}
+	return binary.LittleEndian.Uint16(header[0:]), data, nil
+}
+
+// eraReadBatch is the number of blocks ExportEra reads from the database at
+// once.
+const eraReadBatch = 256
+
+// ExportEra writes the canonical blocks from through to with their receipts
+// to w as an era file.
+func ExportEra(tx kv.Tx, w io.Writer, from, to uint64) error {
+	e, err := NewEraWriter(w, from)
+	if err != nil {
+		return err
+	}
+	for start := from; start <= to; start += eraReadBatch {
+		count := int(min(to-start+1, eraReadBatch))
+		headers, err := ReadHeadersRange(tx, start, count)
+		if err != nil {
+			return err
+		}
+		bodies, err := ReadBodiesRange(tx, start, count)
+		if err != nil {
+			return err
+		}
+		receipts, err := ReadReceiptsRange(tx, start, count)
+		if err != nil {
+			return err
+		}
+		if n := min(len(headers), len(bodies), len(receipts)); n < count {
+			return fmt.Errorf("block %d is missing", start+uint64(n))
+		}
+		for i := 0; i < count; i++ {
+			header, err := headers[i].Marshal()
+			if err != nil {
+				return err
+			}
+			body, err := proto.Marshal(bodies[i].ToProtoMessage())
+			if err != nil {
+				return err
+			}
+			var rs []byte
+			if receipts[i] != nil {
+				if rs, err = receipts[i].Marshal(); err != nil {
+					return err
+				}
+			}
+			if err := e.Add(header, body, rs); err != nil {
+				return err
+			}
+		}
+	}
+	return e.Finish()
+}
+
+// decodeBody decodes a body in its freezer encoding.
+func decodeBody(data []byte) (*block.Body, error) {
+	pbBody := new(types_pb.Body)
+	if err := proto.Unmarshal(data, pbBody); err != nil {
+		return nil, err
+	}
+	body := new(block.Body)
+	if err := body.FromProtoMessage(pbBody); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
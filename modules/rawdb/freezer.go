@@ -0,0 +1,346 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync/atomic"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules"
+	"google.golang.org/protobuf/proto"
+)
+
+// The tables of the freezer. Headers are kept in their database encoding,
+// bodies as the protobuf encoding of the full body with its transactions,
+// verifiers and rewards, and receipts in their database encoding.
+const (
+	freezerHeaderTable  = "headers"
+	freezerBodyTable    = "bodies"
+	freezerReceiptTable = "receipts"
+)
+
+var freezerTables = []string{freezerHeaderTable, freezerBodyTable, freezerReceiptTable}
+
+// freezer is the freezer the chain accessors fall back to for canonical
+// blocks no longer in the database.
+var freezer atomic.Pointer[Freezer]
+
+// SetFreezer makes the chain accessors read the blocks frozen in f, or stop
+// reading a freezer if f is nil.
+func SetFreezer(f *Freezer) {
+	freezer.Store(f)
+}
+
+// Freezer keeps the headers, bodies and receipts of old canonical blocks in
+// append-only flat files outside of the database. The genesis block stays in
+// the database, so item i of every table holds block i+1.
+type Freezer struct {
+	dir    string
+	tables map[string]*freezerTable
+	frozen atomic.Uint64 // number of the first block not frozen
+}
+
+// OpenFreezer opens or creates the freezer in dir.
+func OpenFreezer(dir string) (*Freezer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &Freezer{dir: dir, tables: make(map[string]*freezerTable)}
+	for _, name := range freezerTables {
+		table, err := openFreezerTable(dir, name)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open freezer table %s: %w", name, err)
+		}
+		f.tables[name] = table
+	}
+	// A crash may leave the tables with different lengths, keep the blocks
+	// frozen in all of them.
+	items := f.tables[freezerHeaderTable].Items()
+	for _, table := range f.tables {
+		items = min(items, table.Items())
+	}
+	for _, table := range f.tables {
+		if err := table.Truncate(items); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	f.frozen.Store(items + 1)
+	return f, nil
+}
+
+// OpenFreezerReadonly opens the freezer in dir for reading, while the node
+// owning it may be running. The blocks frozen after it is opened are not
+// visible.
+func OpenFreezerReadonly(dir string) (*Freezer, error) {
+	f := &Freezer{dir: dir, tables: make(map[string]*freezerTable)}
+	items := uint64(math.MaxUint64)
+	for _, name := range freezerTables {
+		table, err := openFreezerTableReadonly(dir, name)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open freezer table %s: %w", name, err)
+		}
+		f.tables[name] = table
+		items = min(items, table.Items())
+	}
+	f.frozen.Store(items + 1)
+	return f, nil
+}
+
+// Frozen returns the number of the first block not in the freezer.
+func (f *Freezer) Frozen() uint64 {
+	return f.frozen.Load()
+}
+
+// Retrieve returns the item of block number from the table kind.
+func (f *Freezer) Retrieve(kind string, number uint64) ([]byte, error) {
+	table, ok := f.tables[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown freezer table %s", kind)
+	}
+	if number == 0 || number >= f.Frozen() {
+		return nil, errOutOfBounds
+	}
+	return table.Retrieve(number - 1)
+}
+
+// append adds the next block to the freezer.
+func (f *Freezer) append(number uint64, header, body, receipts []byte) error {
+	if number != f.Frozen() {
+		return fmt.Errorf("freezing block %d, want %d", number, f.Frozen())
+	}
+	items := map[string][]byte{
+		freezerHeaderTable:  header,
+		freezerBodyTable:    body,
+		freezerReceiptTable: receipts,
+	}
+	for name, item := range items {
+		if err := f.tables[name].Append(number-1, item); err != nil {
+			return err
+		}
+	}
+	f.frozen.Store(number + 1)
+	return nil
+}
+
+// Sync flushes the tables to disk.
+func (f *Freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CopyTo writes a copy of the frozen blocks to a new freezer in dir while
+// blocks may still be appended, reading the files through wrap.
+func (f *Freezer) CopyTo(dir string, wrap func(io.Reader) io.Reader) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range freezerTables {
+		if err := f.tables[name].CopyTo(dir, wrap); err != nil {
+			return fmt.Errorf("failed to copy freezer table %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the tables of the freezer.
+func (f *Freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FreezeBlocks moves up to limit canonical blocks below to from the database
+// to f, and returns the number of blocks moved. The blocks are synced to disk
+// before they are deleted from the database, so a crash before tx is committed
+// leaves them in both. Headers, bodies, transactions, verifiers, rewards and
+// receipts are moved, forks at the frozen heights are deleted, and hashes,
+// total difficulties, senders and logs stay in the database.
+func FreezeBlocks(tx kv.RwTx, f *Freezer, to uint64, limit int) (int, error) {
+	from := f.Frozen()
+	var frozen int
+	for number := from; number < to && frozen < limit; number++ {
+		header, body, receipts, err := readCanonicalBlockRAW(tx, number)
+		if err != nil {
+			return frozen, err
+		}
+		if err := f.append(number, header, body, receipts); err != nil {
+			return frozen, err
+		}
+		frozen++
+	}
+	if frozen == 0 {
+		return 0, nil
+	}
+	if err := f.Sync(); err != nil {
+		return frozen, err
+	}
+	for number := from; number < from+uint64(frozen); number++ {
+		if err := deleteFrozenBlock(tx, number); err != nil {
+			return frozen, err
+		}
+	}
+	return frozen, nil
+}
+
+// readCanonicalBlockRAW returns the header, body and receipts of canonical
+// block number in their freezer encodings.
+func readCanonicalBlockRAW(db kv.Getter, number uint64) (header, body, receipts []byte, err error) {
+	hash, err := ReadCanonicalHash(db, number)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if hash == (types.Hash{}) {
+		return nil, nil, nil, fmt.Errorf("canonical hash of block %d is missing", number)
+	}
+	if header = ReadHeaderRAW(db, hash, number); len(header) == 0 {
+		return nil, nil, nil, fmt.Errorf("header of block %d is missing", number)
+	}
+	b := ReadCanonicalBodyWithTransactions(db, hash, number)
+	if b == nil {
+		return nil, nil, nil, fmt.Errorf("body of block %d is missing", number)
+	}
+	if body, err = proto.Marshal(b.ToProtoMessage()); err != nil {
+		return nil, nil, nil, err
+	}
+	if receipts, err = db.GetOne(modules.Receipts, modules.EncodeBlockNumber(number)); err != nil {
+		return nil, nil, nil, err
+	}
+	if receipts, err = decodeValue(modules.Receipts, receipts); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(receipts) == 0 {
+		receipts = readAncientReceipts(number)
+	}
+	return header, body, receipts, nil
+}
+
+// deleteFrozenBlock deletes every block at height number from the database,
+// together with its transactions and receipts.
+func deleteFrozenBlock(tx kv.RwTx, number uint64) error {
+	var keys [][]byte
+	if err := tx.ForPrefix(modules.Headers, modules.EncodeBlockNumber(number), func(k, _ []byte) error {
+		keys = append(keys, types.CopyBytes(k))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		b, err := ReadBodyForStorageByKey(tx, k)
+		if err != nil {
+			return err
+		}
+		if b != nil {
+			for id := b.BaseTxId; id < b.BaseTxId+uint64(b.TxAmount); id++ {
+				if err := tx.Delete(modules.BlockTx, modules.EncodeBlockNumber(id)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, table := range []string{modules.Headers, modules.BlockBody, modules.BlockVerify, modules.BlockRewards} {
+			if err := tx.Delete(table, k); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Delete(modules.Receipts, modules.EncodeBlockNumber(number))
+}
+
+// readAncient returns the item of block number from the table kind of the
+// freezer, or nil if the block is not frozen or hash is not the canonical hash
+// at its height.
+func readAncient(db kv.Getter, kind string, hash types.Hash, number uint64) []byte {
+	f := freezer.Load()
+	if f == nil || number == 0 || number >= f.Frozen() {
+		return nil
+	}
+	if canonical, err := ReadCanonicalHash(db, number); err != nil || canonical != hash {
+		return nil
+	}
+	data, err := f.Retrieve(kind, number)
+	if err != nil {
+		log.Error("Failed to read frozen block", "table", kind, "number", number, "err", err)
+		return nil
+	}
+	return data
+}
+
+// hasAncient reports whether block number with hash is in the freezer.
+func hasAncient(db kv.Getter, hash types.Hash, number uint64) bool {
+	f := freezer.Load()
+	if f == nil || number == 0 || number >= f.Frozen() {
+		return false
+	}
+	canonical, err := ReadCanonicalHash(db, number)
+	return err == nil && canonical == hash
+}
+
+// readAncientBody returns the body of block number with hash from the
+// freezer. A block without transactions has an empty record, so the presence
+// of the body is told by the freezer rather than by its length.
+func readAncientBody(db kv.Getter, hash types.Hash, number uint64) *block.Body {
+	f := freezer.Load()
+	if f == nil || !hasAncient(db, hash, number) {
+		return nil
+	}
+	data, err := f.Retrieve(freezerBodyTable, number)
+	if err != nil {
+		log.Error("Failed to read frozen block", "table", freezerBodyTable, "number", number, "err", err)
+		return nil
+	}
+	body, err := decodeBody(data)
+	if err != nil {
+		log.Error("Invalid frozen block body", "hash", hash, "err", err)
+		return nil
+	}
+	return body
+}
+
+// readAncientReceipts returns the receipts of block number from the freezer.
+func readAncientReceipts(number uint64) []byte {
+	f := freezer.Load()
+	if f == nil {
+		return nil
+	}
+	data, err := f.Retrieve(freezerReceiptTable, number)
+	if err != nil {
+		if !errors.Is(err, errOutOfBounds) {
+			log.Error("Failed to read frozen receipts", "number", number, "err", err)
+		}
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// indexEntrySize is the size of an index entry: the end offset of the item
+// in the data file.
+const indexEntrySize = 8
+
+var errOutOfBounds = errors.New("out of bounds")
+
+// freezerTable is an append-only list of items kept in two files: name.dat
+// holds the items back to back and name.idx the end offset of every item in
+// name.dat.
+type freezerTable struct {
+	name  string
+	data  *os.File
+	index *os.File
+
+	mu    sync.RWMutex
+	items uint64 // number of items in the table
+	size  uint64 // size of the data file
+}
+
+// openFreezerTable opens or creates the table name in dir. Items appended
+// but not completely synced before a crash are dropped.
+func openFreezerTable(dir, name string) (*freezerTable, error) {
+	data, err := os.OpenFile(filepath.Join(dir, name+".dat"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(filepath.Join(dir, name+".idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	t := &freezerTable{name: name, data: data, index: index}
+	if err := t.repair(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// openFreezerTableReadonly opens the table name in dir for reading, while
+// another process may be appending to it. Items not completely written yet
+// are left out.
+func openFreezerTableReadonly(dir, name string) (*freezerTable, error) {
+	data, err := os.Open(filepath.Join(dir, name+".dat"))
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.Open(filepath.Join(dir, name+".idx"))
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	t := &freezerTable{name: name, data: data, index: index}
+	if t.items, t.size, err = t.complete(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// repair drops the partial index entry and the items whose data is missing,
+// and truncates the data file after the last item.
+func (t *freezerTable) repair() error {
+	items, end, err := t.complete()
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.items, t.size = items, end
+	return nil
+}
+
+// complete returns the number of items whose index entry and data are both
+// in the files, and the end offset of the last of them.
+func (t *freezerTable) complete() (items, end uint64, err error) {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	items = uint64(stat.Size()) / indexEntrySize
+	if stat, err = t.data.Stat(); err != nil {
+		return 0, 0, err
+	}
+	dataSize := uint64(stat.Size())
+
+	for ; items > 0; items-- {
+		if end, err = t.offset(items - 1); err != nil {
+			return 0, 0, err
+		}
+		if end <= dataSize {
+			return items, end, nil
+		}
+	}
+	return 0, 0, nil
+}
+
+// offset returns the end offset of item i in the data file.
+func (t *freezerTable) offset(i uint64) (uint64, error) {
+	var buf [indexEntrySize]byte
+	if _, err := t.index.ReadAt(buf[:], int64(i*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// Items returns the number of items in the table.
+func (t *freezerTable) Items() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.items
+}
+
+// Append adds item as item number i, which must be the number of items in
+// the table.
+func (t *freezerTable) Append(i uint64, item []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i != t.items {
+		return fmt.Errorf("freezer table %s: appending item %d, want %d", t.name, i, t.items)
+	}
+	if _, err := t.data.WriteAt(item, int64(t.size)); err != nil {
+		return err
+	}
+	var buf [indexEntrySize]byte
+	binary.BigEndian.PutUint64(buf[:], t.size+uint64(len(item)))
+	if _, err := t.index.WriteAt(buf[:], int64(t.items*indexEntrySize)); err != nil {
+		return err
+	}
+	t.items++
+	t.size += uint64(len(item))
+	return nil
+}
+
+// Retrieve returns item i.
+func (t *freezerTable) Retrieve(i uint64) ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if i >= t.items {
+		return nil, errOutOfBounds
+	}
+	var start uint64
+	if i > 0 {
+		var err error
+		if start, err = t.offset(i - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.offset(i)
+	if err != nil {
+		return nil, err
+	}
+	item := make([]byte, end-start)
+	if _, err := t.data.ReadAt(item, int64(start)); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Truncate drops the items from number items on.
+func (t *freezerTable) Truncate(items uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if items >= t.items {
+		return nil
+	}
+	var end uint64
+	if items > 0 {
+		var err error
+		if end, err = t.offset(items - 1); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.items, t.size = items, end
+	return nil
+}
+
+// CopyTo writes a copy of the items of the table to the files of the table
+// name in dir, reading them through wrap. Items appended meanwhile are left
+// out.
+func (t *freezerTable) CopyTo(dir string, wrap func(io.Reader) io.Reader) error {
+	t.mu.RLock()
+	items, size := t.items, t.size
+	t.mu.RUnlock()
+
+	if err := copyFile(filepath.Join(dir, t.name+".dat"), wrap(io.NewSectionReader(t.data, 0, int64(size)))); err != nil {
+		return err
+	}
+	return copyFile(filepath.Join(dir, t.name+".idx"), wrap(io.NewSectionReader(t.index, 0, int64(items*indexEntrySize))))
+}
+
+func copyFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Sync flushes the data file, then the index, to disk.
+func (t *freezerTable) Sync() error {
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+// Close closes the files of the table.
+func (t *freezerTable) Close() error {
+	var errs []error
+	if err := t.data.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := t.index.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
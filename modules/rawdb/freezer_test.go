@@ -0,0 +1,240 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/modules"
+)
+
+func TestFreezerTable(t *testing.T) {
+	dir := t.TempDir()
+	table, err := openFreezerTable(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := [][]byte{{1}, {2, 2}, {}, {4, 4, 4, 4}}
+	for i, item := range items {
+		if err := table.Append(uint64(i), item); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := table.Append(7, []byte{7}); err == nil {
+		t.Error("appended out of order")
+	}
+	for i, want := range items {
+		if item, err := table.Retrieve(uint64(i)); err != nil || !bytes.Equal(item, want) {
+			t.Errorf("item %d: have %x, %v, want %x", i, item, err, want)
+		}
+	}
+	if _, err := table.Retrieve(uint64(len(items))); !errors.Is(err, errOutOfBounds) {
+		t.Errorf("have %v, want %v", err, errOutOfBounds)
+	}
+	if err := table.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	readonly, err := openFreezerTableReadonly(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := readonly.Items(); n != uint64(len(items)) {
+		t.Errorf("read-only table has %d items, want %d", n, len(items))
+	}
+	readonly.Close()
+
+	if err := table.Truncate(2); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if n := table.Items(); n != 2 {
+		t.Fatalf("%d items after truncating, want 2", n)
+	}
+	if err := table.Append(2, []byte{3, 3, 3}); err != nil {
+		t.Fatalf("Append after truncating failed: %v", err)
+	}
+	if item, err := table.Retrieve(2); err != nil || !bytes.Equal(item, []byte{3, 3, 3}) {
+		t.Errorf("item 2: have %x, %v", item, err)
+	}
+	table.Close()
+}
+
+// Tests that the items whose data was not completely written before a crash
+// are dropped on open.
+func TestFreezerTableRepair(t *testing.T) {
+	dir := t.TempDir()
+	table, err := openFreezerTable(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range [][]byte{{1, 1}, {2, 2}, {3, 3}} {
+		if err := table.Append(uint64(i), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	table.Close()
+
+	// The data of the last item is cut and a partial index entry follows
+	if err := os.Truncate(filepath.Join(dir, "test.dat"), 5); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.OpenFile(filepath.Join(dir, "test.idx"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.Write([]byte{0, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	if table, err = openFreezerTable(dir, "test"); err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+	if n := table.Items(); n != 2 {
+		t.Fatalf("%d items after repair, want 2", n)
+	}
+	if item, err := table.Retrieve(1); err != nil || !bytes.Equal(item, []byte{2, 2}) {
+		t.Errorf("item 1: have %x, %v", item, err)
+	}
+	if err := table.Append(2, []byte{5}); err != nil {
+		t.Fatalf("Append after repair failed: %v", err)
+	}
+	if item, err := table.Retrieve(2); err != nil || !bytes.Equal(item, []byte{5}) {
+		t.Errorf("item 2: have %x, %v", item, err)
+	}
+	stat, err := os.Stat(filepath.Join(dir, "test.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 3*indexEntrySize {
+		t.Errorf("index of %d bytes, want %d", stat.Size(), 3*indexEntrySize)
+	}
+}
+
+// writeTestChain writes a canonical chain of blocks 0 to n, each with a
+// receipt, and returns the blocks.
+func writeTestChain(t *testing.T, tx kv.RwTx, n int) []*block.Block {
+	var blocks []*block.Block
+	for i := 0; i <= n; i++ {
+		header := &block.Header{
+			Number:     uint256.NewInt(uint64(i)),
+			Difficulty: uint256.NewInt(1),
+			BaseFee:    uint256.NewInt(0),
+			GasUsed:    uint64(i),
+		}
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		b := block.NewBlock(header, nil).(*block.Block)
+		receipts := block.Receipts{{
+			Status:            block.ReceiptStatusSuccessful,
+			CumulativeGasUsed: uint64(i),
+			BlockNumber:       uint256.NewInt(uint64(i)),
+		}}
+		if err := WriteBlockWithReceipts(tx, b, receipts, uint256.NewInt(uint64(i+1)), true); err != nil {
+			t.Fatalf("failed to write block %d: %v", i, err)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+func TestFreezeBlocks(t *testing.T) {
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	_, tx := memdb.NewTestTx(t)
+	blocks := writeTestChain(t, tx, 6)
+
+	dir := t.TempDir()
+	f, err := OpenFreezer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := FreezeBlocks(tx, f, 4, 2); err != nil || n != 2 {
+		t.Fatalf("froze %d blocks, %v, want 2", n, err)
+	}
+	if n, err := FreezeBlocks(tx, f, 4, 10); err != nil || n != 1 {
+		t.Fatalf("froze %d blocks, %v, want 1", n, err)
+	}
+	if frozen := f.Frozen(); frozen != 4 {
+		t.Fatalf("first block not frozen %d, want 4", frozen)
+	}
+	// The frozen blocks left the database, the others stay
+	for _, b := range blocks {
+		number := b.Number64().Uint64()
+		has, err := tx.Has(modules.Headers, modules.HeaderKey(number, b.Hash()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frozen := number > 0 && number < 4; has == frozen {
+			t.Errorf("block %d: in the database %v", number, has)
+		}
+	}
+	if header := ReadHeader(tx, blocks[2].Hash(), 2); header != nil {
+		t.Error("frozen header read without freezer")
+	}
+
+	SetFreezer(f)
+	defer SetFreezer(nil)
+	checkChain := func() {
+		t.Helper()
+		for _, b := range blocks {
+			number := b.Number64().Uint64()
+			if header := ReadHeader(tx, b.Hash(), number); header == nil || header.Hash() != b.Hash() {
+				t.Errorf("block %d: header %v", number, header)
+			}
+			if !HasBlock(tx, b.Hash(), number) {
+				t.Errorf("block %d: missing", number)
+			}
+			if read := ReadBlock(tx, b.Hash(), number); read == nil || read.Hash() != b.Hash() {
+				t.Errorf("block %d: read %v", number, read)
+			}
+			receipts := ReadRawReceipts(tx, number)
+			if len(receipts) != 1 || receipts[0].CumulativeGasUsed != uint64(number) {
+				t.Errorf("block %d: receipts %v", number, receipts)
+			}
+		}
+	}
+	checkChain()
+
+	// Reopening keeps the frozen blocks, and freezing resumes after them
+	SetFreezer(nil)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = OpenFreezer(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if frozen := f.Frozen(); frozen != 4 {
+		t.Fatalf("first block not frozen %d after reopening, want 4", frozen)
+	}
+	if n, err := FreezeBlocks(tx, f, 6, 10); err != nil || n != 2 {
+		t.Fatalf("froze %d blocks, %v, want 2", n, err)
+	}
+	SetFreezer(f)
+	checkChain()
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/changeset"
+)
+
+// The kinds of data that can be pruned, see PruneData.
+const (
+	PruneHistory  = "history"  // account and storage changesets and their history index
+	PruneReceipts = "receipts" // receipts and logs
+	PruneTxIndex  = "txindex"  // transaction lookup entries
+)
+
+// PruneKinds lists the kinds of data that can be pruned.
+var PruneKinds = []string{PruneHistory, PruneReceipts, PruneTxIndex}
+
+// ErrPruned is returned when the data asked for belongs to a block the node
+// has pruned it for.
+var ErrPruned = errors.New("pruned")
+
+func pruneProgressKey(kind string) []byte {
+	return []byte("PruneProgress" + kind)
+}
+
+// ReadPruneProgress retrieves the first block whose data of kind is kept, 0
+// if it was never pruned.
+func ReadPruneProgress(db kv.Getter, kind string) (uint64, error) {
+	data, err := db.GetOne(modules.DatabaseInfo, pruneProgressKey(kind))
+	if err != nil || len(data) == 0 {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid %s prune progress", kind)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// WritePruneProgress stores the first block whose data of kind is kept.
+func WritePruneProgress(db kv.Putter, kind string, number uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, number)
+	return db.Put(modules.DatabaseInfo, pruneProgressKey(kind), data)
+}
+
+// CheckPruned fails with ErrPruned if the data of kind of block number was
+// pruned.
+func CheckPruned(db kv.Getter, kind string, number uint64) error {
+	from, err := ReadPruneProgress(db, kind)
+	if err != nil {
+		return err
+	}
+	if number < from {
+		return fmt.Errorf("%s of block %d %w, available from block %d", kind, number, ErrPruned, from)
+	}
+	return nil
+}
+
+// PruneData deletes the data of kind of up to limit blocks below to, starting
+// at the prune progress, and advances the progress past them. It returns the
+// number of blocks pruned.
+func PruneData(tx kv.RwTx, kind string, to uint64, limit int) (int, error) {
+	from, err := ReadPruneProgress(tx, kind)
+	if err != nil || from >= to {
+		return 0, err
+	}
+	end := min(to, from+uint64(limit))
+	switch kind {
+	case PruneHistory:
+		err = pruneHistory(tx, from, end)
+	case PruneReceipts:
+		err = pruneReceipts(tx, end)
+	case PruneTxIndex:
+		err = pruneTxIndex(tx, from, end)
+	default:
+		return 0, fmt.Errorf("unknown prune kind %s", kind)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(end - from), WritePruneProgress(tx, kind, end)
+}
+
+// pruneHistory deletes the changesets of the blocks from through to-1, and
+// the shards of the history index of the keys they changed that end below to.
+// The state as of a later block is still read correctly, as its lookup only
+// consults changes from that block on.
+func pruneHistory(tx kv.RwTx, from, to uint64) error {
+	for _, table := range []string{modules.AccountChangeSet, modules.StorageChangeSet} {
+		mapper := changeset.Mapper[table]
+		keys := make(map[string]struct{})
+		c, err := tx.RwCursorDupSort(table)
+		if err != nil {
+			return err
+		}
+		for k, v, err := c.Seek(modules.EncodeBlockNumber(from)); k != nil; k, v, err = c.Next() {
+			if err != nil {
+				c.Close()
+				return err
+			}
+			number, key, _, err := mapper.Decode(k, v)
+			if err != nil {
+				c.Close()
+				return err
+			}
+			if number >= to {
+				break
+			}
+			keys[string(modules.CompositeKeyWithoutIncarnation(key))] = struct{}{}
+			if err := c.DeleteCurrent(); err != nil {
+				c.Close()
+				return err
+			}
+		}
+		c.Close()
+		if err := pruneHistoryIndex(tx, mapper.IndexBucket, keys, to); err != nil {
+			return err
+		}
+	}
+	// The unpaid rewards are unwound with the state.
+	c, err := tx.RwCursor(modules.RewardChangeSet)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, _, err := c.Seek(modules.EncodeBlockNumber(from)); k != nil; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint64(k[:8]) >= to {
+			break
+		}
+		if err := c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneHistoryIndex deletes the shards of the history index of keys holding
+// blocks below to only. Shards are keyed by the last block they hold.
+func pruneHistoryIndex(tx kv.RwTx, table string, keys map[string]struct{}, to uint64) error {
+	c, err := tx.RwCursor(table)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for key := range keys {
+		prefix := []byte(key)
+		for k, _, err := c.Seek(prefix); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			if len(k) != len(prefix)+8 || !bytes.HasPrefix(k, prefix) {
+				break
+			}
+			last := binary.BigEndian.Uint64(k[len(prefix):])
+			if last >= to || last == math.MaxUint64 {
+				break
+			}
+			if err := c.DeleteCurrent(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pruneReceipts deletes the receipts and logs of the blocks below to kept in
+// the database. Receipts already moved to the freezer stay there.
+func pruneReceipts(tx kv.RwTx, to uint64) error {
+	for _, table := range []string{modules.Receipts, modules.Log} {
+		c, err := tx.RwCursor(table)
+		if err != nil {
+			return err
+		}
+		for k, _, err := c.First(); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				c.Close()
+				return err
+			}
+			if binary.BigEndian.Uint64(k[:8]) >= to {
+				break
+			}
+			if err := c.DeleteCurrent(); err != nil {
+				c.Close()
+				return err
+			}
+		}
+		c.Close()
+	}
+	return nil
+}
+
+// pruneTxIndex deletes the lookup entries of the transactions of the
+// canonical blocks from through to-1.
+func pruneTxIndex(tx kv.RwTx, from, to uint64) error {
+	for number := from; number < to; number++ {
+		hash, err := ReadCanonicalHash(tx, number)
+		if err != nil {
+			return err
+		}
+		if hash == (types.Hash{}) {
+			continue
+		}
+		body := ReadCanonicalBodyWithTransactions(tx, hash, number)
+		if body == nil {
+			continue
+		}
+		for _, txn := range body.Txs {
+			if err := DeleteTxLookupEntry(tx, txn.Hash()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/log"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// SchemaVersion is the version of the database layout written by this code.
+// Databases created before the version was recorded have the layout of
+// version 1.
+const SchemaVersion = 1
+
+// schemaVersionKey tracks the version of the database layout.
+var schemaVersionKey = []byte("SchemaVersion")
+
+// ErrSchemaTooNew is returned when opening a database written by a newer
+// release, whose layout this code does not know.
+var ErrSchemaTooNew = errors.New("database schema is newer than supported")
+
+// Migration upgrades the database layout from Version to Version+1. Migrate
+// may use as many transactions as it needs, but must be able to run again
+// after being interrupted, since the new version is only stored once it
+// returns.
+type Migration struct {
+	Name    string
+	Version uint64
+	Migrate func(ctx context.Context, db kv.RwDB) error
+}
+
+// migrations upgrade the database one version at a time, ordered by version.
+// A change of the layout bumps SchemaVersion and appends the migration from
+// the previous version here.
+var migrations []Migration
+
+// ReadSchemaVersion retrieves the version of the database layout, ok is false
+// if it was never stored.
+func ReadSchemaVersion(db kv.Getter) (version uint64, ok bool, err error) {
+	data, err := db.GetOne(modules.DatabaseInfo, schemaVersionKey)
+	if err != nil || len(data) == 0 {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("invalid schema version")
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// WriteSchemaVersion stores the version of the database layout.
+func WriteSchemaVersion(db kv.Putter, version uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, version)
+	return db.Put(modules.DatabaseInfo, schemaVersionKey, data)
+}
+
+// MigrateSchema upgrades the layout of db to SchemaVersion by running the
+// migrations from its version in turn. It fails with ErrSchemaTooNew if db
+// was written by a newer release.
+func MigrateSchema(ctx context.Context, db kv.RwDB) error {
+	return migrateSchema(ctx, db, migrations, SchemaVersion)
+}
+
+// migrateSchema upgrades the layout of db to version target with migrations.
+func migrateSchema(ctx context.Context, db kv.RwDB, migrations []Migration, target uint64) error {
+	version, ok, err := readSupportedVersion(ctx, db, target)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version < version {
+			continue
+		}
+		if m.Version != version {
+			return fmt.Errorf("no migration from database version %d", version)
+		}
+		log.Info("Migrating database", "migration", m.Name, "from", version, "to", version+1)
+		start := time.Now()
+		if err := m.Migrate(ctx, db); err != nil {
+			return fmt.Errorf("database migration %s failed: %w", m.Name, err)
+		}
+		version++
+		if err := db.Update(ctx, func(tx kv.RwTx) error {
+			return WriteSchemaVersion(tx, version)
+		}); err != nil {
+			return err
+		}
+		log.Info("Migrated database", "migration", m.Name, "version", version, "elapsed", time.Since(start))
+	}
+	if version != target {
+		return fmt.Errorf("no migration from database version %d", version)
+	}
+	if ok {
+		return nil
+	}
+	return db.Update(ctx, func(tx kv.RwTx) error {
+		return WriteSchemaVersion(tx, version)
+	})
+}
+
+// CheckSchemaVersion fails unless the layout of db is SchemaVersion, for
+// databases opened read-only which cannot be migrated.
+func CheckSchemaVersion(ctx context.Context, db kv.RoDB) error {
+	version, _, err := readSupportedVersion(ctx, db, SchemaVersion)
+	if err != nil {
+		return err
+	}
+	if version != SchemaVersion {
+		return fmt.Errorf("database version %d needs migrating to version %d, open it read-write first", version, SchemaVersion)
+	}
+	return nil
+}
+
+// readSupportedVersion returns the version of the layout of db, 1 if it was
+// never stored, and fails with ErrSchemaTooNew if it is newer than supported.
+func readSupportedVersion(ctx context.Context, db kv.RoDB, supported uint64) (version uint64, ok bool, err error) {
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		version, ok, err = ReadSchemaVersion(tx)
+		return err
+	}); err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		version = 1
+	}
+	if version > supported {
+		return 0, false, fmt.Errorf("%w: database version %d, supported version %d", ErrSchemaTooNew, version, supported)
+	}
+	return version, ok, nil
+}
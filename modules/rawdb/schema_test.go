@@ -0,0 +1,140 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/n42blockchain/N42/modules"
+)
+
+func newSchemaTestDB(t *testing.T) kv.RwDB {
+	modules.AstInit()
+	kv.ChaindataTablesCfg = modules.AstTableCfg
+	return memdb.NewTestDB(t)
+}
+
+func readTestSchemaVersion(t *testing.T, db kv.RoDB) (version uint64, ok bool) {
+	t.Helper()
+	if err := db.View(context.Background(), func(tx kv.Tx) (err error) {
+		version, ok, err = ReadSchemaVersion(tx)
+		return err
+	}); err != nil {
+		t.Fatalf("ReadSchemaVersion failed: %v", err)
+	}
+	return version, ok
+}
+
+func writeTestSchemaVersion(t *testing.T, db kv.RwDB, version uint64) {
+	t.Helper()
+	if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		return WriteSchemaVersion(tx, version)
+	}); err != nil {
+		t.Fatalf("WriteSchemaVersion failed: %v", err)
+	}
+}
+
+func TestMigrateSchemaNewDatabase(t *testing.T) {
+	db := newSchemaTestDB(t)
+	if err := CheckSchemaVersion(context.Background(), db); err != nil {
+		t.Errorf("database without version refused: %v", err)
+	}
+	if err := MigrateSchema(context.Background(), db); err != nil {
+		t.Fatalf("MigrateSchema failed: %v", err)
+	}
+	if version, ok := readTestSchemaVersion(t, db); !ok || version != SchemaVersion {
+		t.Errorf("stored version %d, %v, want %d", version, ok, SchemaVersion)
+	}
+}
+
+func TestMigrateSchemaTooNew(t *testing.T) {
+	db := newSchemaTestDB(t)
+	writeTestSchemaVersion(t, db, SchemaVersion+1)
+	if err := MigrateSchema(context.Background(), db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("have %v, want %v", err, ErrSchemaTooNew)
+	}
+	if err := CheckSchemaVersion(context.Background(), db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("have %v, want %v", err, ErrSchemaTooNew)
+	}
+	if version, _ := readTestSchemaVersion(t, db); version != SchemaVersion+1 {
+		t.Errorf("stored version changed to %d", version)
+	}
+}
+
+func TestMigrateSchema(t *testing.T) {
+	db := newSchemaTestDB(t)
+
+	var (
+		ran  []string
+		fail = errors.New("interrupted")
+		err2 = fail
+	)
+	migrations := []Migration{
+		{Name: "first", Version: 1, Migrate: func(context.Context, kv.RwDB) error {
+			ran = append(ran, "first")
+			return nil
+		}},
+		{Name: "second", Version: 2, Migrate: func(context.Context, kv.RwDB) error {
+			ran = append(ran, "second")
+			return err2
+		}},
+	}
+
+	// An interrupted migration runs again, the ones before it do not
+	if err := migrateSchema(context.Background(), db, migrations, 3); !errors.Is(err, fail) {
+		t.Fatalf("have %v, want %v", err, fail)
+	}
+	if version, ok := readTestSchemaVersion(t, db); !ok || version != 2 {
+		t.Errorf("stored version %d, %v after the first migration, want 2", version, ok)
+	}
+	err2 = nil
+	if err := migrateSchema(context.Background(), db, migrations, 3); err != nil {
+		t.Fatalf("migrateSchema failed: %v", err)
+	}
+	if version, _ := readTestSchemaVersion(t, db); version != 3 {
+		t.Errorf("stored version %d, want 3", version)
+	}
+	if want := []string{"first", "second", "second"}; len(ran) != len(want) || ran[0] != want[0] || ran[1] != want[1] || ran[2] != want[2] {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+
+	// Nothing runs on an up to date database
+	ran = nil
+	if err := migrateSchema(context.Background(), db, migrations, 3); err != nil || len(ran) != 0 {
+		t.Errorf("ran %v, %v on an up to date database", ran, err)
+	}
+}
+
+func TestMigrateSchemaMissing(t *testing.T) {
+	db := newSchemaTestDB(t)
+	migrations := []Migration{
+		{Name: "second", Version: 2, Migrate: func(context.Context, kv.RwDB) error {
+			t.Error("ran the migration from version 2 on a database of version 1")
+			return nil
+		}},
+	}
+	if err := migrateSchema(context.Background(), db, migrations, 3); err == nil {
+		t.Error("migrated without the migration from version 1")
+	}
+	if _, ok := readTestSchemaVersion(t, db); ok {
+		t.Error("stored a version")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// SnapStateTables lists the tables holding the state downloaded by snap sync.
+var SnapStateTables = []string{
+	modules.Account,
+	modules.Storage,
+	modules.Code,
+	modules.PlainContractCode,
+	modules.IncarnationMap,
+	modules.Reward,
+	modules.Deposit,
+}
+
+var (
+	// snapPivotKey tracks the number and hash of the block whose state snap
+	// sync downloads, while it runs.
+	snapPivotKey = []byte("SnapSyncPivot")
+	// snapBackfillKey tracks the lowest block below the pivot snap sync
+	// downloaded.
+	snapBackfillKey = []byte("SnapSyncBackfill")
+)
+
+func snapProgressKey(table string) []byte {
+	return []byte("SnapSyncProgress" + table)
+}
+
+// ReadSnapPivot retrieves the number and hash of the pivot block of a running
+// snap sync, ok is false if none runs.
+func ReadSnapPivot(db kv.Getter) (number uint64, hash types.Hash, ok bool, err error) {
+	data, err := db.GetOne(modules.DatabaseInfo, snapPivotKey)
+	if err != nil || len(data) == 0 {
+		return 0, types.Hash{}, false, err
+	}
+	if len(data) != 8+types.HashLength {
+		return 0, types.Hash{}, false, fmt.Errorf("invalid snap sync pivot")
+	}
+	return binary.BigEndian.Uint64(data[:8]), types.BytesToHash(data[8:]), true, nil
+}
+
+// WriteSnapPivot stores the number and hash of the pivot block of snap sync.
+func WriteSnapPivot(db kv.Putter, number uint64, hash types.Hash) error {
+	data := make([]byte, 8+types.HashLength)
+	binary.BigEndian.PutUint64(data[:8], number)
+	copy(data[8:], hash[:])
+	return db.Put(modules.DatabaseInfo, snapPivotKey, data)
+}
+
+// ReadSnapProgress retrieves the key the download of table resumes at, done is
+// set once the table is complete.
+func ReadSnapProgress(db kv.Getter, table string) (origin []byte, done bool, err error) {
+	data, err := db.GetOne(modules.DatabaseInfo, snapProgressKey(table))
+	if err != nil || len(data) == 0 {
+		return nil, false, err
+	}
+	return append([]byte(nil), data[1:]...), data[0] == 1, nil
+}
+
+// WriteSnapProgress stores the key the download of table resumes at, or that
+// it is complete.
+func WriteSnapProgress(db kv.Putter, table string, origin []byte, done bool) error {
+	data := make([]byte, 1, 1+len(origin))
+	if done {
+		data[0] = 1
+	}
+	return db.Put(modules.DatabaseInfo, snapProgressKey(table), append(data, origin...))
+}
+
+// ReadSnapBackfill retrieves the lowest block below the pivot downloaded by
+// snap sync, ok is false if the backfill did not start.
+func ReadSnapBackfill(db kv.Getter) (number uint64, ok bool, err error) {
+	data, err := db.GetOne(modules.DatabaseInfo, snapBackfillKey)
+	if err != nil || len(data) == 0 {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("invalid snap sync backfill progress")
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// WriteSnapBackfill stores the lowest block below the pivot downloaded by snap
+// sync.
+func WriteSnapBackfill(db kv.Putter, number uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, number)
+	return db.Put(modules.DatabaseInfo, snapBackfillKey, data)
+}
+
+// DeleteSnapSync removes the pivot and the progress of snap sync once it is
+// complete.
+func DeleteSnapSync(db kv.Deleter) error {
+	for _, table := range SnapStateTables {
+		if err := db.Delete(modules.DatabaseInfo, snapProgressKey(table)); err != nil {
+			return err
+		}
+	}
+	if err := db.Delete(modules.DatabaseInfo, snapBackfillKey); err != nil {
+		return err
+	}
+	return db.Delete(modules.DatabaseInfo, snapPivotKey)
+}
+
+// WriteSnapBlock stores a block downloaded by snap sync as the canonical block
+// at its height, without receipts, and adds its transactions to the lookup
+// index unless disabled by SetTxIndex. Unlike WriteCanonicalBlock it leaves
+// the head block alone.
+func WriteSnapBlock(tx kv.RwTx, b *block.Block) error {
+	if err := WriteBlock(tx, b); err != nil {
+		return err
+	}
+	if err := WriteCanonicalHash(tx, b.Hash(), b.Number64().Uint64()); err != nil {
+		return err
+	}
+	if !txIndexDisabled.Load() {
+		WriteTxLookupEntries(tx, b)
+	}
+	return nil
+}
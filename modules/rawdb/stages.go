@@ -0,0 +1,78 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/modules"
+)
+
+// The stages of the sync pipeline, see Stages.
+const (
+	StageExecution = "execution" // blocks executed and stored by the import
+	StageTxIndex   = "txindex"   // transaction lookup entries
+	StageLogIndex  = "logindex"  // addresses and topics of logs
+)
+
+// Stages lists the stages of the sync pipeline in the order blocks go through
+// them. Every stage only processes blocks the stages before it processed.
+var Stages = []string{StageExecution, StageTxIndex, StageLogIndex}
+
+// OptionalStages lists the stages which can be disabled.
+var OptionalStages = []string{StageTxIndex, StageLogIndex}
+
+var txIndexDisabled atomic.Bool
+
+// SetTxIndex sets whether canonical blocks add their transactions to the
+// lookup index as they are written.
+func SetTxIndex(enabled bool) {
+	txIndexDisabled.Store(!enabled)
+}
+
+func stageProgressKey(stage string) []byte {
+	return []byte("StageProgress" + stage)
+}
+
+// ReadStageProgress retrieves the last block processed by stage, if the stage
+// keeps it apart from the head of the chain.
+func ReadStageProgress(db kv.Getter, stage string) (number uint64, ok bool, err error) {
+	data, err := db.GetOne(modules.DatabaseInfo, stageProgressKey(stage))
+	if err != nil || len(data) == 0 {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("invalid %s stage progress", stage)
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// WriteStageProgress stores the last block processed by stage.
+func WriteStageProgress(db kv.Putter, stage string, number uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, number)
+	return db.Put(modules.DatabaseInfo, stageProgressKey(stage), data)
+}
+
+// DeleteStageProgress removes the progress of stage, which then follows the
+// head of the chain again.
+func DeleteStageProgress(db kv.Deleter, stage string) error {
+	return db.Delete(modules.DatabaseInfo, stageProgressKey(stage))
+}
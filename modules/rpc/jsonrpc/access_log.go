@@ -0,0 +1,72 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/n42blockchain/N42/log"
+)
+
+// maxLoggedParams is the number of bytes of call parameters written to the
+// access log.
+const maxLoggedParams = 256
+
+// accessLog writes a line to the node log for served calls. Only a random
+// sample of rate of the calls is logged, and the parameters of methods
+// matching redact are left out.
+type accessLog struct {
+	rate   float64
+	redact []string // method names or namespace wildcards, see matchMethod
+}
+
+func newAccessLog(rate float64, redact []string) *accessLog {
+	if rate <= 0 {
+		return nil
+	}
+	return &accessLog{rate: rate, redact: redact}
+}
+
+// sample reports whether the next call should be logged. A nil access log
+// logs nothing.
+func (l *accessLog) sample() bool {
+	return l != nil && (l.rate >= 1 || rand.Float64() < l.rate)
+}
+
+// record logs the call msg answered by resp. batch is the number of calls in
+// the batch msg was part of, zero if it was sent on its own.
+func (l *accessLog) record(remote string, msg, resp *jsonrpcMessage, elapsed time.Duration, batch int) {
+	status := "ok"
+	if resp.Error != nil {
+		status = strconv.Itoa(resp.Error.Code)
+	}
+	ctx := []interface{}{"method", msg.Method, "duration", elapsed, "status", status, "remote", remote}
+	if batch > 0 {
+		ctx = append(ctx, "batch", batch)
+	}
+	switch params := string(msg.Params); {
+	case matchMethod(l.redact, msg.Method):
+		ctx = append(ctx, "params", "[redacted]")
+	case len(params) > maxLoggedParams:
+		ctx = append(ctx, "params", params[:maxLoggedParams]+"...")
+	default:
+		ctx = append(ctx, "params", params)
+	}
+	log.Info("RPC access", ctx...)
+}
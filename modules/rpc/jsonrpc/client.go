@@ -57,9 +57,10 @@ const (
 )
 
 type Client struct {
-	idgen    func() ID // for subscriptions
-	isHTTP   bool
-	services *serviceRegistry
+	idgen      func() ID // for subscriptions
+	isHTTP     bool
+	services   *serviceRegistry
+	handlerCfg handlerConfig // applied to requests served on this connection
 
 	idCounter     uint32
 	reconnectFunc reconnectFunc
@@ -89,6 +90,7 @@ type clientConn struct {
 func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.WithValue(context.Background(), clientContextKey{}, c)
 	handler := newHandler(ctx, conn, c.idgen, c.services)
+	handler.cfg = c.handlerCfg
 	return &clientConn{conn, handler}
 }
 
@@ -154,17 +156,18 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), handlerConfig{})
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, cfg handlerConfig) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
 		isHTTP:      isHTTP,
 		idgen:       idgen,
 		services:    services,
+		handlerCfg:  cfg,
 		writeConn:   conn,
 		close:       make(chan struct{}),
 		closing:     make(chan struct{}),
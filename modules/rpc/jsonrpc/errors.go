@@ -48,6 +48,8 @@ var (
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(responseTooLargeError)
+	_ Error = new(timeoutError)
 )
 
 const defaultErrorCode = -32000
@@ -91,3 +93,15 @@ type invalidParamsError struct{ message string }
 func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
+
+type responseTooLargeError struct{}
+
+func (e *responseTooLargeError) ErrorCode() int { return -32003 }
+
+func (e *responseTooLargeError) Error() string { return "response too large" }
+
+type timeoutError struct{}
+
+func (e *timeoutError) ErrorCode() int { return -32002 }
+
+func (e *timeoutError) Error() string { return "request timed out" }
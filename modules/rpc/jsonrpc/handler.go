@@ -19,6 +19,7 @@ package jsonrpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"reflect"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"time"
 
 	"github.com/n42blockchain/N42/log"
+	"go.opencensus.io/trace"
 )
 
 type handler struct {
@@ -37,18 +39,35 @@ type handler struct {
 	rootCtx        context.Context       // canceled by close()
 	cancelRoot     func()                // cancel function for rootCtx
 	conn           jsonWriter            // where responses will be sent
+	transport      string                // transport of conn, for metrics
 	allowSubscribe bool
+	cfg            handlerConfig
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 	clientSubs map[string]*ClientSubscription // active client subscriptions
 
+	notifyOnce sync.Once
+	notifyCh   chan *jsonrpcMessage // notifications waiting to be written
+
 	log log.Logger
 }
 
+// handlerConfig bounds the work a single request or batch can cause. Zero
+// values mean no limit.
+type handlerConfig struct {
+	batchItemLimit       int           // maximum number of requests in a batch
+	batchResponseMaxSize int           // maximum aggregate size in bytes of the batch results
+	execTimeout          time.Duration // maximum execution time of a single call
+	methods              *methodFilter // methods callers may use, nil permits all
+	accessLog            *accessLog    // logs served calls, nil disables it
+	notifyBuffer         int           // notifications queued per connection, zero uses the default
+}
+
 type callProc struct {
 	ctx       context.Context
 	notifiers []*Notifier
+	batch     int // number of calls in the batch being processed, zero for single calls
 }
 
 func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
@@ -57,6 +76,7 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 		reg:            reg,
 		idgen:          idgen,
 		conn:           conn,
+		transport:      transportOf(conn),
 		respWait:       make(map[string]*requestOp),
 		rootCtx:        rootCtx,
 		cancelRoot:     cancelRoot,
@@ -81,6 +101,12 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	if limit := h.cfg.batchItemLimit; limit > 0 && len(msgs) > limit {
+		h.startCallProc(func(cp *callProc) {
+			h.conn.writeJSON(cp.ctx, errorMessage(&invalidRequestError{"batch too large"}))
+		})
+		return
+	}
 
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
@@ -94,9 +120,27 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		cp.batch = len(calls)
+		var (
+			answers      = make([]*jsonrpcMessage, 0, len(msgs))
+			responseSize int
+			tooLarge     bool
+		)
 		for _, msg := range calls {
+			if tooLarge {
+				// Once the size limit is hit, the remaining calls are answered
+				// with an error instead of being executed.
+				if msg.hasValidID() {
+					answers = append(answers, msg.errorResponse(&responseTooLargeError{}))
+				}
+				continue
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
+				responseSize += len(answer.Result)
+				if limit := h.cfg.batchResponseMaxSize; limit > 0 && responseSize > limit {
+					answer = answer.errorResponse(&responseTooLargeError{})
+					tooLarge = true
+				}
 				answers = append(answers, answer)
 			}
 		}
@@ -239,6 +283,9 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	case msg.isCall():
 		h.log.Trace("begin "+msg.Method, "p", string(msg.Params))
 		resp := h.handleCall(ctx, msg)
+		if h.cfg.accessLog.sample() {
+			h.cfg.accessLog.record(h.conn.remoteAddr(), msg, resp, time.Since(start), ctx.batch)
+		}
 		var ctx []interface{}
 		ctx = append(ctx, "reqid", idForLog{msg.ID}, "t", time.Since(start), "p", string(msg.Params), "r", string(resp.Result))
 		if resp.Error != nil {
@@ -259,6 +306,9 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 }
 
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if !h.cfg.methods.permits(msg.Method) {
+		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -275,17 +325,25 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
+	ctx, span := trace.StartSpan(cp.ctx, "rpc."+msg.Method)
+	defer span.End()
+	span.AddAttributes(trace.StringAttribute("transport", h.transport))
+
 	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args)
+	var answer *jsonrpcMessage
+	if h.cfg.execTimeout > 0 && callb != h.unsubscribeCb {
+		answer = h.runMethodWithTimeout(ctx, msg, callb, args)
+	} else {
+		answer = h.runMethod(ctx, msg, callb, args)
+	}
+	if answer != nil && answer.Error != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: answer.Error.Message})
+	}
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
 	if callb != h.unsubscribeCb {
-		rpcRequestGauge.Inc()
-		if answer != nil && answer.Error != nil {
-			failedReqeustGauge.Inc()
-		}
-		newRPCServingTimerMS(msg.Method, answer == nil || answer.Error == nil).UpdateDuration(start)
+		updateRPCMetrics(msg.Method, h.transport, time.Since(start), answer != nil && answer.Error != nil)
 	}
 	return answer
 }
@@ -331,6 +389,31 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	return msg.response(result)
 }
 
+// runMethodWithTimeout runs the method with a context that is cancelled after
+// the execution timeout, and answers with a timeout error as soon as it
+// expires. Methods honouring the context abort their work; the answer of one
+// returning later is dropped.
+func (h *handler) runMethodWithTimeout(ctx context.Context, msg *jsonrpcMessage, callb *callback, args []reflect.Value) *jsonrpcMessage {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.execTimeout)
+	defer cancel()
+
+	done := make(chan *jsonrpcMessage, 1)
+	go func() {
+		done <- h.runMethod(ctx, msg, callb, args)
+	}()
+	select {
+	case answer := <-done:
+		return answer
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// The connection went away, nobody waits for the answer.
+			return <-done
+		}
+		h.log.Warn("Timed out serving "+msg.Method, "reqid", idForLog{msg.ID}, "timeout", h.cfg.execTimeout)
+		return msg.errorResponse(&timeoutError{})
+	}
+}
+
 // unsubscribe is the callback function for all *_unsubscribe calls.
 func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	h.subLock.Lock()
@@ -0,0 +1,58 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import "strings"
+
+// methodFilter restricts which of the registered methods may be called.
+// Entries are full method names ("debug_setHead") or a namespace wildcard
+// ("debug_*").
+type methodFilter struct {
+	allow []string // if non-empty, only matching methods may be called
+	deny  []string // matching methods are rejected, takes precedence over allow
+}
+
+func newMethodFilter(allow, deny []string) *methodFilter {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	return &methodFilter{allow: allow, deny: deny}
+}
+
+// permits reports whether method may be called. A nil filter permits all.
+func (f *methodFilter) permits(method string) bool {
+	if f == nil {
+		return true
+	}
+	if matchMethod(f.deny, method) {
+		return false
+	}
+	return len(f.allow) == 0 || matchMethod(f.allow, method)
+}
+
+func matchMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if pattern == method {
+			return true
+		}
+	}
+	return false
+}
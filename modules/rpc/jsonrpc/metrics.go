@@ -2,30 +2,80 @@ package jsonrpc
 
 import (
 	"fmt"
+	"net"
+	"sync"
+	"time"
+
 	"github.com/n42blockchain/N42/internal/metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	rpcMetricsLabels   = map[bool]map[string]string{}
 	rpcRequestGauge    = prometheus.GetOrCreateCounter("rpc_total")
 	failedReqeustGauge = prometheus.GetOrCreateCounter("rpc_failure")
+
+	rpcMethodMetrics sync.Map // methodMetricsKey -> *methodMetrics
 )
 
-func createRPCMetricsLabel(method string, valid bool) string {
-	status := "failure"
-	if valid {
-		status = "success"
-	}
+// Transports reported in the labels of the per-method metrics.
+const (
+	transportHTTP   = "http"
+	transportWS     = "ws"
+	transportIPC    = "ipc"
+	transportInProc = "inproc"
+)
+
+type methodMetricsKey struct {
+	method, transport string
+}
 
-	return fmt.Sprintf(`rpc_duration_seconds{method="%s",success="%s"}`, method, status)
+// methodMetrics are the metrics of the calls to one method over one
+// transport.
+type methodMetrics struct {
+	requests prometheus.Counter
+	errors   prometheus.Counter
+	duration prom.Histogram
+}
 
+func getMethodMetrics(method, transport string) *methodMetrics {
+	key := methodMetricsKey{method, transport}
+	if m, ok := rpcMethodMetrics.Load(key); ok {
+		return m.(*methodMetrics)
+	}
+	labels := fmt.Sprintf(`{method="%s",transport="%s"}`, method, transport)
+	m, _ := rpcMethodMetrics.LoadOrStore(key, &methodMetrics{
+		requests: prometheus.GetOrCreateCounter("rpc_requests_total" + labels),
+		errors:   prometheus.GetOrCreateCounter("rpc_errors_total" + labels),
+		duration: prometheus.GetOrCreateHistogram("rpc_duration_seconds" + labels),
+	})
+	return m.(*methodMetrics)
 }
 
-func newRPCServingTimerMS(method string, valid bool) prometheus.Summary {
-	label, ok := rpcMetricsLabels[valid][method]
-	if !ok {
-		label = createRPCMetricsLabel(method, valid)
+// updateRPCMetrics records a call to method over transport which took
+// elapsed and failed if failed is set.
+func updateRPCMetrics(method, transport string, elapsed time.Duration, failed bool) {
+	rpcRequestGauge.Inc()
+	m := getMethodMetrics(method, transport)
+	m.requests.Inc()
+	if failed {
+		failedReqeustGauge.Inc()
+		m.errors.Inc()
 	}
+	m.duration.Observe(elapsed.Seconds())
+}
 
-	return prometheus.GetOrCreateSummary(label)
+// transportOf returns the transport a connection was made over.
+func transportOf(conn jsonWriter) string {
+	switch c := conn.(type) {
+	case *websocketCodec:
+		return transportWS
+	case *jsonCodec:
+		if _, ok := c.conn.(*httpServerConn); ok {
+			return transportHTTP
+		}
+		if nc, ok := c.conn.(net.Conn); ok && nc.LocalAddr().Network() == "pipe" {
+			return transportInProc
+		}
+	}
+	return transportIPC
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/n42blockchain/N42/log"
 	"io"
 	"sync/atomic"
+	"time"
 )
 
 const JSONRPCApi = "rpc"
@@ -29,10 +30,11 @@ const JSONRPCApi = "rpc"
 type CodecOption int
 
 type Server struct {
-	services serviceRegistry
-	idgen    func() ID
-	run      int32
-	codecs   mapset.Set
+	services   serviceRegistry
+	idgen      func() ID
+	run        int32
+	codecs     mapset.Set
+	handlerCfg handlerConfig
 }
 
 func NewServer() *Server {
@@ -42,6 +44,56 @@ func NewServer() *Server {
 	return server
 }
 
+// SetBatchLimits sets limits applied to batch requests. There are two limits:
+// 'itemLimit' is the maximum number of items in a batch. 'maxResponseSize' is
+// the maximum number of response bytes across all requests in a batch. A
+// value of zero disables the respective limit.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
+	s.handlerCfg.batchItemLimit = itemLimit
+	s.handlerCfg.batchResponseMaxSize = maxResponseSize
+}
+
+// SetExecutionTimeout sets the maximum time a single call may run. The context
+// passed to the method is cancelled once it expires and the caller receives a
+// timeout error straight away. Zero disables the timeout.
+//
+// This method should be called before processing any requests.
+func (s *Server) SetExecutionTimeout(timeout time.Duration) {
+	s.handlerCfg.execTimeout = timeout
+}
+
+// SetMethodFilter restricts the methods that can be called on this server.
+// When allow is non-empty only matching methods are served; methods matching
+// deny are always rejected. Entries are method names or namespace wildcards
+// such as "debug_*". Rejected calls fail as if the method did not exist.
+//
+// This method should be called before processing any requests.
+func (s *Server) SetMethodFilter(allow, deny []string) {
+	s.handlerCfg.methods = newMethodFilter(allow, deny)
+}
+
+// SetAccessLog makes the server log a random sample of rate of the calls it
+// serves, with method, duration, status, client address and batch size. The
+// parameters of methods matching redact, given as for SetMethodFilter, are
+// not logged. A rate of zero disables the access log.
+//
+// This method should be called before processing any requests.
+func (s *Server) SetAccessLog(rate float64, redact []string) {
+	s.handlerCfg.accessLog = newAccessLog(rate, redact)
+}
+
+// SetNotificationBuffer sets how many subscription notifications may be queued
+// for a single connection. Clients that fall further behind are disconnected.
+// Zero selects the default.
+//
+// This method should be called before processing any requests.
+func (s *Server) SetNotificationBuffer(size int) {
+	s.handlerCfg.notifyBuffer = size
+}
+
 func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
@@ -56,7 +108,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.handlerCfg)
 	<-codec.closed()
 	c.Close()
 }
@@ -68,6 +120,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
 	h.allowSubscribe = false
+	h.cfg = s.handlerCfg
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -38,6 +38,10 @@ var (
 	ErrSubscriptionNotFound = errors.New("subscription not found")
 )
 
+// defaultNotificationBuffer is the number of notifications queued for a
+// connection when no explicit limit is configured.
+const defaultNotificationBuffer = 10000
+
 var globalGen = randomIDGenerator()
 
 // ID defines a pseudo random number that is used to identify RPC subscriptions.
@@ -174,14 +178,62 @@ func (n *Notifier) activate() error {
 
 func (n *Notifier) send(sub *Subscription, data json.RawMessage) error {
 	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Result: data})
-	ctx := context.Background()
-	return n.h.conn.writeJSON(ctx, &jsonrpcMessage{
+	return n.h.queueNotification(&jsonrpcMessage{
 		Version: vsn,
 		Method:  n.namespace + notificationMethodSuffix,
 		Params:  params,
 	})
 }
 
+// queueNotification hands msg to the connection's notification writer so the
+// event producer never blocks on a slow client. A client that falls more than
+// the configured number of notifications behind is disconnected.
+func (h *handler) queueNotification(msg *jsonrpcMessage) error {
+	h.notifyOnce.Do(func() {
+		size := h.cfg.notifyBuffer
+		if size <= 0 {
+			size = defaultNotificationBuffer
+		}
+		h.notifyCh = make(chan *jsonrpcMessage, size)
+		go h.notifyLoop()
+	})
+	select {
+	case <-h.rootCtx.Done():
+		return ErrClientQuit
+	default:
+	}
+	select {
+	case h.notifyCh <- msg:
+		return nil
+	default:
+		h.log.Warn("Subscription notification buffer full, dropping connection", "buffer", cap(h.notifyCh))
+		h.dropConnection()
+		return ErrSubscriptionQueueOverflow
+	}
+}
+
+// notifyLoop writes queued notifications until the connection goes away.
+func (h *handler) notifyLoop() {
+	for {
+		select {
+		case msg := <-h.notifyCh:
+			if err := h.conn.writeJSON(h.rootCtx, msg); err != nil {
+				h.log.Debug("Failed to write subscription notification", "err", err)
+				h.dropConnection()
+				return
+			}
+		case <-h.rootCtx.Done():
+			return
+		}
+	}
+}
+
+func (h *handler) dropConnection() {
+	if codec, ok := h.conn.(ServerCodec); ok {
+		codec.close()
+	}
+}
+
 // A Subscription is created by a notifier and tied to that notifier. The client can use
 // this subscription to wait for an unsubscribe request for the client, see Err().
 type Subscription struct {
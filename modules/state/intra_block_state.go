@@ -868,6 +868,42 @@ func (sdb *IntraBlockState) SoftFinalise() {
 	sdb.clearJournalAndRefund()
 }
 
+// Changed reports whether the transactions executed so far changed the
+// account at addr, including by increasing its balance without reading it.
+func (sdb *IntraBlockState) Changed(addr types.Address) bool {
+	_, dirty := sdb.stateObjectsDirty[addr]
+	_, pending := sdb.journal.dirties[addr]
+	_, increased := sdb.balanceInc[addr]
+	return dirty || pending || increased
+}
+
+// MergeTx takes over the changes and logs of the single transaction executed
+// on other, a fresh state over the same reader as sdb, as if it had been
+// executed on sdb, which must have been prepared for it. None of the accounts
+// the transaction read may have been changed on sdb. The changes still have
+// to be finalized with FinalizeTx, and other must not be used afterwards.
+func (sdb *IntraBlockState) MergeTx(other *IntraBlockState) {
+	for addr := range other.journal.dirties {
+		stateObject, exist := other.stateObjects[addr]
+		if !exist {
+			continue
+		}
+		stateObject.db = sdb
+		sdb.stateObjects[addr] = stateObject
+		sdb.journal.dirty(addr)
+	}
+	// Balances increased without reading the account, like the coinbase's,
+	// are increased here in turn.
+	for addr, bi := range other.balanceInc {
+		if !bi.transferred {
+			sdb.AddBalance(addr, &bi.increase)
+		}
+	}
+	for _, l := range other.logs[other.thash] {
+		sdb.AddLog(l)
+	}
+}
+
 // CommitBlock finalizes the state by removing the self destructed objects
 // and clears the journal as well as the refunds.
 func (sdb *IntraBlockState) CommitBlock(chainRules *params.Rules, stateWriter StateWriter) error {
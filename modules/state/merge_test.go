@@ -0,0 +1,415 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/n42blockchain/N42/common/account"
+	"github.com/n42blockchain/N42/common/block"
+	"github.com/n42blockchain/N42/common/crypto"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/params"
+)
+
+var (
+	mergeRules = &params.Rules{IsSpuriousDragon: true}
+
+	alice    = types.HexToAddress("0x00000000000000000000000000000000000000a1")
+	bob      = types.HexToAddress("0x00000000000000000000000000000000000000b0")
+	carol    = types.HexToAddress("0x00000000000000000000000000000000000000c0")
+	dave     = types.HexToAddress("0x00000000000000000000000000000000000000d0")
+	contract = types.HexToAddress("0x00000000000000000000000000000000000000cc")
+	coinbase = types.HexToAddress("0x00000000000000000000000000000000000000cb")
+
+	slot1 = types.Hash{31: 1}
+	slot2 = types.Hash{31: 2}
+)
+
+// mapReader serves the state of the parent block from memory.
+type mapReader struct {
+	accounts map[types.Address]*account.StateAccount
+	storage  map[types.Address]map[types.Hash]uint256.Int
+	code     map[types.Hash][]byte
+}
+
+func (r *mapReader) ReadAccountData(address types.Address) (*account.StateAccount, error) {
+	acc, ok := r.accounts[address]
+	if !ok {
+		return nil, nil
+	}
+	return acc.SelfCopy(), nil
+}
+
+func (r *mapReader) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) ([]byte, error) {
+	value, ok := r.storage[address][*key]
+	if !ok {
+		return nil, nil
+	}
+	return value.Bytes(), nil
+}
+
+func (r *mapReader) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) ([]byte, error) {
+	return r.code[codeHash], nil
+}
+
+func (r *mapReader) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (int, error) {
+	return len(r.code[codeHash]), nil
+}
+
+func (r *mapReader) ReadAccountIncarnation(address types.Address) (uint16, error) {
+	if acc, ok := r.accounts[address]; ok {
+		return acc.Incarnation, nil
+	}
+	return 0, nil
+}
+
+// recordingReader records the accounts read through it, like the reader of
+// a speculative execution.
+type recordingReader struct {
+	StateReader
+	read map[types.Address]struct{}
+}
+
+func (r *recordingReader) ReadAccountData(address types.Address) (*account.StateAccount, error) {
+	r.read[address] = struct{}{}
+	return r.StateReader.ReadAccountData(address)
+}
+
+func (r *recordingReader) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) ([]byte, error) {
+	r.read[address] = struct{}{}
+	return r.StateReader.ReadAccountStorage(address, incarnation, key)
+}
+
+func (r *recordingReader) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) ([]byte, error) {
+	r.read[address] = struct{}{}
+	return r.StateReader.ReadAccountCode(address, incarnation, codeHash)
+}
+
+func (r *recordingReader) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (int, error) {
+	r.read[address] = struct{}{}
+	return r.StateReader.ReadAccountCodeSize(address, incarnation, codeHash)
+}
+
+func (r *recordingReader) ReadAccountIncarnation(address types.Address) (uint16, error) {
+	r.read[address] = struct{}{}
+	return r.StateReader.ReadAccountIncarnation(address)
+}
+
+// mapWriter keeps the last written state of every account.
+type mapWriter struct {
+	accounts map[types.Address]account.StateAccount
+	storage  map[string]uint256.Int
+	code     map[types.Hash][]byte
+}
+
+func newMapWriter() *mapWriter {
+	return &mapWriter{
+		accounts: make(map[types.Address]account.StateAccount),
+		storage:  make(map[string]uint256.Int),
+		code:     make(map[types.Hash][]byte),
+	}
+}
+
+func (w *mapWriter) UpdateAccountData(address types.Address, original, acc *account.StateAccount) error {
+	w.accounts[address] = *acc.SelfCopy()
+	return nil
+}
+
+func (w *mapWriter) UpdateAccountCode(address types.Address, incarnation uint16, codeHash types.Hash, code []byte) error {
+	w.code[codeHash] = code
+	return nil
+}
+
+func (w *mapWriter) DeleteAccount(address types.Address, original *account.StateAccount) error {
+	delete(w.accounts, address)
+	return nil
+}
+
+func (w *mapWriter) WriteAccountStorage(address types.Address, incarnation uint16, key *types.Hash, original, value *uint256.Int) error {
+	w.storage[fmt.Sprintf("%x/%d/%x", address, incarnation, *key)] = *value
+	return nil
+}
+
+func (w *mapWriter) CreateContract(address types.Address) error {
+	return nil
+}
+
+// testTx stands for the state changes of a transaction.
+type testTx func(ibs *IntraBlockState)
+
+func transfer(from, to types.Address, amount uint64) testTx {
+	return func(ibs *IntraBlockState) {
+		ibs.SetNonce(from, ibs.GetNonce(from)+1)
+		ibs.SubBalance(from, uint256.NewInt(amount))
+		ibs.AddBalance(to, uint256.NewInt(amount))
+	}
+}
+
+// payCoinbase adds fee to the coinbase without reading it, like the fees of
+// every transaction.
+func payCoinbase(tx testTx, fee uint64) testTx {
+	return func(ibs *IntraBlockState) {
+		tx(ibs)
+		ibs.AddBalance(coinbase, uint256.NewInt(fee))
+	}
+}
+
+func emitLog(tx testTx, addr types.Address) testTx {
+	return func(ibs *IntraBlockState) {
+		tx(ibs)
+		ibs.AddLog(&block.Log{Address: addr})
+	}
+}
+
+// executionResult is the state written by a block and its logs.
+type executionResult struct {
+	writer *mapWriter
+	logs   []block.Log
+}
+
+func txHash(i int) types.Hash {
+	return types.Hash{31: byte(i + 1)}
+}
+
+// executeSerial applies txs one after the other.
+func executeSerial(t *testing.T, reader StateReader, txs []testTx) *executionResult {
+	var (
+		ibs = New(reader)
+		w   = newMapWriter()
+		res = &executionResult{writer: w}
+	)
+	for i, tx := range txs {
+		ibs.Prepare(txHash(i), types.Hash{}, i)
+		tx(ibs)
+		res.logs = append(res.logs, logValues(ibs.GetLogs(txHash(i)))...)
+		if err := ibs.FinalizeTx(mergeRules, w); err != nil {
+			t.Fatalf("FinalizeTx failed: %v", err)
+		}
+	}
+	if err := ibs.CommitBlock(mergeRules, w); err != nil {
+		t.Fatalf("CommitBlock failed: %v", err)
+	}
+	return res
+}
+
+// executeParallel first applies every tx on a fresh state over reader, then
+// goes through them in order, merging the changes of those which read no
+// account changed by the transactions before and applying the others again.
+// It also returns the indexes of the applied again transactions.
+func executeParallel(t *testing.T, reader StateReader, txs []testTx) (*executionResult, []int) {
+	type speculative struct {
+		ibs  *IntraBlockState
+		read map[types.Address]struct{}
+	}
+	specs := make([]speculative, len(txs))
+	for i, tx := range txs {
+		read := make(map[types.Address]struct{})
+		spec := New(&recordingReader{StateReader: reader, read: read})
+		spec.Prepare(txHash(i), types.Hash{}, i)
+		tx(spec)
+		specs[i] = speculative{ibs: spec, read: read}
+	}
+
+	var (
+		ibs        = New(reader)
+		w          = newMapWriter()
+		res        = &executionResult{writer: w}
+		reexecuted []int
+	)
+	for i, tx := range txs {
+		ibs.Prepare(txHash(i), types.Hash{}, i)
+		valid := true
+		for addr := range specs[i].read {
+			if ibs.Changed(addr) {
+				valid = false
+			}
+		}
+		if valid {
+			ibs.MergeTx(specs[i].ibs)
+		} else {
+			tx(ibs)
+			reexecuted = append(reexecuted, i)
+		}
+		res.logs = append(res.logs, logValues(ibs.GetLogs(txHash(i)))...)
+		if err := ibs.FinalizeTx(mergeRules, w); err != nil {
+			t.Fatalf("FinalizeTx failed: %v", err)
+		}
+	}
+	if err := ibs.CommitBlock(mergeRules, w); err != nil {
+		t.Fatalf("CommitBlock failed: %v", err)
+	}
+	return res, reexecuted
+}
+
+func balanceOf(res *executionResult, addr types.Address) uint64 {
+	acc := res.writer.accounts[addr]
+	return acc.Balance.Uint64()
+}
+
+// storageOf returns the value written to the slot of the given incarnation of
+// addr, and whether it was written.
+func storageOf(res *executionResult, addr types.Address, incarnation uint16, slot types.Hash) (uint64, bool) {
+	value, ok := res.writer.storage[fmt.Sprintf("%x/%d/%x", addr, incarnation, slot)]
+	return value.Uint64(), ok
+}
+
+func logValues(logs []*block.Log) []block.Log {
+	values := make([]block.Log, len(logs))
+	for i, l := range logs {
+		values[i] = *l
+	}
+	return values
+}
+
+func newMapReader() *mapReader {
+	r := &mapReader{
+		accounts: make(map[types.Address]*account.StateAccount),
+		storage:  make(map[types.Address]map[types.Hash]uint256.Int),
+		code:     make(map[types.Hash][]byte),
+	}
+	for _, addr := range []types.Address{alice, bob, carol, dave} {
+		acc := account.NewAccount()
+		acc.Initialised = true
+		acc.Balance.SetUint64(1000)
+		r.accounts[addr] = &acc
+	}
+	code := []byte{0x60, 0x00}
+	codeHash := crypto.Keccak256Hash(code)
+	acc := account.NewAccount()
+	acc.Initialised = true
+	acc.Balance.SetUint64(500)
+	acc.Incarnation = FirstContractIncarnation
+	acc.CodeHash = codeHash
+	r.accounts[contract] = &acc
+	r.code[codeHash] = code
+	r.storage[contract] = map[types.Hash]uint256.Int{slot1: *uint256.NewInt(7)}
+	return r
+}
+
+func checkSameExecution(t *testing.T, txs []testTx, wantReexecuted []int) *executionResult {
+	t.Helper()
+	serial := executeSerial(t, newMapReader(), txs)
+	parallel, reexecuted := executeParallel(t, newMapReader(), txs)
+	if !reflect.DeepEqual(parallel.writer, serial.writer) {
+		t.Errorf("parallel state differs\nhave %+v\nwant %+v", parallel.writer, serial.writer)
+	}
+	if !reflect.DeepEqual(parallel.logs, serial.logs) {
+		t.Errorf("parallel logs differ\nhave %+v\nwant %+v", parallel.logs, serial.logs)
+	}
+	if !reflect.DeepEqual(reexecuted, wantReexecuted) {
+		t.Errorf("applied again %v, want %v", reexecuted, wantReexecuted)
+	}
+	return serial
+}
+
+func TestMergeIndependentTxs(t *testing.T) {
+	res := checkSameExecution(t, []testTx{
+		emitLog(transfer(alice, bob, 10), alice),
+		emitLog(transfer(carol, dave, 20), carol),
+		func(ibs *IntraBlockState) {
+			var value uint256.Int
+			ibs.GetState(contract, &slot1, &value)
+			ibs.SetState(contract, &slot2, *value.AddUint64(&value, 1))
+		},
+	}, nil)
+
+	if value, ok := storageOf(res, contract, FirstContractIncarnation, slot2); !ok || value != 8 {
+		t.Errorf("slot2 is %d, %v, want 8", value, ok)
+	}
+	if _, ok := storageOf(res, contract, FirstContractIncarnation, slot1); ok {
+		t.Error("slot1 written")
+	}
+}
+
+func TestMergeStorageConflict(t *testing.T) {
+	res := checkSameExecution(t, []testTx{
+		func(ibs *IntraBlockState) {
+			ibs.SetState(contract, &slot1, *uint256.NewInt(10))
+		},
+		// Reads the slot written by the transaction before
+		func(ibs *IntraBlockState) {
+			var value uint256.Int
+			ibs.GetState(contract, &slot1, &value)
+			ibs.SetState(contract, &slot2, *value.AddUint64(&value, 1))
+		},
+	}, []int{1})
+
+	if value, ok := storageOf(res, contract, FirstContractIncarnation, slot2); !ok || value != 11 {
+		t.Errorf("slot2 is %d, %v, want 11", value, ok)
+	}
+}
+
+func TestMergeConflictingTxs(t *testing.T) {
+	res := checkSameExecution(t, []testTx{
+		transfer(alice, bob, 10),
+		// Spends what bob received from alice
+		transfer(bob, carol, 1005),
+		transfer(carol, alice, 1),
+		transfer(dave, contract, 5),
+	}, []int{1, 2})
+
+	if balance := balanceOf(res, bob); balance != 5 {
+		t.Errorf("bob has %d, want 5", balance)
+	}
+}
+
+func TestMergeSelfdestructAndRecreate(t *testing.T) {
+	code := []byte{0x60, 0x01}
+	res := checkSameExecution(t, []testTx{
+		func(ibs *IntraBlockState) {
+			ibs.AddBalance(alice, ibs.GetBalance(contract))
+			ibs.Selfdestruct(contract)
+		},
+		func(ibs *IntraBlockState) {
+			ibs.CreateAccount(contract, true)
+			ibs.SetCode(contract, code)
+			ibs.SetState(contract, &slot2, *uint256.NewInt(9))
+		},
+		transfer(carol, dave, 1),
+	}, []int{1})
+
+	acc, ok := res.writer.accounts[contract]
+	if !ok {
+		t.Fatal("recreated contract missing")
+	}
+	if acc.CodeHash != crypto.Keccak256Hash(code) {
+		t.Errorf("recreated contract has code hash %x", acc.CodeHash)
+	}
+	if acc.Incarnation != FirstContractIncarnation+1 {
+		t.Errorf("recreated contract has incarnation %d, want %d", acc.Incarnation, FirstContractIncarnation+1)
+	}
+	if value, ok := storageOf(res, contract, FirstContractIncarnation+1, slot2); !ok || value != 9 {
+		t.Errorf("slot2 of the recreated contract is %d, %v, want 9", value, ok)
+	}
+}
+
+func TestMergeCoinbaseOfEveryTx(t *testing.T) {
+	res := checkSameExecution(t, []testTx{
+		payCoinbase(transfer(alice, bob, 10), 1),
+		payCoinbase(transfer(carol, dave, 10), 2),
+		// Credits alice without reading her account
+		payCoinbase(transfer(contract, alice, 10), 3),
+	}, nil)
+
+	if balance := balanceOf(res, coinbase); balance != 6 {
+		t.Errorf("coinbase has %d, want 6", balance)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/n42blockchain/N42/common/account"
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/modules"
+	"github.com/n42blockchain/N42/modules/changeset"
+	"github.com/n42blockchain/N42/modules/ethdb/bitmapdb"
+)
+
+// UnwindPlainState reverts the plain state to how it was after block target
+// was executed, using the change sets of the blocks above it. The change sets
+// and history indices of those blocks are removed as well.
+func UnwindPlainState(tx kv.RwTx, target uint64) error {
+	accounts, err := firstChanges(tx, modules.AccountChangeSet, target+1)
+	if err != nil {
+		return err
+	}
+	storage, err := firstChanges(tx, modules.StorageChangeSet, target+1)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range accounts {
+		if err := restoreAccount(tx, []byte(k), v); err != nil {
+			return err
+		}
+		if err := bitmapdb.TruncateRange64(tx, modules.AccountsHistory, modules.CompositeKeyWithoutIncarnation([]byte(k)), target+1); err != nil {
+			return err
+		}
+	}
+	for k, v := range storage {
+		if len(v) == 0 {
+			err = tx.Delete(modules.Storage, []byte(k))
+		} else {
+			err = tx.Put(modules.Storage, []byte(k), v)
+		}
+		if err != nil {
+			return err
+		}
+		if err := bitmapdb.TruncateRange64(tx, modules.StorageHistory, modules.CompositeKeyWithoutIncarnation([]byte(k)), target+1); err != nil {
+			return err
+		}
+	}
+	return changeset.Truncate(tx, target+1)
+}
+
+// firstChanges returns, for every key changed at or above block from, the
+// value it had before the first of those changes.
+func firstChanges(tx kv.Tx, bucket string, from uint64) (map[string][]byte, error) {
+	changes := make(map[string][]byte)
+	if err := changeset.ForEach(tx, bucket, modules.EncodeBlockNumber(from), func(_ uint64, k, v []byte) error {
+		if _, ok := changes[string(k)]; !ok {
+			changes[string(k)] = types.CopyBytes(v)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking %s: %w", bucket, err)
+	}
+	return changes, nil
+}
+
+// restoreAccount writes back the account at address as recorded in a change
+// set, deleting it if it did not exist.
+func restoreAccount(tx kv.RwTx, address, enc []byte) error {
+	if len(enc) == 0 {
+		return tx.Delete(modules.Account, address)
+	}
+	var acc account.StateAccount
+	if err := acc.DecodeForStorage(enc); err != nil {
+		return err
+	}
+	// Change sets omit the code hash of contracts, recover it from the
+	// code table of the account's incarnation.
+	if acc.Incarnation > 0 && acc.IsEmptyCodeHash() {
+		codeHash, err := tx.GetOne(modules.PlainContractCode, modules.PlainGenerateStoragePrefix(address, acc.Incarnation))
+		if err != nil {
+			return err
+		}
+		if len(codeHash) > 0 {
+			acc.CodeHash = types.BytesToHash(codeHash)
+		}
+	}
+	data := make([]byte, acc.EncodingLengthForStorage())
+	acc.EncodeForStorage(data)
+	return tx.Put(modules.Account, address, data)
+}
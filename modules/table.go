@@ -59,6 +59,8 @@ const (
 
 	StorageChangeSet = "StorageChangeSet" // blockNum_u64 + address + incarnation_u64 ->  plain_storage_key + value
 	StorageHistory   = "StorageHistory"   // address + storage_key + shard_id_u64 -> roaring bitmap - list of block where it changed
+
+	RewardChangeSet = "RewardChangeSet" // blockNum_u64 + address -> unpaid reward of the account before the block
 )
 
 // Block
@@ -73,6 +75,11 @@ const (
 
 	HeadHeaderKey = "LastHeader"
 
+	// FinalizedBlockKey and SafeBlockKey track the hashes of the finalized and
+	// safe blocks, the ones of the "finalized" and "safe" block tags.
+	FinalizedBlockKey = "LastFinalized"
+	SafeBlockKey      = "LastSafe"
+
 	BlockBody       = "BlockBody"               // block_num_u64 + hash -> block body
 	BlockTx         = "BlockTransaction"        // tbl_sequence_u64 -> (tx)
 	NonCanonicalTxs = "NonCanonicalTransaction" // tbl_sequence_u64 -> rlp(tx)
@@ -112,6 +119,12 @@ const (
 
 	Stake = "Stake" // stakes   ast_stake -> bytes
 
+	BadBlocks = "BadBlock" // block_num_u64 + hash -> json(bad block), see rawdb.WriteBadBlock
+
+	// Slashing protection history of the local validator keys, see rawdb.WriteSignedBlock
+	SignedBlocks       = "SignedBlock"       // bls_pubkey + block_num_u64 -> signing root
+	SignedAttestations = "SignedAttestation" // bls_pubkey + target_epoch_u64 -> source_epoch_u64 + signing root
+
 )
 
 const (
@@ -133,6 +146,7 @@ var astTables = []string{
 	AccountChangeSet,
 	StorageHistory,
 	StorageChangeSet,
+	RewardChangeSet,
 
 	Headers,
 	HeaderTD,
@@ -141,6 +155,8 @@ var astTables = []string{
 
 	HeadBlockKey,
 	HeadHeaderKey,
+	FinalizedBlockKey,
+	SafeBlockKey,
 
 	BlockBody,
 	BlockTx,
@@ -149,6 +165,8 @@ var astTables = []string{
 	Senders,
 	Receipts,
 	Log,
+	LogAddressIndex,
+	LogTopicIndex,
 
 	SignersDB,
 	PoaSnapshot,
@@ -158,6 +176,9 @@ var astTables = []string{
 	Deposit,
 	BlockVerify,
 	BlockRewards,
+	BadBlocks,
+	SignedBlocks,
+	SignedAttestations,
 }
 
 var AstTableCfg = kv.TableCfg{
@@ -171,6 +192,16 @@ var AstTableCfg = kv.TableCfg{
 	},
 }
 
+// AstTables returns the sorted names of all tables of the chain database.
+func AstTables() []string {
+	tables := make([]string, 0, len(AstTableCfg))
+	for name := range AstTableCfg {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
 func AstInit() {
 	sort.SliceStable(astTables, func(i, j int) bool {
 		return strings.Compare(astTables[i], astTables[j]) < 0
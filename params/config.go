@@ -24,7 +24,6 @@ import (
 	"github.com/n42blockchain/N42/common/paths"
 	"github.com/n42blockchain/N42/common/types"
 	"github.com/n42blockchain/N42/internal/avm/common"
-	"github.com/n42blockchain/N42/params/networkname"
 	"golang.org/x/crypto/sha3"
 	"math/big"
 	"path"
@@ -157,6 +156,14 @@ type ChainConfig struct {
 	Eip1559FeeCollector           *types.Address `json:"eip1559FeeCollector,omitempty"`           // (Optional) Address where burnt EIP-1559 fees go to
 	Eip1559FeeCollectorTransition *big.Int       `json:"eip1559FeeCollectorTransition,omitempty"` // (Optional) Block from which burnt EIP-1559 fees go to the Eip1559FeeCollector
 
+	// EIP-1559 fee market parameters, the protocol defaults are used if unset
+	Eip1559BaseFeeChangeDenominator uint64   `json:"eip1559BaseFeeChangeDenominator,omitempty"` // (Optional) Bounds the amount the base fee can change between blocks
+	Eip1559ElasticityMultiplier     uint64   `json:"eip1559ElasticityMultiplier,omitempty"`     // (Optional) Ratio of the gas limit to the gas target of a block
+	Eip1559MinBaseFee               *big.Int `json:"eip1559MinBaseFee,omitempty"`               // (Optional) Floor the base fee never drops below
+
+	// Precompiled contracts added by the chain on top of those of the forks
+	Precompiles []PrecompileConfig `json:"precompiles,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -166,6 +173,14 @@ type ChainConfig struct {
 	Apos   *APosConfig   `json:"apos,omitempty"`
 }
 
+// PrecompileConfig activates the precompiled contract registered under Name
+// in the EVM at Address from Block on.
+type PrecompileConfig struct {
+	Name    string        `json:"name"`
+	Address types.Address `json:"address"`
+	Block   *big.Int      `json:"block"`
+}
+
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
 type EthashConfig struct{}
 
@@ -346,6 +361,7 @@ func NewSnapshotConfig(checkpointInterval uint64, inmemorySnapshots int, inmemor
 var NetworkNames = map[string]string{
 	"100100100": "testnet",
 	"94":        "mainnet",
+	"4242":      "devnet",
 	//"131":       "testnet",
 }
 
@@ -514,6 +530,41 @@ func (c *ChainConfig) IsEip1559FeeCollector(num uint64) bool {
 	return c.Eip1559FeeCollector != nil && isForked(c.Eip1559FeeCollectorTransition, num)
 }
 
+// BaseFeeChangeDenominator bounds the amount the base fee can change between
+// blocks.
+func (c *ChainConfig) BaseFeeChangeDenominator() uint64 {
+	if c.Eip1559BaseFeeChangeDenominator != 0 {
+		return c.Eip1559BaseFeeChangeDenominator
+	}
+	return BaseFeeChangeDenominator
+}
+
+// ElasticityMultiplier bounds the maximum gas limit an EIP-1559 block may have
+// relative to its gas target.
+func (c *ChainConfig) ElasticityMultiplier() uint64 {
+	if c.Eip1559ElasticityMultiplier != 0 {
+		return c.Eip1559ElasticityMultiplier
+	}
+	return ElasticityMultiplier
+}
+
+// MinBaseFee returns the floor of the base fee, zero if there is none.
+func (c *ChainConfig) MinBaseFee() *big.Int {
+	if c.Eip1559MinBaseFee != nil {
+		return new(big.Int).Set(c.Eip1559MinBaseFee)
+	}
+	return new(big.Int)
+}
+
+// InitialBaseFee returns the base fee of the first EIP-1559 block.
+func (c *ChainConfig) InitialBaseFee() *big.Int {
+	fee := new(big.Int).SetUint64(InitialBaseFee)
+	if floor := c.MinBaseFee(); floor.Cmp(fee) > 0 {
+		return floor
+	}
+	return fee
+}
+
 //func (c *ChainConfig) IsMoran(num uint64) bool {
 //	return isForked(c.MoranBlock, num)
 //}
@@ -658,6 +709,24 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head uint64) *ConfigC
 	if isForkIncompatible(c.CancunBlock, newcfg.CancunBlock, head) {
 		return newCompatError("Cancun fork block", c.CancunBlock, newcfg.CancunBlock)
 	}
+	if isForkIncompatible(c.BeijingBlock, newcfg.BeijingBlock, head) {
+		return newCompatError("Beijing fork block", c.BeijingBlock, newcfg.BeijingBlock)
+	}
+	// The consensus engine and its parameters have no fork block, changing
+	// them alters the chain from the genesis on.
+	if head > 0 && c.Consensus != newcfg.Consensus {
+		return newCompatError("consensus engine", nil, nil)
+	}
+	if head > 0 && !aposConfigEqual(c.Apos, newcfg.Apos) {
+		return newCompatError("APoS consensus parameters", nil, nil)
+	}
+	if c.IsLondon(head) && (c.BaseFeeChangeDenominator() != newcfg.BaseFeeChangeDenominator() ||
+		c.ElasticityMultiplier() != newcfg.ElasticityMultiplier() || c.MinBaseFee().Cmp(newcfg.MinBaseFee()) != 0) {
+		return newCompatError("EIP-1559 parameters", c.LondonBlock, newcfg.LondonBlock)
+	}
+	if err := checkPrecompilesCompatible(c.Precompiles, newcfg.Precompiles, head); err != nil {
+		return err
+	}
 
 	// Parlia forks
 	//if isForkIncompatible(c.RamanujanBlock, newcfg.RamanujanBlock, head) {
@@ -687,6 +756,44 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head uint64) *ConfigC
 	return nil
 }
 
+// checkPrecompilesCompatible reports the lowest precompile activation that
+// differs between the stored and the new chain config at or below head.
+func checkPrecompilesCompatible(stored, newcfg []PrecompileConfig, head uint64) *ConfigCompatError {
+	activation := func(list []PrecompileConfig, p PrecompileConfig) *big.Int {
+		for _, q := range list {
+			if q.Address == p.Address && q.Name == p.Name {
+				return q.Block
+			}
+		}
+		return nil
+	}
+	var lowest *ConfigCompatError
+	check := func(p PrecompileConfig, s1, s2 *big.Int) {
+		if isForkIncompatible(s1, s2, head) {
+			err := newCompatError(fmt.Sprintf("precompile %s at %s", p.Name, p.Address), s1, s2)
+			if lowest == nil || err.RewindTo < lowest.RewindTo {
+				lowest = err
+			}
+		}
+	}
+	for _, p := range stored {
+		check(p, p.Block, activation(newcfg, p))
+	}
+	for _, p := range newcfg {
+		check(p, activation(stored, p), p.Block)
+	}
+	return lowest
+}
+
+func aposConfigEqual(x, y *APosConfig) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Period == y.Period && x.Epoch == y.Epoch && x.RewardEpoch == y.RewardEpoch &&
+		configNumEqual(x.RewardLimit, y.RewardLimit) && x.DepositContract == y.DepositContract &&
+		x.DepositNFTContract == y.DepositNFTContract && x.DepositFUJIContract == y.DepositFUJIContract
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2 *big.Int, head uint64) bool {
@@ -739,6 +846,9 @@ func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatEr
 }
 
 func (err *ConfigCompatError) Error() string {
+	if err.StoredConfig == nil && err.NewConfig == nil {
+		return fmt.Sprintf("mismatching %s in database (rewindto %d)", err.What, err.RewindTo)
+	}
 	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
 
@@ -755,6 +865,10 @@ type Rules struct {
 	IsNano, IsMoran                                         bool
 	IsEip1559FeeCollector                                   bool
 	IsParlia, IsStarknet, IsAura, IsBeijing                 bool
+
+	// Precompiles maps the addresses of the active precompiles of the chain
+	// config to their names.
+	Precompiles map[types.Address]string
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -763,6 +877,15 @@ func (c *ChainConfig) Rules(num uint64) *Rules {
 	if chainID == nil {
 		chainID = new(big.Int)
 	}
+	var precompiles map[types.Address]string
+	for _, p := range c.Precompiles {
+		if isForked(p.Block, num) {
+			if precompiles == nil {
+				precompiles = make(map[types.Address]string)
+			}
+			precompiles[p.Address] = p.Name
+		}
+	}
 	return &Rules{
 		ChainID:               new(big.Int).Set(chainID),
 		IsHomestead:           c.IsHomestead(num),
@@ -783,55 +906,38 @@ func (c *ChainConfig) Rules(num uint64) *Rules {
 		IsParlia:              c.Parlia != nil,
 		IsAura:                c.Aura != nil,
 		IsBeijing:             c.IsBeijing(num),
+		Precompiles:           precompiles,
 	}
 }
 
 func ChainConfigByChainName(chain string) *ChainConfig {
-	switch chain {
-	case networkname.MainnetChainName:
-		return MainnetChainConfig
-	case networkname.TestnetChainName:
-		return TestnetChainConfig
-	default:
-		return nil
+	if preset := NetworkPresetByName(chain); preset != nil {
+		return preset.ChainConfig
 	}
+	return nil
 }
 
+// GenesisHashByChainName returns the expected genesis hash of a built-in network.
+// Networks with a locally generated genesis (devnet) and unknown chains return nil.
 func GenesisHashByChainName(chain string) *types.Hash {
-	switch chain {
-	case networkname.MainnetChainName:
-		return &MainnetGenesisHash
-	case networkname.TestnetChainName:
-		return &TestnetGenesisHash
-	default:
-		return nil
+	if preset := NetworkPresetByName(chain); preset != nil && preset.GenesisHash != (types.Hash{}) {
+		return &preset.GenesisHash
 	}
+	return nil
 }
 
 func ChainConfigByGenesisHash(genesisHash types.Hash) *ChainConfig {
-	switch {
-	case genesisHash == MainnetGenesisHash:
-		return MainnetChainConfig
-	case genesisHash == TestnetGenesisHash:
-		return TestnetChainConfig
-	default:
-		return nil
+	if preset := NetworkPresetByGenesisHash(genesisHash); preset != nil {
+		return preset.ChainConfig
 	}
+	return nil
 }
 
 func NetworkIDByChainName(chain string) uint64 {
-	switch chain {
-	case networkname.MainnetChainName:
-		return 97
-	case networkname.TestnetChainName:
-		return 10042
-	default:
-		config := ChainConfigByChainName(chain)
-		if config == nil {
-			return 0
-		}
-		return config.ChainID.Uint64()
+	if preset := NetworkPresetByName(chain); preset != nil {
+		return preset.NetworkID
 	}
+	return 0
 }
 
 // TrustedCheckpoint represents a set of post-processed trie roots (CHT and
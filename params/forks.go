@@ -0,0 +1,143 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/big"
+	"sort"
+
+	"github.com/n42blockchain/N42/common/types"
+)
+
+// Fork is a scheduled fork of a chain config, activated either at a block
+// number or, for time based forks, at a block timestamp.
+type Fork struct {
+	Name  string
+	Block *big.Int // nil for time based forks
+	Time  *big.Int // nil for block based forks
+}
+
+// Active reports whether the fork is active in a block with the given number
+// and timestamp.
+func (f Fork) Active(num, time uint64) bool {
+	if f.Block != nil {
+		return isForked(f.Block, num)
+	}
+	return isForked(f.Time, time)
+}
+
+// Forks returns the forks scheduled by the chain config, block based forks
+// first. Forks that are not scheduled are left out.
+func (c *ChainConfig) Forks() []Fork {
+	var forks []Fork
+	for _, f := range []Fork{
+		{Name: "homestead", Block: c.HomesteadBlock},
+		{Name: "daoFork", Block: c.DAOForkBlock},
+		{Name: "tangerineWhistle", Block: c.TangerineWhistleBlock},
+		{Name: "spuriousDragon", Block: c.SpuriousDragonBlock},
+		{Name: "byzantium", Block: c.ByzantiumBlock},
+		{Name: "constantinople", Block: c.ConstantinopleBlock},
+		{Name: "petersburg", Block: c.PetersburgBlock},
+		{Name: "istanbul", Block: c.IstanbulBlock},
+		{Name: "muirGlacier", Block: c.MuirGlacierBlock},
+		{Name: "berlin", Block: c.BerlinBlock},
+		{Name: "london", Block: c.LondonBlock},
+		{Name: "arrowGlacier", Block: c.ArrowGlacierBlock},
+		{Name: "grayGlacier", Block: c.GrayGlacierBlock},
+		{Name: "mergeNetsplit", Block: c.MergeNetsplitBlock},
+		{Name: "shanghai", Block: c.ShanghaiBlock},
+		{Name: "cancun", Block: c.CancunBlock},
+		{Name: "nano", Block: c.NanoBlock},
+		{Name: "moran", Block: c.MoranBlock},
+		{Name: "beijing", Block: c.BeijingBlock},
+		{Name: "prague", Time: c.PragueTime},
+	} {
+		if f.Block != nil || f.Time != nil {
+			forks = append(forks, f)
+		}
+	}
+	return forks
+}
+
+// ForkID is the identifier of the rules a chain follows in the spirit of
+// EIP-2124: Hash is the CRC32 checksum of the genesis hash and the activation
+// points of the forks already passed, Next the activation point of the next
+// scheduled fork, or 0 if there is none. Nodes with the same Hash run the same
+// rules, and agree on the next fork if their Next matches too.
+type ForkID struct {
+	Hash [4]byte
+	Next uint64
+}
+
+// ForkID returns the fork identifier of the chain with genesis hash genesis
+// at the block with the given number and timestamp.
+func (c *ChainConfig) ForkID(genesis types.Hash, num, time uint64) ForkID {
+	blocks, times := c.forkPoints()
+	hash := crc32.ChecksumIEEE(genesis[:])
+	for _, at := range blocks {
+		if at > num {
+			return ForkID{Hash: checksumToBytes(hash), Next: at}
+		}
+		hash = checksumUpdate(hash, at)
+	}
+	for _, at := range times {
+		if at > time {
+			return ForkID{Hash: checksumToBytes(hash), Next: at}
+		}
+		hash = checksumUpdate(hash, at)
+	}
+	return ForkID{Hash: checksumToBytes(hash)}
+}
+
+// forkPoints returns the activation blocks and timestamps of the scheduled
+// forks in ascending order. As in EIP-2124 forks active from genesis and forks
+// sharing an activation point count once.
+func (c *ChainConfig) forkPoints() (blocks, times []uint64) {
+	for _, f := range c.Forks() {
+		if f.Block != nil {
+			blocks = append(blocks, f.Block.Uint64())
+		} else {
+			times = append(times, f.Time.Uint64())
+		}
+	}
+	return uniqueForkPoints(blocks), uniqueForkPoints(times)
+}
+
+func uniqueForkPoints(points []uint64) []uint64 {
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+	var unique []uint64
+	for _, at := range points {
+		if at != 0 && (len(unique) == 0 || unique[len(unique)-1] != at) {
+			unique = append(unique, at)
+		}
+	}
+	return unique
+}
+
+func checksumUpdate(hash uint32, at uint64) uint32 {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], at)
+	return crc32.Update(hash, crc32.IEEETable, blob[:])
+}
+
+func checksumToBytes(hash uint32) [4]byte {
+	var blob [4]byte
+	binary.BigEndian.PutUint32(blob[:], hash)
+	return blob
+}
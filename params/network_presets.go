@@ -0,0 +1,116 @@
+// Copyright 2024 The N42 Authors
+// This file is part of the N42 library.
+//
+// The N42 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The N42 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"github.com/n42blockchain/N42/common/types"
+	"github.com/n42blockchain/N42/params/networkname"
+)
+
+// DevnetChainConfig contains the chain parameters of the local developer network.
+var DevnetChainConfig = readChainSpec("chainspecs/devnet.json")
+
+// DevnetBootnodes is empty, a devnet is expected to run as a single node.
+var DevnetBootnodes []string
+
+// NetworkPreset bundles everything a node needs to join a well-known network
+// by name, so that NodeConfig.Chain alone is enough to pick it up.
+type NetworkPreset struct {
+	Name      string
+	NetworkID uint64
+
+	// GenesisHash is the expected hash of the genesis block. It is empty for
+	// networks whose genesis is generated locally (e.g. devnet).
+	GenesisHash types.Hash
+	ChainConfig *ChainConfig
+	Bootnodes   []string
+	// Checkpoint is a finalized block new nodes sync from, nil if none.
+	Checkpoint *SyncCheckpoint
+
+	// Default listening ports, used when the configuration leaves them unset.
+	HTTPPort int
+	WSPort   int
+	AuthPort int
+	TCPPort  int
+	UDPPort  int
+}
+
+// SyncCheckpoint is a finalized block trusted by its number and hash. A node
+// syncing from it only accepts a chain containing it, and does not validate
+// the blocks below it.
+type SyncCheckpoint struct {
+	Number uint64
+	Hash   types.Hash
+}
+
+// NetworkPresets maps a chain name to its built-in preset.
+var NetworkPresets = map[string]*NetworkPreset{
+	networkname.MainnetChainName: {
+		Name:        networkname.MainnetChainName,
+		NetworkID:   97,
+		GenesisHash: MainnetGenesisHash,
+		ChainConfig: MainnetChainConfig,
+		Bootnodes:   MainnetBootnodes,
+		HTTPPort:    20012,
+		WSPort:      20013,
+		AuthPort:    8551,
+		TCPPort:     61016,
+		UDPPort:     61015,
+	},
+	networkname.TestnetChainName: {
+		Name:        networkname.TestnetChainName,
+		NetworkID:   10042,
+		GenesisHash: TestnetGenesisHash,
+		ChainConfig: TestnetChainConfig,
+		Bootnodes:   TestnetBootnodes,
+		HTTPPort:    20012,
+		WSPort:      20013,
+		AuthPort:    8551,
+		TCPPort:     61016,
+		UDPPort:     61015,
+	},
+	networkname.DevnetChainName: {
+		Name:        networkname.DevnetChainName,
+		NetworkID:   4242,
+		ChainConfig: DevnetChainConfig,
+		Bootnodes:   DevnetBootnodes,
+		HTTPPort:    8545,
+		WSPort:      8546,
+		AuthPort:    8551,
+		TCPPort:     30303,
+		UDPPort:     30303,
+	},
+}
+
+// NetworkPresetByName returns the preset registered for chain, or nil if the
+// chain is not a built-in network.
+func NetworkPresetByName(chain string) *NetworkPreset {
+	return NetworkPresets[chain]
+}
+
+// NetworkPresetByGenesisHash returns the preset whose genesis hash matches, or nil.
+func NetworkPresetByGenesisHash(genesisHash types.Hash) *NetworkPreset {
+	if genesisHash == (types.Hash{}) {
+		return nil
+	}
+	for _, preset := range NetworkPresets {
+		if preset.GenesisHash == genesisHash {
+			return preset
+		}
+	}
+	return nil
+}
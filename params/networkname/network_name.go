@@ -3,9 +3,11 @@ package networkname
 const (
 	MainnetChainName = "mainnet"
 	TestnetChainName = "testnet"
+	DevnetChainName  = "devnet"
 )
 
 var All = []string{
 	MainnetChainName,
 	TestnetChainName,
+	DevnetChainName,
 }
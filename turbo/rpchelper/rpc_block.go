@@ -17,6 +17,7 @@
 package rpchelper
 
 import (
+	"errors"
 	"fmt"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -31,12 +32,20 @@ func GetLatestBlockNumber(tx kv.Tx) (*uint256.Int, error) {
 	return current.Number64(), nil
 }
 
+// GetFinalizedBlockNumber returns the number of the finalized block.
 func GetFinalizedBlockNumber(tx kv.Tx) (*uint256.Int, error) {
-	//todo
-	return GetLatestBlockNumber(tx)
+	number := rawdb.ReadHeaderNumber(tx, rawdb.ReadFinalizedBlockHash(tx))
+	if number == nil {
+		return nil, errors.New("finalized block not found")
+	}
+	return uint256.NewInt(*number), nil
 }
 
+// GetSafeBlockNumber returns the number of the safe block.
 func GetSafeBlockNumber(tx kv.Tx) (*uint256.Int, error) {
-	//todo
-	return GetLatestBlockNumber(tx)
+	number := rawdb.ReadHeaderNumber(tx, rawdb.ReadSafeBlockHash(tx))
+	if number == nil {
+		return nil, errors.New("safe block not found")
+	}
+	return uint256.NewInt(*number), nil
 }
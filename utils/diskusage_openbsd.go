@@ -17,7 +17,7 @@
 //go:build openbsd
 // +build openbsd
 
-package main
+package utils
 
 import (
 	"fmt"
@@ -25,7 +25,9 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func getFreeDiskSpace(path string) (uint64, error) {
+// FreeDiskSpace returns the number of bytes available to the user on the file
+// system holding path.
+func FreeDiskSpace(path string) (uint64, error) {
 	var stat unix.Statfs_t
 	if err := unix.Statfs(path, &stat); err != nil {
 		return 0, fmt.Errorf("failed to call Statfs: %v", err)
@@ -14,7 +14,7 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the N42 library. If not, see <http://www.gnu.org/licenses/>.
 
-package main
+package utils
 
 import (
 	"fmt"
@@ -22,7 +22,9 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-func getFreeDiskSpace(path string) (uint64, error) {
+// FreeDiskSpace returns the number of bytes available to the user on the file
+// system holding path.
+func FreeDiskSpace(path string) (uint64, error) {
 
 	cwd, err := windows.UTF16PtrFromString(path)
 	if err != nil {